@@ -0,0 +1,687 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	util "teachat/Util"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+友邻蒙评申诉（accept_appeals）：
+1、友邻蒙评婉拒后 AcceptAppealWindowDays 天内，作者可对最近一轮的婉拒提交修订稿及申诉理由，
+   同一对象最多申诉 AcceptAppealMaxRounds 次；
+2、申诉时对象恢复为婉拒前的草稿并换上修订稿（茶围、茶台、茶议可改标题和内容，品味改内容，茶团、集团改宗旨），
+   另建一个蒙评对象作为复审的一轮；
+3、最后一次申诉由茶博士复审，此前的由新的一对友邻评审官复审（此前各轮的评审官回避），找不到评审官时转由茶博士复审；
+   茶博士复审为终审；
+4、复审得出结论后：维持婉拒的，被申诉一轮的评审意见记为复核维持；接纳未经修改的原稿的，记为复核推翻；
+   接纳修订稿的不计入原评审官信誉；
+5、同一对象历次蒙评对象按 id 排列即其结论链，在申诉页及经过申诉发布的对象详情页展示。
+*/
+
+// 申诉状态
+const (
+	AcceptAppealStatus_Pending  = "pending"  // 复审中
+	AcceptAppealStatus_Accepted = "accepted" // 复审接纳
+	AcceptAppealStatus_Rejected = "rejected" // 复审维持婉拒
+)
+
+// 复审方
+const (
+	AcceptAppealReviewer_Neighbor = "neighbor" // 新的一对友邻评审官
+	AcceptAppealReviewer_Office   = "office"   // 茶博士
+)
+
+const (
+	AcceptAppealJustificationMaxLen = 1000
+	acceptAppealTitleMaxLen         = 64
+	acceptAppealListLimit           = 50
+)
+
+var (
+	ErrAcceptAppealNotRejected   = errors.New("只能对友邻蒙评婉拒的最近一轮提出申诉")
+	ErrAcceptAppealNotAuthor     = errors.New("只有作者本人可以申诉")
+	ErrAcceptAppealExpired       = errors.New("已超过申诉期限")
+	ErrAcceptAppealNoRounds      = errors.New("申诉次数已经用完")
+	ErrAcceptAppealNotPending    = errors.New("该申诉已经得出结论")
+	ErrAcceptAppealJustification = errors.New("请写明申诉理由")
+)
+
+// AcceptAppeal 一次申诉
+type AcceptAppeal struct {
+	Id                   int
+	Uuid                 string
+	AcceptObjectId       int // 被申诉的一轮
+	ReviewAcceptObjectId int // 复审的一轮
+	ObjectType           int
+	ObjectId             int
+	AuthorUserId         int
+	AuthorName           string
+	Justification        string
+	OriginalTitle        string
+	OriginalBody         string
+	RevisedTitle         string
+	RevisedBody          string
+	Reviewer             string
+	Status               string
+	DeciderUserId        int // 茶博士复审时的茶博士
+	DeciderName          string
+	DecisionNote         string
+	PublishedId          int // 复审接纳后发布的对象，茶议、品味为新建的id
+	CreatedAt            time.Time
+	DecidedAt            *time.Time
+}
+
+// IsPending 是否复审中
+func (a *AcceptAppeal) IsPending() bool {
+	return a.Status == AcceptAppealStatus_Pending
+}
+
+// IsRevised 修订稿与原稿是否不同
+func (a *AcceptAppeal) IsRevised() bool {
+	return a.RevisedTitle != a.OriginalTitle || a.RevisedBody != a.OriginalBody
+}
+
+// ByOffice 是否由茶博士复审
+func (a *AcceptAppeal) ByOffice() bool {
+	return a.Reviewer == AcceptAppealReviewer_Office
+}
+
+// StatusString 申诉状态
+func (a *AcceptAppeal) StatusString() string {
+	switch a.Status {
+	case AcceptAppealStatus_Pending:
+		return "复审中"
+	case AcceptAppealStatus_Accepted:
+		return "复审接纳"
+	case AcceptAppealStatus_Rejected:
+		return "维持婉拒"
+	}
+	return "未知"
+}
+
+// ReviewerString 复审方
+func (a *AcceptAppeal) ReviewerString() string {
+	if a.ByOffice() {
+		return "茶博士复审"
+	}
+	return "友邻复审"
+}
+
+// ObjectTypeString 申诉对象类型
+func (a *AcceptAppeal) ObjectTypeString() string {
+	return acceptObjectTypeString(a.ObjectType)
+}
+
+// AcceptObjectTitleEditable 该类对象申诉时可否修订标题：茶团、集团的名称不在申诉中修改，品味没有标题
+func AcceptObjectTitleEditable(objectType int) bool {
+	switch objectType {
+	case AcceptObjectTypeObjective, AcceptObjectTypeProject, AcceptObjectTypeThread:
+		return true
+	}
+	return false
+}
+
+// acceptAppealReopenedClass 婉拒后的类别（茶议为状态）对应的草稿类别；品味返回的是没有记下原级别时的默认级别
+func acceptAppealReopenedClass(objectType, class int) (int, bool) {
+	switch objectType {
+	case AcceptObjectTypeObjective:
+		switch class {
+		case ObClassNeighborRejectOpen:
+			return ObClassOpenDraft, true
+		case ObClassNeighborRejectClose:
+			return ObClassCloseDraft, true
+		}
+	case AcceptObjectTypeProject:
+		switch class {
+		case PrClassRejectedOpen:
+			return PrClassOpenDraft, true
+		case PrClassRejectedClose:
+			return PrClassCloseDraft, true
+		}
+	case AcceptObjectTypeThread:
+		if class == DraftThreadStatusRejected {
+			return DraftThreadStatusPending, true
+		}
+	case AcceptObjectTypePost:
+		if class == DraftPostClassRejectedByNeighbor {
+			return DraftPostClassNormal, true
+		}
+	case AcceptObjectTypeTeam:
+		switch class {
+		case TeamClassRejectedOpenDraft:
+			return TeamClassOpenDraft, true
+		case TeamClassRejectedCloseDraft:
+			return TeamClassCloseDraft, true
+		}
+	case AcceptObjectTypeGroup:
+		switch class {
+		case GroupClassRejectedOpenDraft:
+			return GroupClassOpenDraft, true
+		case GroupClassRejectedCloseDraft:
+			return GroupClassCloseDraft, true
+		}
+	}
+	return 0, false
+}
+
+// acceptAppealRoundReviewer 第 round 次申诉的复审方：最后一次由茶博士复审
+func acceptAppealRoundReviewer(round, maxRounds int) string {
+	if round >= maxRounds {
+		return AcceptAppealReviewer_Office
+	}
+	return AcceptAppealReviewer_Neighbor
+}
+
+// acceptObjectSnapshot 蒙评对象的作者、标题（茶团、集团为名称）、内容（茶团、集团为宗旨）及类别（茶议为状态）
+type acceptObjectSnapshot struct {
+	AuthorUserId int
+	Title        string
+	Body         string
+	Class        int
+}
+
+type acceptQueryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getAcceptObjectSnapshot(ctx context.Context, q acceptQueryRower, objectType, objectId int, forUpdate bool) (acceptObjectSnapshot, error) {
+	var s acceptObjectSnapshot
+	var query string
+	switch objectType {
+	case AcceptObjectTypeObjective:
+		query = `SELECT user_id, title, COALESCE(body, ''), class FROM objectives WHERE id = $1`
+	case AcceptObjectTypeProject:
+		query = `SELECT COALESCE(user_id, 0), title, COALESCE(body, ''), COALESCE(class, 0) FROM projects WHERE id = $1`
+	case AcceptObjectTypeThread:
+		query = `SELECT user_id, COALESCE(title, ''), COALESCE(body, ''), COALESCE(status, 0) FROM draft_threads WHERE id = $1`
+	case AcceptObjectTypePost:
+		query = `SELECT COALESCE(user_id, 0), '', COALESCE(body, ''), COALESCE(class, 0) FROM draft_posts WHERE id = $1`
+	case AcceptObjectTypeTeam:
+		query = `SELECT COALESCE(founder_id, 0), COALESCE(name, ''), COALESCE(mission, ''), COALESCE(class, 0) FROM teams WHERE id = $1`
+	case AcceptObjectTypeGroup:
+		query = `SELECT COALESCE(founder_id, 0), name, COALESCE(mission, ''), COALESCE(class, 0) FROM groups WHERE id = $1`
+	default:
+		return s, fmt.Errorf("未知的蒙评对象类型 %d", objectType)
+	}
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	if err := q.QueryRowContext(ctx, query, objectId).Scan(&s.AuthorUserId, &s.Title, &s.Body, &s.Class); err != nil {
+		return s, fmt.Errorf("查询蒙评对象内容失败: %w", err)
+	}
+	return s, nil
+}
+
+// reopenAcceptObjectForAppeal 把婉拒的对象恢复为草稿并换上修订稿
+func reopenAcceptObjectForAppeal(ctx context.Context, tx *sql.Tx, objectType, objectId, class int, title, body string, now time.Time) error {
+	var err error
+	switch objectType {
+	case AcceptObjectTypeObjective:
+		_, err = tx.ExecContext(ctx, `UPDATE objectives SET title = $2, body = $3, class = $4, edit_at = $5 WHERE id = $1`, objectId, title, body, class, now)
+	case AcceptObjectTypeProject:
+		_, err = tx.ExecContext(ctx, `UPDATE projects SET title = $2, body = $3, class = $4, edit_at = $5 WHERE id = $1`, objectId, title, body, class, now)
+	case AcceptObjectTypeThread:
+		_, err = tx.ExecContext(ctx, `UPDATE draft_threads SET title = $2, body = $3, status = $4 WHERE id = $1`, objectId, title, body, class)
+	case AcceptObjectTypePost:
+		_, err = tx.ExecContext(ctx, `UPDATE draft_posts SET body = $2, class = COALESCE(rejected_from_class, $3), rejected_from_class = NULL WHERE id = $1`, objectId, body, class)
+	case AcceptObjectTypeTeam:
+		_, err = tx.ExecContext(ctx, `UPDATE teams SET mission = $2, class = $3, updated_at = $4 WHERE id = $1`, objectId, body, class, now)
+	case AcceptObjectTypeGroup:
+		_, err = tx.ExecContext(ctx, `UPDATE groups SET mission = $2, class = $3, updated_at = $4 WHERE id = $1`, objectId, body, class, now)
+	default:
+		return fmt.Errorf("未知的蒙评对象类型 %d", objectType)
+	}
+	if err != nil {
+		return fmt.Errorf("恢复蒙评对象为草稿失败: %v", err)
+	}
+	return nil
+}
+
+// acceptObjectAuthorSQL 蒙评对象作者，o 为 accept_objects 的别名；茶团、集团为发起人
+const acceptObjectAuthorSQL = `CASE o.object_type
+		WHEN 1 THEN (SELECT user_id FROM objectives WHERE id = o.object_id)
+		WHEN 2 THEN (SELECT user_id FROM projects WHERE id = o.object_id)
+		WHEN 3 THEN (SELECT user_id FROM draft_threads WHERE id = o.object_id)
+		WHEN 4 THEN (SELECT user_id FROM draft_posts WHERE id = o.object_id)
+		WHEN 5 THEN (SELECT founder_id FROM teams WHERE id = o.object_id)
+		WHEN 6 THEN (SELECT founder_id FROM groups WHERE id = o.object_id)
+	END`
+
+// AcceptRejection 作者可以申诉的婉拒
+type AcceptRejection struct {
+	AcceptObjectId int
+	ObjectType     int
+	ObjectId       int
+	Title          string
+	Body           string
+	RejectedAt     time.Time
+	Appeals        int // 同一对象已申诉次数
+}
+
+// ObjectTypeString 对象类型
+func (r *AcceptRejection) ObjectTypeString() string {
+	return acceptObjectTypeString(r.ObjectType)
+}
+
+// DeadlineAt 申诉期限
+func (r *AcceptRejection) DeadlineAt() time.Time {
+	return r.RejectedAt.AddDate(0, 0, int(util.Config.AcceptAppealWindowDays))
+}
+
+// acceptRejectionQuery 最近一轮为友邻婉拒、仍在申诉期限内且还有申诉次数的对象
+const acceptRejectionQuery = `
+	SELECT o.id, o.object_type, o.object_id, COALESCE(c.y_accepted_at, c.x_accepted_at),
+	       (SELECT COUNT(*) FROM accept_appeals p WHERE p.object_type = o.object_type AND p.object_id = o.object_id)
+	FROM accept_objects o
+	JOIN acceptances c ON c.accept_object_id = o.id
+	WHERE COALESCE(c.y_user_id, 0) <> 0 AND NOT (c.x_accept AND c.y_accept)
+	  AND COALESCE(c.y_accepted_at, c.x_accepted_at) >= $1
+	  AND NOT EXISTS (SELECT 1 FROM accept_objects n WHERE n.object_type = o.object_type AND n.object_id = o.object_id AND n.id > o.id)
+	  AND (SELECT COUNT(*) FROM accept_appeals p WHERE p.object_type = o.object_type AND p.object_id = o.object_id) < $2`
+
+func scanAcceptRejection(row interface{ Scan(...any) error }, r *AcceptRejection) error {
+	return row.Scan(&r.AcceptObjectId, &r.ObjectType, &r.ObjectId, &r.RejectedAt, &r.Appeals)
+}
+
+func acceptAppealWindowStart(now time.Time) time.Time {
+	return now.AddDate(0, 0, -int(util.Config.AcceptAppealWindowDays))
+}
+
+// AppealableAcceptRejections 茶友可以申诉的婉拒，最近的在前
+func AppealableAcceptRejections(ctx context.Context, authorUserId int) ([]AcceptRejection, error) {
+	rows, err := DB.QueryContext(ctx, acceptRejectionQuery+`
+	  AND `+acceptObjectAuthorSQL+` = $3
+	ORDER BY o.id DESC LIMIT $4`,
+		acceptAppealWindowStart(time.Now()), util.Config.AcceptAppealMaxRounds, authorUserId, acceptAppealListLimit)
+	if err != nil {
+		return nil, fmt.Errorf("查询可申诉的婉拒失败: %v", err)
+	}
+	var list []AcceptRejection
+	for rows.Next() {
+		var r AcceptRejection
+		if err = scanAcceptRejection(rows, &r); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("扫描可申诉的婉拒失败: %v", err)
+		}
+		list = append(list, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range list {
+		s, err := getAcceptObjectSnapshot(ctx, DB, list[i].ObjectType, list[i].ObjectId, false)
+		if err != nil {
+			return nil, err
+		}
+		list[i].Title, list[i].Body = s.Title, s.Body
+	}
+	return list, nil
+}
+
+// GetAppealableAcceptRejection 作者可以申诉的某一轮婉拒，不能申诉时返回相应的错误
+func GetAppealableAcceptRejection(ctx context.Context, acceptObjectId, authorUserId int) (AcceptRejection, error) {
+	r, err := checkAcceptAppealable(ctx, DB, acceptObjectId, authorUserId, time.Now())
+	if err != nil {
+		return r, err
+	}
+	s, err := getAcceptObjectSnapshot(ctx, DB, r.ObjectType, r.ObjectId, false)
+	if err != nil {
+		return r, err
+	}
+	r.Title, r.Body = s.Title, s.Body
+	return r, nil
+}
+
+// checkAcceptAppealable 核对这一轮婉拒能否由该茶友申诉，逐项给出不能申诉的原因
+func checkAcceptAppealable(ctx context.Context, q acceptQueryRower, acceptObjectId, authorUserId int, now time.Time) (AcceptRejection, error) {
+	r := AcceptRejection{AcceptObjectId: acceptObjectId}
+	var accepted, latest bool
+	var authorId sql.NullInt64
+	err := q.QueryRowContext(ctx, `
+		SELECT o.object_type, o.object_id, COALESCE(c.y_accepted_at, c.x_accepted_at), c.x_accept AND c.y_accept,
+		       NOT EXISTS (SELECT 1 FROM accept_objects n WHERE n.object_type = o.object_type AND n.object_id = o.object_id AND n.id > o.id),
+		       (SELECT COUNT(*) FROM accept_appeals p WHERE p.object_type = o.object_type AND p.object_id = o.object_id),
+		       `+acceptObjectAuthorSQL+`
+		FROM accept_objects o
+		JOIN acceptances c ON c.accept_object_id = o.id
+		WHERE o.id = $1 AND COALESCE(c.y_user_id, 0) <> 0
+		ORDER BY c.id LIMIT 1`, acceptObjectId).
+		Scan(&r.ObjectType, &r.ObjectId, &r.RejectedAt, &accepted, &latest, &r.Appeals, &authorId)
+	if err == sql.ErrNoRows {
+		return r, ErrAcceptAppealNotRejected
+	}
+	if err != nil {
+		return r, fmt.Errorf("查询蒙评结论失败: %v", err)
+	}
+	switch {
+	case int(authorId.Int64) != authorUserId:
+		return r, ErrAcceptAppealNotAuthor
+	case accepted || !latest:
+		return r, ErrAcceptAppealNotRejected
+	case int64(r.Appeals) >= util.Config.AcceptAppealMaxRounds:
+		return r, ErrAcceptAppealNoRounds
+	case r.RejectedAt.Before(acceptAppealWindowStart(now)):
+		return r, ErrAcceptAppealExpired
+	}
+	return r, nil
+}
+
+// FileAcceptAppeal 作者对一轮婉拒提出申诉：对象恢复为草稿并换上修订稿，另建复审的一轮；
+// 修订稿留空的部分沿用原稿。友邻复审的评审官由调用方随后指派
+func FileAcceptAppeal(ctx context.Context, acceptObjectId, authorUserId int, justification, revisedTitle, revisedBody string) (AcceptAppeal, error) {
+	a := AcceptAppeal{
+		AcceptObjectId: acceptObjectId,
+		AuthorUserId:   authorUserId,
+		Justification:  strings.TrimSpace(justification),
+		Status:         AcceptAppealStatus_Pending,
+	}
+	if a.Justification == "" {
+		return a, ErrAcceptAppealJustification
+	}
+	if utf8.RuneCountInString(a.Justification) > AcceptAppealJustificationMaxLen {
+		return a, fmt.Errorf("申诉理由不能超过 %d 字", AcceptAppealJustificationMaxLen)
+	}
+	now := time.Now()
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return a, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	// 锁住被申诉的一轮，同一轮的并发申诉依次核对
+	if _, err = tx.ExecContext(ctx, `SELECT id FROM accept_objects WHERE id = $1 FOR UPDATE`, acceptObjectId); err != nil {
+		return a, fmt.Errorf("锁定蒙评对象失败: %v", err)
+	}
+	r, err := checkAcceptAppealable(ctx, tx, acceptObjectId, authorUserId, now)
+	if err != nil {
+		return a, err
+	}
+	a.ObjectType, a.ObjectId = r.ObjectType, r.ObjectId
+	s, err := getAcceptObjectSnapshot(ctx, tx, a.ObjectType, a.ObjectId, true)
+	if err != nil {
+		return a, err
+	}
+	class, ok := acceptAppealReopenedClass(a.ObjectType, s.Class)
+	if !ok {
+		return a, ErrAcceptAppealNotRejected
+	}
+	a.OriginalTitle, a.OriginalBody = s.Title, s.Body
+	a.RevisedTitle, a.RevisedBody = s.Title, s.Body
+	if t := strings.TrimSpace(revisedTitle); t != "" && AcceptObjectTitleEditable(a.ObjectType) {
+		if utf8.RuneCountInString(t) > acceptAppealTitleMaxLen {
+			return a, fmt.Errorf("标题不能超过 %d 字", acceptAppealTitleMaxLen)
+		}
+		a.RevisedTitle = t
+	}
+	if b := strings.TrimSpace(revisedBody); b != "" {
+		a.RevisedBody = b
+	}
+	if err = reopenAcceptObjectForAppeal(ctx, tx, a.ObjectType, a.ObjectId, class, a.RevisedTitle, a.RevisedBody, now); err != nil {
+		return a, err
+	}
+	if err = tx.QueryRowContext(ctx, `INSERT INTO accept_objects (object_type, object_id) VALUES ($1, $2) RETURNING id`,
+		a.ObjectType, a.ObjectId).Scan(&a.ReviewAcceptObjectId); err != nil {
+		return a, fmt.Errorf("创建复审蒙评对象失败: %v", err)
+	}
+	a.Reviewer = acceptAppealRoundReviewer(r.Appeals+1, int(util.Config.AcceptAppealMaxRounds))
+	if err = tx.QueryRowContext(ctx, `
+		INSERT INTO accept_appeals (accept_object_id, review_accept_object_id, object_type, object_id, author_user_id,
+			justification, original_title, original_body, revised_title, revised_body, reviewer, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, uuid`,
+		a.AcceptObjectId, a.ReviewAcceptObjectId, a.ObjectType, a.ObjectId, a.AuthorUserId,
+		a.Justification, a.OriginalTitle, a.OriginalBody, a.RevisedTitle, a.RevisedBody, a.Reviewer, a.Status, now).
+		Scan(&a.Id, &a.Uuid); err != nil {
+		return a, fmt.Errorf("记录申诉失败: %v", err)
+	}
+	a.CreatedAt = now
+	if err = tx.Commit(); err != nil {
+		return a, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return a, nil
+}
+
+// TransferAcceptAppealToOffice 找不到友邻评审官时，申诉转由茶博士复审
+func TransferAcceptAppealToOffice(ctx context.Context, appealId int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE accept_appeals SET reviewer = $2 WHERE id = $1 AND status = $3`,
+		appealId, AcceptAppealReviewer_Office, AcceptAppealStatus_Pending)
+	if err != nil {
+		return fmt.Errorf("申诉转茶博士复审失败: %v", err)
+	}
+	return nil
+}
+
+// ClaimAcceptAppeal 复审的一轮得出结论、发布或退回对象之前，先在事务中锁定申诉并记下结论，
+// 同一份申诉只有一次认领能成功，避免重复发布。该轮不是申诉复审时返回 sql.ErrNoRows，
+// 申诉已有结论时返回 ErrAcceptAppealNotPending。deciderUserId 为茶博士，友邻复审时为 UserId_None
+func ClaimAcceptAppeal(ctx context.Context, reviewAcceptObjectId int, accepted bool, deciderUserId int, note string) (AcceptAppeal, error) {
+	var a AcceptAppeal
+	status := AcceptAppealStatus_Rejected
+	if accepted {
+		status = AcceptAppealStatus_Accepted
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return a, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var current string
+	err = tx.QueryRowContext(ctx, `SELECT id, status FROM accept_appeals WHERE review_accept_object_id = $1 FOR UPDATE`,
+		reviewAcceptObjectId).Scan(&id, &current)
+	if err != nil {
+		return a, err
+	}
+	if current != AcceptAppealStatus_Pending {
+		return a, ErrAcceptAppealNotPending
+	}
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE accept_appeals SET status = $2, decider_user_id = NULLIF($3, 0), decision_note = $4, decided_at = $5
+		WHERE id = $1`,
+		id, status, deciderUserId, strings.TrimSpace(note), time.Now()); err != nil {
+		return a, err
+	}
+	if err = tx.Commit(); err != nil {
+		return a, err
+	}
+	return getAcceptAppeal(ctx, `WHERE p.id = $1`, id)
+}
+
+// ReleaseAcceptAppeal 认领后未能发布或退回对象时撤回结论，申诉重新回到复审中
+func ReleaseAcceptAppeal(ctx context.Context, appealId int) error {
+	_, err := DB.ExecContext(ctx, `
+		UPDATE accept_appeals SET status = $2, decider_user_id = NULL, decision_note = '', decided_at = NULL
+		WHERE id = $1 AND status <> $2 AND published_id = 0`,
+		appealId, AcceptAppealStatus_Pending)
+	return err
+}
+
+// ResolveAcceptAppeal 认领的结论生效（对象已发布或退回）后记下发布的对象，并据此复核被申诉一轮的评审意见
+func ResolveAcceptAppeal(ctx context.Context, a AcceptAppeal, publishedId int) (AcceptAppeal, error) {
+	if _, err := DB.ExecContext(ctx, `UPDATE accept_appeals SET published_id = $2 WHERE id = $1`, a.Id, publishedId); err != nil {
+		return a, err
+	}
+	a.PublishedId = publishedId
+	accepted := a.Status == AcceptAppealStatus_Accepted
+	// 接纳修订稿说明不了原评审官的对错，不复核
+	if accepted && a.IsRevised() {
+		return a, nil
+	}
+	note := a.DecisionNote
+	if accepted && note == "" {
+		note = "申诉复审接纳未经修改的原稿"
+	}
+	if _, err := RecordAcceptReviewCheck(ctx, a.AcceptObjectId, a.DeciderUserId, AcceptReviewCheckSource_Appeal, accepted, note); err != nil {
+		return a, fmt.Errorf("复核被申诉的评审意见失败: %v", err)
+	}
+	return a, nil
+}
+
+const acceptAppealColumns = `
+	p.id, p.uuid, p.accept_object_id, p.review_accept_object_id, p.object_type, p.object_id,
+	p.author_user_id, COALESCE(au.name, ''), p.justification, p.original_title, p.original_body, p.revised_title, p.revised_body,
+	p.reviewer, p.status, COALESCE(p.decider_user_id, 0), COALESCE(du.name, ''), p.decision_note, p.published_id,
+	p.created_at, p.decided_at
+	FROM accept_appeals p
+	LEFT JOIN users au ON au.id = p.author_user_id
+	LEFT JOIN users du ON du.id = p.decider_user_id`
+
+func scanAcceptAppeal(row interface{ Scan(...any) error }, a *AcceptAppeal) error {
+	return row.Scan(&a.Id, &a.Uuid, &a.AcceptObjectId, &a.ReviewAcceptObjectId, &a.ObjectType, &a.ObjectId,
+		&a.AuthorUserId, &a.AuthorName, &a.Justification, &a.OriginalTitle, &a.OriginalBody, &a.RevisedTitle, &a.RevisedBody,
+		&a.Reviewer, &a.Status, &a.DeciderUserId, &a.DeciderName, &a.DecisionNote, &a.PublishedId,
+		&a.CreatedAt, &a.DecidedAt)
+}
+
+func getAcceptAppeal(ctx context.Context, where string, args ...any) (AcceptAppeal, error) {
+	var a AcceptAppeal
+	err := scanAcceptAppeal(DB.QueryRowContext(ctx, `SELECT `+acceptAppealColumns+` `+where, args...), &a)
+	return a, err
+}
+
+func queryAcceptAppeals(ctx context.Context, where string, args ...any) ([]AcceptAppeal, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+acceptAppealColumns+` `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询申诉失败: %v", err)
+	}
+	defer rows.Close()
+	var appeals []AcceptAppeal
+	for rows.Next() {
+		var a AcceptAppeal
+		if err = scanAcceptAppeal(rows, &a); err != nil {
+			return nil, fmt.Errorf("扫描申诉失败: %v", err)
+		}
+		appeals = append(appeals, a)
+	}
+	return appeals, rows.Err()
+}
+
+// GetAcceptAppealByUuid 按uuid读取申诉
+func GetAcceptAppealByUuid(ctx context.Context, uuid string) (AcceptAppeal, error) {
+	return getAcceptAppeal(ctx, `WHERE p.uuid = $1`, uuid)
+}
+
+// GetAcceptAppealByReviewObject 复审的一轮对应的申诉，不是申诉复审时返回 sql.ErrNoRows
+func GetAcceptAppealByReviewObject(ctx context.Context, reviewAcceptObjectId int) (AcceptAppeal, error) {
+	return getAcceptAppeal(ctx, `WHERE p.review_accept_object_id = $1`, reviewAcceptObjectId)
+}
+
+// AcceptAppealsByAuthor 茶友提出的申诉，最近的在前
+func AcceptAppealsByAuthor(ctx context.Context, authorUserId, limit int) ([]AcceptAppeal, error) {
+	return queryAcceptAppeals(ctx, `WHERE p.author_user_id = $1 ORDER BY p.id DESC LIMIT $2`, authorUserId, limit)
+}
+
+// PendingOfficeAcceptAppeals 待茶博士复审的申诉，先提出的在前
+func PendingOfficeAcceptAppeals(ctx context.Context) ([]AcceptAppeal, error) {
+	return queryAcceptAppeals(ctx, `WHERE p.reviewer = $1 AND p.status = $2 ORDER BY p.created_at`,
+		AcceptAppealReviewer_Office, AcceptAppealStatus_Pending)
+}
+
+// RecentAcceptAppeals 最近的申诉（不含待茶博士复审的）
+func RecentAcceptAppeals(ctx context.Context, limit int) ([]AcceptAppeal, error) {
+	return queryAcceptAppeals(ctx, `WHERE NOT (p.reviewer = $1 AND p.status = $2) ORDER BY p.id DESC LIMIT $3`,
+		AcceptAppealReviewer_Office, AcceptAppealStatus_Pending, limit)
+}
+
+// AcceptVerdictRound 结论链中的一轮：首轮友邻蒙评或一次申诉复审
+type AcceptVerdictRound struct {
+	AcceptObjectId int
+	Appeal         *AcceptAppeal // 首轮为空
+	Votes          int           // 已提交的友邻评审意见数
+	Decided        bool
+	Accepted       bool
+	DecidedAlone   bool // 可信评审官独自裁定
+	DecidedAt      *time.Time
+	CreatedAt      time.Time
+}
+
+// ReviewerString 这一轮的评审方
+func (r *AcceptVerdictRound) ReviewerString() string {
+	if r.Appeal == nil {
+		return "友邻蒙评"
+	}
+	return r.Appeal.ReviewerString()
+}
+
+// VerdictString 这一轮的结论
+func (r *AcceptVerdictRound) VerdictString() string {
+	switch {
+	case !r.Decided:
+		return "评审中"
+	case r.Accepted:
+		return "接纳"
+	}
+	return "婉拒"
+}
+
+// AcceptVerdictChain 同一对象历次蒙评的结论，按先后排列；评审官不具名
+func AcceptVerdictChain(ctx context.Context, objectType, objectId int) ([]AcceptVerdictRound, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT o.id, o.created_at,
+		       (CASE WHEN COALESCE(c.x_user_id, 0) <> 0 THEN 1 ELSE 0 END) + (CASE WHEN COALESCE(c.y_user_id, 0) <> 0 AND c.y_user_id <> c.x_user_id THEN 1 ELSE 0 END),
+		       COALESCE(c.y_user_id, 0) <> 0, COALESCE(c.x_accept AND c.y_accept, false),
+		       COALESCE(c.y_user_id = c.x_user_id, false), c.y_accepted_at
+		FROM accept_objects o
+		LEFT JOIN LATERAL (SELECT * FROM acceptances WHERE accept_object_id = o.id ORDER BY id LIMIT 1) c ON true
+		WHERE o.object_type = $1 AND o.object_id = $2
+		ORDER BY o.id`, objectType, objectId)
+	if err != nil {
+		return nil, fmt.Errorf("查询结论链失败: %v", err)
+	}
+	var chain []AcceptVerdictRound
+	for rows.Next() {
+		var r AcceptVerdictRound
+		if err = rows.Scan(&r.AcceptObjectId, &r.CreatedAt, &r.Votes, &r.Decided, &r.Accepted, &r.DecidedAlone, &r.DecidedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("扫描结论链失败: %v", err)
+		}
+		chain = append(chain, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	appeals, err := queryAcceptAppeals(ctx, `WHERE p.object_type = $1 AND p.object_id = $2`, objectType, objectId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range appeals {
+		a := &appeals[i]
+		for j := range chain {
+			if chain[j].AcceptObjectId != a.ReviewAcceptObjectId {
+				continue
+			}
+			chain[j].Appeal = a
+			if a.ByOffice() {
+				chain[j].Decided = !a.IsPending()
+				chain[j].Accepted = a.Status == AcceptAppealStatus_Accepted
+				chain[j].DecidedAt = a.DecidedAt
+			}
+		}
+	}
+	return chain, nil
+}
+
+// PublishedAcceptVerdictChain 经过申诉后发布的对象的结论链，没有经过申诉的返回空；
+// 茶议、品味发布后是新建的对象，按申诉记下的 published_id 找回草稿
+func PublishedAcceptVerdictChain(ctx context.Context, objectType, publishedId int) ([]AcceptVerdictRound, error) {
+	var objectId int
+	err := DB.QueryRowContext(ctx, `
+		SELECT object_id FROM accept_appeals
+		WHERE object_type = $1 AND published_id = $2 AND status = $3
+		ORDER BY id DESC LIMIT 1`, objectType, publishedId, AcceptAppealStatus_Accepted).Scan(&objectId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询申诉记录失败: %v", err)
+	}
+	return AcceptVerdictChain(ctx, objectType, objectId)
+}
@@ -0,0 +1,83 @@
+package dao
+
+import "testing"
+
+func TestAcceptAppealReopenedClass(t *testing.T) {
+	cases := []struct {
+		objectType, class int
+		want              int
+		ok                bool
+	}{
+		{AcceptObjectTypeObjective, ObClassNeighborRejectOpen, ObClassOpenDraft, true},
+		{AcceptObjectTypeObjective, ObClassNeighborRejectClose, ObClassCloseDraft, true},
+		{AcceptObjectTypeObjective, ObClassOpen, 0, false},
+		{AcceptObjectTypeProject, PrClassRejectedOpen, PrClassOpenDraft, true},
+		{AcceptObjectTypeProject, PrClassRejectedClose, PrClassCloseDraft, true},
+		{AcceptObjectTypeProject, PrClassClose, 0, false},
+		{AcceptObjectTypeThread, DraftThreadStatusRejected, DraftThreadStatusPending, true},
+		{AcceptObjectTypeThread, DraftThreadStatusAccepted, 0, false},
+		{AcceptObjectTypePost, DraftPostClassRejectedByNeighbor, DraftPostClassNormal, true},
+		{AcceptObjectTypePost, DraftPostClassAdmin, 0, false},
+		{AcceptObjectTypeTeam, TeamClassRejectedOpenDraft, TeamClassOpenDraft, true},
+		{AcceptObjectTypeTeam, TeamClassRejectedCloseDraft, TeamClassCloseDraft, true},
+		{AcceptObjectTypeGroup, GroupClassRejectedOpenDraft, GroupClassOpenDraft, true},
+		{AcceptObjectTypeGroup, GroupClassRejectedCloseDraft, GroupClassCloseDraft, true},
+		{AcceptObjectTypeGroup, GroupClassOpen, 0, false},
+		{0, 31, 0, false},
+	}
+	for _, c := range cases {
+		got, ok := acceptAppealReopenedClass(c.objectType, c.class)
+		if got != c.want || ok != c.ok {
+			t.Errorf("类型 %d 类别 %d: got (%d, %t), want (%d, %t)", c.objectType, c.class, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestAcceptAppealRoundReviewer(t *testing.T) {
+	cases := []struct {
+		round, max int
+		want       string
+	}{
+		{1, 2, AcceptAppealReviewer_Neighbor},
+		{2, 2, AcceptAppealReviewer_Office},
+		{1, 1, AcceptAppealReviewer_Office},
+		{2, 3, AcceptAppealReviewer_Neighbor},
+	}
+	for _, c := range cases {
+		if got := acceptAppealRoundReviewer(c.round, c.max); got != c.want {
+			t.Errorf("第 %d 次（共 %d 次）: got %s, want %s", c.round, c.max, got, c.want)
+		}
+	}
+}
+
+func TestAcceptAppealIsRevised(t *testing.T) {
+	a := AcceptAppeal{OriginalTitle: "标题", OriginalBody: "内容", RevisedTitle: "标题", RevisedBody: "内容"}
+	if a.IsRevised() {
+		t.Error("修订稿与原稿相同，不应算作修订")
+	}
+	a.RevisedBody = "修订后的内容"
+	if !a.IsRevised() {
+		t.Error("内容已修订")
+	}
+}
+
+func TestAcceptVerdictRoundStrings(t *testing.T) {
+	first := AcceptVerdictRound{Decided: true}
+	if got := first.ReviewerString(); got != "友邻蒙评" {
+		t.Errorf("首轮评审方 %s", got)
+	}
+	if got := first.VerdictString(); got != "婉拒" {
+		t.Errorf("首轮结论 %s", got)
+	}
+	office := AcceptVerdictRound{Appeal: &AcceptAppeal{Reviewer: AcceptAppealReviewer_Office}}
+	if got := office.ReviewerString(); got != "茶博士复审" {
+		t.Errorf("茶博士复审评审方 %s", got)
+	}
+	if got := office.VerdictString(); got != "评审中" {
+		t.Errorf("复审中结论 %s", got)
+	}
+	office.Decided, office.Accepted = true, true
+	if got := office.VerdictString(); got != "接纳" {
+		t.Errorf("复审接纳结论 %s", got)
+	}
+}
@@ -0,0 +1,569 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	util "teachat/Util"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+/*
+友邻蒙评评审官指派（accept_assignments）：
+1、每个蒙评对象指派 AcceptReviewersPerObject 位评审官，候选人排除：作者本人、作者三代以内亲属
+   （GetThreeGenerationUsers，并以 ShouldAvoidConflict 从候选人一方复核）、对象所属茶团（不含自由人占位团队）
+   的成员及创建人、所属家庭的成员，以及曾经受邀评审该对象的茶友；
+2、信誉分低于 AcceptReviewerLowScore 的排在其他人选之后；同一档内按候选人手上未答复的指派数从少到多挑选，
+   同样少时随机；第二位优先挑选与第一位性别不同的茶友，只要其未答复指派数不超过最少者 acceptReviewerLoadTolerance 件；
+3、每次指派都记一行，评审官提交意见后标记为已答复；超过 AcceptReviewTimeoutHours 仍未答复的，
+   后台任务 accept_review_reassign 标记为超时、撤回其邀请通知；另一位已答复且为可信评审官的由其独自裁定，
+   否则改派他人（优先与仍在评审的另一位性别不同）；暂无合适人选时保留缺额，下次执行再补派；
+4、本表建立前的蒙评对象没有指派记录，仍按原邀请通知核对评审资格。
+*/
+
+// AcceptReviewersPerObject 每个蒙评对象的评审官人数
+const AcceptReviewersPerObject = 2
+
+// acceptReviewerLoadTolerance 为性别搭配可多承担的未答复指派数
+const acceptReviewerLoadTolerance = 1
+
+// acceptReviewerCandidateLimit 每次挑选时读取的候选人数
+const acceptReviewerCandidateLimit = 50
+
+// 指派状态
+const (
+	AcceptAssignmentStatus_Assigned = "assigned"  // 待答复
+	AcceptAssignmentStatus_Answered = "answered"  // 已答复
+	AcceptAssignmentStatus_TimedOut = "timed_out" // 超时改派
+)
+
+// 指派原因
+const (
+	AcceptAssignmentReason_Initial  = "initial"  // 首次指派
+	AcceptAssignmentReason_Reassign = "reassign" // 超时改派
+)
+
+// 友邻蒙评邀请通知的标题和内容
+const (
+	AcceptInvitationTitle   = "新茶语邻座评审邀请"
+	AcceptInvitationContent = "您被茶棚选中为新茶语评审官啦，请及时去审理。"
+)
+
+var ErrAcceptNoEligibleReviewer = errors.New("没有符合回避要求的评审官人选")
+
+// AcceptAssignment 友邻蒙评评审官指派记录
+type AcceptAssignment struct {
+	Id                   int
+	Uuid                 string
+	AcceptObjectId       int
+	ObjectType           int
+	ObjectId             int
+	ReviewerUserId       int
+	ReviewerName         string
+	AuthorUserId         int
+	Status               string
+	Reason               string
+	ReplacesAssignmentId int
+	AssignedAt           time.Time
+	DeadlineAt           time.Time
+	AnsweredAt           *time.Time
+	TimedOutAt           *time.Time
+	CreatedAt            time.Time
+}
+
+// IsOpen 是否待答复
+func (a *AcceptAssignment) IsOpen() bool {
+	return a.Status == AcceptAssignmentStatus_Assigned
+}
+
+// IsOverdue 待答复且已超过期限
+func (a *AcceptAssignment) IsOverdue(now time.Time) bool {
+	return a.IsOpen() && now.After(a.DeadlineAt)
+}
+
+// StatusString 指派状态的中文说明
+func (a *AcceptAssignment) StatusString() string {
+	switch a.Status {
+	case AcceptAssignmentStatus_Assigned:
+		return "待答复"
+	case AcceptAssignmentStatus_Answered:
+		return "已答复"
+	case AcceptAssignmentStatus_TimedOut:
+		return "超时改派"
+	}
+	return a.Status
+}
+
+// ReasonString 指派原因的中文说明
+func (a *AcceptAssignment) ReasonString() string {
+	switch a.Reason {
+	case AcceptAssignmentReason_Initial:
+		return "首次指派"
+	case AcceptAssignmentReason_Reassign:
+		return "超时改派"
+	}
+	return a.Reason
+}
+
+// ObjectTypeString 蒙评对象类型的中文说明
+func (a *AcceptAssignment) ObjectTypeString() string {
+	return acceptObjectTypeString(a.ObjectType)
+}
+
+// acceptObjectTypeString 蒙评对象类型的中文说明
+func acceptObjectTypeString(objectType int) string {
+	switch objectType {
+	case AcceptObjectTypeObjective:
+		return "茶围"
+	case AcceptObjectTypeProject:
+		return "茶台"
+	case AcceptObjectTypeThread:
+		return "茶议"
+	case AcceptObjectTypePost:
+		return "品味"
+	case AcceptObjectTypeTeam:
+		return "茶团"
+	case AcceptObjectTypeGroup:
+		return "集团"
+	}
+	return "未知"
+}
+
+// AcceptReviewerCandidate 评审官候选人
+type AcceptReviewerCandidate struct {
+	UserId   int
+	Gender   int  // User_Gender_Female、User_Gender_Male，未填写为-1
+	OpenLoad int  // 未答复的指派数
+	LowScore bool // 信誉分低于 AcceptReviewerLowScore，排在其他人选之后
+}
+
+// oppositeGender 与之搭配的性别，未填写性别时没有偏好（-1）
+func oppositeGender(gender int) int {
+	switch gender {
+	case User_Gender_Female:
+		return User_Gender_Male
+	case User_Gender_Male:
+		return User_Gender_Female
+	}
+	return -1
+}
+
+// PickAcceptReviewers 从候选人中依次挑选 n 位评审官：每次取信誉分不低者中未答复指派最少者（同样少时保持候选人原有顺序），
+// 但若同一档中有与上一位（首位则与 pairWithGender，-1 表示无）性别不同、且指派数不超过最少者 acceptReviewerLoadTolerance 件的候选人，优先取之
+func PickAcceptReviewers(candidates []AcceptReviewerCandidate, n int, pairWithGender int) []AcceptReviewerCandidate {
+	remaining := append([]AcceptReviewerCandidate(nil), candidates...)
+	want := oppositeGender(pairWithGender)
+	var picked []AcceptReviewerCandidate
+	for len(picked) < n && len(remaining) > 0 {
+		least := 0
+		for i, c := range remaining {
+			l := remaining[least]
+			if (!c.LowScore && l.LowScore) || (c.LowScore == l.LowScore && c.OpenLoad < l.OpenLoad) {
+				least = i
+			}
+		}
+		choice := least
+		if want >= 0 && remaining[least].Gender != want {
+			for i, c := range remaining {
+				if c.Gender == want && c.LowScore == remaining[least].LowScore &&
+					c.OpenLoad <= remaining[least].OpenLoad+acceptReviewerLoadTolerance {
+					choice = i
+					break
+				}
+			}
+		}
+		c := remaining[choice]
+		picked = append(picked, c)
+		remaining = append(remaining[:choice], remaining[choice+1:]...)
+		want = oppositeGender(c.Gender)
+	}
+	return picked
+}
+
+// acceptObjectOwner 蒙评对象所属的茶团和家庭，自由人等占位团队、未知家庭返回0
+func acceptObjectOwner(ctx context.Context, ao AcceptObject) (teamId, familyId int, err error) {
+	var query string
+	switch ao.ObjectType {
+	case AcceptObjectTypeObjective:
+		query = `SELECT COALESCE(team_id, 0), COALESCE(family_id, 0) FROM objectives WHERE id = $1`
+	case AcceptObjectTypeProject:
+		query = `SELECT COALESCE(team_id, 0), COALESCE(family_id, 0) FROM projects WHERE id = $1`
+	case AcceptObjectTypeThread:
+		query = `SELECT COALESCE(team_id, 0), COALESCE(family_id, 0) FROM draft_threads WHERE id = $1`
+	case AcceptObjectTypePost:
+		query = `SELECT COALESCE(team_id, 0), COALESCE(family_id, 0) FROM draft_posts WHERE id = $1`
+	case AcceptObjectTypeTeam:
+		query = `SELECT id, 0 FROM teams WHERE id = $1`
+	case AcceptObjectTypeGroup:
+		query = `SELECT COALESCE(first_team_id, 0), 0 FROM groups WHERE id = $1`
+	default:
+		return 0, 0, fmt.Errorf("未知的蒙评对象类型 %d", ao.ObjectType)
+	}
+	err = DB.QueryRowContext(ctx, query, ao.ObjectId).Scan(&teamId, &familyId)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("查询蒙评对象归属失败: %v", err)
+	}
+	if teamId == TeamIdFreelancer {
+		teamId = TeamIdNone
+	}
+	return teamId, familyId, nil
+}
+
+// acceptReviewerCandidates 符合回避要求的候选人，信誉分低的在后，同一档内未答复指派少的在前，同样少的随机排列；
+// 同一对象此前各轮（含申诉复审）的评审官都不再入选
+func acceptReviewerCandidates(ctx context.Context, ao AcceptObject, authorUserId int) ([]AcceptReviewerCandidate, error) {
+	teamId, familyId, err := acceptObjectOwner(ctx, ao)
+	if err != nil {
+		return nil, err
+	}
+	excluded := []int{UserId_None, authorUserId}
+	if authorUserId != UserId_None {
+		relatives, err := GetThreeGenerationUsers(authorUserId, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("查询作者亲属失败: %v", err)
+		}
+		excluded = append(excluded, relatives...)
+	}
+	rows, err := DB.QueryContext(ctx, `
+		SELECT u.id, COALESCE(u.gender, -1), COUNT(a.id), COALESCE(s.score, $7) < $8
+		FROM users u
+		LEFT JOIN accept_assignments a ON a.reviewer_user_id = u.id AND a.status = $1
+		LEFT JOIN accept_reviewer_stats s ON s.user_id = u.id
+		WHERE u.id <> ALL($2)
+		  AND NOT EXISTS (SELECT 1 FROM accept_assignments p JOIN accept_objects po ON po.id = p.accept_object_id
+		                  WHERE po.object_type = $3 AND po.object_id = $9 AND p.reviewer_user_id = u.id)
+		  AND NOT EXISTS (SELECT 1 FROM acceptances c JOIN accept_objects co ON co.id = c.accept_object_id
+		                  WHERE co.object_type = $3 AND co.object_id = $9 AND u.id IN (c.x_user_id, c.y_user_id))
+		  AND ($4 = 0 OR NOT EXISTS (SELECT 1 FROM team_members tm WHERE tm.team_id = $4 AND tm.user_id = u.id AND tm.deleted_at IS NULL))
+		  AND ($4 = 0 OR NOT EXISTS (SELECT 1 FROM teams t WHERE t.id = $4 AND t.founder_id = u.id))
+		  AND ($5 = 0 OR NOT EXISTS (SELECT 1 FROM family_members fm WHERE fm.family_id = $5 AND fm.user_id = u.id))
+		GROUP BY u.id, u.gender, s.score
+		ORDER BY COALESCE(s.score, $7) < $8, COUNT(a.id), RANDOM()
+		LIMIT $6`,
+		AcceptAssignmentStatus_Assigned, pq.Array(excluded), ao.ObjectType, teamId, familyId, acceptReviewerCandidateLimit,
+		AcceptReviewerDefaultScore, util.Config.AcceptReviewerLowScore, ao.ObjectId)
+	if err != nil {
+		return nil, fmt.Errorf("查询评审官候选人失败: %v", err)
+	}
+	defer rows.Close()
+	var candidates []AcceptReviewerCandidate
+	for rows.Next() {
+		var c AcceptReviewerCandidate
+		if err = rows.Scan(&c.UserId, &c.Gender, &c.OpenLoad, &c.LowScore); err != nil {
+			return nil, fmt.Errorf("扫描评审官候选人失败: %v", err)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// chooseAcceptReviewers 挑选 n 位评审官，逐位以 ShouldAvoidConflict 从候选人一方复核亲属关系
+func chooseAcceptReviewers(ctx context.Context, ao AcceptObject, authorUserId int, n int, pairWithGender int) ([]AcceptReviewerCandidate, error) {
+	pool, err := acceptReviewerCandidates(ctx, ao, authorUserId)
+	if err != nil {
+		return nil, err
+	}
+	var chosen []AcceptReviewerCandidate
+	for len(chosen) < n {
+		picks := PickAcceptReviewers(pool, 1, pairWithGender)
+		if len(picks) == 0 {
+			break
+		}
+		c := picks[0]
+		for i := range pool {
+			if pool[i].UserId == c.UserId {
+				pool = append(pool[:i], pool[i+1:]...)
+				break
+			}
+		}
+		if authorUserId != UserId_None {
+			avoid, err := ShouldAvoidConflict(c.UserId, authorUserId, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("检查利益回避失败: %v", err)
+			}
+			if avoid {
+				continue
+			}
+		}
+		chosen = append(chosen, c)
+		pairWithGender = c.Gender
+	}
+	return chosen, nil
+}
+
+// insertAcceptAssignments 记录指派
+func insertAcceptAssignments(ctx context.Context, aoId, authorUserId int, reviewers []AcceptReviewerCandidate, reason string, replacesId int, now time.Time) ([]AcceptAssignment, error) {
+	deadline := now.Add(time.Duration(util.Config.AcceptReviewTimeoutHours) * time.Hour)
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	var assignments []AcceptAssignment
+	for _, c := range reviewers {
+		a := AcceptAssignment{
+			AcceptObjectId:       aoId,
+			ReviewerUserId:       c.UserId,
+			AuthorUserId:         authorUserId,
+			Status:               AcceptAssignmentStatus_Assigned,
+			Reason:               reason,
+			ReplacesAssignmentId: replacesId,
+			AssignedAt:           now,
+			DeadlineAt:           deadline,
+		}
+		if err = tx.QueryRowContext(ctx, `
+			INSERT INTO accept_assignments
+				(accept_object_id, reviewer_user_id, author_user_id, status, reason, replaces_assignment_id, assigned_at, deadline_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7, $8)
+			RETURNING id, uuid, created_at`,
+			a.AcceptObjectId, a.ReviewerUserId, a.AuthorUserId, a.Status, a.Reason, a.ReplacesAssignmentId, a.AssignedAt, a.DeadlineAt).
+			Scan(&a.Id, &a.Uuid, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("记录评审官指派失败: %v", err)
+		}
+		assignments = append(assignments, a)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return assignments, nil
+}
+
+// AssignAcceptReviewers 为新建的蒙评对象挑选并记录评审官（不发送通知），人选不足 AcceptReviewersPerObject 位时先指派已有的，
+// 缺额由 ReassignOverdueAcceptReviews 补派；一位也没有时返回 ErrAcceptNoEligibleReviewer
+func AssignAcceptReviewers(ctx context.Context, acceptObjectId, authorUserId int) ([]AcceptAssignment, error) {
+	ao := AcceptObject{Id: acceptObjectId}
+	if err := ao.Get(); err != nil {
+		return nil, fmt.Errorf("查询蒙评对象失败: %v", err)
+	}
+	reviewers, err := chooseAcceptReviewers(ctx, ao, authorUserId, AcceptReviewersPerObject, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(reviewers) == 0 {
+		return nil, ErrAcceptNoEligibleReviewer
+	}
+	if len(reviewers) < AcceptReviewersPerObject {
+		util.Warningf("蒙评对象 %d 只找到 %d 位评审官人选，缺额待补派", ao.Id, len(reviewers))
+	}
+	return insertAcceptAssignments(ctx, ao.Id, authorUserId, reviewers, AcceptAssignmentReason_Initial, 0, time.Now())
+}
+
+// AcceptAssignmentOpen 茶友是否可以提交对该蒙评对象的评审意见：recorded 表示该对象有指派记录，
+// 没有记录的旧对象由调用方按邀请通知核对；有记录时 open 表示本人的指派待答复且未超时
+func AcceptAssignmentOpen(ctx context.Context, acceptObjectId, userId int) (recorded, open bool, err error) {
+	err = DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) > 0,
+		       COUNT(*) FILTER (WHERE reviewer_user_id = $2 AND status = $3) > 0
+		FROM accept_assignments WHERE accept_object_id = $1`,
+		acceptObjectId, userId, AcceptAssignmentStatus_Assigned).Scan(&recorded, &open)
+	if err != nil {
+		return false, false, fmt.Errorf("查询评审官指派失败: %v", err)
+	}
+	return recorded, open, nil
+}
+
+// markAcceptAssignmentAnswered 评审官已提交意见
+func markAcceptAssignmentAnswered(ctx context.Context, tx *sql.Tx, acceptObjectId, userId int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE accept_assignments SET status = $3, answered_at = $4
+		WHERE accept_object_id = $1 AND reviewer_user_id = $2 AND status = $5`,
+		acceptObjectId, userId, AcceptAssignmentStatus_Answered, time.Now(), AcceptAssignmentStatus_Assigned)
+	if err != nil {
+		return fmt.Errorf("更新评审官指派失败: %v", err)
+	}
+	return nil
+}
+
+// AcceptReassignSummary 超时改派的执行结果
+type AcceptReassignSummary struct {
+	TimedOut     int // 标记为超时的指派
+	DecidedAlone int // 由可信评审官独自裁定的蒙评对象
+	Failed       int // 独自裁定后未能处理的蒙评对象
+	Reassigned   int // 改派或补派的评审官
+	Short        int // 仍有缺额的蒙评对象
+}
+
+// TimeOutOverdueAcceptAssignments 将超过期限仍未答复的指派标记为超时，撤回其邀请通知并更新其信誉统计，返回超时的指派数
+func TimeOutOverdueAcceptAssignments(ctx context.Context, now time.Time) (int, error) {
+	rows, err := DB.QueryContext(ctx, `
+		UPDATE accept_assignments SET status = $1, timed_out_at = $2
+		WHERE status = $3 AND deadline_at < $2
+		RETURNING accept_object_id, reviewer_user_id`,
+		AcceptAssignmentStatus_TimedOut, now, AcceptAssignmentStatus_Assigned)
+	if err != nil {
+		return 0, fmt.Errorf("标记超时指派失败: %v", err)
+	}
+	type timedOut struct{ aoId, reviewerId int }
+	var expired []timedOut
+	for rows.Next() {
+		var t timedOut
+		if err = rows.Scan(&t.aoId, &t.reviewerId); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("扫描超时指派失败: %v", err)
+		}
+		expired = append(expired, t)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, t := range expired {
+		if err = withdrawAcceptInvitation(ctx, t.aoId, t.reviewerId); err != nil {
+			util.Warningf("撤回超时评审官 %d 对蒙评对象 %d 的邀请失败: %v", t.reviewerId, t.aoId, err)
+		}
+		if err = RefreshAcceptReviewerStats(ctx, t.reviewerId); err != nil {
+			util.Warningf("更新评审官 %d 信誉统计失败: %v", t.reviewerId, err)
+		}
+	}
+	return len(expired), nil
+}
+
+// ReassignShortAcceptReviews 为评审官缺额（超时或首次指派人选不足）且尚未得出结论的蒙评对象改派评审官并发送邀请，
+// 结果记入 summary 的 Reassigned、Short
+func ReassignShortAcceptReviews(ctx context.Context, now time.Time, summary *AcceptReassignSummary) error {
+	// 缺额的对象：有指派记录、仍在评审或已答复的评审官不足额
+	type shortObject struct {
+		aoId, authorId, missing, lastTimedOutId, pairGender int
+	}
+	rows, err := DB.QueryContext(ctx, `
+		SELECT a.accept_object_id, MAX(a.author_user_id),
+		       $1 - COUNT(*) FILTER (WHERE a.status <> $2),
+		       COALESCE(MAX(a.id) FILTER (WHERE a.status = $2), 0),
+		       COALESCE(MIN(COALESCE(u.gender, -1)) FILTER (WHERE a.status <> $2), -1)
+		FROM accept_assignments a
+		JOIN users u ON u.id = a.reviewer_user_id
+		WHERE NOT EXISTS (SELECT 1 FROM acceptances c WHERE c.accept_object_id = a.accept_object_id AND COALESCE(c.y_user_id, 0) <> 0)
+		GROUP BY a.accept_object_id
+		HAVING COUNT(*) FILTER (WHERE a.status <> $2) < $1
+		ORDER BY a.accept_object_id`,
+		AcceptReviewersPerObject, AcceptAssignmentStatus_TimedOut)
+	if err != nil {
+		return fmt.Errorf("查询缺额的蒙评对象失败: %v", err)
+	}
+	var shorts []shortObject
+	for rows.Next() {
+		var s shortObject
+		if err = rows.Scan(&s.aoId, &s.authorId, &s.missing, &s.lastTimedOutId, &s.pairGender); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描缺额的蒙评对象失败: %v", err)
+		}
+		shorts = append(shorts, s)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range shorts {
+		ao := AcceptObject{Id: s.aoId}
+		if err = ao.Get(); err != nil {
+			return fmt.Errorf("查询蒙评对象 %d 失败: %v", s.aoId, err)
+		}
+		reviewers, err := chooseAcceptReviewers(ctx, ao, s.authorId, s.missing, s.pairGender)
+		if err != nil {
+			return err
+		}
+		if len(reviewers) < s.missing {
+			summary.Short++
+		}
+		if len(reviewers) == 0 {
+			continue
+		}
+		assignments, err := insertAcceptAssignments(ctx, ao.Id, s.authorId, reviewers, AcceptAssignmentReason_Reassign, s.lastTimedOutId, now)
+		if err != nil {
+			return err
+		}
+		for _, a := range assignments {
+			if err = sendAcceptInvitation(ctx, ao.Id, a.ReviewerUserId); err != nil {
+				return err
+			}
+			util.Infof("蒙评对象 %d 改派评审官 %d，答复期限 %s", ao.Id, a.ReviewerUserId, a.DeadlineAt.Format(FMT_DATE_TIME_CN))
+		}
+		summary.Reassigned += len(assignments)
+	}
+	return nil
+}
+
+// sendAcceptInvitation 向评审官发送友邻蒙评邀请通知并记1条新通知
+func sendAcceptInvitation(ctx context.Context, acceptObjectId, reviewerUserId int) error {
+	mess := AcceptNotification{
+		FromUserId:     UserId_Captain_Spaceship,
+		Title:          AcceptInvitationTitle,
+		Content:        AcceptInvitationContent,
+		AcceptObjectId: acceptObjectId,
+	}
+	if err := mess.SendWithContext([]int{reviewerUserId}, ctx); err != nil {
+		return fmt.Errorf("发送蒙评邀请失败: %v", err)
+	}
+	if err := AddUserNotificationCount(reviewerUserId); err != nil {
+		return fmt.Errorf("记录新通知数失败: %v", err)
+	}
+	return nil
+}
+
+// withdrawAcceptInvitation 撤回超时评审官的邀请通知，未读的同时减去1条新通知
+func withdrawAcceptInvitation(ctx context.Context, acceptObjectId, reviewerUserId int) error {
+	var unread int
+	err := DB.QueryRowContext(ctx, `
+		WITH deleted AS (
+			DELETE FROM accept_notifications WHERE accept_object_id = $1 AND to_user_id = $2 RETURNING class
+		)
+		SELECT COUNT(*) FROM deleted WHERE class = $3`,
+		acceptObjectId, reviewerUserId, NotificationStatusUnread).Scan(&unread)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < unread; i++ {
+		if err = SubtractUserNotificationCount(reviewerUserId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const acceptAssignmentColumns = `
+	a.id, a.uuid, a.accept_object_id, COALESCE(o.object_type, 0), COALESCE(o.object_id, 0),
+	a.reviewer_user_id, COALESCE(u.name, ''), a.author_user_id, a.status, a.reason, COALESCE(a.replaces_assignment_id, 0),
+	a.assigned_at, a.deadline_at, a.answered_at, a.timed_out_at, a.created_at
+	FROM accept_assignments a
+	LEFT JOIN accept_objects o ON o.id = a.accept_object_id
+	LEFT JOIN users u ON u.id = a.reviewer_user_id`
+
+func scanAcceptAssignment(row interface{ Scan(...any) error }, a *AcceptAssignment) error {
+	return row.Scan(&a.Id, &a.Uuid, &a.AcceptObjectId, &a.ObjectType, &a.ObjectId,
+		&a.ReviewerUserId, &a.ReviewerName, &a.AuthorUserId, &a.Status, &a.Reason, &a.ReplacesAssignmentId,
+		&a.AssignedAt, &a.DeadlineAt, &a.AnsweredAt, &a.TimedOutAt, &a.CreatedAt)
+}
+
+func queryAcceptAssignments(ctx context.Context, where string, args ...any) ([]AcceptAssignment, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+acceptAssignmentColumns+` `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询评审官指派失败: %v", err)
+	}
+	defer rows.Close()
+	var assignments []AcceptAssignment
+	for rows.Next() {
+		var a AcceptAssignment
+		if err = scanAcceptAssignment(rows, &a); err != nil {
+			return nil, fmt.Errorf("扫描评审官指派失败: %v", err)
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+// RecentAcceptAssignments 最近的指派记录，最新的在前
+func RecentAcceptAssignments(ctx context.Context, limit int) ([]AcceptAssignment, error) {
+	return queryAcceptAssignments(ctx, `ORDER BY a.id DESC LIMIT $1`, limit)
+}
+
+// AcceptAssignmentsByObject 蒙评对象的全部指派记录，先指派的在前
+func AcceptAssignmentsByObject(ctx context.Context, acceptObjectId int) ([]AcceptAssignment, error) {
+	return queryAcceptAssignments(ctx, `WHERE a.accept_object_id = $1 ORDER BY a.id`, acceptObjectId)
+}
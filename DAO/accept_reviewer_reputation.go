@@ -0,0 +1,640 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+友邻蒙评评审官信誉（accept_review_votes、accept_review_checks、accept_reviewer_stats）：
+1、评审官提交意见时记一行，另一位答复后两人的意见互为对照，记下是否一致；意见不一致时本人接纳记为偏宽、本人婉拒记为偏严；
+2、茶博士抽查复核（或申诉复审）认定正确结论后，与之一致的意见记为维持，不一致的记为推翻；
+3、信誉分 0~100：与同伴一致率、复核维持率、按期答复率加权（40%、40%、20%），三项都以 80% 为先验按
+   acceptReviewerScorePriorWeight 件评审平滑，新评审官为 AcceptReviewerDefaultScore 分；
+4、已答复不少于 AcceptTrustedReviewerMinReviews 件且信誉分不低于 AcceptTrustedReviewerMinScore 的为可信评审官，
+   另一位超时未答复时由其独自裁定；信誉分低于 AcceptReviewerLowScore 的在挑选评审官时排在后面；
+5、统计在提交意见、复核、超时时更新，后台任务 accept_reviewer_stats 定期全量刷新。
+*/
+
+// AcceptReviewerDefaultScore 没有评审记录时的信誉分
+const AcceptReviewerDefaultScore = 80
+
+const (
+	acceptReviewerScorePrior       = 0.8
+	acceptReviewerScorePriorWeight = 5.0
+)
+
+// 复核结论
+const (
+	AcceptReviewOutcome_Upheld     = "upheld"     // 维持
+	AcceptReviewOutcome_Overturned = "overturned" // 推翻
+)
+
+// 复核来源
+const (
+	AcceptReviewCheckSource_SpotCheck = "spot_check" // 茶博士抽查
+	AcceptReviewCheckSource_Appeal    = "appeal"     // 申诉复审
+)
+
+var (
+	ErrAcceptReviewNotDecided = errors.New("该蒙评对象尚未得出结论")
+	ErrAcceptReviewDecided    = errors.New("该蒙评对象已经得出结论")
+	ErrAcceptReviewCheckSelf  = errors.New("不能复核自己参与评审的对象")
+)
+
+// AcceptReviewVote 评审官对蒙评对象的意见
+type AcceptReviewVote struct {
+	Id               int
+	Uuid             string
+	AcceptObjectId   int
+	ObjectType       int
+	ObjectId         int
+	ReviewerUserId   int
+	Accept           bool
+	CoReviewerUserId int
+	CoReviewerName   string
+	Agreed           *bool // 与另一位是否一致，尚无对照时为空
+	DecidedAlone     bool
+	Outcome          string
+	OutcomeAt        *time.Time
+	CreatedAt        time.Time
+}
+
+// ObjectTypeString 蒙评对象类型的中文说明
+func (v *AcceptReviewVote) ObjectTypeString() string {
+	return acceptObjectTypeString(v.ObjectType)
+}
+
+// VerdictString 意见的中文说明
+func (v *AcceptReviewVote) VerdictString() string {
+	if v.Accept {
+		return "接纳"
+	}
+	return "婉拒"
+}
+
+// AgreementString 与另一位评审官是否一致
+func (v *AcceptReviewVote) AgreementString() string {
+	switch {
+	case v.DecidedAlone:
+		return "独自裁定"
+	case v.Agreed == nil:
+		return "待对照"
+	case *v.Agreed:
+		return "一致"
+	}
+	return "不一致"
+}
+
+// OutcomeString 复核结论的中文说明
+func (v *AcceptReviewVote) OutcomeString() string {
+	switch v.Outcome {
+	case AcceptReviewOutcome_Upheld:
+		return "复核维持"
+	case AcceptReviewOutcome_Overturned:
+		return "复核推翻"
+	}
+	return "-"
+}
+
+// AcceptReviewerStats 评审官统计及信誉分
+type AcceptReviewerStats struct {
+	UserId             int
+	UserName           string
+	Answered           int // 已答复
+	Accepted           int // 其中接纳
+	Paired             int // 有同伴意见对照的
+	Agreed             int // 其中与同伴一致
+	LaxDisagreements   int // 不一致时本人接纳
+	HarshDisagreements int // 不一致时本人婉拒
+	Upheld             int // 复核维持
+	Overturned         int // 复核推翻
+	TimedOut           int // 超时未答复
+	DecidedAlone       int // 独自裁定
+	Score              int
+	UpdatedAt          time.Time
+}
+
+// ComputeAcceptReviewerScore 按统计计算信誉分
+func ComputeAcceptReviewerScore(s AcceptReviewerStats) int {
+	smooth := func(good, total int) float64 {
+		return (float64(good) + acceptReviewerScorePrior*acceptReviewerScorePriorWeight) /
+			(float64(total) + acceptReviewerScorePriorWeight)
+	}
+	agreement := smooth(s.Agreed, s.Paired)
+	outcome := smooth(s.Upheld, s.Upheld+s.Overturned)
+	responsive := smooth(s.Answered, s.Answered+s.TimedOut)
+	return int(math.Round(100 * (0.4*agreement + 0.4*outcome + 0.2*responsive)))
+}
+
+// reviewPercent 百分比，没有样本时为"-"
+func reviewPercent(part, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", int(math.Round(100*float64(part)/float64(total))))
+}
+
+// AgreementRate 与同伴一致率
+func (s *AcceptReviewerStats) AgreementRate() string {
+	return reviewPercent(s.Agreed, s.Paired)
+}
+
+// AcceptRate 接纳率
+func (s *AcceptReviewerStats) AcceptRate() string {
+	return reviewPercent(s.Accepted, s.Answered)
+}
+
+// UpheldRate 复核维持率
+func (s *AcceptReviewerStats) UpheldRate() string {
+	return reviewPercent(s.Upheld, s.Upheld+s.Overturned)
+}
+
+// Tendency 与同伴意见不一致时的倾向：一方至少3次且不少于另一方两倍时为偏宽或偏严
+func (s *AcceptReviewerStats) Tendency() string {
+	switch {
+	case s.LaxDisagreements >= 3 && s.LaxDisagreements >= 2*s.HarshDisagreements:
+		return "偏宽"
+	case s.HarshDisagreements >= 3 && s.HarshDisagreements >= 2*s.LaxDisagreements:
+		return "偏严"
+	}
+	return "适中"
+}
+
+// IsTrustedWith 按给定门槛判断是否为可信评审官，minReviews 为0表示不启用
+func (s *AcceptReviewerStats) IsTrustedWith(minReviews, minScore int64) bool {
+	return minReviews > 0 && int64(s.Answered) >= minReviews && int64(s.Score) >= minScore
+}
+
+// IsTrusted 是否为可信评审官（按配置）
+func (s *AcceptReviewerStats) IsTrusted() bool {
+	return s.IsTrustedWith(util.Config.AcceptTrustedReviewerMinReviews, util.Config.AcceptTrustedReviewerMinScore)
+}
+
+// IsLowScore 信誉分是否低于 AcceptReviewerLowScore
+func (s *AcceptReviewerStats) IsLowScore() bool {
+	return int64(s.Score) < util.Config.AcceptReviewerLowScore
+}
+
+// SubmitAcceptReview 友邻评审官提交意见：在同一事务中写入蒙评记录（第一位记为X，第二位记为Y）、
+// 记录评审意见并将其指派标记为已答复；另一位已答复时互为对照，记下是否一致。
+// 锁定蒙评对象避免两位同时成为第一位；Y 只在仍未填写时写入，已有结论（含可信评审官独自裁定）
+// 或本人已提交过时返回 ErrAcceptReviewDecided。second 为 true 时返回的记录已有两位意见
+func SubmitAcceptReview(ctx context.Context, acceptObjectId, reviewerUserId int, accept bool) (a Acceptance, second bool, err error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return a, false, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, `SELECT id FROM accept_objects WHERE id = $1 FOR UPDATE`, acceptObjectId); err != nil {
+		return a, false, fmt.Errorf("锁定蒙评对象失败: %v", err)
+	}
+	now := time.Now()
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, uuid, accept_object_id, x_accept, x_user_id, x_accepted_at, y_accept, y_user_id, y_accepted_at
+		FROM acceptances WHERE accept_object_id = $1 ORDER BY id LIMIT 1`, acceptObjectId).
+		Scan(&a.Id, &a.Uuid, &a.AcceptObjectId, &a.XAccept, &a.XUserId, &a.XAcceptedAt, &a.YAccept, &a.YUserId, &a.YAcceptedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		a = Acceptance{AcceptObjectId: acceptObjectId, XAccept: accept, XUserId: reviewerUserId, XAcceptedAt: now, YUserId: UserId_None}
+		if err = tx.QueryRowContext(ctx, `
+			INSERT INTO acceptances (accept_object_id, x_accept, x_user_id, x_accepted_at, y_accept, y_user_id)
+			VALUES ($1, $2, $3, $4, false, $5) RETURNING id, uuid`,
+			acceptObjectId, accept, reviewerUserId, now, UserId_None).Scan(&a.Id, &a.Uuid); err != nil {
+			return a, false, fmt.Errorf("创建蒙评记录失败: %v", err)
+		}
+	case err != nil:
+		return a, false, fmt.Errorf("查询蒙评记录失败: %v", err)
+	default:
+		if a.XUserId == reviewerUserId {
+			return a, false, ErrAcceptReviewDecided
+		}
+		result, err := tx.ExecContext(ctx, `
+			UPDATE acceptances SET y_accept = $2, y_user_id = $3, y_accepted_at = $4
+			WHERE id = $1 AND COALESCE(y_user_id, 0) = 0`,
+			a.Id, accept, reviewerUserId, now)
+		if err != nil {
+			return a, false, fmt.Errorf("更新蒙评记录失败: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return a, false, ErrAcceptReviewDecided
+		}
+		a.YAccept, a.YUserId, a.YAcceptedAt = accept, reviewerUserId, &now
+		second = true
+	}
+
+	coReviewerId, paired, err := recordAcceptReviewVote(ctx, tx, acceptObjectId, reviewerUserId, accept)
+	if err != nil {
+		return a, false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return a, false, fmt.Errorf("提交事务失败: %v", err)
+	}
+	if paired {
+		err = RefreshAcceptReviewerStats(ctx, reviewerUserId, coReviewerId)
+	} else {
+		err = RefreshAcceptReviewerStats(ctx, reviewerUserId)
+	}
+	if err != nil {
+		// 意见已记下，统计下次刷新时补上
+		util.WarningContext(ctx, "cannot refresh accept reviewer stats", reviewerUserId, err)
+	}
+	return a, second, nil
+}
+
+// recordAcceptReviewVote 在事务中记录评审官的意见并将其指派标记为已答复；另一位已答复时互为对照，记下是否一致
+func recordAcceptReviewVote(ctx context.Context, tx *sql.Tx, acceptObjectId, reviewerUserId int, accept bool) (coReviewerId int, paired bool, err error) {
+	if err = markAcceptAssignmentAnswered(ctx, tx, acceptObjectId, reviewerUserId); err != nil {
+		return
+	}
+	var coAccept bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT reviewer_user_id, accept FROM accept_review_votes
+		WHERE accept_object_id = $1 AND reviewer_user_id <> $2
+		ORDER BY id LIMIT 1 FOR UPDATE`, acceptObjectId, reviewerUserId).Scan(&coReviewerId, &coAccept)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("查询同伴意见失败: %v", err)
+	}
+	paired = err == nil
+	if _, err = tx.ExecContext(ctx, `
+		INSERT INTO accept_review_votes (accept_object_id, reviewer_user_id, accept, co_reviewer_user_id, agreed)
+		VALUES ($1, $2, $3, NULLIF($4, 0), CASE WHEN $5 THEN $3 = $6 END)`,
+		acceptObjectId, reviewerUserId, accept, coReviewerId, paired, coAccept); err != nil {
+		return 0, false, fmt.Errorf("记录评审意见失败: %v", err)
+	}
+	if paired {
+		if _, err = tx.ExecContext(ctx, `
+			UPDATE accept_review_votes SET co_reviewer_user_id = $3, agreed = (accept = $4)
+			WHERE accept_object_id = $1 AND reviewer_user_id = $2`,
+			acceptObjectId, coReviewerId, reviewerUserId, accept); err != nil {
+			return 0, false, fmt.Errorf("更新同伴意见失败: %v", err)
+		}
+	}
+	return coReviewerId, paired, nil
+}
+
+// RefreshAcceptReviewerStats 按意见及指派记录重新汇总评审官统计并计算信誉分
+func RefreshAcceptReviewerStats(ctx context.Context, userIds ...int) error {
+	for _, userId := range userIds {
+		s := AcceptReviewerStats{UserId: userId}
+		err := DB.QueryRowContext(ctx, `
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE accept),
+			       COUNT(agreed), COUNT(*) FILTER (WHERE agreed),
+			       COUNT(*) FILTER (WHERE NOT agreed AND accept), COUNT(*) FILTER (WHERE NOT agreed AND NOT accept),
+			       COUNT(*) FILTER (WHERE outcome = $2), COUNT(*) FILTER (WHERE outcome = $3),
+			       COUNT(*) FILTER (WHERE decided_alone),
+			       (SELECT COUNT(*) FROM accept_assignments WHERE reviewer_user_id = $1 AND status = $4)
+			FROM accept_review_votes WHERE reviewer_user_id = $1`,
+			userId, AcceptReviewOutcome_Upheld, AcceptReviewOutcome_Overturned, AcceptAssignmentStatus_TimedOut).
+			Scan(&s.Answered, &s.Accepted, &s.Paired, &s.Agreed, &s.LaxDisagreements, &s.HarshDisagreements,
+				&s.Upheld, &s.Overturned, &s.DecidedAlone, &s.TimedOut)
+		if err != nil {
+			return fmt.Errorf("汇总评审官 %d 统计失败: %v", userId, err)
+		}
+		s.Score = ComputeAcceptReviewerScore(s)
+		if _, err = DB.ExecContext(ctx, `
+			INSERT INTO accept_reviewer_stats
+				(user_id, answered, accepted, paired, agreed, lax_disagreements, harsh_disagreements,
+				 upheld, overturned, timed_out, decided_alone, score, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT (user_id) DO UPDATE SET
+				answered = EXCLUDED.answered, accepted = EXCLUDED.accepted, paired = EXCLUDED.paired, agreed = EXCLUDED.agreed,
+				lax_disagreements = EXCLUDED.lax_disagreements, harsh_disagreements = EXCLUDED.harsh_disagreements,
+				upheld = EXCLUDED.upheld, overturned = EXCLUDED.overturned, timed_out = EXCLUDED.timed_out,
+				decided_alone = EXCLUDED.decided_alone, score = EXCLUDED.score, updated_at = EXCLUDED.updated_at`,
+			s.UserId, s.Answered, s.Accepted, s.Paired, s.Agreed, s.LaxDisagreements, s.HarshDisagreements,
+			s.Upheld, s.Overturned, s.TimedOut, s.DecidedAlone, s.Score, time.Now()); err != nil {
+			return fmt.Errorf("保存评审官 %d 统计失败: %v", userId, err)
+		}
+	}
+	return nil
+}
+
+// RefreshAllAcceptReviewerStats 刷新所有有意见或指派记录的评审官统计，返回刷新的人数
+func RefreshAllAcceptReviewerStats(ctx context.Context) (int, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT reviewer_user_id FROM accept_review_votes
+		UNION
+		SELECT reviewer_user_id FROM accept_assignments`)
+	if err != nil {
+		return 0, fmt.Errorf("查询评审官失败: %v", err)
+	}
+	var userIds []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("扫描评审官失败: %v", err)
+		}
+		userIds = append(userIds, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if err = RefreshAcceptReviewerStats(ctx, userIds...); err != nil {
+		return 0, err
+	}
+	return len(userIds), nil
+}
+
+const acceptReviewerStatsColumns = `
+	s.user_id, COALESCE(u.name, ''), s.answered, s.accepted, s.paired, s.agreed, s.lax_disagreements, s.harsh_disagreements,
+	s.upheld, s.overturned, s.timed_out, s.decided_alone, s.score, s.updated_at
+	FROM accept_reviewer_stats s
+	LEFT JOIN users u ON u.id = s.user_id`
+
+func scanAcceptReviewerStats(row interface{ Scan(...any) error }, s *AcceptReviewerStats) error {
+	return row.Scan(&s.UserId, &s.UserName, &s.Answered, &s.Accepted, &s.Paired, &s.Agreed, &s.LaxDisagreements, &s.HarshDisagreements,
+		&s.Upheld, &s.Overturned, &s.TimedOut, &s.DecidedAlone, &s.Score, &s.UpdatedAt)
+}
+
+// GetAcceptReviewerStats 评审官的统计，没有记录时为新评审官的默认值
+func GetAcceptReviewerStats(ctx context.Context, userId int) (AcceptReviewerStats, error) {
+	var s AcceptReviewerStats
+	err := scanAcceptReviewerStats(DB.QueryRowContext(ctx, `SELECT `+acceptReviewerStatsColumns+` WHERE s.user_id = $1`, userId), &s)
+	if err == sql.ErrNoRows {
+		return AcceptReviewerStats{UserId: userId, Score: AcceptReviewerDefaultScore}, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("查询评审官统计失败: %v", err)
+	}
+	return s, nil
+}
+
+// TopAcceptReviewerStats 答复最多的评审官统计
+func TopAcceptReviewerStats(ctx context.Context, limit int) ([]AcceptReviewerStats, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+acceptReviewerStatsColumns+` ORDER BY s.answered DESC, s.score DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询评审官统计失败: %v", err)
+	}
+	defer rows.Close()
+	var list []AcceptReviewerStats
+	for rows.Next() {
+		var s AcceptReviewerStats
+		if err = scanAcceptReviewerStats(rows, &s); err != nil {
+			return nil, fmt.Errorf("扫描评审官统计失败: %v", err)
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// AcceptReviewVotesByReviewer 评审官的意见记录，最新的在前
+func AcceptReviewVotesByReviewer(ctx context.Context, userId int, limit int) ([]AcceptReviewVote, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT v.id, v.uuid, v.accept_object_id, COALESCE(o.object_type, 0), COALESCE(o.object_id, 0), v.reviewer_user_id, v.accept,
+		       COALESCE(v.co_reviewer_user_id, 0), COALESCE(cu.name, ''), v.agreed, v.decided_alone, v.outcome, v.outcome_at, v.created_at
+		FROM accept_review_votes v
+		LEFT JOIN accept_objects o ON o.id = v.accept_object_id
+		LEFT JOIN users cu ON cu.id = v.co_reviewer_user_id
+		WHERE v.reviewer_user_id = $1
+		ORDER BY v.id DESC LIMIT $2`, userId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询评审意见失败: %v", err)
+	}
+	defer rows.Close()
+	var votes []AcceptReviewVote
+	for rows.Next() {
+		var v AcceptReviewVote
+		if err = rows.Scan(&v.Id, &v.Uuid, &v.AcceptObjectId, &v.ObjectType, &v.ObjectId, &v.ReviewerUserId, &v.Accept,
+			&v.CoReviewerUserId, &v.CoReviewerName, &v.Agreed, &v.DecidedAlone, &v.Outcome, &v.OutcomeAt, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("扫描评审意见失败: %v", err)
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+// OpenAcceptAssignmentsByReviewer 评审官待答复的指派，期限近的在前
+func OpenAcceptAssignmentsByReviewer(ctx context.Context, userId int) ([]AcceptAssignment, error) {
+	return queryAcceptAssignments(ctx, `WHERE a.reviewer_user_id = $1 AND a.status = $2 ORDER BY a.deadline_at`,
+		userId, AcceptAssignmentStatus_Assigned)
+}
+
+// AcceptVerdict 已得出结论的蒙评对象
+type AcceptVerdict struct {
+	AcceptObjectId   int
+	ObjectType       int
+	ObjectId         int
+	Accepted         bool
+	XUserId          int
+	XUserName        string
+	YUserId          int
+	YUserName        string
+	DecidedAlone     bool // 可信评审官独自裁定（两份意见出自同一人）
+	DecidedAt        *time.Time
+	Checks           int   // 复核次数
+	LastCheckCorrect *bool // 最近一次复核认定的结论
+}
+
+// ObjectTypeString 蒙评对象类型的中文说明
+func (v *AcceptVerdict) ObjectTypeString() string {
+	return acceptObjectTypeString(v.ObjectType)
+}
+
+// VerdictString 结论的中文说明
+func (v *AcceptVerdict) VerdictString() string {
+	if v.Accepted {
+		return "接纳"
+	}
+	return "婉拒"
+}
+
+// CheckString 最近一次复核的结果
+func (v *AcceptVerdict) CheckString() string {
+	switch {
+	case v.LastCheckCorrect == nil:
+		return "未复核"
+	case *v.LastCheckCorrect == v.Accepted:
+		return "复核维持"
+	}
+	return "复核推翻"
+}
+
+// RecentAcceptVerdicts 最近得出结论的蒙评对象，供抽查复核
+func RecentAcceptVerdicts(ctx context.Context, limit int) ([]AcceptVerdict, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT c.accept_object_id, o.object_type, o.object_id, c.x_accept AND c.y_accept,
+		       c.x_user_id, COALESCE(xu.name, ''), c.y_user_id, COALESCE(yu.name, ''), c.x_user_id = c.y_user_id, c.y_accepted_at,
+		       (SELECT COUNT(*) FROM accept_review_checks k WHERE k.accept_object_id = c.accept_object_id),
+		       (SELECT k.correct_accepted FROM accept_review_checks k WHERE k.accept_object_id = c.accept_object_id ORDER BY k.id DESC LIMIT 1)
+		FROM acceptances c
+		JOIN accept_objects o ON o.id = c.accept_object_id
+		LEFT JOIN users xu ON xu.id = c.x_user_id
+		LEFT JOIN users yu ON yu.id = c.y_user_id
+		WHERE COALESCE(c.y_user_id, 0) <> 0
+		ORDER BY c.y_accepted_at DESC NULLS LAST, c.id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询蒙评结论失败: %v", err)
+	}
+	defer rows.Close()
+	var list []AcceptVerdict
+	for rows.Next() {
+		var v AcceptVerdict
+		if err = rows.Scan(&v.AcceptObjectId, &v.ObjectType, &v.ObjectId, &v.Accepted, &v.XUserId, &v.XUserName, &v.YUserId, &v.YUserName,
+			&v.DecidedAlone, &v.DecidedAt, &v.Checks, &v.LastCheckCorrect); err != nil {
+			return nil, fmt.Errorf("扫描蒙评结论失败: %v", err)
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+// AcceptReviewCheck 复核记录
+type AcceptReviewCheck struct {
+	Id              int
+	Uuid            string
+	AcceptObjectId  int
+	CheckerUserId   int
+	Source          string
+	VerdictAccepted bool
+	CorrectAccepted bool
+	Note            string
+	CreatedAt       time.Time
+}
+
+// IsOverturned 复核是否推翻了友邻蒙评的结论
+func (c *AcceptReviewCheck) IsOverturned() bool {
+	return c.VerdictAccepted != c.CorrectAccepted
+}
+
+// RecordAcceptReviewCheck 记录复核结论：与之一致的评审意见记为维持，不一致的记为推翻，并更新评审官信誉。
+// 推翻时须说明理由；抽查复核人不能是该对象的评审官。只记录结论，不改变蒙评对象本身
+func RecordAcceptReviewCheck(ctx context.Context, acceptObjectId, checkerUserId int, source string, correctAccepted bool, note string) (AcceptReviewCheck, error) {
+	c := AcceptReviewCheck{AcceptObjectId: acceptObjectId, CheckerUserId: checkerUserId, Source: source, CorrectAccepted: correctAccepted, Note: strings.TrimSpace(note)}
+	switch source {
+	case AcceptReviewCheckSource_SpotCheck, AcceptReviewCheckSource_Appeal:
+	default:
+		return c, fmt.Errorf("未知的复核来源 %s", source)
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return c, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	var xUserId, yUserId int
+	err = tx.QueryRowContext(ctx, `
+		SELECT x_accept AND y_accept, x_user_id, y_user_id FROM acceptances
+		WHERE accept_object_id = $1 AND COALESCE(y_user_id, 0) <> 0
+		ORDER BY id LIMIT 1 FOR UPDATE`, acceptObjectId).Scan(&c.VerdictAccepted, &xUserId, &yUserId)
+	if err == sql.ErrNoRows {
+		return c, ErrAcceptReviewNotDecided
+	}
+	if err != nil {
+		return c, fmt.Errorf("查询蒙评结论失败: %v", err)
+	}
+	if source == AcceptReviewCheckSource_SpotCheck && (checkerUserId == xUserId || checkerUserId == yUserId) {
+		return c, ErrAcceptReviewCheckSelf
+	}
+	if c.IsOverturned() && c.Note == "" {
+		return c, fmt.Errorf("推翻友邻蒙评结论须说明理由")
+	}
+	if err = tx.QueryRowContext(ctx, `
+		INSERT INTO accept_review_checks (accept_object_id, checker_user_id, source, verdict_accepted, correct_accepted, note)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6)
+		RETURNING id, uuid, created_at`,
+		c.AcceptObjectId, c.CheckerUserId, c.Source, c.VerdictAccepted, c.CorrectAccepted, c.Note).Scan(&c.Id, &c.Uuid, &c.CreatedAt); err != nil {
+		return c, fmt.Errorf("记录复核失败: %v", err)
+	}
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE accept_review_votes
+		SET outcome = CASE WHEN accept = $2 THEN $3 ELSE $4 END, outcome_at = $5
+		WHERE accept_object_id = $1
+		RETURNING reviewer_user_id`,
+		acceptObjectId, correctAccepted, AcceptReviewOutcome_Upheld, AcceptReviewOutcome_Overturned, c.CreatedAt)
+	if err != nil {
+		return c, fmt.Errorf("更新评审意见复核结论失败: %v", err)
+	}
+	var reviewerIds []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return c, fmt.Errorf("扫描评审官失败: %v", err)
+		}
+		reviewerIds = append(reviewerIds, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return c, err
+	}
+	if err = tx.Commit(); err != nil {
+		return c, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return c, RefreshAcceptReviewerStats(ctx, reviewerIds...)
+}
+
+// AcceptSoloDecision 可由可信评审官独自裁定的蒙评对象
+type AcceptSoloDecision struct {
+	AcceptObject   AcceptObject
+	ReviewerUserId int
+	Accept         bool
+}
+
+// TrustedSoloAcceptDecisions 另一位评审官超时未答复、没有待答复的改派，且已答复的一位是可信评审官的蒙评对象
+func TrustedSoloAcceptDecisions(ctx context.Context) ([]AcceptSoloDecision, error) {
+	if util.Config.AcceptTrustedReviewerMinReviews <= 0 {
+		return nil, nil
+	}
+	rows, err := DB.QueryContext(ctx, `
+		SELECT o.id, o.uuid, o.object_type, o.object_id, c.x_user_id, c.x_accept
+		FROM acceptances c
+		JOIN accept_objects o ON o.id = c.accept_object_id
+		JOIN accept_reviewer_stats s ON s.user_id = c.x_user_id
+		WHERE COALESCE(c.y_user_id, 0) = 0
+		  AND s.answered >= $1 AND s.score >= $2
+		  AND EXISTS (SELECT 1 FROM accept_assignments a WHERE a.accept_object_id = c.accept_object_id AND a.status = $3)
+		  AND NOT EXISTS (SELECT 1 FROM accept_assignments a WHERE a.accept_object_id = c.accept_object_id AND a.status = $4)
+		ORDER BY o.id`,
+		util.Config.AcceptTrustedReviewerMinReviews, util.Config.AcceptTrustedReviewerMinScore,
+		AcceptAssignmentStatus_TimedOut, AcceptAssignmentStatus_Assigned)
+	if err != nil {
+		return nil, fmt.Errorf("查询可独自裁定的蒙评对象失败: %v", err)
+	}
+	defer rows.Close()
+	var list []AcceptSoloDecision
+	for rows.Next() {
+		var d AcceptSoloDecision
+		if err = rows.Scan(&d.AcceptObject.Id, &d.AcceptObject.Uuid, &d.AcceptObject.ObjectType, &d.AcceptObject.ObjectId,
+			&d.ReviewerUserId, &d.Accept); err != nil {
+			return nil, fmt.Errorf("扫描可独自裁定的蒙评对象失败: %v", err)
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// DecideAcceptReviewAlone 可信评审官独自裁定：其意见同时记为第二份意见，蒙评对象随即得出结论
+func DecideAcceptReviewAlone(ctx context.Context, d AcceptSoloDecision) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE acceptances SET y_accept = x_accept, y_user_id = x_user_id, y_accepted_at = $3
+		WHERE accept_object_id = $1 AND x_user_id = $2 AND COALESCE(y_user_id, 0) = 0`,
+		d.AcceptObject.Id, d.ReviewerUserId, time.Now())
+	if err != nil {
+		return fmt.Errorf("更新蒙评记录失败: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrAcceptReviewDecided
+	}
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE accept_review_votes SET decided_alone = true WHERE accept_object_id = $1 AND reviewer_user_id = $2`,
+		d.AcceptObject.Id, d.ReviewerUserId); err != nil {
+		return fmt.Errorf("更新评审意见失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	return RefreshAcceptReviewerStats(ctx, d.ReviewerUserId)
+}
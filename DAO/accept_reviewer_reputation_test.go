@@ -0,0 +1,75 @@
+package dao
+
+import "testing"
+
+func TestComputeAcceptReviewerScore(t *testing.T) {
+	if got := ComputeAcceptReviewerScore(AcceptReviewerStats{}); got != AcceptReviewerDefaultScore {
+		t.Errorf("新评审官信誉分 %d, want %d", got, AcceptReviewerDefaultScore)
+	}
+	good := AcceptReviewerStats{Answered: 30, Paired: 30, Agreed: 29, Upheld: 10}
+	if got := ComputeAcceptReviewerScore(good); got < 90 {
+		t.Errorf("一致率高、复核均维持的信誉分 %d, want >= 90", got)
+	}
+	bad := AcceptReviewerStats{Answered: 20, Paired: 20, Agreed: 8, Upheld: 1, Overturned: 8, TimedOut: 10}
+	if got := ComputeAcceptReviewerScore(bad); got >= 50 {
+		t.Errorf("常被推翻、常超时的信誉分 %d, want < 50", got)
+	}
+	timedOut := AcceptReviewerStats{TimedOut: 5}
+	if got := ComputeAcceptReviewerScore(timedOut); got >= AcceptReviewerDefaultScore {
+		t.Errorf("只超时不答复的信誉分 %d, want < %d", got, AcceptReviewerDefaultScore)
+	}
+}
+
+func TestAcceptReviewerStatsTendency(t *testing.T) {
+	cases := []struct {
+		lax, harsh int
+		want       string
+	}{
+		{0, 0, "适中"},
+		{3, 1, "偏宽"},
+		{3, 2, "适中"},
+		{1, 4, "偏严"},
+		{2, 0, "适中"},
+	}
+	for _, c := range cases {
+		s := AcceptReviewerStats{LaxDisagreements: c.lax, HarshDisagreements: c.harsh}
+		if got := s.Tendency(); got != c.want {
+			t.Errorf("偏宽 %d 偏严 %d: got %s, want %s", c.lax, c.harsh, got, c.want)
+		}
+	}
+}
+
+func TestAcceptReviewerStatsIsTrustedWith(t *testing.T) {
+	s := AcceptReviewerStats{Answered: 20, Score: 92}
+	if !s.IsTrustedWith(20, 90) {
+		t.Errorf("达到门槛应为可信评审官")
+	}
+	if s.IsTrustedWith(0, 90) {
+		t.Errorf("最少评审数为0时不启用")
+	}
+	if s.IsTrustedWith(21, 90) || s.IsTrustedWith(20, 93) {
+		t.Errorf("未达门槛不应为可信评审官")
+	}
+}
+
+func TestAcceptReviewerRates(t *testing.T) {
+	s := AcceptReviewerStats{Answered: 8, Accepted: 6, Paired: 4, Agreed: 3}
+	if s.AcceptRate() != "75%" || s.AgreementRate() != "75%" || s.UpheldRate() != "-" {
+		t.Errorf("比例 %s %s %s", s.AcceptRate(), s.AgreementRate(), s.UpheldRate())
+	}
+	agreed, disagreed := true, false
+	votes := []struct {
+		v    AcceptReviewVote
+		want string
+	}{
+		{AcceptReviewVote{}, "待对照"},
+		{AcceptReviewVote{Agreed: &agreed}, "一致"},
+		{AcceptReviewVote{Agreed: &disagreed}, "不一致"},
+		{AcceptReviewVote{Agreed: &agreed, DecidedAlone: true}, "独自裁定"},
+	}
+	for _, c := range votes {
+		if got := c.v.AgreementString(); got != c.want {
+			t.Errorf("AgreementString got %s, want %s", got, c.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func pickedIds(picked []AcceptReviewerCandidate) []int {
+	var ids []int
+	for _, c := range picked {
+		ids = append(ids, c.UserId)
+	}
+	return ids
+}
+
+func cand(userId, gender, openLoad int) AcceptReviewerCandidate {
+	return AcceptReviewerCandidate{UserId: userId, Gender: gender, OpenLoad: openLoad}
+}
+
+func TestPickAcceptReviewers(t *testing.T) {
+	f, m := User_Gender_Female, User_Gender_Male
+	cases := []struct {
+		name       string
+		candidates []AcceptReviewerCandidate
+		n          int
+		pairWith   int
+		want       []int
+	}{
+		{"男女搭配", []AcceptReviewerCandidate{cand(1, m, 0), cand(2, m, 0), cand(3, f, 1)}, 2, -1, []int{1, 3}},
+		{"异性负担过重时取最少者", []AcceptReviewerCandidate{cand(1, m, 0), cand(2, m, 0), cand(3, f, 2)}, 2, -1, []int{1, 2}},
+		{"未答复少者优先", []AcceptReviewerCandidate{cand(1, f, 3), cand(2, m, 1), cand(3, f, 0)}, 2, -1, []int{3, 2}},
+		{"改派与留任者搭配", []AcceptReviewerCandidate{cand(1, f, 0), cand(2, m, 1)}, 1, f, []int{2}},
+		{"未填写性别不偏好", []AcceptReviewerCandidate{cand(1, -1, 0), cand(2, -1, 0), cand(3, f, 1)}, 2, -1, []int{1, 2}},
+		{"人选不足", []AcceptReviewerCandidate{cand(1, f, 0)}, 2, -1, []int{1}},
+		{"没有人选", nil, 2, -1, nil},
+		{"信誉分低者靠后", []AcceptReviewerCandidate{{UserId: 1, Gender: f, LowScore: true}, cand(2, m, 2), cand(3, m, 3)}, 2, -1, []int{2, 3}},
+		{"信誉分低者补足人数", []AcceptReviewerCandidate{{UserId: 1, Gender: f, LowScore: true}, cand(2, m, 2)}, 2, -1, []int{2, 1}},
+	}
+	for _, c := range cases {
+		got := pickedIds(PickAcceptReviewers(c.candidates, c.n, c.pairWith))
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPickAcceptReviewersKeepsCandidates(t *testing.T) {
+	candidates := []AcceptReviewerCandidate{cand(1, User_Gender_Male, 0), cand(2, User_Gender_Male, 0), cand(3, User_Gender_Female, 0)}
+	PickAcceptReviewers(candidates, 2, -1)
+	if pickedIds(candidates)[1] != 2 || pickedIds(candidates)[2] != 3 {
+		t.Errorf("候选人列表被改动: %v", pickedIds(candidates))
+	}
+}
+
+func TestAcceptAssignmentStatus(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := AcceptAssignment{Status: AcceptAssignmentStatus_Assigned, Reason: AcceptAssignmentReason_Reassign, DeadlineAt: now.Add(-time.Minute)}
+	if !a.IsOpen() || !a.IsOverdue(now) {
+		t.Errorf("逾期未答复的指派应为待答复且超时")
+	}
+	if a.StatusString() != "待答复" || a.ReasonString() != "超时改派" {
+		t.Errorf("状态说明 %s %s", a.StatusString(), a.ReasonString())
+	}
+	a.DeadlineAt = now.Add(time.Hour)
+	if a.IsOverdue(now) {
+		t.Errorf("未到期限不应超时")
+	}
+	a.Status = AcceptAssignmentStatus_Answered
+	if a.IsOpen() || a.IsOverdue(now.Add(2*time.Hour)) {
+		t.Errorf("已答复的指派不应待答复或超时")
+	}
+}
@@ -1,19 +1,16 @@
 package dao
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha1"
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
-	"runtime"
 	"strconv"
 	util "teachat/Util"
 	"time"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
@@ -21,52 +18,40 @@ import (
    涉及数据库存取操作的定义和一些方法
 */
 
-//var db *sql.DB //数据库实例
-
-// DB 数据库实例，请局变量
+// DB 数据库实例，全局变量，由 Open 初始化
 var DB *sql.DB
 
-func init() {
-	var err error
-
-	// 获取项目根目录（无论从哪里调用）
-	_, filename, _, _ := runtime.Caller(0)
-	rootDir := filepath.Dir(filepath.Dir(filename)) // 根据实际层级调整
-	// 加载 .env 文件
-	err = godotenv.Load(filepath.Join(rootDir, ".env"))
-	if err != nil {
-		util.PrintStdout("Error loading .env file")
-	}
-	// 从环境变量获取数据库配置
-	dbdriver := os.Getenv("DB_DRIVER")
-	dbhost := os.Getenv("DB_HOST")
-	dbport, _ := strconv.Atoi(os.Getenv("DB_PORT")) // 字符串转整数
-	dbuser := os.Getenv("DB_USER")
-	dbpassword := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-	dbsslmode := os.Getenv("DB_SSLMODE")
-	dbTimeZone := os.Getenv("DB_TIMEZONE")
-	//数据库连接
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
-		"password=%s dbname=%s sslmode=%s TimeZone=%s",
-		dbhost, dbport, dbuser, dbpassword, dbname, dbsslmode, dbTimeZone)
-	DB, err = sql.Open(dbdriver, psqlInfo)
+// Open 按配置打开数据库并设置连接池，测试连接成功后赋值给全局 DB。
+// 应用启动时显式调用，导入本包不再连接数据库
+func Open(cfg util.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open(cfg.Driver, cfg.DSN())
 	if err != nil {
-		util.Panic("星际迷失->茶棚数据库打开时：", err)
+		return nil, fmt.Errorf("星际迷失->茶棚数据库打开时: %w", err)
 	}
 	// 配置连接池
-	//DB.SetMaxOpenConns(25)
-	//DB.SetMaxIdleConns(25)
-	DB.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetimeMinutes) * time.Minute)
+	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTimeMinutes) * time.Minute)
 
 	//测试数据库连接是否成功
-	if err = DB.Ping(); err != nil {
-		util.Panic("ping teachat database failure - 测试链接茶话会数据库失败", err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping teachat database failure - 测试链接茶话会数据库失败: %w", err)
 	}
 
-	//ok
-	util.PrintStdout("Open tea chat database success, 星际茶棚数据库打开成功")
+	DB = db
+	return db, nil
+}
 
+// Close 关闭全局数据库连接
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Close()
 }
 
 // create a random UUID with from RFC 4122
@@ -87,7 +72,7 @@ func Random_UUID() (uuid string) {
 	return
 }
 
-// hash plaintext with SHA-1
+// hash plaintext with SHA-1，仅用于校验历史遗留的密码摘要，新密码请使用 HashPassword
 func Encrypt(plaintext string) (cryptext string) {
 	cryptext = fmt.Sprintf("%x", sha1.Sum([]byte(plaintext)))
 	return
@@ -0,0 +1,574 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	util "teachat/Util"
+)
+
+/*
+邀请函、申请书、声明书自动过期：
+1、茶团邀请函、加盟茶团申请书、集团邀请函、家庭成员声明书，在待处理或已查看状态下，
+   自创建或最近续期时起超过各自期限（TeamInvitationExpireDays 等，负数不过期）仍未答复的，
+   由后台任务 document_expiry 标记为已过期（ExpireStaleDocuments）；
+2、每份过期文书给发出方和接收方各发一条过期提醒，计入小黑板；接收方原本未查看的，创建时记下的小黑板通知数改由提醒占用，不再另加；
+3、发出方可在提醒中续期（RenewExpiredDocument）：文书回到待处理状态，期限从续期时重新起算，
+   续期时按现有规则重新检查发出方的权限、接收方是否已经加入。
+*/
+
+// 过期文书类别
+const (
+	ExpiryDocTeamInvitation    = "team_invitation"
+	ExpiryDocMemberApplication = "member_application"
+	ExpiryDocGroupInvitation   = "group_invitation"
+	ExpiryDocFamilySignIn      = "family_sign_in"
+)
+
+// 每次每类最多处理的过期文书数，其余留待下次
+const expirySweepBatch = 500
+
+var (
+	ErrExpiryNoticeNotFound = errors.New("过期提醒不存在")
+	ErrExpiryNoticeNotOwner = errors.New("只有文书的发出方才能续期")
+	ErrExpiryNoticeRenewed  = errors.New("这份文书已经续期过了")
+	ErrExpiryDocNotExpired  = errors.New("这份文书没有过期，无需续期")
+	ErrExpiryRenewForbidden = errors.New("你目前没有发出这份文书的权限")
+	ErrExpiryRenewJoined    = errors.New("对方已经加入，无需续期")
+)
+
+// ExpiryNotice 文书过期提醒
+type ExpiryNotice struct {
+	Id        int
+	Uuid      string
+	UserId    int // 收到提醒的茶友
+	DocType   string
+	DocId     int
+	DocUuid   string
+	IsSender  bool // 是发出方，可以续期
+	Content   string
+	CreatedAt time.Time
+	ReadAt    *time.Time
+	RenewedAt *time.Time // 经此提醒续期的时间
+}
+
+// ExpirySummary 一次过期处理的结果
+type ExpirySummary struct {
+	TeamInvitations    int
+	MemberApplications int
+	GroupInvitations   int
+	FamilySignIns      int
+	Notices            int
+}
+
+// expiredDoc 刚标记为过期的文书及双方提醒
+type expiredDoc struct {
+	docType         string
+	id              int
+	uuid            string
+	wasUnread       bool // 接收方从未查看
+	senderId        int
+	receiverId      int
+	senderContent   string
+	receiverContent string
+}
+
+// DocTypeString 文书类别中文
+func (n *ExpiryNotice) DocTypeString() string {
+	switch n.DocType {
+	case ExpiryDocTeamInvitation:
+		return "茶团邀请函"
+	case ExpiryDocMemberApplication:
+		return "加盟申请书"
+	case ExpiryDocGroupInvitation:
+		return "集团邀请函"
+	case ExpiryDocFamilySignIn:
+		return "家庭成员声明书"
+	}
+	return "文书"
+}
+
+// CanRenew 发出方尚未经此提醒续期
+func (n *ExpiryNotice) CanRenew() bool {
+	return n.IsSender && n.RenewedAt == nil
+}
+
+// Link 查看文书的页面，没有合适页面时为空
+func (n *ExpiryNotice) Link() string {
+	switch n.DocType {
+	case ExpiryDocTeamInvitation:
+		if n.IsSender {
+			return "/v1/team_member/invitation/detail?uuid=" + n.DocUuid
+		}
+		return "/v1/team_member/invitation/read?uuid=" + n.DocUuid
+	case ExpiryDocMemberApplication:
+		if n.IsSender {
+			return "/v1/applications/team_member"
+		}
+		return "/v1/team_member/application/detail?uuid=" + n.DocUuid
+	case ExpiryDocGroupInvitation:
+		if !n.IsSender {
+			return "/v1/group/member_invitation?id=" + n.DocUuid
+		}
+	case ExpiryDocFamilySignIn:
+		if !n.IsSender {
+			return "/v1/family_member/sign_in?id=" + n.DocUuid
+		}
+	}
+	return ""
+}
+
+// expiryCutoff 期限为 days 天时的过期分界时间，负数表示不过期
+func expiryCutoff(days int64, now time.Time) (time.Time, bool) {
+	if days < 0 {
+		return time.Time{}, false
+	}
+	return now.AddDate(0, 0, -int(days)), true
+}
+
+// teamReceiverSQL 茶团一方的接收人：现任CEO，CEO空缺时为创建人，与 teamDecisionMakerTx 一致
+func teamReceiverSQL(teamIdExpr string) string {
+	return fmt.Sprintf(`COALESCE(
+	(SELECT tm.user_id FROM team_members tm WHERE tm.team_id = %[1]s AND tm.role = %[2]d AND tm.status = %[3]d ORDER BY tm.created_at DESC LIMIT 1),
+	(SELECT t.founder_id FROM teams t WHERE t.id = %[1]s), 0)`, teamIdExpr, RoleCEO, TeamMemberStatusActive)
+}
+
+// ExpireStaleDocuments 将超过期限仍未答复的文书标记为已过期，并给双方发过期提醒；供后台任务定期调用
+func ExpireStaleDocuments(ctx context.Context, now time.Time) (ExpirySummary, error) {
+	var summary ExpirySummary
+	sweeps := []struct {
+		days  int64
+		sweep func(context.Context, *sql.Tx, time.Time) ([]expiredDoc, error)
+		count *int
+	}{
+		{util.Config.TeamInvitationExpireDays, sweepTeamInvitationsTx, &summary.TeamInvitations},
+		{util.Config.MemberApplicationExpireDays, sweepMemberApplicationsTx, &summary.MemberApplications},
+		{util.Config.GroupInvitationExpireDays, sweepGroupInvitationsTx, &summary.GroupInvitations},
+		{util.Config.FamilySignInExpireDays, sweepFamilySignInsTx, &summary.FamilySignIns},
+	}
+	for _, s := range sweeps {
+		cutoff, ok := expiryCutoff(s.days, now)
+		if !ok {
+			continue
+		}
+		docs, err := expireDocuments(ctx, cutoff, s.sweep)
+		if err != nil {
+			return summary, err
+		}
+		*s.count = len(docs)
+		summary.Notices += notifyExpiredDocuments(docs)
+	}
+	return summary, nil
+}
+
+// expireDocuments 在一个事务中标记过期并记下双方提醒
+func expireDocuments(ctx context.Context, cutoff time.Time, sweep func(context.Context, *sql.Tx, time.Time) ([]expiredDoc, error)) ([]expiredDoc, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	docs, err := sweep(ctx, tx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		if d.senderId > 0 {
+			if err = createExpiryNoticeTx(ctx, tx, d, d.senderId, true, d.senderContent); err != nil {
+				return nil, err
+			}
+		}
+		if d.receiverId > 0 && d.receiverId != d.senderId {
+			if err = createExpiryNoticeTx(ctx, tx, d, d.receiverId, false, d.receiverContent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return docs, nil
+}
+
+func createExpiryNoticeTx(ctx context.Context, tx *sql.Tx, d expiredDoc, userId int, isSender bool, content string) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO expiry_notices (user_id, doc_type, doc_id, doc_uuid, is_sender, content)
+		VALUES ($1, $2, $3, $4, $5, $6)`, userId, d.docType, d.id, d.uuid, isSender, content); err != nil {
+		return fmt.Errorf("记录%s过期提醒失败: %v", d.docType, err)
+	}
+	return nil
+}
+
+// notifyExpiredDocuments 过期提醒计入双方小黑板，返回提醒数；计数失败不影响过期处理
+func notifyExpiredDocuments(docs []expiredDoc) int {
+	count := 0
+	for _, d := range docs {
+		if d.senderId > 0 {
+			count++
+			if err := AddUserNotificationCount(d.senderId); err != nil {
+				util.Warningf("%s %s 过期提醒计数失败: %v", d.docType, d.uuid, err)
+			}
+		}
+		if d.receiverId > 0 && d.receiverId != d.senderId {
+			count++
+			// 茶团、集团邀请函创建时已给接收方记了一条通知，未查看的由过期提醒接着占用
+			if d.wasUnread && (d.docType == ExpiryDocTeamInvitation || d.docType == ExpiryDocGroupInvitation) {
+				continue
+			}
+			if err := AddUserNotificationCount(d.receiverId); err != nil {
+				util.Warningf("%s %s 过期提醒计数失败: %v", d.docType, d.uuid, err)
+			}
+		}
+	}
+	return count
+}
+
+// scanExpiredDocs 读取过期处理返回的行：id, uuid, 原状态, 发出方, 接收方, 两个名称
+func scanExpiredDocs(rows *sql.Rows, docType string, unreadStatus int, build func(d *expiredDoc, name1, name2 string)) ([]expiredDoc, error) {
+	defer rows.Close()
+	var docs []expiredDoc
+	for rows.Next() {
+		d := expiredDoc{docType: docType}
+		var status int
+		var name1, name2 string
+		if err := rows.Scan(&d.id, &d.uuid, &status, &d.senderId, &d.receiverId, &name1, &name2); err != nil {
+			return nil, fmt.Errorf("扫描过期%s失败: %v", docType, err)
+		}
+		d.wasUnread = status == unreadStatus
+		build(&d, name1, name2)
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// sweepTeamInvitationsTx 茶团邀请函：发出方为撰写人，接收方为受邀邮箱对应的茶友
+func sweepTeamInvitationsTx(ctx context.Context, tx *sql.Tx, cutoff time.Time) ([]expiredDoc, error) {
+	rows, err := tx.QueryContext(ctx, `
+		WITH due AS (
+			SELECT id, status FROM invitations
+			WHERE status IN ($1, $2) AND COALESCE(renewed_at, created_at) < $3
+			ORDER BY id LIMIT $5 FOR UPDATE SKIP LOCKED)
+		UPDATE invitations i SET status = $4 FROM due WHERE i.id = due.id
+		RETURNING i.id, i.uuid, due.status, COALESCE(i.author_user_id, 0),
+			COALESCE((SELECT u.id FROM users u WHERE u.email = i.invite_email), 0),
+			COALESCE((SELECT t.name FROM teams t WHERE t.id = i.team_id), ''), COALESCE(i.invite_email, '')`,
+		InvitationStatusPending, InvitationStatusViewed, cutoff, InvitationStatusExpired, expirySweepBatch)
+	if err != nil {
+		return nil, fmt.Errorf("标记过期茶团邀请函失败: %v", err)
+	}
+	return scanExpiredDocs(rows, ExpiryDocTeamInvitation, InvitationStatusPending, func(d *expiredDoc, team, email string) {
+		d.senderContent = fmt.Sprintf("%s 发给 %s 的邀请函已过期，对方未答复。可以续期，重新等待答复。", team, email)
+		d.receiverContent = fmt.Sprintf("%s 发给你的加盟邀请函已过期。", team)
+	})
+}
+
+// sweepMemberApplicationsTx 加盟申请书：发出方为申请人，接收方为茶团CEO（空缺时为创建人）
+func sweepMemberApplicationsTx(ctx context.Context, tx *sql.Tx, cutoff time.Time) ([]expiredDoc, error) {
+	rows, err := tx.QueryContext(ctx, `
+		WITH due AS (
+			SELECT id, status FROM member_applications
+			WHERE status IN ($1, $2) AND COALESCE(renewed_at, created_at) < $3
+			ORDER BY id LIMIT $5 FOR UPDATE SKIP LOCKED)
+		UPDATE member_applications a SET status = $4, updated_at = now() FROM due WHERE a.id = due.id
+		RETURNING a.id, a.uuid, due.status, COALESCE(a.user_id, 0), `+teamReceiverSQL("a.team_id")+`,
+			COALESCE((SELECT t.name FROM teams t WHERE t.id = a.team_id), ''),
+			COALESCE((SELECT u.name FROM users u WHERE u.id = a.user_id), '')`,
+		MemberApplicationStatusPending, MemberApplicationStatusViewed, cutoff, MemberApplicationStatusExpired, expirySweepBatch)
+	if err != nil {
+		return nil, fmt.Errorf("标记过期加盟申请书失败: %v", err)
+	}
+	return scanExpiredDocs(rows, ExpiryDocMemberApplication, MemberApplicationStatusPending, func(d *expiredDoc, team, applicant string) {
+		d.senderContent = fmt.Sprintf("你加盟 %s 的申请书已过期，茶团未处理。可以续期，重新等待处理。", team)
+		d.receiverContent = fmt.Sprintf("%s 加盟 %s 的申请书已过期，未及时处理。", applicant, team)
+	})
+}
+
+// sweepGroupInvitationsTx 集团邀请函：发出方为撰写人，接收方为受邀茶团CEO（空缺时为创建人）
+func sweepGroupInvitationsTx(ctx context.Context, tx *sql.Tx, cutoff time.Time) ([]expiredDoc, error) {
+	rows, err := tx.QueryContext(ctx, `
+		WITH due AS (
+			SELECT id, status FROM group_invitations
+			WHERE status IN ($1, $2) AND COALESCE(renewed_at, created_at) < $3
+			ORDER BY id LIMIT $5 FOR UPDATE SKIP LOCKED)
+		UPDATE group_invitations gi SET status = $4 FROM due WHERE gi.id = due.id
+		RETURNING gi.id, gi.uuid, due.status, COALESCE(gi.author_user_id, 0), `+teamReceiverSQL("gi.team_id")+`,
+			COALESCE((SELECT g.name FROM groups g WHERE g.id = gi.group_id), ''),
+			COALESCE((SELECT t.name FROM teams t WHERE t.id = gi.team_id), '')`,
+		InvitationStatusPending, InvitationStatusViewed, cutoff, InvitationStatusExpired, expirySweepBatch)
+	if err != nil {
+		return nil, fmt.Errorf("标记过期集团邀请函失败: %v", err)
+	}
+	return scanExpiredDocs(rows, ExpiryDocGroupInvitation, InvitationStatusPending, func(d *expiredDoc, group, team string) {
+		d.senderContent = fmt.Sprintf("%s 邀请 %s 加入的邀请函已过期，对方未答复。可以续期，重新等待答复。", group, team)
+		d.receiverContent = fmt.Sprintf("%s 邀请 %s 加入的邀请函已过期。", group, team)
+	})
+}
+
+// sweepFamilySignInsTx 家庭成员声明书：发出方为撰写人，接收方为被声明的茶友
+func sweepFamilySignInsTx(ctx context.Context, tx *sql.Tx, cutoff time.Time) ([]expiredDoc, error) {
+	rows, err := tx.QueryContext(ctx, `
+		WITH due AS (
+			SELECT id, status FROM family_member_sign_ins
+			WHERE status IN ($1, $2) AND COALESCE(renewed_at, created_at) < $3
+			ORDER BY id LIMIT $5 FOR UPDATE SKIP LOCKED)
+		UPDATE family_member_sign_ins s SET status = $4, updated_at = now() FROM due WHERE s.id = due.id
+		RETURNING s.id, s.uuid, due.status, COALESCE(s.author_user_id, 0), COALESCE(s.user_id, 0),
+			COALESCE((SELECT f.name FROM families f WHERE f.id = s.family_id), ''),
+			COALESCE((SELECT u.name FROM users u WHERE u.id = s.user_id), '')`,
+		SignInStatusUnread, SignInStatusRead, cutoff, SignInStatusExpired, expirySweepBatch)
+	if err != nil {
+		return nil, fmt.Errorf("标记过期家庭成员声明书失败: %v", err)
+	}
+	return scanExpiredDocs(rows, ExpiryDocFamilySignIn, SignInStatusUnread, func(d *expiredDoc, family, member string) {
+		d.senderContent = fmt.Sprintf("声明 %s 加入 %s 的声明书已过期，对方未确认。可以续期，重新等待确认。", member, family)
+		d.receiverContent = fmt.Sprintf("%s 声明你为家庭成员的声明书已过期。", family)
+	})
+}
+
+// RenewExpiredDocument 发出方经过期提醒续期：文书回到待处理状态，期限重新起算
+func RenewExpiredDocument(ctx context.Context, noticeUuid string, userId int) (ExpiryNotice, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ExpiryNotice{}, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var n ExpiryNotice
+	err = tx.QueryRowContext(ctx, `SELECT `+expiryNoticeColumns+` FROM expiry_notices WHERE uuid = $1 FOR UPDATE`, noticeUuid).
+		Scan(expiryNoticeFields(&n)...)
+	if err == sql.ErrNoRows {
+		return n, ErrExpiryNoticeNotFound
+	}
+	if err != nil {
+		return n, fmt.Errorf("查询过期提醒失败: %v", err)
+	}
+	if n.UserId != userId || !n.IsSender {
+		return n, ErrExpiryNoticeNotOwner
+	}
+	if n.RenewedAt != nil {
+		return n, ErrExpiryNoticeRenewed
+	}
+
+	var receiverId int
+	switch n.DocType {
+	case ExpiryDocTeamInvitation:
+		receiverId, err = renewTeamInvitationTx(ctx, tx, n.DocId, userId)
+	case ExpiryDocMemberApplication:
+		err = renewMemberApplicationTx(ctx, tx, n.DocId, userId)
+	case ExpiryDocGroupInvitation:
+		receiverId, err = renewGroupInvitationTx(ctx, tx, n.DocId, userId)
+	case ExpiryDocFamilySignIn:
+		err = renewFamilySignInTx(ctx, tx, n.DocId, userId)
+	default:
+		err = fmt.Errorf("未知的文书类别: %s", n.DocType)
+	}
+	if err != nil {
+		return n, err
+	}
+	now := time.Now()
+	if _, err = tx.ExecContext(ctx, `UPDATE expiry_notices SET renewed_at = $2, read_at = COALESCE(read_at, $2) WHERE id = $1`, n.Id, now); err != nil {
+		return n, fmt.Errorf("记录续期失败: %v", err)
+	}
+	wasUnread := n.ReadAt == nil
+	n.RenewedAt = &now
+	if wasUnread {
+		n.ReadAt = &now
+	}
+	if err = tx.Commit(); err != nil {
+		return n, fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	// 续期的提醒视为已读；茶团、集团邀请函如同新发出，给接收方记一条通知
+	if wasUnread {
+		if err = SubtractUserNotificationCount(userId); err != nil {
+			util.Warningf("过期提醒 %s 续期后小黑板计数失败: %v", n.Uuid, err)
+		}
+	}
+	if receiverId > 0 {
+		if err = AddUserNotificationCount(receiverId); err != nil {
+			util.Warningf("过期提醒 %s 续期后通知接收方失败: %v", n.Uuid, err)
+		}
+	}
+	return n, nil
+}
+
+// renewTeamInvitationTx 续期茶团邀请函，须是茶团CEO或创建人，受邀茶友尚未加入；返回受邀茶友id（未注册为0）
+func renewTeamInvitationTx(ctx context.Context, tx *sql.Tx, id, userId int) (int, error) {
+	var teamId, status, inviteeId int
+	err := tx.QueryRowContext(ctx, `
+		SELECT i.team_id, i.status, COALESCE((SELECT u.id FROM users u WHERE u.email = i.invite_email), 0)
+		FROM invitations i WHERE i.id = $1 FOR UPDATE`, id).Scan(&teamId, &status, &inviteeId)
+	if err != nil {
+		return 0, fmt.Errorf("查询茶团邀请函失败: %v", err)
+	}
+	if status != InvitationStatusExpired {
+		return 0, ErrExpiryDocNotExpired
+	}
+	ceoId, err := teamDecisionMakerTx(ctx, tx, teamId)
+	if err != nil {
+		return 0, err
+	}
+	var founderId int
+	if err = tx.QueryRowContext(ctx, `SELECT founder_id FROM teams WHERE id = $1`, teamId).Scan(&founderId); err != nil {
+		return 0, fmt.Errorf("查询茶团失败: %v", err)
+	}
+	if userId != ceoId && userId != founderId {
+		return 0, ErrExpiryRenewForbidden
+	}
+	if inviteeId > 0 {
+		if joined, err := existsTx(ctx, tx, `SELECT EXISTS (SELECT 1 FROM team_members WHERE team_id = $1 AND user_id = $2)`, teamId, inviteeId); err != nil || joined {
+			return 0, joinedOr(err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE invitations SET status = $2, renewed_at = now() WHERE id = $1`, id, InvitationStatusPending)
+	if err != nil {
+		return 0, fmt.Errorf("续期茶团邀请函失败: %v", err)
+	}
+	return inviteeId, nil
+}
+
+// renewMemberApplicationTx 续期加盟申请书，须是申请人本人且尚未加入
+func renewMemberApplicationTx(ctx context.Context, tx *sql.Tx, id, userId int) error {
+	var teamId, applicantId, status int
+	err := tx.QueryRowContext(ctx, `SELECT team_id, user_id, status FROM member_applications WHERE id = $1 FOR UPDATE`, id).
+		Scan(&teamId, &applicantId, &status)
+	if err != nil {
+		return fmt.Errorf("查询加盟申请书失败: %v", err)
+	}
+	if status != MemberApplicationStatusExpired {
+		return ErrExpiryDocNotExpired
+	}
+	if applicantId != userId {
+		return ErrExpiryRenewForbidden
+	}
+	if joined, err := existsTx(ctx, tx, `SELECT EXISTS (SELECT 1 FROM team_members WHERE team_id = $1 AND user_id = $2)`, teamId, userId); err != nil || joined {
+		return joinedOr(err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE member_applications SET status = $2, renewed_at = now(), updated_at = now() WHERE id = $1`,
+		id, MemberApplicationStatusPending); err != nil {
+		return fmt.Errorf("续期加盟申请书失败: %v", err)
+	}
+	return nil
+}
+
+// renewGroupInvitationTx 续期集团邀请函，须有集团管理权限，受邀茶团尚未加入；返回受邀茶团CEO（空缺时为创建人）
+func renewGroupInvitationTx(ctx context.Context, tx *sql.Tx, id, userId int) (int, error) {
+	var groupId, teamId, status int
+	err := tx.QueryRowContext(ctx, `SELECT group_id, team_id, status FROM group_invitations WHERE id = $1 FOR UPDATE`, id).
+		Scan(&groupId, &teamId, &status)
+	if err != nil {
+		return 0, fmt.Errorf("查询集团邀请函失败: %v", err)
+	}
+	if status != InvitationStatusExpired {
+		return 0, ErrExpiryDocNotExpired
+	}
+	group := Group{Id: groupId}
+	if err = group.Get(); err != nil {
+		return 0, fmt.Errorf("查询集团失败: %v", err)
+	}
+	canManage, err := group.CanManage(userId)
+	if err != nil {
+		return 0, fmt.Errorf("检查集团管理权限失败: %v", err)
+	}
+	if !canManage {
+		return 0, ErrExpiryRenewForbidden
+	}
+	if joined, err := existsTx(ctx, tx, `SELECT EXISTS (SELECT 1 FROM group_members WHERE group_id = $1 AND team_id = $2 AND deleted_at IS NULL)`, groupId, teamId); err != nil || joined {
+		return 0, joinedOr(err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE group_invitations SET status = $2, renewed_at = now() WHERE id = $1`, id, InvitationStatusPending); err != nil {
+		return 0, fmt.Errorf("续期集团邀请函失败: %v", err)
+	}
+	return teamDecisionMakerTx(ctx, tx, teamId)
+}
+
+// renewFamilySignInTx 续期家庭成员声明书，须是撰写人本人，被声明的茶友尚未成为家庭成员
+func renewFamilySignInTx(ctx context.Context, tx *sql.Tx, id, userId int) error {
+	var familyId, memberId, authorId, status int
+	err := tx.QueryRowContext(ctx, `SELECT family_id, user_id, author_user_id, status FROM family_member_sign_ins WHERE id = $1 FOR UPDATE`, id).
+		Scan(&familyId, &memberId, &authorId, &status)
+	if err != nil {
+		return fmt.Errorf("查询家庭成员声明书失败: %v", err)
+	}
+	if status != SignInStatusExpired {
+		return ErrExpiryDocNotExpired
+	}
+	if authorId != userId {
+		return ErrExpiryRenewForbidden
+	}
+	family := Family{Id: familyId}
+	isMember, err := family.IsMember(memberId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("检查家庭成员失败: %v", err)
+	}
+	if isMember {
+		return ErrExpiryRenewJoined
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE family_member_sign_ins SET status = $2, renewed_at = now(), updated_at = now() WHERE id = $1`,
+		id, SignInStatusUnread); err != nil {
+		return fmt.Errorf("续期家庭成员声明书失败: %v", err)
+	}
+	return nil
+}
+
+func existsTx(ctx context.Context, tx *sql.Tx, query string, args ...any) (bool, error) {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&exists); err != nil {
+		return false, fmt.Errorf("检查是否已经加入失败: %v", err)
+	}
+	return exists, nil
+}
+
+// joinedOr 查询出错时返回错误，否则表示对方已经加入
+func joinedOr(err error) error {
+	if err != nil {
+		return err
+	}
+	return ErrExpiryRenewJoined
+}
+
+const expiryNoticeColumns = `id, uuid, user_id, doc_type, doc_id, doc_uuid, is_sender, content, created_at, read_at, renewed_at`
+
+func expiryNoticeFields(n *ExpiryNotice) []any {
+	return []any{&n.Id, &n.Uuid, &n.UserId, &n.DocType, &n.DocId, &n.DocUuid, &n.IsSender, &n.Content, &n.CreatedAt, &n.ReadAt, &n.RenewedAt}
+}
+
+// ExpiryNoticesByUser 茶友收到的过期提醒，最新的在前
+func ExpiryNoticesByUser(ctx context.Context, userId, limit int) ([]ExpiryNotice, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+expiryNoticeColumns+` FROM expiry_notices WHERE user_id = $1 ORDER BY id DESC LIMIT $2`, userId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询过期提醒失败: %v", err)
+	}
+	defer rows.Close()
+	var notices []ExpiryNotice
+	for rows.Next() {
+		var n ExpiryNotice
+		if err = rows.Scan(expiryNoticeFields(&n)...); err != nil {
+			return nil, fmt.Errorf("扫描过期提醒失败: %v", err)
+		}
+		notices = append(notices, n)
+	}
+	return notices, rows.Err()
+}
+
+// MarkExpiryNoticesRead 将茶友的未读过期提醒全部标记为已读，返回标记的条数
+func MarkExpiryNoticesRead(ctx context.Context, userId int) (int, error) {
+	result, err := DB.ExecContext(ctx, `UPDATE expiry_notices SET read_at = $2 WHERE user_id = $1 AND read_at IS NULL`, userId, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("标记过期提醒已读失败: %v", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// UnreadExpiryNoticesCount 茶友未读的过期提醒数量
+func (user *User) UnreadExpiryNoticesCount() (count int) {
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM expiry_notices WHERE user_id = $1 AND read_at IS NULL`, user.Id).Scan(&count); err != nil {
+		util.Warningf("查询茶友 %d 未读过期提醒失败: %v", user.Id, err)
+		return 0
+	}
+	return count
+}
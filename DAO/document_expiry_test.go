@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiryCutoff(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		days int64
+		want time.Time
+		ok   bool
+	}{
+		{14, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), true},
+		{30, time.Date(2026, 2, 13, 10, 0, 0, 0, time.UTC), true},
+		{0, now, true},           // 默认值在读取配置时补上，这里视为立即过期
+		{-1, time.Time{}, false}, // 负数不过期
+	}
+	for _, tt := range tests {
+		got, ok := expiryCutoff(tt.days, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("expiryCutoff(%d) = %v/%v, want %v/%v", tt.days, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExpiryNoticeRenewAndLink(t *testing.T) {
+	renewed := time.Now()
+	tests := []struct {
+		notice   ExpiryNotice
+		canRenew bool
+		link     string
+	}{
+		{ExpiryNotice{DocType: ExpiryDocTeamInvitation, DocUuid: "a", IsSender: true}, true, "/v1/team_member/invitation/detail?uuid=a"},
+		{ExpiryNotice{DocType: ExpiryDocTeamInvitation, DocUuid: "a"}, false, "/v1/team_member/invitation/read?uuid=a"},
+		{ExpiryNotice{DocType: ExpiryDocMemberApplication, DocUuid: "b", IsSender: true, RenewedAt: &renewed}, false, "/v1/applications/team_member"},
+		{ExpiryNotice{DocType: ExpiryDocMemberApplication, DocUuid: "b"}, false, "/v1/team_member/application/detail?uuid=b"},
+		{ExpiryNotice{DocType: ExpiryDocGroupInvitation, DocUuid: "c", IsSender: true}, true, ""},
+		{ExpiryNotice{DocType: ExpiryDocGroupInvitation, DocUuid: "c"}, false, "/v1/group/member_invitation?id=c"},
+		{ExpiryNotice{DocType: ExpiryDocFamilySignIn, DocUuid: "d", IsSender: true}, true, ""},
+		{ExpiryNotice{DocType: ExpiryDocFamilySignIn, DocUuid: "d"}, false, "/v1/family_member/sign_in?id=d"},
+	}
+	for _, tt := range tests {
+		if got := tt.notice.CanRenew(); got != tt.canRenew {
+			t.Errorf("%s sender=%v CanRenew() = %v, want %v", tt.notice.DocType, tt.notice.IsSender, got, tt.canRenew)
+		}
+		if got := tt.notice.Link(); got != tt.link {
+			t.Errorf("%s sender=%v Link() = %q, want %q", tt.notice.DocType, tt.notice.IsSender, got, tt.link)
+		}
+		if s := tt.notice.DocTypeString(); s == "文书" || !strings.HasSuffix(s, "书") && !strings.HasSuffix(s, "函") {
+			t.Errorf("%s DocTypeString() = %q", tt.notice.DocType, s)
+		}
+	}
+}
+
+func TestTeamReceiverSQL(t *testing.T) {
+	q := teamReceiverSQL("gi.team_id")
+	if strings.Count(q, "gi.team_id") != 2 || strings.Contains(q, "%!") {
+		t.Errorf("teamReceiverSQL = %s", q)
+	}
+}
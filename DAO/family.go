@@ -113,6 +113,7 @@ const (
 	SignInStatusRead      = 1 // 已读
 	SignInStatusConfirmed = 2 // 已确认
 	SignInStatusDenied    = 3 // 已否认
+	SignInStatusExpired   = 4 // 已过期
 )
 
 // FamilyMember.GetRole()
@@ -383,6 +384,8 @@ func (fms *FamilyMemberSignIn) GetStatus() string {
 		return "已确认"
 	case SignInStatusDenied:
 		return "已否认"
+	case SignInStatusExpired:
+		return "已过期"
 	default:
 		return "未知"
 	}
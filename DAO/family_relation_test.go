@@ -7,6 +7,7 @@ import (
 
 // TestFamilyRelationAvoidance 测试利益回避机制
 func TestFamilyRelationAvoidance(t *testing.T) {
+	requireDB(t)
 	// 场景：张三和李四是夫妻，王五是张三的父亲
 	// 在投票时，张三、李四、王五应该互相回避
 
@@ -131,6 +132,7 @@ func TestFamilyRelationAvoidance(t *testing.T) {
 
 // TestSameGenderFamily 测试同性家庭的回避机制
 func TestSameGenderFamily(t *testing.T) {
+	requireDB(t)
 	// 场景：Alice和Bob是同性伴侣，通过精子库有一个孩子Charlie
 	// 在投票时，Alice、Bob、Charlie应该互相回避
 
@@ -7,6 +7,7 @@ import (
 
 // TestFamilySoftDelete 测试家庭软删除功能
 func TestFamilySoftDelete(t *testing.T) {
+	requireDB(t)
 	// 注意：这个测试需要数据库连接，实际运行时需要确保数据库配置正确
 
 	// 创建测试家庭
@@ -67,6 +68,7 @@ func TestFamilySoftDelete(t *testing.T) {
 
 // TestFamilyQueryWithSoftDelete 测试带软删除的查询功能
 func TestFamilyQueryWithSoftDelete(t *testing.T) {
+	requireDB(t)
 	// 创建测试家庭
 	family := Family{
 		AuthorId:     1,
@@ -0,0 +1,186 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+幂等键（idempotency_keys）：防止网络重试、重复点击造成重复转账。
+1、客户端为同一次提交生成一个键（Idempotency-Key），重试时沿用；
+2、首次请求登记键及请求参数摘要，状态 processing，处理完成后保存响应，状态 completed；
+3、保留期内同一茶友、同一接口、同一键：参数相同则返回首次响应，参数不同则拒绝（ErrIdempotencyKeyConflict），
+   首次请求仍在处理中也拒绝（ErrIdempotencyKeyInProgress）；
+4、处理失败（服务端错误）时删除登记，允许用同一键重试；过期记录由定时任务清理。
+*/
+
+// 幂等键状态
+const (
+	IdempotencyStatus_Processing = "processing"
+	IdempotencyStatus_Completed  = "completed"
+)
+
+// IdempotencyKeyMaxLength 幂等键最大长度，与数据库约束一致
+const IdempotencyKeyMaxLength = 128
+
+var (
+	ErrIdempotencyKeyInvalid    = errors.New("幂等键无效")
+	ErrIdempotencyKeyConflict   = errors.New("幂等键已用于参数不同的请求")
+	ErrIdempotencyKeyInProgress = errors.New("相同幂等键的请求正在处理中")
+)
+
+// IdempotencyRecord 一个幂等键的登记及首次响应
+type IdempotencyRecord struct {
+	Id                  int
+	UserId              int
+	Endpoint            string
+	IdempotencyKey      string
+	RequestHash         string
+	Status              string
+	ResponseStatus      int
+	ResponseContentType string
+	ResponseBody        []byte
+	CreatedAt           time.Time
+	CompletedAt         *time.Time
+	ExpiresAt           time.Time
+}
+
+// Completed 是否已保存首次响应
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status == IdempotencyStatus_Completed
+}
+
+// ValidIdempotencyKey 幂等键须为1到128个可见ASCII字符
+func ValidIdempotencyKey(key string) bool {
+	if key == "" || len(key) > IdempotencyKeyMaxLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// IdempotencyRequestHash 请求参数摘要：方法、路径、按键排序的查询参数及请求体
+func IdempotencyRequestHash(method, path string, query map[string][]string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(strings.ToUpper(method) + " " + path + "\n"))
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range query[k] {
+			fmt.Fprintf(h, "%q=%q\n", k, v)
+		}
+	}
+	h.Write([]byte("\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// BeginIdempotentRequest 登记幂等键。首次请求返回 (nil, nil)，调用方处理后须 Complete 或 Abandon；
+// 键已完成且参数相同时返回首次响应记录；参数不同或仍在处理中返回相应错误
+func BeginIdempotentRequest(userId int, endpoint, key, requestHash string, retention time.Duration) (*IdempotencyRecord, error) {
+	if !ValidIdempotencyKey(key) {
+		return nil, ErrIdempotencyKeyInvalid
+	}
+	// 最多两轮：第一轮遇到过期记录时删除后重新登记
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := DB.Exec(`
+			INSERT INTO idempotency_keys (user_id, endpoint, idempotency_key, request_hash, status, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (user_id, endpoint, idempotency_key) DO NOTHING`,
+			userId, endpoint, key, requestHash, IdempotencyStatus_Processing, time.Now().Add(retention))
+		if err != nil {
+			return nil, fmt.Errorf("登记幂等键失败: %v", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 1 {
+			return nil, nil
+		}
+
+		rec, err := getIdempotencyRecord(userId, endpoint, key)
+		if err == sql.ErrNoRows {
+			// 刚被放弃或清理，重新登记
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !rec.ExpiresAt.After(time.Now()) {
+			if _, err = DB.Exec(`DELETE FROM idempotency_keys WHERE id = $1`, rec.Id); err != nil {
+				return nil, fmt.Errorf("删除过期幂等键失败: %v", err)
+			}
+			continue
+		}
+		if rec.RequestHash != requestHash {
+			return nil, ErrIdempotencyKeyConflict
+		}
+		if !rec.Completed() {
+			return nil, ErrIdempotencyKeyInProgress
+		}
+		return &rec, nil
+	}
+	return nil, ErrIdempotencyKeyInProgress
+}
+
+func getIdempotencyRecord(userId int, endpoint, key string) (IdempotencyRecord, error) {
+	var rec IdempotencyRecord
+	err := DB.QueryRow(`
+		SELECT id, user_id, endpoint, idempotency_key, request_hash, status, response_status,
+			response_content_type, response_body, created_at, completed_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND endpoint = $2 AND idempotency_key = $3`, userId, endpoint, key).
+		Scan(&rec.Id, &rec.UserId, &rec.Endpoint, &rec.IdempotencyKey, &rec.RequestHash, &rec.Status, &rec.ResponseStatus,
+			&rec.ResponseContentType, &rec.ResponseBody, &rec.CreatedAt, &rec.CompletedAt, &rec.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return rec, err
+	}
+	if err != nil {
+		return rec, fmt.Errorf("读取幂等键失败: %v", err)
+	}
+	return rec, nil
+}
+
+// CompleteIdempotentRequest 保存首次响应，此后同一键的重放直接返回该响应
+func CompleteIdempotentRequest(userId int, endpoint, key string, status int, contentType string, body []byte) error {
+	_, err := DB.Exec(`
+		UPDATE idempotency_keys
+		SET status = $4, response_status = $5, response_content_type = $6, response_body = $7, completed_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND endpoint = $2 AND idempotency_key = $3 AND status = $8`,
+		userId, endpoint, key, IdempotencyStatus_Completed, status, contentType, body, IdempotencyStatus_Processing)
+	if err != nil {
+		return fmt.Errorf("保存幂等键响应失败: %v", err)
+	}
+	return nil
+}
+
+// AbandonIdempotentRequest 删除处理中的登记（处理失败时），允许客户端用同一键重试
+func AbandonIdempotentRequest(userId int, endpoint, key string) error {
+	_, err := DB.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND endpoint = $2 AND idempotency_key = $3 AND status = $4`,
+		userId, endpoint, key, IdempotencyStatus_Processing)
+	if err != nil {
+		return fmt.Errorf("删除幂等键失败: %v", err)
+	}
+	return nil
+}
+
+// PurgeExpiredIdempotencyKeys 清理过期的幂等键，返回删除条数
+func PurgeExpiredIdempotencyKeys() (int64, error) {
+	result, err := DB.Exec("DELETE FROM idempotency_keys WHERE expires_at <= $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
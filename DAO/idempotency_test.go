@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidIdempotencyKey(t *testing.T) {
+	cases := []struct {
+		key  string
+		want bool
+	}{
+		{"0f8b5c2e-4c1d-4a8e-9a53-3f3b2a1c9d7e", true},
+		{"a", true},
+		{strings.Repeat("k", IdempotencyKeyMaxLength), true},
+		{"", false},
+		{strings.Repeat("k", IdempotencyKeyMaxLength+1), false},
+		{"has space", false},
+		{"中文键", false},
+	}
+	for _, c := range cases {
+		if got := ValidIdempotencyKey(c.key); got != c.want {
+			t.Errorf("ValidIdempotencyKey(%q) = %t, want %t", c.key, got, c.want)
+		}
+	}
+}
+
+func TestIdempotencyRequestHash(t *testing.T) {
+	body := []byte(`{"to_user_id":2,"amount_milligrams":10}`)
+	base := IdempotencyRequestHash("POST", "/v1/tea/team/transfer/team_to_user/new", map[string][]string{"team_id": {"3"}, "a": {"1"}}, body)
+	if len(base) != 64 {
+		t.Fatalf("摘要长度 = %d", len(base))
+	}
+	if got := IdempotencyRequestHash("post", "/v1/tea/team/transfer/team_to_user/new", map[string][]string{"a": {"1"}, "team_id": {"3"}}, body); got != base {
+		t.Fatal("方法大小写及查询参数顺序不应影响摘要")
+	}
+	if IdempotencyRequestHash("POST", "/v1/tea/team/transfer/team_to_user/new", map[string][]string{"team_id": {"4"}, "a": {"1"}}, body) == base {
+		t.Fatal("查询参数不同时摘要应不同")
+	}
+	if IdempotencyRequestHash("POST", "/v1/tea/team/transfer/team_to_user/new", map[string][]string{"team_id": {"3"}, "a": {"1"}}, []byte(`{"to_user_id":2,"amount_milligrams":100}`)) == base {
+		t.Fatal("请求体不同时摘要应不同")
+	}
+	if IdempotencyRequestHash("POST", "/v1/tea/team/transfer/team_to_team/new", map[string][]string{"team_id": {"3"}, "a": {"1"}}, body) == base {
+		t.Fatal("接口不同时摘要应不同")
+	}
+}
@@ -0,0 +1,635 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	util "teachat/Util"
+)
+
+/*
+后台任务：清理过期会话、处理超时转账、星茶余额对账等维护任务以名称注册，由调度器按计划执行。
+1、计划：注册时给出默认计划（见 job_schedule.go），配置 JobSchedules 可按名称覆盖，off 表示停用；
+2、单实例执行：每次执行前在专用数据库连接上获取以任务名为键的 Postgres 咨询锁（pg_try_advisory_lock），
+   多个实例同时到期时只有取得锁的实例执行；取得锁后以 jobs 表中的 next_run_at 为准，
+   已由其他实例执行过的周期不再执行；
+3、同一任务不重叠执行：上一次未结束时不会再次启动；
+4、停止调度器时取消任务上下文，并等待执行中的任务结束（超时即放弃等待）；
+5、最近一次执行状态记入 jobs 表，每次执行记入 job_runs 表（保留30天），管理页面据此显示任务健康状况；
+   管理页面可立即执行任务（停用的任务也可以），手动执行不改变下次计划执行时间。
+*/
+
+// 后台任务执行状态
+const (
+	JobStatus_Running   = "running"
+	JobStatus_Succeeded = "succeeded"
+	JobStatus_Failed    = "failed"
+)
+
+// 后台任务触发方式
+const (
+	JobTrigger_Schedule = "schedule"
+	JobTrigger_Manual   = "manual"
+)
+
+const (
+	jobPollInterval   = 15 * time.Second    // 调度器检查到期任务的间隔
+	jobRetryDelay     = time.Minute         // 读写任务状态失败时推迟重试
+	jobRunRetention   = 30 * 24 * time.Hour // 执行记录保留时间
+	jobMessageMaxRune = 1000                // 执行结果说明最多保存的字数
+)
+
+var (
+	ErrJobNotFound         = errors.New("后台任务不存在")
+	ErrJobRunning          = errors.New("后台任务正在执行")
+	ErrJobLocked           = errors.New("后台任务正在其他实例执行")
+	ErrJobSchedulerStopped = errors.New("后台任务调度器已停止")
+)
+
+var validJobName = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+// JobFunc 任务函数，返回执行结果说明（例如“已清理过期会话 3 条”）；ctx 在调度器停止时取消
+type JobFunc func(ctx context.Context) (string, error)
+
+// Job 后台任务定义
+type Job struct {
+	Name        string // 小写字母、数字、下划线
+	Description string
+	Schedule    string // 默认计划，off 表示默认停用
+	Run         JobFunc
+}
+
+// JobState 任务计划及最近一次执行状态（jobs 表）
+type JobState struct {
+	Name           string
+	Description    string
+	Schedule       string
+	Enabled        bool
+	NextRunAt      *time.Time
+	LastStartedAt  *time.Time
+	LastFinishedAt *time.Time
+	LastStatus     string
+	LastTrigger    string
+	LastMessage    string
+	LastDurationMs int64
+	LastInstance   string
+	RunCount       int
+	FailureCount   int // 连续失败次数
+	UpdatedAt      time.Time
+}
+
+// JobInfo 管理页面显示的任务
+type JobInfo struct {
+	JobState
+	Running bool // 本实例正在执行
+}
+
+// JobRun 一次执行记录（job_runs 表）
+type JobRun struct {
+	Id         int
+	JobName    string
+	Trigger    string
+	Status     string
+	Message    string
+	Instance   string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	DurationMs int64
+}
+
+// StatusString 状态中文
+func (s JobState) StatusString() string {
+	return jobStatusString(s.LastStatus)
+}
+
+// Overdue 计划执行时间已过去较久仍未执行，说明没有实例在调度该任务
+func (s JobState) Overdue(now time.Time) bool {
+	return s.Enabled && s.NextRunAt != nil && now.Sub(*s.NextRunAt) > 5*time.Minute
+}
+
+// StatusString 状态中文
+func (r JobRun) StatusString() string {
+	return jobStatusString(r.Status)
+}
+
+// TriggerString 触发方式中文
+func (r JobRun) TriggerString() string {
+	if r.Trigger == JobTrigger_Manual {
+		return "手动"
+	}
+	return "计划"
+}
+
+func jobStatusString(status string) string {
+	switch status {
+	case JobStatus_Running:
+		return "执行中"
+	case JobStatus_Succeeded:
+		return "成功"
+	case JobStatus_Failed:
+		return "失败"
+	}
+	return "未执行"
+}
+
+// scheduledJob 调度器内的任务及本实例的调度状态
+type scheduledJob struct {
+	Job
+	spec     string      // 生效计划
+	schedule JobSchedule // nil 表示停用
+	next     time.Time
+	running  bool
+}
+
+// JobScheduler 后台任务调度器
+type JobScheduler struct {
+	mu       sync.Mutex
+	jobs     []*scheduledJob
+	byName   map[string]*scheduledJob
+	instance string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	started  bool
+	stopped  bool
+}
+
+// Jobs 本进程的后台任务调度器
+var Jobs = NewJobScheduler()
+
+// NewJobScheduler 新建调度器，实例名为 主机名:进程号，记入执行记录
+func NewJobScheduler() *JobScheduler {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &JobScheduler{
+		byName:   map[string]*scheduledJob{},
+		instance: fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+}
+
+// Register 注册任务，须在 Start 之前
+func (s *JobScheduler) Register(job Job) error {
+	if !validJobName.MatchString(job.Name) {
+		return fmt.Errorf("后台任务名称无效: %q", job.Name)
+	}
+	if job.Run == nil {
+		return fmt.Errorf("后台任务 %s 缺少任务函数", job.Name)
+	}
+	j := &scheduledJob{Job: job}
+	if err := j.setSchedule(job.Schedule); err != nil {
+		return fmt.Errorf("后台任务 %s: %w", job.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("后台任务调度器已启动，不能再注册 %s", job.Name)
+	}
+	if _, ok := s.byName[job.Name]; ok {
+		return fmt.Errorf("后台任务 %s 重复注册", job.Name)
+	}
+	s.jobs = append(s.jobs, j)
+	s.byName[job.Name] = j
+	return nil
+}
+
+// Configure 按配置 JobSchedules 覆盖已注册任务的计划，须在 Start 之前
+func (s *JobScheduler) Configure(overrides string) error {
+	specs, err := ParseJobScheduleOverrides(overrides)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, spec := range specs {
+		j, ok := s.byName[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+		}
+		if err := j.setSchedule(spec); err != nil {
+			return fmt.Errorf("后台任务 %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ParseJobScheduleOverrides 解析 名称=计划;名称=计划 形式的计划覆盖
+func ParseJobScheduleOverrides(overrides string) (map[string]string, error) {
+	specs := map[string]string{}
+	for _, item := range strings.Split(overrides, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+		if !ok || name == "" || spec == "" {
+			return nil, fmt.Errorf("后台任务计划格式应为 名称=计划: %s", item)
+		}
+		if _, dup := specs[name]; dup {
+			return nil, fmt.Errorf("后台任务 %s 的计划重复配置", name)
+		}
+		specs[name] = spec
+	}
+	return specs, nil
+}
+
+func (j *scheduledJob) setSchedule(spec string) error {
+	spec = strings.TrimSpace(spec)
+	if spec == JobScheduleOff {
+		j.spec, j.schedule = JobScheduleOff, nil
+		return nil
+	}
+	schedule, err := ParseJobSchedule(spec)
+	if err != nil {
+		return err
+	}
+	j.spec, j.schedule = schedule.String(), schedule
+	return nil
+}
+
+// Start 登记任务计划并开始调度，ctx 取消或调用 Stop 时停止
+func (s *JobScheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.New("后台任务调度器已启动")
+	}
+	now := time.Now()
+	for _, j := range s.jobs {
+		next, err := syncJobState(ctx, j, now)
+		if err != nil {
+			return err
+		}
+		j.next = next
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.started = true
+	s.wg.Add(1)
+	go s.loop()
+	util.Infof("后台任务调度器已启动（实例 %s），任务 %d 个", s.instance, len(s.jobs))
+	return nil
+}
+
+// syncJobState 写入任务说明及计划；计划变更或尚无下次执行时间时按新计划重排，否则沿用表中的下次执行时间
+func syncJobState(ctx context.Context, j *scheduledJob, now time.Time) (time.Time, error) {
+	var next sql.NullTime
+	if j.schedule != nil {
+		if t := j.schedule.Next(now); !t.IsZero() {
+			next = sql.NullTime{Time: t, Valid: true}
+		}
+	}
+	var stored sql.NullTime
+	err := DB.QueryRowContext(ctx, `
+		INSERT INTO jobs (name, description, schedule, enabled, next_run_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description,
+			schedule = EXCLUDED.schedule,
+			enabled = EXCLUDED.enabled,
+			next_run_at = CASE
+				WHEN NOT EXCLUDED.enabled THEN NULL
+				WHEN jobs.schedule <> EXCLUDED.schedule OR jobs.next_run_at IS NULL THEN EXCLUDED.next_run_at
+				ELSE jobs.next_run_at END,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING next_run_at`,
+		j.Name, j.Description, j.spec, j.schedule != nil, next).Scan(&stored)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("登记后台任务 %s 失败: %v", j.Name, err)
+	}
+	return stored.Time, nil
+}
+
+// Stop 停止调度并取消执行中的任务，等待其结束直到 ctx 超时
+func (s *JobScheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started || s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	s.cancel()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		util.Info("后台任务调度器已停止")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待后台任务结束超时: %w", ctx.Err())
+	}
+}
+
+func (s *JobScheduler) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		s.dispatchDue(time.Now())
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchDue 启动已到期且未在执行的任务
+func (s *JobScheduler) dispatchDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	for _, j := range s.jobs {
+		if j.schedule == nil || j.running || j.next.IsZero() || now.Before(j.next) {
+			continue
+		}
+		j.running = true
+		s.wg.Add(1)
+		go s.runScheduled(j)
+	}
+}
+
+// runScheduled 按计划执行一次，取得锁后以 jobs 表中的下次执行时间为准
+func (s *JobScheduler) runScheduled(j *scheduledJob) {
+	defer s.wg.Done()
+	next := s.tryRunScheduled(j)
+	s.mu.Lock()
+	j.running = false
+	if !next.IsZero() {
+		j.next = next
+	}
+	s.mu.Unlock()
+}
+
+func (s *JobScheduler) tryRunScheduled(j *scheduledJob) time.Time {
+	conn, err := acquireJobLock(s.ctx, j.Name)
+	if errors.Is(err, ErrJobLocked) {
+		// 其他实例正在执行，下一轮检查时再读取其写入的下次执行时间
+		return time.Time{}
+	}
+	if err != nil {
+		if s.ctx.Err() == nil {
+			util.Errorf("后台任务 %s 获取执行锁失败: %v", j.Name, err)
+		}
+		return time.Now().Add(jobRetryDelay)
+	}
+	defer releaseJobLock(conn, j.Name)
+
+	now := time.Now()
+	var stored sql.NullTime
+	if err = conn.QueryRowContext(context.Background(), `SELECT next_run_at FROM jobs WHERE name = $1`, j.Name).Scan(&stored); err != nil {
+		util.Errorf("后台任务 %s 读取下次执行时间失败: %v", j.Name, err)
+		return now.Add(jobRetryDelay)
+	}
+	if stored.Valid && now.Before(stored.Time) {
+		// 本周期已由其他实例执行
+		return stored.Time
+	}
+	next := j.schedule.Next(now)
+	if err = s.execute(conn, j, JobTrigger_Schedule, now, next); err != nil {
+		util.Errorf("后台任务 %s 登记执行失败: %v", j.Name, err)
+		return now.Add(jobRetryDelay)
+	}
+	if next.IsZero() {
+		// 计划不会再到期
+		return now.AddDate(100, 0, 0)
+	}
+	return next
+}
+
+// RunNow 立即在后台执行任务，不改变下次计划执行时间
+func (s *JobScheduler) RunNow(name string) error {
+	s.mu.Lock()
+	j, ok := s.byName[name]
+	switch {
+	case !ok:
+		s.mu.Unlock()
+		return ErrJobNotFound
+	case !s.started || s.stopped:
+		s.mu.Unlock()
+		return ErrJobSchedulerStopped
+	case j.running:
+		s.mu.Unlock()
+		return ErrJobRunning
+	}
+	j.running = true
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	finish := func() {
+		s.mu.Lock()
+		j.running = false
+		s.mu.Unlock()
+		s.wg.Done()
+	}
+	conn, err := acquireJobLock(s.ctx, j.Name)
+	if err != nil {
+		finish()
+		return err
+	}
+	go func() {
+		defer finish()
+		defer releaseJobLock(conn, j.Name)
+		if err := s.execute(conn, j, JobTrigger_Manual, time.Now(), time.Time{}); err != nil {
+			util.Errorf("后台任务 %s 登记执行失败: %v", j.Name, err)
+		}
+	}()
+	return nil
+}
+
+// execute 登记开始、执行任务、登记结果；next 非零时写入下次计划执行时间。
+// 登记使用独立的上下文，调度器停止后仍能记下被取消的结果
+func (s *JobScheduler) execute(conn *sql.Conn, j *scheduledJob, trigger string, start, next time.Time) error {
+	ctx := context.Background()
+	var nextRun sql.NullTime
+	if !next.IsZero() {
+		nextRun = sql.NullTime{Time: next, Valid: true}
+	}
+	var runId int
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE jobs SET last_started_at = $2, last_finished_at = NULL, last_status = $3, last_trigger = $4,
+			last_message = '', last_instance = $5,
+			next_run_at = CASE WHEN $6 THEN $7 ELSE next_run_at END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE name = $1`, j.Name, start, JobStatus_Running, trigger, s.instance, trigger == JobTrigger_Schedule, nextRun)
+	if err == nil {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO job_runs (job_name, trigger, status, instance, started_at)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id`, j.Name, trigger, JobStatus_Running, s.instance, start).Scan(&runId)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	util.Infof("后台任务 %s 开始执行（%s）", j.Name, trigger)
+	message, runErr := s.call(j)
+	finished := time.Now()
+	status := JobStatus_Succeeded
+	if runErr != nil {
+		status = JobStatus_Failed
+		message = runErr.Error()
+		util.Errorf("后台任务 %s 执行失败: %v", j.Name, runErr)
+	} else {
+		util.Infof("后台任务 %s 执行完成，用时 %s %s", j.Name, finished.Sub(start).Round(time.Millisecond), message)
+	}
+	message = truncateRunes(message, jobMessageMaxRune)
+	duration := finished.Sub(start).Milliseconds()
+
+	if _, err = conn.ExecContext(ctx, `
+		UPDATE jobs SET last_finished_at = $2, last_status = $3, last_message = $4, last_duration_ms = $5,
+			run_count = run_count + 1,
+			failure_count = CASE WHEN $3 = 'failed' THEN failure_count + 1 ELSE 0 END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE name = $1`, j.Name, finished, status, message, duration); err != nil {
+		return err
+	}
+	if _, err = conn.ExecContext(ctx, `
+		UPDATE job_runs SET status = $2, message = $3, finished_at = $4, duration_ms = $5 WHERE id = $1`,
+		runId, status, message, finished, duration); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `DELETE FROM job_runs WHERE job_name = $1 AND started_at < $2`, j.Name, finished.Add(-jobRunRetention))
+	return err
+}
+
+// call 执行任务函数，panic 视为失败
+func (s *JobScheduler) call(j *scheduledJob) (message string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			util.Errorf("后台任务 %s panic: %v\n%s", j.Name, p, debug.Stack())
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return j.Run(s.ctx)
+}
+
+// jobLockKey 任务名对应的咨询锁键
+func jobLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("teachat:job:" + name))
+	return int64(h.Sum64())
+}
+
+// acquireJobLock 在专用连接上获取任务咨询锁，未取得时返回 ErrJobLocked；
+// 咨询锁属于会话，须在同一连接上释放
+func acquireJobLock(ctx context.Context, name string) (*sql.Conn, error) {
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, jobLockKey(name)).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, ErrJobLocked
+	}
+	return conn, nil
+}
+
+// releaseJobLock 释放咨询锁并归还连接；释放失败时关闭连接，会话结束后锁随之释放
+func releaseJobLock(conn *sql.Conn, name string) {
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, jobLockKey(name)); err != nil {
+		util.Errorf("后台任务 %s 释放执行锁失败: %v", name, err)
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	conn.Close()
+}
+
+// Overview 已注册任务的计划及最近执行状态，按注册顺序
+func (s *JobScheduler) Overview(ctx context.Context) ([]JobInfo, error) {
+	states := map[string]JobState{}
+	rows, err := DB.QueryContext(ctx, `
+		SELECT name, description, schedule, enabled, next_run_at, last_started_at, last_finished_at,
+			last_status, last_trigger, last_message, last_duration_ms, last_instance, run_count, failure_count, updated_at
+		FROM jobs`)
+	if err != nil {
+		return nil, fmt.Errorf("查询后台任务失败: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var st JobState
+		if err = rows.Scan(&st.Name, &st.Description, &st.Schedule, &st.Enabled, &st.NextRunAt, &st.LastStartedAt, &st.LastFinishedAt,
+			&st.LastStatus, &st.LastTrigger, &st.LastMessage, &st.LastDurationMs, &st.LastInstance, &st.RunCount, &st.FailureCount, &st.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描后台任务失败: %v", err)
+		}
+		states[st.Name] = st
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		st, ok := states[j.Name]
+		if !ok {
+			// 调度器尚未启动（或登记失败）
+			st = JobState{Name: j.Name, Description: j.Description, Schedule: j.spec, Enabled: j.schedule != nil}
+		}
+		infos = append(infos, JobInfo{JobState: st, Running: j.running})
+	}
+	return infos, nil
+}
+
+// RecentJobRuns 最近的执行记录，name 为空时包括全部任务
+func RecentJobRuns(ctx context.Context, name string, limit int) ([]JobRun, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT id, job_name, trigger, status, message, instance, started_at, finished_at, duration_ms
+		FROM job_runs
+		WHERE $1 = '' OR job_name = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2`, name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询后台任务执行记录失败: %v", err)
+	}
+	defer rows.Close()
+	var runs []JobRun
+	for rows.Next() {
+		var run JobRun
+		if err = rows.Scan(&run.Id, &run.JobName, &run.Trigger, &run.Status, &run.Message, &run.Instance,
+			&run.StartedAt, &run.FinishedAt, &run.DurationMs); err != nil {
+			return nil, fmt.Errorf("扫描后台任务执行记录失败: %v", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// truncateRunes 截取前 n 个字
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
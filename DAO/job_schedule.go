@@ -0,0 +1,205 @@
+package dao
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+后台任务计划表达式：
+1、固定间隔：@every 30m、@every 6h（Go 时长格式，最短1分钟），上一次计划执行开始后间隔这么久再执行；
+2、五段 cron 表达式：分 时 日 月 周，按服务器本地时间，例如 0 3 * * * 表示每天3点，0 9-18 * * 1-5 表示工作日9点至18点整点；
+   每段可用 *、数字、范围 a-b，* 或范围后加 /n 表示步长，以逗号分隔多项；周取 0-7（0和7均为周日）；
+   日与周都不是 * 时，两者满足其一即执行（与 cron 相同）；
+   另有简写 @hourly、@daily、@weekly、@monthly；
+3、off 表示停用，只能手动执行。
+*/
+
+// JobScheduleOff 停用任务的计划取值
+const JobScheduleOff = "off"
+
+// JobSchedule 任务计划
+type JobSchedule interface {
+	// Next 晚于 t 的下一次执行时间，没有时返回零值
+	Next(t time.Time) time.Time
+	// String 计划表达式
+	String() string
+}
+
+// ParseJobSchedule 解析计划表达式，off 不是有效计划，由调用方先行判断
+func ParseJobSchedule(spec string) (JobSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "":
+		return nil, fmt.Errorf("计划表达式为空")
+	case "@hourly":
+		return parseCronSchedule("0 * * * *")
+	case "@daily":
+		return parseCronSchedule("0 0 * * *")
+	case "@weekly":
+		return parseCronSchedule("0 0 * * 0")
+	case "@monthly":
+		return parseCronSchedule("0 0 1 * *")
+	}
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("计划间隔无效: %s", rest)
+		}
+		if every < time.Minute {
+			return nil, fmt.Errorf("计划间隔不能短于1分钟: %s", rest)
+		}
+		return everySchedule{every: every}, nil
+	}
+	return parseCronSchedule(spec)
+}
+
+// everySchedule 固定间隔
+type everySchedule struct {
+	every time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.every)
+}
+
+func (s everySchedule) String() string {
+	return "@every " + s.every.String()
+}
+
+// cronSchedule 五段 cron 表达式，各段以位图表示允许的取值
+type cronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// cronFields 各段名称及取值范围
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"分", 0, 59},
+	{"时", 0, 23},
+	{"日", 1, 31},
+	{"月", 1, 12},
+	{"周", 0, 7},
+}
+
+func parseCronSchedule(spec string) (JobSchedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("计划表达式须为 @every 间隔或五段 cron 表达式（分 时 日 月 周）: %s", spec)
+	}
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("计划表达式“%s”段无效: %v", cronFields[i].name, err)
+		}
+		bits[i] = b
+	}
+	// 周日可写作0或7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cronSchedule{
+		spec:          strings.Join(parts, " "),
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: parts[2] != "*",
+		dowRestricted: parts[4] != "*",
+	}, nil
+}
+
+// parseCronField 解析一段，例如 */15、1-5、0,30、8-18/2
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("步长无效: %s", item)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(a)
+			hi, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil || lo > hi {
+				return 0, fmt.Errorf("范围无效: %s", item)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("取值无效: %s", item)
+			}
+			lo = n
+			if hasStep {
+				hi = max
+			} else {
+				hi = n
+			}
+		}
+		if lo < min || hi > max {
+			return 0, fmt.Errorf("取值超出范围 %d-%d: %s", min, max, item)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *cronSchedule) String() string {
+	return s.spec
+}
+
+// dayMatches 日、周的匹配规则与 cron 相同：两者都有限定时满足其一即可
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domOk := s.dom&(1<<uint(t.Day())) != 0
+	dowOk := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domOk || dowOk
+	}
+	return domOk && dowOk
+}
+
+// Next 从下一分钟起逐级查找，月、日、时不符时整段跳过；5年内没有匹配（例如2月30日）返回零值
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,119 @@
+package dao
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseJobSchedule(t *testing.T) {
+	valid := map[string]string{
+		"@every 30m":       "@every 30m0s",
+		"@every 6h":        "@every 6h0m0s",
+		"0 3 * * *":        "0 3 * * *",
+		" */15  * * * *":   "*/15 * * * *",
+		"0 9-18/3 * * 1-5": "0 9-18/3 * * 1-5",
+		"@daily":           "0 0 * * *",
+	}
+	for spec, want := range valid {
+		s, err := ParseJobSchedule(spec)
+		if err != nil {
+			t.Errorf("ParseJobSchedule(%q) 出错: %v", spec, err)
+			continue
+		}
+		if s.String() != want {
+			t.Errorf("ParseJobSchedule(%q).String() = %q, want %q", spec, s.String(), want)
+		}
+	}
+	for _, spec := range []string{"", "off", "@every 10s", "@every abc", "* * * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseJobSchedule(spec); err == nil {
+			t.Errorf("ParseJobSchedule(%q) 应出错", spec)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	loc := time.Local
+	base := time.Date(2026, 1, 30, 10, 7, 30, 0, loc) // 星期五
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 1, 30, 10, 15, 0, 0, loc)},
+		{"0 3 * * *", time.Date(2026, 1, 31, 3, 0, 0, 0, loc)},
+		{"0 9 * * 1", time.Date(2026, 2, 2, 9, 0, 0, 0, loc)},
+		{"0 9 * * 7", time.Date(2026, 2, 1, 9, 0, 0, 0, loc)},
+		{"30 0 31 * *", time.Date(2026, 1, 31, 0, 30, 0, 0, loc)},
+		{"0 0 1 3 *", time.Date(2026, 3, 1, 0, 0, 0, 0, loc)},
+		// 日与周都有限定时满足其一即可：2月1日或任一周一
+		{"0 0 1 * 1", time.Date(2026, 2, 1, 0, 0, 0, 0, loc)},
+		{"7 10 * * *", time.Date(2026, 1, 31, 10, 7, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		s, err := ParseJobSchedule(c.spec)
+		if err != nil {
+			t.Fatalf("ParseJobSchedule(%q) 出错: %v", c.spec, err)
+		}
+		if got := s.Next(base); !got.Equal(c.want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", c.spec, base, got, c.want)
+		}
+	}
+	never, _ := ParseJobSchedule("0 0 30 2 *")
+	if got := never.Next(base); !got.IsZero() {
+		t.Errorf("2月30日不应有执行时间，得到 %s", got)
+	}
+	every, _ := ParseJobSchedule("@every 30m")
+	if got := every.Next(base); !got.Equal(base.Add(30 * time.Minute)) {
+		t.Errorf("@every 30m 下次执行时间 = %s", got)
+	}
+}
+
+func TestParseJobScheduleOverrides(t *testing.T) {
+	specs, err := ParseJobScheduleOverrides(" tea_reconcile = 0 3 * * * ; session_cleanup=off;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 2 || specs["tea_reconcile"] != "0 3 * * *" || specs["session_cleanup"] != JobScheduleOff {
+		t.Fatalf("解析结果不符: %v", specs)
+	}
+	if specs, err := ParseJobScheduleOverrides(""); err != nil || len(specs) != 0 {
+		t.Fatalf("空配置应无覆盖: %v %v", specs, err)
+	}
+	for _, bad := range []string{"tea_reconcile", "=@every 1h", "a=@every 1h;a=off"} {
+		if _, err := ParseJobScheduleOverrides(bad); err == nil {
+			t.Errorf("ParseJobScheduleOverrides(%q) 应出错", bad)
+		}
+	}
+}
+
+func TestJobSchedulerRegisterConfigure(t *testing.T) {
+	noop := func(context.Context) (string, error) { return "", nil }
+	s := NewJobScheduler()
+	if err := s.Register(Job{Name: "cleanup", Schedule: "@every 30m", Run: noop}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register(Job{Name: "cleanup", Schedule: "@every 1h", Run: noop}); err == nil {
+		t.Error("重复注册应出错")
+	}
+	if err := s.Register(Job{Name: "Bad-Name", Schedule: "@every 1h", Run: noop}); err == nil {
+		t.Error("名称无效应出错")
+	}
+	if err := s.Register(Job{Name: "nightly", Schedule: "0 61 * * *", Run: noop}); err == nil {
+		t.Error("计划无效应出错")
+	}
+	if err := s.Register(Job{Name: "nightly", Schedule: "0 3 * * *"}); err == nil {
+		t.Error("缺少任务函数应出错")
+	}
+	if err := s.Configure("cleanup=off"); err != nil {
+		t.Fatal(err)
+	}
+	if j := s.byName["cleanup"]; j.schedule != nil || j.spec != JobScheduleOff {
+		t.Errorf("cleanup 应已停用: %q", j.spec)
+	}
+	if err := s.Configure("missing=@every 1h"); err == nil {
+		t.Error("覆盖未注册的任务应出错")
+	}
+	if err := s.RunNow("cleanup"); err != ErrJobSchedulerStopped {
+		t.Errorf("未启动时手动执行应返回 ErrJobSchedulerStopped，得到 %v", err)
+	}
+}
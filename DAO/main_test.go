@@ -0,0 +1,39 @@
+package dao
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	util "teachat/Util"
+)
+
+// testDBErr 测试数据库不可用的原因，为 nil 时 DB 已连接
+var testDBErr error
+
+// testDBConfig 测试数据库的连接配置
+var testDBConfig util.DatabaseConfig
+
+// TestMain 按 ../config.json 及环境变量（DB_*）连接测试数据库，连接失败时需要数据库的测试会被跳过
+func TestMain(m *testing.M) {
+	cfg, err := util.Load("../config.json", nil)
+	if err == nil {
+		testDBConfig = cfg.Database
+		_, err = Open(cfg.Database)
+	}
+	testDBErr = err
+	if err != nil {
+		fmt.Println("测试数据库不可用，跳过数据库相关测试:", err)
+	}
+	code := m.Run()
+	Close()
+	os.Exit(code)
+}
+
+// requireDB 没有可用的测试数据库时跳过当前测试
+func requireDB(t *testing.T) {
+	t.Helper()
+	if testDBErr != nil {
+		t.Skip("需要数据库:", testDBErr)
+	}
+}
@@ -0,0 +1,347 @@
+package dao
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"teachat/sql/migrations"
+	"time"
+)
+
+/*
+   数据库迁移：sql/migrations 下按版本号排序的 up/down 脚本，执行记录保存在 schema_migrations 表。
+   每个迁移在单独的事务中执行并记录版本，失败时整体回滚；执行期间持有 PostgreSQL 咨询锁，
+   多个实例同时执行 migrate 时依次进行。
+
+   新建数据库：teachat migrate up -seed
+   已按旧方式（psql -f schema.sql 等）手工建库的数据库：先 teachat migrate baseline，再 teachat migrate up
+*/
+
+// Migration 一个版本的迁移脚本
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string // 为空表示该版本不可回滚
+	Checksum string // up 脚本的 SHA-256，用于发现已执行后又被修改的脚本
+}
+
+// MigrationState 迁移在当前数据库中的执行情况
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // 已执行的脚本与执行时的摘要不一致
+	Missing   bool // 数据库中有执行记录，但程序中已没有该版本的脚本
+}
+
+// BaselineVersion 基线迁移的最高版本：原 schema.sql 与 tea_payment_system.sql
+const BaselineVersion = 2
+
+// migrationLockKey 迁移咨询锁的键
+const migrationLockKey = 20250710
+
+var (
+	ErrMigrationBaselineRequired = errors.New("数据库中已有数据表但没有迁移记录，请先执行 migrate baseline 标记基线版本")
+	ErrMigrationIrreversible     = errors.New("该版本没有 down 脚本，不可回滚")
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migrations 程序内置的全部迁移，按版本号升序
+func Migrations() ([]Migration, error) {
+	return LoadMigrations(migrations.FS)
+}
+
+// LoadMigrations 读取目录中的迁移脚本，按版本号升序返回。
+// 不符合命名规则的 .sql 文件（如 seed_data.sql）被忽略；版本号重复或只有 down 脚本时报错
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := migrationFileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("迁移版本号必须大于0: %s", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("迁移版本号 %d 重复: %s 与 %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+			sum := sha256.Sum256(body)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("迁移 %04d_%s 缺少 up 脚本", mig.Version, mig.Name)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// appliedMigration schema_migrations 中的一条记录
+type appliedMigration struct {
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// withMigrationLock 在单独的连接上持有迁移咨询锁执行 fn，并确保 schema_migrations 表存在
+func withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("获取迁移锁失败: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INTEGER PRIMARY KEY,
+		name        VARCHAR(255) NOT NULL,
+		checksum    VARCHAR(64) NOT NULL,
+		applied_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("创建 schema_migrations 表失败: %w", err)
+	}
+	return fn(conn)
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err = rows.Scan(&version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// runMigration 在一个事务中执行脚本并更新 schema_migrations
+func runMigration(ctx context.Context, conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if err = record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateUp 按版本号顺序执行尚未执行的迁移，steps 大于0时最多执行 steps 个，返回本次执行的迁移
+func MigrateUp(ctx context.Context, steps int) (done []Migration, err error) {
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	err = withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			var existing sql.NullString
+			if err = conn.QueryRowContext(ctx, "SELECT to_regclass('public.users')::text").Scan(&existing); err != nil {
+				return err
+			}
+			if existing.Valid {
+				return ErrMigrationBaselineRequired
+			}
+		}
+		for _, m := range all {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if steps > 0 && len(done) >= steps {
+				break
+			}
+			err = runMigration(ctx, conn, m.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)", m.Version, m.Name, m.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("执行迁移 %04d_%s 失败: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown 按版本号倒序回滚已执行的迁移，steps 小于1时按1处理，返回本次回滚的迁移
+func MigrateDown(ctx context.Context, steps int) (done []Migration, err error) {
+	if steps < 1 {
+		steps = 1
+	}
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	err = withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(all) - 1; i >= 0 && len(done) < steps; i-- {
+			m := all[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("回滚迁移 %04d_%s: %w", m.Version, m.Name, ErrMigrationIrreversible)
+			}
+			err = runMigration(ctx, conn, m.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("回滚迁移 %04d_%s 失败: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateBaseline 把 version 及之前的迁移标记为已执行而不实际执行脚本，
+// 用于此前按旧方式手工建库的数据库；version 小于1时使用 BaselineVersion
+func MigrateBaseline(ctx context.Context, version int) (done []Migration, err error) {
+	if version < 1 {
+		version = BaselineVersion
+	}
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	err = withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			if m.Version > version {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if _, err = conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)", m.Version, m.Name, m.Checksum); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// GetMigrationStates 全部迁移（含数据库中有记录但脚本已不存在的版本）的执行情况，按版本号升序
+func GetMigrationStates(ctx context.Context) (states []MigrationState, err error) {
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	err = withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			s := MigrationState{Migration: m}
+			if a, ok := applied[m.Version]; ok {
+				s.Applied, s.AppliedAt = true, a.AppliedAt
+				s.Modified = a.Checksum != m.Checksum
+				delete(applied, m.Version)
+			}
+			states = append(states, s)
+		}
+		for version, a := range applied {
+			states = append(states, MigrationState{
+				Migration: Migration{Version: version, Name: a.Name, Checksum: a.Checksum},
+				Applied:   true,
+				AppliedAt: a.AppliedAt,
+				Missing:   true,
+			})
+		}
+		return nil
+	})
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, err
+}
+
+// PendingMigrations 尚未执行的迁移数，供启动时提醒
+func PendingMigrations(ctx context.Context) (int, error) {
+	states, err := GetMigrationStates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, s := range states {
+		if !s.Applied {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ApplySeed 在一个事务中执行预填充数据脚本，脚本本身可重复执行，已存在的数据不会重复插入
+func ApplySeed(ctx context.Context) error {
+	script, err := fs.ReadFile(migrations.FS, migrations.SeedFile)
+	if err != nil {
+		return err
+	}
+	return withMigrationLock(ctx, func(conn *sql.Conn) error {
+		err := runMigration(ctx, conn, string(script), func(*sql.Tx) error { return nil })
+		if err != nil {
+			return fmt.Errorf("执行预填充数据失败: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,165 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrationsOrder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0010_tenth.up.sql":     {Data: []byte("SELECT 10;")},
+		"0002_second.up.sql":    {Data: []byte("SELECT 2;")},
+		"0002_second.down.sql":  {Data: []byte("SELECT -2;")},
+		"0001_first.up.sql":     {Data: []byte("SELECT 1;")},
+		"seed_data.sql":         {Data: []byte("SELECT 0;")},
+		"README.md":             {Data: []byte("忽略")},
+		"0003_Bad-Name.up.sql":  {Data: []byte("忽略")},
+		"0004_not_a_script.txt": {Data: []byte("忽略")},
+	}
+	list, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	var versions []int
+	for _, m := range list {
+		versions = append(versions, m.Version)
+	}
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 10 {
+		t.Fatalf("版本顺序错误: %v", versions)
+	}
+	if list[0].Down != "" || list[1].Down != "SELECT -2;" {
+		t.Errorf("down 脚本读取错误: %q %q", list[0].Down, list[1].Down)
+	}
+	if list[1].Name != "second" || len(list[1].Checksum) != 64 {
+		t.Errorf("名称或摘要错误: %+v", list[1])
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"重复": {
+			"0001_first.up.sql": {Data: []byte("SELECT 1;")},
+			"0001_other.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"缺少 up 脚本": {
+			"0001_first.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"版本号为0": {
+			"0000_zero.up.sql": {Data: []byte("SELECT 0;")},
+		},
+	}
+	for name, fsys := range cases {
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Errorf("%s: 应当报错", name)
+		}
+	}
+}
+
+// 内置迁移版本号连续，基线之后的迁移都可回滚
+func TestBuiltinMigrations(t *testing.T) {
+	list, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(list) < BaselineVersion {
+		t.Fatalf("内置迁移数量 %d 少于基线版本 %d", len(list), BaselineVersion)
+	}
+	for i, m := range list {
+		if m.Version != i+1 {
+			t.Errorf("迁移版本号不连续: 第%d个为 %04d_%s", i+1, m.Version, m.Name)
+		}
+		if m.Version > BaselineVersion && m.Down == "" {
+			t.Errorf("迁移 %04d_%s 缺少 down 脚本", m.Version, m.Name)
+		}
+		if strings.HasPrefix(m.Up, "\\") || strings.Contains(m.Up, "\n\\") {
+			t.Errorf("迁移 %04d_%s 含有 psql 元命令", m.Version, m.Name)
+		}
+	}
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?i)CREATE TABLE\s+(?:IF NOT EXISTS\s+)?([\w.]+)`)
+	referencesPattern  = regexp.MustCompile(`(?i)\bREFERENCES\s+([\w.]+)\s*\(`)
+	sqlCommentPattern  = regexp.MustCompile(`--[^\n]*`)
+)
+
+// 每条迁移在一个事务中执行，外键引用的表必须在此前已经建好，否则空库执行 migrate up 即失败
+func TestBuiltinMigrationsReferenceOrder(t *testing.T) {
+	list, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	created := map[string]bool{}
+	for _, m := range list {
+		for _, stmt := range strings.Split(sqlCommentPattern.ReplaceAllString(m.Up, ""), ";") {
+			table := ""
+			if c := createTablePattern.FindStringSubmatch(stmt); c != nil {
+				table = c[1]
+			}
+			for _, ref := range referencesPattern.FindAllStringSubmatch(stmt, -1) {
+				if ref[1] != table && !created[ref[1]] {
+					t.Errorf("迁移 %04d_%s: %s 引用了尚未建立的表 %s", m.Version, m.Name, table, ref[1])
+				}
+			}
+			if table != "" {
+				created[table] = true
+			}
+		}
+	}
+}
+
+// 在新建的空库上执行 全部 up（含预填充数据）→ 回滚到基线 → 再次 up，
+// 需要测试数据库账号有建库权限
+func TestMigrateFreshDatabase(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+	name := "teachat_migrate_test_" + strings.ReplaceAll(Random_UUID(), "-", "")[:12]
+	if _, err := DB.ExecContext(ctx, "CREATE DATABASE "+name); err != nil {
+		t.Skip("无法新建测试库:", err)
+	}
+	prev := DB
+	cfg := testDBConfig
+	cfg.Name = name
+	fresh, err := Open(cfg)
+	if err != nil {
+		DB = prev
+		prev.ExecContext(ctx, "DROP DATABASE "+name)
+		t.Fatalf("连接测试库失败: %v", err)
+	}
+	defer func() {
+		fresh.Close()
+		DB = prev
+		if _, err := prev.ExecContext(ctx, "DROP DATABASE "+name); err != nil {
+			t.Logf("删除测试库 %s 失败: %v", name, err)
+		}
+	}()
+
+	all, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	done, err := MigrateUp(ctx, 0)
+	if err != nil {
+		t.Fatalf("空库 migrate up: %v", err)
+	}
+	if len(done) != len(all) {
+		t.Fatalf("执行了 %d 个迁移，应为 %d 个", len(done), len(all))
+	}
+	if err = ApplySeed(ctx); err != nil {
+		t.Fatalf("写入预填充数据: %v", err)
+	}
+	reversible := len(all) - BaselineVersion
+	if done, err = MigrateDown(ctx, reversible); err != nil || len(done) != reversible {
+		t.Fatalf("回滚到基线: 回滚 %d 个, %v", len(done), err)
+	}
+	if done, err = MigrateUp(ctx, 0); err != nil || len(done) != reversible {
+		t.Fatalf("再次 migrate up: 执行 %d 个, %v", len(done), err)
+	}
+	pending, err := PendingMigrations(ctx)
+	if err != nil || pending != 0 {
+		t.Errorf("仍有 %d 个迁移未执行: %v", pending, err)
+	}
+}
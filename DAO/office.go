@@ -98,18 +98,21 @@ func (a *Acceptance) Create() (err error) {
 	return
 }
 
-// Update() 根据id更新一条友邻蒙评 Y记录
+// Update() 根据id更新一条友邻蒙评 Y记录，只在 Y 仍未填写时写入，否则返回 ErrAcceptReviewDecided
 func (a *Acceptance) Update() (err error) {
-	statement := `UPDATE acceptances SET y_accept = $1, y_user_id = $2, y_accepted_at = $3 WHERE id = $4`
+	statement := `UPDATE acceptances SET y_accept = $1, y_user_id = $2, y_accepted_at = $3 WHERE id = $4 AND COALESCE(y_user_id, 0) = 0`
 	stmt, err := DB.Prepare(statement)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(a.YAccept, a.YUserId, a.YAcceptedAt, a.Id)
+	result, err := stmt.Exec(a.YAccept, a.YUserId, a.YAcceptedAt, a.Id)
 	if err != nil {
 		return err
 	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrAcceptReviewDecided
+	}
 	return nil
 }
 
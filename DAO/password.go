@@ -0,0 +1,163 @@
+package dao
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+/*
+   登船密码摘要。
+   数据库 users.password 保存的是带版本的编码串，算法及参数与摘要一同保存，
+   以后调整参数或更换算法时，旧摘要仍可校验，并在用户下次成功登船时自动升级：
+
+   argon2id（当前）：$argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+   sha1（历史遗留）：40位十六进制，无盐，仅用于校验旧账号，不再写入
+*/
+
+var (
+	ErrPasswordHashFormat = errors.New("unrecognized password hash format")
+	ErrPasswordEmpty      = errors.New("password is empty")
+)
+
+// PasswordHasher 密码摘要算法
+type PasswordHasher interface {
+	// Hash 生成带算法标识及参数的编码串
+	Hash(plaintext string) (string, error)
+	// Verify 校验明文与编码串是否匹配，needsRehash 表示应以当前算法及参数重新生成摘要
+	Verify(encoded, plaintext string) (ok bool, needsRehash bool, err error)
+	// Recognize 判断编码串是否由本算法生成
+	Recognize(encoded string) bool
+}
+
+// Argon2idHasher argon2id 摘要，参数参考 RFC 9106 推荐的低内存配置
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultPasswordHasher 新密码使用的算法
+var DefaultPasswordHasher PasswordHasher = &Argon2idHasher{
+	Memory:      64 * 1024,
+	Iterations:  1,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// passwordHashers 可识别的全部算法，校验时按顺序匹配
+var passwordHashers = []PasswordHasher{
+	DefaultPasswordHasher,
+	legacySHA1Hasher{},
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (h *Argon2idHasher) Recognize(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+func (h *Argon2idHasher) Hash(plaintext string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plaintext), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(encoded, plaintext string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, false, ErrPasswordHashFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, ErrPasswordHashFormat
+	}
+	if version != argon2.Version {
+		return false, false, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+	var memory, iterations uint32
+	var parallelism uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false, false, ErrPasswordHashFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrPasswordHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrPasswordHashFormat
+	}
+
+	other := argon2.IDKey([]byte(plaintext), salt, iterations, memory, parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+	needsRehash := memory != h.Memory || iterations != h.Iterations || parallelism != h.Parallelism ||
+		uint32(len(salt)) != h.SaltLength || uint32(len(key)) != h.KeyLength
+	return true, needsRehash, nil
+}
+
+// legacySHA1Hasher 早期无盐 sha1 摘要，只读不写，匹配成功即要求升级
+type legacySHA1Hasher struct{}
+
+func (legacySHA1Hasher) Recognize(encoded string) bool {
+	if len(encoded) != sha1.Size*2 {
+		return false
+	}
+	for _, c := range encoded {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (legacySHA1Hasher) Hash(plaintext string) (string, error) {
+	return "", errors.New("sha1 password hashing is retired")
+}
+
+func (legacySHA1Hasher) Verify(encoded, plaintext string) (bool, bool, error) {
+	if subtle.ConstantTimeCompare([]byte(encoded), []byte(Encrypt(plaintext))) != 1 {
+		return false, false, nil
+	}
+	return true, true, nil
+}
+
+// HashPassword 使用当前算法生成密码摘要
+func HashPassword(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", ErrPasswordEmpty
+	}
+	return DefaultPasswordHasher.Hash(plaintext)
+}
+
+// VerifyPassword 校验明文密码与数据库中保存的摘要是否匹配，
+// needsRehash 为 true 时调用方应在校验成功后用明文重新生成摘要保存
+func VerifyPassword(encoded, plaintext string) (ok bool, needsRehash bool, err error) {
+	if encoded == "" || plaintext == "" {
+		return false, false, nil
+	}
+	for _, h := range passwordHashers {
+		if h.Recognize(encoded) {
+			return h.Verify(encoded, plaintext)
+		}
+	}
+	return false, false, ErrPasswordHashFormat
+}
@@ -0,0 +1,127 @@
+package dao
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+/*
+   找回密码：
+   1、茶友提交注册邮箱，系统生成一次性随机令牌，只保存令牌的SHA-256摘要，明文令牌通过邮件投递；
+   2、令牌有效期由配置 PasswordResetTTLMinutes 决定，使用后立即作废，同一用户再次申请时旧令牌作废；
+   3、重设密码成功后，删除该用户全部会话（sessions），所有设备需要重新登船。
+*/
+
+// ErrPasswordResetTokenInvalid 令牌不存在、已使用或已过期
+var ErrPasswordResetTokenInvalid = errors.New("password reset token invalid or expired")
+
+// 找回密码令牌
+type PasswordReset struct {
+	Id        int
+	Uuid      string
+	UserId    int
+	TokenHash string // 令牌的SHA-256摘要（十六进制），数据库不保存明文令牌
+	ExpiresAt time.Time
+	UsedAt    *time.Time // 使用时间，nil表示未使用
+	CreatedAt time.Time
+}
+
+// hashPasswordResetToken 计算令牌摘要
+func hashPasswordResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePasswordReset 为用户生成新的找回密码令牌，返回明文令牌（仅用于投递），
+// 同时作废该用户此前尚未使用的令牌
+func CreatePasswordReset(userId int, ttl time.Duration, ctx context.Context) (token string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", err
+	}
+	token = hex.EncodeToString(buf)
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err = tx.ExecContext(ctx, `UPDATE password_resets SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL`, userId, now); err != nil {
+		return "", err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO password_resets (user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4)`,
+		userId, hashPasswordResetToken(token), now.Add(ttl), now); err != nil {
+		return "", err
+	}
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetValidPasswordReset 根据明文令牌获取未使用且未过期的找回密码记录
+func GetValidPasswordReset(token string, ctx context.Context) (reset PasswordReset, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = DB.QueryRowContext(ctx, `SELECT id, uuid, user_id, token_hash, expires_at, used_at, created_at FROM password_resets
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2`, hashPasswordResetToken(token), time.Now()).
+		Scan(&reset.Id, &reset.Uuid, &reset.UserId, &reset.TokenHash, &reset.ExpiresAt, &reset.UsedAt, &reset.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrPasswordResetTokenInvalid
+	}
+	return
+}
+
+// ResetPasswordWithToken 使用令牌重设密码：在同一事务中更新密码、作废令牌并删除该用户全部会话
+// 返回被重设密码的用户ID
+func ResetPasswordWithToken(token, newPassword string, ctx context.Context) (userId int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hashed, err := HashPassword(newPassword)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var resetId int
+	err = tx.QueryRowContext(ctx, `SELECT id, user_id FROM password_resets
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2 FOR UPDATE`, hashPasswordResetToken(token), now).
+		Scan(&resetId, &userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrPasswordResetTokenInvalid
+		}
+		return 0, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE password_resets SET used_at = $2 WHERE id = $1`, resetId, now); err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE users SET password = $2, updated_at = $3 WHERE id = $1`, userId, hashed, now); err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userId); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashPassword_RoundTrip(t *testing.T) {
+	encoded, err := HashPassword("闻香识茶123")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=65536,t=1,p=4$") {
+		t.Errorf("HashPassword() = %v, want argon2id encoding with params", encoded)
+	}
+
+	ok, needsRehash, err := VerifyPassword(encoded, "闻香识茶123")
+	if err != nil || !ok || needsRehash {
+		t.Errorf("VerifyPassword(correct) = %v, %v, %v, want true, false, nil", ok, needsRehash, err)
+	}
+	ok, _, err = VerifyPassword(encoded, "闻香识茶124")
+	if err != nil || ok {
+		t.Errorf("VerifyPassword(wrong) = %v, %v, want false, nil", ok, err)
+	}
+
+	other, _ := HashPassword("闻香识茶123")
+	if other == encoded {
+		t.Error("HashPassword() should use a random salt")
+	}
+}
+
+func TestVerifyPassword_LegacySHA1(t *testing.T) {
+	legacy := Encrypt("teachat")
+
+	ok, needsRehash, err := VerifyPassword(legacy, "teachat")
+	if err != nil || !ok || !needsRehash {
+		t.Errorf("VerifyPassword(legacy) = %v, %v, %v, want true, true, nil", ok, needsRehash, err)
+	}
+	ok, _, _ = VerifyPassword(legacy, "Teachat")
+	if ok {
+		t.Error("VerifyPassword(legacy, wrong) = true, want false")
+	}
+}
+
+func TestVerifyPassword_OutdatedParams(t *testing.T) {
+	weak := &Argon2idHasher{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	encoded, err := weak.Hash("teachat")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	ok, needsRehash, err := VerifyPassword(encoded, "teachat")
+	if err != nil || !ok || !needsRehash {
+		t.Errorf("VerifyPassword(outdated) = %v, %v, %v, want true, true, nil", ok, needsRehash, err)
+	}
+}
+
+func TestVerifyPassword_UnknownFormat(t *testing.T) {
+	for _, encoded := range []string{"plain-text", "$argon2id$v=19$broken", ""} {
+		if ok, _, _ := VerifyPassword(encoded, "teachat"); ok {
+			t.Errorf("VerifyPassword(%q) = true, want false", encoded)
+		}
+	}
+}
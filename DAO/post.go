@@ -232,6 +232,12 @@ func (post *DraftPost) UpdateClass(class int) (err error) {
 	return
 }
 
+// Reject() 友邻蒙评婉拒品味草稿，记下原来的发布级别，申诉复审时恢复
+func (post *DraftPost) Reject() (err error) {
+	_, err = DB.Exec("UPDATE draft_posts SET rejected_from_class = class, class = $2 WHERE id = $1 AND class <> $2", post.Id, DraftPostClassRejectedByNeighbor)
+	return
+}
+
 // HasUserPostedInThread 检查用户是否在指定话题下发表过回复
 // 结构体必备参数: userID - 用户ID, threadID - 话题ID
 // 返回值: bool - 是否发表过, error - 错误信息
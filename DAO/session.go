@@ -1,49 +1,140 @@
 package dao
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	util "teachat/Util"
 	"time"
+	"unicode/utf8"
 )
 
-const sessionDuration = 7 * 24 * time.Hour
+/*
+   会话：每台设备登船各自创建一条会话记录，记录浏览器标识、IP及最后活动时间。
+   有效期为滑动续期：闲置超过 SessionIdleHours 即失效，每次访问（节流）顺延；
+   无论是否活跃，自登船起超过 SessionMaxDays 都需要重新登船。
+*/
+
+// sessionTouchInterval 两次刷新最后活动时间的最短间隔，避免每个请求都写库
+const sessionTouchInterval = time.Minute
 
 // 会话
 type Session struct {
-	Id        int
-	Uuid      string
-	Email     string
-	UserId    int
-	CreatedAt time.Time
-	Gender    int
+	Id         int
+	Uuid       string
+	Email      string
+	UserId     int
+	CreatedAt  time.Time
+	Gender     int
+	UserAgent  string
+	IP         string
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	CsrfToken  string // 本会话的防跨站请求伪造令牌，提交表单时必须携带
+}
+
+const sessionColumns = "id, uuid, email, user_id, created_at, gender, user_agent, ip, last_seen_at, expires_at, csrf_token"
+
+func (session *Session) scanFields() []any {
+	return []any{&session.Id, &session.Uuid, &session.Email, &session.UserId, &session.CreatedAt, &session.Gender,
+		&session.UserAgent, &session.IP, &session.LastSeenAt, &session.ExpiresAt, &session.CsrfToken}
+}
+
+// SessionIdleTimeout 会话闲置超时
+func SessionIdleTimeout() time.Duration {
+	return time.Duration(util.Config.SessionIdleHours) * time.Hour
+}
+
+// SessionMaxLifetime 会话自创建起的最长有效期
+func SessionMaxLifetime() time.Duration {
+	return time.Duration(util.Config.SessionMaxDays) * 24 * time.Hour
+}
+
+// nextExpiry 顺延后的过期时间，不超过最长有效期
+func (session *Session) nextExpiry(now time.Time) time.Time {
+	expiresAt := now.Add(SessionIdleTimeout())
+	if limit := session.CreatedAt.Add(SessionMaxLifetime()); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	return expiresAt
+}
+
+// LastSeenDateTime 最后活动时间
+func (session *Session) LastSeenDateTime() string {
+	return session.LastSeenAt.Format(FMT_DATE_TIME_CN)
+}
+
+// CreatedDateTime 登船时间
+func (session *Session) CreatedDateTime() string {
+	return session.CreatedAt.Format(FMT_DATE_TIME_CN)
+}
+
+// 浏览器标识最多保存的字节数
+const userAgentMaxBytes = 512
+
+// truncateUserAgent 去掉无效的UTF-8字节，并在字符边界处截断到 userAgentMaxBytes 以内，
+// 按字节硬截可能切开多字节字符，数据库会拒绝写入
+func truncateUserAgent(userAgent string) string {
+	userAgent = strings.ToValidUTF8(userAgent, "")
+	if len(userAgent) <= userAgentMaxBytes {
+		return userAgent
+	}
+	cut := userAgentMaxBytes
+	for cut > 0 && !utf8.RuneStart(userAgent[cut]) {
+		cut--
+	}
+	return userAgent[:cut]
 }
 
 // Create a new session for an existing user
-func (user *User) CreateSession() (session Session, err error) {
-	statement := "INSERT INTO sessions (uuid, email, user_id, created_at, gender) VALUES ($1, $2, $3, $4 ,$5) RETURNING id, uuid, email, user_id, created_at, gender"
+func (user *User) CreateSession(userAgent, ip string) (session Session, err error) {
+	statement := "INSERT INTO sessions (uuid, email, user_id, created_at, gender, user_agent, ip, last_seen_at, expires_at, csrf_token) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING " + sessionColumns
 	stmt, err := DB.Prepare(statement)
 	if err != nil {
 		return
 	}
 	defer stmt.Close()
 
+	userAgent = truncateUserAgent(userAgent)
+	csrfToken, err := newCsrfToken()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	session.CreatedAt = now
 	// use QueryRow to return a row and scan the returned id into the Session struct
-	err = stmt.QueryRow(Random_UUID(), user.Email, user.Id, time.Now(), user.Gender).Scan(&session.Id, &session.Uuid, &session.Email, &session.UserId, &session.CreatedAt, &session.Gender)
+	err = stmt.QueryRow(Random_UUID(), user.Email, user.Id, now, user.Gender, userAgent, ip, now, session.nextExpiry(now), csrfToken).Scan(session.scanFields()...)
 	return
 }
 
-// Get the session for an existing user
-func (user *User) Session() (session Session, err error) {
-	session = Session{}
-	err = DB.QueryRow("SELECT id, uuid, email, user_id, created_at, gender FROM sessions WHERE user_id = $1", user.Id).
-		Scan(&session.Id, &session.Uuid, &session.Email, &session.UserId, &session.CreatedAt, &session.Gender)
+// Sessions 用户全部未过期的会话（每台设备一条），最近活动的在前
+func (user *User) Sessions(ctx context.Context) (sessions []Session, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := DB.QueryContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND expires_at > $2 ORDER BY last_seen_at DESC", user.Id, time.Now())
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var session Session
+		if err = rows.Scan(session.scanFields()...); err != nil {
+			return
+		}
+		sessions = append(sessions, session)
+	}
+	err = rows.Err()
 	return
 }
 
-// 删除用户的session
-func (user *User) DeleteSession() (err error) {
+// 删除用户的全部session（所有设备登出）
+func (user *User) DeleteAllSessions() (err error) {
 	statement := /* sql */ "DELETE FROM sessions WHERE user_id = $1"
 	stmt, err := DB.Prepare(statement)
 	if err != nil {
@@ -55,12 +146,53 @@ func (user *User) DeleteSession() (err error) {
 	return
 }
 
+// DeviceId 会话在登船设备页面上的标识：由 Uuid 散列得到，不能反推出 Cookie 中的会话值
+func (session *Session) DeviceId() string {
+	sum := sha256.Sum256([]byte("session-device:" + session.Uuid))
+	return hex.EncodeToString(sum[:16])
+}
+
+// RevokeSession 按 DeviceId 注销用户的指定会话，只能注销自己的会话，会话不存在时 revoked 为 nil
+func (user *User) RevokeSession(ctx context.Context, deviceId string) (revoked *Session, err error) {
+	sessions, err := user.Sessions(ctx)
+	if err != nil {
+		return
+	}
+	for i := range sessions {
+		if sessions[i].DeviceId() != deviceId {
+			continue
+		}
+		result, err := DB.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1 AND uuid = $2", user.Id, sessions[i].Uuid)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return nil, err
+		}
+		return &sessions[i], nil
+	}
+	return nil, nil
+}
+
+// 删除用户除当前会话以外的全部session（修改密码后其他设备需要重新登录）
+func (user *User) DeleteSessionsExcept(keepUuid string) (err error) {
+	statement := /* sql */ "DELETE FROM sessions WHERE user_id = $1 AND uuid <> $2"
+	stmt, err := DB.Prepare(statement)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(user.Id, keepUuid)
+	return
+}
+
 // Check if session is valid in the database
 func (session *Session) Check() (bool, error) {
 	err := DB.QueryRow(
-		"SELECT id, uuid, email, user_id, created_at, gender FROM sessions WHERE uuid = $1",
+		"SELECT "+sessionColumns+" FROM sessions WHERE uuid = $1",
 		session.Uuid,
-	).Scan(&session.Id, &session.Uuid, &session.Email, &session.UserId, &session.CreatedAt, &session.Gender)
+	).Scan(session.scanFields()...)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -73,18 +205,69 @@ func (session *Session) Check() (bool, error) {
 		return false, nil
 	}
 
-	expiryTime := session.CreatedAt.Add(sessionDuration)
-	if time.Now().Before(expiryTime) {
+	if time.Now().Before(session.ExpiresAt) {
 		return true, nil
 	}
 
 	// 会话过期
 	if err := session.Delete(); err != nil {
-		util.Debug("failed to delete expired session: %v", err)
+		util.Debug("failed to delete expired session", err)
 	}
 	return false, nil
 }
 
+// Touch 记录会话最新活动（时间、IP、浏览器标识）并顺延过期时间，
+// 距上次记录不足 sessionTouchInterval 时不写库
+func (session *Session) Touch(userAgent, ip string) error {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < sessionTouchInterval && session.IP == ip {
+		return nil
+	}
+	userAgent = truncateUserAgent(userAgent)
+	expiresAt := session.nextExpiry(now)
+	_, err := DB.Exec("UPDATE sessions SET last_seen_at = $2, expires_at = $3, ip = $4, user_agent = $5 WHERE id = $1",
+		session.Id, now, expiresAt, ip, userAgent)
+	if err != nil {
+		return err
+	}
+	session.LastSeenAt, session.ExpiresAt, session.IP, session.UserAgent = now, expiresAt, ip, userAgent
+	return nil
+}
+
+// newCsrfToken 32字节随机数的十六进制串
+func newCsrfToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// EnsureCsrfToken 旧会话没有令牌时补发一个
+func (session *Session) EnsureCsrfToken() error {
+	if session.CsrfToken != "" {
+		return nil
+	}
+	token, err := newCsrfToken()
+	if err != nil {
+		return err
+	}
+	if _, err = DB.Exec("UPDATE sessions SET csrf_token = $2 WHERE id = $1", session.Id, token); err != nil {
+		return err
+	}
+	session.CsrfToken = token
+	return nil
+}
+
+// DeleteExpiredSessions 清理已过期的会话，返回删除条数
+func DeleteExpiredSessions() (int64, error) {
+	result, err := DB.Exec("DELETE FROM sessions WHERE expires_at <= $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // type Watchword struct {
 // 	Id              int
 // 	Word            string
@@ -0,0 +1,40 @@
+package dao
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateUserAgent(t *testing.T) {
+	tests := []struct {
+		name, in string
+		wantLen  int
+	}{
+		{"短标识原样保留", "Mozilla/5.0", len("Mozilla/5.0")},
+		{"ASCII按字节截断", strings.Repeat("a", 600), userAgentMaxBytes},
+		{"多字节字符不被切开", "a" + strings.Repeat("茶", 200), 511}, // 1 + 170*3
+		{"无效字节被去掉", "Mozilla\xff/5.0", len("Mozilla/5.0")},
+	}
+	for _, tt := range tests {
+		got := truncateUserAgent(tt.in)
+		if !utf8.ValidString(got) || len(got) != tt.wantLen {
+			t.Errorf("%s: len=%d valid=%v, want len=%d", tt.name, len(got), utf8.ValidString(got), tt.wantLen)
+		}
+	}
+}
+
+// 登船设备页面上的标识固定、互不相同，且不包含会话 Uuid
+func TestSessionDeviceId(t *testing.T) {
+	a := Session{Uuid: "c0ffee00-1234-4abc-8def-000000000001"}
+	b := Session{Uuid: "c0ffee00-1234-4abc-8def-000000000002"}
+	if a.DeviceId() != a.DeviceId() {
+		t.Error("同一会话的 DeviceId 应固定")
+	}
+	if a.DeviceId() == b.DeviceId() {
+		t.Error("不同会话的 DeviceId 不应相同")
+	}
+	if len(a.DeviceId()) != 32 || strings.Contains(a.DeviceId(), a.Uuid) {
+		t.Errorf("DeviceId %q 应为32位十六进制且不含 Uuid", a.DeviceId())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -59,7 +60,7 @@ type WitnessLog struct {
 	Id         int
 	Uuid       string
 	TeaOrderId int
-	Action     string // "审批"/"暂停"/"恢复"/"终止"/"罚没"/"退款"
+	Action     string // "审批"/"暂停"/"恢复"/"终止"/"罚没"/"退款"/"争议"/"答辩"/"撤诉"/"仲裁"
 	Reason     string
 	WitnessId  int // 见证人用户ID
 	EvidenceId int // 证据材料 ->Evidence{}
@@ -73,6 +74,12 @@ const (
 	WitnessActionCancel  = "终止"
 	WitnessActionForfeit = "罚没" // 见证人对违规恶意/不道德行为的处罚，罚没星茶转入系统特殊团队"公共治理团队"
 	WitnessActionRefund  = "退回" // 见证人对无恶意但超出预设讨论范围的约茶的处理，退款星茶原路退回双方团队
+
+	// 公道杯（预备金托管争议）流程
+	WitnessActionDispute   = "争议" // 需求方或解题方团队对托管预备金发起争议
+	WitnessActionRespond   = "答辩" // 被诉方团队提交答辩
+	WitnessActionWithdraw  = "撤诉" // 发起方撤销争议
+	WitnessActionArbitrate = "仲裁" // 见证者作出仲裁，托管星茶释放给胜出方
 )
 
 // WitnessLog.Create() 创建见证日志
@@ -90,6 +97,17 @@ func (w *WitnessLog) Create(ctx context.Context) (err error) {
 	return err
 }
 
+// createTx 在调用方的事务中创建见证日志，与所见证的操作一同提交或回滚
+func (w *WitnessLog) createTx(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO witness_logs (tea_order_id, action, reason, witness_id, evidence_id, witness_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		w.TeaOrderId, w.Action, w.Reason, w.WitnessId, w.EvidenceId, w.WitnessAt)
+	if err != nil {
+		return fmt.Errorf("见证日志写入失败: %w", err)
+	}
+	return nil
+}
+
 // WitnessLog.GetByTeaOrderId() 获取见证日志列表
 func (w *WitnessLog) GetByTeaOrderId(ctx context.Context) ([]*WitnessLog, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -440,7 +440,7 @@ func TeaUserProcessToUserExpiredTransfers() error {
 		// 检查锁定余额是否足够
 		if currentLockedBalance < et.Amount {
 			// 锁定余额不足，记录警告并跳过
-			util.Error("用户对用户转账过期处理时，锁定余额不足，无法解锁，转账ID：%d，用户ID：%d，锁定余额：%d，转账金额：%d", et.Id, et.FromUserId, currentLockedBalance, et.Amount)
+			util.Errorf("用户对用户转账过期处理时，锁定余额不足，无法解锁，转账ID：%d，用户ID：%d，锁定余额：%d，转账金额：%d", et.Id, et.FromUserId, currentLockedBalance, et.Amount)
 			continue
 		}
 
@@ -525,7 +525,7 @@ func TeaUserProcessToTeamExpiredTransfers() error {
 		// 检查锁定余额是否足够
 		if currentLockedBalance < et.Amount {
 			// 锁定余额不足，记录警告并跳过
-			util.Error("用户对团队转账过期处理时，锁定余额不足，无法解锁，转账ID：%d，用户ID：%d，锁定余额：%d，转账金额：%d", et.Id, et.FromUserId, currentLockedBalance, et.Amount)
+			util.Errorf("用户对团队转账过期处理时，锁定余额不足，无法解锁，转账ID：%d，用户ID：%d，锁定余额：%d，转账金额：%d", et.Id, et.FromUserId, currentLockedBalance, et.Amount)
 			continue
 		}
 
@@ -587,25 +587,40 @@ func CheckTeaUserAccountFrozen(userId int) (bool, string, error) {
 	return frozen, reason, nil
 }
 
-// 创建用户对用户转账记录
+// 创建用户对用户转账记录（不经风控规则判定，发起转账请用 SubmitTeaUserToUserTransfer）
 func CreateTeaUserToUserTransferOut(fromUserId int, fromUserName string, toUserId int, toUserName string, amountMilligrams int64, notes string, expireHours int) (TeaUserToUserTransferOut, error) {
-	transfer := TeaUserToUserTransferOut{}
-
 	if amountMilligrams <= 0 {
-		return transfer, fmt.Errorf("转账金额必须大于0")
+		return TeaUserToUserTransferOut{}, fmt.Errorf("转账金额必须大于0")
 	}
 
 	// 开始事务
 	tx, err := DB.Begin()
 	if err != nil {
-		return transfer, fmt.Errorf("开始事务失败: %v", err)
+		return TeaUserToUserTransferOut{}, fmt.Errorf("开始事务失败: %v", err)
 	}
 	defer tx.Rollback()
 
+	transfer, err := createTeaUserToUserTransferOutTx(tx, fromUserId, fromUserName, toUserId, toUserName, amountMilligrams, notes, expireHours)
+	if err != nil {
+		return transfer, err
+	}
+
+	// 提交事务
+	if err = tx.Commit(); err != nil {
+		return transfer, fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	return transfer, nil
+}
+
+// createTeaUserToUserTransferOutTx 在事务中锁定转出金额并创建用户对用户转账记录
+func createTeaUserToUserTransferOutTx(tx *sql.Tx, fromUserId int, fromUserName string, toUserId int, toUserName string, amountMilligrams int64, notes string, expireHours int) (TeaUserToUserTransferOut, error) {
+	transfer := TeaUserToUserTransferOut{}
+
 	// 1. 锁定并检查转出用户账户余额和状态
 	var balance, lockedBalance int64
 	var status string
-	err = tx.QueryRow(`
+	err := tx.QueryRow(`
 		SELECT balance_milligrams, locked_balance_milligrams, status 
 		FROM tea.user_accounts 
 		WHERE user_id = $1 
@@ -652,11 +667,6 @@ func CreateTeaUserToUserTransferOut(fromUserId int, fromUserName string, toUserI
 		return transfer, fmt.Errorf("创建用户间转账记录失败: %v", err)
 	}
 
-	// 5. 提交事务
-	if err = tx.Commit(); err != nil {
-		return transfer, fmt.Errorf("提交事务失败: %v", err)
-	}
-
 	return transfer, nil
 }
 func CreateTeaUserFromUserTransferIn(userToUserTransferOutId int, toUserId int, to_user_name string, fromUserId int, from_user_name string, amount_milligrams int64, notes string, balanceAfterReceipt int64, expiresAt time.Time) (TeaUserFromUserTransferIn, error) {
@@ -1042,6 +1052,20 @@ func TeaUserConfirmFromUserTransferIn(transferUuid string, to_user_id int) error
 		return fmt.Errorf("创建接收来自用户转账记录失败: %v", err)
 	}
 
+	// 5. 记账
+	if err = postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             TeaLedgerType_Transfer,
+		From:             TeaUserLedgerAccount(fromUserId),
+		To:               TeaUserLedgerAccount(to_user_id),
+		AmountMilligrams: amountMg,
+		ReferenceTable:   "user_to_user_transfer_out",
+		ReferenceId:      transferOutID,
+		InitiatorUserId:  to_user_id,
+		Notes:            notes,
+	}); err != nil {
+		return err
+	}
+
 	// 提交事务
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交事务失败: %v", err)
@@ -1059,7 +1083,8 @@ func TeaUserConfirmFromTeamTransferIn(transferUuid string, confirm_user_id int)
 	defer tx.Rollback()
 
 	// 首先锁定并获取转账记录详情
-	var transferOutID, amountMg, toUserID, fromTeamId int
+	var transferOutID, toUserID, fromTeamId int
+	var amountMg int64
 	var notes, toUserName, fromTeamName string
 	var expiresAt time.Time
 	err = tx.QueryRow(`
@@ -1086,14 +1111,16 @@ func TeaUserConfirmFromTeamTransferIn(transferUuid string, confirm_user_id int)
 
 	now := time.Now()
 
-	// 1. 更新转出团队账户（减少余额），并获取转出后余额
-	var senderBalanceAfter int
+	// 1. 更新转出团队账户（减少余额和发起转账时锁定的金额），并获取转出后余额
+	var senderBalanceAfter int64
 	err = tx.QueryRow(`
-		UPDATE tea.team_accounts 
+		UPDATE tea.team_accounts
 		SET balance_milligrams = balance_milligrams - $1,
+			locked_balance_milligrams = locked_balance_milligrams - $1,
 			updated_at = $2
-		WHERE team_id = $3 
+		WHERE team_id = $3
 		AND balance_milligrams >= $1
+		AND locked_balance_milligrams >= $1
 		RETURNING balance_milligrams`,
 		amountMg, now, fromTeamId).Scan(&senderBalanceAfter)
 	if err != nil {
@@ -1111,7 +1138,7 @@ func TeaUserConfirmFromTeamTransferIn(transferUuid string, confirm_user_id int)
 	}
 
 	// 3. 更新接收用户账户（增加余额）
-	var receiverBalanceAfter int
+	var receiverBalanceAfter int64
 	err = tx.QueryRow(`
 		UPDATE tea.user_accounts 
 		SET balance_milligrams = balance_milligrams + $1,
@@ -1137,6 +1164,20 @@ func TeaUserConfirmFromTeamTransferIn(transferUuid string, confirm_user_id int)
 		return fmt.Errorf("创建接收来自团队星茶转账记录失败: %v", err)
 	}
 
+	// 5. 记账
+	if err = postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             TeaLedgerType_Transfer,
+		From:             TeaTeamLedgerAccount(fromTeamId),
+		To:               TeaUserLedgerAccount(confirm_user_id),
+		AmountMilligrams: amountMg,
+		ReferenceTable:   "team_to_user_transfer_out",
+		ReferenceId:      transferOutID,
+		InitiatorUserId:  confirm_user_id,
+		Notes:            notes,
+	}); err != nil {
+		return err
+	}
+
 	// 提交事务
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交事务失败: %v", err)
@@ -1775,6 +1816,11 @@ func TeaUserToTeamRejectedTransfers(from_user_id int, page, limit int) ([]TeaUse
 //
 // 返回：错误信息
 func TransferUserToTeamDirectly(fromUserId, toTeamId int, amountMg int64, notes string) error {
+	// 确保接收团队有星茶账户（在事务外创建，避免与本事务互相等待）
+	if err := EnsureTeaTeamAccountExists(toTeamId); err != nil {
+		return fmt.Errorf("确保接收团队星茶账户存在失败: %v", err)
+	}
+
 	// 开始事务
 	tx, err := DB.Begin()
 	if err != nil {
@@ -1788,7 +1834,7 @@ func TransferUserToTeamDirectly(fromUserId, toTeamId int, amountMg int64, notes
 	err = tx.QueryRow(`
 		SELECT u.name, ua.balance_milligrams, ua.locked_balance_milligrams 
 		FROM tea.user_accounts ua
-		JOIN tea.users u ON ua.user_id = u.id
+		JOIN users u ON ua.user_id = u.id
 		WHERE ua.user_id = $1 
 		FOR UPDATE`,
 		fromUserId).Scan(&fromUserName, &balance, &lockedBalance)
@@ -1820,46 +1866,60 @@ func TransferUserToTeamDirectly(fromUserId, toTeamId int, amountMg int64, notes
 
 	// 4. 获取接收团队信息
 	var toTeamName string
-	err = tx.QueryRow(`SELECT name FROM tea.teams WHERE id = $1`, toTeamId).Scan(&toTeamName)
+	err = tx.QueryRow(`SELECT name FROM teams WHERE id = $1`, toTeamId).Scan(&toTeamName)
 	if err != nil {
 		return fmt.Errorf("查询接收团队信息失败: %v", err)
 	}
 
-	// 5. 确保接收团队有星茶账户
-	err = EnsureTeaTeamAccountExists(toTeamId)
-	if err != nil {
-		return fmt.Errorf("确保接收团队星茶账户存在失败: %v", err)
-	}
-
-	// 6. 增加接收团队账户余额
-	err = tx.QueryRow(`
+	// 5. 增加接收团队账户余额
+	result, err := tx.Exec(`
 		UPDATE tea.team_accounts 
 		SET balance_milligrams = balance_milligrams + $1,
 		    updated_at = $2
-		WHERE team_id = $3
-		RETURNING balance_milligrams`,
-		amountMg, time.Now(), toTeamId).Scan(&balanceAfterTransfer)
+		WHERE team_id = $3`,
+		amountMg, time.Now(), toTeamId)
+	if err == nil {
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			err = sql.ErrNoRows
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("增加接收团队账户余额失败: %v", err)
 	}
 
-	// 7. 创建转账记录（状态为已完成）
+	// 6. 创建转账记录（状态为已完成）
 	now := time.Now().UTC()
-	_, err = tx.Exec(`
+	var transferOutId int
+	err = tx.QueryRow(`
 		INSERT INTO tea.user_to_team_transfer_out 
 		(from_user_id, from_user_name, to_team_id, to_team_name, amount_milligrams, notes, status, balance_after_transfer, expires_at, payment_time, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id`,
 		fromUserId, fromUserName, toTeamId, toTeamName, amountMg, notes,
-		TeaTransferStatusCompleted, balanceAfterTransfer, now, now, now, now)
+		TeaTransferStatusCompleted, balanceAfterTransfer, now, now, now, now).Scan(&transferOutId)
 	if err != nil {
 		return fmt.Errorf("创建转账记录失败: %v", err)
 	}
 
+	// 7. 记账
+	if err = postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             TeaLedgerType_Transfer,
+		From:             TeaUserLedgerAccount(fromUserId),
+		To:               TeaTeamLedgerAccount(toTeamId),
+		AmountMilligrams: amountMg,
+		ReferenceTable:   "user_to_team_transfer_out",
+		ReferenceId:      transferOutId,
+		InitiatorUserId:  fromUserId,
+		Notes:            notes,
+	}); err != nil {
+		return err
+	}
+
 	// 8. 提交事务
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交事务失败: %v", err)
 	}
 
-	util.Debug("直接转账成功: 用户%d -> 团队%d, 金额%d毫克", fromUserId, toTeamId, amountMg)
+	util.Debugf("直接转账成功: 用户%d -> 团队%d, 金额%d毫克", fromUserId, toTeamId, amountMg)
 	return nil
 }
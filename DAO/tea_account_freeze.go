@@ -0,0 +1,569 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	util "teachat/Util"
+)
+
+/*
+星茶账户冻结记录及申诉：
+1、冻结、解冻一律经 FreezeTeaAccount / UnfreezeTeaAccount，在同一事务中更新账户状态并记一行冻结记录
+   （操作人、来源、原因、相关凭据、之前的状态）；系统操作（对账冻结、到期解冻）操作人为空；
+2、冻结时可设定自动解冻期限，后台任务 tea_auto_unfreeze 到期解冻（UnfreezeDueTeaAccounts），再次冻结以最新一次的期限为准；
+3、账户冻结期间，持有人（茶友本人，团队账户为团队核心成员）可提出申诉，同一账户同时只能有一份待审核申诉；
+4、茶博士审核申诉：批准即解冻，解冻记录关联该申诉；驳回须说明理由；申诉人不能审核自己的申诉；
+   账户经其他途径解冻时，待审核的申诉自动结案。
+*/
+
+// 冻结记录动作
+const (
+	TeaFreezeAction_Freeze   = "freeze"
+	TeaFreezeAction_Unfreeze = "unfreeze"
+)
+
+// 冻结记录来源
+const (
+	TeaFreezeSource_Manual    = "manual"
+	TeaFreezeSource_Reconcile = "reconcile"
+	TeaFreezeSource_Auto      = "auto"
+	TeaFreezeSource_Appeal    = "appeal"
+)
+
+// 冻结申诉状态
+const (
+	TeaFreezeAppealStatus_Pending  = "pending"
+	TeaFreezeAppealStatus_Approved = "approved"
+	TeaFreezeAppealStatus_Rejected = "rejected"
+	TeaFreezeAppealStatus_Closed   = "closed"
+)
+
+// TeaFreezeAppealStatementMaxLength 申诉陈述最多字数
+const TeaFreezeAppealStatementMaxLength = 2000
+
+var (
+	ErrTeaAccountNotFrozen       = errors.New("星茶账户未冻结")
+	ErrTeaFreezeAppealNotFound   = errors.New("冻结申诉不存在")
+	ErrTeaFreezeAppealPending    = errors.New("该账户已有待审核的申诉")
+	ErrTeaFreezeAppealDecided    = errors.New("该申诉已审核或已结案")
+	ErrTeaFreezeAppealSelfReview = errors.New("不能审核自己提出的申诉")
+)
+
+// TeaAccountFreezeEvent 星茶账户冻结、解冻记录
+type TeaAccountFreezeEvent struct {
+	Id             int
+	Uuid           string
+	HolderType     string
+	HolderId       int
+	Action         string
+	Source         string
+	Reason         string
+	OperatorUserId int // 0 表示系统操作
+	OperatorName   string
+	EvidenceId     int
+	EvidenceUuid   string
+	UnfreezeAt     *time.Time // 冻结时设定的自动解冻期限
+	AppealId       int        // 申诉获准解冻时对应的申诉
+	PreviousStatus string
+	PreviousReason string
+	CreatedAt      time.Time
+}
+
+// ActionString 动作中文
+func (e *TeaAccountFreezeEvent) ActionString() string {
+	if e.Action == TeaFreezeAction_Unfreeze {
+		return "解冻"
+	}
+	return "冻结"
+}
+
+// SourceString 来源中文
+func (e *TeaAccountFreezeEvent) SourceString() string {
+	switch e.Source {
+	case TeaFreezeSource_Reconcile:
+		return "对账"
+	case TeaFreezeSource_Auto:
+		return "到期自动解冻"
+	case TeaFreezeSource_Appeal:
+		return "申诉获准"
+	}
+	return "人工"
+}
+
+// TeaFreezeRequest 冻结参数
+type TeaFreezeRequest struct {
+	OperatorUserId int // 0 表示系统操作
+	Source         string
+	Reason         string
+	EvidenceId     int
+	UnfreezeAt     *time.Time
+}
+
+// TeaUnfreezeRequest 解冻参数
+type TeaUnfreezeRequest struct {
+	OperatorUserId int
+	Source         string
+	Reason         string
+	AppealId       int
+}
+
+// TeaFreezeAppeal 星茶账户冻结申诉
+type TeaFreezeAppeal struct {
+	Id              int
+	Uuid            string
+	FreezeEventId   int // 0 表示冻结发生在有冻结记录之前
+	HolderType      string
+	HolderId        int
+	HolderName      string
+	AppellantUserId int
+	AppellantName   string
+	Statement       string
+	EvidenceId      int
+	EvidenceUuid    string
+	Status          string
+	ReviewerUserId  int
+	ReviewerName    string
+	ReviewNote      string
+	ReviewedAt      *time.Time
+	FreezeReason    string // 账户当前的冻结原因
+	CreatedAt       time.Time
+}
+
+// StatusString 申诉状态中文
+func (a *TeaFreezeAppeal) StatusString() string {
+	switch a.Status {
+	case TeaFreezeAppealStatus_Pending:
+		return "待审核"
+	case TeaFreezeAppealStatus_Approved:
+		return "已获准解冻"
+	case TeaFreezeAppealStatus_Rejected:
+		return "已驳回"
+	case TeaFreezeAppealStatus_Closed:
+		return "已结案"
+	}
+	return "未知"
+}
+
+// HolderTypeString 账户类型中文
+func (a *TeaFreezeAppeal) HolderTypeString() string {
+	if a.HolderType == TeaAccountHolderType_Team {
+		return "团队"
+	}
+	return "茶友"
+}
+
+// lockTeaAccountStatus 锁定账户行并读取当前状态
+func lockTeaAccountStatus(ctx context.Context, tx *sql.Tx, account TeaLedgerAccount) (status, reason string, err error) {
+	table, column, err := teaAccountTable(account)
+	if err != nil {
+		return "", "", err
+	}
+	err = tx.QueryRowContext(ctx, `SELECT status, COALESCE(frozen_reason, '-') FROM `+table+` WHERE `+column+` = $1 FOR UPDATE`,
+		account.HolderId).Scan(&status, &reason)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("星茶账户不存在")
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("查询星茶账户状态失败: %v", err)
+	}
+	return status, reason, nil
+}
+
+// FreezeTeaAccount 冻结星茶账户并记冻结记录；已冻结的账户再次冻结时更新原因及解冻期限
+func FreezeTeaAccount(ctx context.Context, account TeaLedgerAccount, req TeaFreezeRequest) (TeaAccountFreezeEvent, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TeaAccountFreezeEvent{}, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	event, err := freezeTeaAccountTx(ctx, tx, account, req)
+	if err != nil {
+		return event, err
+	}
+	if err = tx.Commit(); err != nil {
+		return event, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return event, nil
+}
+
+func freezeTeaAccountTx(ctx context.Context, tx *sql.Tx, account TeaLedgerAccount, req TeaFreezeRequest) (TeaAccountFreezeEvent, error) {
+	event := TeaAccountFreezeEvent{HolderType: account.HolderType, HolderId: account.HolderId, Action: TeaFreezeAction_Freeze,
+		Source: req.Source, Reason: strings.TrimSpace(req.Reason), OperatorUserId: req.OperatorUserId,
+		EvidenceId: req.EvidenceId, UnfreezeAt: req.UnfreezeAt}
+	if event.Reason == "" {
+		return event, fmt.Errorf("冻结原因不能为空")
+	}
+	if event.Source == "" {
+		event.Source = TeaFreezeSource_Manual
+	}
+	if req.UnfreezeAt != nil && !req.UnfreezeAt.After(time.Now()) {
+		return event, fmt.Errorf("自动解冻时间须晚于当前时间")
+	}
+	status, reason, err := lockTeaAccountStatus(ctx, tx, account)
+	if err != nil {
+		return event, err
+	}
+	event.PreviousStatus, event.PreviousReason = status, reason
+	table, column, _ := teaAccountTable(account)
+	if _, err = tx.ExecContext(ctx, `UPDATE `+table+` SET status = $2, frozen_reason = $3, updated_at = $4 WHERE `+column+` = $1`,
+		account.HolderId, TeaAccountStatus_Frozen, event.Reason, time.Now()); err != nil {
+		return event, fmt.Errorf("更新账户状态失败: %v", err)
+	}
+	if err = insertTeaAccountFreezeEvent(ctx, tx, &event); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+// UnfreezeTeaAccount 解冻星茶账户并记解冻记录，账户待审核的申诉随之结案
+func UnfreezeTeaAccount(ctx context.Context, account TeaLedgerAccount, req TeaUnfreezeRequest) (TeaAccountFreezeEvent, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TeaAccountFreezeEvent{}, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	event, err := unfreezeTeaAccountTx(ctx, tx, account, req)
+	if err != nil {
+		return event, err
+	}
+	if err = tx.Commit(); err != nil {
+		return event, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return event, nil
+}
+
+func unfreezeTeaAccountTx(ctx context.Context, tx *sql.Tx, account TeaLedgerAccount, req TeaUnfreezeRequest) (TeaAccountFreezeEvent, error) {
+	event := TeaAccountFreezeEvent{HolderType: account.HolderType, HolderId: account.HolderId, Action: TeaFreezeAction_Unfreeze,
+		Source: req.Source, Reason: strings.TrimSpace(req.Reason), OperatorUserId: req.OperatorUserId, AppealId: req.AppealId}
+	if event.Source == "" {
+		event.Source = TeaFreezeSource_Manual
+	}
+	status, reason, err := lockTeaAccountStatus(ctx, tx, account)
+	if err != nil {
+		return event, err
+	}
+	if status != TeaAccountStatus_Frozen {
+		return event, ErrTeaAccountNotFrozen
+	}
+	event.PreviousStatus, event.PreviousReason = status, reason
+	table, column, _ := teaAccountTable(account)
+	if _, err = tx.ExecContext(ctx, `UPDATE `+table+` SET status = $2, frozen_reason = '-', updated_at = $3 WHERE `+column+` = $1`,
+		account.HolderId, TeaAccountStatus_Normal, time.Now()); err != nil {
+		return event, fmt.Errorf("更新账户状态失败: %v", err)
+	}
+	if err = insertTeaAccountFreezeEvent(ctx, tx, &event); err != nil {
+		return event, err
+	}
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE tea.account_freeze_appeals SET status = $3, review_note = '账户已解冻', reviewed_at = $4
+		WHERE holder_type = $1 AND holder_id = $2 AND status = 'pending'`,
+		account.HolderType, account.HolderId, TeaFreezeAppealStatus_Closed, time.Now()); err != nil {
+		return event, fmt.Errorf("结案待审核申诉失败: %v", err)
+	}
+	return event, nil
+}
+
+func insertTeaAccountFreezeEvent(ctx context.Context, tx *sql.Tx, e *TeaAccountFreezeEvent) error {
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO tea.account_freeze_events (holder_type, holder_id, action, source, reason, operator_user_id,
+			evidence_id, unfreeze_at, appeal_id, previous_status, previous_reason)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0), $8, NULLIF($9, 0), $10, $11)
+		RETURNING id, uuid, created_at`,
+		e.HolderType, e.HolderId, e.Action, e.Source, e.Reason, e.OperatorUserId,
+		e.EvidenceId, e.UnfreezeAt, e.AppealId, e.PreviousStatus, e.PreviousReason).Scan(&e.Id, &e.Uuid, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("记录冻结记录失败: %v", err)
+	}
+	return nil
+}
+
+const teaFreezeEventColumns = `
+	e.id, e.uuid, e.holder_type, e.holder_id, e.action, e.source, e.reason,
+	COALESCE(e.operator_user_id, 0), COALESCE(u.name, ''), COALESCE(e.evidence_id, 0), COALESCE(ev.uuid, ''),
+	e.unfreeze_at, COALESCE(e.appeal_id, 0), e.previous_status, e.previous_reason, e.created_at
+	FROM tea.account_freeze_events e
+	LEFT JOIN users u ON u.id = e.operator_user_id
+	LEFT JOIN evidences ev ON ev.id = e.evidence_id`
+
+func scanTeaAccountFreezeEvent(row interface{ Scan(...any) error }, e *TeaAccountFreezeEvent) error {
+	return row.Scan(&e.Id, &e.Uuid, &e.HolderType, &e.HolderId, &e.Action, &e.Source, &e.Reason,
+		&e.OperatorUserId, &e.OperatorName, &e.EvidenceId, &e.EvidenceUuid,
+		&e.UnfreezeAt, &e.AppealId, &e.PreviousStatus, &e.PreviousReason, &e.CreatedAt)
+}
+
+// TeaAccountFreezeHistory 账户的冻结、解冻记录，最新的在前
+func TeaAccountFreezeHistory(ctx context.Context, account TeaLedgerAccount) ([]TeaAccountFreezeEvent, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+teaFreezeEventColumns+`
+		WHERE e.holder_type = $1 AND e.holder_id = $2
+		ORDER BY e.id DESC`, account.HolderType, account.HolderId)
+	if err != nil {
+		return nil, fmt.Errorf("查询冻结记录失败: %v", err)
+	}
+	defer rows.Close()
+	var events []TeaAccountFreezeEvent
+	for rows.Next() {
+		var e TeaAccountFreezeEvent
+		if err = scanTeaAccountFreezeEvent(rows, &e); err != nil {
+			return nil, fmt.Errorf("扫描冻结记录失败: %v", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// currentTeaAccountFreezeEventId 账户冻结中时最近一次冻结记录的id，没有记录（冻结早于冻结记录）时为0
+func currentTeaAccountFreezeEventId(ctx context.Context, tx *sql.Tx, account TeaLedgerAccount) (int, error) {
+	var id int
+	var action string
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, action FROM tea.account_freeze_events
+		WHERE holder_type = $1 AND holder_id = $2
+		ORDER BY id DESC LIMIT 1`, account.HolderType, account.HolderId).Scan(&id, &action)
+	if err == sql.ErrNoRows || (err == nil && action != TeaFreezeAction_Freeze) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询冻结记录失败: %v", err)
+	}
+	return id, nil
+}
+
+// UnfreezeDueTeaAccounts 解冻已到自动解冻期限的账户，以最近一次冻结记录的期限为准，返回解冻的账户数
+func UnfreezeDueTeaAccounts(ctx context.Context, now time.Time) (int, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT e.holder_type, e.holder_id FROM tea.account_freeze_events e
+		WHERE e.action = 'freeze' AND e.unfreeze_at IS NOT NULL AND e.unfreeze_at <= $1
+		  AND e.id = (SELECT MAX(l.id) FROM tea.account_freeze_events l
+		              WHERE l.holder_type = e.holder_type AND l.holder_id = e.holder_id)`, now)
+	if err != nil {
+		return 0, fmt.Errorf("查询到期冻结记录失败: %v", err)
+	}
+	var due []TeaLedgerAccount
+	for rows.Next() {
+		var a TeaLedgerAccount
+		if err = rows.Scan(&a.HolderType, &a.HolderId); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("扫描到期冻结记录失败: %v", err)
+		}
+		due = append(due, a)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, a := range due {
+		_, err := UnfreezeTeaAccount(ctx, a, TeaUnfreezeRequest{Source: TeaFreezeSource_Auto, Reason: "冻结期限已到"})
+		if errors.Is(err, ErrTeaAccountNotFrozen) {
+			continue
+		}
+		if err != nil {
+			return count, fmt.Errorf("自动解冻账户 %s%d 失败: %v", a.HolderType, a.HolderId, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// FileTeaFreezeAppeal 对冻结中的账户提出申诉
+func FileTeaFreezeAppeal(ctx context.Context, account TeaLedgerAccount, appellantUserId int, statement string, evidenceId int) (TeaFreezeAppeal, error) {
+	appeal := TeaFreezeAppeal{HolderType: account.HolderType, HolderId: account.HolderId, AppellantUserId: appellantUserId,
+		Statement: strings.TrimSpace(statement), EvidenceId: evidenceId, Status: TeaFreezeAppealStatus_Pending}
+	if appeal.Statement == "" {
+		return appeal, fmt.Errorf("申诉理由不能为空")
+	}
+	if utf8.RuneCountInString(appeal.Statement) > TeaFreezeAppealStatementMaxLength {
+		return appeal, fmt.Errorf("申诉理由不能超过 %d 字", TeaFreezeAppealStatementMaxLength)
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return appeal, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	status, _, err := lockTeaAccountStatus(ctx, tx, account)
+	if err != nil {
+		return appeal, err
+	}
+	if status != TeaAccountStatus_Frozen {
+		return appeal, ErrTeaAccountNotFrozen
+	}
+	var pending bool
+	if err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM tea.account_freeze_appeals WHERE holder_type = $1 AND holder_id = $2 AND status = 'pending')`,
+		account.HolderType, account.HolderId).Scan(&pending); err != nil {
+		return appeal, fmt.Errorf("查询待审核申诉失败: %v", err)
+	}
+	if pending {
+		return appeal, ErrTeaFreezeAppealPending
+	}
+	if appeal.FreezeEventId, err = currentTeaAccountFreezeEventId(ctx, tx, account); err != nil {
+		return appeal, err
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tea.account_freeze_appeals (freeze_event_id, holder_type, holder_id, appellant_user_id, statement, evidence_id)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, NULLIF($6, 0))
+		RETURNING id, uuid, created_at`,
+		appeal.FreezeEventId, appeal.HolderType, appeal.HolderId, appeal.AppellantUserId, appeal.Statement, appeal.EvidenceId).
+		Scan(&appeal.Id, &appeal.Uuid, &appeal.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "23505") || strings.Contains(err.Error(), "duplicate key") {
+			return appeal, ErrTeaFreezeAppealPending
+		}
+		return appeal, fmt.Errorf("提交申诉失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return appeal, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return appeal, nil
+}
+
+const teaFreezeAppealColumns = `
+	a.id, a.uuid, COALESCE(a.freeze_event_id, 0), a.holder_type, a.holder_id,
+	COALESCE(CASE WHEN a.holder_type = 'u' THEN hu.name ELSE ht.name END, ''),
+	a.appellant_user_id, COALESCE(au.name, ''), a.statement, COALESCE(a.evidence_id, 0), COALESCE(ev.uuid, ''),
+	a.status, COALESCE(a.reviewer_user_id, 0), COALESCE(ru.name, ''), a.review_note, a.reviewed_at,
+	COALESCE(CASE WHEN a.holder_type = 'u' THEN ua.frozen_reason ELSE ta.frozen_reason END, '-'), a.created_at
+	FROM tea.account_freeze_appeals a
+	LEFT JOIN users hu ON a.holder_type = 'u' AND hu.id = a.holder_id
+	LEFT JOIN teams ht ON a.holder_type = 't' AND ht.id = a.holder_id
+	LEFT JOIN tea.user_accounts ua ON a.holder_type = 'u' AND ua.user_id = a.holder_id
+	LEFT JOIN tea.team_accounts ta ON a.holder_type = 't' AND ta.team_id = a.holder_id
+	LEFT JOIN users au ON au.id = a.appellant_user_id
+	LEFT JOIN users ru ON ru.id = a.reviewer_user_id
+	LEFT JOIN evidences ev ON ev.id = a.evidence_id`
+
+func scanTeaFreezeAppeal(row interface{ Scan(...any) error }, a *TeaFreezeAppeal) error {
+	return row.Scan(&a.Id, &a.Uuid, &a.FreezeEventId, &a.HolderType, &a.HolderId, &a.HolderName,
+		&a.AppellantUserId, &a.AppellantName, &a.Statement, &a.EvidenceId, &a.EvidenceUuid,
+		&a.Status, &a.ReviewerUserId, &a.ReviewerName, &a.ReviewNote, &a.ReviewedAt, &a.FreezeReason, &a.CreatedAt)
+}
+
+func queryTeaFreezeAppeals(ctx context.Context, where string, args ...any) ([]TeaFreezeAppeal, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+teaFreezeAppealColumns+` `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询冻结申诉失败: %v", err)
+	}
+	defer rows.Close()
+	var appeals []TeaFreezeAppeal
+	for rows.Next() {
+		var a TeaFreezeAppeal
+		if err = scanTeaFreezeAppeal(rows, &a); err != nil {
+			return nil, fmt.Errorf("扫描冻结申诉失败: %v", err)
+		}
+		appeals = append(appeals, a)
+	}
+	return appeals, rows.Err()
+}
+
+// TeaFreezeAppealsByAccount 账户的全部申诉，最新的在前
+func TeaFreezeAppealsByAccount(ctx context.Context, account TeaLedgerAccount) ([]TeaFreezeAppeal, error) {
+	return queryTeaFreezeAppeals(ctx, `WHERE a.holder_type = $1 AND a.holder_id = $2 ORDER BY a.id DESC`,
+		account.HolderType, account.HolderId)
+}
+
+// PendingTeaFreezeAppeals 待审核申诉，先提出的在前
+func PendingTeaFreezeAppeals(ctx context.Context) ([]TeaFreezeAppeal, error) {
+	return queryTeaFreezeAppeals(ctx, `WHERE a.status = 'pending' ORDER BY a.id`)
+}
+
+// DecideTeaFreezeAppeal 审核申诉：批准即解冻账户（解冻记录关联该申诉），驳回须说明理由
+func DecideTeaFreezeAppeal(ctx context.Context, uuid string, reviewerUserId int, approve bool, note string) (TeaFreezeAppeal, error) {
+	var appeal TeaFreezeAppeal
+	note = strings.TrimSpace(note)
+	if !approve && note == "" {
+		return appeal, fmt.Errorf("驳回申诉须说明理由")
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return appeal, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, holder_type, holder_id, appellant_user_id, status
+		FROM tea.account_freeze_appeals WHERE uuid = $1 FOR UPDATE`, uuid).
+		Scan(&appeal.Id, &appeal.HolderType, &appeal.HolderId, &appeal.AppellantUserId, &appeal.Status)
+	if err == sql.ErrNoRows {
+		return appeal, ErrTeaFreezeAppealNotFound
+	}
+	if err != nil {
+		return appeal, fmt.Errorf("查询冻结申诉失败: %v", err)
+	}
+	if appeal.Status != TeaFreezeAppealStatus_Pending {
+		return appeal, ErrTeaFreezeAppealDecided
+	}
+	if appeal.AppellantUserId == reviewerUserId {
+		return appeal, ErrTeaFreezeAppealSelfReview
+	}
+
+	appeal.Status = TeaFreezeAppealStatus_Rejected
+	if approve {
+		appeal.Status = TeaFreezeAppealStatus_Approved
+	}
+	now := time.Now()
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE tea.account_freeze_appeals SET status = $2, reviewer_user_id = $3, review_note = $4, reviewed_at = $5
+		WHERE id = $1`, appeal.Id, appeal.Status, reviewerUserId, note, now); err != nil {
+		return appeal, fmt.Errorf("更新冻结申诉失败: %v", err)
+	}
+	if approve {
+		reason := "申诉获准"
+		if note != "" {
+			reason += "：" + note
+		}
+		account := TeaLedgerAccount{HolderType: appeal.HolderType, HolderId: appeal.HolderId}
+		if _, err = unfreezeTeaAccountTx(ctx, tx, account, TeaUnfreezeRequest{
+			OperatorUserId: reviewerUserId, Source: TeaFreezeSource_Appeal, Reason: reason, AppealId: appeal.Id,
+		}); err != nil {
+			return appeal, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return appeal, fmt.Errorf("提交事务失败: %v", err)
+	}
+	appeal.Uuid, appeal.ReviewerUserId, appeal.ReviewNote, appeal.ReviewedAt = uuid, reviewerUserId, note, &now
+	if appeal.HolderType == TeaAccountHolderType_Team {
+		notifyTeaFreezeAppealDecision(ctx, &appeal)
+	}
+	return appeal, nil
+}
+
+// notifyTeaFreezeAppealDecision 在团队消息盒子发布布告，告知全体成员申诉审核结果；通知失败不影响审核
+func notifyTeaFreezeAppealDecision(ctx context.Context, a *TeaFreezeAppeal) {
+	var box MessageBox
+	if err := box.GetOrCreateMessageBoxWithContext(MessageBoxTypeTeam, a.HolderId, ctx); err != nil {
+		util.Warningf("冻结申诉 %s 审核通知未发送，无法获取团队 %d 消息盒子: %v", a.Uuid, a.HolderId, err)
+		return
+	}
+	if box.AllMessagesCount() >= box.MaxCount {
+		util.Warningf("冻结申诉 %s 审核通知未发送，团队 %d 消息盒子已满", a.Uuid, a.HolderId)
+		return
+	}
+	content := "【星茶账户冻结申诉】团队星茶账户的冻结申诉已获准，账户已解冻。"
+	if a.Status == TeaFreezeAppealStatus_Rejected {
+		content = "【星茶账户冻结申诉】团队星茶账户的冻结申诉已被驳回：" + a.ReviewNote + "。账户仍处于冻结状态，可在团队星茶罐的冻结记录页面查看详情。"
+	}
+	message := Message{
+		Uuid:           Random_UUID(),
+		MessageBoxId:   box.Id,
+		SenderType:     MessageSenderTypeTeam,
+		SenderObjectId: a.ReviewerUserId,
+		ReceiverType:   MessageReceiverTypeAll,
+		ReceiverId:     UserId_None,
+		Content:        content,
+	}
+	if err := message.CreateWithContext(ctx); err != nil {
+		util.Warningf("冻结申诉 %s 审核通知未发送: %v", a.Uuid, err)
+		return
+	}
+	box.Count++
+	if err := box.UpdateWithContext(ctx); err != nil {
+		util.Warningf("更新团队 %d 消息盒子计数失败: %v", a.HolderId, err)
+	}
+}
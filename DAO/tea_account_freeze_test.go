@@ -0,0 +1,38 @@
+package dao
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTeaAccountFreezeStrings(t *testing.T) {
+	e := TeaAccountFreezeEvent{Action: TeaFreezeAction_Unfreeze, Source: TeaFreezeSource_Appeal}
+	if e.ActionString() != "解冻" || e.SourceString() != "申诉获准" {
+		t.Errorf("解冻记录 = %s/%s", e.ActionString(), e.SourceString())
+	}
+	e = TeaAccountFreezeEvent{Action: TeaFreezeAction_Freeze, Source: TeaFreezeSource_Reconcile}
+	if e.ActionString() != "冻结" || e.SourceString() != "对账" {
+		t.Errorf("冻结记录 = %s/%s", e.ActionString(), e.SourceString())
+	}
+	a := TeaFreezeAppeal{HolderType: TeaAccountHolderType_Team, Status: TeaFreezeAppealStatus_Closed}
+	if a.HolderTypeString() != "团队" || a.StatusString() != "已结案" {
+		t.Errorf("申诉 = %s/%s", a.HolderTypeString(), a.StatusString())
+	}
+}
+
+// 参数校验在访问数据库之前完成
+func TestTeaFreezeAppealValidation(t *testing.T) {
+	ctx := context.Background()
+	account := TeaUserLedgerAccount(1)
+	if _, err := FileTeaFreezeAppeal(ctx, account, 1, "  ", 0); err == nil {
+		t.Error("空申诉理由应被拒绝")
+	}
+	long := strings.Repeat("茶", TeaFreezeAppealStatementMaxLength+1)
+	if _, err := FileTeaFreezeAppeal(ctx, account, 1, long, 0); err == nil {
+		t.Error("超长申诉理由应被拒绝")
+	}
+	if _, err := DecideTeaFreezeAppeal(ctx, "x", 2, false, " "); err == nil {
+		t.Error("驳回未说明理由应被拒绝")
+	}
+}
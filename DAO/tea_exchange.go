@@ -0,0 +1,646 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+茶庄星茶兑换（tea.exchange_orders）：
+1、购买：用户或团队核心成员按克数下单，向支付渠道创建收款单（pending_payment）；
+   渠道确认到账后订单为已付款（paid）；另一位茶博士/船长（不能是下单人）审核通过后，在同一事务中给账户加余额并记账（系统发放），订单完成（completed）；
+   审核否决时先提交否决（rejected），再向渠道全额退款，成功记下退款时间，失败记下原因，可重试；
+   未付款的订单可由下单人撤回（cancelled）。
+2、兑现：下单时锁定兑现数量（pending_review）；审核通过后扣减余额与锁定并记账（兑现），订单为已批准（approved），
+   随即通过渠道付款，成功后完成（completed），失败保留 approved 及失败原因，可重试付款；
+   审核否决或下单人撤回时解锁（rejected / cancelled）。
+3、幂等：入账、扣减都以订单状态条件更新（WHERE status = 原状态）与余额变动同事务提交，重复审核不会重复入账；
+   渠道收款单号、付款单号按渠道唯一，渠道侧以订单uuid为幂等键。
+4、价格：1元/克，即 10 毫克 = 1 分，数量须为 10 毫克的整数倍。
+*/
+
+// 兑换订单类型
+const (
+	TeaExchangeKind_Purchase = "purchase" // 购买（发行星茶）
+	TeaExchangeKind_Redeem   = "redeem"   // 兑现（回收星茶）
+)
+
+// 兑换订单状态
+const (
+	TeaExchangeStatus_PendingPayment = "pending_payment" // 购买：等待付款
+	TeaExchangeStatus_Paid           = "paid"            // 购买：已到账，待审核
+	TeaExchangeStatus_PendingReview  = "pending_review"  // 兑现：已锁定，待审核
+	TeaExchangeStatus_Approved       = "approved"        // 兑现：已扣减，待渠道付款
+	TeaExchangeStatus_Completed      = "completed"       // 已完成
+	TeaExchangeStatus_Rejected       = "rejected"        // 审核否决
+	TeaExchangeStatus_Cancelled      = "cancelled"       // 下单人撤回
+)
+
+// TeaMilligramsPerFen 1元/克，即每分对应10毫克
+const TeaMilligramsPerFen = 10
+
+var (
+	ErrTeaExchangeOrderNotFound  = errors.New("星茶兑换订单不存在")
+	ErrTeaExchangeOrderProcessed = errors.New("星茶兑换订单已处理")
+	ErrTeaExchangeSelfReview     = errors.New("不能审核自己提交的兑换订单")
+	ErrTeaExchangeRefundFailed   = errors.New("订单已否决，但渠道退款未成功，可稍后重试退款")
+)
+
+// TeaExchangeOrder 星茶兑换订单
+type TeaExchangeOrder struct {
+	Id                  int
+	Uuid                string
+	Kind                string
+	HolderType          string
+	HolderId            int
+	RequesterUserId     int
+	AmountMilligrams    int64
+	AmountFen           int64
+	Provider            string
+	ProviderReference   sql.NullString
+	PaymentInstructions string
+	PayoutAccount       string
+	Status              string
+	ReviewerUserId      sql.NullInt64
+	ReviewNote          string
+	LastError           string
+	PaidAt              *time.Time
+	ReviewedAt          *time.Time
+	CompletedAt         *time.Time
+	RefundedAt          *time.Time // 否决已付款的购买订单后渠道退款成功的时间
+	CreatedAt           time.Time
+	UpdatedAt           *time.Time
+}
+
+// Account 订单所属的星茶账户
+func (o *TeaExchangeOrder) Account() TeaLedgerAccount {
+	return TeaLedgerAccount{HolderType: o.HolderType, HolderId: o.HolderId}
+}
+
+// KindString 订单类型中文描述
+func (o *TeaExchangeOrder) KindString() string {
+	if o.Kind == TeaExchangeKind_Redeem {
+		return "兑现"
+	}
+	return "购买"
+}
+
+// StatusString 订单状态中文描述
+func (o *TeaExchangeOrder) StatusString() string {
+	switch o.Status {
+	case TeaExchangeStatus_PendingPayment:
+		return "待付款"
+	case TeaExchangeStatus_Paid:
+		return "已付款待审核"
+	case TeaExchangeStatus_PendingReview:
+		return "待审核"
+	case TeaExchangeStatus_Approved:
+		return "待付款给申请人"
+	case TeaExchangeStatus_Completed:
+		return "已完成"
+	case TeaExchangeStatus_Rejected:
+		if o.RefundPending() {
+			return "已否决待退款"
+		}
+		return "已否决"
+	case TeaExchangeStatus_Cancelled:
+		return "已撤回"
+	default:
+		return "未知状态"
+	}
+}
+
+// RefundPending 已否决的已付款购买订单，尚未退款成功
+func (o *TeaExchangeOrder) RefundPending() bool {
+	return o.Kind == TeaExchangeKind_Purchase && o.Status == TeaExchangeStatus_Rejected && o.PaidAt != nil && o.RefundedAt == nil
+}
+
+// AmountYuan 金额（元），两位小数
+func (o *TeaExchangeOrder) AmountYuan() string {
+	return fmt.Sprintf("%d.%02d", o.AmountFen/100, o.AmountFen%100)
+}
+
+// TeaExchangeFen 星茶数量对应的人民币金额（分）
+func TeaExchangeFen(amountMg int64) (int64, error) {
+	if amountMg <= 0 {
+		return 0, fmt.Errorf("星茶数量必须大于0")
+	}
+	if amountMg%TeaMilligramsPerFen != 0 {
+		return 0, fmt.Errorf("星茶数量须为%d毫克的整数倍", TeaMilligramsPerFen)
+	}
+	return amountMg / TeaMilligramsPerFen, nil
+}
+
+const teaExchangeOrderColumns = `id, uuid, kind, holder_type, holder_id, requester_user_id, amount_milligrams, amount_fen,
+	provider, provider_reference, payment_instructions, payout_account, status, reviewer_user_id, review_note, last_error,
+	paid_at, reviewed_at, completed_at, refunded_at, created_at, updated_at`
+
+func scanTeaExchangeOrder(row interface{ Scan(...any) error }) (TeaExchangeOrder, error) {
+	var o TeaExchangeOrder
+	err := row.Scan(&o.Id, &o.Uuid, &o.Kind, &o.HolderType, &o.HolderId, &o.RequesterUserId, &o.AmountMilligrams, &o.AmountFen,
+		&o.Provider, &o.ProviderReference, &o.PaymentInstructions, &o.PayoutAccount, &o.Status, &o.ReviewerUserId, &o.ReviewNote, &o.LastError,
+		&o.PaidAt, &o.ReviewedAt, &o.CompletedAt, &o.RefundedAt, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+// GetTeaExchangeOrderByUuid 按uuid读取兑换订单
+func GetTeaExchangeOrderByUuid(ctx context.Context, uuid string) (TeaExchangeOrder, error) {
+	o, err := scanTeaExchangeOrder(DB.QueryRowContext(ctx,
+		`SELECT `+teaExchangeOrderColumns+` FROM tea.exchange_orders WHERE uuid = $1`, uuid))
+	if err == sql.ErrNoRows {
+		return o, ErrTeaExchangeOrderNotFound
+	}
+	return o, err
+}
+
+// lockTeaExchangeOrderTx 在事务中锁定并读取兑换订单
+func lockTeaExchangeOrderTx(ctx context.Context, tx *sql.Tx, uuid string) (TeaExchangeOrder, error) {
+	o, err := scanTeaExchangeOrder(tx.QueryRowContext(ctx,
+		`SELECT `+teaExchangeOrderColumns+` FROM tea.exchange_orders WHERE uuid = $1 FOR UPDATE`, uuid))
+	if err == sql.ErrNoRows {
+		return o, ErrTeaExchangeOrderNotFound
+	}
+	return o, err
+}
+
+// TeaExchangeOrdersByHolder 账户的兑换订单（按时间倒序）
+func TeaExchangeOrdersByHolder(ctx context.Context, account TeaLedgerAccount, limit int) ([]TeaExchangeOrder, error) {
+	return queryTeaExchangeOrders(ctx, `SELECT `+teaExchangeOrderColumns+` FROM tea.exchange_orders
+		WHERE holder_type = $1 AND holder_id = $2 ORDER BY id DESC LIMIT $3`,
+		account.HolderType, account.HolderId, limit)
+}
+
+// TeaExchangeOrdersAwaitingOperator 等待茶博士处理的订单：待付款、待审核、待付款给申请人、否决后待退款
+func TeaExchangeOrdersAwaitingOperator(ctx context.Context) ([]TeaExchangeOrder, error) {
+	return queryTeaExchangeOrders(ctx, `SELECT `+teaExchangeOrderColumns+` FROM tea.exchange_orders
+		WHERE status IN ($1, $2, $3, $4)
+			OR (kind = $5 AND status = $6 AND paid_at IS NOT NULL AND refunded_at IS NULL)
+		ORDER BY id`,
+		TeaExchangeStatus_PendingPayment, TeaExchangeStatus_Paid, TeaExchangeStatus_PendingReview, TeaExchangeStatus_Approved,
+		TeaExchangeKind_Purchase, TeaExchangeStatus_Rejected)
+}
+
+func queryTeaExchangeOrders(ctx context.Context, query string, args ...any) ([]TeaExchangeOrder, error) {
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询星茶兑换订单失败: %v", err)
+	}
+	defer rows.Close()
+	var orders []TeaExchangeOrder
+	for rows.Next() {
+		o, err := scanTeaExchangeOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取星茶兑换订单失败: %v", err)
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// ensureTeaLedgerAccount 确保用户或团队星茶账户存在
+func ensureTeaLedgerAccount(account TeaLedgerAccount) error {
+	switch account.HolderType {
+	case TeaAccountHolderType_User:
+		return TeaUserEnsureAccountExists(account.HolderId)
+	case TeaAccountHolderType_Team:
+		if account.HolderId == TeamIdFreelancer {
+			return fmt.Errorf("自由人团队没有星茶账户")
+		}
+		return EnsureTeaTeamAccountExists(account.HolderId)
+	}
+	return fmt.Errorf("未知的星茶账户类型: %s", account.HolderType)
+}
+
+// teaAccountTable 账户所在表及主键列
+func teaAccountTable(account TeaLedgerAccount) (table, column string, err error) {
+	switch account.HolderType {
+	case TeaAccountHolderType_User:
+		return "tea.user_accounts", "user_id", nil
+	case TeaAccountHolderType_Team:
+		return "tea.team_accounts", "team_id", nil
+	}
+	return "", "", fmt.Errorf("未知的星茶账户类型: %s", account.HolderType)
+}
+
+// CreateTeaPurchaseOrder 创建购买订单并向支付渠道发起收款
+func CreateTeaPurchaseOrder(ctx context.Context, account TeaLedgerAccount, requesterUserId int, amountMg int64, providerName string) (TeaExchangeOrder, error) {
+	var order TeaExchangeOrder
+	fen, err := TeaExchangeFen(amountMg)
+	if err != nil {
+		return order, err
+	}
+	provider, err := util.GetPaymentProvider(providerName)
+	if err != nil {
+		return order, err
+	}
+	if err = ensureTeaLedgerAccount(account); err != nil {
+		return order, err
+	}
+
+	order, err = scanTeaExchangeOrder(DB.QueryRowContext(ctx, `
+		INSERT INTO tea.exchange_orders (kind, holder_type, holder_id, requester_user_id, amount_milligrams, amount_fen, provider, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+teaExchangeOrderColumns,
+		TeaExchangeKind_Purchase, account.HolderType, account.HolderId, requesterUserId, amountMg, fen, provider.Name(), TeaExchangeStatus_PendingPayment))
+	if err != nil {
+		return order, fmt.Errorf("创建购买订单失败: %v", err)
+	}
+
+	intent, err := provider.CreatePayment(order.Uuid, fen)
+	if err != nil {
+		DB.ExecContext(ctx, `UPDATE tea.exchange_orders SET status = $2, last_error = $3 WHERE id = $1`,
+			order.Id, TeaExchangeStatus_Cancelled, err.Error())
+		return order, fmt.Errorf("支付渠道创建收款单失败: %v", err)
+	}
+	_, err = DB.ExecContext(ctx, `
+		UPDATE tea.exchange_orders SET provider_reference = $2, payment_instructions = $3 WHERE id = $1`,
+		order.Id, intent.Reference, intent.Instructions)
+	if err != nil {
+		return order, fmt.Errorf("保存收款单号失败: %v", err)
+	}
+	order.ProviderReference = sql.NullString{String: intent.Reference, Valid: true}
+	order.PaymentInstructions = intent.Instructions
+	return order, nil
+}
+
+// SyncTeaPurchasePayment 向支付渠道查询购买订单是否到账，到账则标记为已付款，返回最新订单
+func SyncTeaPurchasePayment(ctx context.Context, uuid string) (TeaExchangeOrder, error) {
+	order, err := GetTeaExchangeOrderByUuid(ctx, uuid)
+	if err != nil {
+		return order, err
+	}
+	if order.Kind != TeaExchangeKind_Purchase || order.Status != TeaExchangeStatus_PendingPayment || !order.ProviderReference.Valid {
+		return order, nil
+	}
+	provider, err := util.GetPaymentProvider(order.Provider)
+	if err != nil {
+		return order, err
+	}
+	status, err := provider.QueryPayment(order.ProviderReference.String)
+	if err != nil {
+		return order, fmt.Errorf("查询渠道收款状态失败: %v", err)
+	}
+	if status != util.PaymentStatusPaid {
+		return order, nil
+	}
+	_, err = DB.ExecContext(ctx, `
+		UPDATE tea.exchange_orders SET status = $2, paid_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $3`,
+		order.Id, TeaExchangeStatus_Paid, TeaExchangeStatus_PendingPayment)
+	if err != nil {
+		return order, fmt.Errorf("更新订单付款状态失败: %v", err)
+	}
+	return GetTeaExchangeOrderByUuid(ctx, uuid)
+}
+
+// CreateTeaRedeemOrder 创建兑现订单，同时锁定兑现数量
+func CreateTeaRedeemOrder(ctx context.Context, account TeaLedgerAccount, requesterUserId int, amountMg int64, providerName, payoutAccount string) (TeaExchangeOrder, error) {
+	var order TeaExchangeOrder
+	fen, err := TeaExchangeFen(amountMg)
+	if err != nil {
+		return order, err
+	}
+	if payoutAccount == "" {
+		return order, fmt.Errorf("收款账户不能为空")
+	}
+	provider, err := util.GetPaymentProvider(providerName)
+	if err != nil {
+		return order, err
+	}
+	if err = ensureTeaLedgerAccount(account); err != nil {
+		return order, err
+	}
+	table, column, err := teaAccountTable(account)
+	if err != nil {
+		return order, err
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return order, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var balance, locked int64
+	var status string
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT balance_milligrams, locked_balance_milligrams, status FROM %s WHERE %s = $1 FOR UPDATE`, table, column),
+		account.HolderId).Scan(&balance, &locked, &status)
+	if err != nil {
+		return order, fmt.Errorf("查询星茶账户失败: %v", err)
+	}
+	if status == TeaAccountStatus_Frozen {
+		return order, fmt.Errorf("星茶账户已冻结")
+	}
+	if balance-locked < amountMg {
+		return order, fmt.Errorf("星茶可用余额不足，可用余额: %d 毫克，需要: %d 毫克", balance-locked, amountMg)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE %s SET locked_balance_milligrams = locked_balance_milligrams + $1, updated_at = $2 WHERE %s = $3`, table, column),
+		amountMg, time.Now(), account.HolderId)
+	if err != nil {
+		return order, fmt.Errorf("锁定兑现数量失败: %v", err)
+	}
+	order, err = scanTeaExchangeOrder(tx.QueryRowContext(ctx, `
+		INSERT INTO tea.exchange_orders (kind, holder_type, holder_id, requester_user_id, amount_milligrams, amount_fen, provider, payout_account, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+teaExchangeOrderColumns,
+		TeaExchangeKind_Redeem, account.HolderType, account.HolderId, requesterUserId, amountMg, fen, provider.Name(), payoutAccount, TeaExchangeStatus_PendingReview))
+	if err != nil {
+		return order, fmt.Errorf("创建兑现订单失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return order, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return order, nil
+}
+
+// unlockTeaRedeemTx 在事务中解锁兑现订单锁定的星茶
+func unlockTeaRedeemTx(ctx context.Context, tx *sql.Tx, order TeaExchangeOrder) error {
+	table, column, err := teaAccountTable(order.Account())
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE %s SET locked_balance_milligrams = locked_balance_milligrams - $1, updated_at = $2
+		WHERE %s = $3 AND locked_balance_milligrams >= $1`, table, column),
+		order.AmountMilligrams, time.Now(), order.HolderId)
+	if err != nil {
+		return fmt.Errorf("解锁兑现数量失败: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("账户锁定余额不足，无法解锁 %d 毫克", order.AmountMilligrams)
+	}
+	return nil
+}
+
+// CancelTeaExchangeOrder 下单人撤回未付款的购买订单或待审核的兑现订单
+func CancelTeaExchangeOrder(ctx context.Context, uuid string, userId int) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	order, err := lockTeaExchangeOrderTx(ctx, tx, uuid)
+	if err != nil {
+		return err
+	}
+	if order.RequesterUserId != userId {
+		return fmt.Errorf("只有下单人可以撤回订单")
+	}
+	switch {
+	case order.Kind == TeaExchangeKind_Purchase && order.Status == TeaExchangeStatus_PendingPayment:
+	case order.Kind == TeaExchangeKind_Redeem && order.Status == TeaExchangeStatus_PendingReview:
+		if err = unlockTeaRedeemTx(ctx, tx, order); err != nil {
+			return err
+		}
+	default:
+		return ErrTeaExchangeOrderProcessed
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE tea.exchange_orders SET status = $2 WHERE id = $1`, order.Id, TeaExchangeStatus_Cancelled)
+	if err != nil {
+		return fmt.Errorf("撤回订单失败: %v", err)
+	}
+	return tx.Commit()
+}
+
+// ApproveTeaExchangeOrder 茶博士/船长审核通过：购买订单入账；兑现订单扣减并向渠道付款
+func ApproveTeaExchangeOrder(ctx context.Context, uuid string, reviewerUserId int, note string) (TeaExchangeOrder, error) {
+	if note == "" {
+		note = "-"
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TeaExchangeOrder{}, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	order, err := lockTeaExchangeOrderTx(ctx, tx, uuid)
+	if err != nil {
+		return order, err
+	}
+	// 下单人审核自己的订单等于自行发行星茶，须由另一位茶博士/船长审核
+	if order.RequesterUserId == reviewerUserId {
+		return order, ErrTeaExchangeSelfReview
+	}
+	table, column, err := teaAccountTable(order.Account())
+	if err != nil {
+		return order, err
+	}
+	now := time.Now()
+
+	switch {
+	case order.Kind == TeaExchangeKind_Purchase && order.Status == TeaExchangeStatus_Paid:
+		if err = teaSystemGrantTx(ctx, tx, order.Account(), order.AmountMilligrams, reviewerUserId,
+			"exchange_orders", order.Id, "茶庄售出星茶"); err != nil {
+			return order, err
+		}
+		order.Status = TeaExchangeStatus_Completed
+		order.CompletedAt = &now
+
+	case order.Kind == TeaExchangeKind_Redeem && order.Status == TeaExchangeStatus_PendingReview:
+		result, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %s SET balance_milligrams = balance_milligrams - $1,
+				locked_balance_milligrams = locked_balance_milligrams - $1, updated_at = $2
+			WHERE %s = $3 AND locked_balance_milligrams >= $1 AND balance_milligrams >= $1`, table, column),
+			order.AmountMilligrams, now, order.HolderId)
+		if err != nil {
+			return order, fmt.Errorf("扣减账户余额失败: %v", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return order, fmt.Errorf("账户余额或锁定余额不足 %d 毫克", order.AmountMilligrams)
+		}
+		if err = postTeaLedgerTx(tx, teaLedgerPosting{
+			Type:             TeaLedgerType_Withdraw,
+			From:             order.Account(),
+			To:               TeaSystemLedgerAccount(),
+			AmountMilligrams: order.AmountMilligrams,
+			ReferenceTable:   "exchange_orders",
+			ReferenceId:      order.Id,
+			InitiatorUserId:  reviewerUserId,
+			Notes:            "茶庄回购星茶",
+		}); err != nil {
+			return order, err
+		}
+		order.Status = TeaExchangeStatus_Approved
+
+	default:
+		return order, ErrTeaExchangeOrderProcessed
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tea.exchange_orders SET status = $2, reviewer_user_id = $3, review_note = $4, reviewed_at = $5, completed_at = $6
+		WHERE id = $1`,
+		order.Id, order.Status, reviewerUserId, note, now, order.CompletedAt)
+	if err != nil {
+		return order, fmt.Errorf("更新订单状态失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return order, fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	if order.Kind == TeaExchangeKind_Redeem {
+		return PayoutTeaExchangeOrder(ctx, uuid)
+	}
+	return order, nil
+}
+
+// PayoutTeaExchangeOrder 向渠道付款给已批准的兑现订单申请人，失败时记录原因，可重试
+func PayoutTeaExchangeOrder(ctx context.Context, uuid string) (TeaExchangeOrder, error) {
+	order, err := GetTeaExchangeOrderByUuid(ctx, uuid)
+	if err != nil {
+		return order, err
+	}
+	if order.Kind != TeaExchangeKind_Redeem || order.Status != TeaExchangeStatus_Approved {
+		return order, ErrTeaExchangeOrderProcessed
+	}
+	provider, err := util.GetPaymentProvider(order.Provider)
+	if err != nil {
+		return order, err
+	}
+	reference, err := provider.Payout(order.Uuid, order.AmountFen, order.PayoutAccount)
+	if err != nil {
+		DB.ExecContext(ctx, `UPDATE tea.exchange_orders SET last_error = $2 WHERE id = $1`, order.Id, err.Error())
+		return order, fmt.Errorf("渠道付款失败: %v", err)
+	}
+	_, err = DB.ExecContext(ctx, `
+		UPDATE tea.exchange_orders SET status = $2, provider_reference = $3, completed_at = CURRENT_TIMESTAMP, last_error = '-'
+		WHERE id = $1 AND status = $4`,
+		order.Id, TeaExchangeStatus_Completed, reference, TeaExchangeStatus_Approved)
+	if err != nil {
+		return order, fmt.Errorf("更新订单付款状态失败: %v", err)
+	}
+	return GetTeaExchangeOrderByUuid(ctx, uuid)
+}
+
+// RejectTeaExchangeOrder 茶博士/船长否决：兑现订单解锁；已付款的购买订单先提交否决，再向渠道退款。
+// 退款失败时订单仍是已否决，返回 ErrTeaExchangeRefundFailed，由 RefundTeaExchangeOrder 重试
+func RejectTeaExchangeOrder(ctx context.Context, uuid string, reviewerUserId int, note string) error {
+	if note == "" {
+		return fmt.Errorf("否决原因不能为空")
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	order, err := lockTeaExchangeOrderTx(ctx, tx, uuid)
+	if err != nil {
+		return err
+	}
+	switch {
+	case order.Kind == TeaExchangeKind_Purchase && order.Status == TeaExchangeStatus_PendingPayment:
+	case order.Kind == TeaExchangeKind_Purchase && order.Status == TeaExchangeStatus_Paid:
+	case order.Kind == TeaExchangeKind_Redeem && order.Status == TeaExchangeStatus_PendingReview:
+		if err = unlockTeaRedeemTx(ctx, tx, order); err != nil {
+			return err
+		}
+	default:
+		return ErrTeaExchangeOrderProcessed
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE tea.exchange_orders SET status = $2, reviewer_user_id = $3, review_note = $4, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $5`,
+		order.Id, TeaExchangeStatus_Rejected, reviewerUserId, note, order.Status)
+	if err != nil {
+		return fmt.Errorf("更新订单状态失败: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrTeaExchangeOrderProcessed
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	// 否决已生效，此后订单不会再被审核通过或重复否决，退款只需保证最终完成
+	if order.Kind == TeaExchangeKind_Purchase && order.Status == TeaExchangeStatus_Paid {
+		_, err = RefundTeaExchangeOrder(ctx, uuid)
+		return err
+	}
+	return nil
+}
+
+// RefundTeaExchangeOrder 向渠道退回已否决的已付款购买订单，失败时记录原因，可重试；已退款的订单直接返回
+func RefundTeaExchangeOrder(ctx context.Context, uuid string) (TeaExchangeOrder, error) {
+	order, err := GetTeaExchangeOrderByUuid(ctx, uuid)
+	if err != nil {
+		return order, err
+	}
+	if order.Kind != TeaExchangeKind_Purchase || order.Status != TeaExchangeStatus_Rejected || order.PaidAt == nil {
+		return order, ErrTeaExchangeOrderProcessed
+	}
+	if order.RefundedAt != nil {
+		return order, nil
+	}
+	provider, err := util.GetPaymentProvider(order.Provider)
+	if err != nil {
+		DB.ExecContext(ctx, `UPDATE tea.exchange_orders SET last_error = $2 WHERE id = $1`, order.Id, err.Error())
+		return order, fmt.Errorf("%w: %v", ErrTeaExchangeRefundFailed, err)
+	}
+	if err = provider.Refund(order.Uuid, order.ProviderReference.String); err != nil {
+		DB.ExecContext(ctx, `UPDATE tea.exchange_orders SET last_error = $2 WHERE id = $1`, order.Id, err.Error())
+		return order, fmt.Errorf("%w: %v", ErrTeaExchangeRefundFailed, err)
+	}
+	_, err = DB.ExecContext(ctx, `
+		UPDATE tea.exchange_orders SET refunded_at = CURRENT_TIMESTAMP, last_error = '-'
+		WHERE id = $1 AND refunded_at IS NULL`, order.Id)
+	if err != nil {
+		return order, fmt.Errorf("记录退款时间失败: %v", err)
+	}
+	return GetTeaExchangeOrderByUuid(ctx, uuid)
+}
+
+// TeaSupplyReport 星茶发行总量报表（毫克）
+type TeaSupplyReport struct {
+	IssuedMilligrams          int64 // 系统账户净发行量（记账流水：发放、购买、期初余额减去扣除、兑现）
+	PurchasedMilligrams       int64 // 已完成购买订单合计
+	RedeemedMilligrams        int64 // 已扣减的兑现订单合计（含待付款给申请人）
+	UserBalanceMilligrams     int64 // 用户账户余额合计
+	TeamBalanceMilligrams     int64 // 团队账户余额合计
+	LockedMilligrams          int64 // 用户、团队锁定余额合计
+	PendingPurchaseMilligrams int64 // 已付款待审核的购买
+	PendingRedeemMilligrams   int64 // 待审核的兑现
+	PayoutPendingFen          int64 // 已批准待付款给申请人的金额（分）
+}
+
+// CirculatingMilligrams 流通量：用户与团队账户余额合计
+func (r *TeaSupplyReport) CirculatingMilligrams() int64 {
+	return r.UserBalanceMilligrams + r.TeamBalanceMilligrams
+}
+
+// Balanced 流通量是否等于净发行量
+func (r *TeaSupplyReport) Balanced() bool {
+	return r.CirculatingMilligrams() == r.IssuedMilligrams
+}
+
+// GetTeaSupplyReport 统计星茶发行总量、流通量及待处理兑换订单
+func GetTeaSupplyReport(ctx context.Context) (TeaSupplyReport, error) {
+	var r TeaSupplyReport
+	err := DB.QueryRowContext(ctx, `
+		SELECT
+			COALESCE((SELECT SUM(CASE WHEN direction = 'debit' THEN amount_milligrams ELSE -amount_milligrams END)
+				FROM tea.ledger_entries WHERE holder_type = 's'), 0),
+			COALESCE((SELECT SUM(amount_milligrams) FROM tea.exchange_orders WHERE kind = $1 AND status = $3), 0),
+			COALESCE((SELECT SUM(amount_milligrams) FROM tea.exchange_orders WHERE kind = $2 AND status IN ($3, $4)), 0),
+			COALESCE((SELECT SUM(balance_milligrams) FROM tea.user_accounts), 0),
+			COALESCE((SELECT SUM(balance_milligrams) FROM tea.team_accounts), 0),
+			COALESCE((SELECT SUM(locked_balance_milligrams) FROM tea.user_accounts), 0)
+				+ COALESCE((SELECT SUM(locked_balance_milligrams) FROM tea.team_accounts), 0),
+			COALESCE((SELECT SUM(amount_milligrams) FROM tea.exchange_orders WHERE kind = $1 AND status = $5), 0),
+			COALESCE((SELECT SUM(amount_milligrams) FROM tea.exchange_orders WHERE kind = $2 AND status = $6), 0),
+			COALESCE((SELECT SUM(amount_fen) FROM tea.exchange_orders WHERE kind = $2 AND status = $4), 0)`,
+		TeaExchangeKind_Purchase, TeaExchangeKind_Redeem, TeaExchangeStatus_Completed, TeaExchangeStatus_Approved,
+		TeaExchangeStatus_Paid, TeaExchangeStatus_PendingReview).
+		Scan(&r.IssuedMilligrams, &r.PurchasedMilligrams, &r.RedeemedMilligrams, &r.UserBalanceMilligrams, &r.TeamBalanceMilligrams,
+			&r.LockedMilligrams, &r.PendingPurchaseMilligrams, &r.PendingRedeemMilligrams, &r.PayoutPendingFen)
+	if err != nil {
+		return r, fmt.Errorf("统计星茶发行总量失败: %v", err)
+	}
+	return r, nil
+}
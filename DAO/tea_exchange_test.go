@@ -0,0 +1,179 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	util "teachat/Util"
+	"testing"
+	"time"
+)
+
+func TestTeaExchangeFen(t *testing.T) {
+	cases := []struct {
+		mg      int64
+		fen     int64
+		wantErr bool
+	}{
+		{mg: 10, fen: 1},
+		{mg: 1000, fen: 100},
+		{mg: 123450, fen: 12345},
+		{mg: 0, wantErr: true},
+		{mg: -10, wantErr: true},
+		{mg: 15, wantErr: true},
+	}
+	for _, c := range cases {
+		fen, err := TeaExchangeFen(c.mg)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("TeaExchangeFen(%d) 应报错", c.mg)
+			}
+			continue
+		}
+		if err != nil || fen != c.fen {
+			t.Errorf("TeaExchangeFen(%d) = %d, %v, want %d", c.mg, fen, err, c.fen)
+		}
+	}
+}
+
+func TestTeaExchangeOrderAmountYuan(t *testing.T) {
+	o := TeaExchangeOrder{AmountFen: 12305}
+	if got := o.AmountYuan(); got != "123.05" {
+		t.Fatalf("AmountYuan = %q", got)
+	}
+}
+
+func TestTeaSupplyReportBalanced(t *testing.T) {
+	r := TeaSupplyReport{IssuedMilligrams: 1500, UserBalanceMilligrams: 1000, TeamBalanceMilligrams: 500}
+	if !r.Balanced() {
+		t.Fatal("流通量等于净发行量时应平衡")
+	}
+	r.TeamBalanceMilligrams = 400
+	if r.Balanced() {
+		t.Fatal("流通量少于净发行量时不应平衡")
+	}
+}
+
+func TestLocalPaymentProviderIdempotent(t *testing.T) {
+	p := util.NewLocalPaymentProvider()
+	a, err := p.CreatePayment("order-1", 100)
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	b, _ := p.CreatePayment("order-1", 100)
+	if a.Reference != b.Reference {
+		t.Fatalf("同一订单重复创建收款单应返回同一单号: %s != %s", a.Reference, b.Reference)
+	}
+	if status, _ := p.QueryPayment(a.Reference); status != util.PaymentStatusPending {
+		t.Fatalf("新收款单状态 = %s", status)
+	}
+	if err = p.MarkPaid(a.Reference); err != nil {
+		t.Fatalf("MarkPaid: %v", err)
+	}
+	if status, _ := p.QueryPayment(a.Reference); status != util.PaymentStatusPaid {
+		t.Fatalf("模拟到账后状态 = %s", status)
+	}
+	if err = p.Refund("order-1", a.Reference); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if err = p.Refund("order-1", a.Reference); err != nil {
+		t.Fatalf("同一订单重复退款应直接成功: %v", err)
+	}
+	if status, _ := p.QueryPayment(a.Reference); status != util.PaymentStatusClosed {
+		t.Fatalf("退款后状态 = %s", status)
+	}
+	x, _ := p.Payout("order-2", 100, "acct")
+	y, _ := p.Payout("order-2", 100, "acct")
+	if x == "" || x != y {
+		t.Fatalf("同一订单重复付款应返回同一单号: %s, %s", x, y)
+	}
+}
+
+func TestApproveTeaExchangeOrderRejectsRequester(t *testing.T) {
+	requireDB(t)
+	util.RegisterPaymentProvider(util.NewLocalPaymentProvider())
+	user := User{Name: "兑换测试茶友", Email: "exchange_test_" + Random_UUID()[:8] + "@example.com", Password: "Exchange-test-123"}
+	if err := user.Create(); err != nil {
+		t.Fatalf("创建茶友失败: %v", err)
+	}
+	ctx := context.Background()
+	account := TeaUserLedgerAccount(user.Id)
+	order, err := CreateTeaPurchaseOrder(ctx, account, user.Id, 1000, util.LocalPaymentProviderName)
+	if err != nil {
+		t.Fatalf("CreateTeaPurchaseOrder: %v", err)
+	}
+	provider, _ := util.GetPaymentProvider(util.LocalPaymentProviderName)
+	if err = provider.(*util.LocalPaymentProvider).MarkPaid(order.ProviderReference.String); err != nil {
+		t.Fatalf("MarkPaid: %v", err)
+	}
+	if order, err = SyncTeaPurchasePayment(ctx, order.Uuid); err != nil || order.Status != TeaExchangeStatus_Paid {
+		t.Fatalf("SyncTeaPurchasePayment = %s, %v", order.Status, err)
+	}
+
+	if _, err = ApproveTeaExchangeOrder(ctx, order.Uuid, user.Id, ""); !errors.Is(err, ErrTeaExchangeSelfReview) {
+		t.Fatalf("下单人审核自己的订单应被拒绝，得到 %v", err)
+	}
+	after, err := GetTeaExchangeOrderByUuid(ctx, order.Uuid)
+	if err != nil || after.Status != TeaExchangeStatus_Paid {
+		t.Fatalf("拒绝后订单状态 = %s, %v，应保持已付款", after.Status, err)
+	}
+	a, err := GetTeaAccountByUserId(user.Id)
+	if err != nil || a.BalanceMilligrams != 0 {
+		t.Fatalf("拒绝后余额 = %d, %v，不应入账", a.BalanceMilligrams, err)
+	}
+}
+
+func TestTeaExchangeOrderRefundPending(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		order TeaExchangeOrder
+		want  bool
+	}{
+		{TeaExchangeOrder{Kind: TeaExchangeKind_Purchase, Status: TeaExchangeStatus_Rejected, PaidAt: &now}, true},
+		{TeaExchangeOrder{Kind: TeaExchangeKind_Purchase, Status: TeaExchangeStatus_Rejected, PaidAt: &now, RefundedAt: &now}, false},
+		{TeaExchangeOrder{Kind: TeaExchangeKind_Purchase, Status: TeaExchangeStatus_Rejected}, false}, // 未付款即否决，无需退款
+		{TeaExchangeOrder{Kind: TeaExchangeKind_Purchase, Status: TeaExchangeStatus_Paid, PaidAt: &now}, false},
+		{TeaExchangeOrder{Kind: TeaExchangeKind_Redeem, Status: TeaExchangeStatus_Rejected, PaidAt: &now}, false},
+	}
+	for _, c := range cases {
+		if got := c.order.RefundPending(); got != c.want {
+			t.Errorf("%s/%s paid=%v refunded=%v RefundPending() = %v, want %v",
+				c.order.Kind, c.order.Status, c.order.PaidAt != nil, c.order.RefundedAt != nil, got, c.want)
+		}
+	}
+}
+
+func TestRejectTeaExchangeOrderRefundsAfterCommit(t *testing.T) {
+	requireDB(t)
+	util.RegisterPaymentProvider(util.NewLocalPaymentProvider())
+	user := User{Name: "兑换测试茶友", Email: "exchange_test_" + Random_UUID()[:8] + "@example.com", Password: "Exchange-test-123"}
+	if err := user.Create(); err != nil {
+		t.Fatalf("创建茶友失败: %v", err)
+	}
+	ctx := context.Background()
+	order, err := CreateTeaPurchaseOrder(ctx, TeaUserLedgerAccount(user.Id), user.Id, 1000, util.LocalPaymentProviderName)
+	if err != nil {
+		t.Fatalf("CreateTeaPurchaseOrder: %v", err)
+	}
+	provider, _ := util.GetPaymentProvider(util.LocalPaymentProviderName)
+	provider.(*util.LocalPaymentProvider).MarkPaid(order.ProviderReference.String)
+	if _, err = SyncTeaPurchasePayment(ctx, order.Uuid); err != nil {
+		t.Fatalf("SyncTeaPurchasePayment: %v", err)
+	}
+
+	if err = RejectTeaExchangeOrder(ctx, order.Uuid, user.Id, "测试否决"); err != nil {
+		t.Fatalf("RejectTeaExchangeOrder: %v", err)
+	}
+	after, err := GetTeaExchangeOrderByUuid(ctx, order.Uuid)
+	if err != nil || after.Status != TeaExchangeStatus_Rejected || after.RefundedAt == nil {
+		t.Fatalf("否决后订单 = %s refunded=%v, %v", after.Status, after.RefundedAt, err)
+	}
+	if err = RejectTeaExchangeOrder(ctx, order.Uuid, user.Id, "重复否决"); !errors.Is(err, ErrTeaExchangeOrderProcessed) {
+		t.Fatalf("重复否决应返回 ErrTeaExchangeOrderProcessed，得到 %v", err)
+	}
+	if _, err = ApproveTeaExchangeOrder(ctx, order.Uuid, user.Id+1, ""); err == nil {
+		t.Fatal("已否决的订单不能再审核通过")
+	}
+	if _, err = RefundTeaExchangeOrder(ctx, order.Uuid); err != nil {
+		t.Fatalf("已退款的订单重试退款应直接返回: %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+星茶复式记账流水（tea.ledger_transactions / tea.ledger_entries，只追加）：
+1、记账时机：任何改变 tea.user_accounts 或 tea.team_accounts 余额（balance_milligrams）的操作，
+   在同一数据库事务中调用 postTeaLedgerTx 记一笔交易，锁定/解锁额度不改变余额，不记账；
+2、借贷方向：以账户持有人视角，debit 表示余额减少（转出），credit 表示余额增加（转入），每笔交易借贷合计相等；
+3、系统账户：星茶发放、扣除、兑现的对方为系统账户（TeaAccountHolderType_System，id=0），不记余额；
+4、只追加：数据库触发器禁止修改或删除流水，更正须另记一笔反向交易。
+*/
+
+// 系统账户（星茶发行），作为发放、扣除、兑现的对方账户
+const (
+	TeaAccountHolderType_System = "s"
+	TeaSystemAccountId          = 0
+)
+
+// 记账业务类型常量
+const (
+	TeaLedgerType_Transfer       = "transfer"       // 用户、团队之间转账
+	TeaLedgerType_Escrow         = "escrow"         // 团队向茶庄托管预备金
+	TeaLedgerType_EscrowRelease  = "escrow_release" // 茶庄释放托管星茶给胜出方
+	TeaLedgerType_Refund         = "refund"         // 茶庄退回托管星茶给支付方
+	TeaLedgerType_Forfeit        = "forfeit"        // 托管星茶罚没转入公共治理团队
+	TeaLedgerType_SystemGrant    = TeaTransactionType_SystemGrant
+	TeaLedgerType_SystemDeduct   = TeaTransactionType_SystemDeduct
+	TeaLedgerType_Withdraw       = TeaTransactionType_Withdraw
+	TeaLedgerType_OpeningBalance = "opening_balance" // 启用记账流水时的期初余额
+)
+
+// 记账方向常量
+const (
+	TeaLedgerDirection_Debit  = "debit"  // 余额减少
+	TeaLedgerDirection_Credit = "credit" // 余额增加
+)
+
+// TeaLedgerAccount 记账账户：用户、团队或系统账户
+type TeaLedgerAccount struct {
+	HolderType string // TeaAccountHolderType_User / _Team / _System
+	HolderId   int
+}
+
+// TeaUserLedgerAccount 用户星茶账户
+func TeaUserLedgerAccount(userId int) TeaLedgerAccount {
+	return TeaLedgerAccount{HolderType: TeaAccountHolderType_User, HolderId: userId}
+}
+
+// TeaTeamLedgerAccount 团队星茶账户
+func TeaTeamLedgerAccount(teamId int) TeaLedgerAccount {
+	return TeaLedgerAccount{HolderType: TeaAccountHolderType_Team, HolderId: teamId}
+}
+
+// TeaSystemLedgerAccount 系统账户（星茶发行）
+func TeaSystemLedgerAccount() TeaLedgerAccount {
+	return TeaLedgerAccount{HolderType: TeaAccountHolderType_System, HolderId: TeaSystemAccountId}
+}
+
+// teaLedgerPosting 一笔记账交易：From 记借（余额减少），To 记贷（余额增加）
+type teaLedgerPosting struct {
+	Type             string // TeaLedgerType_*
+	From             TeaLedgerAccount
+	To               TeaLedgerAccount
+	AmountMilligrams int64
+	ReferenceTable   string // 关联业务记录所在表（不含 tea. 前缀）
+	ReferenceId      int
+	InitiatorUserId  int
+	Notes            string
+}
+
+// TeaLedgerEntry 某个账户的一条记账分录，附带所属交易信息，用于交易流水页面及API
+type TeaLedgerEntry struct {
+	Id               int
+	TransactionId    int
+	TransactionUuid  string
+	Type             string // 业务类型 TeaLedgerType_*
+	EntryType        string // 账户视角的交易类型 TeaTransactionType_*
+	HolderType       string
+	HolderId         int
+	Direction        string
+	AmountMilligrams int64
+	BalanceAfter     int64 // 记账后余额（毫克）
+	CounterpartyType string
+	CounterpartyId   int
+	CounterpartyName string
+	ReferenceTable   string
+	ReferenceId      int
+	InitiatorUserId  int
+	Notes            string
+	CreatedAt        time.Time
+}
+
+// IsCredit 是否为转入（余额增加）
+func (e *TeaLedgerEntry) IsCredit() bool {
+	return e.Direction == TeaLedgerDirection_Credit
+}
+
+// TypeString 业务类型的中文描述
+func (e *TeaLedgerEntry) TypeString() string {
+	return TeaLedgerTypeString(e.Type)
+}
+
+// TeaLedgerTypeString 返回记账业务类型的中文描述
+func TeaLedgerTypeString(t string) string {
+	switch t {
+	case TeaLedgerType_Transfer:
+		return "转账"
+	case TeaLedgerType_Escrow:
+		return "托管"
+	case TeaLedgerType_EscrowRelease:
+		return "托管释放"
+	case TeaLedgerType_Refund:
+		return "托管退回"
+	case TeaLedgerType_Forfeit:
+		return "罚没"
+	case TeaLedgerType_SystemGrant:
+		return "系统发放"
+	case TeaLedgerType_SystemDeduct:
+		return "系统扣除"
+	case TeaLedgerType_Withdraw:
+		return "兑现"
+	case TeaLedgerType_OpeningBalance:
+		return "期初余额"
+	default:
+		return "未知类型"
+	}
+}
+
+// TeaLedgerTypes 可用于筛选的业务类型
+var TeaLedgerTypes = []string{
+	TeaLedgerType_Transfer,
+	TeaLedgerType_Escrow,
+	TeaLedgerType_EscrowRelease,
+	TeaLedgerType_Refund,
+	TeaLedgerType_Forfeit,
+	TeaLedgerType_SystemGrant,
+	TeaLedgerType_SystemDeduct,
+	TeaLedgerType_Withdraw,
+	TeaLedgerType_OpeningBalance,
+}
+
+// entryType 账户视角的交易类型：发放、扣除、兑现沿用业务类型，其余按方向记为转出/转入
+func (p *teaLedgerPosting) entryType(direction string) string {
+	switch p.Type {
+	case TeaLedgerType_SystemGrant, TeaLedgerType_SystemDeduct, TeaLedgerType_Withdraw:
+		return p.Type
+	}
+	if direction == TeaLedgerDirection_Debit {
+		return TeaTransactionType_TransferOut
+	}
+	return TeaTransactionType_TransferIn
+}
+
+// ledgerBalanceTx 读取账户当前（已更新的）余额，系统账户没有余额
+func ledgerBalanceTx(tx *sql.Tx, account TeaLedgerAccount) (sql.NullInt64, error) {
+	var balance sql.NullInt64
+	var err error
+	switch account.HolderType {
+	case TeaAccountHolderType_User:
+		err = tx.QueryRow(`SELECT balance_milligrams FROM tea.user_accounts WHERE user_id = $1`, account.HolderId).Scan(&balance)
+	case TeaAccountHolderType_Team:
+		err = tx.QueryRow(`SELECT balance_milligrams FROM tea.team_accounts WHERE team_id = $1`, account.HolderId).Scan(&balance)
+	case TeaAccountHolderType_System:
+		return balance, nil
+	default:
+		return balance, fmt.Errorf("未知的星茶账户类型: %s", account.HolderType)
+	}
+	return balance, err
+}
+
+// postTeaLedgerTx 在余额已更新的事务中记一笔交易（From 借、To 贷），与余额变动一同提交或回滚
+func postTeaLedgerTx(tx *sql.Tx, p teaLedgerPosting) error {
+	if p.AmountMilligrams <= 0 {
+		return fmt.Errorf("记账金额必须大于0: %d", p.AmountMilligrams)
+	}
+	if p.From == p.To {
+		return fmt.Errorf("记账的转出、转入账户不能相同")
+	}
+	if p.ReferenceTable == "" {
+		p.ReferenceTable = "-"
+	}
+	if p.Notes == "" {
+		p.Notes = "-"
+	}
+
+	var transactionId int
+	err := tx.QueryRow(`
+		INSERT INTO tea.ledger_transactions (type, reference_table, reference_id, initiator_user_id, notes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		p.Type, p.ReferenceTable, p.ReferenceId, p.InitiatorUserId, p.Notes).Scan(&transactionId)
+	if err != nil {
+		return fmt.Errorf("创建记账交易失败: %v", err)
+	}
+
+	legs := []struct {
+		account, counterparty TeaLedgerAccount
+		direction             string
+	}{
+		{p.From, p.To, TeaLedgerDirection_Debit},
+		{p.To, p.From, TeaLedgerDirection_Credit},
+	}
+	for _, leg := range legs {
+		balance, err := ledgerBalanceTx(tx, leg.account)
+		if err != nil {
+			return fmt.Errorf("读取记账账户余额失败: %v", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO tea.ledger_entries (transaction_id, entry_type, holder_type, holder_id, direction,
+				amount_milligrams, balance_after, counterparty_type, counterparty_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			transactionId, p.entryType(leg.direction), leg.account.HolderType, leg.account.HolderId, leg.direction,
+			p.AmountMilligrams, balance, leg.counterparty.HolderType, leg.counterparty.HolderId)
+		if err != nil {
+			return fmt.Errorf("创建记账分录失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// teaSystemGrantTx 系统向用户或团队星茶账户发放星茶并记账，所有发行星茶都经由这里，在调用方的事务中执行；
+// 冻结的账户不能入账。referenceTable/referenceId 为发放依据（如兑换订单）
+func teaSystemGrantTx(ctx context.Context, tx *sql.Tx, account TeaLedgerAccount, amountMg int64, operatorUserId int,
+	referenceTable string, referenceId int, notes string) error {
+	if amountMg <= 0 {
+		return fmt.Errorf("发放金额必须大于0")
+	}
+	table, column, err := teaAccountTable(account)
+	if err != nil {
+		return fmt.Errorf("只能向用户或团队星茶账户发放星茶")
+	}
+	var status string
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+		UPDATE %s SET balance_milligrams = balance_milligrams + $1, updated_at = $2 WHERE %s = $3
+		RETURNING status`, table, column),
+		amountMg, time.Now(), account.HolderId).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("星茶账户不存在")
+	}
+	if err != nil {
+		return fmt.Errorf("增加账户余额失败: %v", err)
+	}
+	if status == TeaAccountStatus_Frozen {
+		return fmt.Errorf("星茶账户已冻结，不能入账")
+	}
+	return postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             TeaLedgerType_SystemGrant,
+		From:             TeaSystemLedgerAccount(),
+		To:               account,
+		AmountMilligrams: amountMg,
+		ReferenceTable:   referenceTable,
+		ReferenceId:      referenceId,
+		InitiatorUserId:  operatorUserId,
+		Notes:            notes,
+	})
+}
+
+// TeaLedgerFilter 交易流水查询条件
+type TeaLedgerFilter struct {
+	Type      string     // 业务类型，空表示全部
+	Direction string     // debit / credit，空表示全部
+	From      *time.Time // 起始时间（含）
+	To        *time.Time // 截止时间（不含）
+	Page      int        // 从1开始
+	Limit     int
+}
+
+// where 生成查询条件，参数从 $1 开始：持有人类型、持有人id、其余筛选条件
+func (f *TeaLedgerFilter) where(account TeaLedgerAccount) (string, []any) {
+	conds := []string{"e.holder_type = $1", "e.holder_id = $2"}
+	args := []any{account.HolderType, account.HolderId}
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Type != "" {
+		add("t.type = $%d", f.Type)
+	}
+	if f.Direction != "" {
+		add("e.direction = $%d", f.Direction)
+	}
+	if f.From != nil {
+		add("e.created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("e.created_at < $%d", *f.To)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Validate 检查筛选条件是否有效，并补齐分页参数
+func (f *TeaLedgerFilter) Validate() error {
+	if f.Type != "" {
+		valid := false
+		for _, t := range TeaLedgerTypes {
+			if t == f.Type {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("未知的交易类型: %s", f.Type)
+		}
+	}
+	if f.Direction != "" && f.Direction != TeaLedgerDirection_Debit && f.Direction != TeaLedgerDirection_Credit {
+		return fmt.Errorf("未知的交易方向: %s", f.Direction)
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return fmt.Errorf("起始时间必须早于截止时间")
+	}
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 || f.Limit > 100 {
+		f.Limit = 20
+	}
+	return nil
+}
+
+// TeaLedgerHistory 查询账户的交易流水（按时间倒序）及符合条件的总条数
+func TeaLedgerHistory(ctx context.Context, account TeaLedgerAccount, filter TeaLedgerFilter) (entries []TeaLedgerEntry, total int, err error) {
+	if err = filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+	where, args := filter.where(account)
+
+	err = DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM tea.ledger_entries e
+		JOIN tea.ledger_transactions t ON t.id = e.transaction_id
+		WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("统计交易流水失败: %v", err)
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+	rows, err := DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT e.id, e.transaction_id, t.uuid, t.type, e.entry_type, e.holder_type, e.holder_id, e.direction,
+			e.amount_milligrams, COALESCE(e.balance_after, 0), e.counterparty_type, e.counterparty_id,
+			COALESCE(cu.name, ct.name, '星茶发行'), t.reference_table, t.reference_id, t.initiator_user_id, t.notes, e.created_at
+		FROM tea.ledger_entries e
+		JOIN tea.ledger_transactions t ON t.id = e.transaction_id
+		LEFT JOIN users cu ON e.counterparty_type = 'u' AND cu.id = e.counterparty_id
+		LEFT JOIN teams ct ON e.counterparty_type = 't' AND ct.id = e.counterparty_id
+		WHERE %s
+		ORDER BY e.id DESC
+		LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询交易流水失败: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e TeaLedgerEntry
+		if err = rows.Scan(&e.Id, &e.TransactionId, &e.TransactionUuid, &e.Type, &e.EntryType, &e.HolderType, &e.HolderId, &e.Direction,
+			&e.AmountMilligrams, &e.BalanceAfter, &e.CounterpartyType, &e.CounterpartyId,
+			&e.CounterpartyName, &e.ReferenceTable, &e.ReferenceId, &e.InitiatorUserId, &e.Notes, &e.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("扫描交易流水失败: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
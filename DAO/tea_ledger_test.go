@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTeaLedgerFilterWhere(t *testing.T) {
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	f := TeaLedgerFilter{Type: TeaLedgerType_Transfer, Direction: TeaLedgerDirection_Credit, From: &from, To: &to}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	where, args := f.where(TeaTeamLedgerAccount(7))
+	want := "e.holder_type = $1 AND e.holder_id = $2 AND t.type = $3 AND e.direction = $4 AND e.created_at >= $5 AND e.created_at < $6"
+	if where != want {
+		t.Fatalf("where = %q, want %q", where, want)
+	}
+	if len(args) != 6 || args[0] != TeaAccountHolderType_Team || args[1] != 7 {
+		t.Fatalf("args = %v", args)
+	}
+	if f.Page != 1 || f.Limit != 20 {
+		t.Fatalf("默认分页错误: page=%d limit=%d", f.Page, f.Limit)
+	}
+}
+
+func TestTeaLedgerFilterValidate(t *testing.T) {
+	now := time.Now()
+	cases := []TeaLedgerFilter{
+		{Type: "mint"},
+		{Direction: "in"},
+		{From: &now, To: &now},
+	}
+	for _, f := range cases {
+		if err := f.Validate(); err == nil {
+			t.Errorf("筛选条件 %+v 应无效", f)
+		}
+	}
+}
+
+func TestTeaLedgerPostingEntryType(t *testing.T) {
+	p := teaLedgerPosting{Type: TeaLedgerType_Escrow}
+	if got := p.entryType(TeaLedgerDirection_Debit); got != TeaTransactionType_TransferOut {
+		t.Errorf("托管转出分录类型 = %s", got)
+	}
+	if got := p.entryType(TeaLedgerDirection_Credit); got != TeaTransactionType_TransferIn {
+		t.Errorf("托管转入分录类型 = %s", got)
+	}
+	p.Type = TeaLedgerType_SystemGrant
+	if got := p.entryType(TeaLedgerDirection_Credit); got != TeaTransactionType_SystemGrant {
+		t.Errorf("系统发放分录类型 = %s", got)
+	}
+}
@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 )
@@ -189,6 +190,24 @@ func GetTeaOrderDepositById(id int) (*TeaOrderDeposit, error) {
 	return d, nil
 }
 
+// GetTeaOrderDepositByUuid 根据UUID获取预备金托管记录
+func GetTeaOrderDepositByUuid(uuid string) (*TeaOrderDeposit, error) {
+	statement := `SELECT id, uuid, tea_order_id, type, payer_team_id, bank_team_id, payee_team_id,
+		amount_milligrams, transfer_out_id, transfer_in_id, status, notes, has_dispute,
+		expired_at, created_at, updated_at, deleted_at
+		FROM tea.tea_order_deposits WHERE uuid = $1 AND deleted_at IS NULL`
+	d := &TeaOrderDeposit{}
+	err := DB.QueryRow(statement, uuid).Scan(
+		&d.Id, &d.Uuid, &d.TeaOrderId, &d.Type, &d.PayerTeamId, &d.BankTeamId, &d.PayeeTeamId,
+		&d.AmountMilligrams, &d.TransferOutId, &d.TransferInId, &d.Status,
+		&d.Notes, &d.HasDispute, &d.ExpiredAt, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
 // UpdateStatus 更新预备金托管状态
 func (tod *TeaOrderDeposit) UpdateStatus(status TeaOrderDepositStatus) error {
 	now := time.Now()
@@ -228,8 +247,8 @@ func (tod *TeaOrderDeposit) Forfeit() error {
 	}
 	defer tx.Rollback()
 
-	// 从托管团队账户扣除星茶
-	_, err = tx.Exec(`
+	// 从托管团队账户扣除星茶，检查受影响行数确保余额充足
+	result, err := tx.Exec(`
 		UPDATE tea.team_accounts
 		SET balance_milligrams = balance_milligrams - $1, updated_at = $2
 		WHERE team_id = $3 AND balance_milligrams >= $1`,
@@ -237,6 +256,9 @@ func (tod *TeaOrderDeposit) Forfeit() error {
 	if err != nil {
 		return err
 	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("托管团队(team_id=%d)星茶余额不足，需要 %d 毫克", tod.BankTeamId, tod.AmountMilligrams)
+	}
 
 	// 转入公共治理团队账户
 	_, err = tx.Exec(`
@@ -248,6 +270,18 @@ func (tod *TeaOrderDeposit) Forfeit() error {
 		return err
 	}
 
+	if err = postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             TeaLedgerType_Forfeit,
+		From:             TeaTeamLedgerAccount(tod.BankTeamId),
+		To:               TeaTeamLedgerAccount(TeamIdPublicGovernance),
+		AmountMilligrams: tod.AmountMilligrams,
+		ReferenceTable:   "tea_order_deposits",
+		ReferenceId:      tod.Id,
+		Notes:            tod.Notes,
+	}); err != nil {
+		return err
+	}
+
 	// 更新托管状态为已罚没
 	_, err = tx.Exec(`
 		UPDATE tea.tea_order_deposits SET status = $2, updated_at = $3 WHERE id = $1`,
@@ -279,15 +313,30 @@ func (tod *TeaOrderDeposit) Refund(initiatorUserId int) error {
 	}
 	defer tx.Rollback()
 
-	// 获取托管团队（茶庄）和原支付方的团队名称，用于转账记录
-	var bankTeamName, payerTeamName string
-	err = tx.QueryRow(`SELECT name FROM teams WHERE id = $1`, tod.BankTeamId).Scan(&bankTeamName)
+	if err = tod.settleFromBankWithTx(tx, initiatorUserId, tod.PayerTeamId, DepositStatusRefundedToPayer, fmt.Sprintf("退款：%s", tod.Notes)); err != nil {
+		return fmt.Errorf("退款失败：%v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	tod.Status = DepositStatusRefundedToPayer
+	return nil
+}
+
+// settleFromBankWithTx 在事务中将托管团队（茶庄）持有的星茶转给目标团队，
+// 并创建托管团队→目标团队的转出/接收记录（状态直接为 completed），最后更新托管状态。
+// 退款（退回支付方）与争议仲裁（释放给胜出方）共用此方法。
+func (tod *TeaOrderDeposit) settleFromBankWithTx(tx *sql.Tx, initiatorUserId, toTeamId int, finalStatus TeaOrderDepositStatus, notes string) error {
+	// 获取托管团队（茶庄）和目标团队的团队名称，用于转账记录
+	var bankTeamName, toTeamName string
+	err := tx.QueryRow(`SELECT name FROM teams WHERE id = $1`, tod.BankTeamId).Scan(&bankTeamName)
 	if err != nil {
-		return fmt.Errorf("退款失败：查询托管团队名称失败: %v", err)
+		return fmt.Errorf("查询托管团队名称失败: %v", err)
 	}
-	err = tx.QueryRow(`SELECT name FROM teams WHERE id = $1`, tod.PayerTeamId).Scan(&payerTeamName)
+	err = tx.QueryRow(`SELECT name FROM teams WHERE id = $1`, toTeamId).Scan(&toTeamName)
 	if err != nil {
-		return fmt.Errorf("退款失败：查询原支付方团队名称失败: %v", err)
+		return fmt.Errorf("查询接收方团队名称失败: %v", err)
 	}
 
 	// 从托管团队账户扣除星茶，检查受影响行数确保余额充足
@@ -301,45 +350,44 @@ func (tod *TeaOrderDeposit) Refund(initiatorUserId int) error {
 	}
 	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("退款失败：查询托管团队扣款影响行数出错: %v", err)
+		return fmt.Errorf("查询托管团队扣款影响行数出错: %v", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("退款失败：托管团队(team_id=%d)星茶余额不足，需要 %d 毫克", tod.BankTeamId, tod.AmountMilligrams)
+		return fmt.Errorf("托管团队(team_id=%d)星茶余额不足，需要 %d 毫克", tod.BankTeamId, tod.AmountMilligrams)
 	}
 
 	// 查询托管团队扣款后的余额
 	var bankBalanceAfter int64
 	err = tx.QueryRow(`SELECT balance_milligrams FROM tea.team_accounts WHERE team_id = $1`, tod.BankTeamId).Scan(&bankBalanceAfter)
 	if err != nil {
-		return fmt.Errorf("退款失败：查询托管团队余额失败: %v", err)
+		return fmt.Errorf("查询托管团队余额失败: %v", err)
 	}
 
-	// 退回给原支付方
+	// 转给目标团队
 	result, err = tx.Exec(`
 		UPDATE tea.team_accounts
 		SET balance_milligrams = balance_milligrams + $1, updated_at = $2
 		WHERE team_id = $3`,
-		tod.AmountMilligrams, time.Now(), tod.PayerTeamId)
+		tod.AmountMilligrams, time.Now(), toTeamId)
 	if err != nil {
 		return err
 	}
 	rows, err = result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("退款失败：查询原支付方加款影响行数出错: %v", err)
+		return fmt.Errorf("查询接收方加款影响行数出错: %v", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("退款失败：原支付方团队(team_id=%d)账户不存在", tod.PayerTeamId)
+		return fmt.Errorf("接收方团队(team_id=%d)账户不存在", toTeamId)
 	}
 
-	// 查询原支付方加款后的余额
-	var payerBalanceAfter int64
-	err = tx.QueryRow(`SELECT balance_milligrams FROM tea.team_accounts WHERE team_id = $1`, tod.PayerTeamId).Scan(&payerBalanceAfter)
+	// 查询接收方加款后的余额
+	var toBalanceAfter int64
+	err = tx.QueryRow(`SELECT balance_milligrams FROM tea.team_accounts WHERE team_id = $1`, toTeamId).Scan(&toBalanceAfter)
 	if err != nil {
-		return fmt.Errorf("退款失败：查询原支付方余额失败: %v", err)
+		return fmt.Errorf("查询接收方余额失败: %v", err)
 	}
 
-	// 创建托管团队→原支付方的转出记录（状态直接为 completed）
-	refundNotes := fmt.Sprintf("退款：%s", tod.Notes)
+	// 创建托管团队→接收方的转出记录（状态直接为 completed）
 	now := time.Now()
 	var transferOutId int
 	var transferOutUuid string
@@ -350,41 +398,51 @@ func (tod *TeaOrderDeposit) Refund(initiatorUserId int) error {
 		 expires_at, is_approved, approver_user_id, approved_at, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, true, $3, $11, $11)
 		RETURNING id, uuid`,
-		tod.BankTeamId, tod.PayerTeamId, initiatorUserId, tod.AmountMilligrams, refundNotes,
-		bankTeamName, payerTeamName,
+		tod.BankTeamId, toTeamId, initiatorUserId, tod.AmountMilligrams, notes,
+		bankTeamName, toTeamName,
 		TeaTransferStatusCompleted, bankBalanceAfter,
 		now.Add(24*time.Hour), now).Scan(&transferOutId, &transferOutUuid)
 	if err != nil {
-		return fmt.Errorf("退款失败：创建托管团队转出记录失败: %v", err)
+		return fmt.Errorf("创建托管团队转出记录失败: %v", err)
 	}
 
-	// 创建原支付方的接收记录（状态直接为 completed）
+	// 创建接收方的接收记录（状态直接为 completed）
 	_, err = tx.Exec(`
 		INSERT INTO tea.team_from_team_transfer_in (
 			team_to_team_transfer_out_id, to_team_id, to_team_name,
 			from_team_id, from_team_name, amount_milligrams, notes,
 			balance_after_receipt, status, is_confirmed, operational_user_id, created_at, expires_at
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
-		transferOutId, tod.PayerTeamId, payerTeamName,
+		transferOutId, toTeamId, toTeamName,
 		tod.BankTeamId, bankTeamName,
-		tod.AmountMilligrams, refundNotes,
-		payerBalanceAfter, TeaTransferStatusCompleted,
+		tod.AmountMilligrams, notes,
+		toBalanceAfter, TeaTransferStatusCompleted,
 		true, initiatorUserId, now, now.Add(24*time.Hour))
 	if err != nil {
-		return fmt.Errorf("退款失败：创建原支付方接收记录失败: %v", err)
+		return fmt.Errorf("创建接收方接收记录失败: %v", err)
+	}
+
+	// 记账：退回支付方记为退款，其余（争议仲裁释放给胜出方）记为托管释放
+	ledgerType := TeaLedgerType_EscrowRelease
+	if finalStatus == DepositStatusRefundedToPayer {
+		ledgerType = TeaLedgerType_Refund
+	}
+	if err = postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             ledgerType,
+		From:             TeaTeamLedgerAccount(tod.BankTeamId),
+		To:               TeaTeamLedgerAccount(toTeamId),
+		AmountMilligrams: tod.AmountMilligrams,
+		ReferenceTable:   "team_to_team_transfer_out",
+		ReferenceId:      transferOutId,
+		InitiatorUserId:  initiatorUserId,
+		Notes:            notes,
+	}); err != nil {
+		return err
 	}
 
-	// 更新托管状态为已退款给需求方
+	// 更新托管状态
 	_, err = tx.Exec(`
 		UPDATE tea.tea_order_deposits SET status = $2, updated_at = $3 WHERE id = $1`,
-		tod.Id, DepositStatusRefundedToPayer, now)
-	if err != nil {
-		return err
-	}
-
-	if err = tx.Commit(); err != nil {
-		return err
-	}
-	tod.Status = DepositStatusRefundedToPayer
-	return nil
+		tod.Id, finalStatus, now)
+	return err
 }
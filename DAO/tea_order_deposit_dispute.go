@@ -1,6 +1,23 @@
 package dao
 
-import "time"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+公道杯：茶订单预备金托管争议仲裁流程
+1、需求方或解题方团队的核心成员，针对某笔已托管（DepositStatusPaid）的预备金发起争议，
+   托管记录随即标记 HasDispute 并进入 DepositStatusDisputed 状态，星茶继续冻结在茶庄；
+2、被诉方团队核心成员提交答辩；
+3、见证者集团中与双方团队成员均无需利益回避（ShouldAvoidConflict）的见证者，代表其团队作出仲裁；
+4、仲裁结果自动通过团队对团队转账记录，将托管星茶从茶庄释放给胜出方；
+5、发起方可在仲裁前撤销争议，托管记录恢复为已托管状态。
+每一步操作均在同一事务中写入见证日志 WitnessLog，日志写入失败时整步操作回滚。
+*/
 
 // TeaOrderDepositDispute 托管争议表
 type TeaOrderDepositDispute struct {
@@ -9,11 +26,18 @@ type TeaOrderDepositDispute struct {
 	DepositId        int           // 关联的托管记录ID, REFERENCES tea_order_deposit(id)
 	InitiatorTeamId  int           // 发起争议方团队ID
 	RespondentTeamId int           // 被诉方团队ID
+	InitiatorUserId  int           // 发起争议的用户ID（发起方团队核心成员）
 	Reason           string        // 争议原因
 	Status           DisputeStatus // 争议状态
 
+	// 答辩信息
+	RespondentUserId int        // 答辩用户ID（被诉方团队核心成员），未答辩时为0
+	Response         string     // 被诉方答辩内容，默认值'-'
+	RespondedAt      *time.Time // 答辩时间
+
 	// 仲裁信息
 	ArbitratorTeamId *int          // 仲裁方团队ID,未仲裁时为 nil
+	ArbitratorUserId int           // 仲裁人用户ID（见证者），未仲裁时为0
 	ArbitrationNotes string        // 仲裁说明
 	Result           DisputeResult // 仲裁结果
 
@@ -69,3 +93,362 @@ func (d *TeaOrderDepositDispute) ResultString() string {
 		return "未知结果"
 	}
 }
+
+// WinnerTeamId 返回仲裁胜出方团队ID，未仲裁时返回 TeamIdNone
+func (d *TeaOrderDepositDispute) WinnerTeamId(deposit *TeaOrderDeposit) int {
+	switch d.Result {
+	case DisputeResultPayeeWin:
+		return deposit.PayeeTeamId
+	case DisputeResultPayerWin:
+		return deposit.PayerTeamId
+	default:
+		return TeamIdNone
+	}
+}
+
+// CreatedDateTime 格式化争议发起时间
+func (d *TeaOrderDepositDispute) CreatedDateTime() string {
+	return d.CreatedAt.Format(FMT_DATE_TIME_CN)
+}
+
+// IsPending 争议是否仍在处理中
+func (d *TeaOrderDepositDispute) IsPending() bool {
+	return d.Status == DisputeStatusPending
+}
+
+// IsResponded 被诉方是否已答辩
+func (d *TeaOrderDepositDispute) IsResponded() bool {
+	return d.RespondedAt != nil
+}
+
+const teaOrderDepositDisputeColumns = `id, uuid, deposit_id, initiator_team_id, respondent_team_id, initiator_user_id, reason, status,
+	respondent_user_id, response, responded_at, arbitrator_team_id, arbitrator_user_id, arbitration_notes, result,
+	created_at, arbitrated_at, updated_at, deleted_at`
+
+func (d *TeaOrderDepositDispute) scanFields() []any {
+	return []any{&d.Id, &d.Uuid, &d.DepositId, &d.InitiatorTeamId, &d.RespondentTeamId, &d.InitiatorUserId, &d.Reason, &d.Status,
+		&d.RespondentUserId, &d.Response, &d.RespondedAt, &d.ArbitratorTeamId, &d.ArbitratorUserId, &d.ArbitrationNotes, &d.Result,
+		&d.CreatedAt, &d.ArbitratedAt, &d.UpdatedAt, &d.DeletedAt}
+}
+
+// Create 发起争议：在同一事务中创建争议记录、将托管记录标记为争议中并写入见证日志
+// 只有已托管（DepositStatusPaid）且不存在进行中争议的托管记录才能发起争议
+func (d *TeaOrderDepositDispute) Create(ctx context.Context, witness *WitnessLog) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 锁定托管记录，防止并发发起争议或结算
+	result, err := tx.ExecContext(ctx, `
+		UPDATE tea.tea_order_deposits SET status = $2, has_dispute = true, updated_at = $3
+		WHERE id = $1 AND status = $4 AND has_dispute = false AND deleted_at IS NULL`,
+		d.DepositId, DepositStatusDisputed, time.Now(), DepositStatusPaid)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("托管记录(id=%d)不是已托管状态或已存在争议", d.DepositId)
+	}
+
+	now := time.Now()
+	d.Status = DisputeStatusPending
+	d.Result = DisputeResultNone
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tea.tea_order_deposit_disputes
+		(uuid, deposit_id, initiator_team_id, respondent_team_id, initiator_user_id, reason, status, result, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, uuid`,
+		Random_UUID(), d.DepositId, d.InitiatorTeamId, d.RespondentTeamId, d.InitiatorUserId,
+		d.Reason, d.Status, d.Result, now).Scan(&d.Id, &d.Uuid)
+	if err != nil {
+		return err
+	}
+	if err = witness.createTx(ctx, tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	d.CreatedAt = now
+	return nil
+}
+
+// GetTeaOrderDepositDisputeByUuid 根据UUID获取争议记录
+func GetTeaOrderDepositDisputeByUuid(ctx context.Context, uuid string) (*TeaOrderDepositDispute, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	d := &TeaOrderDepositDispute{}
+	err := DB.QueryRowContext(ctx, `SELECT `+teaOrderDepositDisputeColumns+`
+		FROM tea.tea_order_deposit_disputes WHERE uuid = $1 AND deleted_at IS NULL`, uuid).Scan(d.scanFields()...)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetPendingDisputeByDepositId 获取某托管记录进行中的争议，不存在时返回 sql.ErrNoRows
+func GetPendingDisputeByDepositId(ctx context.Context, depositId int) (*TeaOrderDepositDispute, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	d := &TeaOrderDepositDispute{}
+	err := DB.QueryRowContext(ctx, `SELECT `+teaOrderDepositDisputeColumns+`
+		FROM tea.tea_order_deposit_disputes WHERE deposit_id = $1 AND status = $2 AND deleted_at IS NULL`,
+		depositId, DisputeStatusPending).Scan(d.scanFields()...)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetDisputesByTeaOrderId 获取某茶订单全部托管记录的争议，按发起时间倒序
+func GetDisputesByTeaOrderId(ctx context.Context, teaOrderId int) ([]*TeaOrderDepositDispute, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx, `SELECT `+teaOrderDepositDisputeColumns+`
+		FROM tea.tea_order_deposit_disputes
+		WHERE deposit_id IN (SELECT id FROM tea.tea_order_deposits WHERE tea_order_id = $1)
+			AND deleted_at IS NULL
+		ORDER BY created_at DESC`, teaOrderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTeaOrderDepositDisputes(rows)
+}
+
+// GetPendingDisputes 获取待仲裁的争议列表（见证工作间），按发起时间正序，分页
+func GetPendingDisputes(ctx context.Context, page int, pageSize int) ([]*TeaOrderDepositDispute, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	rows, err := DB.QueryContext(ctx, `SELECT `+teaOrderDepositDisputeColumns+`
+		FROM tea.tea_order_deposit_disputes WHERE status = $1 AND deleted_at IS NULL
+		ORDER BY created_at ASC LIMIT $2 OFFSET $3`, DisputeStatusPending, pageSize, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTeaOrderDepositDisputes(rows)
+}
+
+// GetPendingDisputeCount 统计待仲裁的争议数量
+func GetPendingDisputeCount(ctx context.Context) (count int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM tea.tea_order_deposit_disputes WHERE status = $1 AND deleted_at IS NULL`,
+		DisputeStatusPending).Scan(&count)
+	return
+}
+
+func scanTeaOrderDepositDisputes(rows *sql.Rows) ([]*TeaOrderDepositDispute, error) {
+	disputes := make([]*TeaOrderDepositDispute, 0)
+	for rows.Next() {
+		d := &TeaOrderDepositDispute{}
+		if err := rows.Scan(d.scanFields()...); err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// Respond 被诉方提交答辩（仅争议中且未答辩时可提交），同一事务中写入见证日志
+func (d *TeaOrderDepositDispute) Respond(ctx context.Context, userId int, response string, witness *WitnessLog) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE tea.tea_order_deposit_disputes
+		SET respondent_user_id = $2, response = $3, responded_at = $4, updated_at = $4
+		WHERE id = $1 AND status = $5 AND responded_at IS NULL`,
+		d.Id, userId, response, now, DisputeStatusPending)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("争议已答辩或已结束，无法提交答辩")
+	}
+	if err = witness.createTx(ctx, tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	d.RespondentUserId = userId
+	d.Response = response
+	d.RespondedAt = &now
+	d.UpdatedAt = &now
+	return nil
+}
+
+// Withdraw 发起方撤销争议，托管记录恢复为已托管状态，同一事务中写入见证日志
+func (d *TeaOrderDepositDispute) Withdraw(ctx context.Context, witness *WitnessLog) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE tea.tea_order_deposit_disputes SET status = $2, updated_at = $3
+		WHERE id = $1 AND status = $4`,
+		d.Id, DisputeStatusWithdrawn, now, DisputeStatusPending)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("争议已结束，无法撤销")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tea.tea_order_deposits SET status = $2, has_dispute = false, updated_at = $3
+		WHERE id = $1 AND status = $4`,
+		d.DepositId, DepositStatusPaid, now, DepositStatusDisputed)
+	if err != nil {
+		return err
+	}
+	if err = witness.createTx(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	d.Status = DisputeStatusWithdrawn
+	d.UpdatedAt = &now
+	return nil
+}
+
+// Arbitrate 见证者仲裁争议，并在同一事务中将托管星茶从茶庄释放给胜出方、写入见证日志：
+// DisputeResultPayeeWin -> 释放给托管记录的 PayeeTeamId（DepositStatusReleasedToPayee）
+// DisputeResultPayerWin -> 退回托管记录的 PayerTeamId（DepositStatusRefundedToPayer）
+func (d *TeaOrderDepositDispute) Arbitrate(ctx context.Context, arbitratorTeamId, arbitratorUserId int, result DisputeResult, notes string, witness *WitnessLog) error {
+	var finalStatus TeaOrderDepositStatus
+	switch result {
+	case DisputeResultPayeeWin:
+		finalStatus = DepositStatusReleasedToPayee
+	case DisputeResultPayerWin:
+		finalStatus = DepositStatusRefundedToPayer
+	default:
+		return fmt.Errorf("无效的仲裁结果: %d", result)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, `
+		UPDATE tea.tea_order_deposit_disputes
+		SET status = $2, result = $3, arbitrator_team_id = $4, arbitrator_user_id = $5,
+			arbitration_notes = $6, arbitrated_at = $7, updated_at = $7
+		WHERE id = $1 AND status = $8`,
+		d.Id, DisputeStatusArbitrated, result, arbitratorTeamId, arbitratorUserId, notes, now, DisputeStatusPending)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("争议已结束，无法重复仲裁")
+	}
+
+	// 锁定托管记录并确认仍处于争议中
+	deposit := &TeaOrderDeposit{}
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, bank_team_id, payer_team_id, payee_team_id, amount_milligrams, notes, status
+		FROM tea.tea_order_deposits WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, d.DepositId).
+		Scan(&deposit.Id, &deposit.BankTeamId, &deposit.PayerTeamId, &deposit.PayeeTeamId,
+			&deposit.AmountMilligrams, &deposit.Notes, &deposit.Status)
+	if err != nil {
+		return fmt.Errorf("查询托管记录失败: %v", err)
+	}
+	if deposit.Status != DepositStatusDisputed {
+		return fmt.Errorf("托管记录(id=%d)不在争议中，当前状态=%d", deposit.Id, deposit.Status)
+	}
+
+	toTeamId := deposit.PayerTeamId
+	if result == DisputeResultPayeeWin {
+		toTeamId = deposit.PayeeTeamId
+	}
+	settleNotes := fmt.Sprintf("公道杯仲裁结算：%s", deposit.Notes)
+	if err = deposit.settleFromBankWithTx(tx, arbitratorUserId, toTeamId, finalStatus, settleNotes); err != nil {
+		return fmt.Errorf("仲裁结算失败：%v", err)
+	}
+	if err = witness.createTx(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	d.Status = DisputeStatusArbitrated
+	d.Result = result
+	d.ArbitratorTeamId = &arbitratorTeamId
+	d.ArbitratorUserId = arbitratorUserId
+	d.ArbitrationNotes = notes
+	d.ArbitratedAt = &now
+	d.UpdatedAt = &now
+	return nil
+}
+
+// CheckArbitratorConflict 检查仲裁人与争议双方团队的活跃成员之间是否需要利益回避，
+// 仲裁人本身是任一方团队成员，或与任一成员存在三代以内亲属关系时，返回 true
+func CheckArbitratorConflict(ctx context.Context, arbitratorUserId int, teamIds ...int) (bool, error) {
+	for _, teamId := range teamIds {
+		if teamId == TeamIdNone || teamId == TeamIdFreelancer {
+			continue
+		}
+		userIds, err := GetActiveMemberUserIdsByTeamId(teamId)
+		if err != nil {
+			return false, err
+		}
+		for _, uid := range userIds {
+			avoid, err := ShouldAvoidConflict(arbitratorUserId, uid, ctx)
+			if err != nil {
+				return false, err
+			}
+			if avoid {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,334 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+星茶余额对账（tea.reconciliation_runs / tea.reconciliation_findings）：
+1、锁定余额：用户账户应等于其待接收（pending_receipt）转出之和；团队账户应等于其待审批、待接收转出之和；
+   两者都另加待审核（pending_review）的兑现订单；
+2、可用余额：余额、锁定余额不能为负，锁定余额不能超过余额；
+3、记账流水：账户余额应等于记账分录贷方合计减借方合计；
+4、托管余额：托管团队（茶庄）余额不能少于其仍在托管中（已支付、争议中）的预备金之和；
+5、每次对账记一个批次，差异逐项记入对账发现；指定冻结时，经 FreezeTeaAccount 冻结有差异的账户（记入冻结记录，来源为对账）。
+对账只读取快照并写入对账表，不修正账户余额，修正须人工核实后另行处理。
+*/
+
+// 对账触发方式
+const (
+	TeaReconcileTrigger_CLI = "cli"
+	TeaReconcileTrigger_Job = "job"
+)
+
+// 对账差异类型
+const (
+	TeaReconcileKind_LockedMismatch    = "locked_mismatch"    // 锁定余额与待处理转出之和不符
+	TeaReconcileKind_NegativeAvailable = "negative_available" // 余额或锁定余额为负，或锁定超过余额
+	TeaReconcileKind_LedgerMismatch    = "ledger_mismatch"    // 余额与记账流水合计不符
+	TeaReconcileKind_EscrowShortfall   = "escrow_shortfall"   // 托管团队余额少于托管中的预备金
+)
+
+// TeaReconcileKindString 返回对账差异类型的中文描述
+func TeaReconcileKindString(kind string) string {
+	switch kind {
+	case TeaReconcileKind_LockedMismatch:
+		return "锁定余额不符"
+	case TeaReconcileKind_NegativeAvailable:
+		return "可用余额为负"
+	case TeaReconcileKind_LedgerMismatch:
+		return "余额与流水不符"
+	case TeaReconcileKind_EscrowShortfall:
+		return "托管余额不足"
+	default:
+		return "未知差异"
+	}
+}
+
+// TeaReconcileRun 对账批次
+type TeaReconcileRun struct {
+	Id              int
+	Uuid            string
+	Trigger         string
+	Freeze          bool
+	AccountsChecked int
+	FindingsCount   int
+	StartedAt       time.Time
+	FinishedAt      *time.Time
+}
+
+// TeaReconcileFinding 对账发现的账户差异
+type TeaReconcileFinding struct {
+	Id                 int
+	RunId              int
+	HolderType         string // TeaAccountHolderType_User / _Team
+	HolderId           int
+	Kind               string
+	ExpectedMilligrams int64
+	ActualMilligrams   int64
+	Frozen             bool
+	CreatedAt          time.Time
+}
+
+// DriftMilligrams 实际值减应有值
+func (f *TeaReconcileFinding) DriftMilligrams() int64 {
+	return f.ActualMilligrams - f.ExpectedMilligrams
+}
+
+// teaAccountSnapshot 对账时某个账户的实际值与按记录重新计算的应有值
+type teaAccountSnapshot struct {
+	HolderType     string
+	HolderId       int
+	Status         string
+	Balance        int64
+	Locked         int64
+	ExpectedLocked int64 // 待处理转出之和
+	LedgerBalance  int64 // 记账流水贷方合计减借方合计
+	EscrowHeld     int64 // 作为托管方仍在托管中的预备金之和，仅团队账户
+}
+
+// findings 比较账户快照，返回差异（未写入数据库）
+func (s *teaAccountSnapshot) findings() []TeaReconcileFinding {
+	var out []TeaReconcileFinding
+	add := func(kind string, expected, actual int64) {
+		out = append(out, TeaReconcileFinding{
+			HolderType:         s.HolderType,
+			HolderId:           s.HolderId,
+			Kind:               kind,
+			ExpectedMilligrams: expected,
+			ActualMilligrams:   actual,
+		})
+	}
+	if s.Locked != s.ExpectedLocked {
+		add(TeaReconcileKind_LockedMismatch, s.ExpectedLocked, s.Locked)
+	}
+	if s.Balance < 0 || s.Locked < 0 || s.Balance < s.Locked {
+		// 应有可用余额不小于0，实际为余额减锁定余额
+		add(TeaReconcileKind_NegativeAvailable, 0, s.Balance-s.Locked)
+	}
+	if s.Balance != s.LedgerBalance {
+		add(TeaReconcileKind_LedgerMismatch, s.LedgerBalance, s.Balance)
+	}
+	if s.Balance < s.EscrowHeld {
+		add(TeaReconcileKind_EscrowShortfall, s.EscrowHeld, s.Balance)
+	}
+	return out
+}
+
+// TeaReconcileOptions 对账选项
+type TeaReconcileOptions struct {
+	Trigger string // TeaReconcileTrigger_*
+	Freeze  bool   // 冻结有差异且未冻结的账户
+}
+
+// TeaReconcile 执行一次对账：在一致性快照上重新计算各账户应有值，记录批次与差异，按需冻结账户
+func TeaReconcile(ctx context.Context, opts TeaReconcileOptions) (TeaReconcileRun, []TeaReconcileFinding, error) {
+	run := TeaReconcileRun{Trigger: opts.Trigger, Freeze: opts.Freeze}
+	if run.Trigger == "" {
+		run.Trigger = TeaReconcileTrigger_CLI
+	}
+
+	snapshots, err := teaAccountSnapshots(ctx)
+	if err != nil {
+		return run, nil, err
+	}
+	var findings []TeaReconcileFinding
+	for i := range snapshots {
+		findings = append(findings, snapshots[i].findings()...)
+	}
+	run.AccountsChecked = len(snapshots)
+	run.FindingsCount = len(findings)
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return run, nil, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tea.reconciliation_runs (trigger, freeze, accounts_checked, findings_count, finished_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		RETURNING id, uuid, started_at, finished_at`,
+		run.Trigger, run.Freeze, run.AccountsChecked, run.FindingsCount).
+		Scan(&run.Id, &run.Uuid, &run.StartedAt, &run.FinishedAt)
+	if err != nil {
+		return run, nil, fmt.Errorf("创建对账批次失败: %v", err)
+	}
+	for i := range findings {
+		f := &findings[i]
+		f.RunId = run.Id
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO tea.reconciliation_findings (run_id, holder_type, holder_id, kind, expected_milligrams, actual_milligrams)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at`,
+			f.RunId, f.HolderType, f.HolderId, f.Kind, f.ExpectedMilligrams, f.ActualMilligrams).Scan(&f.Id, &f.CreatedAt)
+		if err != nil {
+			return run, nil, fmt.Errorf("记录对账发现失败: %v", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return run, nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	if opts.Freeze {
+		if err = freezeReconciledAccounts(ctx, run, snapshots, findings); err != nil {
+			return run, findings, err
+		}
+	}
+	return run, findings, nil
+}
+
+// teaAccountSnapshots 在可重复读的只读事务中读取所有用户、团队账户的快照
+func teaAccountSnapshots(ctx context.Context) ([]teaAccountSnapshot, error) {
+	tx, err := DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("开始对账快照事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var snapshots []teaAccountSnapshot
+	scan := func(holderType, query string, args ...any) error {
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			s := teaAccountSnapshot{HolderType: holderType}
+			if err := rows.Scan(&s.HolderId, &s.Status, &s.Balance, &s.Locked, &s.ExpectedLocked, &s.LedgerBalance, &s.EscrowHeld); err != nil {
+				return err
+			}
+			snapshots = append(snapshots, s)
+		}
+		return rows.Err()
+	}
+
+	err = scan(TeaAccountHolderType_User, `
+		SELECT a.user_id, a.status, a.balance_milligrams, a.locked_balance_milligrams,
+			COALESCE((SELECT SUM(amount_milligrams) FROM tea.user_to_user_transfer_out WHERE from_user_id = a.user_id AND status = $1), 0)
+			+ COALESCE((SELECT SUM(amount_milligrams) FROM tea.user_to_team_transfer_out WHERE from_user_id = a.user_id AND status = $1), 0)
+			+ COALESCE((SELECT SUM(amount_milligrams) FROM tea.exchange_orders
+				WHERE holder_type = 'u' AND holder_id = a.user_id AND kind = $2 AND status = $3), 0),
+			COALESCE((SELECT SUM(CASE WHEN direction = 'credit' THEN amount_milligrams ELSE -amount_milligrams END)
+				FROM tea.ledger_entries WHERE holder_type = 'u' AND holder_id = a.user_id), 0),
+			0
+		FROM tea.user_accounts a
+		ORDER BY a.user_id`,
+		TeaTransferStatusPendingReceipt, TeaExchangeKind_Redeem, TeaExchangeStatus_PendingReview)
+	if err != nil {
+		return nil, fmt.Errorf("读取用户账户快照失败: %v", err)
+	}
+
+	err = scan(TeaAccountHolderType_Team, `
+		SELECT a.team_id, a.status, a.balance_milligrams, a.locked_balance_milligrams,
+			COALESCE((SELECT SUM(amount_milligrams) FROM tea.team_to_user_transfer_out WHERE from_team_id = a.team_id AND status IN ($1, $2)), 0)
+			+ COALESCE((SELECT SUM(amount_milligrams) FROM tea.team_to_team_transfer_out WHERE from_team_id = a.team_id AND status IN ($1, $2)), 0)
+			+ COALESCE((SELECT SUM(amount_milligrams) FROM tea.exchange_orders
+				WHERE holder_type = 't' AND holder_id = a.team_id AND kind = $5 AND status = $6), 0),
+			COALESCE((SELECT SUM(CASE WHEN direction = 'credit' THEN amount_milligrams ELSE -amount_milligrams END)
+				FROM tea.ledger_entries WHERE holder_type = 't' AND holder_id = a.team_id), 0),
+			COALESCE((SELECT SUM(amount_milligrams) FROM tea.tea_order_deposits
+				WHERE bank_team_id = a.team_id AND status IN ($3, $4) AND deleted_at IS NULL), 0)
+		FROM tea.team_accounts a
+		ORDER BY a.team_id`,
+		TeaTransferStatusPendingApproval, TeaTransferStatusPendingReceipt, DepositStatusPaid, DepositStatusDisputed,
+		TeaExchangeKind_Redeem, TeaExchangeStatus_PendingReview)
+	if err != nil {
+		return nil, fmt.Errorf("读取团队账户快照失败: %v", err)
+	}
+	return snapshots, nil
+}
+
+// freezeReconciledAccounts 冻结有差异且尚未冻结的账户，并标记对应的对账发现
+func freezeReconciledAccounts(ctx context.Context, run TeaReconcileRun, snapshots []teaAccountSnapshot, findings []TeaReconcileFinding) error {
+	type holder struct {
+		holderType string
+		holderId   int
+	}
+	frozen := map[holder]bool{}
+	for _, s := range snapshots {
+		if s.Status == TeaAccountStatus_Frozen {
+			frozen[holder{s.HolderType, s.HolderId}] = true
+		}
+	}
+	kinds := map[holder][]string{}
+	var order []holder
+	for _, f := range findings {
+		h := holder{f.HolderType, f.HolderId}
+		if frozen[h] {
+			continue
+		}
+		if _, ok := kinds[h]; !ok {
+			order = append(order, h)
+		}
+		kinds[h] = append(kinds[h], TeaReconcileKindString(f.Kind))
+	}
+
+	for _, h := range order {
+		reason := fmt.Sprintf("对账发现异常：%s（对账批次 #%d）", strings.Join(kinds[h], "、"), run.Id)
+		account := TeaLedgerAccount{HolderType: h.holderType, HolderId: h.holderId}
+		if _, err := FreezeTeaAccount(ctx, account, TeaFreezeRequest{Source: TeaFreezeSource_Reconcile, Reason: reason}); err != nil {
+			return fmt.Errorf("冻结星茶账户(%s%d)失败: %v", h.holderType, h.holderId, err)
+		}
+		_, err := DB.ExecContext(ctx, `
+			UPDATE tea.reconciliation_findings SET frozen = TRUE
+			WHERE run_id = $1 AND holder_type = $2 AND holder_id = $3`,
+			run.Id, h.holderType, h.holderId)
+		if err != nil {
+			return fmt.Errorf("标记对账发现失败: %v", err)
+		}
+		for i := range findings {
+			if findings[i].HolderType == h.holderType && findings[i].HolderId == h.holderId {
+				findings[i].Frozen = true
+			}
+		}
+	}
+	return nil
+}
+
+// TeaReconcileRuns 最近的对账批次
+func TeaReconcileRuns(ctx context.Context, limit int) ([]TeaReconcileRun, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT id, uuid, trigger, freeze, accounts_checked, findings_count, started_at, finished_at
+		FROM tea.reconciliation_runs
+		ORDER BY id DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询对账批次失败: %v", err)
+	}
+	defer rows.Close()
+	var runs []TeaReconcileRun
+	for rows.Next() {
+		var r TeaReconcileRun
+		if err = rows.Scan(&r.Id, &r.Uuid, &r.Trigger, &r.Freeze, &r.AccountsChecked, &r.FindingsCount, &r.StartedAt, &r.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// TeaReconcileFindingsByRun 某个对账批次的差异
+func TeaReconcileFindingsByRun(ctx context.Context, runId int) ([]TeaReconcileFinding, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT id, run_id, holder_type, holder_id, kind, expected_milligrams, actual_milligrams, frozen, created_at
+		FROM tea.reconciliation_findings
+		WHERE run_id = $1
+		ORDER BY id`, runId)
+	if err != nil {
+		return nil, fmt.Errorf("查询对账发现失败: %v", err)
+	}
+	defer rows.Close()
+	var findings []TeaReconcileFinding
+	for rows.Next() {
+		var f TeaReconcileFinding
+		if err = rows.Scan(&f.Id, &f.RunId, &f.HolderType, &f.HolderId, &f.Kind, &f.ExpectedMilligrams, &f.ActualMilligrams, &f.Frozen, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
@@ -0,0 +1,38 @@
+package dao
+
+import "testing"
+
+func TestTeaAccountSnapshotFindings(t *testing.T) {
+	cases := []struct {
+		name  string
+		s     teaAccountSnapshot
+		kinds []string
+	}{
+		{"一致", teaAccountSnapshot{Balance: 100, Locked: 30, ExpectedLocked: 30, LedgerBalance: 100}, nil},
+		{"锁定不符", teaAccountSnapshot{Balance: 100, Locked: 30, ExpectedLocked: 10, LedgerBalance: 100},
+			[]string{TeaReconcileKind_LockedMismatch}},
+		{"锁定超过余额", teaAccountSnapshot{Balance: 20, Locked: 30, ExpectedLocked: 30, LedgerBalance: 20},
+			[]string{TeaReconcileKind_NegativeAvailable}},
+		{"流水不符", teaAccountSnapshot{Balance: 150, LedgerBalance: 100},
+			[]string{TeaReconcileKind_LedgerMismatch}},
+		{"托管不足", teaAccountSnapshot{Balance: 50, LedgerBalance: 50, EscrowHeld: 80},
+			[]string{TeaReconcileKind_EscrowShortfall}},
+	}
+	for _, c := range cases {
+		got := c.s.findings()
+		if len(got) != len(c.kinds) {
+			t.Errorf("%s: 差异数 = %d, want %d (%+v)", c.name, len(got), len(c.kinds), got)
+			continue
+		}
+		for i, f := range got {
+			if f.Kind != c.kinds[i] {
+				t.Errorf("%s: 差异类型 = %s, want %s", c.name, f.Kind, c.kinds[i])
+			}
+		}
+	}
+
+	f := (&teaAccountSnapshot{Balance: 100, Locked: 30, ExpectedLocked: 10, LedgerBalance: 100}).findings()[0]
+	if f.DriftMilligrams() != 20 {
+		t.Errorf("DriftMilligrams = %d, want 20", f.DriftMilligrams())
+	}
+}
@@ -0,0 +1,147 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+星茶账户对账单：按记账流水（tea.ledger_entries）生成用户或团队账户在某一期间的对账单。
+1、期初余额：期间开始前最后一条分录的记账后余额，之前没有分录时为0；
+2、期间明细：按记账顺序列出每条分录，对方名称优先取转账记录中的名称（from_user_name、to_team_name 等发生时的名称），
+   没有转账记录的取对方现名，系统账户记为“星茶发行”；
+3、期末余额：期间最后一条分录的记账后余额，期间没有分录时等于期初余额；
+   期初余额 + 转入合计 - 转出合计 应等于期末余额，否则对账单标记为不平，须执行对账核查。
+导出CSV时，茶友填写的名称、说明等文字经 TeaStatementCSVCell 处理，防止表格软件把它当作公式执行。
+*/
+
+// TeaStatementMaxLines 单份对账单最多分录条数，超过时须缩短期间
+const TeaStatementMaxLines = 5000
+
+// TeaStatement 星茶账户对账单
+type TeaStatement struct {
+	Account        TeaLedgerAccount
+	HolderName     string
+	From           time.Time // 期间起始（含）
+	To             time.Time // 期间截止（不含）
+	OpeningBalance int64
+	ClosingBalance int64
+	TotalCredit    int64 // 转入合计
+	TotalDebit     int64 // 转出合计
+	Lines          []TeaLedgerEntry
+	GeneratedAt    time.Time
+}
+
+// Balanced 期初余额加减期间发生额是否等于期末余额
+func (s *TeaStatement) Balanced() bool {
+	return s.OpeningBalance+s.TotalCredit-s.TotalDebit == s.ClosingBalance
+}
+
+// LastDay 期间最后一天（To 不含，显示时减一天）
+func (s *TeaStatement) LastDay() time.Time {
+	return s.To.AddDate(0, 0, -1)
+}
+
+// HolderTypeString 账户类型中文
+func (s *TeaStatement) HolderTypeString() string {
+	if s.Account.HolderType == TeaAccountHolderType_Team {
+		return "团队"
+	}
+	return "茶友"
+}
+
+// TeaStatementCSVCell 以 = + - @ 制表符或回车开头的文字会被表格软件当作公式，前面加单引号按文本显示
+func TeaStatementCSVCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// summarize 由期初余额及明细计算发生额合计、期末余额
+func (s *TeaStatement) summarize() {
+	s.TotalCredit, s.TotalDebit = 0, 0
+	s.ClosingBalance = s.OpeningBalance
+	for _, e := range s.Lines {
+		if e.IsCredit() {
+			s.TotalCredit += e.AmountMilligrams
+		} else {
+			s.TotalDebit += e.AmountMilligrams
+		}
+		s.ClosingBalance = e.BalanceAfter
+	}
+}
+
+// GetTeaStatement 生成账户在 [from, to) 期间的对账单
+func GetTeaStatement(ctx context.Context, account TeaLedgerAccount, holderName string, from, to time.Time) (TeaStatement, error) {
+	st := TeaStatement{Account: account, HolderName: holderName, From: from, To: to, GeneratedAt: time.Now()}
+	if account.HolderType != TeaAccountHolderType_User && account.HolderType != TeaAccountHolderType_Team {
+		return st, fmt.Errorf("只有茶友和团队星茶账户有对账单")
+	}
+	if !from.Before(to) {
+		return st, fmt.Errorf("起始日期必须早于截止日期")
+	}
+
+	var opening sql.NullInt64
+	err := DB.QueryRowContext(ctx, `
+		SELECT balance_after FROM tea.ledger_entries
+		WHERE holder_type = $1 AND holder_id = $2 AND created_at < $3
+		ORDER BY id DESC LIMIT 1`, account.HolderType, account.HolderId, from).Scan(&opening)
+	if err != nil && err != sql.ErrNoRows {
+		return st, fmt.Errorf("查询期初余额失败: %v", err)
+	}
+	st.OpeningBalance = opening.Int64
+
+	var count int
+	err = DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM tea.ledger_entries
+		WHERE holder_type = $1 AND holder_id = $2 AND created_at >= $3 AND created_at < $4`,
+		account.HolderType, account.HolderId, from, to).Scan(&count)
+	if err != nil {
+		return st, fmt.Errorf("统计对账单分录失败: %v", err)
+	}
+	if count > TeaStatementMaxLines {
+		return st, fmt.Errorf("期间内有 %d 条流水，超过单份对账单上限 %d 条，请缩短期间", count, TeaStatementMaxLines)
+	}
+
+	// 对方名称：转出记录的借方看接收方名称，贷方看转出方名称
+	rows, err := DB.QueryContext(ctx, `
+		SELECT e.id, e.transaction_id, t.uuid, t.type, e.entry_type, e.holder_type, e.holder_id, e.direction,
+			e.amount_milligrams, COALESCE(e.balance_after, 0), e.counterparty_type, e.counterparty_id,
+			COALESCE(
+				CASE WHEN e.direction = 'debit' THEN COALESCE(uu.to_user_name, ut.to_team_name, tu.to_user_name, tt.to_team_name)
+					ELSE COALESCE(uu.from_user_name, ut.from_user_name, tu.from_team_name, tt.from_team_name) END,
+				cu.name, ct.name, '星茶发行'),
+			t.reference_table, t.reference_id, t.initiator_user_id, t.notes, e.created_at
+		FROM tea.ledger_entries e
+		JOIN tea.ledger_transactions t ON t.id = e.transaction_id
+		LEFT JOIN tea.user_to_user_transfer_out uu ON t.reference_table = 'user_to_user_transfer_out' AND uu.id = t.reference_id
+		LEFT JOIN tea.user_to_team_transfer_out ut ON t.reference_table = 'user_to_team_transfer_out' AND ut.id = t.reference_id
+		LEFT JOIN tea.team_to_user_transfer_out tu ON t.reference_table = 'team_to_user_transfer_out' AND tu.id = t.reference_id
+		LEFT JOIN tea.team_to_team_transfer_out tt ON t.reference_table = 'team_to_team_transfer_out' AND tt.id = t.reference_id
+		LEFT JOIN users cu ON e.counterparty_type = 'u' AND cu.id = e.counterparty_id
+		LEFT JOIN teams ct ON e.counterparty_type = 't' AND ct.id = e.counterparty_id
+		WHERE e.holder_type = $1 AND e.holder_id = $2 AND e.created_at >= $3 AND e.created_at < $4
+		ORDER BY e.id`, account.HolderType, account.HolderId, from, to)
+	if err != nil {
+		return st, fmt.Errorf("查询对账单分录失败: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e TeaLedgerEntry
+		if err = rows.Scan(&e.Id, &e.TransactionId, &e.TransactionUuid, &e.Type, &e.EntryType, &e.HolderType, &e.HolderId, &e.Direction,
+			&e.AmountMilligrams, &e.BalanceAfter, &e.CounterpartyType, &e.CounterpartyId,
+			&e.CounterpartyName, &e.ReferenceTable, &e.ReferenceId, &e.InitiatorUserId, &e.Notes, &e.CreatedAt); err != nil {
+			return st, fmt.Errorf("扫描对账单分录失败: %v", err)
+		}
+		st.Lines = append(st.Lines, e)
+	}
+	if err = rows.Err(); err != nil {
+		return st, err
+	}
+	st.summarize()
+	return st, nil
+}
@@ -0,0 +1,65 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTeaStatementSummarize(t *testing.T) {
+	st := TeaStatement{
+		OpeningBalance: 1000,
+		Lines: []TeaLedgerEntry{
+			{Direction: TeaLedgerDirection_Credit, AmountMilligrams: 500, BalanceAfter: 1500},
+			{Direction: TeaLedgerDirection_Debit, AmountMilligrams: 200, BalanceAfter: 1300},
+			{Direction: TeaLedgerDirection_Debit, AmountMilligrams: 300, BalanceAfter: 1000},
+		},
+	}
+	st.summarize()
+	if st.TotalCredit != 500 || st.TotalDebit != 500 || st.ClosingBalance != 1000 {
+		t.Fatalf("合计 = %d/%d，期末 = %d", st.TotalCredit, st.TotalDebit, st.ClosingBalance)
+	}
+	if !st.Balanced() {
+		t.Fatal("发生额与余额一致时应平衡")
+	}
+
+	st.Lines[2].BalanceAfter = 900
+	st.summarize()
+	if st.Balanced() {
+		t.Fatal("记账后余额与发生额不符时不应平衡")
+	}
+
+	empty := TeaStatement{OpeningBalance: 42}
+	empty.summarize()
+	if empty.ClosingBalance != 42 || !empty.Balanced() {
+		t.Fatalf("没有流水时期末应等于期初: %d", empty.ClosingBalance)
+	}
+}
+
+func TestTeaStatementLastDay(t *testing.T) {
+	st := TeaStatement{
+		From: time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local),
+		To:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local),
+	}
+	if got := st.LastDay().Format("2006-01-02"); got != "2026-01-31" {
+		t.Fatalf("LastDay = %s", got)
+	}
+}
+
+func TestTeaStatementCSVCell(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"茶友甲", "茶友甲"},
+		{"备注 =1+1", "备注 =1+1"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+86", "'+86"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+	}
+	for _, tt := range tests {
+		if got := TeaStatementCSVCell(tt.in); got != tt.want {
+			t.Errorf("TeaStatementCSVCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -22,8 +22,9 @@ import (
 团队星茶账户转账流程：
 1、发起方法：团队转出额度星茶，无论接收方是团队还是用户（个人），都由1成员填写转账表单，
    创建待审核转账表单，同时锁定转出金额（locked_balance_milligrams += 金额，余额不变）；
-2、审核方法：由任意1核心成员审批待审核表单，
-2.1、如果审核批准，转账表单状态更新为待接收，保持锁定不变
+   团队设有每日转出上限时，当日转出合计不能超过上限（见 tea_team_transfer_policy.go）；
+2、审核方法：由核心成员审批待审核表单，默认任意1人批准即可，团队转出审批策略可要求大额转出多人签批、禁止发起人自批，
+2.1、如果批准人数满足策略，转账表单状态更新为待接收，保持锁定不变
      （等待对方确认接收时再扣减余额并释放锁定）；
 2.2、如果审核否决，转账表单状态更新为已否决，释放锁定
      （locked_balance_milligrams -= 金额，余额不变），流程结束；
@@ -422,15 +423,16 @@ func processExpiredTransfers(tableName string) error {
 			return fmt.Errorf("更新待接收过期转账状态失败: %v", err)
 		}
 
-		// 退回余额到团队账户
+		// 释放锁定金额（余额在对方确认接收时才扣减，此处不能增加余额）
 		_, err = tx.Exec(`
 			UPDATE tea.team_accounts 
-			SET balance_milligrams = balance_milligrams + $1,
+			SET locked_balance_milligrams = locked_balance_milligrams - $1,
 			    updated_at = $2
-			WHERE team_id = $3`,
+			WHERE team_id = $3
+			AND locked_balance_milligrams >= $1`,
 			et.Amount, time.Now(), et.FromTeamId)
 		if err != nil {
-			return fmt.Errorf("退回余额失败: %v", err)
+			return fmt.Errorf("释放锁定金额失败: %v", err)
 		}
 	}
 
@@ -499,6 +501,9 @@ func CreateTeaTeamToUserTransferOut(fromTeamId, initiatorUserId, toUserId int, a
 	if availableBalance < amountMilligrams {
 		return transfer, fmt.Errorf("团队星茶可用余额不足，可用余额: %d 毫克，需要: %d 毫克", availableBalance, amountMilligrams)
 	}
+	if err = checkTeaTeamDailyOutflowTx(tx, fromTeamId, amountMilligrams); err != nil {
+		return transfer, err
+	}
 
 	// 3. 锁定转出金额（增加 locked_balance_milligrams，与用户转账一致）
 	_, err = tx.Exec(`
@@ -594,6 +599,9 @@ func CreateTeaTeamToTeamTransferOut(fromTeamId, initiatorUserId, toTeamId int, a
 	if availableBalance < amountMilligrams {
 		return transfer, fmt.Errorf("团队星茶可用余额不足，可用余额: %d 毫克，需要: %d 毫克", availableBalance, amountMilligrams)
 	}
+	if err = checkTeaTeamDailyOutflowTx(tx, fromTeamId, amountMilligrams); err != nil {
+		return transfer, err
+	}
 
 	// 3. 锁定转出金额（增加 locked_balance_milligrams，与用户转账一致）
 	_, err = tx.Exec(`
@@ -772,7 +780,21 @@ func CreateEscrowTransferOut(fromTeamId, initiatorUserId int, amountMilligrams i
 		return transfer, fmt.Errorf("创建茶庄托管团队接收记录失败: %v", err)
 	}
 
-	// 7. 提交事务
+	// 7. 记账
+	if err = postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             TeaLedgerType_Escrow,
+		From:             TeaTeamLedgerAccount(fromTeamId),
+		To:               TeaTeamLedgerAccount(toTeamId),
+		AmountMilligrams: amountMilligrams,
+		ReferenceTable:   "team_to_team_transfer_out",
+		ReferenceId:      transfer.Id,
+		InitiatorUserId:  initiatorUserId,
+		Notes:            notes,
+	}); err != nil {
+		return transfer, err
+	}
+
+	// 8. 提交事务
 	if err = tx.Commit(); err != nil {
 		return transfer, fmt.Errorf("提交事务失败: %v", err)
 	}
@@ -898,36 +920,49 @@ func TeaTeamPendingApprovalToUserTransfers(teamId, page, limit int, ctx context.
 }
 
 // TeaTeamApproveToUserTransferOut 某个团队核心成员,审批通过,团队对用户转账 0211
-// 流程：审批通过后，更新状态为待接收，保持锁定不变，等待对方确认接收时再扣减余额
-func TeaTeamApproveToUserTransferOut(fromTeamId int, transferUuid string, approverUserId int) error {
+// 流程：按团队转出审批策略记录批准，批准人数满足策略后更新状态为待接收，保持锁定不变，等待对方确认接收时再扣减余额；
+// 返回还需批准的人数，0表示已进入待接收
+func TeaTeamApproveToUserTransferOut(fromTeamId int, transferUuid string, approverUserId int) (int, error) {
 	tx, err := DB.Begin()
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %v", err)
+		return 0, fmt.Errorf("开始事务失败: %v", err)
 	}
 	defer tx.Rollback()
 
 	// 首先锁定并获取转账记录详情
-	var transferOutID int
+	var transferOutID, initiatorUserId int
 	var amountMg int64
 	var toUserId int
 	var toUserName, fromTeamName, notes string
 	err = tx.QueryRow(`
-		SELECT id, amount_milligrams, to_user_id, to_user_name, from_team_name, notes
+		SELECT id, initiator_user_id, amount_milligrams, to_user_id, to_user_name, from_team_name, notes
 		FROM tea.team_to_user_transfer_out 
 		WHERE from_team_id = $1 AND uuid = $2 AND status = $3
-		FOR UPDATE SKIP LOCKED`,
+		FOR UPDATE`,
 		fromTeamId, transferUuid, TeaTransferStatusPendingApproval,
-	).Scan(&transferOutID, &amountMg, &toUserId, &toUserName, &fromTeamName, &notes)
+	).Scan(&transferOutID, &initiatorUserId, &amountMg, &toUserId, &toUserName, &fromTeamName, &notes)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("未找到待审批的转账记录")
+			return 0, fmt.Errorf("未找到待审批的转账记录")
 		}
-		return fmt.Errorf("查询转账记录失败: %v", err)
+		return 0, fmt.Errorf("查询转账记录失败: %v", err)
 	}
 
 	if amountMg <= 0 {
-		return fmt.Errorf("转账金额无效: %d", amountMg)
+		return 0, fmt.Errorf("转账金额无效: %d", amountMg)
+	}
+
+	// 按团队策略记录本人批准，人数未满足时保持待审批
+	remaining, err := approveTeaTeamTransferTx(tx, TeaTeamTransferTable_ToUser, transferOutID, fromTeamId, initiatorUserId, amountMg, approverUserId)
+	if err != nil {
+		return 0, err
+	}
+	if remaining > 0 {
+		if err = tx.Commit(); err != nil {
+			return 0, fmt.Errorf("提交事务失败: %v", err)
+		}
+		return remaining, nil
 	}
 
 	now := time.Now()
@@ -940,50 +975,63 @@ func TeaTeamApproveToUserTransferOut(fromTeamId int, transferUuid string, approv
 		WHERE id = $5 AND status = $6`,
 		TeaTransferStatusPendingReceipt, approverUserId, now, now, transferOutID, TeaTransferStatusPendingApproval)
 	if err != nil {
-		return fmt.Errorf("更新转账审批状态失败: %v", err)
+		return 0, fmt.Errorf("更新转账审批状态失败: %v", err)
 	}
 
 	// 2. 审批通过时只更新状态，不扣减余额，保持锁定不变
 	// 余额扣减和锁定释放将在对方确认接收时处理
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return 0, fmt.Errorf("提交事务失败: %v", err)
 	}
 
-	return nil
+	return 0, nil
 }
 
 // TeaTeamApproveToTeamTransferOut 某个团队核心成员,审批通过,团队对团队转账 0211
-// 流程：审批通过后，更新状态为待接收，保持锁定不变，等待对方确认接收时再扣减余额
-func TeaTeamApproveToTeamTransferOut(fromTeamId int, transferUuid string, approverUserId int) error {
+// 流程：按团队转出审批策略记录批准，批准人数满足策略后更新状态为待接收，保持锁定不变，等待对方确认接收时再扣减余额；
+// 返回还需批准的人数，0表示已进入待接收
+func TeaTeamApproveToTeamTransferOut(fromTeamId int, transferUuid string, approverUserId int) (int, error) {
 	tx, err := DB.Begin()
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %v", err)
+		return 0, fmt.Errorf("开始事务失败: %v", err)
 	}
 	defer tx.Rollback()
 
 	// 首先锁定并获取转账记录详情
-	var transferOutID int
+	var transferOutID, initiatorUserId int
 	var amountMg int64
 	var toTeamId int
 	var toTeamName, fromTeamName, notes string
 	err = tx.QueryRow(`
-		SELECT id, amount_milligrams, to_team_id, to_team_name, from_team_name, notes
+		SELECT id, initiator_user_id, amount_milligrams, to_team_id, to_team_name, from_team_name, notes
 		FROM tea.team_to_team_transfer_out 
 		WHERE from_team_id = $1 AND uuid = $2 AND status = $3
-		FOR UPDATE SKIP LOCKED`,
+		FOR UPDATE`,
 		fromTeamId, transferUuid, TeaTransferStatusPendingApproval,
-	).Scan(&transferOutID, &amountMg, &toTeamId, &toTeamName, &fromTeamName, &notes)
+	).Scan(&transferOutID, &initiatorUserId, &amountMg, &toTeamId, &toTeamName, &fromTeamName, &notes)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("未找到待审批的转账记录")
+			return 0, fmt.Errorf("未找到待审批的转账记录")
 		}
-		return fmt.Errorf("查询转账记录失败: %v", err)
+		return 0, fmt.Errorf("查询转账记录失败: %v", err)
 	}
 
 	if amountMg <= 0 {
-		return fmt.Errorf("转账金额无效: %d", amountMg)
+		return 0, fmt.Errorf("转账金额无效: %d", amountMg)
+	}
+
+	// 按团队策略记录本人批准，人数未满足时保持待审批
+	remaining, err := approveTeaTeamTransferTx(tx, TeaTeamTransferTable_ToTeam, transferOutID, fromTeamId, initiatorUserId, amountMg, approverUserId)
+	if err != nil {
+		return 0, err
+	}
+	if remaining > 0 {
+		if err = tx.Commit(); err != nil {
+			return 0, fmt.Errorf("提交事务失败: %v", err)
+		}
+		return remaining, nil
 	}
 
 	now := time.Now()
@@ -996,17 +1044,17 @@ func TeaTeamApproveToTeamTransferOut(fromTeamId int, transferUuid string, approv
 		WHERE id = $5 AND status = $6`,
 		TeaTransferStatusPendingReceipt, approverUserId, now, now, transferOutID, TeaTransferStatusPendingApproval)
 	if err != nil {
-		return fmt.Errorf("更新转账审批状态失败: %v", err)
+		return 0, fmt.Errorf("更新转账审批状态失败: %v", err)
 	}
 
 	// 2. 审批通过时只更新状态，不扣减余额，保持锁定不变
 	// 余额扣减和锁定释放将在对方确认接收时处理
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
+		return 0, fmt.Errorf("提交事务失败: %v", err)
 	}
 
-	return nil
+	return 0, nil
 }
 
 // TeaTeamRejectToUserTransferOut 某个团队核心成员,拒绝审批,团队对用户转账 0211
@@ -1533,7 +1581,8 @@ func TeaTeamConfirmFromUserTransfer(transferUuid string, toTeamId, operationalUs
 	}
 
 	// 2. 更新转出用户账户（减少余额和锁定金额）
-	_, err = tx.Exec(`
+	var senderAccountId int
+	err = tx.QueryRow(`
 		UPDATE tea.user_accounts 
 		SET balance_milligrams = balance_milligrams - $1,
 			locked_balance_milligrams = locked_balance_milligrams - $1,
@@ -1541,7 +1590,7 @@ func TeaTeamConfirmFromUserTransfer(transferUuid string, toTeamId, operationalUs
 		WHERE user_id = $3 
 		AND locked_balance_milligrams >= $1
 		RETURNING id`,
-		amountMg, now, fromUserId)
+		amountMg, now, fromUserId).Scan(&senderAccountId)
 	if err != nil {
 		return fmt.Errorf("更新转出用户账户失败: %v", err)
 	}
@@ -1573,6 +1622,20 @@ func TeaTeamConfirmFromUserTransfer(transferUuid string, toTeamId, operationalUs
 		return fmt.Errorf("创建接收来自用户转账记录失败: %v", err)
 	}
 
+	// 5. 记账
+	if err = postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             TeaLedgerType_Transfer,
+		From:             TeaUserLedgerAccount(fromUserId),
+		To:               TeaTeamLedgerAccount(toTeamId),
+		AmountMilligrams: amountMg,
+		ReferenceTable:   "user_to_team_transfer_out",
+		ReferenceId:      transferOutID,
+		InitiatorUserId:  operationalUserId,
+		Notes:            notes,
+	}); err != nil {
+		return err
+	}
+
 	// 提交事务
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交事务失败: %v", err)
@@ -1627,7 +1690,8 @@ func TeaTeamConfirmFromTeamTransfer(transferUuid string, toTeamId, operationalUs
 	}
 
 	// 2. 更新转出团队账户（减少余额和锁定金额）
-	_, err = tx.Exec(`
+	var senderAccountId int
+	err = tx.QueryRow(`
 		UPDATE tea.team_accounts 
 		SET balance_milligrams = balance_milligrams - $1,
 			locked_balance_milligrams = locked_balance_milligrams - $1,
@@ -1635,7 +1699,7 @@ func TeaTeamConfirmFromTeamTransfer(transferUuid string, toTeamId, operationalUs
 		WHERE team_id = $3 
 		AND locked_balance_milligrams >= $1
 		RETURNING id`,
-		amountMg, now, fromTeamId)
+		amountMg, now, fromTeamId).Scan(&senderAccountId)
 	if err != nil {
 		return fmt.Errorf("更新转出团队账户失败: %v", err)
 	}
@@ -1667,6 +1731,20 @@ func TeaTeamConfirmFromTeamTransfer(transferUuid string, toTeamId, operationalUs
 		return fmt.Errorf("创建接收来自团队转账记录失败: %v", err)
 	}
 
+	// 5. 记账
+	if err = postTeaLedgerTx(tx, teaLedgerPosting{
+		Type:             TeaLedgerType_Transfer,
+		From:             TeaTeamLedgerAccount(fromTeamId),
+		To:               TeaTeamLedgerAccount(toTeamId),
+		AmountMilligrams: amountMg,
+		ReferenceTable:   "team_to_team_transfer_out",
+		ReferenceId:      transferOutID,
+		InitiatorUserId:  operationalUserId,
+		Notes:            notes,
+	}); err != nil {
+		return err
+	}
+
 	// 提交事务
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交事务失败: %v", err)
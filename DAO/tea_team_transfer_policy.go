@@ -0,0 +1,404 @@
+package dao
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/*
+团队星茶转出审批策略（tea.team_transfer_policies / tea.team_transfer_approvals）：
+1、每个团队星茶账户可设一条策略，没有策略时沿用原规则：任一核心成员批准即可，不限每日转出；
+2、大额：转出金额超过阈值时，须 LargeAmountApprovals 位不同核心成员批准，否则1位；
+3、禁止自批：发起人不能批准自己发起的转账；
+4、每日上限：当天（数据库时区零点起）已发起且未被否决、拒收、超时的转出，加上本笔不能超过上限，发起时检查；
+5、每位核心成员的批准记一行，批准人数满足策略后转账才进入待接收（pending_receipt），
+   任一核心成员否决即终止（approval_rejected）并解锁；
+6、策略只能由CEO或创建人修改，每次修改写入 tea.team_transfer_policy_changes：
+   收紧的部分立即生效，放宽的部分排期，等待期满才生效，期间其他核心成员可以看到并提出异议；
+   排期到点后读取策略即按新取值，下次修改时写回策略表。
+*/
+
+// 团队转出表名，写入批准记录
+const (
+	TeaTeamTransferTable_ToUser = "team_to_user_transfer_out"
+	TeaTeamTransferTable_ToTeam = "team_to_team_transfer_out"
+)
+
+// TeaTeamTransferMaxApprovals 策略可要求的最多批准人数，与数据库约束一致
+const TeaTeamTransferMaxApprovals = 9
+
+// TeaTeamTransferPolicy 团队星茶转出审批策略
+type TeaTeamTransferPolicy struct {
+	Id                             int
+	TeamId                         int
+	LargeAmountThresholdMilligrams int64 // 大额阈值，0表示不区分大额
+	LargeAmountApprovals           int   // 大额转出须批准人数
+	ForbidSelfApproval             bool  // 禁止发起人批准自己发起的转账
+	DailyOutflowCapMilligrams      int64 // 每日转出上限，0表示不限
+	UpdatedByUserId                int
+	CreatedAt                      time.Time
+	UpdatedAt                      *time.Time
+}
+
+// 策略修改记录状态
+const (
+	TeaTeamTransferPolicyChangeApplied    = "applied"    // 已生效
+	TeaTeamTransferPolicyChangeScheduled  = "scheduled"  // 放宽，待生效
+	TeaTeamTransferPolicyChangeSuperseded = "superseded" // 生效前被再次修改，已作废
+)
+
+// TeaTeamTransferPolicyChange 团队转出审批策略的一次修改，Old、New 只含四项规则取值
+type TeaTeamTransferPolicyChange struct {
+	Id              int
+	TeamId          int
+	ChangedByUserId int
+	ChangedByName   string // 修改人名字，仅列表查询时填写
+	Old             TeaTeamTransferPolicy
+	New             TeaTeamTransferPolicy
+	Status          string
+	EffectiveAt     time.Time
+	CreatedAt       time.Time
+}
+
+// TeaTeamTransferApproval 团队转出的一条批准记录
+type TeaTeamTransferApproval struct {
+	Id             int
+	TransferTable  string
+	TransferId     int
+	TeamId         int
+	ApproverUserId int
+	CreatedAt      time.Time
+}
+
+// DefaultTeaTeamTransferPolicy 未设置策略时的默认规则
+func DefaultTeaTeamTransferPolicy(teamId int) TeaTeamTransferPolicy {
+	return TeaTeamTransferPolicy{TeamId: teamId, LargeAmountApprovals: 1}
+}
+
+// RequiredApprovals 转出金额须批准的人数
+func (p *TeaTeamTransferPolicy) RequiredApprovals(amountMg int64) int {
+	if p.LargeAmountThresholdMilligrams > 0 && amountMg > p.LargeAmountThresholdMilligrams && p.LargeAmountApprovals > 1 {
+		return p.LargeAmountApprovals
+	}
+	return 1
+}
+
+// SameRules 两个策略的四项规则取值是否相同
+func (p *TeaTeamTransferPolicy) SameRules(q TeaTeamTransferPolicy) bool {
+	return p.LargeAmountThresholdMilligrams == q.LargeAmountThresholdMilligrams && p.LargeAmountApprovals == q.LargeAmountApprovals &&
+		p.ForbidSelfApproval == q.ForbidSelfApproval && p.DailyOutflowCapMilligrams == q.DailyOutflowCapMilligrams
+}
+
+// stricterTeaTeamTransferPolicy 逐项取两个策略中更严格的取值：批准人数取多，禁止自批取是，阈值与上限取小（0表示不设，最宽松）
+func stricterTeaTeamTransferPolicy(a, b TeaTeamTransferPolicy) TeaTeamTransferPolicy {
+	s := a
+	s.LargeAmountApprovals = max(a.LargeAmountApprovals, b.LargeAmountApprovals)
+	s.ForbidSelfApproval = a.ForbidSelfApproval || b.ForbidSelfApproval
+	s.LargeAmountThresholdMilligrams = stricterLimit(a.LargeAmountThresholdMilligrams, b.LargeAmountThresholdMilligrams)
+	s.DailyOutflowCapMilligrams = stricterLimit(a.DailyOutflowCapMilligrams, b.DailyOutflowCapMilligrams)
+	return s
+}
+
+// stricterLimit 0表示不设，取两者中更严格的
+func stricterLimit(a, b int64) int64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	return min(a, b)
+}
+
+// Validate 检查策略取值；coreMembers 为团队现有核心成员人数，策略不能要求多于可批准的人数
+func (p *TeaTeamTransferPolicy) Validate(coreMembers int) error {
+	if p.LargeAmountThresholdMilligrams < 0 || p.DailyOutflowCapMilligrams < 0 {
+		return fmt.Errorf("阈值和每日上限不能为负数")
+	}
+	if p.LargeAmountApprovals < 1 || p.LargeAmountApprovals > TeaTeamTransferMaxApprovals {
+		return fmt.Errorf("大额转出批准人数须在1到%d之间", TeaTeamTransferMaxApprovals)
+	}
+	eligible := coreMembers
+	if p.ForbidSelfApproval {
+		// 发起人可能是核心成员，保守起见不计入
+		eligible--
+		if eligible < 1 {
+			return fmt.Errorf("团队只有%d位核心成员，禁止自批后无人可以批准转账", coreMembers)
+		}
+	}
+	if p.LargeAmountThresholdMilligrams > 0 && p.LargeAmountApprovals > eligible {
+		return fmt.Errorf("大额转出须%d人批准，但团队只有%d位核心成员可以批准", p.LargeAmountApprovals, eligible)
+	}
+	return nil
+}
+
+// GetTeaTeamTransferPolicy 读取团队转出审批策略，未设置时返回默认规则
+func GetTeaTeamTransferPolicy(teamId int) (TeaTeamTransferPolicy, error) {
+	return getTeaTeamTransferPolicy(DB, teamId)
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func getTeaTeamTransferPolicy(q queryRower, teamId int) (TeaTeamTransferPolicy, error) {
+	p := DefaultTeaTeamTransferPolicy(teamId)
+	err := q.QueryRow(`
+		SELECT id, team_id, large_amount_threshold_milligrams, large_amount_approvals, forbid_self_approval,
+			daily_outflow_cap_milligrams, updated_by_user_id, created_at, updated_at
+		FROM tea.team_transfer_policies WHERE team_id = $1`, teamId).
+		Scan(&p.Id, &p.TeamId, &p.LargeAmountThresholdMilligrams, &p.LargeAmountApprovals, &p.ForbidSelfApproval,
+			&p.DailyOutflowCapMilligrams, &p.UpdatedByUserId, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		p = DefaultTeaTeamTransferPolicy(teamId)
+	} else if err != nil {
+		return p, fmt.Errorf("读取团队转出审批策略失败: %v", err)
+	}
+	// 到期的放宽排期按新取值生效，下次修改时写回策略表
+	var effectiveAt time.Time
+	err = q.QueryRow(`
+		SELECT new_large_amount_threshold_milligrams, new_large_amount_approvals, new_forbid_self_approval,
+			new_daily_outflow_cap_milligrams, changed_by_user_id, effective_at
+		FROM tea.team_transfer_policy_changes
+		WHERE team_id = $1 AND status = $2 AND effective_at <= CURRENT_TIMESTAMP`, teamId, TeaTeamTransferPolicyChangeScheduled).
+		Scan(&p.LargeAmountThresholdMilligrams, &p.LargeAmountApprovals, &p.ForbidSelfApproval,
+			&p.DailyOutflowCapMilligrams, &p.UpdatedByUserId, &effectiveAt)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("读取团队转出审批策略排期失败: %v", err)
+	}
+	p.UpdatedAt = &effectiveAt
+	return p, nil
+}
+
+// SaveTeaTeamTransferPolicy 由CEO或创建人修改团队转出审批策略，调用前须 Validate；coreMembers 为团队现有核心成员人数。
+// 比当前策略严格的部分立即生效，放宽的部分排期 loosenDelay 后生效，返回排期的修改（没有放宽时为nil）；
+// 此前尚未生效的排期作废。已在审批中的转账按批准时的策略判断
+func SaveTeaTeamTransferPolicy(p *TeaTeamTransferPolicy, coreMembers int, loosenDelay time.Duration) (*TeaTeamTransferPolicyChange, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	// 锁定团队，同一团队的策略修改依次进行
+	if _, err = tx.Exec(`SELECT id FROM teams WHERE id = $1 FOR UPDATE`, p.TeamId); err != nil {
+		return nil, fmt.Errorf("锁定团队失败: %v", err)
+	}
+	current, err := getTeaTeamTransferPolicy(tx, p.TeamId)
+	if err != nil {
+		return nil, err
+	}
+	// 已到期的排期已体现在 current 中，与尚未到期的排期一起结束：到期的记为已生效并写回策略表，未到期的作废
+	if _, err = tx.Exec(`
+		UPDATE tea.team_transfer_policy_changes
+		SET status = CASE WHEN effective_at <= CURRENT_TIMESTAMP THEN $3 ELSE $4 END
+		WHERE team_id = $1 AND status = $2`,
+		p.TeamId, TeaTeamTransferPolicyChangeScheduled, TeaTeamTransferPolicyChangeApplied, TeaTeamTransferPolicyChangeSuperseded); err != nil {
+		return nil, fmt.Errorf("结束策略排期失败: %v", err)
+	}
+
+	immediate := stricterTeaTeamTransferPolicy(current, *p)
+	if immediate.Validate(coreMembers) != nil {
+		// 逐项收紧后核心成员人数不够批准，整个修改按放宽排期
+		immediate = current
+	}
+	immediate.UpdatedByUserId = p.UpdatedByUserId
+	if err = upsertTeaTeamTransferPolicyTx(tx, &immediate); err != nil {
+		return nil, err
+	}
+	if !immediate.SameRules(current) {
+		if _, err = insertTeaTeamTransferPolicyChangeTx(tx, current, immediate, TeaTeamTransferPolicyChangeApplied, 0); err != nil {
+			return nil, err
+		}
+	}
+	var scheduled *TeaTeamTransferPolicyChange
+	if !p.SameRules(immediate) {
+		if scheduled, err = insertTeaTeamTransferPolicyChangeTx(tx, immediate, *p, TeaTeamTransferPolicyChangeScheduled, loosenDelay); err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+	*p = immediate
+	return scheduled, nil
+}
+
+// upsertTeaTeamTransferPolicyTx 新建或更新策略表中的团队策略
+func upsertTeaTeamTransferPolicyTx(tx *sql.Tx, p *TeaTeamTransferPolicy) error {
+	err := tx.QueryRow(`
+		INSERT INTO tea.team_transfer_policies
+			(team_id, large_amount_threshold_milligrams, large_amount_approvals, forbid_self_approval, daily_outflow_cap_milligrams, updated_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (team_id) DO UPDATE SET
+			large_amount_threshold_milligrams = EXCLUDED.large_amount_threshold_milligrams,
+			large_amount_approvals = EXCLUDED.large_amount_approvals,
+			forbid_self_approval = EXCLUDED.forbid_self_approval,
+			daily_outflow_cap_milligrams = EXCLUDED.daily_outflow_cap_milligrams,
+			updated_by_user_id = EXCLUDED.updated_by_user_id
+		RETURNING id, created_at, updated_at`,
+		p.TeamId, p.LargeAmountThresholdMilligrams, p.LargeAmountApprovals, p.ForbidSelfApproval,
+		p.DailyOutflowCapMilligrams, p.UpdatedByUserId).
+		Scan(&p.Id, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存团队转出审批策略失败: %v", err)
+	}
+	return nil
+}
+
+// insertTeaTeamTransferPolicyChangeTx 记录一次策略修改，delay 为生效等待时间
+func insertTeaTeamTransferPolicyChangeTx(tx *sql.Tx, old, next TeaTeamTransferPolicy, status string, delay time.Duration) (*TeaTeamTransferPolicyChange, error) {
+	c := TeaTeamTransferPolicyChange{TeamId: next.TeamId, ChangedByUserId: next.UpdatedByUserId, Old: old, New: next, Status: status}
+	err := tx.QueryRow(`
+		INSERT INTO tea.team_transfer_policy_changes (team_id, changed_by_user_id,
+			old_large_amount_threshold_milligrams, old_large_amount_approvals, old_forbid_self_approval, old_daily_outflow_cap_milligrams,
+			new_large_amount_threshold_milligrams, new_large_amount_approvals, new_forbid_self_approval, new_daily_outflow_cap_milligrams,
+			status, effective_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP + $12 * INTERVAL '1 second')
+		RETURNING id, effective_at, created_at`,
+		c.TeamId, c.ChangedByUserId,
+		old.LargeAmountThresholdMilligrams, old.LargeAmountApprovals, old.ForbidSelfApproval, old.DailyOutflowCapMilligrams,
+		next.LargeAmountThresholdMilligrams, next.LargeAmountApprovals, next.ForbidSelfApproval, next.DailyOutflowCapMilligrams,
+		status, int64(delay/time.Second)).
+		Scan(&c.Id, &c.EffectiveAt, &c.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("记录团队转出审批策略修改失败: %v", err)
+	}
+	return &c, nil
+}
+
+// TeaTeamTransferPolicyChanges 团队转出审批策略的修改记录（按时间倒序）
+func TeaTeamTransferPolicyChanges(teamId, limit int) ([]TeaTeamTransferPolicyChange, error) {
+	rows, err := DB.Query(`
+		SELECT c.id, c.team_id, c.changed_by_user_id, u.name,
+			c.old_large_amount_threshold_milligrams, c.old_large_amount_approvals, c.old_forbid_self_approval, c.old_daily_outflow_cap_milligrams,
+			c.new_large_amount_threshold_milligrams, c.new_large_amount_approvals, c.new_forbid_self_approval, c.new_daily_outflow_cap_milligrams,
+			c.status, c.effective_at, c.created_at
+		FROM tea.team_transfer_policy_changes c
+		JOIN users u ON u.id = c.changed_by_user_id
+		WHERE c.team_id = $1 ORDER BY c.id DESC LIMIT $2`, teamId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询团队转出审批策略修改记录失败: %v", err)
+	}
+	defer rows.Close()
+	var changes []TeaTeamTransferPolicyChange
+	for rows.Next() {
+		var c TeaTeamTransferPolicyChange
+		if err = rows.Scan(&c.Id, &c.TeamId, &c.ChangedByUserId, &c.ChangedByName,
+			&c.Old.LargeAmountThresholdMilligrams, &c.Old.LargeAmountApprovals, &c.Old.ForbidSelfApproval, &c.Old.DailyOutflowCapMilligrams,
+			&c.New.LargeAmountThresholdMilligrams, &c.New.LargeAmountApprovals, &c.New.ForbidSelfApproval, &c.New.DailyOutflowCapMilligrams,
+			&c.Status, &c.EffectiveAt, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取团队转出审批策略修改记录失败: %v", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// Pending 放宽排期尚未生效
+func (c *TeaTeamTransferPolicyChange) Pending() bool {
+	return c.Status == TeaTeamTransferPolicyChangeScheduled && c.EffectiveAt.After(time.Now())
+}
+
+// StatusString 修改记录状态中文描述，到期的排期视为已生效
+func (c *TeaTeamTransferPolicyChange) StatusString() string {
+	switch {
+	case c.Pending():
+		return "待生效"
+	case c.Status == TeaTeamTransferPolicyChangeSuperseded:
+		return "已作废"
+	default:
+		return "已生效"
+	}
+}
+
+// checkTeaTeamDailyOutflowTx 在发起转出的事务中检查每日转出上限（转出团队账户行已锁定，同一团队的发起依次进行）
+func checkTeaTeamDailyOutflowTx(tx *sql.Tx, teamId int, amountMg int64) error {
+	policy, err := getTeaTeamTransferPolicy(tx, teamId)
+	if err != nil {
+		return err
+	}
+	if policy.DailyOutflowCapMilligrams <= 0 {
+		return nil
+	}
+	var today int64
+	err = tx.QueryRow(`
+		SELECT
+			COALESCE((SELECT SUM(amount_milligrams) FROM tea.team_to_user_transfer_out
+				WHERE from_team_id = $1 AND created_at >= date_trunc('day', CURRENT_TIMESTAMP) AND status NOT IN ($2, $3, $4)), 0)
+			+ COALESCE((SELECT SUM(amount_milligrams) FROM tea.team_to_team_transfer_out
+				WHERE from_team_id = $1 AND created_at >= date_trunc('day', CURRENT_TIMESTAMP) AND status NOT IN ($2, $3, $4)), 0)`,
+		teamId, TeaTransferStatusApprovalRejected, TeaTransferStatusRejected, TeaTransferStatusExpired).Scan(&today)
+	if err != nil {
+		return fmt.Errorf("统计团队当日转出失败: %v", err)
+	}
+	if today+amountMg > policy.DailyOutflowCapMilligrams {
+		return fmt.Errorf("超过团队每日转出上限 %d 毫克，今日已转出 %d 毫克", policy.DailyOutflowCapMilligrams, today)
+	}
+	return nil
+}
+
+// approveTeaTeamTransferTx 在审批事务中（转账记录已锁定）按策略记录一位核心成员的批准，返回还需批准的人数，0表示已满足策略
+func approveTeaTeamTransferTx(tx *sql.Tx, transferTable string, transferId, teamId, initiatorUserId int, amountMg int64, approverUserId int) (int, error) {
+	policy, err := getTeaTeamTransferPolicy(tx, teamId)
+	if err != nil {
+		return 0, err
+	}
+	if policy.ForbidSelfApproval && approverUserId == initiatorUserId {
+		return 0, fmt.Errorf("团队策略禁止发起人批准自己发起的转账")
+	}
+	result, err := tx.Exec(`
+		INSERT INTO tea.team_transfer_approvals (transfer_table, transfer_id, team_id, approver_user_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (transfer_table, transfer_id, approver_user_id) DO NOTHING`,
+		transferTable, transferId, teamId, approverUserId)
+	if err != nil {
+		return 0, fmt.Errorf("记录转账批准失败: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return 0, fmt.Errorf("您已经批准过这笔转账")
+	}
+
+	// 只统计仍是核心成员的批准人，批准后离任的不计入
+	var approvals int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM tea.team_transfer_approvals a
+		JOIN team_members m ON m.team_id = a.team_id AND m.user_id = a.approver_user_id
+		WHERE a.transfer_table = $1 AND a.transfer_id = $2
+			AND m.role IN ($3, $4, $5, $6) AND m.status = $7`,
+		transferTable, transferId, RoleCEO, RoleCTO, RoleCMO, RoleCFO, TeamMemberStatusActive).Scan(&approvals)
+	if err != nil {
+		return 0, fmt.Errorf("统计转账批准人数失败: %v", err)
+	}
+	remaining := policy.RequiredApprovals(amountMg) - approvals
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, nil
+}
+
+// TeaTeamTransferApprovals 一笔团队转出的批准记录（按时间顺序）
+func TeaTeamTransferApprovals(transferTable string, transferId int) ([]TeaTeamTransferApproval, error) {
+	rows, err := DB.Query(`
+		SELECT id, transfer_table, transfer_id, team_id, approver_user_id, created_at
+		FROM tea.team_transfer_approvals
+		WHERE transfer_table = $1 AND transfer_id = $2
+		ORDER BY id`, transferTable, transferId)
+	if err != nil {
+		return nil, fmt.Errorf("查询转账批准记录失败: %v", err)
+	}
+	defer rows.Close()
+	var approvals []TeaTeamTransferApproval
+	for rows.Next() {
+		var a TeaTeamTransferApproval
+		if err = rows.Scan(&a.Id, &a.TransferTable, &a.TransferId, &a.TeamId, &a.ApproverUserId, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取转账批准记录失败: %v", err)
+		}
+		approvals = append(approvals, a)
+	}
+	return approvals, rows.Err()
+}
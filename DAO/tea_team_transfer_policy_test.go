@@ -0,0 +1,70 @@
+package dao
+
+import "testing"
+
+func TestTeaTeamTransferPolicyRequiredApprovals(t *testing.T) {
+	p := TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 1000, LargeAmountApprovals: 3}
+	cases := map[int64]int{500: 1, 1000: 1, 1001: 3}
+	for amount, want := range cases {
+		if got := p.RequiredApprovals(amount); got != want {
+			t.Errorf("RequiredApprovals(%d) = %d, want %d", amount, got, want)
+		}
+	}
+	d := DefaultTeaTeamTransferPolicy(7)
+	if got := d.RequiredApprovals(1 << 40); got != 1 {
+		t.Errorf("默认策略 RequiredApprovals = %d, want 1", got)
+	}
+}
+
+func TestTeaTeamTransferPolicyValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  TeaTeamTransferPolicy
+		core    int
+		wantErr bool
+	}{
+		{"默认", TeaTeamTransferPolicy{LargeAmountApprovals: 1}, 1, false},
+		{"两人签批", TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 100, LargeAmountApprovals: 2}, 2, false},
+		{"人数不足", TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 100, LargeAmountApprovals: 3}, 2, true},
+		{"禁止自批后人数不足", TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 100, LargeAmountApprovals: 2, ForbidSelfApproval: true}, 2, true},
+		{"单人团队禁止自批", TeaTeamTransferPolicy{LargeAmountApprovals: 1, ForbidSelfApproval: true}, 1, true},
+		{"批准人数为0", TeaTeamTransferPolicy{}, 3, true},
+		{"负数上限", TeaTeamTransferPolicy{LargeAmountApprovals: 1, DailyOutflowCapMilligrams: -1}, 3, true},
+	}
+	for _, c := range cases {
+		err := c.policy.Validate(c.core)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: Validate = %v, wantErr %t", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestStricterTeaTeamTransferPolicy(t *testing.T) {
+	current := TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 1000, LargeAmountApprovals: 3, ForbidSelfApproval: true, DailyOutflowCapMilligrams: 5000}
+	cases := []struct {
+		name      string
+		requested TeaTeamTransferPolicy
+		want      TeaTeamTransferPolicy
+	}{
+		{"全部放宽，保持原策略",
+			TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 0, LargeAmountApprovals: 1, DailyOutflowCapMilligrams: 0},
+			current},
+		{"全部收紧，立即生效",
+			TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 500, LargeAmountApprovals: 4, ForbidSelfApproval: true, DailyOutflowCapMilligrams: 2000},
+			TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 500, LargeAmountApprovals: 4, ForbidSelfApproval: true, DailyOutflowCapMilligrams: 2000}},
+		{"收紧人数、放宽上限，只收紧人数",
+			TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 1000, LargeAmountApprovals: 4, ForbidSelfApproval: true, DailyOutflowCapMilligrams: 9000},
+			TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 1000, LargeAmountApprovals: 4, ForbidSelfApproval: true, DailyOutflowCapMilligrams: 5000}},
+	}
+	for _, c := range cases {
+		got := stricterTeaTeamTransferPolicy(current, c.requested)
+		if !got.SameRules(c.want) {
+			t.Errorf("%s: stricter = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+	unlimited := TeaTeamTransferPolicy{LargeAmountApprovals: 1}
+	capped := TeaTeamTransferPolicy{LargeAmountThresholdMilligrams: 100, LargeAmountApprovals: 2, DailyOutflowCapMilligrams: 300}
+	if got := stricterTeaTeamTransferPolicy(unlimited, capped); !got.SameRules(capped) {
+		t.Errorf("由不限改为设限应立即生效: %+v", got)
+	}
+}
@@ -0,0 +1,617 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+团队定期星茶转账（tea.team_transfer_schedules / tea.team_transfer_schedule_runs）：
+1、团队成员设定一次性（指定时间）、每周或每月的团队转出，设定人即每笔转账的发起人；
+2、每周以首次执行时间起每7天执行；每月以首次执行日为准，当月没有该日（如31日）时在月末执行；
+3、后台任务 RunDueTeaTeamTransferSchedules 定期执行到期的设定：先把下次执行时间推进到当前时间之后并记一行执行记录（认领），
+   再按正常流程 CreateTeaTeamToUserTransferOut / CreateTeaTeamToTeamTransferOut 发起转账（锁定金额、待核心成员审批）；
+   停机期间错过的各期不补发，只执行一次；
+4、余额不足、账户冻结、设定人已离开团队等失败记入执行记录，并以纸条布告通知团队全体成员；
+5、设定人或团队核心成员可暂停、恢复、取消；一次性设定执行后、重复设定过了截止时间后即结束。
+*/
+
+// 定期转账频率
+const (
+	TeaTransferScheduleFrequency_Once    = "once"
+	TeaTransferScheduleFrequency_Weekly  = "weekly"
+	TeaTransferScheduleFrequency_Monthly = "monthly"
+)
+
+// 定期转账状态
+const (
+	TeaTransferScheduleStatus_Active    = "active"
+	TeaTransferScheduleStatus_Paused    = "paused"
+	TeaTransferScheduleStatus_Cancelled = "cancelled"
+	TeaTransferScheduleStatus_Completed = "completed"
+)
+
+// 定期转账执行记录状态
+const (
+	TeaTransferScheduleRunStatus_Running = "running"
+	TeaTransferScheduleRunStatus_Created = "created"
+	TeaTransferScheduleRunStatus_Failed  = "failed"
+)
+
+// 定期转账接收方类型
+const (
+	TeaTransferScheduleTarget_User = "u"
+	TeaTransferScheduleTarget_Team = "t"
+)
+
+var (
+	ErrTeaTransferScheduleNotFound = errors.New("定期转账不存在")
+	ErrTeaTransferScheduleFinished = errors.New("定期转账已取消或已结束")
+)
+
+// TeaTeamTransferSchedule 团队定期转账设定
+type TeaTeamTransferSchedule struct {
+	Id               int
+	Uuid             string
+	TeamId           int
+	CreatedByUserId  int
+	TargetType       string
+	TargetId         int
+	TargetName       string
+	AmountMilligrams int64
+	Notes            string
+	ExpireHours      int
+	Frequency        string
+	StartAt          time.Time
+	EndAt            *time.Time
+	NextRunAt        *time.Time
+	LastRunAt        *time.Time
+	RunCount         int
+	FailureCount     int
+	LastError        string
+	Status           string
+	CreatedAt        time.Time
+	UpdatedAt        *time.Time
+}
+
+// TeaTeamTransferScheduleRun 定期转账的一次执行
+type TeaTeamTransferScheduleRun struct {
+	Id             int
+	ScheduleId     int
+	ScheduledFor   time.Time
+	Status         string
+	TransferTable  string
+	TransferUuid   string
+	TransferStatus string // 生成转账的当前状态，未生成时为空
+	Error          string
+	CreatedAt      time.Time
+	FinishedAt     *time.Time
+}
+
+var teaTransferScheduleFrequencyText = map[string]string{
+	TeaTransferScheduleFrequency_Once:    "一次性",
+	TeaTransferScheduleFrequency_Weekly:  "每周",
+	TeaTransferScheduleFrequency_Monthly: "每月",
+}
+
+var teaTransferScheduleStatusText = map[string]string{
+	TeaTransferScheduleStatus_Active:    "生效中",
+	TeaTransferScheduleStatus_Paused:    "已暂停",
+	TeaTransferScheduleStatus_Cancelled: "已取消",
+	TeaTransferScheduleStatus_Completed: "已结束",
+}
+
+var teaTransferScheduleRunStatusText = map[string]string{
+	TeaTransferScheduleRunStatus_Running: "执行中",
+	TeaTransferScheduleRunStatus_Created: "已发起转账",
+	TeaTransferScheduleRunStatus_Failed:  "失败",
+}
+
+// FrequencyString 频率中文
+func (s *TeaTeamTransferSchedule) FrequencyString() string {
+	return teaTransferScheduleFrequencyText[s.Frequency]
+}
+
+// StatusString 状态中文
+func (s *TeaTeamTransferSchedule) StatusString() string {
+	return teaTransferScheduleStatusText[s.Status]
+}
+
+// TargetString 接收方描述
+func (s *TeaTeamTransferSchedule) TargetString() string {
+	if s.TargetType == TeaTransferScheduleTarget_Team {
+		return "团队 " + s.TargetName
+	}
+	return "茶友 " + s.TargetName
+}
+
+// Finished 已取消或已结束，不能再修改
+func (s *TeaTeamTransferSchedule) Finished() bool {
+	return s.Status == TeaTransferScheduleStatus_Cancelled || s.Status == TeaTransferScheduleStatus_Completed
+}
+
+// StatusString 执行状态中文
+func (r *TeaTeamTransferScheduleRun) StatusString() string {
+	return teaTransferScheduleRunStatusText[r.Status]
+}
+
+// Validate 检查设定取值（不含接收方是否存在）
+func (s *TeaTeamTransferSchedule) Validate() error {
+	if s.TeamId <= 0 || s.TeamId == TeamIdFreelancer {
+		return fmt.Errorf("自由人团队不支持星茶转账")
+	}
+	switch s.TargetType {
+	case TeaTransferScheduleTarget_User:
+	case TeaTransferScheduleTarget_Team:
+		if s.TargetId == TeamIdFreelancer {
+			return fmt.Errorf("不能向自由人团队转账")
+		}
+		if s.TargetId == s.TeamId {
+			return fmt.Errorf("不能向自己的团队转账")
+		}
+	default:
+		return fmt.Errorf("接收方类型无效")
+	}
+	if s.TargetId <= 0 {
+		return fmt.Errorf("接收方ID无效")
+	}
+	if s.AmountMilligrams <= 0 {
+		return fmt.Errorf("转账数额必须大于0")
+	}
+	if len([]rune(s.Notes)) > 255 {
+		return fmt.Errorf("备注不能超过255字")
+	}
+	if s.ExpireHours <= 0 || s.ExpireHours > 168 {
+		return fmt.Errorf("待接收时限须在1到168小时之间")
+	}
+	if _, ok := teaTransferScheduleFrequencyText[s.Frequency]; !ok {
+		return fmt.Errorf("执行频率无效")
+	}
+	if s.StartAt.IsZero() {
+		return fmt.Errorf("须指定首次执行时间")
+	}
+	if s.EndAt != nil && s.EndAt.Before(s.StartAt) {
+		return fmt.Errorf("截止时间不能早于首次执行时间")
+	}
+	return nil
+}
+
+// teaTransferScheduleOccurrence 第 n 次（从0起）应执行时间
+func teaTransferScheduleOccurrence(frequency string, start time.Time, n int) time.Time {
+	switch frequency {
+	case TeaTransferScheduleFrequency_Weekly:
+		return start.AddDate(0, 0, 7*n)
+	case TeaTransferScheduleFrequency_Monthly:
+		// 按月推进，当月没有首次执行日时取月末
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+	return start
+}
+
+// NextRunAfter 严格晚于 after 的下一次执行时间；一次性设定、超过截止时间时返回 false
+func (s *TeaTeamTransferSchedule) NextRunAfter(after time.Time) (time.Time, bool) {
+	if s.Frequency == TeaTransferScheduleFrequency_Once {
+		if s.StartAt.After(after) {
+			return s.StartAt, true
+		}
+		return time.Time{}, false
+	}
+	n := 0
+	if after.After(s.StartAt) {
+		// 先按最短周期估算跳过的期数，再逐期推进
+		n = int(after.Sub(s.StartAt).Hours()/24) / 31
+		if s.Frequency == TeaTransferScheduleFrequency_Weekly {
+			n = int(after.Sub(s.StartAt).Hours()/24) / 7
+		}
+		if n > 0 {
+			n--
+		}
+	}
+	next := teaTransferScheduleOccurrence(s.Frequency, s.StartAt, n)
+	for !next.After(after) {
+		n++
+		next = teaTransferScheduleOccurrence(s.Frequency, s.StartAt, n)
+	}
+	if s.EndAt != nil && next.After(*s.EndAt) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+const teaTransferScheduleColumns = `id, uuid, team_id, created_by_user_id, target_type, target_id, target_name, amount_milligrams, notes,
+	expire_hours, frequency, start_at, end_at, next_run_at, last_run_at, run_count, failure_count, last_error, status, created_at, updated_at`
+
+func scanTeaTransferSchedule(row interface{ Scan(...any) error }) (TeaTeamTransferSchedule, error) {
+	var s TeaTeamTransferSchedule
+	err := row.Scan(&s.Id, &s.Uuid, &s.TeamId, &s.CreatedByUserId, &s.TargetType, &s.TargetId, &s.TargetName, &s.AmountMilligrams, &s.Notes,
+		&s.ExpireHours, &s.Frequency, &s.StartAt, &s.EndAt, &s.NextRunAt, &s.LastRunAt, &s.RunCount, &s.FailureCount, &s.LastError, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+// CreateTeaTeamTransferSchedule 新建定期转账，调用前须确认设定人是团队成员、接收方存在
+func CreateTeaTeamTransferSchedule(ctx context.Context, s *TeaTeamTransferSchedule) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	next, ok := s.NextRunAfter(time.Now().Add(-time.Minute))
+	if !ok {
+		return fmt.Errorf("首次执行时间已过，且之后没有可执行的日期")
+	}
+	s.NextRunAt = &next
+	s.Status = TeaTransferScheduleStatus_Active
+	err := DB.QueryRowContext(ctx, `
+		INSERT INTO tea.team_transfer_schedules
+			(team_id, created_by_user_id, target_type, target_id, target_name, amount_milligrams, notes,
+			 expire_hours, frequency, start_at, end_at, next_run_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, uuid, created_at`,
+		s.TeamId, s.CreatedByUserId, s.TargetType, s.TargetId, s.TargetName, s.AmountMilligrams, s.Notes,
+		s.ExpireHours, s.Frequency, s.StartAt, s.EndAt, s.NextRunAt, s.Status).
+		Scan(&s.Id, &s.Uuid, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("创建定期转账失败: %v", err)
+	}
+	return nil
+}
+
+// GetTeaTeamTransferScheduleByUuid 按uuid读取定期转账
+func GetTeaTeamTransferScheduleByUuid(ctx context.Context, uuid string) (TeaTeamTransferSchedule, error) {
+	s, err := scanTeaTransferSchedule(DB.QueryRowContext(ctx,
+		`SELECT `+teaTransferScheduleColumns+` FROM tea.team_transfer_schedules WHERE uuid = $1`, uuid))
+	if err == sql.ErrNoRows {
+		return s, ErrTeaTransferScheduleNotFound
+	}
+	if err != nil {
+		return s, fmt.Errorf("读取定期转账失败: %v", err)
+	}
+	return s, nil
+}
+
+// TeaTeamTransferSchedulesByTeam 团队的定期转账，生效、暂停的在前
+func TeaTeamTransferSchedulesByTeam(ctx context.Context, teamId int) ([]TeaTeamTransferSchedule, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT `+teaTransferScheduleColumns+`
+		FROM tea.team_transfer_schedules
+		WHERE team_id = $1
+		ORDER BY CASE WHEN status IN ($2, $3) THEN 0 ELSE 1 END, id DESC`,
+		teamId, TeaTransferScheduleStatus_Active, TeaTransferScheduleStatus_Paused)
+	if err != nil {
+		return nil, fmt.Errorf("查询定期转账失败: %v", err)
+	}
+	defer rows.Close()
+	var schedules []TeaTeamTransferSchedule
+	for rows.Next() {
+		s, err := scanTeaTransferSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取定期转账失败: %v", err)
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// TeaTeamTransferScheduleRuns 定期转账的执行记录（新的在前），附生成转账的当前状态
+func TeaTeamTransferScheduleRuns(ctx context.Context, scheduleId int) ([]TeaTeamTransferScheduleRun, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT r.id, r.schedule_id, r.scheduled_for, r.status, r.transfer_table, r.transfer_uuid,
+			COALESCE(tu.status, tt.status, ''), r.error, r.created_at, r.finished_at
+		FROM tea.team_transfer_schedule_runs r
+		LEFT JOIN tea.team_to_user_transfer_out tu ON r.transfer_table = $2 AND tu.uuid = r.transfer_uuid
+		LEFT JOIN tea.team_to_team_transfer_out tt ON r.transfer_table = $3 AND tt.uuid = r.transfer_uuid
+		WHERE r.schedule_id = $1
+		ORDER BY r.scheduled_for DESC, r.id DESC`,
+		scheduleId, TeaTeamTransferTable_ToUser, TeaTeamTransferTable_ToTeam)
+	if err != nil {
+		return nil, fmt.Errorf("查询定期转账执行记录失败: %v", err)
+	}
+	defer rows.Close()
+	var runs []TeaTeamTransferScheduleRun
+	for rows.Next() {
+		var r TeaTeamTransferScheduleRun
+		if err = rows.Scan(&r.Id, &r.ScheduleId, &r.ScheduledFor, &r.Status, &r.TransferTable, &r.TransferUuid,
+			&r.TransferStatus, &r.Error, &r.CreatedAt, &r.FinishedAt); err != nil {
+			return nil, fmt.Errorf("读取定期转账执行记录失败: %v", err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// PauseTeaTeamTransferSchedule 暂停生效中的定期转账
+func PauseTeaTeamTransferSchedule(ctx context.Context, s *TeaTeamTransferSchedule) error {
+	if s.Status != TeaTransferScheduleStatus_Active {
+		return fmt.Errorf("只有生效中的定期转账可以暂停")
+	}
+	return updateTeaTransferScheduleStatus(ctx, s, TeaTransferScheduleStatus_Paused, s.NextRunAt, s.Status)
+}
+
+// ResumeTeaTeamTransferSchedule 恢复已暂停的定期转账：一次性设定未执行的照常执行（已过时间则尽快执行），
+// 重复设定从当前时间之后的下一期开始，暂停期间的各期不补发
+func ResumeTeaTeamTransferSchedule(ctx context.Context, s *TeaTeamTransferSchedule) error {
+	if s.Status != TeaTransferScheduleStatus_Paused {
+		return fmt.Errorf("只有已暂停的定期转账可以恢复")
+	}
+	next := s.NextRunAt
+	if s.Frequency != TeaTransferScheduleFrequency_Once && (next == nil || !next.After(time.Now())) {
+		t, ok := s.NextRunAfter(time.Now())
+		if !ok {
+			return updateTeaTransferScheduleStatus(ctx, s, TeaTransferScheduleStatus_Completed, nil, s.Status)
+		}
+		next = &t
+	}
+	return updateTeaTransferScheduleStatus(ctx, s, TeaTransferScheduleStatus_Active, next, s.Status)
+}
+
+// CancelTeaTeamTransferSchedule 取消定期转账，已发起的转账不受影响
+func CancelTeaTeamTransferSchedule(ctx context.Context, s *TeaTeamTransferSchedule) error {
+	if s.Finished() {
+		return ErrTeaTransferScheduleFinished
+	}
+	return updateTeaTransferScheduleStatus(ctx, s, TeaTransferScheduleStatus_Cancelled, nil, s.Status)
+}
+
+func updateTeaTransferScheduleStatus(ctx context.Context, s *TeaTeamTransferSchedule, status string, next *time.Time, fromStatus string) error {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE tea.team_transfer_schedules SET status = $2, next_run_at = $3
+		WHERE id = $1 AND status = $4`, s.Id, status, next, fromStatus)
+	if err != nil {
+		return fmt.Errorf("更新定期转账状态失败: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("定期转账状态已变化，请刷新后再试")
+	}
+	s.Status = status
+	s.NextRunAt = next
+	return nil
+}
+
+// TeaTransferScheduleRunSummary 一轮定期转账执行的统计
+type TeaTransferScheduleRunSummary struct {
+	Due     int // 到期的设定
+	Created int // 已发起转账
+	Failed  int // 失败
+}
+
+// RunDueTeaTeamTransferSchedules 执行到期的定期转账，供后台任务定期调用；多个实例同时执行时同一期只会认领一次
+func RunDueTeaTeamTransferSchedules(ctx context.Context, now time.Time) (TeaTransferScheduleRunSummary, error) {
+	var summary TeaTransferScheduleRunSummary
+	rows, err := DB.QueryContext(ctx, `
+		SELECT `+teaTransferScheduleColumns+`
+		FROM tea.team_transfer_schedules
+		WHERE status = $1 AND next_run_at <= $2
+		ORDER BY next_run_at
+		LIMIT 200`, TeaTransferScheduleStatus_Active, now)
+	if err != nil {
+		return summary, fmt.Errorf("查询到期定期转账失败: %v", err)
+	}
+	var due []TeaTeamTransferSchedule
+	for rows.Next() {
+		s, err := scanTeaTransferSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return summary, fmt.Errorf("读取定期转账失败: %v", err)
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return summary, err
+	}
+
+	summary.Due = len(due)
+	for i := range due {
+		s := &due[i]
+		runId, claimed, err := claimTeaTransferScheduleRun(ctx, s, now)
+		if err != nil {
+			return summary, err
+		}
+		if !claimed {
+			continue
+		}
+		table, transferUuid, execErr := executeTeaTransferSchedule(s)
+		if err = finishTeaTransferScheduleRun(ctx, s, runId, table, transferUuid, execErr); err != nil {
+			return summary, err
+		}
+		if execErr != nil {
+			summary.Failed++
+			notifyTeaTransferScheduleFailure(ctx, s, execErr)
+		} else {
+			summary.Created++
+		}
+	}
+	return summary, nil
+}
+
+// claimTeaTransferScheduleRun 认领本期：推进下次执行时间并记一行执行记录，同一事务内完成；
+// 认领后即使发起转账前进程中断，本期也不会重复发起（执行记录停留在 running）
+func claimTeaTransferScheduleRun(ctx context.Context, s *TeaTeamTransferSchedule, now time.Time) (int, bool, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	scheduledFor := *s.NextRunAt
+	status := TeaTransferScheduleStatus_Active
+	var next *time.Time
+	if t, ok := s.NextRunAfter(now); ok {
+		next = &t
+	} else {
+		status = TeaTransferScheduleStatus_Completed
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE tea.team_transfer_schedules
+		SET next_run_at = $3, status = $4, last_run_at = $5, run_count = run_count + 1
+		WHERE id = $1 AND status = $6 AND next_run_at = $2`,
+		s.Id, scheduledFor, next, status, now, TeaTransferScheduleStatus_Active)
+	if err != nil {
+		return 0, false, fmt.Errorf("认领定期转账失败: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		// 已被其他实例认领，或刚被暂停、取消
+		return 0, false, nil
+	}
+	var runId int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tea.team_transfer_schedule_runs (schedule_id, scheduled_for, status)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (schedule_id, scheduled_for) DO NOTHING
+		RETURNING id`, s.Id, scheduledFor, TeaTransferScheduleRunStatus_Running).Scan(&runId)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("记录定期转账执行失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("提交事务失败: %v", err)
+	}
+	s.NextRunAt = next
+	s.Status = status
+	s.RunCount++
+	return runId, true, nil
+}
+
+// executeTeaTransferSchedule 以设定人名义按正常流程发起转账，返回转账表名及uuid
+func executeTeaTransferSchedule(s *TeaTeamTransferSchedule) (string, string, error) {
+	isMember, err := IsTeamActiveMember(s.CreatedByUserId, s.TeamId)
+	if err != nil {
+		return "", "", fmt.Errorf("检查设定人团队成员身份失败: %v", err)
+	}
+	if !isMember {
+		return "", "", fmt.Errorf("设定人已不是团队成员，请由现任成员重新设定")
+	}
+	fromTeam, err := GetTeam(s.TeamId)
+	if err != nil {
+		return "", "", fmt.Errorf("读取转出团队失败: %v", err)
+	}
+	frozen, reason, err := CheckTeaTeamAccountFrozen(s.TeamId)
+	if err != nil {
+		return "", "", fmt.Errorf("检查团队账户状态失败: %v", err)
+	}
+	if frozen {
+		return "", "", fmt.Errorf("团队账户已被冻结：%s", reason)
+	}
+
+	notes := s.Notes
+	if notes == "" {
+		notes = "定期转账"
+	}
+	if s.TargetType == TeaTransferScheduleTarget_Team {
+		toTeam, err := GetTeam(s.TargetId)
+		if err != nil {
+			return "", "", fmt.Errorf("读取接收团队失败: %v", err)
+		}
+		if frozen, reason, err = CheckTeaTeamAccountFrozen(s.TargetId); err != nil {
+			return "", "", fmt.Errorf("检查接收团队账户状态失败: %v", err)
+		} else if frozen {
+			return "", "", fmt.Errorf("接收方账户已被冻结：%s", reason)
+		}
+		transfer, err := CreateTeaTeamToTeamTransferOut(s.TeamId, s.CreatedByUserId, s.TargetId, s.AmountMilligrams, notes, fromTeam.Name, toTeam.Name, s.ExpireHours)
+		if err != nil {
+			return "", "", err
+		}
+		return TeaTeamTransferTable_ToTeam, transfer.Uuid, nil
+	}
+
+	toUser, err := GetUser(s.TargetId)
+	if err != nil {
+		return "", "", fmt.Errorf("读取接收茶友失败: %v", err)
+	}
+	if frozen, reason, err = CheckTeaUserAccountFrozen(s.TargetId); err != nil {
+		return "", "", fmt.Errorf("检查接收方账户状态失败: %v", err)
+	} else if frozen {
+		return "", "", fmt.Errorf("接收方账户已被冻结：%s", reason)
+	}
+	transfer, err := CreateTeaTeamToUserTransferOut(s.TeamId, s.CreatedByUserId, s.TargetId, s.AmountMilligrams, notes, fromTeam.Name, toUser.Name, s.ExpireHours)
+	if err != nil {
+		return "", "", err
+	}
+	return TeaTeamTransferTable_ToUser, transfer.Uuid, nil
+}
+
+// finishTeaTransferScheduleRun 记录本期执行结果，成功时连续失败次数清零
+func finishTeaTransferScheduleRun(ctx context.Context, s *TeaTeamTransferSchedule, runId int, table, transferUuid string, execErr error) error {
+	status, errText := TeaTransferScheduleRunStatus_Created, ""
+	if execErr != nil {
+		status, errText = TeaTransferScheduleRunStatus_Failed, execErr.Error()
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE tea.team_transfer_schedule_runs
+		SET status = $2, transfer_table = $3, transfer_uuid = $4, error = $5, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, runId, status, table, transferUuid, errText); err != nil {
+		return fmt.Errorf("记录定期转账执行结果失败: %v", err)
+	}
+	if execErr != nil {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE tea.team_transfer_schedules SET failure_count = failure_count + 1, last_error = $2 WHERE id = $1`,
+			s.Id, errText)
+		s.FailureCount++
+		s.LastError = errText
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE tea.team_transfer_schedules SET failure_count = 0, last_error = '' WHERE id = $1`, s.Id)
+		s.FailureCount = 0
+		s.LastError = ""
+	}
+	if err != nil {
+		return fmt.Errorf("更新定期转账失败次数失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	return nil
+}
+
+// notifyTeaTransferScheduleFailure 在团队消息盒子发布布告，告知全体成员定期转账执行失败；通知失败不影响执行记录
+func notifyTeaTransferScheduleFailure(ctx context.Context, s *TeaTeamTransferSchedule, execErr error) {
+	var box MessageBox
+	if err := box.GetOrCreateMessageBoxWithContext(MessageBoxTypeTeam, s.TeamId, ctx); err != nil {
+		util.Warningf("定期转账 %s 失败通知未发送，无法获取团队 %d 消息盒子: %v", s.Uuid, s.TeamId, err)
+		return
+	}
+	if box.AllMessagesCount() >= box.MaxCount {
+		util.Warningf("定期转账 %s 失败通知未发送，团队 %d 消息盒子已满", s.Uuid, s.TeamId)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "【定期转账执行失败】%s向%s转出 %d 毫克星茶未能发起：%s。",
+		s.FrequencyString(), s.TargetString(), s.AmountMilligrams, execErr.Error())
+	if s.FailureCount > 1 {
+		fmt.Fprintf(&b, "已连续失败 %d 次。", s.FailureCount)
+	}
+	b.WriteString("请检查团队星茶账户余额及状态，或在团队星茶罐的定期转账页面暂停、取消该设定。")
+	message := Message{
+		Uuid:           Random_UUID(),
+		MessageBoxId:   box.Id,
+		SenderType:     MessageSenderTypeTeam,
+		SenderObjectId: s.CreatedByUserId,
+		ReceiverType:   MessageReceiverTypeAll,
+		ReceiverId:     UserId_None,
+		Content:        b.String(),
+	}
+	if err := message.CreateWithContext(ctx); err != nil {
+		util.Warningf("定期转账 %s 失败通知未发送: %v", s.Uuid, err)
+		return
+	}
+	box.Count++
+	if err := box.UpdateWithContext(ctx); err != nil {
+		util.Warningf("更新团队 %d 消息盒子计数失败: %v", s.TeamId, err)
+	}
+}
@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTeaTeamTransferScheduleNextRunAfter(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	at := func(y int, m time.Month, d, h int) time.Time { return time.Date(y, m, d, h, 0, 0, 0, loc) }
+
+	monthly := TeaTeamTransferSchedule{Frequency: TeaTransferScheduleFrequency_Monthly, StartAt: at(2026, 1, 31, 9)}
+	cases := []struct {
+		after time.Time
+		want  time.Time
+	}{
+		{at(2026, 1, 1, 0), at(2026, 1, 31, 9)},
+		{at(2026, 1, 31, 9), at(2026, 2, 28, 9)}, // 当月没有31日取月末
+		{at(2026, 3, 1, 0), at(2026, 3, 31, 9)},
+		{at(2026, 4, 15, 0), at(2026, 4, 30, 9)},
+		{at(2027, 2, 1, 0), at(2027, 2, 28, 9)},
+	}
+	for _, c := range cases {
+		got, ok := monthly.NextRunAfter(c.after)
+		if !ok || !got.Equal(c.want) {
+			t.Errorf("monthly NextRunAfter(%v) = %v, %t, want %v", c.after, got, ok, c.want)
+		}
+	}
+
+	weekly := TeaTeamTransferSchedule{Frequency: TeaTransferScheduleFrequency_Weekly, StartAt: at(2026, 3, 2, 10)}
+	if got, _ := weekly.NextRunAfter(at(2026, 3, 2, 10)); !got.Equal(at(2026, 3, 9, 10)) {
+		t.Errorf("weekly 下一期 = %v", got)
+	}
+	if got, _ := weekly.NextRunAfter(at(2026, 5, 20, 0)); !got.Equal(at(2026, 5, 25, 10)) {
+		t.Errorf("weekly 跳过错过的各期 = %v", got)
+	}
+	end := at(2026, 3, 10, 0)
+	weekly.EndAt = &end
+	if _, ok := weekly.NextRunAfter(at(2026, 3, 9, 10)); ok {
+		t.Error("超过截止时间不应再有下一期")
+	}
+
+	once := TeaTeamTransferSchedule{Frequency: TeaTransferScheduleFrequency_Once, StartAt: at(2026, 6, 1, 8)}
+	if got, ok := once.NextRunAfter(at(2026, 5, 1, 0)); !ok || !got.Equal(once.StartAt) {
+		t.Errorf("once 未到期 = %v, %t", got, ok)
+	}
+	if _, ok := once.NextRunAfter(once.StartAt); ok {
+		t.Error("once 执行后不应再有下一期")
+	}
+}
+
+func TestTeaTeamTransferScheduleValidate(t *testing.T) {
+	valid := TeaTeamTransferSchedule{
+		TeamId: 10, TargetType: TeaTransferScheduleTarget_User, TargetId: 5, AmountMilligrams: 100,
+		ExpireHours: 24, Frequency: TeaTransferScheduleFrequency_Monthly, StartAt: time.Now(),
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("合法设定报错: %v", err)
+	}
+	bad := []func(s *TeaTeamTransferSchedule){
+		func(s *TeaTeamTransferSchedule) { s.TeamId = TeamIdFreelancer },
+		func(s *TeaTeamTransferSchedule) { s.TargetType = TeaTransferScheduleTarget_Team; s.TargetId = s.TeamId },
+		func(s *TeaTeamTransferSchedule) { s.AmountMilligrams = 0 },
+		func(s *TeaTeamTransferSchedule) { s.ExpireHours = 200 },
+		func(s *TeaTeamTransferSchedule) { s.Frequency = "daily" },
+		func(s *TeaTeamTransferSchedule) { end := s.StartAt.Add(-time.Hour); s.EndAt = &end },
+	}
+	for i, mutate := range bad {
+		s := valid
+		mutate(&s)
+		if err := s.Validate(); err == nil {
+			t.Errorf("第%d个非法设定未报错", i)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+茶友对茶友星茶转账风控规则（tea.user_transfer_rule_decisions）：
+1、发起转账时，在锁定星茶之前按规则判定，规则取值见配置 TeaUserTransfer*，0表示不限：
+   单笔上限；每日累计上限（当天已发起且未被拒收、超时的转出加上本笔）；注册不满 N 天的新账户单笔上限；
+   每日向同一接收方发起的笔数；双方同在进行中（active/pause）的茶订单且按 ShouldAvoidConflict 须利益回避；
+2、未命中规则即放行，按原流程锁定星茶、创建转账；命中任一规则时不锁定星茶，记为待审核（hold），
+   由茶博士/船长审核：批准时再锁定星茶、创建转账（余额不足等仍会失败），驳回须说明理由；发起人可撤回待审核的转账；
+3、每次判定（放行或待审核）都记一行，命中的规则名及说明一并记录；
+4、同一茶友的发起依次判定（转出账户行已锁定），每日上限、笔数统计不会被并发绕过。
+*/
+
+// 判定结果
+const (
+	TeaUserTransferOutcome_Allow = "allow" // 放行
+	TeaUserTransferOutcome_Hold  = "hold"  // 待审核
+)
+
+// 待审核转账的审核状态，放行时为空
+const (
+	TeaUserTransferReview_Pending   = "pending"
+	TeaUserTransferReview_Approved  = "approved"
+	TeaUserTransferReview_Rejected  = "rejected"
+	TeaUserTransferReview_Cancelled = "cancelled"
+)
+
+// 风控规则名
+const (
+	TeaUserTransferRule_MaxAmount          = "max_amount"
+	TeaUserTransferRule_DailyCap           = "daily_cap"
+	TeaUserTransferRule_NewAccount         = "new_account"
+	TeaUserTransferRule_RecipientFrequency = "recipient_frequency"
+	TeaUserTransferRule_Conflict           = "conflict_of_interest"
+)
+
+var (
+	ErrTeaUserTransferHoldNotFound   = errors.New("待审核转账不存在")
+	ErrTeaUserTransferHoldDecided    = errors.New("该转账已审核或已撤回")
+	ErrTeaUserTransferHoldSelfReview = errors.New("不能审核自己发起的转账")
+)
+
+// TeaUserTransferLimits 风控规则取值，0表示不限
+type TeaUserTransferLimits struct {
+	MaxMilligrams           int64
+	DailyCapMilligrams      int64
+	NewAccountDays          int64
+	NewAccountMaxMilligrams int64
+	RecipientDailyMax       int64
+	ConflictCheck           bool
+}
+
+// TeaUserTransferLimitsFromConfig 按配置读取风控规则
+func TeaUserTransferLimitsFromConfig() TeaUserTransferLimits {
+	return TeaUserTransferLimits{
+		MaxMilligrams:           util.Config.TeaUserTransferMaxMilligrams,
+		DailyCapMilligrams:      util.Config.TeaUserTransferDailyCapMilligrams,
+		NewAccountDays:          util.Config.TeaUserTransferNewAccountDays,
+		NewAccountMaxMilligrams: util.Config.TeaUserTransferNewAccountMaxMilligrams,
+		RecipientDailyMax:       util.Config.TeaUserTransferRecipientDailyMax,
+		ConflictCheck:           util.Config.TeaUserTransferConflictCheck,
+	}
+}
+
+// TeaUserTransferFacts 判定一笔转账所需的情况
+type TeaUserTransferFacts struct {
+	AmountMilligrams    int64
+	SenderCreatedAt     time.Time // 发起人注册时间
+	Now                 time.Time
+	SentTodayMilligrams int64 // 当天已发起且未被拒收、超时的转出
+	ToRecipientToday    int64 // 当天已向同一接收方发起的笔数
+	ConflictTeaOrderId  int   // 双方同在其中且须利益回避的进行中茶订单，0表示没有
+}
+
+// TeaUserTransferRuleHit 命中的一条规则
+type TeaUserTransferRuleHit struct {
+	Rule   string
+	Reason string
+}
+
+// teaUserTransferRule 一条风控规则，check 返回命中说明，未命中为空
+type teaUserTransferRule struct {
+	name  string
+	check func(l *TeaUserTransferLimits, f *TeaUserTransferFacts) string
+}
+
+// teaUserTransferRules 按顺序判定的全部规则，新增规则在此登记
+var teaUserTransferRules = []teaUserTransferRule{
+	{TeaUserTransferRule_MaxAmount, func(l *TeaUserTransferLimits, f *TeaUserTransferFacts) string {
+		if l.MaxMilligrams > 0 && f.AmountMilligrams > l.MaxMilligrams {
+			return fmt.Sprintf("超过单笔上限 %d 毫克", l.MaxMilligrams)
+		}
+		return ""
+	}},
+	{TeaUserTransferRule_DailyCap, func(l *TeaUserTransferLimits, f *TeaUserTransferFacts) string {
+		if l.DailyCapMilligrams > 0 && f.SentTodayMilligrams+f.AmountMilligrams > l.DailyCapMilligrams {
+			return fmt.Sprintf("超过每日转出上限 %d 毫克，今日已转出 %d 毫克", l.DailyCapMilligrams, f.SentTodayMilligrams)
+		}
+		return ""
+	}},
+	{TeaUserTransferRule_NewAccount, func(l *TeaUserTransferLimits, f *TeaUserTransferFacts) string {
+		if l.NewAccountDays <= 0 || l.NewAccountMaxMilligrams <= 0 {
+			return ""
+		}
+		if f.Now.Sub(f.SenderCreatedAt) < time.Duration(l.NewAccountDays)*24*time.Hour && f.AmountMilligrams > l.NewAccountMaxMilligrams {
+			return fmt.Sprintf("注册不满 %d 天，单笔上限 %d 毫克", l.NewAccountDays, l.NewAccountMaxMilligrams)
+		}
+		return ""
+	}},
+	{TeaUserTransferRule_RecipientFrequency, func(l *TeaUserTransferLimits, f *TeaUserTransferFacts) string {
+		if l.RecipientDailyMax > 0 && f.ToRecipientToday+1 > l.RecipientDailyMax {
+			return fmt.Sprintf("今日已向该茶友发起 %d 笔，超过每日 %d 笔", f.ToRecipientToday, l.RecipientDailyMax)
+		}
+		return ""
+	}},
+	{TeaUserTransferRule_Conflict, func(l *TeaUserTransferLimits, f *TeaUserTransferFacts) string {
+		if l.ConflictCheck && f.ConflictTeaOrderId > 0 {
+			return fmt.Sprintf("双方同在进行中的茶订单 #%d 且须利益回避", f.ConflictTeaOrderId)
+		}
+		return ""
+	}},
+}
+
+// EvaluateTeaUserTransferRules 按规则判定一笔转账，返回命中的规则，没有命中即放行
+func EvaluateTeaUserTransferRules(l TeaUserTransferLimits, f TeaUserTransferFacts) []TeaUserTransferRuleHit {
+	var hits []TeaUserTransferRuleHit
+	for _, rule := range teaUserTransferRules {
+		if reason := rule.check(&l, &f); reason != "" {
+			hits = append(hits, TeaUserTransferRuleHit{Rule: rule.name, Reason: reason})
+		}
+	}
+	return hits
+}
+
+// TeaUserTransferDecision 一次风控判定记录，待审核时也是审核单
+type TeaUserTransferDecision struct {
+	Id               int
+	Uuid             string
+	FromUserId       int
+	FromUserName     string
+	ToUserId         int
+	ToUserName       string
+	AmountMilligrams int64
+	Notes            string
+	ExpireHours      int
+	Outcome          string
+	Rules            string // 命中的规则名，逗号分隔
+	Reasons          string // 命中规则的说明，每条一行
+	TransferId       int    // 放行或批准后创建的转账，0表示没有
+	TransferUuid     string
+	ReviewStatus     string
+	ReviewerUserId   int
+	ReviewerName     string
+	ReviewNote       string
+	ReviewedAt       *time.Time
+	CreatedAt        time.Time
+}
+
+// IsHeld 是否为待审核判定
+func (d *TeaUserTransferDecision) IsHeld() bool {
+	return d.Outcome == TeaUserTransferOutcome_Hold
+}
+
+// IsPendingReview 是否等待审核
+func (d *TeaUserTransferDecision) IsPendingReview() bool {
+	return d.ReviewStatus == TeaUserTransferReview_Pending
+}
+
+// Hits 命中的规则及说明
+func (d *TeaUserTransferDecision) Hits() []TeaUserTransferRuleHit {
+	if d.Rules == "" {
+		return nil
+	}
+	rules := strings.Split(d.Rules, ",")
+	reasons := strings.Split(d.Reasons, "\n")
+	hits := make([]TeaUserTransferRuleHit, len(rules))
+	for i, rule := range rules {
+		hits[i].Rule = rule
+		if i < len(reasons) {
+			hits[i].Reason = reasons[i]
+		}
+	}
+	return hits
+}
+
+// OutcomeString 判定结果中文
+func (d *TeaUserTransferDecision) OutcomeString() string {
+	if d.IsHeld() {
+		return "待审核"
+	}
+	return "放行"
+}
+
+// ReviewStatusString 审核状态中文
+func (d *TeaUserTransferDecision) ReviewStatusString() string {
+	switch d.ReviewStatus {
+	case TeaUserTransferReview_Pending:
+		return "待审核"
+	case TeaUserTransferReview_Approved:
+		return "已批准"
+	case TeaUserTransferReview_Rejected:
+		return "已驳回"
+	case TeaUserTransferReview_Cancelled:
+		return "已撤回"
+	}
+	return "-"
+}
+
+// SubmitTeaUserToUserTransfer 发起茶友对茶友转账：先按风控规则判定，放行时锁定星茶并创建转账，
+// 命中规则时只记待审核判定（transfer 为 nil），不锁定星茶
+func SubmitTeaUserToUserTransfer(ctx context.Context, from, to User, amountMilligrams int64, notes string, expireHours int) (decision TeaUserTransferDecision, transfer *TeaUserToUserTransferOut, err error) {
+	decision = TeaUserTransferDecision{FromUserId: from.Id, FromUserName: from.Name, ToUserId: to.Id, ToUserName: to.Name,
+		AmountMilligrams: amountMilligrams, Notes: notes, ExpireHours: expireHours}
+	if amountMilligrams <= 0 {
+		return decision, nil, fmt.Errorf("转账金额必须大于0")
+	}
+	limits := TeaUserTransferLimitsFromConfig()
+	facts := TeaUserTransferFacts{AmountMilligrams: amountMilligrams, SenderCreatedAt: from.CreatedAt, Now: time.Now()}
+	if limits.ConflictCheck {
+		if facts.ConflictTeaOrderId, err = teaUserTransferConflictOrder(ctx, from.Id, to.Id); err != nil {
+			return decision, nil, err
+		}
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return decision, nil, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	// 锁定转出账户行，同一茶友的发起依次判定
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM tea.user_accounts WHERE user_id = $1 FOR UPDATE`, from.Id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return decision, nil, fmt.Errorf("转出用户星茶账户不存在")
+	}
+	if err != nil {
+		return decision, nil, fmt.Errorf("查询转出用户账户失败: %v", err)
+	}
+	if status == TeaAccountStatus_Frozen {
+		return decision, nil, fmt.Errorf("用户账户已冻结")
+	}
+	if err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount_milligrams) FILTER (WHERE status NOT IN ($3, $4)), 0),
+		       COUNT(*) FILTER (WHERE to_user_id = $2)
+		FROM tea.user_to_user_transfer_out
+		WHERE from_user_id = $1 AND created_at >= date_trunc('day', CURRENT_TIMESTAMP)`,
+		from.Id, to.Id, TeaTransferStatusRejected, TeaTransferStatusExpired).
+		Scan(&facts.SentTodayMilligrams, &facts.ToRecipientToday); err != nil {
+		return decision, nil, fmt.Errorf("统计今日转出失败: %v", err)
+	}
+
+	if hits := EvaluateTeaUserTransferRules(limits, facts); len(hits) > 0 {
+		decision.Outcome, decision.ReviewStatus = TeaUserTransferOutcome_Hold, TeaUserTransferReview_Pending
+		rules := make([]string, len(hits))
+		reasons := make([]string, len(hits))
+		for i, h := range hits {
+			rules[i], reasons[i] = h.Rule, h.Reason
+		}
+		decision.Rules, decision.Reasons = strings.Join(rules, ","), strings.Join(reasons, "\n")
+	} else {
+		t, err := createTeaUserToUserTransferOutTx(tx, from.Id, from.Name, to.Id, to.Name, amountMilligrams, notes, expireHours)
+		if err != nil {
+			return decision, nil, err
+		}
+		transfer = &t
+		decision.Outcome, decision.TransferId, decision.TransferUuid = TeaUserTransferOutcome_Allow, t.Id, t.Uuid
+	}
+
+	if err = tx.QueryRowContext(ctx, `
+		INSERT INTO tea.user_transfer_rule_decisions
+			(from_user_id, from_user_name, to_user_id, to_user_name, amount_milligrams, notes, expire_hours,
+			 outcome, rules, reasons, transfer_id, review_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, 0), $12)
+		RETURNING id, uuid, created_at`,
+		decision.FromUserId, decision.FromUserName, decision.ToUserId, decision.ToUserName, decision.AmountMilligrams,
+		decision.Notes, decision.ExpireHours, decision.Outcome, decision.Rules, decision.Reasons, decision.TransferId,
+		decision.ReviewStatus).Scan(&decision.Id, &decision.Uuid, &decision.CreatedAt); err != nil {
+		return decision, nil, fmt.Errorf("记录转账风控判定失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return decision, nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+	if decision.IsHeld() {
+		util.Infof("茶友 %d 对茶友 %d 转账 %d 毫克命中风控规则 %s，待审核 %s",
+			from.Id, to.Id, amountMilligrams, decision.Rules, decision.Uuid)
+	}
+	return decision, transfer, nil
+}
+
+// teaUserTransferConflictOrder 双方同在其中（任一参与团队的正常成员或创建人）且须利益回避的进行中茶订单，没有时为0
+func teaUserTransferConflictOrder(ctx context.Context, userId1, userId2 int) (int, error) {
+	var orderId int
+	err := DB.QueryRowContext(ctx, `
+		WITH participants AS (
+			SELECT o.id AS order_id, tm.user_id
+			FROM tea_orders o
+			JOIN team_members tm ON tm.team_id IN (o.verify_team_id, o.payer_team_id, o.payee_team_id, o.care_team_id)
+			WHERE o.status IN ($3, $4) AND o.deleted_at IS NULL
+			  AND tm.status = $5 AND tm.user_id IN ($1, $2)
+			UNION
+			SELECT o.id, t.founder_id
+			FROM tea_orders o
+			JOIN teams t ON t.id IN (o.verify_team_id, o.payer_team_id, o.payee_team_id, o.care_team_id)
+			WHERE o.status IN ($3, $4) AND o.deleted_at IS NULL AND t.founder_id IN ($1, $2)
+		)
+		SELECT order_id FROM participants
+		GROUP BY order_id HAVING COUNT(DISTINCT user_id) = 2
+		ORDER BY order_id LIMIT 1`,
+		userId1, userId2, TeaOrderStatusActive, TeaOrderStatusPause, TeamMemberStatusActive).Scan(&orderId)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询进行中的茶订单失败: %v", err)
+	}
+	avoid, err := ShouldAvoidConflict(userId1, userId2, ctx)
+	if err != nil {
+		return 0, fmt.Errorf("检查利益回避失败: %v", err)
+	}
+	if !avoid {
+		return 0, nil
+	}
+	return orderId, nil
+}
+
+const teaUserTransferDecisionColumns = `
+	d.id, d.uuid, d.from_user_id, d.from_user_name, d.to_user_id, d.to_user_name, d.amount_milligrams, d.notes, d.expire_hours,
+	d.outcome, d.rules, d.reasons, COALESCE(d.transfer_id, 0), COALESCE(tr.uuid, ''),
+	d.review_status, COALESCE(d.reviewer_user_id, 0), COALESCE(ru.name, ''), d.review_note, d.reviewed_at, d.created_at
+	FROM tea.user_transfer_rule_decisions d
+	LEFT JOIN tea.user_to_user_transfer_out tr ON tr.id = d.transfer_id
+	LEFT JOIN users ru ON ru.id = d.reviewer_user_id`
+
+func scanTeaUserTransferDecision(row interface{ Scan(...any) error }, d *TeaUserTransferDecision) error {
+	return row.Scan(&d.Id, &d.Uuid, &d.FromUserId, &d.FromUserName, &d.ToUserId, &d.ToUserName, &d.AmountMilligrams, &d.Notes, &d.ExpireHours,
+		&d.Outcome, &d.Rules, &d.Reasons, &d.TransferId, &d.TransferUuid,
+		&d.ReviewStatus, &d.ReviewerUserId, &d.ReviewerName, &d.ReviewNote, &d.ReviewedAt, &d.CreatedAt)
+}
+
+func queryTeaUserTransferDecisions(ctx context.Context, where string, args ...any) ([]TeaUserTransferDecision, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+teaUserTransferDecisionColumns+` `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询转账风控判定失败: %v", err)
+	}
+	defer rows.Close()
+	var decisions []TeaUserTransferDecision
+	for rows.Next() {
+		var d TeaUserTransferDecision
+		if err = scanTeaUserTransferDecision(rows, &d); err != nil {
+			return nil, fmt.Errorf("扫描转账风控判定失败: %v", err)
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, rows.Err()
+}
+
+// PendingTeaUserTransferHolds 待审核的转账，先发起的在前
+func PendingTeaUserTransferHolds(ctx context.Context) ([]TeaUserTransferDecision, error) {
+	return queryTeaUserTransferDecisions(ctx, `WHERE d.review_status = $1 ORDER BY d.id`, TeaUserTransferReview_Pending)
+}
+
+// RecentTeaUserTransferDecisions 最近的判定记录（含放行），最新的在前
+func RecentTeaUserTransferDecisions(ctx context.Context, limit int) ([]TeaUserTransferDecision, error) {
+	return queryTeaUserTransferDecisions(ctx, `ORDER BY d.id DESC LIMIT $1`, limit)
+}
+
+// TeaUserTransferHoldsByUser 茶友发起的命中规则的转账，最新的在前
+func TeaUserTransferHoldsByUser(ctx context.Context, userId int, limit int) ([]TeaUserTransferDecision, error) {
+	return queryTeaUserTransferDecisions(ctx, `WHERE d.from_user_id = $1 AND d.outcome = $2 ORDER BY d.id DESC LIMIT $3`,
+		userId, TeaUserTransferOutcome_Hold, limit)
+}
+
+// DecideTeaUserTransferHold 审核待审核的转账：批准时锁定星茶并创建转账（有效期自批准起算），驳回须说明理由
+func DecideTeaUserTransferHold(ctx context.Context, uuid string, reviewerUserId int, approve bool, note string) (TeaUserTransferDecision, error) {
+	var d TeaUserTransferDecision
+	note = strings.TrimSpace(note)
+	if !approve && note == "" {
+		return d, fmt.Errorf("驳回转账须说明理由")
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return d, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	err = scanTeaUserTransferDecision(tx.QueryRowContext(ctx, `SELECT `+teaUserTransferDecisionColumns+`
+		WHERE d.uuid = $1 FOR UPDATE OF d`, uuid), &d)
+	if err == sql.ErrNoRows {
+		return d, ErrTeaUserTransferHoldNotFound
+	}
+	if err != nil {
+		return d, fmt.Errorf("查询待审核转账失败: %v", err)
+	}
+	if !d.IsPendingReview() {
+		return d, ErrTeaUserTransferHoldDecided
+	}
+	if d.FromUserId == reviewerUserId {
+		return d, ErrTeaUserTransferHoldSelfReview
+	}
+
+	d.ReviewStatus = TeaUserTransferReview_Rejected
+	if approve {
+		var toStatus string
+		err = tx.QueryRowContext(ctx, `SELECT status FROM tea.user_accounts WHERE user_id = $1`, d.ToUserId).Scan(&toStatus)
+		if err == sql.ErrNoRows {
+			return d, fmt.Errorf("接收方星茶账户不存在")
+		}
+		if err != nil {
+			return d, fmt.Errorf("查询接收方账户失败: %v", err)
+		}
+		if toStatus == TeaAccountStatus_Frozen {
+			return d, fmt.Errorf("接收方账户已冻结")
+		}
+		t, err := createTeaUserToUserTransferOutTx(tx, d.FromUserId, d.FromUserName, d.ToUserId, d.ToUserName, d.AmountMilligrams, d.Notes, d.ExpireHours)
+		if err != nil {
+			return d, err
+		}
+		d.ReviewStatus, d.TransferId, d.TransferUuid = TeaUserTransferReview_Approved, t.Id, t.Uuid
+	}
+	now := time.Now()
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE tea.user_transfer_rule_decisions
+		SET review_status = $2, reviewer_user_id = $3, review_note = $4, reviewed_at = $5, transfer_id = NULLIF($6, 0)
+		WHERE id = $1`, d.Id, d.ReviewStatus, reviewerUserId, note, now, d.TransferId); err != nil {
+		return d, fmt.Errorf("更新待审核转账失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return d, fmt.Errorf("提交事务失败: %v", err)
+	}
+	d.ReviewerUserId, d.ReviewNote, d.ReviewedAt = reviewerUserId, note, &now
+	return d, nil
+}
+
+// CancelTeaUserTransferHold 发起人撤回待审核的转账
+func CancelTeaUserTransferHold(ctx context.Context, uuid string, userId int) error {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE tea.user_transfer_rule_decisions SET review_status = $3, reviewed_at = $4
+		WHERE uuid = $1 AND from_user_id = $2 AND review_status = $5`,
+		uuid, userId, TeaUserTransferReview_Cancelled, time.Now(), TeaUserTransferReview_Pending)
+	if err != nil {
+		return fmt.Errorf("撤回待审核转账失败: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTeaUserTransferHoldDecided
+	}
+	return nil
+}
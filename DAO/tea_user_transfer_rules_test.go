@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateTeaUserTransferRules(t *testing.T) {
+	now := time.Date(2026, 5, 20, 10, 0, 0, 0, time.UTC)
+	limits := TeaUserTransferLimits{
+		MaxMilligrams:           10000,
+		DailyCapMilligrams:      20000,
+		NewAccountDays:          7,
+		NewAccountMaxMilligrams: 1000,
+		RecipientDailyMax:       3,
+		ConflictCheck:           true,
+	}
+	veteran := now.AddDate(-1, 0, 0)
+	cases := []struct {
+		name  string
+		f     TeaUserTransferFacts
+		rules []string
+	}{
+		{"放行", TeaUserTransferFacts{AmountMilligrams: 5000, SenderCreatedAt: veteran, Now: now}, nil},
+		{"单笔上限", TeaUserTransferFacts{AmountMilligrams: 10001, SenderCreatedAt: veteran, Now: now},
+			[]string{TeaUserTransferRule_MaxAmount}},
+		{"每日上限", TeaUserTransferFacts{AmountMilligrams: 5000, SentTodayMilligrams: 16000, SenderCreatedAt: veteran, Now: now},
+			[]string{TeaUserTransferRule_DailyCap}},
+		{"新账户", TeaUserTransferFacts{AmountMilligrams: 2000, SenderCreatedAt: now.Add(-48 * time.Hour), Now: now},
+			[]string{TeaUserTransferRule_NewAccount}},
+		{"新账户满期", TeaUserTransferFacts{AmountMilligrams: 2000, SenderCreatedAt: now.AddDate(0, 0, -7), Now: now}, nil},
+		{"同一接收方", TeaUserTransferFacts{AmountMilligrams: 100, ToRecipientToday: 3, SenderCreatedAt: veteran, Now: now},
+			[]string{TeaUserTransferRule_RecipientFrequency}},
+		{"利益回避且超额", TeaUserTransferFacts{AmountMilligrams: 12000, ConflictTeaOrderId: 8, SenderCreatedAt: veteran, Now: now},
+			[]string{TeaUserTransferRule_MaxAmount, TeaUserTransferRule_Conflict}},
+	}
+	for _, c := range cases {
+		hits := EvaluateTeaUserTransferRules(limits, c.f)
+		if len(hits) != len(c.rules) {
+			t.Errorf("%s: 命中 %+v, want %v", c.name, hits, c.rules)
+			continue
+		}
+		for i, h := range hits {
+			if h.Rule != c.rules[i] || h.Reason == "" {
+				t.Errorf("%s: 第%d条命中 %+v, want %s", c.name, i, h, c.rules[i])
+			}
+		}
+	}
+
+	// 0 表示不限
+	if hits := EvaluateTeaUserTransferRules(TeaUserTransferLimits{}, TeaUserTransferFacts{
+		AmountMilligrams: 1 << 40, SentTodayMilligrams: 1 << 40, ToRecipientToday: 99, ConflictTeaOrderId: 1, SenderCreatedAt: now, Now: now,
+	}); len(hits) != 0 {
+		t.Errorf("未设置规则时不应命中: %+v", hits)
+	}
+}
+
+func TestTeaUserTransferDecisionHits(t *testing.T) {
+	d := TeaUserTransferDecision{Outcome: TeaUserTransferOutcome_Hold, ReviewStatus: TeaUserTransferReview_Pending,
+		Rules: TeaUserTransferRule_MaxAmount + "," + TeaUserTransferRule_DailyCap, Reasons: "超过单笔上限\n超过每日上限"}
+	hits := d.Hits()
+	if len(hits) != 2 || hits[1].Rule != TeaUserTransferRule_DailyCap || hits[1].Reason != "超过每日上限" {
+		t.Errorf("Hits = %+v", hits)
+	}
+	if !d.IsPendingReview() || d.OutcomeString() != "待审核" || d.ReviewStatusString() != "待审核" {
+		t.Errorf("状态 = %s/%s", d.OutcomeString(), d.ReviewStatusString())
+	}
+	allow := TeaUserTransferDecision{Outcome: TeaUserTransferOutcome_Allow}
+	if allow.Hits() != nil || allow.IsHeld() || allow.ReviewStatusString() != "-" {
+		t.Errorf("放行判定 = %+v", allow)
+	}
+}
@@ -1165,6 +1165,10 @@ func (team *Team) IsCoreMember(user_id int) (bool, error) {
 		}
 		return false, err
 	}
+	// 被开除、暂停或已退出的成员不再行使核心成员职权
+	if team_member.Status != TeamMemberStatusActive {
+		return false, nil
+	}
 	// 检查是否为核心成员角色
 	return team_member.Role == RoleCEO || team_member.Role == RoleCTO || team_member.Role == RoleCMO || team_member.Role == RoleCFO, nil
 }
@@ -1579,6 +1583,23 @@ func IsVerifier(userId int) bool {
 	return count > 0
 }
 
+// GetVerifierTeamIdByUserId 获取用户所在的见证者集团活跃团队ID（取最早加入的一个），
+// 用于记录仲裁等见证行为的代表团队
+func GetVerifierTeamIdByUserId(userId int) (teamId int, err error) {
+	query := `SELECT tm.team_id FROM team_members tm
+		INNER JOIN group_members gm ON tm.team_id = gm.team_id
+		WHERE gm.group_id = $1
+			AND tm.user_id = $2
+			AND tm.status = $3
+			AND gm.status = $4
+			AND tm.deleted_at IS NULL
+			AND gm.deleted_at IS NULL
+		ORDER BY tm.created_at ASC LIMIT 1`
+	err = DB.QueryRow(query, GroupIdVerifier, userId,
+		TeamMemberStatusActive, GroupMemberStatusActive).Scan(&teamId)
+	return
+}
+
 // ConvertFamilyToFriendTeam 以家庭成员为基础，创建家庭的亲友团（团队），
 // 亲友可以加入该团队协助，但不强制要求必须加入，团队成员的核心职责是由CEO（家庭负责人）和CFO（配偶，如果存在且不是CEO本人）担任，其他家庭成员和亲友可以根据需要加入团队协助工作。
 // 一个家庭只创建一个亲友团，可服务多个茶围，无需为每个茶围重复创建。
@@ -0,0 +1,858 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	util "teachat/Util"
+)
+
+/*
+茶团成员开除、暂停：
+1、正常状态的核心成员提出开除或暂停某位成员（正常或暂停中），须说明理由；不能针对自己，同一成员同时只能有一份待确认的提议；
+2、对象不是CEO时由CEO确认或否决（CEO空缺时由创建人代行），CEO本人提出的即时确认；
+   对象是CEO本人时由其余正常状态的核心成员表决，提出人视为同意，同意超过半数即确认，反对多到同意不可能过半即否决；
+3、确认时在同一事务中：成员状态改为黑名单（开除）或暂停，该成员发起、尚待审批的团队转账（对茶友、对团队）一律拒绝并释放锁定额度，
+   其设定的生效中定期转账暂停，由现任成员决定恢复或取消；
+4、暂停到期由后台任务 team_member_suspension_expire 恢复（UnsuspendDueTeamMembers），CEO可提前恢复；
+   恢复时原核心角色已由他人担任的，改为品茶师；
+5、确认前提出人可撤回；确认后通过团队消息及小黑板通知该成员，成员查看记录后计为已读。
+开除、暂停记录与退出声明出现在同样的历史页面：离开成员、退出声明（管理）、本人的退出声明。
+*/
+
+// 开除、暂停类别
+const (
+	TeamMemberDismissalKind_Dismiss = "dismiss"
+	TeamMemberDismissalKind_Suspend = "suspend"
+)
+
+// 开除、暂停提议状态
+const (
+	TeamMemberDismissalStatus_Pending   = "pending"
+	TeamMemberDismissalStatus_Confirmed = "confirmed"
+	TeamMemberDismissalStatus_Rejected  = "rejected"
+	TeamMemberDismissalStatus_Withdrawn = "withdrawn"
+)
+
+const (
+	TeamMemberDismissalReasonMaxLen = 1000 // 理由最多字数
+	TeamMemberSuspendMaxDays        = 90   // 暂停最长天数
+)
+
+var (
+	ErrTeamMemberDismissalNotFound    = errors.New("开除或暂停记录不存在")
+	ErrTeamMemberDismissalReason      = errors.New("请填写理由，最多1000字")
+	ErrTeamMemberDismissalKind        = errors.New("请选择开除或暂停")
+	ErrTeamMemberDismissalDays        = errors.New("暂停天数须在1至90天之间")
+	ErrTeamMemberDismissalSelf        = errors.New("不能对自己提出开除或暂停")
+	ErrTeamMemberDismissalNotProposer = errors.New("只有茶团核心成员才能提出开除或暂停")
+	ErrTeamMemberDismissalTarget      = errors.New("该茶友不是茶团的在任成员")
+	ErrTeamMemberDismissalSuspended   = errors.New("该成员已在暂停中")
+	ErrTeamMemberDismissalPending     = errors.New("该成员已有待确认的开除或暂停提议")
+	ErrTeamMemberDismissalDecided     = errors.New("该提议已确认、否决或撤回")
+	ErrTeamMemberDismissalNotCEO      = errors.New("只有CEO才能确认或否决该提议")
+	ErrTeamMemberDismissalByVote      = errors.New("对CEO的提议须由核心成员表决")
+	ErrTeamMemberDismissalNotVoter    = errors.New("只有其余核心成员才能表决")
+	ErrTeamMemberDismissalVoted       = errors.New("你已经表决过了")
+	ErrTeamMemberDismissalNotOwner    = errors.New("只有提出人才能撤回")
+	ErrTeamMemberNotSuspended         = errors.New("该成员不在暂停中")
+)
+
+// TeamMemberDismissal 茶团成员开除、暂停记录
+type TeamMemberDismissal struct {
+	Id                int
+	Uuid              string
+	TeamId            int
+	TeamName          string
+	TeamUuid          string
+	MemberId          int // team_members.id
+	MemberUserId      int
+	MemberName        string
+	MemberRole        int // 时任角色
+	Kind              string
+	SuspendDays       int
+	Reason            string
+	ProposerUserId    int
+	ProposerName      string
+	ByVote            bool // 对象是CEO，由核心成员表决
+	Status            string
+	DeciderUserId     int // 确认或否决的CEO，表决时为0
+	DeciderName       string
+	DecisionNote      string
+	RejectedTransfers int // 确认时拒绝的待审批团队转账数
+	PausedSchedules   int // 确认时暂停的定期转账数
+	SuspendedUntil    *time.Time
+	LiftedAt          *time.Time
+	LiftedByUserId    int // 提前恢复的CEO，到期自动恢复时为0
+	MemberReadAt      *time.Time
+	CreatedAt         time.Time
+	DecidedAt         *time.Time
+}
+
+// TeamMemberDismissalVote 核心成员对CEO开除、暂停提议的表决
+type TeamMemberDismissalVote struct {
+	DismissalId int
+	VoterUserId int
+	VoterName   string
+	Agree       bool
+	CreatedAt   time.Time
+}
+
+// KindString 类别中文
+func (d *TeamMemberDismissal) KindString() string {
+	if d.Kind == TeamMemberDismissalKind_Suspend {
+		return "暂停"
+	}
+	return "开除"
+}
+
+// StatusString 状态中文
+func (d *TeamMemberDismissal) StatusString() string {
+	switch d.Status {
+	case TeamMemberDismissalStatus_Pending:
+		if d.ByVote {
+			return "表决中"
+		}
+		return "待CEO确认"
+	case TeamMemberDismissalStatus_Confirmed:
+		if d.Kind == TeamMemberDismissalKind_Suspend && d.LiftedAt != nil {
+			return "已恢复"
+		}
+		return "已确认"
+	case TeamMemberDismissalStatus_Rejected:
+		return "已否决"
+	case TeamMemberDismissalStatus_Withdrawn:
+		return "已撤回"
+	}
+	return "未知"
+}
+
+// RoleName 时任角色名称
+func (d *TeamMemberDismissal) RoleName() string {
+	return RoleNameMap[d.MemberRole]
+}
+
+// IsPending 是否待确认
+func (d *TeamMemberDismissal) IsPending() bool {
+	return d.Status == TeamMemberDismissalStatus_Pending
+}
+
+// IsSuspending 是否暂停中（已确认且尚未恢复的暂停）
+func (d *TeamMemberDismissal) IsSuspending() bool {
+	return d.Kind == TeamMemberDismissalKind_Suspend && d.Status == TeamMemberDismissalStatus_Confirmed && d.LiftedAt == nil
+}
+
+// teamMemberDismissalVoteOutcome 核心成员表决结果：eligible 为有表决权的人数，同意过半即确认，
+// 其余人全部同意也不能过半即否决，否则继续表决
+func teamMemberDismissalVoteOutcome(agree, oppose, eligible int) (decided, confirmed bool) {
+	if agree*2 > eligible {
+		return true, true
+	}
+	if (eligible-oppose)*2 <= eligible {
+		return true, false
+	}
+	return false, false
+}
+
+// validateTeamMemberDismissal 检查提议内容
+func validateTeamMemberDismissal(d *TeamMemberDismissal) error {
+	d.Reason = strings.TrimSpace(d.Reason)
+	if d.Reason == "" || utf8.RuneCountInString(d.Reason) > TeamMemberDismissalReasonMaxLen {
+		return ErrTeamMemberDismissalReason
+	}
+	switch d.Kind {
+	case TeamMemberDismissalKind_Dismiss:
+		d.SuspendDays = 0
+	case TeamMemberDismissalKind_Suspend:
+		if d.SuspendDays < 1 || d.SuspendDays > TeamMemberSuspendMaxDays {
+			return ErrTeamMemberDismissalDays
+		}
+	default:
+		return ErrTeamMemberDismissalKind
+	}
+	if d.ProposerUserId == d.MemberUserId {
+		return ErrTeamMemberDismissalSelf
+	}
+	return nil
+}
+
+// teamMemberRowTx 锁定茶友在茶团中最近一条成员记录
+func teamMemberRowTx(ctx context.Context, tx *sql.Tx, teamId, userId int) (TeamMember, error) {
+	var m TeamMember
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, uuid, team_id, user_id, role, created_at, status, updated_at FROM team_members
+		WHERE team_id = $1 AND user_id = $2
+		ORDER BY id DESC LIMIT 1 FOR UPDATE`, teamId, userId).
+		Scan(&m.Id, &m.Uuid, &m.TeamId, &m.UserId, &m.Role, &m.CreatedAt, &m.Status, &m.UpdatedAt)
+	return m, err
+}
+
+// teamDecisionMakerTx 茶团现任CEO的茶友id，CEO空缺时为创建人
+func teamDecisionMakerTx(ctx context.Context, tx *sql.Tx, teamId int) (int, error) {
+	var userId int
+	err := tx.QueryRowContext(ctx, `
+		SELECT user_id FROM team_members WHERE team_id = $1 AND role = $2 AND status = $3
+		ORDER BY created_at DESC LIMIT 1`, teamId, RoleCEO, TeamMemberStatusActive).Scan(&userId)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, `SELECT founder_id FROM teams WHERE id = $1`, teamId).Scan(&userId)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询茶团CEO失败: %v", err)
+	}
+	return userId, nil
+}
+
+// teamDismissalVotersTx 有表决权的核心成员：正常状态的核心成员，不含被提议的CEO
+func teamDismissalVotersTx(ctx context.Context, tx *sql.Tx, d *TeamMemberDismissal) (map[int]bool, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT user_id FROM team_members
+		WHERE team_id = $1 AND role IN ($2, $3, $4, $5) AND status = $6 AND user_id <> $7`,
+		d.TeamId, RoleCEO, RoleCTO, RoleCMO, RoleCFO, TeamMemberStatusActive, d.MemberUserId)
+	if err != nil {
+		return nil, fmt.Errorf("查询核心成员失败: %v", err)
+	}
+	defer rows.Close()
+	voters := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("扫描核心成员失败: %v", err)
+		}
+		voters[id] = true
+	}
+	return voters, rows.Err()
+}
+
+// ProposeTeamMemberDismissal 核心成员提出开除或暂停，需填 TeamId、MemberUserId、Kind、SuspendDays、Reason、ProposerUserId；
+// CEO提出的即时确认，对CEO的提议在表决即已过半（例如只有一位其余核心成员）时也即时确认
+func ProposeTeamMemberDismissal(ctx context.Context, d *TeamMemberDismissal) error {
+	if err := validateTeamMemberDismissal(d); err != nil {
+		return err
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	proposer, err := teamMemberRowTx(ctx, tx, d.TeamId, d.ProposerUserId)
+	if err == sql.ErrNoRows || (err == nil && (proposer.Status != TeamMemberStatusActive || !proposer.IsCoreMember())) {
+		return ErrTeamMemberDismissalNotProposer
+	}
+	if err != nil {
+		return fmt.Errorf("查询提出人成员资料失败: %v", err)
+	}
+	member, err := teamMemberRowTx(ctx, tx, d.TeamId, d.MemberUserId)
+	if err == sql.ErrNoRows || (err == nil && member.Status != TeamMemberStatusActive && member.Status != TeamMemberStatusSuspended) {
+		return ErrTeamMemberDismissalTarget
+	}
+	if err != nil {
+		return fmt.Errorf("查询成员资料失败: %v", err)
+	}
+	if d.Kind == TeamMemberDismissalKind_Suspend && member.Status == TeamMemberStatusSuspended {
+		return ErrTeamMemberDismissalSuspended
+	}
+	var pending int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM team_member_dismissals WHERE member_id = $1 AND status = $2`,
+		member.Id, TeamMemberDismissalStatus_Pending).Scan(&pending); err != nil {
+		return fmt.Errorf("查询待确认提议失败: %v", err)
+	}
+	if pending > 0 {
+		return ErrTeamMemberDismissalPending
+	}
+
+	d.MemberId, d.MemberRole = member.Id, member.Role
+	d.ByVote = member.Role == RoleCEO
+	d.Status = TeamMemberDismissalStatus_Pending
+	if err = tx.QueryRowContext(ctx, `
+		INSERT INTO team_member_dismissals
+			(team_id, member_id, member_user_id, member_role, kind, suspend_days, reason, proposer_user_id, by_vote, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, uuid, created_at`,
+		d.TeamId, d.MemberId, d.MemberUserId, d.MemberRole, d.Kind, d.SuspendDays, d.Reason, d.ProposerUserId, d.ByVote, d.Status).
+		Scan(&d.Id, &d.Uuid, &d.CreatedAt); err != nil {
+		return fmt.Errorf("记录开除或暂停提议失败: %v", err)
+	}
+
+	if d.ByVote {
+		if err = recordTeamDismissalVoteTx(ctx, tx, d, d.ProposerUserId, true); err != nil {
+			return err
+		}
+	} else if proposer.Role == RoleCEO {
+		if err = confirmTeamMemberDismissalTx(ctx, tx, d, d.ProposerUserId, d.ProposerUserId, "CEO提出即确认"); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	d.afterChange(ctx)
+	return nil
+}
+
+// DecideTeamMemberDismissal CEO确认或否决提议
+func DecideTeamMemberDismissal(ctx context.Context, uuid string, ceoUserId int, confirm bool, note string) (TeamMemberDismissal, error) {
+	note = strings.TrimSpace(note)
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TeamMemberDismissal{}, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	d, err := lockPendingTeamMemberDismissal(ctx, tx, uuid)
+	if err != nil {
+		return d, err
+	}
+	if d.ByVote {
+		return d, ErrTeamMemberDismissalByVote
+	}
+	ceo, err := teamDecisionMakerTx(ctx, tx, d.TeamId)
+	if err != nil {
+		return d, err
+	}
+	if ceo != ceoUserId {
+		return d, ErrTeamMemberDismissalNotCEO
+	}
+	if confirm {
+		err = confirmTeamMemberDismissalTx(ctx, tx, &d, ceoUserId, ceoUserId, note)
+	} else {
+		err = closeTeamMemberDismissalTx(ctx, tx, &d, TeamMemberDismissalStatus_Rejected, ceoUserId, note)
+	}
+	if err != nil {
+		return d, err
+	}
+	if err = tx.Commit(); err != nil {
+		return d, fmt.Errorf("提交事务失败: %v", err)
+	}
+	d.afterChange(ctx)
+	return d, nil
+}
+
+// VoteTeamMemberDismissal 其余核心成员对CEO的提议表决
+func VoteTeamMemberDismissal(ctx context.Context, uuid string, voterUserId int, agree bool) (TeamMemberDismissal, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TeamMemberDismissal{}, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	d, err := lockPendingTeamMemberDismissal(ctx, tx, uuid)
+	if err != nil {
+		return d, err
+	}
+	if !d.ByVote {
+		return d, ErrTeamMemberDismissalNotVoter
+	}
+	if err = recordTeamDismissalVoteTx(ctx, tx, &d, voterUserId, agree); err != nil {
+		return d, err
+	}
+	if err = tx.Commit(); err != nil {
+		return d, fmt.Errorf("提交事务失败: %v", err)
+	}
+	d.afterChange(ctx)
+	return d, nil
+}
+
+// WithdrawTeamMemberDismissal 提出人撤回待确认的提议
+func WithdrawTeamMemberDismissal(ctx context.Context, uuid string, userId int) (TeamMemberDismissal, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TeamMemberDismissal{}, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	d, err := lockPendingTeamMemberDismissal(ctx, tx, uuid)
+	if err != nil {
+		return d, err
+	}
+	if d.ProposerUserId != userId {
+		return d, ErrTeamMemberDismissalNotOwner
+	}
+	if err = closeTeamMemberDismissalTx(ctx, tx, &d, TeamMemberDismissalStatus_Withdrawn, 0, ""); err != nil {
+		return d, err
+	}
+	if err = tx.Commit(); err != nil {
+		return d, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return d, nil
+}
+
+// lockPendingTeamMemberDismissal 锁定待确认的提议
+func lockPendingTeamMemberDismissal(ctx context.Context, tx *sql.Tx, uuid string) (TeamMemberDismissal, error) {
+	var d TeamMemberDismissal
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, uuid, team_id, member_id, member_user_id, member_role, kind, suspend_days, proposer_user_id, by_vote, status
+		FROM team_member_dismissals WHERE uuid = $1 FOR UPDATE`, uuid).
+		Scan(&d.Id, &d.Uuid, &d.TeamId, &d.MemberId, &d.MemberUserId, &d.MemberRole, &d.Kind, &d.SuspendDays,
+			&d.ProposerUserId, &d.ByVote, &d.Status)
+	if err == sql.ErrNoRows {
+		return d, ErrTeamMemberDismissalNotFound
+	}
+	if err != nil {
+		return d, fmt.Errorf("查询开除或暂停提议失败: %v", err)
+	}
+	if d.Status != TeamMemberDismissalStatus_Pending {
+		return d, ErrTeamMemberDismissalDecided
+	}
+	return d, nil
+}
+
+// recordTeamDismissalVoteTx 记下一票并按表决结果确认或否决
+func recordTeamDismissalVoteTx(ctx context.Context, tx *sql.Tx, d *TeamMemberDismissal, voterUserId int, agree bool) error {
+	voters, err := teamDismissalVotersTx(ctx, tx, d)
+	if err != nil {
+		return err
+	}
+	if !voters[voterUserId] {
+		return ErrTeamMemberDismissalNotVoter
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO team_member_dismissal_votes (dismissal_id, voter_user_id, agree) VALUES ($1, $2, $3)
+		ON CONFLICT (dismissal_id, voter_user_id) DO NOTHING`, d.Id, voterUserId, agree)
+	if err != nil {
+		return fmt.Errorf("记录表决失败: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTeamMemberDismissalVoted
+	}
+
+	// 只计仍有表决权的核心成员的票
+	rows, err := tx.QueryContext(ctx, `SELECT voter_user_id, agree FROM team_member_dismissal_votes WHERE dismissal_id = $1`, d.Id)
+	if err != nil {
+		return fmt.Errorf("查询表决失败: %v", err)
+	}
+	agreeCount, opposeCount := 0, 0
+	for rows.Next() {
+		var id int
+		var a bool
+		if err = rows.Scan(&id, &a); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描表决失败: %v", err)
+		}
+		if !voters[id] {
+			continue
+		}
+		if a {
+			agreeCount++
+		} else {
+			opposeCount++
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	decided, confirmed := teamMemberDismissalVoteOutcome(agreeCount, opposeCount, len(voters))
+	if !decided {
+		return nil
+	}
+	if confirmed {
+		return confirmTeamMemberDismissalTx(ctx, tx, d, 0, voterUserId,
+			fmt.Sprintf("核心成员表决通过：%d 人中 %d 人同意", len(voters), agreeCount))
+	}
+	return closeTeamMemberDismissalTx(ctx, tx, d, TeamMemberDismissalStatus_Rejected, 0,
+		fmt.Sprintf("核心成员表决未过半数：%d 人中 %d 人反对", len(voters), opposeCount))
+}
+
+// closeTeamMemberDismissalTx 否决或撤回
+func closeTeamMemberDismissalTx(ctx context.Context, tx *sql.Tx, d *TeamMemberDismissal, status string, deciderUserId int, note string) error {
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE team_member_dismissals SET status = $2, decider_user_id = NULLIF($3, 0), decision_note = $4, decided_at = $5
+		WHERE id = $1`, d.Id, status, deciderUserId, note, now); err != nil {
+		return fmt.Errorf("更新开除或暂停提议失败: %v", err)
+	}
+	d.Status, d.DeciderUserId, d.DecisionNote, d.DecidedAt = status, deciderUserId, note, &now
+	return nil
+}
+
+// confirmTeamMemberDismissalTx 确认提议：更新成员状态，拒绝其发起的待审批团队转账，暂停其设定的定期转账；
+// deciderUserId 为确认的CEO（表决时为0），operatorUserId 记为转账的审批人
+func confirmTeamMemberDismissalTx(ctx context.Context, tx *sql.Tx, d *TeamMemberDismissal, deciderUserId, operatorUserId int, note string) error {
+	var status TeamMemberStatus
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM team_members WHERE id = $1 FOR UPDATE`, d.MemberId).Scan(&status); err != nil {
+		return fmt.Errorf("查询成员资料失败: %v", err)
+	}
+	if status != TeamMemberStatusActive && status != TeamMemberStatusSuspended {
+		return ErrTeamMemberDismissalTarget
+	}
+
+	now := time.Now()
+	newStatus := TeamMemberStatusBlacklist
+	var until *time.Time
+	if d.Kind == TeamMemberDismissalKind_Suspend {
+		newStatus = TeamMemberStatusSuspended
+		t := now.AddDate(0, 0, d.SuspendDays)
+		until = &t
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE team_members SET status = $2, updated_at = $3 WHERE id = $1`,
+		d.MemberId, newStatus, now); err != nil {
+		return fmt.Errorf("更新成员状态失败: %v", err)
+	}
+	// 开除暂停中的成员时，此前的暂停不再到期恢复
+	if d.Kind == TeamMemberDismissalKind_Dismiss {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE team_member_dismissals SET lifted_at = $2
+			WHERE member_id = $1 AND kind = 'suspend' AND status = 'confirmed' AND lifted_at IS NULL`, d.MemberId, now); err != nil {
+			return fmt.Errorf("结束此前的暂停失败: %v", err)
+		}
+	}
+
+	reason := "发起人已被" + d.KindString() + "出茶团"
+	if d.Kind == TeamMemberDismissalKind_Suspend {
+		reason = "发起人已被暂停品茶"
+	}
+	var rejected int
+	var released int64
+	for _, table := range []string{"tea.team_to_user_transfer_out", "tea.team_to_team_transfer_out"} {
+		var n int
+		var amount int64
+		if err := tx.QueryRowContext(ctx, `
+			WITH r AS (
+				UPDATE `+table+`
+				SET status = $3, approver_user_id = $4, approval_rejection_reason = $5, approved_at = $6, updated_at = $6
+				WHERE from_team_id = $1 AND initiator_user_id = $2 AND status = $7
+				RETURNING amount_milligrams)
+			SELECT COUNT(*), COALESCE(SUM(amount_milligrams), 0) FROM r`,
+			d.TeamId, d.MemberUserId, TeaTransferStatusApprovalRejected, operatorUserId, reason, now, TeaTransferStatusPendingApproval).
+			Scan(&n, &amount); err != nil {
+			return fmt.Errorf("拒绝待审批转账失败: %v", err)
+		}
+		rejected += n
+		released += amount
+	}
+	if released > 0 {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE tea.team_accounts SET locked_balance_milligrams = locked_balance_milligrams - $1, updated_at = $2
+			WHERE team_id = $3 AND locked_balance_milligrams >= $1`, released, now, d.TeamId); err != nil {
+			return fmt.Errorf("释放团队账户锁定金额失败: %v", err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE tea.team_transfer_schedules SET status = $3
+		WHERE team_id = $1 AND created_by_user_id = $2 AND status = $4`,
+		d.TeamId, d.MemberUserId, TeaTransferScheduleStatus_Paused, TeaTransferScheduleStatus_Active)
+	if err != nil {
+		return fmt.Errorf("暂停定期转账失败: %v", err)
+	}
+	paused, _ := result.RowsAffected()
+
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE team_member_dismissals
+		SET status = $2, decider_user_id = NULLIF($3, 0), decision_note = $4, decided_at = $5,
+			rejected_transfers = $6, paused_schedules = $7, suspended_until = $8
+		WHERE id = $1`, d.Id, TeamMemberDismissalStatus_Confirmed, deciderUserId, note, now, rejected, paused, until); err != nil {
+		return fmt.Errorf("确认开除或暂停提议失败: %v", err)
+	}
+	d.Status, d.DeciderUserId, d.DecisionNote, d.DecidedAt = TeamMemberDismissalStatus_Confirmed, deciderUserId, note, &now
+	d.RejectedTransfers, d.PausedSchedules, d.SuspendedUntil = rejected, int(paused), until
+	return nil
+}
+
+// LiftTeamMemberSuspension CEO提前恢复暂停中的成员
+func LiftTeamMemberSuspension(ctx context.Context, uuid string, ceoUserId int) (TeamMemberDismissal, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TeamMemberDismissal{}, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	d, err := liftTeamMemberSuspensionTx(ctx, tx, uuid, ceoUserId)
+	if err != nil {
+		return d, err
+	}
+	if err = tx.Commit(); err != nil {
+		return d, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return d, nil
+}
+
+// liftTeamMemberSuspensionTx 恢复暂停中的成员，operatorUserId 为0表示到期自动恢复；
+// 原核心角色已由他人担任的改为品茶师
+func liftTeamMemberSuspensionTx(ctx context.Context, tx *sql.Tx, uuid string, operatorUserId int) (TeamMemberDismissal, error) {
+	var d TeamMemberDismissal
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, uuid, team_id, member_id, member_user_id, kind, status, lifted_at
+		FROM team_member_dismissals WHERE uuid = $1 FOR UPDATE`, uuid).
+		Scan(&d.Id, &d.Uuid, &d.TeamId, &d.MemberId, &d.MemberUserId, &d.Kind, &d.Status, &d.LiftedAt)
+	if err == sql.ErrNoRows {
+		return d, ErrTeamMemberDismissalNotFound
+	}
+	if err != nil {
+		return d, fmt.Errorf("查询暂停记录失败: %v", err)
+	}
+	if !d.IsSuspending() {
+		return d, ErrTeamMemberNotSuspended
+	}
+	if operatorUserId != 0 {
+		ceo, err := teamDecisionMakerTx(ctx, tx, d.TeamId)
+		if err != nil {
+			return d, err
+		}
+		if ceo != operatorUserId {
+			return d, ErrTeamMemberDismissalNotCEO
+		}
+	}
+
+	var member TeamMember
+	if err = tx.QueryRowContext(ctx, `SELECT role, status FROM team_members WHERE id = $1 FOR UPDATE`, d.MemberId).
+		Scan(&member.Role, &member.Status); err != nil {
+		return d, fmt.Errorf("查询成员资料失败: %v", err)
+	}
+	now := time.Now()
+	if member.Status == TeamMemberStatusSuspended {
+		if member.IsCoreMember() {
+			var taken int
+			if err = tx.QueryRowContext(ctx, `
+				SELECT COUNT(*) FROM team_members WHERE team_id = $1 AND role = $2 AND status = $3 AND id <> $4`,
+				d.TeamId, member.Role, TeamMemberStatusActive, d.MemberId).Scan(&taken); err != nil {
+				return d, fmt.Errorf("查询角色担任情况失败: %v", err)
+			}
+			if taken > 0 {
+				member.Role = RoleTaster
+			}
+		}
+		if _, err = tx.ExecContext(ctx, `UPDATE team_members SET role = $2, status = $3, updated_at = $4 WHERE id = $1`,
+			d.MemberId, member.Role, TeamMemberStatusActive, now); err != nil {
+			return d, fmt.Errorf("恢复成员状态失败: %v", err)
+		}
+	}
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE team_member_dismissals SET lifted_at = $2, lifted_by_user_id = NULLIF($3, 0) WHERE id = $1`,
+		d.Id, now, operatorUserId); err != nil {
+		return d, fmt.Errorf("记录暂停恢复失败: %v", err)
+	}
+	d.LiftedAt, d.LiftedByUserId = &now, operatorUserId
+	return d, nil
+}
+
+// UnsuspendDueTeamMembers 恢复暂停期满的成员，供后台任务定期调用，返回恢复的人数
+func UnsuspendDueTeamMembers(ctx context.Context, now time.Time) (int, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT uuid FROM team_member_dismissals
+		WHERE kind = 'suspend' AND status = 'confirmed' AND lifted_at IS NULL AND suspended_until <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("查询到期暂停记录失败: %v", err)
+	}
+	var due []string
+	for rows.Next() {
+		var uuid string
+		if err = rows.Scan(&uuid); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("扫描到期暂停记录失败: %v", err)
+		}
+		due = append(due, uuid)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, uuid := range due {
+		tx, err := DB.BeginTx(ctx, nil)
+		if err != nil {
+			return count, fmt.Errorf("开始事务失败: %v", err)
+		}
+		_, err = liftTeamMemberSuspensionTx(ctx, tx, uuid, 0)
+		if errors.Is(err, ErrTeamMemberNotSuspended) {
+			tx.Rollback()
+			continue
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			return count, fmt.Errorf("恢复暂停记录 %s 失败: %v", uuid, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// afterChange 提议提交或处理后发送通知：待CEO确认的通知CEO，表决中的通知其余核心成员，确认后通知该成员；
+// 通知失败不影响处理结果
+func (d *TeamMemberDismissal) afterChange(ctx context.Context) {
+	full, err := GetTeamMemberDismissalByUuid(ctx, d.Uuid)
+	if err != nil {
+		util.Warningf("开除或暂停记录 %s 通知未发送: %v", d.Uuid, err)
+		return
+	}
+	switch full.Status {
+	case TeamMemberDismissalStatus_Pending:
+		if full.ByVote {
+			team := Team{Id: full.TeamId}
+			members, err := team.CoreMembers()
+			if err != nil {
+				util.Warningf("开除或暂停记录 %s 通知未发送，无法读取核心成员: %v", d.Uuid, err)
+				return
+			}
+			for _, m := range members {
+				if m.UserId != full.MemberUserId && m.UserId != full.ProposerUserId {
+					sendTeamMemberDismissalMessage(ctx, &full, m.UserId,
+						fmt.Sprintf("【成员%s表决】%s 提议%s CEO %s，理由：%s。请在茶团的开除与暂停记录中表决。",
+							full.KindString(), full.ProposerName, full.KindString(), full.MemberName, full.Reason))
+				}
+			}
+			return
+		}
+		team, err := GetTeam(full.TeamId)
+		if err != nil {
+			util.Warningf("开除或暂停记录 %s 通知未发送，无法读取茶团: %v", d.Uuid, err)
+			return
+		}
+		ceo, err := team.MemberCEO()
+		if err != nil {
+			util.Warningf("开除或暂停记录 %s 通知未发送，无法读取CEO: %v", d.Uuid, err)
+			return
+		}
+		if ceo.UserId == 0 {
+			ceo.UserId = team.FounderId
+		}
+		sendTeamMemberDismissalMessage(ctx, &full, ceo.UserId,
+			fmt.Sprintf("【成员%s】%s 提议%s %s，理由：%s。请在茶团的开除与暂停记录中确认或否决。",
+				full.KindString(), full.ProposerName, full.KindString(), full.MemberName, full.Reason))
+	case TeamMemberDismissalStatus_Confirmed:
+		content := fmt.Sprintf("【成员%s】你已被%s出茶团 %s，理由：%s。", full.KindString(), full.KindString(), full.TeamName, full.Reason)
+		if full.Kind == TeamMemberDismissalKind_Suspend {
+			content = fmt.Sprintf("【成员%s】你在茶团 %s 的品茶资格已暂停至 %s，理由：%s。",
+				full.KindString(), full.TeamName, full.SuspendedUntil.Format("2006-01-02 15:04"), full.Reason)
+		}
+		sendTeamMemberDismissalMessage(ctx, &full, full.MemberUserId, content)
+		if err := AddUserNotificationCount(full.MemberUserId); err != nil {
+			util.Warningf("开除或暂停记录 %s 小黑板通知失败: %v", d.Uuid, err)
+		}
+	}
+}
+
+// sendTeamMemberDismissalMessage 在团队消息盒子给某位成员发一条消息
+func sendTeamMemberDismissalMessage(ctx context.Context, d *TeamMemberDismissal, receiverUserId int, content string) {
+	var box MessageBox
+	if err := box.GetOrCreateMessageBoxWithContext(MessageBoxTypeTeam, d.TeamId, ctx); err != nil {
+		util.Warningf("开除或暂停记录 %s 通知未发送，无法获取团队 %d 消息盒子: %v", d.Uuid, d.TeamId, err)
+		return
+	}
+	if box.AllMessagesCount() >= box.MaxCount {
+		util.Warningf("开除或暂停记录 %s 通知未发送，团队 %d 消息盒子已满", d.Uuid, d.TeamId)
+		return
+	}
+	message := Message{
+		Uuid:           Random_UUID(),
+		MessageBoxId:   box.Id,
+		SenderType:     MessageSenderTypeTeam,
+		SenderObjectId: d.ProposerUserId,
+		ReceiverType:   MessageReceiverTypeMember,
+		ReceiverId:     receiverUserId,
+		Content:        content,
+	}
+	if err := message.CreateWithContext(ctx); err != nil {
+		util.Warningf("开除或暂停记录 %s 通知未发送: %v", d.Uuid, err)
+		return
+	}
+	box.Count++
+	if err := box.UpdateWithContext(ctx); err != nil {
+		util.Warningf("更新团队 %d 消息盒子计数失败: %v", d.TeamId, err)
+	}
+}
+
+const teamMemberDismissalColumns = `
+	d.id, d.uuid, d.team_id, t.name, t.uuid, d.member_id, d.member_user_id, mu.name, d.member_role, d.kind, d.suspend_days, d.reason,
+	d.proposer_user_id, pu.name, d.by_vote, d.status, COALESCE(d.decider_user_id, 0), COALESCE(du.name, ''), d.decision_note,
+	d.rejected_transfers, d.paused_schedules, d.suspended_until, d.lifted_at, COALESCE(d.lifted_by_user_id, 0),
+	d.member_read_at, d.created_at, d.decided_at
+	FROM team_member_dismissals d
+	JOIN teams t ON t.id = d.team_id
+	JOIN users mu ON mu.id = d.member_user_id
+	JOIN users pu ON pu.id = d.proposer_user_id
+	LEFT JOIN users du ON du.id = d.decider_user_id`
+
+func scanTeamMemberDismissal(row interface{ Scan(...any) error }, d *TeamMemberDismissal) error {
+	return row.Scan(&d.Id, &d.Uuid, &d.TeamId, &d.TeamName, &d.TeamUuid, &d.MemberId, &d.MemberUserId, &d.MemberName,
+		&d.MemberRole, &d.Kind, &d.SuspendDays, &d.Reason, &d.ProposerUserId, &d.ProposerName, &d.ByVote, &d.Status,
+		&d.DeciderUserId, &d.DeciderName, &d.DecisionNote, &d.RejectedTransfers, &d.PausedSchedules,
+		&d.SuspendedUntil, &d.LiftedAt, &d.LiftedByUserId, &d.MemberReadAt, &d.CreatedAt, &d.DecidedAt)
+}
+
+func queryTeamMemberDismissals(ctx context.Context, where string, args ...any) ([]TeamMemberDismissal, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT `+teamMemberDismissalColumns+` `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询开除或暂停记录失败: %v", err)
+	}
+	defer rows.Close()
+	var list []TeamMemberDismissal
+	for rows.Next() {
+		var d TeamMemberDismissal
+		if err = scanTeamMemberDismissal(rows, &d); err != nil {
+			return nil, fmt.Errorf("扫描开除或暂停记录失败: %v", err)
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// GetTeamMemberDismissalByUuid 按uuid读取开除或暂停记录
+func GetTeamMemberDismissalByUuid(ctx context.Context, uuid string) (TeamMemberDismissal, error) {
+	var d TeamMemberDismissal
+	err := scanTeamMemberDismissal(DB.QueryRowContext(ctx, `SELECT `+teamMemberDismissalColumns+` WHERE d.uuid = $1`, uuid), &d)
+	if err == sql.ErrNoRows {
+		return d, ErrTeamMemberDismissalNotFound
+	}
+	return d, err
+}
+
+// TeamMemberDismissalsByTeam 茶团的全部开除、暂停记录，最新的在前
+func TeamMemberDismissalsByTeam(ctx context.Context, teamId int) ([]TeamMemberDismissal, error) {
+	return queryTeamMemberDismissals(ctx, `WHERE d.team_id = $1 ORDER BY d.id DESC`, teamId)
+}
+
+// ConfirmedTeamMemberDismissals 茶团已确认的开除记录，最新的在前
+func ConfirmedTeamMemberDismissals(ctx context.Context, teamId int) ([]TeamMemberDismissal, error) {
+	return queryTeamMemberDismissals(ctx, `WHERE d.team_id = $1 AND d.kind = $2 AND d.status = $3 ORDER BY d.decided_at DESC`,
+		teamId, TeamMemberDismissalKind_Dismiss, TeamMemberDismissalStatus_Confirmed)
+}
+
+// TeamMemberDismissalsByMemberUser 茶友本人已确认的开除、暂停记录，最新的在前
+func TeamMemberDismissalsByMemberUser(ctx context.Context, userId int) ([]TeamMemberDismissal, error) {
+	return queryTeamMemberDismissals(ctx, `WHERE d.member_user_id = $1 AND d.status = $2 ORDER BY d.id DESC`,
+		userId, TeamMemberDismissalStatus_Confirmed)
+}
+
+// TeamMemberDismissalVotes 提议的表决情况，先表决的在前
+func TeamMemberDismissalVotes(ctx context.Context, dismissalId int) ([]TeamMemberDismissalVote, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT v.dismissal_id, v.voter_user_id, u.name, v.agree, v.created_at
+		FROM team_member_dismissal_votes v JOIN users u ON u.id = v.voter_user_id
+		WHERE v.dismissal_id = $1 ORDER BY v.id`, dismissalId)
+	if err != nil {
+		return nil, fmt.Errorf("查询表决失败: %v", err)
+	}
+	defer rows.Close()
+	var votes []TeamMemberDismissalVote
+	for rows.Next() {
+		var v TeamMemberDismissalVote
+		if err = rows.Scan(&v.DismissalId, &v.VoterUserId, &v.VoterName, &v.Agree, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("扫描表决失败: %v", err)
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+// MarkTeamMemberDismissalRead 成员本人查看已确认的记录，首次查看返回 true
+func MarkTeamMemberDismissalRead(ctx context.Context, d *TeamMemberDismissal) (bool, error) {
+	now := time.Now()
+	result, err := DB.ExecContext(ctx, `
+		UPDATE team_member_dismissals SET member_read_at = $2
+		WHERE id = $1 AND status = $3 AND member_read_at IS NULL`, d.Id, now, TeamMemberDismissalStatus_Confirmed)
+	if err != nil {
+		return false, fmt.Errorf("记录查看时间失败: %v", err)
+	}
+	n, _ := result.RowsAffected()
+	if n > 0 {
+		d.MemberReadAt = &now
+	}
+	return n > 0, nil
+}
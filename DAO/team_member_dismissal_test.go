@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTeamMemberDismissalVoteOutcome(t *testing.T) {
+	tests := []struct {
+		agree, oppose, eligible int
+		decided, confirmed      bool
+	}{
+		{1, 0, 1, true, true},   // 只有一位其余核心成员，提出即过半
+		{1, 0, 2, false, false}, // 两人中一人同意，尚未过半
+		{2, 0, 2, true, true},   // 两人都同意
+		{1, 1, 2, true, false},  // 一同意一反对，不可能过半
+		{1, 0, 3, false, false}, // 三人中一人同意
+		{2, 0, 3, true, true},   // 三人中两人同意
+		{1, 1, 3, false, false}, // 还剩一票可定
+		{1, 2, 3, true, false},  // 三人中两人反对
+		{0, 0, 0, true, false},  // 没有其余核心成员，无法表决
+		{2, 1, 4, false, false}, // 四人中两人同意一人反对，还差一票
+		{2, 2, 4, true, false},  // 四人中两人反对，同意不可能过半
+		{3, 1, 4, true, true},   // 四人中三人同意
+	}
+	for _, tt := range tests {
+		decided, confirmed := teamMemberDismissalVoteOutcome(tt.agree, tt.oppose, tt.eligible)
+		if decided != tt.decided || confirmed != tt.confirmed {
+			t.Errorf("outcome(%d, %d, %d) = %v/%v, want %v/%v",
+				tt.agree, tt.oppose, tt.eligible, decided, confirmed, tt.decided, tt.confirmed)
+		}
+	}
+}
+
+func TestTeamMemberDismissalStrings(t *testing.T) {
+	d := TeamMemberDismissal{Kind: TeamMemberDismissalKind_Dismiss, Status: TeamMemberDismissalStatus_Pending, MemberRole: RoleCTO}
+	if d.KindString() != "开除" || d.StatusString() != "待CEO确认" || d.RoleName() != RoleNameMap[RoleCTO] {
+		t.Errorf("开除提议 = %s/%s/%s", d.KindString(), d.StatusString(), d.RoleName())
+	}
+	d.ByVote = true
+	if d.StatusString() != "表决中" {
+		t.Errorf("表决中的提议 = %s", d.StatusString())
+	}
+	now := time.Now()
+	d = TeamMemberDismissal{Kind: TeamMemberDismissalKind_Suspend, Status: TeamMemberDismissalStatus_Confirmed}
+	if d.KindString() != "暂停" || d.StatusString() != "已确认" || !d.IsSuspending() {
+		t.Errorf("暂停中 = %s/%s/%v", d.KindString(), d.StatusString(), d.IsSuspending())
+	}
+	d.LiftedAt = &now
+	if d.StatusString() != "已恢复" || d.IsSuspending() {
+		t.Errorf("已恢复 = %s/%v", d.StatusString(), d.IsSuspending())
+	}
+}
+
+// 参数校验在访问数据库之前完成
+func TestProposeTeamMemberDismissalValidation(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		d    TeamMemberDismissal
+		want error
+	}{
+		{"空理由", TeamMemberDismissal{Kind: TeamMemberDismissalKind_Dismiss, Reason: "  ", MemberUserId: 2, ProposerUserId: 1}, ErrTeamMemberDismissalReason},
+		{"超长理由", TeamMemberDismissal{Kind: TeamMemberDismissalKind_Dismiss, Reason: strings.Repeat("茶", TeamMemberDismissalReasonMaxLen+1), MemberUserId: 2, ProposerUserId: 1}, ErrTeamMemberDismissalReason},
+		{"未知类别", TeamMemberDismissal{Kind: "fire", Reason: "r", MemberUserId: 2, ProposerUserId: 1}, ErrTeamMemberDismissalKind},
+		{"暂停0天", TeamMemberDismissal{Kind: TeamMemberDismissalKind_Suspend, Reason: "r", MemberUserId: 2, ProposerUserId: 1}, ErrTeamMemberDismissalDays},
+		{"暂停过长", TeamMemberDismissal{Kind: TeamMemberDismissalKind_Suspend, SuspendDays: TeamMemberSuspendMaxDays + 1, Reason: "r", MemberUserId: 2, ProposerUserId: 1}, ErrTeamMemberDismissalDays},
+		{"针对自己", TeamMemberDismissal{Kind: TeamMemberDismissalKind_Dismiss, Reason: "r", MemberUserId: 1, ProposerUserId: 1}, ErrTeamMemberDismissalSelf},
+	}
+	for _, tt := range tests {
+		if err := ProposeTeamMemberDismissal(ctx, &tt.d); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package dao
+
+import "time"
+
+// AcceptAssignmentsPageData 评审官指派记录页面数据
+type AcceptAssignmentsPageData struct {
+	SessUser     User
+	Assignments  []AcceptAssignment
+	TimeoutHours int64
+	Now          time.Time
+}
+
+// MyAcceptReviewsPageData 本人评审记录页面数据
+type MyAcceptReviewsPageData struct {
+	SessUser   User
+	Stats      AcceptReviewerStats
+	Open       []AcceptAssignment
+	Votes      []AcceptReviewVote
+	MinReviews int64
+	MinScore   int64
+	Now        time.Time
+}
+
+// AcceptReviewersPageData 评审官信誉管理页面数据
+type AcceptReviewersPageData struct {
+	SessUser   User
+	Reviewers  []AcceptReviewerStats
+	Verdicts   []AcceptVerdict
+	LowScore   int64
+	MinReviews int64
+	MinScore   int64
+}
+
+// MyAcceptAppealsPageData 本人申诉页面数据
+type MyAcceptAppealsPageData struct {
+	SessUser   User
+	Rejections []AcceptRejection
+	Appeals    []AcceptAppeal
+	WindowDays int64
+	MaxRounds  int64
+}
+
+// NewAcceptAppealPageData 填写申诉页面数据
+type NewAcceptAppealPageData struct {
+	SessUser         User
+	Rejection        AcceptRejection
+	TitleEditable    bool
+	ByOffice         bool // 这次申诉由茶博士复审
+	JustificationMax int
+	Verdicts         []AcceptVerdictRound
+}
+
+// AcceptAppealPageData 申诉详情页面数据
+type AcceptAppealPageData struct {
+	SessUser   User
+	Appeal     AcceptAppeal
+	Verdicts   []AcceptVerdictRound
+	IsOperator bool
+	CanDecide  bool
+}
+
+// AcceptAppealsPageData 申诉管理页面数据
+type AcceptAppealsPageData struct {
+	SessUser User
+	Pending  []AcceptAppeal
+	Recent   []AcceptAppeal
+}
@@ -27,6 +27,8 @@ type ObjectiveDetail struct {
 
 	ObjectiveBean    ObjectiveBean // 该茶话会资料夹
 	ProjectBeanSlice []ProjectBean // objective下所有projects
+
+	AcceptVerdicts []AcceptVerdictRound // 经申诉复审发布的，友邻蒙评结论链
 }
 
 // 茶话会页面（集合）页面渲染所需数据
@@ -112,6 +114,8 @@ type ProjectDetail struct {
 	IsHandicraftsCompleted bool // 手工艺是否全部完成
 
 	IsOverTwelve bool //是否超过12个
+
+	AcceptVerdicts []AcceptVerdictRound // 经申诉复审发布的，友邻蒙评结论链
 }
 
 // 入围茶台预设6茶议
@@ -174,6 +178,8 @@ type ThreadDetail struct {
 	IsHandicraftsCompleted    bool               // 所有手工艺已完成
 
 	StatsSet StatsSet //涉及人事统计值集合
+
+	AcceptVerdicts []AcceptVerdictRound // 经申诉复审发布的，友邻蒙评结论链
 }
 
 // 茶议对象和作者资料荚（豆荚一样有许多个单元）
@@ -241,6 +247,8 @@ type PostDetail struct {
 	QuoteObjectiveBean ObjectiveBean // 引用的茶围豆荚
 
 	IsOverTwelve bool // 是否超过12个
+
+	AcceptVerdicts []AcceptVerdictRound // 经申诉复审发布的，友邻蒙评结论链
 }
 type PostBean struct {
 	Post          Post
@@ -271,4 +279,6 @@ type AcceptObjectPageData struct {
 	Title    string //标题
 	Body     string //内容
 	Id       int    //ao_id
+
+	Justification string // 申诉复审时作者的申诉理由
 }
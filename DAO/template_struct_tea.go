@@ -0,0 +1,134 @@
+package dao
+
+// TeaLedgerFilterForm 回显到页面的筛选条件
+type TeaLedgerFilterForm struct {
+	Type      string
+	Direction string
+	From      string
+	To        string
+}
+
+// TeaLedgerPager 交易流水页面的分页信息，字段与接口返回的分页信息一致
+type TeaLedgerPager struct {
+	Page       int
+	Limit      int
+	Total      int
+	TotalPages int
+}
+
+// TeaLedgerPageData 交易流水页面数据
+type TeaLedgerPageData struct {
+	SessUser      User
+	Team          *Team // 用户流水页面为nil
+	Balance       int64
+	Locked        int64
+	StatusDisplay string
+	Entries       []TeaLedgerEntry
+	Types         []string
+	Filter        TeaLedgerFilterForm
+	PageInfo      TeaLedgerPager
+}
+
+// TeaExchangePageData 兑换页面数据
+type TeaExchangePageData struct {
+	SessUser  User
+	Team      *Team // 用户账户时为nil
+	Balance   int64
+	Locked    int64
+	Frozen    bool
+	Provider  string
+	Orders    []TeaExchangeOrder
+	PerFen    int
+	ReturnUrl string
+}
+
+// TeaExchangeAdminPageData 兑换管理页面数据
+type TeaExchangeAdminPageData struct {
+	SessUser User
+	Orders   []TeaExchangeOrder
+	Supply   TeaSupplyReport
+	LocalPay bool // 启用了本地模拟渠道，可模拟到账
+}
+
+// TeaTeamTransferPolicyPageData 团队转出审批策略页面数据
+type TeaTeamTransferPolicyPageData struct {
+	SessUser     User
+	Team         Team
+	Policy       TeaTeamTransferPolicy
+	CoreMembers  int
+	IsCoreMember bool
+	CanEdit      bool // CEO或创建人，可以修改策略
+	MaxApprovals int
+	LoosenHours  int64                         // 放宽策略的生效等待时间（小时）
+	Pending      *TeaTeamTransferPolicyChange  // 尚未生效的放宽
+	Changes      []TeaTeamTransferPolicyChange // 最近的修改记录
+}
+
+// TeaTeamTransferSchedulesPageData 团队定期转账列表页面数据
+type TeaTeamTransferSchedulesPageData struct {
+	SessUser     User
+	Team         Team
+	Schedules    []TeaTeamTransferSchedule
+	DefaultStart string
+	Frequencies  []TeaTransferScheduleOption
+}
+
+// TeaTeamTransferSchedulePageData 团队定期转账详情页面数据
+type TeaTeamTransferSchedulePageData struct {
+	SessUser  User
+	Team      Team
+	Schedule  TeaTeamTransferSchedule
+	Creator   User
+	Runs      []TeaTeamTransferScheduleRun
+	CanManage bool
+}
+
+// TeaTransferScheduleOption 定期转账频率选项
+type TeaTransferScheduleOption struct {
+	Value string
+	Text  string
+}
+
+// TeaStatementPageData 打印版对账单页面数据
+type TeaStatementPageData struct {
+	SessUser  User
+	Statement TeaStatement
+}
+
+// TeaAccountFreezePageData 冻结记录页面数据
+type TeaAccountFreezePageData struct {
+	SessUser     User
+	HolderType   string
+	Team         Team // 团队账户时有效
+	Status       string
+	FrozenReason string
+	Events       []TeaAccountFreezeEvent
+	Appeals      []TeaFreezeAppeal
+	CanAppeal    bool // 冻结中、没有待审核申诉且有权申诉
+	AppealAction string
+}
+
+// IsFrozen 账户是否冻结中
+func (d *TeaAccountFreezePageData) IsFrozen() bool {
+	return d.Status == TeaAccountStatus_Frozen
+}
+
+// TeaFreezeAppealsPageData 待审核申诉页面数据
+type TeaFreezeAppealsPageData struct {
+	SessUser User
+	Appeals  []TeaFreezeAppeal
+}
+
+// TeaUserTransferHoldsPageData 发起人的待审核转账页面数据
+type TeaUserTransferHoldsPageData struct {
+	SessUser User
+	Holds    []TeaUserTransferDecision
+}
+
+// TeaTransferHoldsAdminPageData 待审核转账审核页面数据
+type TeaTransferHoldsAdminPageData struct {
+	SessUser  User
+	Limits    TeaUserTransferLimits
+	Holds     []TeaUserTransferDecision
+	Decisions []TeaUserTransferDecision
+}
@@ -36,6 +36,8 @@ type GroupDetail struct {
 	TeamBeanSlice []TeamBean
 	//FirstTeamBean TeamBean // 集团第一/顶级管理团队（董事会？）
 	IsOverTwelve bool
+
+	AcceptVerdicts []AcceptVerdictRound // 经申诉复审发布的，友邻蒙评结论链
 }
 
 // 集团详情资料荚
@@ -204,4 +206,36 @@ type TeamDetail struct {
 	MessageCount int         //用户可见的消息总数
 
 	GroupBean *GroupBean //所属集团资料夹（如果有）
+
+	AcceptVerdicts []AcceptVerdictRound // 经申诉复审发布的，友邻蒙评结论链
+}
+
+// MemberFirePageData 填写开除、暂停提议页面数据
+type MemberFirePageData struct {
+	SessUser   User
+	Team       Team
+	Member     User
+	TeamMember TeamMember
+	IsCEO      bool // 对象是CEO，由其余核心成员表决
+	MaxDays    int
+	ReasonMax  int
+}
+
+// TeamMemberDismissalPageData 开除、暂停提议详情页面数据
+type TeamMemberDismissalPageData struct {
+	SessUser    User
+	Dismissal   TeamMemberDismissal
+	Votes       []TeamMemberDismissalVote
+	IsMember    bool // 当前茶友是被开除或暂停的成员
+	CanDecide   bool
+	CanVote     bool
+	CanWithdraw bool
+	CanLift     bool
+}
+
+// TeamMemberDismissalsPageData 茶团开除、暂停记录页面数据
+type TeamMemberDismissalsPageData struct {
+	SessUser   User
+	Team       Team
+	Dismissals []TeamMemberDismissal
 }
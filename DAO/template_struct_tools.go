@@ -1,5 +1,7 @@
 package dao
 
+import "time"
+
 // 查询功能，根据关键词查找数据库记录，所得到的数据集合，页面数据
 type SearchPageData struct {
 	SessUser User
@@ -109,3 +111,12 @@ type GoodsProjectList struct {
 
 	GoodsList []Goods
 }
+
+// JobsPageData 后台任务管理页面数据
+type JobsPageData struct {
+	SessUser User
+	Jobs     []JobInfo
+	Runs     []JobRun
+	Name     string // 筛选执行记录的任务名
+	Now      time.Time
+}
@@ -121,3 +121,18 @@ type LetterboxPageData struct {
 	GroupInvitationBeanSlice   []GroupInvitationBean //集团邀请函
 	GroupInvitationUnreadCount int                   //未读集团邀请函数量
 }
+
+// 我的登船设备（会话）页面数据
+type UserSessionsPageData struct {
+	SessUser User
+
+	CurrentDeviceId string    // 当前设备会话的 DeviceId，页面上不出现会话 Uuid
+	Sessions        []Session // 全部未过期会话，最近活动的在前
+}
+
+// ExpiryNoticesPageData 过期提醒页面数据
+type ExpiryNoticesPageData struct {
+	SessUser                   User
+	Notices                    []ExpiryNotice
+	GroupInvitationUnreadCount int
+}
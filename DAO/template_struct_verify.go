@@ -11,6 +11,8 @@ type VerifierWorkspagePageData struct {
 	PauseOrderCount     int
 	CancelledOrderCount int
 	CompletedOrderCount int
+	PendingDisputes     []*TeaOrderDepositDispute // 待仲裁的预备金托管争议（公道杯）
+	PendingDisputeCount int
 }
 type TeaOrderBean struct {
 	TeaOrder         *TeaOrder
@@ -25,3 +27,17 @@ type TeaOrderBean struct {
 	StatusLabelClass string // 根据状态返回Bootstrap标签类，如"warning"、"success"等
 	CreatedDateTime  string // 格式化后的时间
 }
+
+// FairnessMugPageData 公道杯（预备金托管争议）页面数据
+type FairnessMugPageData struct {
+	SessUser       User
+	TeaOrder       *TeaOrder
+	Deposit        *TeaOrderDeposit
+	Dispute        *TeaOrderDepositDispute // 发起争议表单页面时为 nil
+	InitiatorTeam  Team                    // 发起方（或准备发起争议的）团队
+	RespondentTeam Team                    // 被诉方团队
+	IsInitiator    bool                    // 当前用户是否发起方团队核心成员
+	IsRespondent   bool                    // 当前用户是否被诉方团队核心成员
+	CanArbitrate   bool                    // 当前用户是否可以仲裁（见证者且无需利益回避）
+	ConflictNotice string                  // 见证者需要回避时的提示
+}
@@ -52,6 +52,7 @@ func TestThread_IsEdited(t *testing.T) {
 }
 
 func TestThread_NumReplies(t *testing.T) {
+	requireDB(t)
 	thread := Thread{Id: 1}
 	count := thread.NumReplies()
 
@@ -61,6 +62,7 @@ func TestThread_NumReplies(t *testing.T) {
 }
 
 func TestThread_NumSupport(t *testing.T) {
+	requireDB(t)
 	thread := Thread{Id: 1}
 	count := thread.NumSupport()
 
@@ -70,6 +72,7 @@ func TestThread_NumSupport(t *testing.T) {
 }
 
 func TestThread_NumOppose(t *testing.T) {
+	requireDB(t)
 	thread := Thread{Id: 1}
 	count := thread.NumOppose()
 
@@ -100,6 +103,7 @@ func TestThread_IsAuthor(t *testing.T) {
 }
 
 func TestThread_UpdateBodyAndClass(t *testing.T) {
+	requireDB(t)
 	thread := Thread{Id: 1}
 	ctx := context.Background()
 
@@ -110,6 +114,7 @@ func TestThread_UpdateBodyAndClass(t *testing.T) {
 }
 
 func TestHotThreads(t *testing.T) {
+	requireDB(t)
 	ctx := context.Background()
 
 	tests := []struct {
@@ -146,6 +151,7 @@ func TestHotThreads(t *testing.T) {
 }
 
 func TestGetThreadByUUID(t *testing.T) {
+	requireDB(t)
 
 	tests := []struct {
 		name      string
@@ -171,6 +177,7 @@ func TestGetThreadByUUID(t *testing.T) {
 }
 
 func TestGetThreadById(t *testing.T) {
+	requireDB(t)
 
 	_, err := GetThreadById(1)
 	if err != nil && err != sql.ErrNoRows {
@@ -179,6 +186,7 @@ func TestGetThreadById(t *testing.T) {
 }
 
 func TestProject_ThreadsNormal(t *testing.T) {
+	requireDB(t)
 
 	project := Project{Id: 1}
 	ctx := context.Background()
@@ -195,6 +203,7 @@ func TestProject_ThreadsNormal(t *testing.T) {
 }
 
 func TestProject_ThreadAppointment(t *testing.T) {
+	requireDB(t)
 
 	project := Project{Id: 1}
 	ctx := context.Background()
@@ -206,6 +215,7 @@ func TestProject_ThreadAppointment(t *testing.T) {
 }
 
 func TestProject_ThreadsSeeSeek(t *testing.T) {
+	requireDB(t)
 
 	project := Project{Id: 1}
 	ctx := context.Background()
@@ -222,6 +232,7 @@ func TestProject_ThreadsSeeSeek(t *testing.T) {
 }
 
 func TestProject_ThreadsBrainFire(t *testing.T) {
+	requireDB(t)
 
 	project := Project{Id: 1}
 	ctx := context.Background()
@@ -238,6 +249,7 @@ func TestProject_ThreadsBrainFire(t *testing.T) {
 }
 
 func TestProject_ThreadsSuggestion(t *testing.T) {
+	requireDB(t)
 
 	project := Project{Id: 1}
 	ctx := context.Background()
@@ -254,6 +266,7 @@ func TestProject_ThreadsSuggestion(t *testing.T) {
 }
 
 func TestProject_ThreadsGoods(t *testing.T) {
+	requireDB(t)
 
 	project := Project{Id: 1}
 	ctx := context.Background()
@@ -270,6 +283,7 @@ func TestProject_ThreadsGoods(t *testing.T) {
 }
 
 func TestProject_ThreadsHandicraft(t *testing.T) {
+	requireDB(t)
 
 	project := Project{Id: 1}
 	ctx := context.Background()
@@ -286,6 +300,7 @@ func TestProject_ThreadsHandicraft(t *testing.T) {
 }
 
 func TestSearchThreadByTitle(t *testing.T) {
+	requireDB(t)
 	ctx := context.Background()
 
 	tests := []struct {
@@ -359,6 +374,7 @@ func TestThread_EditAtDate(t *testing.T) {
 }
 
 func TestCreateRequiredThreads(t *testing.T) {
+	requireDB(t)
 
 	objective := &Objective{Id: 1, FamilyId: 1, TeamId: 1}
 	project := &Project{Id: 1, Class: 1, IsPrivate: false}
@@ -372,6 +388,7 @@ func TestCreateRequiredThreads(t *testing.T) {
 }
 
 func TestThreadApproved_Create(t *testing.T) {
+	requireDB(t)
 
 	approved := ThreadApproved{
 		ProjectId: 1,
@@ -386,6 +403,7 @@ func TestThreadApproved_Create(t *testing.T) {
 }
 
 func TestThread_IsApproved(t *testing.T) {
+	requireDB(t)
 
 	thread := Thread{Id: 1}
 	result := thread.IsApproved()
@@ -163,6 +163,10 @@ func (user *User) Create() (err error) {
 	// you're always using a sequence.You need to use the RETURNING keyword in your insert to get this
 	// information from postgres.
 
+	hashed, err := HashPassword(user.Password)
+	if err != nil {
+		return
+	}
 	statement := "INSERT INTO users (uuid, name, email, password, created_at, biography, role, gender, avatar) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, uuid"
 	stmt, err := DB.Prepare(statement)
 	if err != nil {
@@ -171,7 +175,7 @@ func (user *User) Create() (err error) {
 	defer stmt.Close()
 
 	// use QueryRow to return a row and scan the returned id into the User struct
-	err = stmt.QueryRow(Random_UUID(), user.Name, user.Email, Encrypt(user.Password), time.Now(), user.Biography, user.Role, user.Gender, user.Avatar).Scan(&user.Id, &user.Uuid)
+	err = stmt.QueryRow(Random_UUID(), user.Name, user.Email, hashed, time.Now(), user.Biography, user.Role, user.Gender, user.Avatar).Scan(&user.Id, &user.Uuid)
 	return
 }
 
@@ -214,6 +218,24 @@ func (user *User) UpdateAvatar() (err error) {
 	return
 }
 
+// UpdatePassword 修改用户登录密码（传入明文，保存摘要）
+func (user *User) UpdatePassword(newPassword string) (err error) {
+	statement := "UPDATE users SET password = $2, updated_at = $3 where id = $1"
+	stmt, err := DB.Prepare(statement)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	hashed, err := HashPassword(newPassword)
+	if err != nil {
+		return
+	}
+	user.Password = hashed
+	_, err = stmt.Exec(user.Id, user.Password, time.Now())
+	return
+}
+
 // Get a single user given the email，limit - 限制查询结果数量,5秒超时就取消
 func GetUserByEmail(email string, ctx context.Context) (user User, err error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -5,10 +5,8 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
-	"strings"
 	dao "teachat/DAO"
 	util "teachat/Util"
-	"time"
 )
 
 // Handler /v1/office/polite
@@ -78,15 +76,6 @@ func PolitePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 声明 1 友邻蒙评 记录
-	newAcceptance := dao.Acceptance{
-		AcceptObjectId: ao_id_int,
-		XAccept:        false,
-		XUserId:        s_u.Id,
-		YAccept:        false,
-		YUserId:        dao.UserId_None,
-	}
-
 	// 权限检查。。。
 	ok, err := s_u.CheckHasReadAcceptNotification(ao_id_int, r.Context())
 	if err != nil {
@@ -99,57 +88,40 @@ func PolitePost(w http.ResponseWriter, r *http.Request) {
 		report(w, s_u, "你好，(茶博士摸摸头想了又想), 这里真的可以接受无票喝茶吗？")
 		return
 	}
-
-	// 检查ao对象是否已经有记录，如果没有，说明是第一位友邻提交评判结果。如果有记录，说明是第二位友邻提交。
-	oldAcceptance, err := newAcceptance.GetByAcceptObjectId()
+	// 有指派记录的对象，须本人的指派仍待答复（未超时改派，也未提交过意见）
+	recorded, open, err := dao.AcceptAssignmentOpen(r.Context(), ao_id_int, s_u.Id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			// 没有记录
-			if civilizer == "yes" && care == "yes" {
-				// ok
-				newAcceptance.XAccept = true
-			} else {
-				newAcceptance.XAccept = false
-			}
-			// 创建初记录
-			if err = newAcceptance.Create(); err != nil {
-				util.Debug(" Cannot create acceptance first", err)
-				report(w, s_u, "你好，(摸摸头想了又想), 茴香豆的茴字真的有四种写法吗？")
-				return
-			}
-			// 友邻蒙评审茶首次记录完成
-			report(w, s_u, "好茶香护有缘人，感谢你出手维护文明秩序！")
-			return
-		} else {
-			util.Debug(" Cannot check acceptance by ao_id", err)
-			report(w, s_u, "你好，(摸摸头想了又想)，去年今日此门中，人面桃花相映红。")
-			return
-		}
+		util.Debug("Cannot check accept assignment", ao_id_int, err)
+		report(w, s_u, "你好，(茶博士摸摸头想了又想), 茴香豆的茴字真的有四种写法吗？")
+		return
 	}
-	// err==nil说明已经有记录，说明是第二位茶语审核官的提交
-	// update旧记录
-	if civilizer == "yes" && care == "yes" {
-		// ok
-		oldAcceptance.YAccept = true
-	} else {
-		oldAcceptance.YAccept = false
+	if recorded && !open {
+		report(w, s_u, "你好，这份新茶已超过答复期限改派其他茶友评审，或者你已经提交过评审意见。")
+		return
 	}
-	oldAcceptance.YUserId = s_u.Id
 
-	now_time := time.Now() //DeepSeek教的方法
-	oldAcceptance.YAcceptedAt = &now_time
-
-	if err = oldAcceptance.Update(); err != nil {
-		util.Debug(" Cannot update acceptance", err)
+	// 蒙评记录与评审意见同一事务写入：还没有记录的是第一位友邻（X），已有记录的是第二位（Y）
+	acceptance, second, err := dao.SubmitAcceptReview(r.Context(), ao_id_int, s_u.Id, civilizer == "yes" && care == "yes")
+	if err != nil {
+		if errors.Is(err, dao.ErrAcceptReviewDecided) {
+			report(w, s_u, "你好，这份新茶的评审意见已经提交过了，不能重复评审。")
+			return
+		}
+		util.ErrorContext(r.Context(), " Cannot submit accept review", ao_id_int, err)
 		report(w, s_u, "你好，(摸摸头想了又想),隔岸花分一脉香。")
 		return
 	}
+	if !second {
+		// 友邻蒙评审茶首次记录完成
+		report(w, s_u, "好茶香护有缘人，感谢你出手维护文明秩序！")
+		return
+	}
 
 	//以下是根据两个审核意见处理新茶语
 
 	// 新声明一个审核对象，映射审核对象id
 	ao := dao.AcceptObject{
-		Id: oldAcceptance.AcceptObjectId,
+		Id: acceptance.AcceptObjectId,
 	}
 	// 读取这个审核对象（根据审核对象id）
 	if err = ao.Get(); err != nil {
@@ -158,206 +130,12 @@ func PolitePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// 检查新茶评审结果,如果任意一位友邻否定这是文明发言，就判断为不通过审核
-	if !oldAcceptance.XAccept || !oldAcceptance.YAccept {
-		//友邻蒙评拒绝接纳这个茶语！Oh my...
-		// 通知茶语主人友邻蒙评结果为：婉拒！---没有通知
-		// 根据对象类型处理
-		switch ao.ObjectType {
-		case dao.AcceptObjectTypeObjective:
-			ob := dao.Objective{
-				Id: ao.ObjectId}
-			if err = ob.Get(); err != nil {
-				util.Debug("Cannot get objective", err)
-				report(w, s_u, "你好，茶博士失魂鱼，竟然说没有找到新茶茶叶的资料未必是怪事。")
-				return
-			}
-			switch ob.Class {
-			case dao.ObClassOpenDraft:
-				ob.Class = dao.ObClassNeighborRejectOpen
-			case dao.ObClassCloseDraft:
-				ob.Class = dao.ObClassNeighborRejectClose
-			}
-			// 更新茶话会，友邻蒙评未通过！
-			if err = ob.UpdateClass(); err != nil {
-				util.Debug("Cannot update ob class", err)
-				report(w, s_u, "你好，(摸摸头想了又想), 为什么踢足球的人都说临门一脚最麻烦呢？")
-				return
-			}
-		case dao.AcceptObjectTypeProject:
-			pr := dao.Project{
-				Id: ao.ObjectId,
-			}
-			if err = pr.Get(); err != nil {
-				util.Debug("Cannot get project", err)
-				report(w, s_u, "你好，茶博士失魂鱼，竟然说有时找茶叶也是一种修养的过程。")
-				return
-			}
-			switch pr.Class {
-			case dao.PrClassOpenDraft:
-				pr.Class = dao.PrClassRejectedOpen
-			case dao.PrClassCloseDraft:
-				pr.Class = dao.PrClassClose
-			}
-			// 更新茶台属性，
-			if err = pr.UpdateClass(); err != nil {
-				util.Debug("Cannot update pr class", err)
-				report(w, s_u, "你好，一畦春韭绿，十里稻花香。")
-				return
-			}
-		case dao.AcceptObjectTypeThread:
-			dThread := dao.DraftThread{
-				Id: ao.ObjectId,
-			}
-
-			if err = dThread.Get(); err != nil {
-				util.Debug("Cannot get dfart-thread", err)
-				report(w, s_u, "你好，茶博士失魂鱼，竟然说有时候找茶叶需要的不是技术,而是耐心。")
-				return
-			}
-			// 更新茶议属性，友邻蒙评 已拒绝公开发布
-			if err = dThread.UpdateStatus(dao.DraftThreadStatusRejected); err != nil {
-				util.Debug("Cannot update thread class", err)
-				report(w, s_u, "你好，睿藻仙才盈彩笔，自惭何敢再为辞。")
-				return
-			}
-		case dao.AcceptObjectTypePost:
-			dPost := dao.DraftPost{
-				Id: ao.ObjectId,
-			}
-			if err = dPost.Get(); err != nil {
-				util.Debug("Cannot get draft-post", err)
-				report(w, s_u, "你好，茶博士失魂鱼，竟然说有时候 弄丢草稿的人不一定是诗人？")
-				return
-			}
-			if err = dPost.UpdateClass(dao.DraftPostClassRejectedByNeighbor); err != nil {
-				util.Debug("Cannot update draft-post class", err)
-				report(w, s_u, "你好，宝鼎茶闲烟尚绿，幽窗棋罢指犹凉。")
-				return
-			}
-		case dao.AcceptObjectTypeTeam:
-			team, err := dao.GetTeam(ao.ObjectId)
-			if err != nil {
-				util.Debug("Cannot get team", err)
-				report(w, s_u, "你好，茶博士失魂鱼，竟然说有时候临急抱佛脚比刻苦奋斗更有用？")
-				return
-			}
-			switch team.Class {
-			case dao.TeamClassOpenDraft:
-				team.Class = dao.TeamClassRejectedOpenDraft
-			case dao.TeamClassCloseDraft:
-				team.Class = dao.TeamClassRejectedCloseDraft
-			}
-			if err = team.UpdateClass(); err != nil {
-				util.Debug("Cannot update team class", err)
-				report(w, s_u, "你好，（摸摸头）考一考你，错里错以错劝哥哥是什么茶品种？")
-				return
-			}
-		}
-		// 感谢友邻维护了茶棚的文明秩序
-		report(w, s_u, "好茶香护有缘人，感谢你出手维护文明品茶秩序！")
-		return
-	} else {
-		// 两个审茶官都认为这是文明发言
-		// 根据对象类型处理
-		switch ao.ObjectType {
-		case dao.AcceptObjectTypeObjective:
-			if _, err = acceptNewObjective(ao.ObjectId); err != nil {
-				report(w, s_u, err.Error())
-				return
-			}
-
-		case dao.AcceptObjectTypeProject:
-			if err = acceptNewProject(ao.ObjectId); err != nil {
-				report(w, s_u, err.Error())
-				return
-			}
-		case dao.AcceptObjectTypeThread:
-			_, err := acceptNewDraftThread(ao.ObjectId)
-			if err != nil {
-				switch {
-				case strings.Contains(err.Error(), "获取茶议草稿失败"):
-					util.Debug("Cannot get draft-thread", err)
-					report(w, s_u, "你好，茶博士失魂鱼，竟然说有时候泡一壶好茶的关键，需要的不是技术而是耐心。")
-				case strings.Contains(err.Error(), "更新茶议草稿状态失败"):
-					util.Debug("Cannot update draft-thread status", err)
-					report(w, s_u, "你好，睿藻仙才盈彩笔，自惭何敢再为辞。")
-				case strings.Contains(err.Error(), "创建新茶议失败"):
-					util.Debug("Cannot save thread", err)
-					report(w, s_u, "你好，吟成荳蔻才犹艳，睡足酴醾梦也香。")
-				default:
-					util.Debug("未知错误", err)
-					report(w, s_u, "世事洞明皆学问，人情练达即文章。")
-				}
-				return
-			}
-		case dao.AcceptObjectTypePost:
-			_, err = acceptNewDraftPost(ao.ObjectId)
-			if err != nil {
-				switch {
-				case strings.Contains(err.Error(), "获取品味草稿失败"):
-					util.Debug("Cannot get draft-post", err)
-					report(w, s_u, "你好，茶博士失魂鱼，竟然说有时候泡一壶好茶的关键，需要的不是技术而是耐心。")
-				case strings.Contains(err.Error(), "创建新品味失败"):
-					util.Debug("Cannot save post", err)
-					report(w, s_u, "你好，吟成荳蔻才犹艳，睡足酴醾梦也香。")
-				default:
-					util.Debug("处理接纳新品味时发生未知错误", err)
-					report(w, s_u, "世事洞明皆学问，人情练达即文章。")
-				}
-				return
-			}
-
-		case dao.AcceptObjectTypeTeam:
-			//把草团转为正式$事业茶团
-			team, err := acceptNewTeam(ao.ObjectId)
-			if err != nil {
-				util.Debug("Cannot accept new team", err)
-				report(w, s_u, "盛世无饥馑，何须耕织忙？不急不急。")
-				return
-			}
-
-			// 将设立team的Founder作为默认的CEO角色成员，teamMember.Role=dao.RoleCEO
-			teamMember := dao.TeamMember{
-				TeamId: team.Id,
-				UserId: team.FounderId,
-				Role:   dao.RoleCEO,
-				Status: dao.TeamMemberStatusActive,
-			}
-			if err = teamMember.Create(); err != nil {
-				util.Debug("Cannot create team-member", err)
-				report(w, s_u, "你好，花因喜洁难寻偶，人为悲秋易断魂。")
-				return
-			}
-			//检查团队发起人是否设置了（有效）非占位默认$茶团，
-			//如果还没有，把这个新茶团设置为默认$茶团
-			t_founder, err := dao.GetUser(team.FounderId)
-			if err != nil {
-				util.Debug("Cannot get team founder", err)
-				report(w, s_u, "你好，吟成荳蔻才犹艳，睡足酴醾梦也香。请稍后再试。")
-				return
-			}
-			if !setUserDefaultTeam(t_founder, team.Id, w) {
-				return
-			}
-
-		case dao.AcceptObjectTypeGroup:
-			// 接纳新集团
-			_, err := acceptNewGroup(ao.ObjectId)
-			if err != nil {
-				util.Debug("Cannot accept new team", err)
-				report(w, s_u, "盛世无饥馑，何须耕织忙？不急不急。")
-			}
-
-		default:
-			util.Debug("Cannot get object", err)
-			report(w, s_u, "你好，茶博士失魂鱼，竟然说有时候喝茶比做傻事强？")
-			return
-		}
-
-		// 感谢友邻维护了茶棚的文明秩序
-		report(w, s_u, "好茶香护有缘人，感谢你出手维护文明品茶秩序！")
+	if err = settleAcceptVerdict(r.Context(), ao, acceptance.XAccept && acceptance.YAccept); err != nil {
+		report(w, s_u, err.Error())
 		return
 	}
+	// 感谢友邻维护了茶棚的文明秩序
+	report(w, s_u, "好茶香护有缘人，感谢你出手维护文明品茶秩序！")
 }
 
 // Get /v1/office/polite?id=123456
@@ -399,6 +177,18 @@ func PoliteGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 有指派记录的对象，超时改派后不能再评审
+	recorded, open, err := dao.AcceptAssignmentOpen(r.Context(), ao.Id, s_u.Id)
+	if err != nil {
+		util.Debug("Cannot check accept assignment", ao.Id, err)
+		report(w, s_u, "你好，茶博士莫名其妙，竟然说没有机票也登船有时候是合情合理的。")
+		return
+	}
+	if recorded && !open {
+		report(w, s_u, "你好，这份新茶已超过答复期限改派其他茶友评审，或者你已经提交过评审意见。")
+		return
+	}
+
 	//读取友邻蒙评邀请函
 	var acceptNotification dao.AcceptNotification
 	if err = acceptNotification.GetAccNotiByUIdAndAOId(s_u.Id, ao.Id); err != nil {
@@ -519,6 +309,11 @@ func PoliteGet(w http.ResponseWriter, r *http.Request) {
 
 	aopd.SessUser = s_u
 	aopd.Id = ao.Id
+	if appeal, err := dao.GetAcceptAppealByReviewObject(r.Context(), ao.Id); err == nil {
+		aopd.Justification = appeal.Justification
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		util.Debug("Cannot get accept appeal", ao.Id, err)
+	}
 
 	// 减少1新通知小黑板用户通知记录
 	if err = dao.SubtractUserNotificationCount(s_u.Id); err != nil {
@@ -0,0 +1,290 @@
+package route
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	dao "teachat/DAO"
+	util "teachat/Util"
+)
+
+/*
+友邻蒙评申诉：
+1、GET /v1/office/appeals 本人可以申诉的婉拒及已提出的申诉；
+2、GET /v1/office/appeal/new?id= 针对某一轮婉拒（蒙评对象id）填写修订稿和申诉理由，
+   POST /v1/office/appeal/create 提交申诉，字段 id、title（茶围、茶台、茶议可改）、body、justification；
+   友邻复审的随即指派新的一对评审官，找不到评审官时转由茶博士复审；
+3、GET /v1/office/appeal?uuid= 申诉详情及结论链，作者本人和茶博士/船长可看；
+4、茶博士/船长：GET /v1/admin/accept/appeals 待茶博士复审及最近的申诉，
+   POST /v1/admin/accept/appeal/decide 茶博士复审，字段 uuid、verdict（accept|reject）、note（维持婉拒时必填）。
+*/
+
+const (
+	myAcceptAppealsPageLimit = 50
+	acceptAppealsPageLimit   = 50
+)
+
+// isTeaOperator 当值的茶博士或船长
+func isTeaOperator(u dao.User) bool {
+	return u.Role == dao.User_Role_TeaOffice || u.Role == dao.User_Role_Captain
+}
+
+// acceptAppealUserError 可以直接告诉茶友的申诉错误
+func acceptAppealUserError(err error) bool {
+	for _, e := range []error{dao.ErrAcceptAppealNotRejected, dao.ErrAcceptAppealNotAuthor, dao.ErrAcceptAppealExpired,
+		dao.ErrAcceptAppealNoRounds, dao.ErrAcceptAppealNotPending, dao.ErrAcceptAppealJustification} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// publishedAcceptVerdicts 已发布对象的友邻蒙评结论链，只有经申诉复审发布的才有；查询失败不影响详情页
+func publishedAcceptVerdicts(r *http.Request, objectType, publishedId int) []dao.AcceptVerdictRound {
+	chain, err := dao.PublishedAcceptVerdictChain(r.Context(), objectType, publishedId)
+	if err != nil {
+		util.WarningContext(r.Context(), " Cannot get accept verdict chain", objectType, publishedId, err)
+		return nil
+	}
+	return chain
+}
+
+// HandleMyAcceptAppeals GET /v1/office/appeals 本人可以申诉的婉拒及已提出的申诉
+func HandleMyAcceptAppeals(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	rejections, err := dao.AppealableAcceptRejections(r.Context(), s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get appealable accept rejections", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取可以申诉的婉拒，请稍后再试。")
+		return
+	}
+	appeals, err := dao.AcceptAppealsByAuthor(r.Context(), s_u.Id, myAcceptAppealsPageLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get accept appeals", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取你的申诉，请稍后再试。")
+		return
+	}
+	pageData := dao.MyAcceptAppealsPageData{
+		SessUser:   s_u,
+		Rejections: rejections,
+		Appeals:    appeals,
+		WindowDays: util.Config.AcceptAppealWindowDays,
+		MaxRounds:  util.Config.AcceptAppealMaxRounds,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "accept.appeals")
+}
+
+// NewAcceptAppealGet GET /v1/office/appeal/new?id= 填写申诉
+func NewAcceptAppealGet(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	aoId, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || aoId <= 0 {
+		report(w, s_u, "你好，缺少编号参数，茶博士找不到茶叶的资料。")
+		return
+	}
+	rejection, err := dao.GetAppealableAcceptRejection(r.Context(), aoId, s_u.Id)
+	if err != nil {
+		if acceptAppealUserError(err) {
+			report(w, s_u, "你好，"+err.Error()+"。")
+			return
+		}
+		util.DebugContext(r.Context(), "cannot get appealable accept rejection", aoId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取被婉拒的茶叶资料，请稍后再试。")
+		return
+	}
+	verdicts, err := dao.AcceptVerdictChain(r.Context(), rejection.ObjectType, rejection.ObjectId)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get accept verdict chain", aoId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取历次评审结论，请稍后再试。")
+		return
+	}
+	pageData := dao.NewAcceptAppealPageData{
+		SessUser:         s_u,
+		Rejection:        rejection,
+		TitleEditable:    dao.AcceptObjectTitleEditable(rejection.ObjectType),
+		ByOffice:         int64(rejection.Appeals+1) >= util.Config.AcceptAppealMaxRounds,
+		JustificationMax: dao.AcceptAppealJustificationMaxLen,
+		Verdicts:         verdicts,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "accept.appeal_new", "component_accept_verdicts")
+}
+
+// CreateAcceptAppealPost POST /v1/office/appeal/create 提交申诉
+func CreateAcceptAppealPost(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	aoId, err := strconv.Atoi(r.PostFormValue("id"))
+	if err != nil || aoId <= 0 {
+		report(w, s_u, "你好，缺少编号参数，茶博士找不到茶叶的资料。")
+		return
+	}
+	appeal, err := dao.FileAcceptAppeal(r.Context(), aoId, s_u.Id,
+		r.PostFormValue("justification"), r.PostFormValue("title"), r.PostFormValue("body"))
+	if err != nil {
+		if acceptAppealUserError(err) {
+			report(w, s_u, "你好，"+err.Error()+"。")
+			return
+		}
+		util.DebugContext(r.Context(), "cannot file accept appeal", aoId, err)
+		report(w, s_u, "你好，申诉未能提交："+err.Error()+"。")
+		return
+	}
+	if !appeal.ByOffice() {
+		mess := dao.AcceptNotification{
+			FromUserId:     dao.UserId_Captain_Spaceship,
+			Title:          dao.AcceptInvitationTitle,
+			Content:        dao.AcceptInvitationContent,
+			AcceptObjectId: appeal.ReviewAcceptObjectId,
+		}
+		if err = TwoAcceptNotificationsSendExceptUserId(s_u.Id, mess, r.Context()); err != nil {
+			util.DebugContext(r.Context(), "cannot assign accept appeal reviewers", appeal.Id, err)
+			// 没能指派评审官（如此前各轮的评审官回避后已无人选）的，转由茶博士复审；
+			// 已指派的评审官即使没收到邀请，也能在“我的评审记录”看到待答复的评审
+			assigned, err := dao.AcceptAssignmentsByObject(r.Context(), appeal.ReviewAcceptObjectId)
+			if err == nil && len(assigned) == 0 {
+				err = dao.TransferAcceptAppealToOffice(r.Context(), appeal.Id)
+			}
+			if err != nil {
+				util.DebugContext(r.Context(), "cannot transfer accept appeal to office", appeal.Id, err)
+			}
+		}
+	}
+	util.InfoContext(r.Context(), " accept appeal filed", appeal.Id, appeal.AcceptObjectId, s_u.Id)
+	http.Redirect(w, r, "/v1/office/appeal?uuid="+appeal.Uuid, http.StatusFound)
+}
+
+// AcceptAppealDetail GET /v1/office/appeal?uuid= 申诉详情及结论链
+func AcceptAppealDetail(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	appeal, err := dao.GetAcceptAppealByUuid(r.Context(), r.URL.Query().Get("uuid"))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			util.DebugContext(r.Context(), "cannot get accept appeal", err)
+		}
+		report(w, s_u, "你好，茶博士找不到这份申诉。")
+		return
+	}
+	operator := isTeaOperator(s_u)
+	if appeal.AuthorUserId != s_u.Id && !operator {
+		report(w, s_u, "你好，申诉只有作者本人和当值的茶博士可以查看。")
+		return
+	}
+	verdicts, err := dao.AcceptVerdictChain(r.Context(), appeal.ObjectType, appeal.ObjectId)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get accept verdict chain", appeal.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取历次评审结论，请稍后再试。")
+		return
+	}
+	pageData := dao.AcceptAppealPageData{
+		SessUser:   s_u,
+		Appeal:     appeal,
+		Verdicts:   verdicts,
+		IsOperator: operator,
+		CanDecide:  operator && appeal.IsPending() && appeal.ByOffice() && appeal.AuthorUserId != s_u.Id,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "accept.appeal_detail", "component_accept_verdicts")
+}
+
+// HandleAcceptAppeals GET /v1/admin/accept/appeals 待茶博士复审及最近的申诉
+func HandleAcceptAppeals(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	pending, err := dao.PendingOfficeAcceptAppeals(r.Context())
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get pending office accept appeals", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取待复审的申诉，请稍后再试。")
+		return
+	}
+	recent, err := dao.RecentAcceptAppeals(r.Context(), acceptAppealsPageLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get recent accept appeals", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取最近的申诉，请稍后再试。")
+		return
+	}
+	pageData := dao.AcceptAppealsPageData{SessUser: s_u, Pending: pending, Recent: recent}
+	generateHTML(w, &pageData, "layout", "navbar.private", "admin.accept_appeals")
+}
+
+// DecideAcceptAppeal POST /v1/admin/accept/appeal/decide 茶博士复审申诉
+func DecideAcceptAppeal(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	appeal, err := dao.GetAcceptAppealByUuid(r.Context(), r.PostFormValue("uuid"))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			util.DebugContext(r.Context(), "cannot get accept appeal", err)
+		}
+		report(w, s_u, "你好，茶博士找不到这份申诉。")
+		return
+	}
+	var accepted bool
+	switch r.PostFormValue("verdict") {
+	case "accept":
+		accepted = true
+	case "reject":
+	default:
+		report(w, s_u, "你好，请选择复审结论：接纳或维持婉拒。")
+		return
+	}
+	note := r.PostFormValue("note")
+	switch {
+	case !appeal.IsPending():
+		report(w, s_u, "你好，"+dao.ErrAcceptAppealNotPending.Error()+"。")
+		return
+	case !appeal.ByOffice():
+		report(w, s_u, "你好，这份申诉由友邻评审官复审，茶博士不必插手。")
+		return
+	case appeal.AuthorUserId == s_u.Id:
+		report(w, s_u, "你好，不能复审自己提出的申诉。")
+		return
+	case !accepted && note == "":
+		report(w, s_u, "你好，维持婉拒须向作者说明理由。")
+		return
+	}
+	ao := dao.AcceptObject{Id: appeal.ReviewAcceptObjectId}
+	if err = ao.Get(); err != nil {
+		util.DebugContext(r.Context(), "cannot get accept object", appeal.ReviewAcceptObjectId, err)
+		report(w, s_u, "你好，茶博士都糊涂了，竟然唱问世间情为何物，直教人找不到对象？")
+		return
+	}
+	// 先认领申诉记下结论，认领成功才发布或退回对象，避免并发复审重复发布
+	claimed, err := dao.ClaimAcceptAppeal(r.Context(), ao.Id, accepted, s_u.Id, note)
+	if err != nil {
+		if errors.Is(err, dao.ErrAcceptAppealNotPending) || errors.Is(err, sql.ErrNoRows) {
+			report(w, s_u, "你好，"+dao.ErrAcceptAppealNotPending.Error()+"。")
+			return
+		}
+		util.ErrorContext(r.Context(), "cannot claim accept appeal", appeal.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能记下复审结论，请稍后再试。")
+		return
+	}
+	if err = resolveClaimedAcceptAppeal(r.Context(), claimed, ao, accepted); err != nil {
+		report(w, s_u, err.Error())
+		return
+	}
+	util.InfoContext(r.Context(), " accept appeal decided", appeal.Id, accepted, s_u.Id)
+	http.Redirect(w, r, "/v1/office/appeal?uuid="+appeal.Uuid, http.StatusFound)
+}
@@ -0,0 +1,65 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+友邻蒙评评审官指派记录，茶博士/船长使用：
+GET /v1/admin/accept/assignments 最近的指派（首次指派、超时改派）及答复情况，超时未答复的由后台任务 accept_review_reassign 改派。
+*/
+
+const acceptAssignmentsPageLimit = 100
+
+// HandleAcceptAssignments GET /v1/admin/accept/assignments 评审官指派记录
+func HandleAcceptAssignments(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	assignments, err := dao.RecentAcceptAssignments(r.Context(), acceptAssignmentsPageLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get recent accept assignments", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取评审官指派记录，请稍后再试。")
+		return
+	}
+	pageData := dao.AcceptAssignmentsPageData{SessUser: s_u, Assignments: assignments, TimeoutHours: util.Config.AcceptReviewTimeoutHours, Now: time.Now()}
+	generateHTML(w, &pageData, "layout", "navbar.private", "admin.accept_assignments")
+}
+
+// ReassignOverdueAcceptReviews 后台任务 accept_review_reassign：超时未答复的指派标记为超时，
+// 另一位已答复且为可信评审官的由其独自裁定并按结论处理蒙评对象，其余缺额改派他人
+func ReassignOverdueAcceptReviews(ctx context.Context, now time.Time) (summary dao.AcceptReassignSummary, err error) {
+	if summary.TimedOut, err = dao.TimeOutOverdueAcceptAssignments(ctx, now); err != nil {
+		return summary, err
+	}
+	solos, err := dao.TrustedSoloAcceptDecisions(ctx)
+	if err != nil {
+		return summary, err
+	}
+	for _, d := range solos {
+		if err = dao.DecideAcceptReviewAlone(ctx, d); err != nil {
+			if !errors.Is(err, dao.ErrAcceptReviewDecided) {
+				util.WarningContext(ctx, fmt.Sprintf("可信评审官 %d 独自裁定蒙评对象 %d 失败: %v", d.ReviewerUserId, d.AcceptObject.Id, err))
+			}
+			summary.Failed++
+			continue
+		}
+		if err = settleAcceptVerdict(ctx, d.AcceptObject, d.Accept); err != nil {
+			util.WarningContext(ctx, fmt.Sprintf("处理可信评审官独自裁定的蒙评对象 %d 失败: %v", d.AcceptObject.Id, err))
+			summary.Failed++
+			continue
+		}
+		util.InfoContext(ctx, fmt.Sprintf("蒙评对象 %d 由可信评审官 %d 独自裁定：接纳 %t", d.AcceptObject.Id, d.ReviewerUserId, d.Accept))
+		summary.DecidedAlone++
+	}
+	err = dao.ReassignShortAcceptReviews(ctx, now, &summary)
+	return summary, err
+}
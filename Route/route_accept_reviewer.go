@@ -0,0 +1,124 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+友邻蒙评评审官信誉：
+1、GET /v1/office/reviews 本人的评审统计、信誉分、待答复的评审及评审记录；
+2、茶博士/船长：GET /v1/admin/accept/reviewers 评审官统计及最近得出结论的蒙评对象，
+   POST /v1/admin/accept/check 抽查复核，字段 id（蒙评对象）、correct（accept|reject）、note（推翻时必填），
+   复核只计入评审官信誉，不改变蒙评对象本身。
+*/
+
+const (
+	myAcceptReviewsPageLimit = 100
+	acceptReviewersPageLimit = 100
+	acceptVerdictsCheckLimit = 50
+)
+
+// HandleMyAcceptReviews GET /v1/office/reviews 本人的评审记录
+func HandleMyAcceptReviews(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	stats, err := dao.GetAcceptReviewerStats(r.Context(), s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get accept reviewer stats", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取你的评审统计，请稍后再试。")
+		return
+	}
+	open, err := dao.OpenAcceptAssignmentsByReviewer(r.Context(), s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get open accept assignments", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取待答复的评审，请稍后再试。")
+		return
+	}
+	votes, err := dao.AcceptReviewVotesByReviewer(r.Context(), s_u.Id, myAcceptReviewsPageLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get accept review votes", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取你的评审记录，请稍后再试。")
+		return
+	}
+	pageData := dao.MyAcceptReviewsPageData{
+		SessUser:   s_u,
+		Stats:      stats,
+		Open:       open,
+		Votes:      votes,
+		MinReviews: util.Config.AcceptTrustedReviewerMinReviews,
+		MinScore:   util.Config.AcceptTrustedReviewerMinScore,
+		Now:        time.Now(),
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "accept.my_reviews")
+}
+
+// HandleAcceptReviewers GET /v1/admin/accept/reviewers 评审官统计及待抽查的蒙评结论
+func HandleAcceptReviewers(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	reviewers, err := dao.TopAcceptReviewerStats(r.Context(), acceptReviewersPageLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get accept reviewer stats", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取评审官统计，请稍后再试。")
+		return
+	}
+	verdicts, err := dao.RecentAcceptVerdicts(r.Context(), acceptVerdictsCheckLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get recent accept verdicts", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取蒙评结论，请稍后再试。")
+		return
+	}
+	pageData := dao.AcceptReviewersPageData{
+		SessUser:   s_u,
+		Reviewers:  reviewers,
+		Verdicts:   verdicts,
+		LowScore:   util.Config.AcceptReviewerLowScore,
+		MinReviews: util.Config.AcceptTrustedReviewerMinReviews,
+		MinScore:   util.Config.AcceptTrustedReviewerMinScore,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "admin.accept_reviewers")
+}
+
+// CheckAcceptReview POST /v1/admin/accept/check 抽查复核友邻蒙评结论
+func CheckAcceptReview(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	aoId, err := strconv.Atoi(r.PostFormValue("id"))
+	if err != nil || aoId <= 0 {
+		report(w, s_u, "你好，缺少蒙评对象编号，茶博士找不到茶叶的资料。")
+		return
+	}
+	var correct bool
+	switch r.PostFormValue("correct") {
+	case "accept":
+		correct = true
+	case "reject":
+	default:
+		report(w, s_u, "你好，请选择复核认定的结论：接纳或婉拒。")
+		return
+	}
+	check, err := dao.RecordAcceptReviewCheck(r.Context(), aoId, s_u.Id, dao.AcceptReviewCheckSource_SpotCheck, correct, r.PostFormValue("note"))
+	if err != nil {
+		if !errors.Is(err, dao.ErrAcceptReviewNotDecided) && !errors.Is(err, dao.ErrAcceptReviewCheckSelf) {
+			util.DebugContext(r.Context(), "cannot record accept review check", aoId, err)
+		}
+		report(w, s_u, "你好，复核未能记录："+err.Error()+"。")
+		return
+	}
+	util.InfoContext(r.Context(), " accept review checked", aoId, check.IsOverturned(), s_u.Id)
+	http.Redirect(w, r, "/v1/admin/accept/reviewers", http.StatusFound)
+}
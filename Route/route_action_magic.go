@@ -306,7 +306,7 @@ func MagicListGet(w http.ResponseWriter, r *http.Request) {
 	magicData.SessUser = s_u
 	magicData.Magics = magicBeans
 
-	generateHTML(w, &magicData, "layout", "navbar.private", "magic.list")
+	generateHTML(w, &magicData, "layout", "navbar.private", "magic.list", "component_magic_bean")
 }
 
 // Handler /v1/magics/user_list
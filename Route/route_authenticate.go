@@ -1,8 +1,8 @@
 package route
 
 import (
-	"crypto/subtle"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -72,7 +72,7 @@ func SignupPost(w http.ResponseWriter, r *http.Request) {
 	newU := dao.User{
 		Name:      name,
 		Email:     email,
-		Password:  dao.Encrypt(password),
+		Password:  password,
 		Biography: biography,
 		Role:      "traveller",
 		Gender:    gender,
@@ -183,26 +183,26 @@ func Authenticate(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		encryptedPw := dao.Encrypt(pw)
-		if subtle.ConstantTimeCompare([]byte(s_u.Password), []byte(encryptedPw)) == 1 {
+		ok, needsRehash, err := dao.VerifyPassword(s_u.Password, pw)
+		if err != nil {
+			util.Debug(s_u.Email, "密码摘要格式无法识别", err)
+		}
+		if ok {
+			// 旧算法或旧参数生成的摘要，登船成功时顺手升级
+			if needsRehash {
+				if err = s_u.UpdatePassword(pw); err != nil {
+					util.Debug(" Cannot upgrade password hash", s_u.Id, err)
+				}
+			}
 			// 创建新的会话
-			session, err := s_u.CreateSession()
+			session, err := s_u.CreateSession(r.UserAgent(), clientIP(r))
 			if err != nil {
 				util.Debug(" Cannot create session", err)
 				report(w, s_u, "你好，茶博士因找不到笔导致登船验证失败，请确认情况后重试。")
 				return
 			}
-			// 设置cookie
-			cookie := http.Cookie{
-				Name:     "_cookie",
-				Value:    session.Uuid,
-				HttpOnly: true,
-				MaxAge:   60 * 60 * 24 * 7, // 7 days
-				//Secure:   true, // 生产环境应启用HTTPS
-				SameSite: http.SameSiteLaxMode,
-			}
-
-			http.SetCookie(w, &cookie)
+			// 设置cookie，服务端按闲置时间滑动续期，cookie 保留到会话最长有效期
+			http.SetCookie(w, sessionCookie(session.Uuid, int(dao.SessionMaxLifetime().Seconds())))
 
 			// 安全重定向⚠️本站
 			footprint := sanitizeRedirectPath(r.FormValue("footprint"))
@@ -288,13 +288,38 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 
 // clearSessionCookie 清除客户端会话Cookie
 func clearSessionCookie(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
+	http.SetCookie(w, sessionCookie("", -1)) // 立即过期
+}
+
+// sessionCookie 会话Cookie，Secure/SameSite 取自配置
+func sessionCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
 		Name:     "_cookie",
-		Value:    "",
+		Value:    value,
 		Path:     "/",
-		MaxAge:   -1,   // 立即过期
+		MaxAge:   maxAge,
 		HttpOnly: true, // 防止XSS
-		//Secure:   true, // 生产环境应启用HTTPS
-		SameSite: http.SameSiteLaxMode,
-	})
+		Secure:   util.Config.CookieSecure,
+		SameSite: cookieSameSite(util.Config.CookieSameSite),
+	}
+}
+
+func cookieSameSite(mode string) http.SameSite {
+	switch mode {
+	case "Strict":
+		return http.SameSiteStrictMode
+	case "None":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// clientIP 请求来源IP（不信任可伪造的转发头）
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
@@ -1,8 +1,505 @@
 package route
 
-import "net/http"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
 
 // 喝茶的公道杯，寓意解决某些争议功能
+// 处理茶订单预备金托管争议：发起、答辩、撤销、见证者仲裁
 func FairnessMug(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		FairnessMugGet(w, r)
+	case http.MethodPost:
+		FairnessMugPost(w, r)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /v1/balance/fairnessmug?uuid=xxx 查看争议详情
+// GET /v1/balance/fairnessmug?deposit_uuid=xxx 对某笔托管预备金发起争议的表单页面
+func FairnessMugGet(w http.ResponseWriter, r *http.Request) {
+	sess, err := session(r)
+	if err != nil {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	s_u, err := sess.User()
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get user from session", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+
+	if uuid := r.URL.Query().Get("uuid"); uuid != "" {
+		fairnessMugDetail(w, r, s_u, uuid)
+		return
+	}
+
+	depositUuid := r.URL.Query().Get("deposit_uuid")
+	if depositUuid == "" {
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到指定的托管记录。")
+		return
+	}
+	deposit, err := dao.GetTeaOrderDepositByUuid(depositUuid)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get tea order deposit", depositUuid, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到指定的托管记录。请确认后再试。")
+		return
+	}
+	if deposit.Status != dao.DepositStatusPaid || deposit.HasDispute {
+		report(w, s_u, "你好，该托管记录当前状态不允许发起争议。")
+		return
+	}
+
+	initiatorTeamId, respondentTeamId, err := fairnessMugPartiesForUser(deposit, s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot check dispute parties", deposit.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能确认争议双方团队。请稍后再试。")
+		return
+	}
+	if initiatorTeamId == dao.TeamIdNone {
+		report(w, s_u, "你好，只有托管双方团队的核心成员才能发起争议。")
+		return
+	}
+
+	teaOrder := &dao.TeaOrder{Id: deposit.TeaOrderId}
+	if err = teaOrder.GetByIdOrUUID(r.Context()); err != nil {
+		util.DebugContext(r.Context(), "Cannot get tea order", deposit.TeaOrderId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到托管记录所属的茶订单。")
+		return
+	}
+	initiatorTeam, err := dao.GetTeam(initiatorTeamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get initiator team", initiatorTeamId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能获取发起方团队资料。")
+		return
+	}
+	respondentTeam, err := dao.GetTeam(respondentTeamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get respondent team", respondentTeamId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能获取被诉方团队资料。")
+		return
+	}
+
+	pageData := dao.FairnessMugPageData{
+		SessUser:       s_u,
+		TeaOrder:       teaOrder,
+		Deposit:        deposit,
+		InitiatorTeam:  initiatorTeam,
+		RespondentTeam: respondentTeam,
+		IsInitiator:    true,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "balance.fairnessmug.new", "component_sess_capacity")
+}
+
+// POST /v1/balance/fairnessmug
+// 托管双方团队的核心成员对某笔已托管预备金发起争议
+func FairnessMugPost(w http.ResponseWriter, r *http.Request) {
+	sess, err := session(r)
+	if err != nil {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	s_u, err := sess.User()
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get user from session", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		util.DebugContext(r.Context(), "Cannot parse form", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+
+	depositUuid := r.PostFormValue("deposit_uuid")
+	reason := r.PostFormValue("reason")
+	if depositUuid == "" {
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到指定的托管记录。")
+		return
+	}
+	if cnStrLen(reason) < 7 || cnStrLen(reason) > 456 {
+		report(w, s_u, "你好，请填写争议原因（7-456字），以便见证者了解情况。")
+		return
+	}
+
+	deposit, err := dao.GetTeaOrderDepositByUuid(depositUuid)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get tea order deposit", depositUuid, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到指定的托管记录。请确认后再试。")
+		return
+	}
+	initiatorTeamId, respondentTeamId, err := fairnessMugPartiesForUser(deposit, s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot check dispute parties", deposit.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能确认争议双方团队。请稍后再试。")
+		return
+	}
+	if initiatorTeamId == dao.TeamIdNone {
+		report(w, s_u, "你好，只有托管双方团队的核心成员才能发起争议。")
+		return
+	}
+
+	dispute := &dao.TeaOrderDepositDispute{
+		DepositId:        deposit.Id,
+		InitiatorTeamId:  initiatorTeamId,
+		RespondentTeamId: respondentTeamId,
+		InitiatorUserId:  s_u.Id,
+		Reason:           reason,
+	}
+	witness := fairnessMugWitness(deposit.TeaOrderId, dao.WitnessActionDispute, s_u.Id,
+		fmt.Sprintf("托管预备金(id=%d)发生争议，争议原因：%s", deposit.Id, reason))
+	if err = dispute.Create(r.Context(), witness); err != nil {
+		util.DebugContext(r.Context(), "Cannot create tea order deposit dispute", deposit.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能登记争议，该托管记录可能已在争议中或已结算。")
+		return
+	}
+
+	http.Redirect(w, r, "/v1/balance/fairnessmug?uuid="+dispute.Uuid, http.StatusFound)
+}
+
+// POST /v1/balance/fairnessmug/respond
+// 被诉方团队核心成员提交答辩
+func FairnessMugRespond(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, err := session(r)
+	if err != nil {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	s_u, err := sess.User()
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get user from session", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		util.DebugContext(r.Context(), "Cannot parse form", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+
+	response := r.PostFormValue("response")
+	if cnStrLen(response) < 7 || cnStrLen(response) > 456 {
+		report(w, s_u, "你好，请填写答辩内容（7-456字）。")
+		return
+	}
+	dispute, deposit, ok := fairnessMugLoadDispute(w, r, s_u, r.PostFormValue("uuid"))
+	if !ok {
+		return
+	}
+
+	respondentTeam := dao.Team{Id: dispute.RespondentTeamId}
+	isCore, err := respondentTeam.IsCoreMember(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot check respondent core member", dispute.RespondentTeamId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能确认您的团队身份。请稍后再试。")
+		return
+	}
+	if !isCore {
+		report(w, s_u, "你好，只有被诉方团队的核心成员才能提交答辩。")
+		return
+	}
+
+	witness := fairnessMugWitness(deposit.TeaOrderId, dao.WitnessActionRespond, s_u.Id,
+		fmt.Sprintf("托管预备金(id=%d)争议被诉方答辩：%s", deposit.Id, response))
+	if err = dispute.Respond(r.Context(), s_u.Id, response, witness); err != nil {
+		util.DebugContext(r.Context(), "Cannot respond dispute", dispute.Id, err)
+		report(w, s_u, fmt.Sprintf("你好，茶博士失魂鱼，未能提交答辩：%v", err))
+		return
+	}
+
+	http.Redirect(w, r, "/v1/balance/fairnessmug?uuid="+dispute.Uuid, http.StatusFound)
+}
+
+// POST /v1/balance/fairnessmug/withdraw
+// 发起方团队核心成员在仲裁前撤销争议，托管记录恢复为已托管状态
+func FairnessMugWithdraw(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, err := session(r)
+	if err != nil {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	s_u, err := sess.User()
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get user from session", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		util.DebugContext(r.Context(), "Cannot parse form", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+	dispute, deposit, ok := fairnessMugLoadDispute(w, r, s_u, r.PostFormValue("uuid"))
+	if !ok {
+		return
+	}
+
+	initiatorTeam := dao.Team{Id: dispute.InitiatorTeamId}
+	isCore, err := initiatorTeam.IsCoreMember(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot check initiator core member", dispute.InitiatorTeamId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能确认您的团队身份。请稍后再试。")
+		return
+	}
+	if !isCore {
+		report(w, s_u, "你好，只有发起方团队的核心成员才能撤销争议。")
+		return
+	}
+
+	witness := fairnessMugWitness(deposit.TeaOrderId, dao.WitnessActionWithdraw, s_u.Id,
+		fmt.Sprintf("托管预备金(id=%d)争议已由发起方撤销，恢复托管", deposit.Id))
+	if err = dispute.Withdraw(r.Context(), witness); err != nil {
+		util.DebugContext(r.Context(), "Cannot withdraw dispute", dispute.Id, err)
+		report(w, s_u, fmt.Sprintf("你好，茶博士失魂鱼，未能撤销争议：%v", err))
+		return
+	}
+
+	http.Redirect(w, r, "/v1/balance/fairnessmug?uuid="+dispute.Uuid, http.StatusFound)
+}
+
+// POST /v1/balance/fairnessmug/arbitrate
+// 见证者仲裁争议，托管星茶自动释放给胜出方
+func FairnessMugArbitrate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, err := session(r)
+	if err != nil {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	s_u, err := sess.User()
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get user from session", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+	if !dao.IsVerifier(s_u.Id) {
+		report(w, s_u, "你好，您没有权限执行仲裁操作。")
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		util.DebugContext(r.Context(), "Cannot parse form", err)
+		report(w, s_u, "你好，世人都晓神仙好，只有金银忘不了！请稍后再试。")
+		return
+	}
+
+	resultInt, err := strconv.Atoi(r.PostFormValue("result"))
+	if err != nil {
+		report(w, s_u, "你好，请选择仲裁结果。")
+		return
+	}
+	result := dao.DisputeResult(resultInt)
+	if result != dao.DisputeResultPayeeWin && result != dao.DisputeResultPayerWin {
+		report(w, s_u, "你好，请选择仲裁结果。")
+		return
+	}
+	notes := r.PostFormValue("notes")
+	if cnStrLen(notes) < 7 || cnStrLen(notes) > 456 {
+		report(w, s_u, "你好，请填写仲裁说明（7-456字），这将作为仲裁的正式记录。")
+		return
+	}
+
+	dispute, deposit, ok := fairnessMugLoadDispute(w, r, s_u, r.PostFormValue("uuid"))
+	if !ok {
+		return
+	}
+
+	arbitratorTeamId, notice, err := fairnessMugArbitratorCheck(r, s_u.Id, deposit)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot check arbitrator", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能确认您的仲裁资格。请稍后再试。")
+		return
+	}
+	if notice != "" {
+		report(w, s_u, notice)
+		return
+	}
+
+	// 见证日志与仲裁结算同一事务写入，先按所选结果确定胜出方
+	verdict := *dispute
+	verdict.Result = result
+	winnerTeamId := verdict.WinnerTeamId(deposit)
+	winnerTeam, err := dao.GetTeam(winnerTeamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get winner team", winnerTeamId, err)
+	}
+	witness := fairnessMugWitness(deposit.TeaOrderId, dao.WitnessActionArbitrate, s_u.Id,
+		fmt.Sprintf("托管预备金(id=%d)争议仲裁：%s，%.3f克星茶已释放给 %s。仲裁说明：%s",
+			deposit.Id, verdict.ResultString(), deposit.AmountGrams(), winnerTeam.Name, notes))
+	if err = dispute.Arbitrate(r.Context(), arbitratorTeamId, s_u.Id, result, notes, witness); err != nil {
+		util.DebugContext(r.Context(), "Cannot arbitrate dispute", dispute.Id, err)
+		report(w, s_u, fmt.Sprintf("你好，茶博士失魂鱼，未能完成仲裁：%v", err))
+		return
+	}
+
+	http.Redirect(w, r, "/v1/balance/fairnessmug?uuid="+dispute.Uuid, http.StatusFound)
+}
+
+// fairnessMugDetail 渲染争议详情页面，按当前用户身份显示答辩、撤销或仲裁表单
+func fairnessMugDetail(w http.ResponseWriter, r *http.Request, s_u dao.User, uuid string) {
+	dispute, deposit, ok := fairnessMugLoadDispute(w, r, s_u, uuid)
+	if !ok {
+		return
+	}
+
+	initiatorTeam, err := dao.GetTeam(dispute.InitiatorTeamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get initiator team", dispute.InitiatorTeamId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能获取发起方团队资料。")
+		return
+	}
+	respondentTeam, err := dao.GetTeam(dispute.RespondentTeamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get respondent team", dispute.RespondentTeamId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能获取被诉方团队资料。")
+		return
+	}
+	isInitiator, err := initiatorTeam.IsActiveMember(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot check initiator team member", initiatorTeam.Id, err)
+	}
+	isRespondent, err := respondentTeam.IsActiveMember(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot check respondent team member", respondentTeam.Id, err)
+	}
+	isVerifier := dao.IsVerifier(s_u.Id)
+	if !isInitiator && !isRespondent && !isVerifier {
+		report(w, s_u, "你好，您没有权限查看该争议。")
+		return
+	}
+
+	pageData := dao.FairnessMugPageData{
+		SessUser:       s_u,
+		Deposit:        deposit,
+		Dispute:        dispute,
+		InitiatorTeam:  initiatorTeam,
+		RespondentTeam: respondentTeam,
+	}
+	// 表单操作仅对核心成员开放
+	if isInitiator {
+		pageData.IsInitiator, _ = initiatorTeam.IsCoreMember(s_u.Id)
+	}
+	if isRespondent {
+		pageData.IsRespondent, _ = respondentTeam.IsCoreMember(s_u.Id)
+	}
+	if isVerifier && dispute.IsPending() {
+		_, notice, err := fairnessMugArbitratorCheck(r, s_u.Id, deposit)
+		if err != nil {
+			util.DebugContext(r.Context(), "Cannot check arbitrator", s_u.Id, err)
+			notice = "茶博士未能确认您的仲裁资格，请稍后再试。"
+		}
+		pageData.CanArbitrate = notice == ""
+		pageData.ConflictNotice = notice
+	}
+
+	teaOrder := &dao.TeaOrder{Id: deposit.TeaOrderId}
+	if err = teaOrder.GetByIdOrUUID(r.Context()); err != nil {
+		util.DebugContext(r.Context(), "Cannot get tea order", deposit.TeaOrderId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到托管记录所属的茶订单。")
+		return
+	}
+	pageData.TeaOrder = teaOrder
+
+	generateHTML(w, &pageData, "layout", "navbar.private", "balance.fairnessmug.detail", "component_sess_capacity")
+}
+
+// fairnessMugLoadDispute 根据UUID读取争议及其托管记录，失败时已向用户报告
+func fairnessMugLoadDispute(w http.ResponseWriter, r *http.Request, s_u dao.User, uuid string) (*dao.TeaOrderDepositDispute, *dao.TeaOrderDeposit, bool) {
+	if uuid == "" {
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到指定的争议记录。")
+		return nil, nil, false
+	}
+	dispute, err := dao.GetTeaOrderDepositDisputeByUuid(r.Context(), uuid)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			util.DebugContext(r.Context(), "Cannot get dispute", uuid, err)
+		}
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到指定的争议记录。请确认后再试。")
+		return nil, nil, false
+	}
+	deposit, err := dao.GetTeaOrderDepositById(dispute.DepositId)
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get tea order deposit", dispute.DepositId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到争议关联的托管记录。")
+		return nil, nil, false
+	}
+	return dispute, deposit, true
+}
+
+// fairnessMugPartiesForUser 根据当前用户所属团队，确定其代表的发起方团队及对应的被诉方团队
+// 用户必须是托管记录支付方或接收方团队的核心成员，否则返回 TeamIdNone
+func fairnessMugPartiesForUser(deposit *dao.TeaOrderDeposit, userId int) (initiatorTeamId, respondentTeamId int, err error) {
+	payerTeam := dao.Team{Id: deposit.PayerTeamId}
+	isCore, err := payerTeam.IsCoreMember(userId)
+	if err != nil {
+		return
+	}
+	if isCore {
+		return deposit.PayerTeamId, deposit.PayeeTeamId, nil
+	}
+	payeeTeam := dao.Team{Id: deposit.PayeeTeamId}
+	isCore, err = payeeTeam.IsCoreMember(userId)
+	if err != nil {
+		return
+	}
+	if isCore {
+		return deposit.PayeeTeamId, deposit.PayerTeamId, nil
+	}
+	return dao.TeamIdNone, dao.TeamIdNone, nil
+}
+
+// fairnessMugArbitratorCheck 检查见证者的仲裁资格，返回其代表的见证团队ID；
+// 不具备资格时返回给用户的提示 notice 非空
+func fairnessMugArbitratorCheck(r *http.Request, userId int, deposit *dao.TeaOrderDeposit) (arbitratorTeamId int, notice string, err error) {
+	arbitratorTeamId, err = dao.GetVerifierTeamIdByUserId(userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "你好，您不属于任何见证团队，无法仲裁。", nil
+		}
+		return
+	}
+	if arbitratorTeamId == deposit.PayerTeamId || arbitratorTeamId == deposit.PayeeTeamId {
+		return 0, "你好，您所在的见证团队是争议一方，需要回避，请由其他见证团队仲裁。", nil
+	}
+	avoid, err := dao.CheckArbitratorConflict(r.Context(), userId, deposit.PayerTeamId, deposit.PayeeTeamId)
+	if err != nil {
+		return
+	}
+	if avoid {
+		return 0, "你好，您与争议团队成员存在需要利益回避的关系，请由其他见证者仲裁。", nil
+	}
+	return arbitratorTeamId, "", nil
+}
 
+// fairnessMugWitness 公道杯流程的见证日志，交给争议操作在同一事务中写入
+func fairnessMugWitness(teaOrderId int, action string, userId int, reason string) *dao.WitnessLog {
+	return &dao.WitnessLog{
+		Uuid:       dao.Random_UUID(),
+		TeaOrderId: teaOrderId,
+		Action:     action,
+		Reason:     reason,
+		WitnessId:  userId,
+		EvidenceId: 0,
+		WitnessAt:  time.Now(),
+	}
 }
@@ -0,0 +1,208 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+	dao "teachat/DAO"
+	util "teachat/Util"
+)
+
+/*
+   防跨站请求伪造（CSRF）：同步令牌模式。
+   每条会话（dao.Session）登船时生成一个随机令牌；CSRFProtect 包裹整个路由器，
+   对已登船茶友的 POST/PUT/PATCH/DELETE 请求，要求表单字段 csrf_token 或请求头 X-CSRF-Token 与会话令牌一致。
+   generateHTML 渲染页面时自动在每个 POST 表单中插入隐藏字段，并在 <head> 中写入 csrf-token 元标签，
+   供 /v1/static/js/csrf.js 给页面脚本发起的 fetch / jQuery 请求附加请求头。
+   未登船的请求（登船、注册、找回及重设密码等）采用双重提交：首次访问时下发随机令牌 Cookie（_csrf），
+   页面表单同样注入该令牌，提交时表单字段或请求头须与 Cookie 一致，防止第三方页面替茶友登入别人的账号。
+   为读取令牌而解析的 multipart 表单，处理器返回后删除其临时文件。
+*/
+
+const (
+	csrfFieldName  = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+	csrfCookieName = "_csrf"
+
+	// 读取表单令牌时请求体的上限：普通表单1MB；带文件的表单按最大的上传（证据文件10MB）留出余量，
+	// 超出的部分写入临时文件而不是占用内存
+	csrfMaxFormBytes      = 1 << 20
+	csrfMaxMultipartBytes = 11 << 20
+	csrfMultipartMemory   = 1 << 20
+)
+
+// CSRFProtect 校验状态变更请求的CSRF令牌，并把本次请求的会话及令牌传递给后续处理器
+func CSRFProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer func() {
+			// r 可能是 WithContext 的副本，服务器只清理原请求的表单，这里自行删除解析时写下的临时文件
+			if r.MultipartForm != nil {
+				r.MultipartForm.RemoveAll()
+			}
+		}()
+		sess, err := session(r)
+		if err != nil {
+			preSessionCsrf(next, w, r)
+			return
+		}
+		if err = sess.EnsureCsrfToken(); err != nil {
+			util.ErrorContext(r.Context(), " Cannot issue csrf token", sess.Uuid, err)
+			report(w, dao.UserUnknown, "你好，茶博士失魂鱼，未能读取会话资料，请稍后再试。")
+			return
+		}
+		r = withLogUser(r.WithContext(context.WithValue(r.Context(), sessionContextKey, sess)), sess.UserId)
+
+		if !isSafeMethod(r.Method) && !validCsrfToken(w, r, sess.CsrfToken) {
+			s_u, err := sess.User()
+			if err != nil {
+				s_u = dao.UserUnknown
+			}
+			util.WarningContext(r.Context(), " csrf token mismatch", r.Method)
+			report(w, s_u, "你好，茶博士发现这份表单的来历不明，已经拒绝处理。请刷新页面后重新提交。")
+			return
+		}
+		next.ServeHTTP(&csrfResponseWriter{ResponseWriter: w, token: sess.CsrfToken}, r)
+	})
+}
+
+// preSessionCsrf 未登船请求的双重提交校验：令牌取自 _csrf Cookie，没有时下发一个新的；
+// POST 请求必须带回与请求中 Cookie 相同的令牌，刚下发的令牌不算数。
+// 跨站页面只能发出 GET/POST 表单，其他方法要先经过浏览器的跨域预检，未登船时交给路由按方法处理（如405）
+func preSessionCsrf(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	token, fromCookie := preSessionCsrfToken(r)
+	if !fromCookie {
+		if token == "" {
+			util.ErrorContext(r.Context(), " Cannot issue pre-session csrf token")
+			report(w, dao.UserUnknown, "你好，茶博士失魂鱼，未能准备表单，请稍后再试。")
+			return
+		}
+		http.SetCookie(w, csrfCookie(token))
+	}
+	if r.Method == http.MethodPost && (!fromCookie || !validCsrfToken(w, r, token)) {
+		util.WarningContext(r.Context(), " pre-session csrf token mismatch", r.Method)
+		report(w, dao.UserUnknown, "你好，茶博士发现这份表单的来历不明，已经拒绝处理。请刷新页面后重新提交。")
+		return
+	}
+	next.ServeHTTP(&csrfResponseWriter{ResponseWriter: w, token: token}, r)
+}
+
+var csrfCookieValue = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// preSessionCsrfToken 请求所带 _csrf Cookie 中的令牌；没有或格式不对时生成新令牌，fromCookie 为 false
+func preSessionCsrfToken(r *http.Request) (token string, fromCookie bool) {
+	if c, err := r.Cookie(csrfCookieName); err == nil && csrfCookieValue.MatchString(c.Value) {
+		return c.Value, true
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", false
+	}
+	return hex.EncodeToString(b), false
+}
+
+// csrfCookie 未登船时的令牌Cookie，Secure/SameSite 与会话Cookie一致
+func csrfCookie(token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   util.Config.CookieSecure,
+		SameSite: cookieSameSite(util.Config.CookieSameSite),
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// validCsrfToken 先看请求头；没有请求头时只从表单请求体中读取令牌字段，
+// 读取前限制请求体大小，避免在处理器之前就把超大请求体整个读进来
+func validCsrfToken(w http.ResponseWriter, r *http.Request, expected string) bool {
+	token := r.Header.Get(csrfHeaderName)
+	if token == "" {
+		token = csrfFormToken(w, r)
+	}
+	if token == "" || expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// csrfFormToken 表单请求体中的令牌字段，其他类型的请求体不读取
+func csrfFormToken(w http.ResponseWriter, r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		r.Body = http.MaxBytesReader(w, r.Body, csrfMaxFormBytes)
+		if err := r.ParseForm(); err != nil {
+			util.WarningContext(r.Context(), " csrf cannot parse form", err)
+			return ""
+		}
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		r.Body = http.MaxBytesReader(w, r.Body, csrfMaxMultipartBytes)
+		if err := r.ParseMultipartForm(csrfMultipartMemory); err != nil {
+			util.WarningContext(r.Context(), " csrf cannot parse multipart form", err)
+			return ""
+		}
+	default:
+		return ""
+	}
+	return r.PostForm.Get(csrfFieldName)
+}
+
+// csrfResponseWriter 携带本次请求会话的CSRF令牌，供 generateHTML 注入页面
+type csrfResponseWriter struct {
+	http.ResponseWriter
+	token string
+}
+
+func (w *csrfResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// csrfTokenFrom 从（可能被多层包裹的）ResponseWriter 中取出CSRF令牌，未经 CSRFProtect 时为空
+func csrfTokenFrom(w http.ResponseWriter) string {
+	for {
+		switch rw := w.(type) {
+		case *csrfResponseWriter:
+			return rw.token
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return ""
+		}
+	}
+}
+
+var postFormTag = regexp.MustCompile(`(?i)<form\b[^>]*\bmethod\s*=\s*["']?post["']?[^>]*>`)
+
+// injectCsrfToken 在页面的每个 POST 表单开头插入隐藏令牌字段，并在 </head> 前写入元标签
+func injectCsrfToken(page []byte, token string) []byte {
+	if token == "" {
+		return page
+	}
+	field := []byte(`<input type="hidden" name="` + csrfFieldName + `" value="` + token + `">`)
+	page = postFormTag.ReplaceAllFunc(page, func(tag []byte) []byte {
+		out := make([]byte, 0, len(tag)+len(field))
+		return append(append(out, tag...), field...)
+	})
+	meta := []byte(`<meta name="csrf-token" content="` + token + `">` + "\n</head>")
+	return bytes.Replace(page, []byte("</head>"), meta, 1)
+}
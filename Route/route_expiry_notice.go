@@ -0,0 +1,86 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	dao "teachat/DAO"
+	util "teachat/Util"
+)
+
+/*
+文书过期提醒：
+1、GET /v1/notification/expiry 茶友收到的邀请函、申请书、声明书过期提醒，查看即全部计为已读；
+2、POST /v1/notification/expiry/renew 发出方续期过期的文书，字段 uuid（提醒编号），
+   文书回到待处理状态，期限从续期时重新起算。
+过期由后台任务 document_expiry 处理，期限见配置 TeamInvitationExpireDays 等。
+*/
+
+// 过期提醒页面最多列出的条数
+const expiryNoticeListLimit = 100
+
+// expiryNoticeUserError 可以直接告诉茶友的续期错误
+func expiryNoticeUserError(err error) bool {
+	for _, e := range []error{dao.ErrExpiryNoticeNotFound, dao.ErrExpiryNoticeNotOwner, dao.ErrExpiryNoticeRenewed,
+		dao.ErrExpiryDocNotExpired, dao.ErrExpiryRenewForbidden, dao.ErrExpiryRenewJoined} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /v1/notification/expiry
+// 查看文书过期提醒
+func ExpiryNotices(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	notices, err := dao.ExpiryNoticesByUser(r.Context(), s_u.Id, expiryNoticeListLimit)
+	if err != nil {
+		util.ErrorContext(r.Context(), " cannot get expiry notices", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到过期提醒，请稍后再试。")
+		return
+	}
+	n, err := dao.MarkExpiryNoticesRead(r.Context(), s_u.Id)
+	if err != nil {
+		util.ErrorContext(r.Context(), " cannot mark expiry notices read", s_u.Id, err)
+	}
+	for i := 0; i < n; i++ {
+		if err = dao.SubtractUserNotificationCount(s_u.Id); err != nil {
+			util.DebugContext(r.Context(), s_u.Id, " cannot subtract notification count", err)
+			break
+		}
+	}
+
+	pageData := dao.ExpiryNoticesPageData{
+		SessUser: s_u,
+		Notices:  notices,
+	}
+	pageData.GroupInvitationUnreadCount, _ = dao.CountGroupInvitationsByUserIdAndStatus(s_u.Id, 0)
+	generateHTML(w, &pageData, "layout", "navbar.private", "expiry.notifications")
+}
+
+// POST /v1/notification/expiry/renew
+// 发出方续期过期的邀请函、申请书或声明书
+func ExpiryNoticeRenew(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	notice, err := dao.RenewExpiredDocument(r.Context(), uuid, s_u.Id)
+	if err != nil {
+		if expiryNoticeUserError(err) {
+			report(w, s_u, "你好，"+err.Error()+"。")
+			return
+		}
+		util.ErrorContext(r.Context(), " cannot renew expired document", uuid, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能续期这份文书，请稍后再试。")
+		return
+	}
+	util.InfoContext(r.Context(), " expired document renewed", notice.DocType, notice.DocUuid, s_u.Id)
+	http.Redirect(w, r, "/v1/notification/expiry", http.StatusFound)
+}
@@ -315,12 +315,14 @@ func FamilyMemberSignInRead(w http.ResponseWriter, r *http.Request) {
 		report(w, s_u, "读取声明书失误，请稍后再试一次。")
 		return
 	}
-	//更新声明书状态为已读
-	family_member_sign_in.Status = dao.SignInStatusRead
-	if err := family_member_sign_in.Update(); err != nil {
-		util.Debug(" Cannot update family_member_sign_in", err)
-		report(w, s_u, "更新声明书失误，请稍后再试一次。")
-		return
+	//未读的声明书更新为已读，已答复或已过期的保持原状态
+	if family_member_sign_in.Status == dao.SignInStatusUnread {
+		family_member_sign_in.Status = dao.SignInStatusRead
+		if err := family_member_sign_in.Update(); err != nil {
+			util.Debug(" Cannot update family_member_sign_in", err)
+			report(w, s_u, "更新声明书失误，请稍后再试一次。")
+			return
+		}
 	}
 
 	//填写页面数据
@@ -380,6 +382,10 @@ func FamilyMemberSignInReply(w http.ResponseWriter, r *http.Request) {
 		report(w, s_u, "你好，声明资料满天飞。各人自有各人家，请勿乱入别人家。")
 		return
 	}
+	if family_member_sign_in.Status == dao.SignInStatusExpired {
+		report(w, s_u, "你好，这份声明书已经过期，请联系声明人续期后再答复。")
+		return
+	}
 	// 检查声明书状态是否已读但未处理，status==1是已读未处理，其它值都是非法的值
 	if family_member_sign_in.Status != dao.SignInStatusRead {
 		report(w, s_u, "你好，柳丝榆荚自芳菲，声明资料满天飞。请稍后再试。")
@@ -681,8 +681,9 @@ func GroupDetailGet(w http.ResponseWriter, r *http.Request) {
 	}
 	pageData.TeamBeanSlice = teamBeans
 	pageData.IsOverTwelve = len(teamBeans) > 12
+	pageData.AcceptVerdicts = publishedAcceptVerdicts(r, dao.AcceptObjectTypeGroup, group.Id)
 
-	generateHTML(w, &pageData, "layout", "navbar.private", "group.detail", "component_team")
+	generateHTML(w, &pageData, "layout", "navbar.private", "group.detail", "component_team", "component_accept_verdicts")
 }
 
 // GET /v1/group/manage?id=xxx
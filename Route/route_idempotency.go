@@ -0,0 +1,183 @@
+package route
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+   幂等键中间件：包裹会产生副作用的接口（发起星茶转账），防止网络重试、重复点击造成重复提交。
+   客户端在请求头 Idempotency-Key（或查询参数、表单字段 idempotency_key）附带本次提交的键，重试时沿用同一个键：
+   1、首次请求照常处理，保存响应（服务端错误除外，可用同一键重试）；
+   2、保留期（IdempotencyKeyRetentionHours）内重放：参数相同返回首次响应，并带响应头 Idempotent-Replayed: true；
+      参数不同，或首次请求仍在处理中，返回409。
+   未附带键的请求、未登船的请求、非 POST 请求直接放行。
+*/
+
+const (
+	idempotencyHeaderName   = "Idempotency-Key"
+	idempotencyFieldName    = "idempotency_key"
+	idempotencyReplayHeader = "Idempotent-Replayed"
+	idempotencyMaxBodyBytes = 1 << 20
+)
+
+// Idempotent 按幂等键去重，须放在 CSRFProtect 之后（由全局中间件保证）
+func Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sess, err := session(r)
+		if err != nil {
+			// 交给处理器按未登船处理
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := idempotencyRequestBody(r)
+		if err != nil {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "你好，请求内容过大。")
+			return
+		}
+		key := idempotencyKey(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !dao.ValidIdempotencyKey(key) {
+			respondWithError(w, http.StatusBadRequest, "你好，幂等键须为1到128个字母、数字或符号。")
+			return
+		}
+
+		endpoint := r.URL.Path
+		hash := dao.IdempotencyRequestHash(r.Method, endpoint, r.URL.Query(), body)
+		retention := time.Duration(util.Config.IdempotencyKeyRetentionHours) * time.Hour
+		stored, err := dao.BeginIdempotentRequest(sess.UserId, endpoint, key, hash, retention)
+		switch {
+		case errors.Is(err, dao.ErrIdempotencyKeyConflict):
+			util.WarningContext(r.Context(), " idempotency key reused with different request", endpoint, key)
+			respondWithError(w, http.StatusConflict, "你好，这个幂等键已用于内容不同的请求，请刷新页面后重新提交。")
+			return
+		case errors.Is(err, dao.ErrIdempotencyKeyInProgress):
+			respondWithError(w, http.StatusConflict, "你好，相同的请求正在处理中，请稍后查看记录，不要重复提交。")
+			return
+		case err != nil:
+			util.ErrorContext(r.Context(), " cannot begin idempotent request", endpoint, key, err)
+			respondWithError(w, http.StatusInternalServerError, "你好，茶博士失魂鱼，未能登记这次提交，请稍后再试。")
+			return
+		}
+		if stored != nil {
+			util.InfoContext(r.Context(), " replay idempotent response", endpoint, key, stored.ResponseStatus)
+			if stored.ResponseContentType != "" {
+				w.Header().Set("Content-Type", stored.ResponseContentType)
+			}
+			w.Header().Set(idempotencyReplayHeader, "true")
+			w.WriteHeader(stored.ResponseStatus)
+			w.Write(stored.ResponseBody)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			// 处理器 panic，放弃登记，允许重试；panic 交给 Recover
+			if err := dao.AbandonIdempotentRequest(sess.UserId, endpoint, key); err != nil {
+				util.ErrorContext(r.Context(), " cannot abandon idempotent request", endpoint, key, err)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		finished = true
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			if err := dao.AbandonIdempotentRequest(sess.UserId, endpoint, key); err != nil {
+				util.ErrorContext(r.Context(), " cannot abandon idempotent request", endpoint, key, err)
+			}
+			return
+		}
+		if err := dao.CompleteIdempotentRequest(sess.UserId, endpoint, key, status, rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			util.ErrorContext(r.Context(), " cannot save idempotent response", endpoint, key, err)
+		}
+	})
+}
+
+// idempotencyKey 依次取请求头、查询参数、表单字段中的幂等键
+func idempotencyKey(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get(idempotencyHeaderName)); key != "" {
+		return key
+	}
+	if key := strings.TrimSpace(r.URL.Query().Get(idempotencyFieldName)); key != "" {
+		return key
+	}
+	return strings.TrimSpace(r.PostForm.Get(idempotencyFieldName))
+}
+
+// idempotencyRequestBody 取请求内容用于计算摘要：表单请求取解析后的字段（按键排序编码），
+// 其他请求读取原始请求体后放回，供处理器再次读取
+func idempotencyRequestBody(r *http.Request) ([]byte, error) {
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		return []byte(r.PostForm.Encode()), nil
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		if err := r.ParseMultipartForm(idempotencyMaxBodyBytes); err != nil {
+			return nil, err
+		}
+		return []byte(r.PostForm.Encode()), nil
+	}
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, idempotencyMaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > idempotencyMaxBodyBytes {
+		return nil, errors.New("request body too large")
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// idempotencyRecorder 转发响应的同时留存状态码及响应体，供保存为幂等响应
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,60 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+后台任务管理，茶博士/船长使用：
+1、GET /v1/admin/jobs?name= 各任务计划、下次执行时间、最近一次执行状态，以及最近的执行记录（可按任务筛选）；
+2、POST /v1/admin/jobs/run 立即执行任务（name），在后台执行，完成后刷新页面查看结果。
+*/
+
+const jobRunsPageLimit = 50
+
+// HandleJobs GET /v1/admin/jobs 后台任务管理页面
+func HandleJobs(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	jobs, err := dao.Jobs.Overview(r.Context())
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get job overview", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取后台任务状态，请稍后再试。")
+		return
+	}
+	name := r.URL.Query().Get("name")
+	runs, err := dao.RecentJobRuns(r.Context(), name, jobRunsPageLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get recent job runs", name, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取后台任务执行记录，请稍后再试。")
+		return
+	}
+	pageData := dao.JobsPageData{SessUser: s_u, Jobs: jobs, Runs: runs, Name: name, Now: time.Now()}
+	generateHTML(w, &pageData, "layout", "navbar.private", "admin.jobs")
+}
+
+// HandleJobRun POST /v1/admin/jobs/run 立即执行后台任务
+func HandleJobRun(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	name := r.PostFormValue("name")
+	if err := dao.Jobs.RunNow(name); err != nil {
+		if !errors.Is(err, dao.ErrJobRunning) && !errors.Is(err, dao.ErrJobLocked) {
+			util.ErrorContext(r.Context(), " cannot run job", name, err)
+		}
+		report(w, s_u, "你好，后台任务未能启动："+err.Error()+"。")
+		return
+	}
+	util.InfoContext(r.Context(), " job triggered manually", name, s_u.Id)
+	http.Redirect(w, r, "/v1/admin/jobs?name="+name, http.StatusFound)
+}
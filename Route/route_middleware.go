@@ -0,0 +1,280 @@
+package route
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+   中间件：把各处理器重复的样板代码（读取会话、校验请求方法）和全局关注点（请求编号、访问日志、panic恢复）
+   抽成可组合的 http.Handler 包装。
+
+   全局：main.go 中 Chain(mux, RequestID, AccessLog, Recover, CSRFProtect)
+   路由：Handle(handler, Methods(http.MethodGet), RequireLogin)，处理器内用 sessionUser(r) 取当前茶友；
+         管理页面再加 RequireRole(dao.User_Role_TeaOffice, dao.User_Role_Captain)
+*/
+
+// Middleware 包装一个处理器，返回新的处理器
+type Middleware func(http.Handler) http.Handler
+
+type contextKey int
+
+const (
+	sessionContextKey      contextKey = iota // 已校验的会话 dao.Session
+	sessionUserContextKey                    // 当前登船茶友 dao.User
+	requestScopeContextKey                   // 本次请求的 *requestScope
+)
+
+// requestScope 本次请求的编号及登船茶友，由 RequestID 创建，内层中间件补充茶友id，供外层访问日志使用
+type requestScope struct {
+	Id     string
+	UserId int
+}
+
+// Chain 依次用中间件包裹处理器，第一个中间件在最外层（最先执行）
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Handle 用中间件包裹处理器函数，便于在 main.go 中注册路由
+func Handle(fn http.HandlerFunc, middlewares ...Middleware) http.Handler {
+	return Chain(fn, middlewares...)
+}
+
+// ByMethod 按请求方法分派处理器，取代各处理器中的 switch r.Method，未列出的方法返回405
+type ByMethod map[string]http.HandlerFunc
+
+func (m ByMethod) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := m[r.Method]; ok {
+		h(w, r)
+		return
+	}
+	if r.Method == http.MethodHead {
+		if h, ok := m[http.MethodGet]; ok {
+			h(w, r)
+			return
+		}
+	}
+	allow := make([]string, 0, len(m))
+	for method := range m {
+		allow = append(allow, method)
+	}
+	methodNotAllowed(w, allow)
+}
+
+// Methods 只允许指定的请求方法，GET 同时允许 HEAD
+func Methods(methods ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, m := range methods {
+				if r.Method == m || (r.Method == http.MethodHead && m == http.MethodGet) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			methodNotAllowed(w, methods)
+		})
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow []string) {
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+}
+
+// RequireLogin 要求已登船，未登船时跳转登船页面（GET 请求登船后返回原页面），
+// 已登船时把会话及茶友资料放入请求上下文
+func RequireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, err := session(r)
+		if err != nil {
+			login := "/v1/login"
+			if r.Method == http.MethodGet {
+				login += "?footprint=" + url.QueryEscape(r.URL.Path) + "&query=" + url.QueryEscape(r.URL.RawQuery)
+			}
+			http.Redirect(w, r, login, http.StatusFound)
+			return
+		}
+		s_u, err := sess.User()
+		if err != nil {
+			util.DebugContext(r.Context(), " 获取用户信息错误！", err)
+			report(w, s_u, "你好，茶博士失魂鱼，未能读取用户信息！")
+			return
+		}
+		ctx := context.WithValue(r.Context(), sessionContextKey, sess)
+		ctx = context.WithValue(ctx, sessionUserContextKey, s_u)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// sessionUser 取出 RequireLogin 放入上下文的会话及当前茶友
+func sessionUser(r *http.Request) (sess dao.Session, s_u dao.User, ok bool) {
+	if s_u, ok = r.Context().Value(sessionUserContextKey).(dao.User); !ok {
+		return
+	}
+	sess, ok = r.Context().Value(sessionContextKey).(dao.Session)
+	return
+}
+
+// RequireRole 要求当前茶友具有其中一个角色（例如茶博士、船长），须放在 RequireLogin 之后
+func RequireRole(roles ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, s_u, ok := sessionUser(r)
+			if ok {
+				for _, role := range roles {
+					if s_u.Role == role {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			util.WarningContext(r.Context(), " role required", roles)
+			report(w, s_u, "你好，茶博士说这里只接待当值的茶博士和船长，请回大堂喝茶。")
+		})
+	}
+}
+
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID 为每个请求分配编号（沿用上游代理传来的合法 X-Request-ID），写入响应头及请求上下文，
+// 之后用 r.Context() 记录的日志都带有 request_id、route 字段
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestId.MatchString(id) {
+			id = newRequestId()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), requestScopeContextKey, &requestScope{Id: id})
+		ctx = util.WithLogFields(ctx, "request_id", id, "route", r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withLogUser 记录本次请求的登船茶友，之后的日志带有 user_id 字段
+func withLogUser(r *http.Request, userId int) *http.Request {
+	if scope, ok := r.Context().Value(requestScopeContextKey).(*requestScope); ok {
+		scope.UserId = userId
+	}
+	return r.WithContext(util.WithLogFields(r.Context(), "user_id", userId))
+}
+
+func newRequestId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestId 本次请求的编号，未经 RequestID 中间件时为空
+func requestId(r *http.Request) string {
+	if scope, ok := r.Context().Value(requestScopeContextKey).(*requestScope); ok {
+		return scope.Id
+	}
+	return ""
+}
+
+// responseRecorder 记录响应状态码及字节数
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func recorderOf(w http.ResponseWriter) *responseRecorder {
+	if rec, ok := w.(*responseRecorder); ok {
+		return rec
+	}
+	return &responseRecorder{ResponseWriter: w}
+}
+
+// AccessLog 记录访问日志：请求编号、方法、路径、状态码、字节数、耗时
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := recorderOf(w)
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		userId := 0
+		if scope, ok := r.Context().Value(requestScopeContextKey).(*requestScope); ok {
+			userId = scope.UserId
+		}
+		util.Logger().LogAttrs(r.Context(), slog.LevelInfo, "access",
+			slog.String("method", r.Method),
+			slog.String("uri", r.URL.RequestURI()),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("ip", clientIP(r)),
+			slog.Int("user_id", userId))
+	})
+}
+
+// Recover 捕获处理器中的 panic，记录堆栈，并用 report 页面告知茶友，而不是直接断开连接
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := recorderOf(w)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			util.ErrorContext(r.Context(), "panic:", r.Method, r.URL.RequestURI(), p, string(debug.Stack()))
+			if rec.status != 0 {
+				// 已经开始输出响应，无法再改为提示页面
+				return
+			}
+			s_u := dao.UserUnknown
+			if sess, err := session(r); err == nil {
+				if u, err := sess.User(); err == nil {
+					s_u = u
+				}
+			}
+			rec.Header().Set("Content-Type", "text/html; charset=utf-8")
+			rec.WriteHeader(http.StatusInternalServerError)
+			report(rec, s_u, "你好，茶博士手一抖打翻了茶壶，请稍后再试。（编号："+requestId(r)+"）")
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
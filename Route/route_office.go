@@ -13,44 +13,16 @@ func ActivateDraftThread(w http.ResponseWriter, r *http.Request) {
 
 }
 
-// 向2个非当前用户发送蒙评审核通知
+// 按回避、工作量和性别搭配要求指派蒙评评审官（不含作者u_id），记录指派并向评审官发送蒙评审核通知
 func TwoAcceptNotificationsSendExceptUserId(u_id int, mess dao.AcceptNotification, ctx context.Context) error {
-	var user_ids []int
-	var err error
-	// if dao.UserCount() < 50 {
-
-	// 	if user_ids, err = dao.Get2RandomUserId(); err != nil {
-	// 		util.PanicTea(util.LogError(err), " Cannot get 2 random user id")
-	// 		return err
-	// 	}
-	// } else {
-	// 	if dao.SessionCount() > 12 && dao.SessionCount() < 250 {
-	// 		// 在线不同性别随机
-	// 		user_ids, err = dao.Get2GenderRandomSUserIdExceptId(u_id)
-	// 		if err != nil {
-	// 			util.PanicTea(util.LogError(err), " Cannot get 2 gender random sess_user id")
-	// 			user_ids, err = dao.Get2GenderRandomUserIdExceptId(u_id)
-	// 			if err != nil {
-	// 				util.PanicTea(util.LogError(err), " Cannot get 2 gender random user id")
-	// 				// 在线不分性别随机
-	// 				user_ids, err = dao.Get2RandomSUserIdExceptId(u_id)
-	// 				if err != nil {
-	// 					util.PanicTea(util.LogError(err), " Cannot get 2 random sess_user id")
-	// 					return err
-	// 				}
-	// 			}
-	// 		}
-	// 	} else {
-	if user_ids, err = dao.Get2RandomUserId(); err != nil {
-		//test status
-		util.Debug(" Cannot get 2 random user id", err)
+	assignments, err := dao.AssignAcceptReviewers(ctx, mess.AcceptObjectId, u_id)
+	if err != nil {
+		util.Debug(" Cannot assign accept reviewers", mess.AcceptObjectId, err)
 		return err
 	}
-	// 	}
-	// }
-	if len(user_ids) != 2 {
-		util.Debug(" Cannot get 2 random-user-ids", err)
-		return err
+	user_ids := make([]int, 0, len(assignments))
+	for _, a := range assignments {
+		user_ids = append(user_ids, a.ReviewerUserId)
 	}
 	// 发送“是否接纳”通知
 	if err = mess.SendWithContext(user_ids, ctx); err != nil {
@@ -60,7 +32,7 @@ func TwoAcceptNotificationsSendExceptUserId(u_id int, mess dao.AcceptNotificatio
 	// 记录用户有1新通知
 	for _, u_id := range user_ids {
 		if err = dao.AddUserNotificationCount(u_id); err != nil {
-			util.Debug(" Cannot add random user new-notification-count", err)
+			util.Debug(" Cannot add reviewer new-notification-count", err)
 			return err
 		}
 	}
@@ -9,6 +9,11 @@ import (
 	util "teachat/Util"
 )
 
+// searchPageTemplates 查询页面按结果类别引用不同组件，html/template 转义时要求所有被引用的组件都存在
+var searchPageTemplates = []string{"layout", "navbar.private", "search",
+	"component_avatar_name_gender", "component_family", "component_team", "component_thread_bean",
+	"component_project_bean", "component_objective_bean", "component_place"}
+
 // HandleSearch() 查询窗口 /v1/search
 func HandleSearch(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -112,7 +117,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 				fPD.IsEmpty = false
 			}
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_avatar_name_gender")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeUserId:
@@ -127,7 +132,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				fPD.IsEmpty = true
-				generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_avatar_name_gender")
+				generateHTML(w, &fPD, searchPageTemplates...)
 				return
 			} else {
 				util.Debug("failed to get user given user_id: ", keyword_int, err)
@@ -148,7 +153,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 				fPD.IsEmpty = false
 			}
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_avatar_name_gender")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 	case dao.SearchTypeTeamAbbr:
 		//查询，茶团简称，team.abbreviation
@@ -172,7 +177,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 				fPD.IsEmpty = false
 			}
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_team", "component_avatar_name_gender")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeThreadTitle:
@@ -194,7 +199,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.ThreadBeanSlice = thread_bean_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_thread_bean", "component_avatar_name_gender")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeObjectiveTitle:
@@ -216,7 +221,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.ObjectiveBeanSlice = objective_bean_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_objective_bean", "component_avatar_name_gender")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeProjectTitle:
@@ -225,7 +230,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			util.Debug(" failed to search project by title", err)
 			fPD.IsEmpty = true
-			generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_project_bean", "component_avatar_name_gender")
+			generateHTML(w, &fPD, searchPageTemplates...)
 			return
 		} else {
 			if len(project_slice) >= 1 {
@@ -237,7 +242,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 				fPD.ProjectBeanSlice = project_bean_slice
 				fPD.IsEmpty = false
 			}
-			generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_project_bean", "component_avatar_name_gender")
+			generateHTML(w, &fPD, searchPageTemplates...)
 			return
 		}
 	case dao.SearchTypePlaceName:
@@ -251,7 +256,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.PlaceSlice = place_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_place")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeEnvironment:
@@ -265,7 +270,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.EnvironmentSlice = environment_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeHazard:
@@ -279,7 +284,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.HazardSlice = hazard_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeGoods:
@@ -293,7 +298,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.GoodsSlice = goods_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeRisk: // SearchTypeRisk
@@ -307,7 +312,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.RiskSlice = risk_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeSkill:
@@ -321,7 +326,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.SkillSlice = skill_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeMagic:
@@ -335,7 +340,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.MagicSlice = magic_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	case dao.SearchTypeFamilyName:
@@ -355,7 +360,7 @@ func SearchPost(w http.ResponseWriter, r *http.Request) {
 			fPD.FamilyBeanSlice = family_bean_slice
 			fPD.IsEmpty = false
 		}
-		generateHTML(w, &fPD, "layout", "navbar.private", "search", "component_family")
+		generateHTML(w, &fPD, searchPageTemplates...)
 		return
 
 	default:
@@ -382,5 +387,5 @@ func SearchGet(w http.ResponseWriter, r *http.Request) {
 	f.SessUser = s_u
 
 	// 打开查询页面
-	generateHTML(w, &f, "layout", "navbar.private", "search")
+	generateHTML(w, &f, searchPageTemplates...)
 }
@@ -2,8 +2,10 @@ package route
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	dao "teachat/DAO"
 	util "teachat/Util"
 )
@@ -23,8 +25,8 @@ func createAndSendAcceptNotification(objectId int, objectType int, excludeUserId
 	// 创建通知
 	mess := dao.AcceptNotification{
 		FromUserId:     dao.UserId_Captain_Spaceship,
-		Title:          "新茶语邻座评审邀请",
-		Content:        "您被茶棚选中为新茶语评审官啦，请及时去审理。",
+		Title:          dao.AcceptInvitationTitle,
+		Content:        dao.AcceptInvitationContent,
 		AcceptObjectId: aO.Id,
 	}
 
@@ -195,3 +197,255 @@ func acceptNewGroup(objectId int) (*dao.Group, error) {
 	}
 	return &g, nil
 }
+
+// applyAcceptVerdict 按友邻蒙评结论处理蒙评对象：婉拒时标记为友邻蒙评未通过，接纳时发布，
+// 返回发布后的对象id（茶议、品味为新建的id，婉拒时为0）。出错时返回可直接展示给茶友的提示
+func applyAcceptVerdict(ao dao.AcceptObject, accepted bool) (int, error) {
+	if accepted {
+		return acceptAcceptObject(ao)
+	}
+	return 0, rejectAcceptObject(ao)
+}
+
+// settleAcceptVerdict 友邻评审得出结论后处理蒙评对象；这一轮是申诉复审的，先认领申诉记下结论，
+// 认领成功后才发布或退回对象，申诉已有结论时不再重复处理
+func settleAcceptVerdict(ctx context.Context, ao dao.AcceptObject, accepted bool) error {
+	appeal, err := dao.ClaimAcceptAppeal(ctx, ao.Id, accepted, dao.UserId_None, "")
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// 不是申诉复审的一轮
+		_, err = applyAcceptVerdict(ao, accepted)
+		return err
+	case errors.Is(err, dao.ErrAcceptAppealNotPending):
+		return errors.New("你好，" + dao.ErrAcceptAppealNotPending.Error() + "。")
+	case err != nil:
+		util.ErrorContext(ctx, "cannot claim accept appeal", ao.Id, err)
+		return errors.New("你好，茶博士失魂鱼，未能记下申诉复审结论，请稍后再试。")
+	}
+	return resolveClaimedAcceptAppeal(ctx, appeal, ao, accepted)
+}
+
+// resolveClaimedAcceptAppeal 按已认领的申诉结论处理蒙评对象；处理失败时撤回认领，以便重新复审
+func resolveClaimedAcceptAppeal(ctx context.Context, appeal dao.AcceptAppeal, ao dao.AcceptObject, accepted bool) error {
+	publishedId, err := applyAcceptVerdict(ao, accepted)
+	if err != nil {
+		if rerr := dao.ReleaseAcceptAppeal(ctx, appeal.Id); rerr != nil {
+			util.ErrorContext(ctx, "cannot release accept appeal", appeal.Id, rerr)
+		}
+		return err
+	}
+	if _, err = dao.ResolveAcceptAppeal(ctx, appeal, publishedId); err != nil {
+		util.ErrorContext(ctx, "cannot resolve accept appeal", appeal.Id, err)
+		return errors.New("你好，复审结论已生效，但茶博士未能记下申诉结论，请稍后查看。")
+	}
+	return nil
+}
+
+// rejectAcceptObject 友邻蒙评拒绝接纳这个茶语！Oh my...
+// 通知茶语主人友邻蒙评结果为：婉拒！---没有通知
+func rejectAcceptObject(ao dao.AcceptObject) error {
+	// 根据对象类型处理
+	switch ao.ObjectType {
+	case dao.AcceptObjectTypeObjective:
+		ob := dao.Objective{
+			Id: ao.ObjectId}
+		if err := ob.Get(); err != nil {
+			util.Debug("Cannot get objective", err)
+			return errors.New("你好，茶博士失魂鱼，竟然说没有找到新茶茶叶的资料未必是怪事。")
+		}
+		switch ob.Class {
+		case dao.ObClassOpenDraft:
+			ob.Class = dao.ObClassNeighborRejectOpen
+		case dao.ObClassCloseDraft:
+			ob.Class = dao.ObClassNeighborRejectClose
+		}
+		// 更新茶话会，友邻蒙评未通过！
+		if err := ob.UpdateClass(); err != nil {
+			util.Debug("Cannot update ob class", err)
+			return errors.New("你好，(摸摸头想了又想), 为什么踢足球的人都说临门一脚最麻烦呢？")
+		}
+	case dao.AcceptObjectTypeProject:
+		pr := dao.Project{
+			Id: ao.ObjectId,
+		}
+		if err := pr.Get(); err != nil {
+			util.Debug("Cannot get project", err)
+			return errors.New("你好，茶博士失魂鱼，竟然说有时找茶叶也是一种修养的过程。")
+		}
+		switch pr.Class {
+		case dao.PrClassOpenDraft:
+			pr.Class = dao.PrClassRejectedOpen
+		case dao.PrClassCloseDraft:
+			pr.Class = dao.PrClassRejectedClose
+		}
+		// 更新茶台属性，
+		if err := pr.UpdateClass(); err != nil {
+			util.Debug("Cannot update pr class", err)
+			return errors.New("你好，一畦春韭绿，十里稻花香。")
+		}
+	case dao.AcceptObjectTypeThread:
+		dThread := dao.DraftThread{
+			Id: ao.ObjectId,
+		}
+		if err := dThread.Get(); err != nil {
+			util.Debug("Cannot get dfart-thread", err)
+			return errors.New("你好，茶博士失魂鱼，竟然说有时候找茶叶需要的不是技术,而是耐心。")
+		}
+		// 更新茶议属性，友邻蒙评 已拒绝公开发布
+		if err := dThread.UpdateStatus(dao.DraftThreadStatusRejected); err != nil {
+			util.Debug("Cannot update thread class", err)
+			return errors.New("你好，睿藻仙才盈彩笔，自惭何敢再为辞。")
+		}
+	case dao.AcceptObjectTypePost:
+		dPost := dao.DraftPost{
+			Id: ao.ObjectId,
+		}
+		if err := dPost.Get(); err != nil {
+			util.Debug("Cannot get draft-post", err)
+			return errors.New("你好，茶博士失魂鱼，竟然说有时候 弄丢草稿的人不一定是诗人？")
+		}
+		if err := dPost.Reject(); err != nil {
+			util.Debug("Cannot update draft-post class", err)
+			return errors.New("你好，宝鼎茶闲烟尚绿，幽窗棋罢指犹凉。")
+		}
+	case dao.AcceptObjectTypeTeam:
+		team, err := dao.GetTeam(ao.ObjectId)
+		if err != nil {
+			util.Debug("Cannot get team", err)
+			return errors.New("你好，茶博士失魂鱼，竟然说有时候临急抱佛脚比刻苦奋斗更有用？")
+		}
+		switch team.Class {
+		case dao.TeamClassOpenDraft:
+			team.Class = dao.TeamClassRejectedOpenDraft
+		case dao.TeamClassCloseDraft:
+			team.Class = dao.TeamClassRejectedCloseDraft
+		}
+		if err = team.UpdateClass(); err != nil {
+			util.Debug("Cannot update team class", err)
+			return errors.New("你好，（摸摸头）考一考你，错里错以错劝哥哥是什么茶品种？")
+		}
+	case dao.AcceptObjectTypeGroup:
+		g := dao.Group{Id: ao.ObjectId}
+		if err := g.Get(); err != nil {
+			util.Debug("Cannot get group", err)
+			return errors.New("你好，满头大汗的茶博士请教你，乌龙茶是什么茶品种？")
+		}
+		switch g.Class {
+		case dao.GroupClassOpenDraft:
+			g.Class = dao.GroupClassRejectedOpenDraft
+		case dao.GroupClassCloseDraft:
+			g.Class = dao.GroupClassRejectedCloseDraft
+		}
+		if err := g.Update(); err != nil {
+			util.Debug("Cannot update group class", err)
+			return errors.New("你好，茶博士失魂鱼，竟然说有时候泡茶的水比茶叶还要紧。")
+		}
+	}
+	return nil
+}
+
+// acceptAcceptObject 两个审茶官都认为这是文明发言，接纳发布
+func acceptAcceptObject(ao dao.AcceptObject) (int, error) {
+	// 根据对象类型处理
+	switch ao.ObjectType {
+	case dao.AcceptObjectTypeObjective:
+		if _, err := acceptNewObjective(ao.ObjectId); err != nil {
+			return 0, err
+		}
+
+	case dao.AcceptObjectTypeProject:
+		if err := acceptNewProject(ao.ObjectId); err != nil {
+			return 0, err
+		}
+	case dao.AcceptObjectTypeThread:
+		thread, err := acceptNewDraftThread(ao.ObjectId)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), "获取茶议草稿失败"):
+				util.Debug("Cannot get draft-thread", err)
+				return 0, errors.New("你好，茶博士失魂鱼，竟然说有时候泡一壶好茶的关键，需要的不是技术而是耐心。")
+			case strings.Contains(err.Error(), "更新茶议草稿状态失败"):
+				util.Debug("Cannot update draft-thread status", err)
+				return 0, errors.New("你好，睿藻仙才盈彩笔，自惭何敢再为辞。")
+			case strings.Contains(err.Error(), "创建新茶议失败"):
+				util.Debug("Cannot save thread", err)
+				return 0, errors.New("你好，吟成荳蔻才犹艳，睡足酴醾梦也香。")
+			default:
+				util.Debug("未知错误", err)
+				return 0, errors.New("世事洞明皆学问，人情练达即文章。")
+			}
+		}
+		return thread.Id, nil
+	case dao.AcceptObjectTypePost:
+		post, err := acceptNewDraftPost(ao.ObjectId)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), "获取品味草稿失败"):
+				util.Debug("Cannot get draft-post", err)
+				return 0, errors.New("你好，茶博士失魂鱼，竟然说有时候泡一壶好茶的关键，需要的不是技术而是耐心。")
+			case strings.Contains(err.Error(), "创建新品味失败"):
+				util.Debug("Cannot save post", err)
+				return 0, errors.New("你好，吟成荳蔻才犹艳，睡足酴醾梦也香。")
+			default:
+				util.Debug("处理接纳新品味时发生未知错误", err)
+				return 0, errors.New("世事洞明皆学问，人情练达即文章。")
+			}
+		}
+		return post.Id, nil
+
+	case dao.AcceptObjectTypeTeam:
+		//把草团转为正式$事业茶团
+		team, err := acceptNewTeam(ao.ObjectId)
+		if err != nil {
+			util.Debug("Cannot accept new team", err)
+			return 0, errors.New("盛世无饥馑，何须耕织忙？不急不急。")
+		}
+
+		// 将设立team的Founder作为默认的CEO角色成员，teamMember.Role=dao.RoleCEO
+		teamMember := dao.TeamMember{
+			TeamId: team.Id,
+			UserId: team.FounderId,
+			Role:   dao.RoleCEO,
+			Status: dao.TeamMemberStatusActive,
+		}
+		if err = teamMember.Create(); err != nil {
+			util.Debug("Cannot create team-member", err)
+			return 0, errors.New("你好，花因喜洁难寻偶，人为悲秋易断魂。")
+		}
+		//检查团队发起人是否设置了（有效）非占位默认$茶团，
+		//如果还没有，把这个新茶团设置为默认$茶团
+		t_founder, err := dao.GetUser(team.FounderId)
+		if err != nil {
+			util.Debug("Cannot get team founder", err)
+			return 0, errors.New("你好，吟成荳蔻才犹艳，睡足酴醾梦也香。请稍后再试。")
+		}
+		oldDefaultTeam, err := t_founder.GetLastDefaultTeam()
+		if err != nil {
+			util.Debug(t_founder.Email, "Cannot get last default team")
+			return 0, errors.New("你好，茶博士失魂鱼，手滑未能创建你的天命使团，请稍后再试。")
+		}
+		// 检查是否为占位团队（自由人）
+		if oldDefaultTeam.Id == dao.TeamIdFreelancer {
+			uDT := dao.UserDefaultTeam{
+				UserId: t_founder.Id,
+				TeamId: team.Id,
+			}
+			if err = uDT.Create(); err != nil {
+				util.Debug(t_founder.Email, team.Id, "Cannot create default team")
+				return 0, errors.New("你好，茶博士失魂鱼，未能创建新茶团，请稍后再试。")
+			}
+		}
+
+	case dao.AcceptObjectTypeGroup:
+		// 接纳新集团
+		if _, err := acceptNewGroup(ao.ObjectId); err != nil {
+			util.Debug("Cannot accept new team", err)
+			return 0, errors.New("盛世无饥馑，何须耕织忙？不急不急。")
+		}
+
+	default:
+		util.Debug("Cannot get object", ao.ObjectType)
+		return 0, errors.New("你好，茶博士失魂鱼，竟然说有时候喝茶比做傻事强？")
+	}
+	return ao.ObjectId, nil
+}
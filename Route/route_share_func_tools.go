@@ -1,6 +1,7 @@
 package route
 
 import (
+	"bytes"
 	"crypto/md5"
 	"errors"
 	"fmt"
@@ -16,84 +17,14 @@ import (
 	"strings"
 	dao "teachat/DAO"
 	util "teachat/Util"
-	"text/template"
 	"time"
 	"unicode/utf8"
 )
 
 // 处理器把页面模版和需求数据揉合后，由这个方法，将填写好的页面“制作“成HTML格式，调用http响应方法，发送给浏览器端客户
 func generateHTML(w http.ResponseWriter, template_data any, filenames ...string) {
-	var files []string
-	for _, file := range filenames {
-		files = append(files, fmt.Sprintf("templates/%s.go.html", file))
-	}
-
-	// 创建模板并添加自定义函数
-	tmpl := template.New("layout").Funcs(template.FuncMap{
-		"GetEnvironmentLevelDescription": dao.GetEnvironmentLevelDescription,
-		"GetStarIcons": func(level int) string {
-			if level < 1 || level > 5 {
-				return ""
-			}
-			stars := ""
-			for i := 0; i < level; i++ {
-				stars += `<span class="glyphicon glyphicon-star" style="color: #f39c12;"></span>`
-			}
-			return stars
-		},
-		"RiskSeverityLevelString": dao.RiskSeverityLevelString,
-		"AvailabilityString":      dao.GoodsAvailabilityString,
-		"mul": func(a, b int) int {
-			return a * b
-		},
-		"sub": func(a, b int64) int64 {
-			return a - b
-		},
-		"subtract": func(a, b int) int {
-			return a - b
-		},
-		"add": func(a, b int) int {
-			return a + b
-		},
-		"max": func(a, b int) int {
-			if a > b {
-				return a
-			}
-			return b
-		},
-		"min": func(a, b int) int {
-			if a < b {
-				return a
-			}
-			return b
-		},
-		"seq": func(start, end int) []int {
-			var items []int
-			for i := start; i <= end; i++ {
-				items = append(items, i)
-			}
-			return items
-		},
-		"iterate": func(count int) []int {
-			var items []int
-			for i := 0; i < count; i++ {
-				items = append(items, i)
-			}
-			return items
-		},
-		"split": func(s, sep string) []string {
-			return strings.Split(s, sep)
-		},
-		"trim": func(s string) string {
-			return strings.TrimSpace(s)
-		},
-		"RoleName":      dao.TeamMemberRoleName,
-		"GroupRoleName": dao.GroupRoleName,
-		"FormatFloat":   util.FormatFloat,
-	})
-
-	// 手动解析模板并处理错误
-	templates, err := tmpl.ParseFiles(files...)
+	// 从缓存中取出模版集，首次使用时拼装
+	tmpl, err := pageTemplates.lookup(filenames...)
 	if err != nil {
 		// 添加详细的错误日志和HTTP错误响应
 		util.PrintStdout("模板解析错误: ", err)
@@ -101,17 +32,20 @@ func generateHTML(w http.ResponseWriter, template_data any, filenames ...string)
 		return
 	}
 
-	// 安全增强：设置内容类型为HTML并添加XSS防护头
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-
-	// 执行模板渲染
-	if err = templates.ExecuteTemplate(w, "layout", template_data); err != nil {
+	// 先渲染到缓冲区，渲染出错时不会向浏览器发送半截页面
+	var buf bytes.Buffer
+	if err = tmpl.ExecuteTemplate(&buf, "layout", template_data); err != nil {
 		// 添加详细的错误日志
 		util.PrintStdout("模板渲染错误: ", err)
 		// 避免在错误响应中泄露敏感信息
 		http.Error(w, "*** 茶博士: 茶壶不见了，无法烧水冲茶，陛下稍安勿躁 ***", http.StatusInternalServerError)
+		return
 	}
+
+	// 安全增强：设置内容类型为HTML并添加XSS防护头
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(injectCsrfToken(buf.Bytes(), csrfTokenFrom(w)))
 }
 
 // 验证邮箱地址，格式是否正确，正确返回true，错误返回false。
@@ -337,7 +271,7 @@ func saveUploadAvatar(r *http.Request, uuid, avatarType string) error {
 	// 从请求中解包出单个上传文件
 	file, fileHeader, err := r.FormFile("avatar")
 	if err != nil {
-		util.Debug("avatar upload formFile error: %v", err)
+		util.DebugContext(r.Context(), "avatar upload formFile error:", err)
 		return errors.New("获取头像文件失败，请稍后再试。")
 	}
 	// 确保文件在函数执行完毕后关闭
@@ -346,17 +280,17 @@ func saveUploadAvatar(r *http.Request, uuid, avatarType string) error {
 	// 获取文件大小，注意：客户端提供的文件大小可能不准确
 	size := fileHeader.Size
 	if size > 30*1024 {
-		util.Debug("avatar upload file size over 30kb,error: %v", err)
+		util.DebugContext(r.Context(), "avatar upload file size over 30kb:", size)
 		return errors.New("文件大小超过30kb,茶博士接不住。")
 	}
 	// 实际读取文件大小进行校验，以防止客户端伪造
 	fileBytes, err := io.ReadAll(file)
 	if err != nil {
-		util.Debug("avatar upload read file error: %v", err)
+		util.DebugContext(r.Context(), "avatar upload read file error:", err)
 		return errors.New("读取头像文件失败，请稍后再试。")
 	}
 	if len(fileBytes) > 30*1024 {
-		util.Debug("avatar upload file size over 30kb,error: %v", err)
+		util.DebugContext(r.Context(), "avatar upload file size over 30kb:", len(fileBytes))
 		return errors.New("文件大小超过30kb,茶博士接不住。")
 	}
 
@@ -364,25 +298,25 @@ func saveUploadAvatar(r *http.Request, uuid, avatarType string) error {
 	filename := fileHeader.Filename
 	ext := strings.ToLower(path.Ext(filename))
 	if ext != ".jpeg" && ext != ".jpg" {
-		util.Debug("avatar upload file ext error: %v", err)
+		util.DebugContext(r.Context(), "avatar upload file ext error:", ext)
 		return errors.New("注意头像图片文件类型, 目前仅限jpeg格式图片上传。")
 	}
 
 	// 获取文件类型，注意：客户端提供的文件类型可能不准确
 	fileType := http.DetectContentType(fileBytes)
 	if fileType != "image/jpeg" {
-		util.Debug("avatar upload file type error: %v", err)
+		util.DebugContext(r.Context(), "avatar upload file type error:", fileType)
 		return errors.New("注意图片文件类型,目前仅限jpeg格式。")
 	}
 
 	// 检测图片尺寸宽高和图像格式,判断是否合适
 	width, height, err := getWidthHeightForJpeg(fileBytes)
 	if err != nil {
-		util.Debug("avatar upload file type error: %v", err)
+		util.DebugContext(r.Context(), "avatar upload jpeg decode error:", err)
 		return errors.New("注意图片文件类型,目前仅限jpeg格式。")
 	}
 	if width < 32 || width > 64 || height < 32 || height > 64 {
-		util.Debug("avatar upload file size is not between 32 and 64 error: %v", err)
+		util.DebugContext(r.Context(), "avatar upload image size is not between 32 and 64:", width, height)
 		return errors.New("注意图片尺寸, 宽高需要在32-64像素之间。")
 	}
 
@@ -398,14 +332,14 @@ func saveUploadAvatar(r *http.Request, uuid, avatarType string) error {
 	}
 	// 确保目录存在
 	if err := os.MkdirAll(saveDir, 0755); err != nil {
-		util.Debug("fail to create avatar directory", err)
+		util.DebugContext(r.Context(), "fail to create avatar directory", err)
 		return errors.New("创建头像目录失败，请稍后再试。")
 	}
 	// 创建新文件，无需切换目录，直接使用完整路径，减少安全风险
 	newFilePath := saveDir + uuid + util.Config.ImageExt
 	newFile, err := os.Create(newFilePath)
 	if err != nil {
-		util.Debug("fail to create avatar image", err)
+		util.DebugContext(r.Context(), "fail to create avatar image", err)
 		return errors.New("创建头像文件失败，请稍后再试。")
 	}
 	// 确保文件在函数执行完毕后关闭
@@ -413,7 +347,7 @@ func saveUploadAvatar(r *http.Request, uuid, avatarType string) error {
 
 	// 直接写入文件，参考 saveUploadedFile 的简洁实现
 	if _, err = newFile.Write(fileBytes); err != nil {
-		util.Debug("fail to write avatar image", err)
+		util.DebugContext(r.Context(), "fail to write avatar image", err)
 		return errors.New("创建头像文件失败，请稍后再试。")
 	}
 
@@ -480,6 +414,10 @@ func report(w http.ResponseWriter, s_u dao.User, msg ...any) {
 
 // Checks if the user is logged in and has a session, if not err is not nil
 func session(r *http.Request) (dao.Session, error) {
+	// CSRFProtect 已校验过的会话
+	if sess, ok := r.Context().Value(sessionContextKey).(dao.Session); ok {
+		return sess, nil
+	}
 	cookie, err := r.Cookie("_cookie")
 	if err != nil {
 		return dao.Session{}, fmt.Errorf("cookie not found: %w", err)
@@ -493,6 +431,10 @@ func session(r *http.Request) (dao.Session, error) {
 	if !ok {
 		return dao.Session{}, errors.New("invalid or expired session")
 	}
+	// 记录最后活动并顺延有效期，失败不影响本次访问
+	if err = sess.Touch(r.UserAgent(), clientIP(r)); err != nil {
+		util.DebugContext(r.Context(), "Cannot touch session", sess.Uuid, err)
+	}
 
 	return sess, nil
 }
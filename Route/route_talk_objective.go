@@ -356,6 +356,7 @@ func ObjectiveDetail(w http.ResponseWriter, r *http.Request) {
 		report(w, s_u, "你好，疏是枝条艳是花，春妆儿女竞奢华。茶博士为你时刻忙碌奋斗着。")
 		return
 	}
+	oD.AcceptVerdicts = publishedAcceptVerdicts(r, dao.AcceptObjectTypeObjective, ob.Id)
 	//检查用户是否已经登录
 	s, err := session(r)
 	if err != nil {
@@ -369,7 +370,7 @@ func ObjectiveDetail(w http.ResponseWriter, r *http.Request) {
 			Query:     r.URL.RawQuery,
 		}
 		//配置公开导航条的茶话会详情页面
-		generateHTML(w, &oD, "layout", "navbar.public", "objective.detail", "component_project_bean", "component_avatar_name_gender", "component_sess_capacity")
+		generateHTML(w, &oD, "layout", "navbar.public", "objective.detail", "component_project_bean", "component_avatar_name_gender", "component_sess_capacity", "component_accept_verdicts")
 		return
 	}
 
@@ -420,7 +421,7 @@ func ObjectiveDetail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//配置私有导航条的茶话会详情页面
-	generateHTML(w, &oD, "layout", "navbar.private", "objective.detail", "component_project_bean", "component_avatar_name_gender", "component_sess_capacity")
+	generateHTML(w, &oD, "layout", "navbar.private", "objective.detail", "component_project_bean", "component_avatar_name_gender", "component_sess_capacity", "component_accept_verdicts")
 
 }
 
@@ -97,6 +97,8 @@ func PostDetail(w http.ResponseWriter, r *http.Request) {
 		pD.IsOverTwelve = false
 	}
 
+	pD.AcceptVerdicts = publishedAcceptVerdicts(r, dao.AcceptObjectTypePost, t_post.Id)
+
 	// 读取会话
 	s, err := session(r)
 	if err != nil {
@@ -111,7 +113,7 @@ func PostDetail(w http.ResponseWriter, r *http.Request) {
 			Footprint: r.URL.Path,
 			Query:     r.URL.RawQuery,
 		}
-		generateHTML(w, &pD, "layout", "navbar.public", "post.detail", "component_sess_capacity", "component_avatar_name_gender")
+		generateHTML(w, &pD, "layout", "navbar.public", "post.detail", "component_sess_capacity", "component_avatar_name_gender", "component_accept_verdicts")
 		return
 	}
 	// 读取已登陆陛下资料
@@ -186,7 +188,7 @@ func PostDetail(w http.ResponseWriter, r *http.Request) {
 		pD.IsAuthor = false
 	}
 
-	generateHTML(w, &pD, "layout", "navbar.private", "post.detail", "component_sess_capacity", "component_avatar_name_gender")
+	generateHTML(w, &pD, "layout", "navbar.private", "post.detail", "component_sess_capacity", "component_avatar_name_gender", "component_accept_verdicts")
 
 }
 
@@ -1336,6 +1336,8 @@ func ProjectDetail(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	pD.AcceptVerdicts = publishedAcceptVerdicts(r, dao.AcceptObjectTypeProject, pr.Id)
+
 	// 获取会话session
 	s, err := session(r)
 	if err != nil {
@@ -1348,7 +1350,7 @@ func ProjectDetail(w http.ResponseWriter, r *http.Request) {
 			Query:     r.URL.RawQuery,
 		}
 		// 返回茶台详情游客页面
-		generateHTML(w, &pD, "layout", "navbar.public", "project.detail", "component_thread_bean_approved", "component_thread_bean", "component_avatar_name_gender", "component_sess_capacity")
+		generateHTML(w, &pD, "layout", "navbar.public", "project.detail", "component_thread_bean_approved", "component_thread_bean", "component_avatar_name_gender", "component_sess_capacity", "component_accept_verdicts")
 		return
 	}
 
@@ -1449,5 +1451,5 @@ func ProjectDetail(w http.ResponseWriter, r *http.Request) {
 	pD.SessUser.Footprint = r.URL.Path
 	pD.SessUser.Query = r.URL.RawQuery
 
-	generateHTML(w, &pD, "layout", "navbar.private", "project.detail", "component_thread_bean_approved", "component_thread_bean", "component_avatar_name_gender", "component_sess_capacity")
+	generateHTML(w, &pD, "layout", "navbar.private", "project.detail", "component_thread_bean_approved", "component_thread_bean", "component_avatar_name_gender", "component_sess_capacity", "component_accept_verdicts")
 }
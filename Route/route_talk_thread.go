@@ -385,6 +385,7 @@ func ThreadDetail(w http.ResponseWriter, r *http.Request) {
 		report(w, s_u, "你好，茶博士失魂鱼，嘀咕无为有处有还无？。")
 		return
 	}
+	tD.AcceptVerdicts = publishedAcceptVerdicts(r, dao.AcceptObjectTypeThread, thread.Id)
 	// 读取会话
 	s, err := session(r)
 
@@ -407,7 +408,7 @@ func ThreadDetail(w http.ResponseWriter, r *http.Request) {
 			}
 
 			//show the thread and the posts展示页面
-			generateHTML(w, &tD, "layout", "navbar.public", "thread.detail", "component_sess_capacity", "component_post_left", "component_post_right", "component_avatar_name_gender")
+			generateHTML(w, &tD, "layout", "navbar.public", "thread.detail", "component_sess_capacity", "component_post_left", "component_post_right", "component_avatar_name_gender", "component_accept_verdicts")
 			return
 		} else {
 			report(w, s_u, "茶水温度太高了，不适合品味，请稍后再试。")
@@ -572,7 +573,7 @@ func ThreadDetail(w http.ResponseWriter, r *http.Request) {
 				//dao.SaveReadedUserId(tD.ThreadBean.Thread.Id, s_u.Id)
 
 				//展示撰写者视角茶议详情页面
-				generateHTML(w, &tD, "layout", "navbar.private", "thread.detail", "component_sess_capacity", "component_post_left", "component_post_right", "component_avatar_name_gender")
+				generateHTML(w, &tD, "layout", "navbar.private", "thread.detail", "component_sess_capacity", "component_post_left", "component_post_right", "component_avatar_name_gender", "component_accept_verdicts")
 				return
 			} else {
 				//不是茶议撰写者
@@ -612,7 +613,7 @@ func ThreadDetail(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 				//展示非撰写者视角茶议详情页面
-				generateHTML(w, &tD, "layout", "navbar.private", "thread.detail", "component_sess_capacity", "component_post_left", "component_post_right", "component_avatar_name_gender")
+				generateHTML(w, &tD, "layout", "navbar.private", "thread.detail", "component_sess_capacity", "component_post_left", "component_post_right", "component_avatar_name_gender", "component_accept_verdicts")
 				return
 			}
 		} else {
@@ -0,0 +1,228 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+星茶账户冻结记录及申诉：
+1、持有人查看冻结记录、申诉记录：GET /v1/tea/user/freeze、GET /v1/tea/team/freeze?team_id=（团队成员）；
+2、账户冻结期间提出申诉：POST /v1/tea/user/freeze/appeal、POST /v1/tea/team/freeze/appeal?team_id=（团队核心成员），
+   字段 statement（申诉理由）、evidence_uuid（可选，相关凭据）；
+3、茶博士/船长审核：GET /v1/tea/freeze/appeals 待审核申诉，POST /v1/tea/freeze/appeal/decide 字段 uuid、decision（approve|reject）、note。
+*/
+
+// teaFreezeAutoUnfreezeMaxHours 自动解冻时限上限（一年）
+const teaFreezeAutoUnfreezeMaxHours = 24 * 365
+
+// teaFreezeRequest 由冻结接口参数组装冻结请求，凭据须存在，自动解冻时限0表示不自动解冻
+func teaFreezeRequest(r *http.Request, operatorUserId int, reason, evidenceUuid string, autoUnfreezeHours int) (dao.TeaFreezeRequest, error) {
+	req := dao.TeaFreezeRequest{OperatorUserId: operatorUserId, Source: dao.TeaFreezeSource_Manual, Reason: reason}
+	if autoUnfreezeHours < 0 || autoUnfreezeHours > teaFreezeAutoUnfreezeMaxHours {
+		return req, fmt.Errorf("自动解冻时限须为1到%d小时", teaFreezeAutoUnfreezeMaxHours)
+	}
+	if autoUnfreezeHours > 0 {
+		at := time.Now().Add(time.Duration(autoUnfreezeHours) * time.Hour)
+		req.UnfreezeAt = &at
+	}
+	evidenceId, err := teaFreezeEvidenceId(r, evidenceUuid)
+	if err != nil {
+		return req, err
+	}
+	req.EvidenceId = evidenceId
+	return req, nil
+}
+
+// teaFreezeEvidenceId 按uuid查找凭据，未指定时为0
+func teaFreezeEvidenceId(r *http.Request, evidenceUuid string) (int, error) {
+	evidenceUuid = strings.TrimSpace(evidenceUuid)
+	if evidenceUuid == "" {
+		return 0, nil
+	}
+	evidence, err := dao.GetEvidenceByUUID(evidenceUuid, r.Context())
+	if err != nil || evidence.IsDeleted() {
+		util.DebugContext(r.Context(), "cannot get evidence by uuid", evidenceUuid, err)
+		return 0, fmt.Errorf("凭据不存在")
+	}
+	return evidence.Id, nil
+}
+
+// HandleTeaUserFreeze GET /v1/tea/user/freeze 用户星茶账户冻结记录及申诉
+func HandleTeaUserFreeze(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	account, err := dao.GetTeaAccountByUserId(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get tea user account", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取您的星茶账户，请稍后再试。")
+		return
+	}
+	pageData := dao.TeaAccountFreezePageData{SessUser: s_u, HolderType: dao.TeaAccountHolderType_User,
+		Status: account.Status, FrozenReason: account.FrozenReason, AppealAction: "/v1/tea/user/freeze/appeal"}
+	if !loadTeaAccountFreezePage(w, r, &pageData, dao.TeaUserLedgerAccount(s_u.Id), true) {
+		return
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.account.freeze")
+}
+
+// HandleTeaTeamFreeze GET /v1/tea/team/freeze?team_id= 团队星茶账户冻结记录及申诉
+func HandleTeaTeamFreeze(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	team, err := teaAccountTeam(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	account, err := dao.GetTeaTeamAccountByTeamId(team.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get tea team account", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取团队星茶账户，请稍后再试。")
+		return
+	}
+	isCoreMember, err := team.IsCoreMember(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot check team core member", team.Id, s_u.Id, err)
+	}
+	pageData := dao.TeaAccountFreezePageData{SessUser: s_u, HolderType: dao.TeaAccountHolderType_Team, Team: team,
+		Status: account.Status, FrozenReason: account.FrozenReason, AppealAction: fmt.Sprintf("/v1/tea/team/freeze/appeal?team_id=%d", team.Id)}
+	if !loadTeaAccountFreezePage(w, r, &pageData, dao.TeaTeamLedgerAccount(team.Id), isCoreMember) {
+		return
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.account.freeze")
+}
+
+// loadTeaAccountFreezePage 读取冻结记录及申诉，出错时已输出提示页面并返回 false
+func loadTeaAccountFreezePage(w http.ResponseWriter, r *http.Request, pageData *dao.TeaAccountFreezePageData, account dao.TeaLedgerAccount, mayAppeal bool) bool {
+	var err error
+	if pageData.Events, err = dao.TeaAccountFreezeHistory(r.Context(), account); err != nil {
+		util.DebugContext(r.Context(), "cannot get tea account freeze history", account, err)
+		report(w, pageData.SessUser, "你好，茶博士失魂鱼，未能读取冻结记录，请稍后再试。")
+		return false
+	}
+	if pageData.Appeals, err = dao.TeaFreezeAppealsByAccount(r.Context(), account); err != nil {
+		util.DebugContext(r.Context(), "cannot get tea freeze appeals", account, err)
+		report(w, pageData.SessUser, "你好，茶博士失魂鱼，未能读取申诉记录，请稍后再试。")
+		return false
+	}
+	pending := false
+	for _, a := range pageData.Appeals {
+		if a.Status == dao.TeaFreezeAppealStatus_Pending {
+			pending = true
+			break
+		}
+	}
+	pageData.CanAppeal = mayAppeal && pageData.IsFrozen() && !pending
+	return true
+}
+
+// FileTeaUserFreezeAppeal POST /v1/tea/user/freeze/appeal 对冻结的用户星茶账户提出申诉
+func FileTeaUserFreezeAppeal(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	if !fileTeaFreezeAppeal(w, r, s_u, dao.TeaUserLedgerAccount(s_u.Id)) {
+		return
+	}
+	http.Redirect(w, r, "/v1/tea/user/freeze", http.StatusFound)
+}
+
+// FileTeaTeamFreezeAppeal POST /v1/tea/team/freeze/appeal?team_id= 团队核心成员对冻结的团队星茶账户提出申诉
+func FileTeaTeamFreezeAppeal(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	team, err := teaAccountTeam(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	isCoreMember, err := team.IsCoreMember(s_u.Id)
+	if err != nil || !isCoreMember {
+		report(w, s_u, "你好，只有团队核心成员可以为团队星茶账户提出申诉。")
+		return
+	}
+	if !fileTeaFreezeAppeal(w, r, s_u, dao.TeaTeamLedgerAccount(team.Id)) {
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/v1/tea/team/freeze?team_id=%d", team.Id), http.StatusFound)
+}
+
+// fileTeaFreezeAppeal 提交申诉，出错时已输出提示页面并返回 false
+func fileTeaFreezeAppeal(w http.ResponseWriter, r *http.Request, s_u dao.User, account dao.TeaLedgerAccount) bool {
+	evidenceId, err := teaFreezeEvidenceId(r, r.PostFormValue("evidence_uuid"))
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return false
+	}
+	appeal, err := dao.FileTeaFreezeAppeal(r.Context(), account, s_u.Id, r.PostFormValue("statement"), evidenceId)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot file tea freeze appeal", account, err)
+		report(w, s_u, "你好，申诉未能提交："+err.Error()+"。")
+		return false
+	}
+	util.InfoContext(r.Context(), " tea freeze appeal filed", appeal.Uuid, account, s_u.Id)
+	return true
+}
+
+// HandleTeaFreezeAppeals GET /v1/tea/freeze/appeals 待审核的冻结申诉
+func HandleTeaFreezeAppeals(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	appeals, err := dao.PendingTeaFreezeAppeals(r.Context())
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get pending tea freeze appeals", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取待审核申诉，请稍后再试。")
+		return
+	}
+	pageData := dao.TeaFreezeAppealsPageData{SessUser: s_u, Appeals: appeals}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.freeze.appeals")
+}
+
+// DecideTeaFreezeAppeal POST /v1/tea/freeze/appeal/decide 审核冻结申诉
+func DecideTeaFreezeAppeal(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	var approve bool
+	switch r.PostFormValue("decision") {
+	case "approve":
+		approve = true
+	case "reject":
+	default:
+		report(w, s_u, "你好，请选择批准或驳回。")
+		return
+	}
+	appeal, err := dao.DecideTeaFreezeAppeal(r.Context(), uuid, s_u.Id, approve, r.PostFormValue("note"))
+	if err != nil {
+		if !errors.Is(err, dao.ErrTeaFreezeAppealDecided) && !errors.Is(err, dao.ErrTeaFreezeAppealSelfReview) {
+			util.ErrorContext(r.Context(), " cannot decide tea freeze appeal", uuid, err)
+		}
+		report(w, s_u, "你好，申诉审核未完成："+err.Error()+"。")
+		return
+	}
+	util.InfoContext(r.Context(), " tea freeze appeal decided", uuid, appeal.Status, s_u.Id)
+	http.Redirect(w, r, "/v1/tea/freeze/appeals", http.StatusFound)
+}
@@ -0,0 +1,371 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	dao "teachat/DAO"
+	util "teachat/Util"
+)
+
+/*
+茶庄星茶兑换（购买、兑现）：
+1、茶友或团队核心成员：GET /v1/tea/exchange/page[?team_id=] 查看订单并下单；
+   POST /v1/tea/exchange/purchase、/v1/tea/exchange/redeem 下单，/v1/tea/exchange/cancel 撤回，/v1/tea/exchange/sync 刷新付款状态
+2、茶博士/船长：GET /v1/tea/exchange/admin 待处理订单及星茶发行总量；
+   POST /v1/tea/exchange/admin/approve、/v1/tea/exchange/admin/reject 审核，/v1/tea/exchange/admin/payout 重试付款，
+   /v1/tea/exchange/admin/refund 重试否决后的退款，
+   /v1/tea/exchange/admin/local_pay 本地模拟渠道模拟到账（仅 local 渠道，且配置启用 TeaPaymentLocalEnabled 时才注册）
+   下单人不能审核自己的订单。
+表单参数：team_id（团队账户时填写）、amount_milligrams、payout_account、uuid、note
+*/
+
+// teaExchangeReturnUrl 兑换页面地址
+func teaExchangeReturnUrl(account dao.TeaLedgerAccount) string {
+	if account.HolderType == dao.TeaAccountHolderType_Team {
+		return "/v1/tea/exchange/page?team_id=" + strconv.Itoa(account.HolderId)
+	}
+	return "/v1/tea/exchange/page"
+}
+
+// teaExchangeHolder 解析 team_id：为空时是当前茶友账户，否则须是团队核心成员
+func teaExchangeHolder(r *http.Request, s_u dao.User) (dao.TeaLedgerAccount, *dao.Team, error) {
+	teamIdStr := r.FormValue("team_id")
+	if teamIdStr == "" {
+		return dao.TeaUserLedgerAccount(s_u.Id), nil, nil
+	}
+	teamId, err := strconv.Atoi(teamIdStr)
+	if err != nil || teamId <= 0 {
+		return dao.TeaLedgerAccount{}, nil, fmt.Errorf("团队ID无效")
+	}
+	if teamId == dao.TeamIdFreelancer {
+		return dao.TeaLedgerAccount{}, nil, fmt.Errorf("自由人团队没有星茶账户")
+	}
+	team, err := dao.GetTeam(teamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team by id", teamId, err)
+		return dao.TeaLedgerAccount{}, nil, fmt.Errorf("团队不存在")
+	}
+	canManage, err := dao.CanUserManageTeamAccount(s_u.Id, teamId)
+	if err != nil || !canManage {
+		return dao.TeaLedgerAccount{}, nil, fmt.Errorf("只有团队核心成员可以为团队购买或兑现星茶")
+	}
+	return dao.TeaTeamLedgerAccount(teamId), &team, nil
+}
+
+// teaExchangeOrderOfUser 按uuid读取订单，并检查当前茶友可以操作该订单所属账户
+func teaExchangeOrderOfUser(r *http.Request, s_u dao.User) (dao.TeaExchangeOrder, error) {
+	order, err := dao.GetTeaExchangeOrderByUuid(r.Context(), r.PostFormValue("uuid"))
+	if err != nil {
+		if !errors.Is(err, dao.ErrTeaExchangeOrderNotFound) {
+			util.DebugContext(r.Context(), "cannot get tea exchange order", r.PostFormValue("uuid"), err)
+		}
+		return order, fmt.Errorf("兑换订单不存在")
+	}
+	switch order.HolderType {
+	case dao.TeaAccountHolderType_User:
+		if order.HolderId == s_u.Id {
+			return order, nil
+		}
+	case dao.TeaAccountHolderType_Team:
+		if canManage, err := dao.CanUserManageTeamAccount(s_u.Id, order.HolderId); err == nil && canManage {
+			return order, nil
+		}
+	}
+	return order, fmt.Errorf("您无权操作这个兑换订单")
+}
+
+// teaExchangeAmount 解析兑换数量（毫克）
+func teaExchangeAmount(r *http.Request) (int64, error) {
+	amount, err := strconv.ParseInt(strings.TrimSpace(r.PostFormValue("amount_milligrams")), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("星茶数量无效")
+	}
+	if _, err = dao.TeaExchangeFen(amount); err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// HandleTeaExchange GET /v1/tea/exchange/page[?team_id=] 茶庄星茶兑换页面
+func HandleTeaExchange(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	account, team, err := teaExchangeHolder(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	pageData := dao.TeaExchangePageData{
+		SessUser:  s_u,
+		Team:      team,
+		Provider:  util.Config.TeaPaymentProvider,
+		PerFen:    dao.TeaMilligramsPerFen,
+		ReturnUrl: teaExchangeReturnUrl(account),
+	}
+	if team == nil {
+		if err = dao.TeaUserEnsureAccountExists(s_u.Id); err != nil {
+			util.DebugContext(r.Context(), "cannot ensure user tea account", s_u.Id, err)
+			report(w, s_u, "你好，茶博士失魂鱼，未能读取星茶账户，请稍后再试。")
+			return
+		}
+		a, err := dao.GetTeaAccountByUserId(s_u.Id)
+		if err != nil {
+			util.DebugContext(r.Context(), "cannot get user tea account", s_u.Id, err)
+			report(w, s_u, "你好，茶博士失魂鱼，未能读取星茶账户，请稍后再试。")
+			return
+		}
+		pageData.Balance, pageData.Locked, pageData.Frozen = a.BalanceMilligrams, a.LockedBalanceMilligrams, a.Status == dao.TeaAccountStatus_Frozen
+	} else {
+		if err = dao.EnsureTeaTeamAccountExists(team.Id); err != nil {
+			util.DebugContext(r.Context(), "cannot ensure team tea account", team.Id, err)
+			report(w, s_u, "你好，茶博士失魂鱼，未能读取团队星茶账户，请稍后再试。")
+			return
+		}
+		a, err := dao.GetTeaTeamAccountByTeamId(team.Id)
+		if err != nil {
+			util.DebugContext(r.Context(), "cannot get team tea account", team.Id, err)
+			report(w, s_u, "你好，茶博士失魂鱼，未能读取团队星茶账户，请稍后再试。")
+			return
+		}
+		pageData.Balance, pageData.Locked, pageData.Frozen = a.BalanceMilligrams, a.LockedBalanceMilligrams, a.Status == dao.TeaTeamAccountStatus_Frozen
+	}
+	pageData.Orders, err = dao.TeaExchangeOrdersByHolder(r.Context(), account, 50)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get tea exchange orders", account, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取兑换订单，请稍后再试。")
+		return
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.exchange")
+}
+
+// HandleTeaExchangePurchase POST /v1/tea/exchange/purchase 下单购买星茶
+func HandleTeaExchangePurchase(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	account, _, err := teaExchangeHolder(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	amount, err := teaExchangeAmount(r)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	if _, err = dao.CreateTeaPurchaseOrder(r.Context(), account, s_u.Id, amount, util.Config.TeaPaymentProvider); err != nil {
+		util.DebugContext(r.Context(), "cannot create tea purchase order", account, amount, err)
+		report(w, s_u, "你好，茶博士未能登记购买订单："+err.Error()+"。")
+		return
+	}
+	http.Redirect(w, r, teaExchangeReturnUrl(account), http.StatusFound)
+}
+
+// HandleTeaExchangeRedeem POST /v1/tea/exchange/redeem 申请兑现星茶
+func HandleTeaExchangeRedeem(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	account, _, err := teaExchangeHolder(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	amount, err := teaExchangeAmount(r)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	payoutAccount := strings.TrimSpace(r.PostFormValue("payout_account"))
+	if payoutAccount == "" || len(payoutAccount) > 255 {
+		report(w, s_u, "你好，请填写有效的收款账户。")
+		return
+	}
+	if _, err = dao.CreateTeaRedeemOrder(r.Context(), account, s_u.Id, amount, util.Config.TeaPaymentProvider, payoutAccount); err != nil {
+		util.DebugContext(r.Context(), "cannot create tea redeem order", account, amount, err)
+		report(w, s_u, "你好，茶博士未能登记兑现申请："+err.Error()+"。")
+		return
+	}
+	http.Redirect(w, r, teaExchangeReturnUrl(account), http.StatusFound)
+}
+
+// HandleTeaExchangeCancel POST /v1/tea/exchange/cancel 下单人撤回订单
+func HandleTeaExchangeCancel(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	order, err := teaExchangeOrderOfUser(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	if err = dao.CancelTeaExchangeOrder(r.Context(), order.Uuid, s_u.Id); err != nil {
+		util.DebugContext(r.Context(), "cannot cancel tea exchange order", order.Uuid, err)
+		report(w, s_u, "你好，未能撤回兑换订单："+err.Error()+"。")
+		return
+	}
+	http.Redirect(w, r, teaExchangeReturnUrl(order.Account()), http.StatusFound)
+}
+
+// HandleTeaExchangeSync POST /v1/tea/exchange/sync 向支付渠道刷新购买订单付款状态
+func HandleTeaExchangeSync(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	order, err := teaExchangeOrderOfUser(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	if _, err = dao.SyncTeaPurchasePayment(r.Context(), order.Uuid); err != nil {
+		util.DebugContext(r.Context(), "cannot sync tea purchase payment", order.Uuid, err)
+		report(w, s_u, "你好，茶博士未能查询付款状态，请稍后再试。")
+		return
+	}
+	http.Redirect(w, r, teaExchangeReturnUrl(order.Account()), http.StatusFound)
+}
+
+// HandleTeaExchangeAdmin GET /v1/tea/exchange/admin 茶博士/船长处理兑换订单页面
+func HandleTeaExchangeAdmin(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	orders, err := dao.TeaExchangeOrdersAwaitingOperator(r.Context())
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get tea exchange orders awaiting operator", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取兑换订单，请稍后再试。")
+		return
+	}
+	supply, err := dao.GetTeaSupplyReport(r.Context())
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get tea supply report", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能统计星茶发行总量，请稍后再试。")
+		return
+	}
+	pageData := dao.TeaExchangeAdminPageData{SessUser: s_u, Orders: orders, Supply: supply, LocalPay: util.Config.TeaPaymentLocalEnabled}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.exchange.admin")
+}
+
+// HandleTeaExchangeApprove POST /v1/tea/exchange/admin/approve 审核通过兑换订单
+func HandleTeaExchangeApprove(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	if _, err := dao.ApproveTeaExchangeOrder(r.Context(), uuid, s_u.Id, strings.TrimSpace(r.PostFormValue("note"))); err != nil {
+		if errors.Is(err, dao.ErrTeaExchangeSelfReview) {
+			report(w, s_u, "你好，"+err.Error()+"，请另一位茶博士审核。")
+			return
+		}
+		util.DebugContext(r.Context(), "cannot approve tea exchange order", uuid, err)
+		report(w, s_u, "你好，兑换订单处理未完成："+err.Error()+"。")
+		return
+	}
+	http.Redirect(w, r, "/v1/tea/exchange/admin", http.StatusFound)
+}
+
+// HandleTeaExchangeReject POST /v1/tea/exchange/admin/reject 否决兑换订单
+func HandleTeaExchangeReject(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	if err := dao.RejectTeaExchangeOrder(r.Context(), uuid, s_u.Id, strings.TrimSpace(r.PostFormValue("note"))); err != nil {
+		if errors.Is(err, dao.ErrTeaExchangeRefundFailed) {
+			util.WarningContext(r.Context(), "tea exchange order rejected but refund failed", uuid, err)
+			report(w, s_u, "你好，"+dao.ErrTeaExchangeRefundFailed.Error()+"。")
+			return
+		}
+		util.DebugContext(r.Context(), "cannot reject tea exchange order", uuid, err)
+		report(w, s_u, "你好，未能否决兑换订单："+err.Error()+"。")
+		return
+	}
+	http.Redirect(w, r, "/v1/tea/exchange/admin", http.StatusFound)
+}
+
+// HandleTeaExchangePayout POST /v1/tea/exchange/admin/payout 重试兑现付款
+func HandleTeaExchangePayout(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	if _, err := dao.PayoutTeaExchangeOrder(r.Context(), uuid); err != nil {
+		util.DebugContext(r.Context(), "cannot payout tea redeem order", uuid, err)
+		report(w, s_u, "你好，兑现付款未成功："+err.Error()+"。")
+		return
+	}
+	http.Redirect(w, r, "/v1/tea/exchange/admin", http.StatusFound)
+}
+
+// HandleTeaExchangeRefund POST /v1/tea/exchange/admin/refund 重试否决后的退款
+func HandleTeaExchangeRefund(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	if _, err := dao.RefundTeaExchangeOrder(r.Context(), uuid); err != nil {
+		util.DebugContext(r.Context(), "cannot refund tea exchange order", uuid, err)
+		report(w, s_u, "你好，退款未成功："+err.Error()+"。")
+		return
+	}
+	http.Redirect(w, r, "/v1/tea/exchange/admin", http.StatusFound)
+}
+
+// HandleTeaExchangeLocalPay POST /v1/tea/exchange/admin/local_pay 本地模拟渠道模拟付款人完成付款
+func HandleTeaExchangeLocalPay(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	order, err := dao.GetTeaExchangeOrderByUuid(r.Context(), r.PostFormValue("uuid"))
+	if err != nil {
+		report(w, s_u, "你好，兑换订单不存在。")
+		return
+	}
+	provider, err := util.GetPaymentProvider(order.Provider)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	local, isLocal := provider.(*util.LocalPaymentProvider)
+	if !isLocal || !order.ProviderReference.Valid {
+		report(w, s_u, "你好，只有本地模拟渠道的收款单可以模拟到账。")
+		return
+	}
+	if err = local.MarkPaid(order.ProviderReference.String); err != nil {
+		util.DebugContext(r.Context(), "cannot mark local payment paid", order.Uuid, err)
+		report(w, s_u, "你好，模拟到账失败："+err.Error()+"。")
+		return
+	}
+	if _, err = dao.SyncTeaPurchasePayment(r.Context(), order.Uuid); err != nil {
+		util.DebugContext(r.Context(), "cannot sync tea purchase payment", order.Uuid, err)
+		report(w, s_u, "你好，茶博士未能查询付款状态，请稍后再试。")
+		return
+	}
+	http.Redirect(w, r, "/v1/tea/exchange/admin", http.StatusFound)
+}
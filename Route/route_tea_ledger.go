@@ -0,0 +1,275 @@
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+星茶交易流水（复式记账分录）查询：
+1、用户查看自己的星茶账户流水：GET /v1/tea/user/transactions/page、/v1/tea/user/transactions/api
+2、团队成员查看团队星茶账户流水：GET /v1/tea/team/transactions/page、/v1/tea/team/transactions/api（参数 team_id）
+3、筛选参数：type（业务类型）、direction（debit转出/credit转入）、from、to（日期，格式2006-01-02，含当日）、page、limit
+*/
+
+// TeaLedgerEntryResponse 交易流水API响应结构体
+type TeaLedgerEntryResponse struct {
+	TransactionUuid  string `json:"transaction_uuid"`
+	Type             string `json:"type"`
+	TypeDisplay      string `json:"type_display"`
+	EntryType        string `json:"entry_type"`
+	Direction        string `json:"direction"`
+	AmountMilligrams int64  `json:"amount_milligrams"`
+	BalanceAfter     int64  `json:"balance_after_milligrams"`
+	CounterpartyType string `json:"counterparty_type"`
+	CounterpartyId   int    `json:"counterparty_id"`
+	CounterpartyName string `json:"counterparty_name"`
+	ReferenceTable   string `json:"reference_table"`
+	ReferenceId      int    `json:"reference_id"`
+	Notes            string `json:"notes"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// parseTeaLedgerFilter 从查询参数解析交易流水筛选条件
+func parseTeaLedgerFilter(r *http.Request) (dao.TeaLedgerFilter, dao.TeaLedgerFilterForm, error) {
+	q := r.URL.Query()
+	form := dao.TeaLedgerFilterForm{
+		Type:      q.Get("type"),
+		Direction: q.Get("direction"),
+		From:      q.Get("from"),
+		To:        q.Get("to"),
+	}
+	filter := dao.TeaLedgerFilter{Type: form.Type, Direction: form.Direction}
+	filter.Page, filter.Limit = getPaginationParams(r)
+
+	if form.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", form.From, time.Local)
+		if err != nil {
+			return filter, form, fmt.Errorf("起始日期格式无效")
+		}
+		filter.From = &from
+	}
+	if form.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", form.To, time.Local)
+		if err != nil {
+			return filter, form, fmt.Errorf("截止日期格式无效")
+		}
+		// 截止日期含当日
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+	return filter, form, filter.Validate()
+}
+
+// teaLedgerPageInfo 计算分页信息
+func teaLedgerPageInfo(filter dao.TeaLedgerFilter, total int) PageInfo {
+	return PageInfo{
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: (total + filter.Limit - 1) / filter.Limit,
+	}
+}
+
+// respondWithTeaLedger 以JSON返回交易流水及分页信息
+func respondWithTeaLedger(w http.ResponseWriter, entries []dao.TeaLedgerEntry, pageInfo PageInfo) {
+	data := make([]TeaLedgerEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		data = append(data, teaLedgerEntryResponse(e))
+	}
+	response := ApiResponse{
+		Success:  true,
+		Message:  "获取交易流水成功",
+		Data:     data,
+		PageInfo: &pageInfo,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// teaLedgerEntryResponse 把记账分录转换为API响应结构体
+func teaLedgerEntryResponse(e dao.TeaLedgerEntry) TeaLedgerEntryResponse {
+	return TeaLedgerEntryResponse{
+		TransactionUuid:  e.TransactionUuid,
+		Type:             e.Type,
+		TypeDisplay:      e.TypeString(),
+		EntryType:        e.EntryType,
+		Direction:        e.Direction,
+		AmountMilligrams: e.AmountMilligrams,
+		BalanceAfter:     e.BalanceAfter,
+		CounterpartyType: e.CounterpartyType,
+		CounterpartyId:   e.CounterpartyId,
+		CounterpartyName: e.CounterpartyName,
+		ReferenceTable:   e.ReferenceTable,
+		ReferenceId:      e.ReferenceId,
+		Notes:            e.Notes,
+		CreatedAt:        e.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// teaAccountTeam 解析 team_id 并检查当前用户是团队成员
+func teaAccountTeam(r *http.Request, s_u dao.User) (dao.Team, error) {
+	teamId, err := strconv.Atoi(r.URL.Query().Get("team_id"))
+	if err != nil || teamId <= 0 {
+		return dao.Team{}, fmt.Errorf("团队ID无效")
+	}
+	if teamId == dao.TeamIdFreelancer {
+		return dao.Team{}, fmt.Errorf("自由人团队没有星茶账户")
+	}
+	team, err := dao.GetTeam(teamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team by id", teamId, err)
+		return dao.Team{}, fmt.Errorf("团队不存在")
+	}
+	isMember, err := dao.IsTeamActiveMember(s_u.Id, teamId)
+	if err != nil || !isMember {
+		return dao.Team{}, fmt.Errorf("您不是该团队成员，无法查看团队星茶账户")
+	}
+	return team, nil
+}
+
+// HandleTeaUserTransactionHistory GET /v1/tea/user/transactions/page 用户星茶交易流水页面
+func HandleTeaUserTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	filter, form, err := parseTeaLedgerFilter(r)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	if err = dao.TeaUserEnsureAccountExists(s_u.Id); err != nil {
+		util.DebugContext(r.Context(), "cannot ensure user tea account", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取星茶账户，请稍后再试。")
+		return
+	}
+	account, err := dao.GetTeaAccountByUserId(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get user tea account", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取星茶账户，请稍后再试。")
+		return
+	}
+	entries, total, err := dao.TeaLedgerHistory(r.Context(), dao.TeaUserLedgerAccount(s_u.Id), filter)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get user tea ledger", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取交易流水，请稍后再试。")
+		return
+	}
+
+	pageData := dao.TeaLedgerPageData{
+		SessUser:      s_u,
+		Balance:       account.BalanceMilligrams,
+		Locked:        account.LockedBalanceMilligrams,
+		StatusDisplay: "正常",
+		Entries:       entries,
+		Types:         dao.TeaLedgerTypes,
+		Filter:        form,
+		PageInfo:      dao.TeaLedgerPager(teaLedgerPageInfo(filter, total)),
+	}
+	if account.Status == dao.TeaAccountStatus_Frozen {
+		pageData.StatusDisplay = "已冻结"
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.user.transactions", "component_tea_ledger")
+}
+
+// GetTeaUserTransactionHistoryAPI GET /v1/tea/user/transactions/api 用户星茶交易流水API
+func GetTeaUserTransactionHistoryAPI(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "请先登录")
+		return
+	}
+	filter, _, err := parseTeaLedgerFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	entries, total, err := dao.TeaLedgerHistory(r.Context(), dao.TeaUserLedgerAccount(s_u.Id), filter)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get user tea ledger", s_u.Id, err)
+		respondWithError(w, http.StatusInternalServerError, "获取交易流水失败")
+		return
+	}
+	respondWithTeaLedger(w, entries, teaLedgerPageInfo(filter, total))
+}
+
+// HandleTeaTeamTransactionHistory GET /v1/tea/team/transactions/page?team_id= 团队星茶交易流水页面
+func HandleTeaTeamTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	team, err := teaAccountTeam(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	filter, form, err := parseTeaLedgerFilter(r)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	account, err := dao.GetTeaTeamAccountByTeamId(team.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team tea account", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取团队星茶账户，请稍后再试。")
+		return
+	}
+	entries, total, err := dao.TeaLedgerHistory(r.Context(), dao.TeaTeamLedgerAccount(team.Id), filter)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team tea ledger", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取团队交易流水，请稍后再试。")
+		return
+	}
+
+	pageData := dao.TeaLedgerPageData{
+		SessUser:      s_u,
+		Team:          &team,
+		Balance:       account.BalanceMilligrams,
+		Locked:        account.LockedBalanceMilligrams,
+		StatusDisplay: "正常",
+		Entries:       entries,
+		Types:         dao.TeaLedgerTypes,
+		Filter:        form,
+		PageInfo:      dao.TeaLedgerPager(teaLedgerPageInfo(filter, total)),
+	}
+	if account.Status == dao.TeaTeamAccountStatus_Frozen {
+		pageData.StatusDisplay = "已冻结"
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.team.transactions", "component_tea_ledger")
+}
+
+// GetTeaTeamTransactionHistoryAPI GET /v1/tea/team/transactions/api?team_id= 团队星茶交易流水API
+func GetTeaTeamTransactionHistoryAPI(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "请先登录")
+		return
+	}
+	team, err := teaAccountTeam(r, s_u)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	filter, _, err := parseTeaLedgerFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	entries, total, err := dao.TeaLedgerHistory(r.Context(), dao.TeaTeamLedgerAccount(team.Id), filter)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team tea ledger", team.Id, err)
+		respondWithError(w, http.StatusInternalServerError, "获取团队交易流水失败")
+		return
+	}
+	respondWithTeaLedger(w, entries, teaLedgerPageInfo(filter, total))
+}
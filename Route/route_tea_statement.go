@@ -0,0 +1,183 @@
+package route
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+星茶账户对账单：期初余额、期间每笔流水（含对方名称）、期末余额。
+1、用户：GET /v1/tea/user/statement
+2、团队成员：GET /v1/tea/team/statement?team_id=
+参数 from、to（日期，格式2006-01-02，含当日，缺省为本月1日至今日），
+format：html（默认，可打印或另存为PDF）、csv、json，后两者以附件下载。
+*/
+
+const teaStatementDateLayout = "2006-01-02"
+
+// TeaStatementResponse 对账单JSON导出结构体
+type TeaStatementResponse struct {
+	HolderType     string                   `json:"holder_type"`
+	HolderId       int                      `json:"holder_id"`
+	HolderName     string                   `json:"holder_name"`
+	From           string                   `json:"from"`
+	To             string                   `json:"to"`
+	OpeningBalance int64                    `json:"opening_balance_milligrams"`
+	TotalCredit    int64                    `json:"total_credit_milligrams"`
+	TotalDebit     int64                    `json:"total_debit_milligrams"`
+	ClosingBalance int64                    `json:"closing_balance_milligrams"`
+	Balanced       bool                     `json:"balanced"`
+	Lines          []TeaLedgerEntryResponse `json:"lines"`
+	GeneratedAt    string                   `json:"generated_at"`
+}
+
+// parseTeaStatementPeriod 解析对账单期间，返回 [from, to)
+func parseTeaStatementPeriod(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.ParseInLocation(teaStatementDateLayout, v, time.Local); err != nil {
+			return from, to, fmt.Errorf("起始日期格式无效")
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.ParseInLocation(teaStatementDateLayout, v, time.Local); err != nil {
+			return from, to, fmt.Errorf("截止日期格式无效")
+		}
+	}
+	// 截止日期含当日
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("起始日期不能晚于截止日期")
+	}
+	return from, to, nil
+}
+
+// HandleTeaUserStatement GET /v1/tea/user/statement 用户星茶账户对账单
+func HandleTeaUserStatement(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	from, to, err := parseTeaStatementPeriod(r)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	statement, err := dao.GetTeaStatement(r.Context(), dao.TeaUserLedgerAccount(s_u.Id), s_u.Name, from, to)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get tea user statement", s_u.Id, err)
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	writeTeaStatement(w, r, s_u, statement)
+}
+
+// HandleTeaTeamStatement GET /v1/tea/team/statement?team_id= 团队星茶账户对账单
+func HandleTeaTeamStatement(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	team, err := teaAccountTeam(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	from, to, err := parseTeaStatementPeriod(r)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	statement, err := dao.GetTeaStatement(r.Context(), dao.TeaTeamLedgerAccount(team.Id), team.Name, from, to)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get tea team statement", team.Id, err)
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	writeTeaStatement(w, r, s_u, statement)
+}
+
+// writeTeaStatement 按 format 参数输出对账单
+func writeTeaStatement(w http.ResponseWriter, r *http.Request, s_u dao.User, st dao.TeaStatement) {
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+teaStatementFilename(st, "csv")+`"`)
+		// UTF-8 BOM，便于电子表格软件识别中文
+		w.Write([]byte("\xEF\xBB\xBF"))
+		if err := writeTeaStatementCSV(w, st); err != nil {
+			util.DebugContext(r.Context(), "cannot write tea statement csv", st.Account, err)
+		}
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+teaStatementFilename(st, "json")+`"`)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(teaStatementResponse(st)); err != nil {
+			util.DebugContext(r.Context(), "cannot write tea statement json", st.Account, err)
+		}
+	case "", "html":
+		generateHTML(w, &dao.TeaStatementPageData{SessUser: s_u, Statement: st}, "tea.statement")
+	default:
+		report(w, s_u, "你好，对账单格式只支持 html、csv、json。")
+	}
+}
+
+// teaStatementFilename 下载文件名，例如 tea-statement-t12-20260101-20260131.csv
+func teaStatementFilename(st dao.TeaStatement, ext string) string {
+	return fmt.Sprintf("tea-statement-%s%d-%s-%s.%s", st.Account.HolderType, st.Account.HolderId,
+		st.From.Format("20060102"), st.LastDay().Format("20060102"), ext)
+}
+
+// writeTeaStatementCSV 首行期初余额、末行期末余额，中间每行一条流水；对方名称、说明按文本写入，不会被当作公式
+func writeTeaStatementCSV(w http.ResponseWriter, st dao.TeaStatement) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"时间", "交易编号", "类型", "对方", "转入(毫克)", "转出(毫克)", "余额(毫克)", "说明"})
+	cw.Write([]string{st.From.Format("2006-01-02 15:04:05"), "", "期初余额", "", "", "", strconv.FormatInt(st.OpeningBalance, 10), st.HolderTypeString() + " " + st.HolderName})
+	for _, e := range st.Lines {
+		credit, debit := "", ""
+		if e.IsCredit() {
+			credit = strconv.FormatInt(e.AmountMilligrams, 10)
+		} else {
+			debit = strconv.FormatInt(e.AmountMilligrams, 10)
+		}
+		cw.Write([]string{e.CreatedAt.Format("2006-01-02 15:04:05"), e.TransactionUuid, e.TypeString(), dao.TeaStatementCSVCell(e.CounterpartyName),
+			credit, debit, strconv.FormatInt(e.BalanceAfter, 10), dao.TeaStatementCSVCell(e.Notes)})
+	}
+	cw.Write([]string{st.LastDay().Format("2006-01-02") + " 23:59:59", "", "期末余额", "",
+		strconv.FormatInt(st.TotalCredit, 10), strconv.FormatInt(st.TotalDebit, 10), strconv.FormatInt(st.ClosingBalance, 10), ""})
+	cw.Flush()
+	return cw.Error()
+}
+
+func teaStatementResponse(st dao.TeaStatement) TeaStatementResponse {
+	lines := make([]TeaLedgerEntryResponse, 0, len(st.Lines))
+	for _, e := range st.Lines {
+		lines = append(lines, teaLedgerEntryResponse(e))
+	}
+	return TeaStatementResponse{
+		HolderType:     st.Account.HolderType,
+		HolderId:       st.Account.HolderId,
+		HolderName:     st.HolderName,
+		From:           st.From.Format(teaStatementDateLayout),
+		To:             st.LastDay().Format(teaStatementDateLayout),
+		OpeningBalance: st.OpeningBalance,
+		TotalCredit:    st.TotalCredit,
+		TotalDebit:     st.TotalDebit,
+		ClosingBalance: st.ClosingBalance,
+		Balanced:       st.Balanced(),
+		Lines:          lines,
+		GeneratedAt:    st.GeneratedAt.Format("2006-01-02 15:04:05"),
+	}
+}
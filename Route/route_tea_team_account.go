@@ -2,6 +2,7 @@ package route
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -23,7 +24,8 @@ type TeamTeaAccountResponse struct {
 
 // 团队星茶账户解冻请求结构体
 type UnfreezeTeamAccountRequest struct {
-	TeamId int `json:"team_id"`
+	TeamId int    `json:"team_id"`
+	Reason string `json:"reason"` // 可选，解冻说明
 }
 
 // TeaTeamAccountWithAvailable 用于模板渲染，包含可用余额
@@ -249,8 +251,10 @@ func FreezeTeaTeamAccountAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type Request struct {
-		TeamId int    `json:"team_id"`
-		Reason string `json:"reason"`
+		TeamId            int    `json:"team_id"`
+		Reason            string `json:"reason"`
+		EvidenceUuid      string `json:"evidence_uuid"`       // 可选，相关凭据
+		AutoUnfreezeHours int    `json:"auto_unfreeze_hours"` // 可选，到期自动解冻
 	}
 
 	var req Request
@@ -282,15 +286,13 @@ func FreezeTeaTeamAccountAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取账户并冻结
-	account, err := dao.GetTeaTeamAccountByTeamId(req.TeamId)
+	freezeReq, err := teaFreezeRequest(r, user.Id, req.Reason, req.EvidenceUuid, req.AutoUnfreezeHours)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "团队账户不存在")
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	err = account.UpdateStatus(dao.TeaTeamAccountStatus_Frozen, req.Reason)
-	if err != nil {
+	if _, err = dao.FreezeTeaAccount(r.Context(), dao.TeaTeamLedgerAccount(req.TeamId), freezeReq); err != nil {
+		util.ErrorContext(r.Context(), " cannot freeze tea team account", req.TeamId, err)
 		respondWithError(w, http.StatusInternalServerError, "冻结账户失败")
 		return
 	}
@@ -341,15 +343,14 @@ func UnfreezeTeaTeamAccountAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取账户并解冻
-	account, err := dao.GetTeaTeamAccountByTeamId(req.TeamId)
-	if err != nil {
-		respondWithError(w, http.StatusNotFound, "团队账户不存在")
+	_, err = dao.UnfreezeTeaAccount(r.Context(), dao.TeaTeamLedgerAccount(req.TeamId),
+		dao.TeaUnfreezeRequest{OperatorUserId: user.Id, Source: dao.TeaFreezeSource_Manual, Reason: req.Reason})
+	if errors.Is(err, dao.ErrTeaAccountNotFrozen) {
+		respondWithError(w, http.StatusBadRequest, "团队账户未冻结")
 		return
 	}
-
-	err = account.UpdateStatus(dao.TeaTeamAccountStatus_Normal, "")
 	if err != nil {
+		util.ErrorContext(r.Context(), " cannot unfreeze tea team account", req.TeamId, err)
 		respondWithError(w, http.StatusInternalServerError, "解冻账户失败")
 		return
 	}
@@ -615,11 +616,15 @@ func ApproveTeaTeamToUserTransferAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = dao.TeaTeamApproveToUserTransferOut(req.FromTeamId, req.TransferUuid, user.Id)
+	remaining, err := dao.TeaTeamApproveToUserTransferOut(req.FromTeamId, req.TransferUuid, user.Id)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if remaining > 0 {
+		respondWithSuccess(w, fmt.Sprintf("已记录您的批准，按团队审批策略还需 %d 位核心成员批准", remaining), map[string]int{"remaining_approvals": remaining})
+		return
+	}
 
 	respondWithSuccess(w, "团队对用户转账审批通过", nil)
 }
@@ -666,11 +671,15 @@ func ApproveTeaTeamToTeamTransferAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = dao.TeaTeamApproveToTeamTransferOut(req.FromTeamId, req.TransferUuid, user.Id)
+	remaining, err := dao.TeaTeamApproveToTeamTransferOut(req.FromTeamId, req.TransferUuid, user.Id)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if remaining > 0 {
+		respondWithSuccess(w, fmt.Sprintf("已记录您的批准，按团队审批策略还需 %d 位核心成员批准", remaining), map[string]int{"remaining_approvals": remaining})
+		return
+	}
 
 	respondWithSuccess(w, "团队对团队转账审批通过", nil)
 }
@@ -1046,6 +1055,13 @@ func GetTeaTeamPendingApproveToTeamTransfers(w http.ResponseWriter, r *http.Requ
 		IsExpired     bool
 		CanApprove    bool
 		TimeRemaining string
+		TeaTeamApprovalProgress
+	}
+	policy, err := dao.GetTeaTeamTransferPolicy(teamId)
+	if err != nil {
+		util.Debug("cannot get team transfer policy", teamId, err)
+		respondWithError(w, http.StatusInternalServerError, "获取团队转出审批策略失败")
+		return
 	}
 	var enhancedTransfers []EnhancedPendingApproveTransfer
 	for _, t := range transfers {
@@ -1056,6 +1072,7 @@ func GetTeaTeamPendingApproveToTeamTransfers(w http.ResponseWriter, r *http.Requ
 			TeaTeamToTeamTransferOut: t,
 			IsExpired:                nowUTC.After(expiresAtUTC),
 			CanApprove:               !expiresAtUTC.Before(nowUTC),
+			TeaTeamApprovalProgress:  teaTeamApprovalProgress(policy, dao.TeaTeamTransferTable_ToTeam, t.Id, t.AmountMilligrams, t.InitiatorUserId, user.Id),
 		}
 
 		if enhanced.CanApprove {
@@ -1178,6 +1195,13 @@ func GetTeaTeamPendingApproveToUserTransfers(w http.ResponseWriter, r *http.Requ
 		IsExpired     bool
 		CanApprove    bool
 		TimeRemaining string
+		TeaTeamApprovalProgress
+	}
+	policy, err := dao.GetTeaTeamTransferPolicy(teamId)
+	if err != nil {
+		util.Debug("cannot get team transfer policy", teamId, err)
+		respondWithError(w, http.StatusInternalServerError, "获取团队转出审批策略失败")
+		return
 	}
 	var enhancedTransfers []EnhancedPendingApproveTransfer
 	for _, t := range transfers {
@@ -1188,6 +1212,7 @@ func GetTeaTeamPendingApproveToUserTransfers(w http.ResponseWriter, r *http.Requ
 			TeaTeamToUserTransferOut: t,
 			IsExpired:                nowUTC.After(expiresAtUTC),
 			CanApprove:               !expiresAtUTC.Before(nowUTC),
+			TeaTeamApprovalProgress:  teaTeamApprovalProgress(policy, dao.TeaTeamTransferTable_ToUser, t.Id, t.AmountMilligrams, t.InitiatorUserId, user.Id),
 		}
 
 		if enhanced.CanApprove {
@@ -2210,3 +2235,31 @@ func GetTeaTeamFromUserExpiredTransfers(w http.ResponseWriter, r *http.Request)
 
 	generateHTML(w, &pageData, "layout", "navbar.private", "tea.team.from_user_expired_transfers")
 }
+
+// TeaTeamApprovalProgress 待审批团队转出的签批进度
+type TeaTeamApprovalProgress struct {
+	Approvals         int  // 已批准人数
+	RequiredApprovals int  // 按团队策略须批准人数
+	ApprovedByMe      bool // 当前核心成员已批准
+	SelfApprovalBlock bool // 当前核心成员是发起人且团队策略禁止自批
+}
+
+// teaTeamApprovalProgress 按团队转出审批策略计算一笔待审批转账的签批进度
+func teaTeamApprovalProgress(policy dao.TeaTeamTransferPolicy, transferTable string, transferId int, amountMg int64, initiatorUserId, userId int) TeaTeamApprovalProgress {
+	progress := TeaTeamApprovalProgress{
+		RequiredApprovals: policy.RequiredApprovals(amountMg),
+		SelfApprovalBlock: policy.ForbidSelfApproval && initiatorUserId == userId,
+	}
+	approvals, err := dao.TeaTeamTransferApprovals(transferTable, transferId)
+	if err != nil {
+		util.Debug("cannot get team transfer approvals", transferTable, transferId, err)
+		return progress
+	}
+	progress.Approvals = len(approvals)
+	for _, a := range approvals {
+		if a.ApproverUserId == userId {
+			progress.ApprovedByMe = true
+		}
+	}
+	return progress
+}
@@ -0,0 +1,149 @@
+package route
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+团队星茶转出审批策略：
+1、团队成员查看：GET /v1/tea/team/transfer_policy?team_id=
+2、团队CEO或创建人修改：POST /v1/tea/team/transfer_policy/save，表单参数 team_id、large_amount_threshold_milligrams、
+   large_amount_approvals、forbid_self_approval、daily_outflow_cap_milligrams；
+   收紧立即生效，放宽等待 TeaTeamTransferPolicyLoosenHours 小时后生效，修改记录团队成员都能看到
+*/
+
+// 策略页面列出的修改记录条数
+const teaTeamTransferPolicyChangeListLimit = 20
+
+// teaTeamPolicyManager 只有CEO或仍是核心成员的创建人可以修改转出审批策略，普通核心成员不能为自己的转账放宽规则
+func teaTeamPolicyManager(team *dao.Team, userId int, isCoreMember bool) bool {
+	if team.FounderId == userId && isCoreMember {
+		return true
+	}
+	ceo, err := team.MemberCEO()
+	return err == nil && ceo.UserId == userId
+}
+
+// HandleTeaTeamTransferPolicy GET /v1/tea/team/transfer_policy?team_id= 团队转出审批策略页面
+func HandleTeaTeamTransferPolicy(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	team, err := teaAccountTeam(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	policy, err := dao.GetTeaTeamTransferPolicy(team.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team transfer policy", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取团队转出审批策略，请稍后再试。")
+		return
+	}
+	coreMembers, err := team.CoreMembers()
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team core members", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取团队核心成员，请稍后再试。")
+		return
+	}
+	isCoreMember, err := team.IsCoreMember(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot check team core member", team.Id, s_u.Id, err)
+	}
+	changes, err := dao.TeaTeamTransferPolicyChanges(team.Id, teaTeamTransferPolicyChangeListLimit)
+	if err != nil {
+		util.ErrorContext(r.Context(), "cannot get team transfer policy changes", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取团队转出审批策略的修改记录，请稍后再试。")
+		return
+	}
+	pageData := dao.TeaTeamTransferPolicyPageData{
+		SessUser:     s_u,
+		Team:         team,
+		Policy:       policy,
+		CoreMembers:  len(coreMembers),
+		IsCoreMember: isCoreMember,
+		CanEdit:      teaTeamPolicyManager(&team, s_u.Id, isCoreMember),
+		MaxApprovals: dao.TeaTeamTransferMaxApprovals,
+		LoosenHours:  util.Config.TeaTeamTransferPolicyLoosenHours,
+		Changes:      changes,
+	}
+	for i := range changes {
+		if changes[i].Pending() {
+			pageData.Pending = &changes[i]
+			break
+		}
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.team.transfer_policy")
+}
+
+// SaveTeaTeamTransferPolicy POST /v1/tea/team/transfer_policy/save 团队CEO或创建人修改转出审批策略
+func SaveTeaTeamTransferPolicy(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	teamId, err := strconv.Atoi(r.PostFormValue("team_id"))
+	if err != nil || teamId <= 0 || teamId == dao.TeamIdFreelancer {
+		report(w, s_u, "你好，团队ID无效。")
+		return
+	}
+	team, err := dao.GetTeam(teamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team by id", teamId, err)
+		report(w, s_u, "你好，团队不存在。")
+		return
+	}
+	isCoreMember, err := team.IsCoreMember(s_u.Id)
+	if err != nil || !teaTeamPolicyManager(&team, s_u.Id, isCoreMember) {
+		report(w, s_u, "你好，只有团队CEO或创建人可以修改转出审批策略。")
+		return
+	}
+
+	policy := dao.TeaTeamTransferPolicy{
+		TeamId:             teamId,
+		ForbidSelfApproval: r.PostFormValue("forbid_self_approval") == "true",
+		UpdatedByUserId:    s_u.Id,
+	}
+	if policy.LargeAmountThresholdMilligrams, err = strconv.ParseInt(strings.TrimSpace(r.PostFormValue("large_amount_threshold_milligrams")), 10, 64); err != nil {
+		report(w, s_u, "你好，大额阈值须为整数毫克。")
+		return
+	}
+	if policy.LargeAmountApprovals, err = strconv.Atoi(strings.TrimSpace(r.PostFormValue("large_amount_approvals"))); err != nil {
+		report(w, s_u, "你好，大额转出批准人数须为整数。")
+		return
+	}
+	if policy.DailyOutflowCapMilligrams, err = strconv.ParseInt(strings.TrimSpace(r.PostFormValue("daily_outflow_cap_milligrams")), 10, 64); err != nil {
+		report(w, s_u, "你好，每日转出上限须为整数毫克。")
+		return
+	}
+	coreMembers, err := team.CoreMembers()
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team core members", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取团队核心成员，请稍后再试。")
+		return
+	}
+	if err = policy.Validate(len(coreMembers)); err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	requested := policy
+	delay := time.Duration(util.Config.TeaTeamTransferPolicyLoosenHours) * time.Hour
+	scheduled, err := dao.SaveTeaTeamTransferPolicy(&policy, len(coreMembers), delay)
+	if err != nil {
+		util.ErrorContext(r.Context(), "cannot save team transfer policy", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能保存团队转出审批策略，请稍后再试。")
+		return
+	}
+	util.InfoContext(r.Context(), "team transfer policy updated", team.Id, "threshold", requested.LargeAmountThresholdMilligrams,
+		"approvals", requested.LargeAmountApprovals, "forbid_self", requested.ForbidSelfApproval, "daily_cap", requested.DailyOutflowCapMilligrams,
+		"loosening_scheduled", scheduled != nil)
+	http.Redirect(w, r, "/v1/tea/team/transfer_policy?team_id="+strconv.Itoa(team.Id), http.StatusFound)
+}
@@ -0,0 +1,262 @@
+package route
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+团队定期星茶转账：
+1、团队成员查看、新建：GET /v1/tea/team/transfer_schedules?team_id=，POST /v1/tea/team/transfer_schedule/new
+   表单参数 team_id、target_type(user/team)、target_id、amount_milligrams、notes、expire_hours、
+   frequency(once/weekly/monthly)、start_at(2006-01-02T15:04)、end_date(可选，2006-01-02)
+2、查看设定及每次执行记录：GET /v1/tea/team/transfer_schedule?uuid=
+3、设定人或团队核心成员暂停、恢复、取消：POST /v1/tea/team/transfer_schedule/pause|resume|cancel，表单参数 uuid
+到期执行由后台任务 dao.RunDueTeaTeamTransferSchedules 完成，按 TeaTransferScheduleIntervalMinutes 检查。
+*/
+
+const teaTransferScheduleTimeLayout = "2006-01-02T15:04"
+
+var teaTransferScheduleFrequencies = []dao.TeaTransferScheduleOption{
+	{Value: dao.TeaTransferScheduleFrequency_Monthly, Text: "每月"},
+	{Value: dao.TeaTransferScheduleFrequency_Weekly, Text: "每周"},
+	{Value: dao.TeaTransferScheduleFrequency_Once, Text: "一次性"},
+}
+
+// HandleTeaTeamTransferSchedules GET /v1/tea/team/transfer_schedules?team_id= 团队定期转账列表及新建表单
+func HandleTeaTeamTransferSchedules(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	team, err := teaAccountTeam(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	schedules, err := dao.TeaTeamTransferSchedulesByTeam(r.Context(), team.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team transfer schedules", team.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取团队定期转账，请稍后再试。")
+		return
+	}
+	pageData := dao.TeaTeamTransferSchedulesPageData{
+		SessUser:     s_u,
+		Team:         team,
+		Schedules:    schedules,
+		DefaultStart: time.Now().Add(time.Hour).Truncate(time.Hour).Format(teaTransferScheduleTimeLayout),
+		Frequencies:  teaTransferScheduleFrequencies,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.team.transfer_schedules")
+}
+
+// NewTeaTeamTransferSchedule POST /v1/tea/team/transfer_schedule/new 团队成员新建定期转账
+func NewTeaTeamTransferSchedule(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	teamId, err := strconv.Atoi(r.PostFormValue("team_id"))
+	if err != nil || teamId <= 0 || teamId == dao.TeamIdFreelancer {
+		report(w, s_u, "你好，团队ID无效。")
+		return
+	}
+	if _, err = dao.GetTeam(teamId); err != nil {
+		util.DebugContext(r.Context(), "cannot get team by id", teamId, err)
+		report(w, s_u, "你好，团队不存在。")
+		return
+	}
+	isMember, err := dao.IsTeamActiveMember(s_u.Id, teamId)
+	if err != nil || !isMember {
+		report(w, s_u, "你好，只有团队成员才能设定团队定期转账。")
+		return
+	}
+
+	schedule, err := parseTeaTeamTransferScheduleForm(r, teamId)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	schedule.CreatedByUserId = s_u.Id
+	if err = dao.CreateTeaTeamTransferSchedule(r.Context(), &schedule); err != nil {
+		util.DebugContext(r.Context(), "cannot create team transfer schedule", teamId, err)
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	util.InfoContext(r.Context(), fmt.Sprintf("team %d transfer schedule %s created by user %d: %s to %s/%d amount=%d",
+		teamId, schedule.Uuid, s_u.Id, schedule.Frequency, schedule.TargetType, schedule.TargetId, schedule.AmountMilligrams))
+	http.Redirect(w, r, "/v1/tea/team/transfer_schedule?uuid="+schedule.Uuid, http.StatusFound)
+}
+
+// parseTeaTeamTransferScheduleForm 读取新建表单，并确认接收方存在
+func parseTeaTeamTransferScheduleForm(r *http.Request, teamId int) (dao.TeaTeamTransferSchedule, error) {
+	s := dao.TeaTeamTransferSchedule{
+		TeamId:    teamId,
+		Notes:     strings.TrimSpace(r.PostFormValue("notes")),
+		Frequency: r.PostFormValue("frequency"),
+	}
+	var err error
+	if s.TargetId, err = strconv.Atoi(strings.TrimSpace(r.PostFormValue("target_id"))); err != nil || s.TargetId <= 0 {
+		return s, fmt.Errorf("接收方ID无效")
+	}
+	if s.AmountMilligrams, err = strconv.ParseInt(strings.TrimSpace(r.PostFormValue("amount_milligrams")), 10, 64); err != nil {
+		return s, fmt.Errorf("转账数额须为整数毫克")
+	}
+	if s.ExpireHours, err = strconv.Atoi(strings.TrimSpace(r.PostFormValue("expire_hours"))); err != nil {
+		return s, fmt.Errorf("待接收时限须为整数小时")
+	}
+	if s.StartAt, err = time.ParseInLocation(teaTransferScheduleTimeLayout, r.PostFormValue("start_at"), time.Local); err != nil {
+		return s, fmt.Errorf("首次执行时间格式无效")
+	}
+	if endDate := strings.TrimSpace(r.PostFormValue("end_date")); endDate != "" {
+		end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			return s, fmt.Errorf("截止日期格式无效")
+		}
+		// 截止日当天仍可执行
+		end = end.AddDate(0, 0, 1).Add(-time.Second)
+		s.EndAt = &end
+	}
+
+	switch r.PostFormValue("target_type") {
+	case "team":
+		s.TargetType = dao.TeaTransferScheduleTarget_Team
+		if s.TargetId == dao.TeamIdFreelancer {
+			return s, fmt.Errorf("不能向自由人团队转账")
+		}
+		toTeam, err := dao.GetTeam(s.TargetId)
+		if err != nil {
+			util.DebugContext(r.Context(), "cannot get team by id", s.TargetId, err)
+			return s, fmt.Errorf("接收团队不存在")
+		}
+		s.TargetName = toTeam.Name
+	case "user":
+		s.TargetType = dao.TeaTransferScheduleTarget_User
+		toUser, err := dao.GetUser(s.TargetId)
+		if err != nil {
+			util.DebugContext(r.Context(), "cannot get user by id", s.TargetId, err)
+			return s, fmt.Errorf("接收茶友不存在")
+		}
+		s.TargetName = toUser.Name
+	default:
+		return s, fmt.Errorf("接收方类型无效")
+	}
+	return s, s.Validate()
+}
+
+// teaTeamTransferScheduleOf 读取请求中 uuid 指定的定期转账，要求当前茶友是该团队成员
+func teaTeamTransferScheduleOf(r *http.Request, s_u dao.User) (dao.TeaTeamTransferSchedule, dao.Team, error) {
+	uuid := strings.TrimSpace(r.FormValue("uuid"))
+	if uuid == "" {
+		return dao.TeaTeamTransferSchedule{}, dao.Team{}, fmt.Errorf("请指定定期转账")
+	}
+	schedule, err := dao.GetTeaTeamTransferScheduleByUuid(r.Context(), uuid)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team transfer schedule", uuid, err)
+		return schedule, dao.Team{}, dao.ErrTeaTransferScheduleNotFound
+	}
+	team, err := dao.GetTeam(schedule.TeamId)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team by id", schedule.TeamId, err)
+		return schedule, team, fmt.Errorf("团队不存在")
+	}
+	isMember, err := dao.IsTeamActiveMember(s_u.Id, team.Id)
+	if err != nil || !isMember {
+		return schedule, team, fmt.Errorf("您不是该团队成员，无法查看团队定期转账")
+	}
+	return schedule, team, nil
+}
+
+// canManageTeaTeamTransferSchedule 设定人或团队核心成员可以暂停、恢复、取消
+func canManageTeaTeamTransferSchedule(team dao.Team, schedule dao.TeaTeamTransferSchedule, s_u dao.User) bool {
+	if schedule.CreatedByUserId == s_u.Id {
+		return true
+	}
+	isCoreMember, err := team.IsCoreMember(s_u.Id)
+	if err != nil {
+		util.Debug("cannot check team core member", team.Id, s_u.Id, err)
+		return false
+	}
+	return isCoreMember
+}
+
+// HandleTeaTeamTransferSchedule GET /v1/tea/team/transfer_schedule?uuid= 定期转账详情及执行记录
+func HandleTeaTeamTransferSchedule(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	schedule, team, err := teaTeamTransferScheduleOf(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	runs, err := dao.TeaTeamTransferScheduleRuns(r.Context(), schedule.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get team transfer schedule runs", schedule.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取定期转账执行记录，请稍后再试。")
+		return
+	}
+	creator, err := dao.GetUser(schedule.CreatedByUserId)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get user by id", schedule.CreatedByUserId, err)
+	}
+	pageData := dao.TeaTeamTransferSchedulePageData{
+		SessUser:  s_u,
+		Team:      team,
+		Schedule:  schedule,
+		Creator:   creator,
+		Runs:      runs,
+		CanManage: !schedule.Finished() && canManageTeaTeamTransferSchedule(team, schedule, s_u),
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.team.transfer_schedule")
+}
+
+// PauseTeaTeamTransferSchedule POST /v1/tea/team/transfer_schedule/pause 暂停定期转账
+func PauseTeaTeamTransferSchedule(w http.ResponseWriter, r *http.Request) {
+	changeTeaTeamTransferSchedule(w, r, "暂停", dao.PauseTeaTeamTransferSchedule)
+}
+
+// ResumeTeaTeamTransferSchedule POST /v1/tea/team/transfer_schedule/resume 恢复定期转账
+func ResumeTeaTeamTransferSchedule(w http.ResponseWriter, r *http.Request) {
+	changeTeaTeamTransferSchedule(w, r, "恢复", dao.ResumeTeaTeamTransferSchedule)
+}
+
+// CancelTeaTeamTransferSchedule POST /v1/tea/team/transfer_schedule/cancel 取消定期转账
+func CancelTeaTeamTransferSchedule(w http.ResponseWriter, r *http.Request) {
+	changeTeaTeamTransferSchedule(w, r, "取消", dao.CancelTeaTeamTransferSchedule)
+}
+
+func changeTeaTeamTransferSchedule(w http.ResponseWriter, r *http.Request, action string,
+	change func(ctx context.Context, s *dao.TeaTeamTransferSchedule) error) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	schedule, team, err := teaTeamTransferScheduleOf(r, s_u)
+	if err != nil {
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	if !canManageTeaTeamTransferSchedule(team, schedule, s_u) {
+		report(w, s_u, "你好，只有设定人或团队核心成员可以"+action+"定期转账。")
+		return
+	}
+	if err = change(r.Context(), &schedule); err != nil {
+		util.DebugContext(r.Context(), "cannot change team transfer schedule", schedule.Uuid, action, err)
+		report(w, s_u, "你好，"+err.Error()+"。")
+		return
+	}
+	util.InfoContext(r.Context(), fmt.Sprintf("team %d transfer schedule %s %s by user %d, status=%s", team.Id, schedule.Uuid, action, s_u.Id, schedule.Status))
+	http.Redirect(w, r, "/v1/tea/team/transfer_schedule?uuid="+schedule.Uuid, http.StatusFound)
+}
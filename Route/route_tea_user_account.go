@@ -2,6 +2,7 @@ package route
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -20,6 +21,17 @@ type TeaUsrAccountResponse struct {
 	CreatedAt         string `json:"created_at"`
 }
 
+// UserTransferHoldResponse 命中风控规则、待审核的用户对用户转账
+type UserTransferHoldResponse struct {
+	Uuid             string   `json:"uuid"`
+	ToUserId         int      `json:"to_user_id"`
+	ToUserName       string   `json:"to_user_name,omitempty"`
+	AmountMilligrams int64    `json:"amount_milligrams"`
+	Status           string   `json:"status"`
+	Reasons          []string `json:"reasons"`
+	CreatedAt        string   `json:"created_at"`
+}
+
 // 用户对用户转账响应结构体
 type UserToUserTransferOutResponse struct {
 	Uuid             string  `json:"uuid"`
@@ -293,8 +305,10 @@ func FreezeTeaUserAccountAPI(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求体
 	var req struct {
-		UserId int    `json:"user_id"`
-		Reason string `json:"reason"`
+		UserId            int    `json:"user_id"`
+		Reason            string `json:"reason"`
+		EvidenceUuid      string `json:"evidence_uuid"`       // 可选，相关凭据
+		AutoUnfreezeHours int    `json:"auto_unfreeze_hours"` // 可选，到期自动解冻
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondWithError(w, http.StatusBadRequest, "请求格式错误")
@@ -309,16 +323,19 @@ func FreezeTeaUserAccountAPI(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "冻结原因不能为空")
 		return
 	}
-
-	// 获取账户并冻结
-	account, err := dao.GetTeaAccountByUserId(req.UserId)
+	freezeReq, err := teaFreezeRequest(r, user.Id, req.Reason, req.EvidenceUuid, req.AutoUnfreezeHours)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "账户不存在")
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = account.UpdateStatus(dao.TeaAccountStatus_Frozen, req.Reason)
-	if err != nil {
+	// 确认账户存在后冻结
+	if _, err = dao.GetTeaAccountByUserId(req.UserId); err != nil {
+		respondWithError(w, http.StatusBadRequest, "账户不存在")
+		return
+	}
+	if _, err = dao.FreezeTeaAccount(r.Context(), dao.TeaUserLedgerAccount(req.UserId), freezeReq); err != nil {
+		util.ErrorContext(r.Context(), " cannot freeze tea user account", req.UserId, err)
 		respondWithError(w, http.StatusInternalServerError, "冻结账户失败")
 		return
 	}
@@ -342,7 +359,8 @@ func UnfreezeTeaUserAccountAPI(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求体
 	var req struct {
-		UserId int `json:"user_id"`
+		UserId int    `json:"user_id"`
+		Reason string `json:"reason"` // 可选，解冻说明
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondWithError(w, http.StatusBadRequest, "请求格式错误")
@@ -354,15 +372,19 @@ func UnfreezeTeaUserAccountAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取账户并解冻
-	account, err := dao.GetTeaAccountByUserId(req.UserId)
-	if err != nil {
+	// 确认账户存在后解冻
+	if _, err = dao.GetTeaAccountByUserId(req.UserId); err != nil {
 		respondWithError(w, http.StatusBadRequest, "账户不存在")
 		return
 	}
-
-	err = account.UpdateStatus(dao.TeaAccountStatus_Normal, "")
+	_, err = dao.UnfreezeTeaAccount(r.Context(), dao.TeaUserLedgerAccount(req.UserId),
+		dao.TeaUnfreezeRequest{OperatorUserId: user.Id, Source: dao.TeaFreezeSource_Manual, Reason: req.Reason})
+	if errors.Is(err, dao.ErrTeaAccountNotFrozen) {
+		respondWithError(w, http.StatusBadRequest, "账户未冻结")
+		return
+	}
 	if err != nil {
+		util.ErrorContext(r.Context(), " cannot unfreeze tea user account", req.UserId, err)
 		respondWithError(w, http.StatusInternalServerError, "解冻账户失败")
 		return
 	}
@@ -443,13 +465,28 @@ func CreateTeaUserToUserTransferAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 创建转出方用户对用户转账OUT记录
-	transfer, err := dao.CreateTeaUserToUserTransferOut(user.Id, user.Name, toUser.Id, toUser.Name, req.AmountMilligrams, req.Notes, req.ExpireHours)
+	// 按风控规则判定，放行时创建转出方用户对用户转账OUT记录，命中规则时待茶博士审核
+	decision, transfer, err := dao.SubmitTeaUserToUserTransfer(r.Context(), user, toUser, req.AmountMilligrams, req.Notes, req.ExpireHours)
 	if err != nil {
-		util.Debug("CreateTeaUserToUserTransferOut error:", err)
+		util.Debug("SubmitTeaUserToUserTransfer error:", err)
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if transfer == nil {
+		hold := UserTransferHoldResponse{
+			Uuid:             decision.Uuid,
+			ToUserId:         decision.ToUserId,
+			ToUserName:       decision.ToUserName,
+			AmountMilligrams: decision.AmountMilligrams,
+			Status:           decision.ReviewStatus,
+			CreatedAt:        decision.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		for _, h := range decision.Hits() {
+			hold.Reasons = append(hold.Reasons, h.Reason)
+		}
+		respondWithSuccess(w, "转账需茶博士审核，审核通过后才会锁定星茶并通知对方", hold)
+		return
+	}
 
 	response := UserToUserTransferOutResponse{
 		Uuid:             transfer.Uuid,
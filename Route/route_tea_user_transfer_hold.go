@@ -0,0 +1,108 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	dao "teachat/DAO"
+	util "teachat/Util"
+)
+
+/*
+茶友对茶友转账命中风控规则后的待审核转账：
+1、发起人查看、撤回：GET /v1/tea/user/transfer/holds，POST /v1/tea/user/transfer/hold/cancel 字段 uuid；
+2、茶博士/船长审核：GET /v1/tea/transfer/holds 待审核转账、当前规则及最近判定记录，
+   POST /v1/tea/transfer/hold/decide 字段 uuid、decision（approve|reject）、note。
+*/
+
+const (
+	teaUserTransferHoldsPageLimit     = 50
+	teaUserTransferDecisionsPageLimit = 100
+)
+
+// HandleTeaUserTransferHolds GET /v1/tea/user/transfer/holds 本人发起的待审核转账
+func HandleTeaUserTransferHolds(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	holds, err := dao.TeaUserTransferHoldsByUser(r.Context(), s_u.Id, teaUserTransferHoldsPageLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get tea user transfer holds", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取待审核转账，请稍后再试。")
+		return
+	}
+	pageData := dao.TeaUserTransferHoldsPageData{SessUser: s_u, Holds: holds}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.user.transfer_holds")
+}
+
+// CancelTeaUserTransferHold POST /v1/tea/user/transfer/hold/cancel 撤回本人发起的待审核转账
+func CancelTeaUserTransferHold(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	if err := dao.CancelTeaUserTransferHold(r.Context(), uuid, s_u.Id); err != nil {
+		if !errors.Is(err, dao.ErrTeaUserTransferHoldDecided) {
+			util.ErrorContext(r.Context(), " cannot cancel tea user transfer hold", uuid, err)
+		}
+		report(w, s_u, "你好，转账未能撤回："+err.Error()+"。")
+		return
+	}
+	util.InfoContext(r.Context(), " tea user transfer hold cancelled", uuid, s_u.Id)
+	http.Redirect(w, r, "/v1/tea/user/transfer/holds", http.StatusFound)
+}
+
+// HandleTeaTransferHolds GET /v1/tea/transfer/holds 待审核转账及最近的风控判定记录
+func HandleTeaTransferHolds(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	holds, err := dao.PendingTeaUserTransferHolds(r.Context())
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get pending tea user transfer holds", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取待审核转账，请稍后再试。")
+		return
+	}
+	decisions, err := dao.RecentTeaUserTransferDecisions(r.Context(), teaUserTransferDecisionsPageLimit)
+	if err != nil {
+		util.DebugContext(r.Context(), "cannot get recent tea user transfer decisions", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取风控判定记录，请稍后再试。")
+		return
+	}
+	pageData := dao.TeaTransferHoldsAdminPageData{SessUser: s_u, Limits: dao.TeaUserTransferLimitsFromConfig(), Holds: holds, Decisions: decisions}
+	generateHTML(w, &pageData, "layout", "navbar.private", "tea.transfer_holds.admin")
+}
+
+// DecideTeaTransferHold POST /v1/tea/transfer/hold/decide 批准或驳回待审核转账
+func DecideTeaTransferHold(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	var approve bool
+	switch r.PostFormValue("decision") {
+	case "approve":
+		approve = true
+	case "reject":
+	default:
+		report(w, s_u, "你好，请选择批准或驳回。")
+		return
+	}
+	decision, err := dao.DecideTeaUserTransferHold(r.Context(), uuid, s_u.Id, approve, r.PostFormValue("note"))
+	if err != nil {
+		if !errors.Is(err, dao.ErrTeaUserTransferHoldDecided) && !errors.Is(err, dao.ErrTeaUserTransferHoldSelfReview) {
+			util.DebugContext(r.Context(), "cannot decide tea user transfer hold", uuid, err)
+		}
+		report(w, s_u, "你好，转账审核未完成："+err.Error()+"。")
+		return
+	}
+	util.InfoContext(r.Context(), " tea user transfer hold decided", uuid, decision.ReviewStatus, s_u.Id)
+	http.Redirect(w, r, "/v1/tea/transfer/holds", http.StatusFound)
+}
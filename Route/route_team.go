@@ -758,28 +758,35 @@ func TeamDetail(w http.ResponseWriter, r *http.Request) {
 	// 检查用户是否见证者团队成员
 	isVerifier := dao.IsVerifier(s_u.Id)
 	tD.IsVerifierTeamMember = isVerifier
+	tD.AcceptVerdicts = publishedAcceptVerdicts(r, dao.AcceptObjectTypeTeam, team.Id)
 
-	generateHTML(w, &tD, "layout", "navbar.private", "team.detail", "component_avatar_name_gender")
+	generateHTML(w, &tD, "layout", "navbar.private", "team.detail", "component_avatar_name_gender", "component_accept_verdicts")
 
 }
 
 // HandleManageTeam() /v1/team/manage
 func HandleManageTeam(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		ManageTeamIndexGet(w, r)
-	case http.MethodPost:
-		ManageTeamPost(w, r)
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-	}
-
+	ByMethod{
+		http.MethodGet:  ManageTeamIndexGet,
+		http.MethodPost: ManageTeamPost,
+	}.ServeHTTP(w, r)
 }
 
 // POST /v1/team/manage
 // 根据提交的参数，处理管理某支团队事务，例如：注销团队，调整状态等
 func ManageTeamPost(w http.ResponseWriter, r *http.Request) {
-	panic("not implemented")
+	switch r.PostFormValue("action") {
+	case "fire":
+		// 开除、暂停成员
+		MemberFirePost(w, r)
+	default:
+		_, s_u, ok := sessionUser(r)
+		if !ok {
+			http.Redirect(w, r, "/v1/login", http.StatusFound)
+			return
+		}
+		report(w, s_u, "你好，茶博士暂时还不会处理这项茶团事务，请稍后再试。")
+	}
 }
 
 // GET /v1/team/manage?uuid=
@@ -1337,15 +1344,25 @@ func TeamMembersLeft(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 已确认的开除记录
+	dismissals, err := dao.ConfirmedTeamMemberDismissals(r.Context(), team.Id)
+	if err != nil {
+		util.Debug(team.Id, "Cannot get confirmed team member dismissals", err)
+		report(w, s_u, "你好，茶博士正在努力的查找离开成员，请稍后再试。")
+		return
+	}
+
 	type PageData struct {
 		SessUser        dao.User
 		Team            dao.Team
 		LeftMemberSlice []dao.TeamMember
+		Dismissals      []dao.TeamMemberDismissal
 	}
 
 	pageData := PageData{
 		Team:            team,
 		LeftMemberSlice: leftMembers,
+		Dismissals:      dismissals,
 	}
 
 	pageData.SessUser = s_u
@@ -104,7 +104,7 @@ func MemberResignPost(w http.ResponseWriter, r *http.Request) {
 			report(w, s_u, "你好，你不是茶团成员，不接受退出声明噢。")
 			return
 		} else {
-			util.Debug("Cannot get team member by team_id %d and user_id %d", t_team.Id, t_user.Id, err)
+			util.DebugContext(r.Context(), "Cannot get team member by team_id and user_id", t_team.Id, t_user.Id, err)
 			report(w, s_u, "你好，未能获取拟退出的茶团资料，请稍后再试。")
 			return
 		}
@@ -2055,12 +2055,6 @@ func TeamNewApplicationsCheck(w http.ResponseWriter, r *http.Request) {
 
 }
 
-// GET /v1/team_members/fired
-// 显示被开除的成员的表单页面
-func MemberFired(w http.ResponseWriter, r *http.Request) {
-	panic("implement me")
-}
-
 // GET /v1/team_member/resigned?uuid=
 // 团队最高管理员查看本茶团全部退出声明，支持分页
 func TeamMemberResigned(w http.ResponseWriter, r *http.Request) {
@@ -2550,20 +2544,30 @@ func ResignationsReceived(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	// 本人被开除、暂停的记录
+	dismissals, err := dao.TeamMemberDismissalsByMemberUser(r.Context(), s_u.Id)
+	if err != nil {
+		util.Debug("Cannot get team member dismissals by user id", err)
+		report(w, s_u, "你好，茶博士正在忙碌中，稍后再试。")
+		return
+	}
+
 	type PageData struct {
 		SessUser         dao.User
 		ResignationBeans []ResignationBean
+		Dismissals       []dao.TeamMemberDismissal
 		IsEmpty          bool
 	}
 
 	pageData := PageData{
 		SessUser:         s_u,
 		ResignationBeans: resignationBeans,
-		IsEmpty:          len(resignationBeans) == 0,
+		Dismissals:       dismissals,
+		IsEmpty:          len(resignationBeans) == 0 && len(dismissals) == 0,
 	}
 
 	// 渲染页面
-	generateHTML(w, &pageData, "layout", "navbar.private", "team_member.resignations")
+	generateHTML(w, &pageData, "layout", "navbar.private", "team_member.resignations.user")
 }
 
 // GET /v1/applications/team_member
@@ -0,0 +1,302 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	dao "teachat/DAO"
+	util "teachat/Util"
+)
+
+/*
+茶团成员开除、暂停：
+1、GET /v1/team_member/fire?id=&m_email= 核心成员填写开除或暂停某位成员的提议（茶团管理页“移出成员”），
+   POST /v1/team_member/fire 提交，字段 team_id、member_user_id、kind（dismiss|suspend）、suspend_days、reason；
+2、GET /v1/team_member/dismissal/detail?uuid= 提议详情，该成员本人、茶团核心成员和创建人可看，成员本人查看即计为已读；
+   POST 同一地址处理提议，字段 uuid、action（confirm|reject 由CEO，agree|oppose 由其余核心成员表决，withdraw 由提出人，lift 由CEO提前恢复）、note；
+3、GET /v1/team_members/fired?uuid= 茶团全部开除、暂停记录，核心成员和创建人可看；
+已确认的开除另列于离开成员页面，成员本人的记录列于“查看退团”。
+*/
+
+// teamMemberDismissalUserError 可以直接告诉茶友的开除、暂停错误
+func teamMemberDismissalUserError(err error) bool {
+	for _, e := range []error{dao.ErrTeamMemberDismissalNotFound, dao.ErrTeamMemberDismissalReason, dao.ErrTeamMemberDismissalKind,
+		dao.ErrTeamMemberDismissalDays, dao.ErrTeamMemberDismissalSelf, dao.ErrTeamMemberDismissalNotProposer,
+		dao.ErrTeamMemberDismissalTarget, dao.ErrTeamMemberDismissalSuspended, dao.ErrTeamMemberDismissalPending,
+		dao.ErrTeamMemberDismissalDecided, dao.ErrTeamMemberDismissalNotCEO, dao.ErrTeamMemberDismissalByVote,
+		dao.ErrTeamMemberDismissalNotVoter, dao.ErrTeamMemberDismissalVoted, dao.ErrTeamMemberDismissalNotOwner,
+		dao.ErrTeamMemberNotSuspended} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// teamDecisionMakerId 茶团现任CEO的茶友id，CEO空缺时由创建人代行
+func teamDecisionMakerId(team *dao.Team) (int, error) {
+	ceo, err := team.MemberCEO()
+	if err != nil {
+		return 0, err
+	}
+	if ceo.UserId == 0 {
+		return team.FounderId, nil
+	}
+	return ceo.UserId, nil
+}
+
+// /v1/team_member/fire
+func HandleMemberFire(w http.ResponseWriter, r *http.Request) {
+	ByMethod{
+		http.MethodGet:  MemberFireGet,
+		http.MethodPost: MemberFirePost,
+	}.ServeHTTP(w, r)
+}
+
+// GET /v1/team_member/fire?id=&m_email=
+// 核心成员填写开除或暂停某位成员的提议
+func MemberFireGet(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	vals := r.URL.Query()
+	teamId, err := strconv.Atoi(vals.Get("id"))
+	if err != nil || teamId <= 0 {
+		report(w, s_u, "你好，缺少茶团编号，茶博士找不到这个茶团。")
+		return
+	}
+	team, err := dao.GetTeam(teamId)
+	if err != nil {
+		util.DebugContext(r.Context(), teamId, "Cannot get team by id", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到这个茶团，请稍后再试。")
+		return
+	}
+	isCore, err := team.IsCoreMember(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), team.Id, "Cannot check core member", err)
+		report(w, s_u, "你好，茶博士正在忙碌中，稍后再试。")
+		return
+	}
+	if !isCore {
+		report(w, s_u, "你好，只有茶团核心成员才能提出开除或暂停。")
+		return
+	}
+	member, err := dao.GetUserByEmail(vals.Get("m_email"), r.Context())
+	if err != nil {
+		util.DebugContext(r.Context(), "Cannot get user by email", err)
+		report(w, s_u, "你好，茶博士未能找到这位茶友，请确认后再试。")
+		return
+	}
+	if member.Id == s_u.Id {
+		report(w, s_u, "你好，"+dao.ErrTeamMemberDismissalSelf.Error()+"，需要离开可以提交退出声明。")
+		return
+	}
+	teamMember, err := dao.GetMemberByTeamIdUserId(team.Id, member.Id)
+	if err != nil || (teamMember.Status != dao.TeamMemberStatusActive && teamMember.Status != dao.TeamMemberStatusSuspended) {
+		report(w, s_u, "你好，"+dao.ErrTeamMemberDismissalTarget.Error()+"。")
+		return
+	}
+	pageData := dao.MemberFirePageData{
+		SessUser:   s_u,
+		Team:       team,
+		Member:     member,
+		TeamMember: teamMember,
+		IsCEO:      teamMember.Role == dao.RoleCEO,
+		MaxDays:    dao.TeamMemberSuspendMaxDays,
+		ReasonMax:  dao.TeamMemberDismissalReasonMaxLen,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "team.member_fire")
+}
+
+// POST /v1/team_member/fire
+// 提交开除或暂停提议
+func MemberFirePost(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	teamId, err := strconv.Atoi(r.PostFormValue("team_id"))
+	if err != nil || teamId <= 0 {
+		report(w, s_u, "你好，缺少茶团编号，茶博士找不到这个茶团。")
+		return
+	}
+	memberUserId, err := strconv.Atoi(r.PostFormValue("member_user_id"))
+	if err != nil || memberUserId <= 0 {
+		report(w, s_u, "你好，缺少成员编号，茶博士找不到这位成员。")
+		return
+	}
+	suspendDays, _ := strconv.Atoi(r.PostFormValue("suspend_days"))
+	d := dao.TeamMemberDismissal{
+		TeamId:         teamId,
+		MemberUserId:   memberUserId,
+		Kind:           r.PostFormValue("kind"),
+		SuspendDays:    suspendDays,
+		Reason:         r.PostFormValue("reason"),
+		ProposerUserId: s_u.Id,
+	}
+	if err = dao.ProposeTeamMemberDismissal(r.Context(), &d); err != nil {
+		if teamMemberDismissalUserError(err) {
+			report(w, s_u, "你好，"+err.Error()+"。")
+			return
+		}
+		util.ErrorContext(r.Context(), " cannot propose team member dismissal", teamId, memberUserId, err)
+		report(w, s_u, "你好，茶博士失魂鱼，提议未能提交，请稍后再试。")
+		return
+	}
+	util.InfoContext(r.Context(), " team member dismissal proposed", d.Uuid, d.Kind, d.Status, s_u.Id)
+	http.Redirect(w, r, "/v1/team_member/dismissal/detail?uuid="+d.Uuid, http.StatusFound)
+}
+
+// GET /v1/team_members/fired?uuid=
+// 茶团核心成员或创建人查看本茶团全部开除、暂停记录
+func MemberFired(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	team_uuid := r.URL.Query().Get("uuid")
+	team, err := dao.GetTeamByUUID(team_uuid)
+	if err != nil {
+		util.DebugContext(r.Context(), team_uuid, "Cannot get team by uuid", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能找到这个茶团，请稍后再试。")
+		return
+	}
+	if !canManageTeam(&team, s_u, w) {
+		return
+	}
+	dismissals, err := dao.TeamMemberDismissalsByTeam(r.Context(), team.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), team.Id, "Cannot get team member dismissals", err)
+		report(w, s_u, "你好，茶博士正在努力的查找开除与暂停记录，请稍后再试。")
+		return
+	}
+	pageData := dao.TeamMemberDismissalsPageData{SessUser: s_u, Team: team, Dismissals: dismissals}
+	generateHTML(w, &pageData, "layout", "navbar.private", "team_member.dismissals")
+}
+
+// /v1/team_member/dismissal/detail
+func TeamMemberDismissalDetail(w http.ResponseWriter, r *http.Request) {
+	ByMethod{
+		http.MethodGet:  TeamMemberDismissalDetailGet,
+		http.MethodPost: TeamMemberDismissalProcess,
+	}.ServeHTTP(w, r)
+}
+
+// GET /v1/team_member/dismissal/detail?uuid=
+// 查看开除、暂停提议详情
+func TeamMemberDismissalDetailGet(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	d, err := dao.GetTeamMemberDismissalByUuid(r.Context(), r.URL.Query().Get("uuid"))
+	if err != nil {
+		if !errors.Is(err, dao.ErrTeamMemberDismissalNotFound) {
+			util.DebugContext(r.Context(), "Cannot get team member dismissal", err)
+		}
+		report(w, s_u, "你好，茶博士找不到这份开除或暂停记录。")
+		return
+	}
+	team, err := dao.GetTeam(d.TeamId)
+	if err != nil {
+		util.DebugContext(r.Context(), d.TeamId, "Cannot get team by id", err)
+		report(w, s_u, "你好，茶博士正在努力的查找茶团资料，请稍后再试。")
+		return
+	}
+	isMember := d.MemberUserId == s_u.Id
+	isCore, err := team.IsCoreMember(s_u.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), team.Id, "Cannot check core member", err)
+		report(w, s_u, "你好，茶博士正在忙碌中，稍后再试。")
+		return
+	}
+	if !isMember && !isCore && team.FounderId != s_u.Id {
+		report(w, s_u, "你好，开除或暂停记录只有该成员本人和茶团核心成员可以查看。")
+		return
+	}
+	decider, err := teamDecisionMakerId(&team)
+	if err != nil {
+		util.DebugContext(r.Context(), team.Id, "Cannot get CEO", err)
+		report(w, s_u, "你好，茶博士正在忙碌中，稍后再试。")
+		return
+	}
+	votes, err := dao.TeamMemberDismissalVotes(r.Context(), d.Id)
+	if err != nil {
+		util.DebugContext(r.Context(), d.Id, "Cannot get team member dismissal votes", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取表决情况，请稍后再试。")
+		return
+	}
+	voted := false
+	for _, v := range votes {
+		if v.VoterUserId == s_u.Id {
+			voted = true
+			break
+		}
+	}
+
+	if isMember {
+		marked, err := dao.MarkTeamMemberDismissalRead(r.Context(), &d)
+		if err != nil {
+			util.DebugContext(r.Context(), d.Id, "Cannot mark team member dismissal read", err)
+		} else if marked {
+			if err = dao.SubtractUserNotificationCount(s_u.Id); err != nil {
+				util.DebugContext(r.Context(), s_u.Id, "Cannot subtract user notification count", err)
+			}
+		}
+	}
+
+	pageData := dao.TeamMemberDismissalPageData{
+		SessUser:    s_u,
+		Dismissal:   d,
+		Votes:       votes,
+		IsMember:    isMember,
+		CanDecide:   d.IsPending() && !d.ByVote && decider == s_u.Id,
+		CanVote:     d.IsPending() && d.ByVote && isCore && !isMember && !voted,
+		CanWithdraw: d.IsPending() && d.ProposerUserId == s_u.Id,
+		CanLift:     d.IsSuspending() && decider == s_u.Id && !isMember,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "team_member.dismissal.detail")
+}
+
+// POST /v1/team_member/dismissal/detail
+// 确认、否决、表决、撤回开除或暂停提议，或提前恢复暂停中的成员
+func TeamMemberDismissalProcess(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	uuid := r.PostFormValue("uuid")
+	action := r.PostFormValue("action")
+	var d dao.TeamMemberDismissal
+	var err error
+	switch action {
+	case "confirm", "reject":
+		d, err = dao.DecideTeamMemberDismissal(r.Context(), uuid, s_u.Id, action == "confirm", r.PostFormValue("note"))
+	case "agree", "oppose":
+		d, err = dao.VoteTeamMemberDismissal(r.Context(), uuid, s_u.Id, action == "agree")
+	case "withdraw":
+		d, err = dao.WithdrawTeamMemberDismissal(r.Context(), uuid, s_u.Id)
+	case "lift":
+		d, err = dao.LiftTeamMemberSuspension(r.Context(), uuid, s_u.Id)
+	default:
+		report(w, s_u, "你好，无效的操作。")
+		return
+	}
+	if err != nil {
+		if teamMemberDismissalUserError(err) {
+			report(w, s_u, "你好，"+err.Error()+"。")
+			return
+		}
+		util.ErrorContext(r.Context(), " cannot process team member dismissal", uuid, action, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能处理这份提议，请稍后再试。")
+		return
+	}
+	util.InfoContext(r.Context(), " team member dismissal processed", uuid, action, d.Status, s_u.Id)
+	http.Redirect(w, r, "/v1/team_member/dismissal/detail?uuid="+uuid, http.StatusFound)
+}
@@ -0,0 +1,204 @@
+package route
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	dao "teachat/DAO"
+	util "teachat/Util"
+)
+
+/*
+   页面模版缓存。
+   启动时 LoadTemplates 把模版目录下每个文件各自解析一次（html/template，自动按上下文转义），
+   处理器调用 generateHTML 时按文件组合拼装成模版集，首次拼装后缓存，后续请求直接复用。
+   拼装时复制各文件的语法树，因为 html/template 转义时会改写语法树，不能在多个模版集之间共享。
+   开发时将配置 TemplateHotReload 设为 true，每次请求都从磁盘重新读取，修改模版无需重启。
+*/
+
+type templateCache struct {
+	mu    sync.RWMutex
+	ext   string
+	files map[string]*template.Template // 文件名（不含扩展名） -> 单文件解析结果
+	sets  map[string]*template.Template // 文件组合 -> 拼装好的模版集
+}
+
+var pageTemplates = &templateCache{
+	files: map[string]*template.Template{},
+	sets:  map[string]*template.Template{},
+}
+
+// templateFuncs 模版可用的自定义函数，返回 HTML 片段的函数使用 template.HTML 声明为可信内容
+var templateFuncs = template.FuncMap{
+	"GetEnvironmentLevelDescription": dao.GetEnvironmentLevelDescription,
+	"GetStarIcons": func(level int) template.HTML {
+		if level < 1 || level > 5 {
+			return ""
+		}
+		stars := strings.Repeat(`<span class="glyphicon glyphicon-star" style="color: #f39c12;"></span>`, level)
+		return template.HTML(stars)
+	},
+	"RiskSeverityLevelString": dao.RiskSeverityLevelString,
+	"AvailabilityString":      dao.GoodsAvailabilityString,
+	"ledgerTypeString":        dao.TeaLedgerTypeString,
+	"mul": func(a, b int) int {
+		return a * b
+	},
+	"sub": func(a, b int64) int64 {
+		return a - b
+	},
+	"subtract": func(a, b int) int {
+		return a - b
+	},
+	"add": func(a, b int) int {
+		return a + b
+	},
+	"max": func(a, b int) int {
+		if a > b {
+			return a
+		}
+		return b
+	},
+	"min": func(a, b int) int {
+		if a < b {
+			return a
+		}
+		return b
+	},
+	"seq": func(start, end int) []int {
+		var items []int
+		for i := start; i <= end; i++ {
+			items = append(items, i)
+		}
+		return items
+	},
+	"iterate": func(count int) []int {
+		var items []int
+		for i := 0; i < count; i++ {
+			items = append(items, i)
+		}
+		return items
+	},
+	"split": func(s, sep string) []string {
+		return strings.Split(s, sep)
+	},
+	"trim": func(s string) string {
+		return strings.TrimSpace(s)
+	},
+	"RoleName":      dao.TeamMemberRoleName,
+	"GroupRoleName": dao.GroupRoleName,
+	"FormatFloat":   util.FormatFloat,
+}
+
+// LoadTemplates 启动时解析模版目录下全部模版文件，任一文件语法错误即返回错误
+func LoadTemplates() error {
+	return pageTemplates.load(util.Config.TemplatesDir, util.Config.TemplateExt)
+}
+
+func (c *templateCache) load(dir, ext string) error {
+	if dir == "" {
+		dir = "templates"
+	}
+	if ext == "" {
+		ext = ".go.html"
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("模版目录 %s 中没有找到 %s 文件", dir, ext)
+	}
+
+	files := make(map[string]*template.Template, len(paths))
+	for _, p := range paths {
+		t, err := parseTemplateFile(p)
+		if err != nil {
+			return err
+		}
+		files[strings.TrimSuffix(filepath.Base(p), ext)] = t
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ext = ext
+	c.files = files
+	c.sets = map[string]*template.Template{}
+	return nil
+}
+
+func parseTemplateFile(path string) (*template.Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("解析模版 %s 失败: %w", path, err)
+	}
+	return t, nil
+}
+
+// lookup 返回由指定模版文件组合而成的模版集，入口模版为 "layout"
+func (c *templateCache) lookup(filenames ...string) (*template.Template, error) {
+	if util.Config.TemplateHotReload {
+		return c.parseFromDisk(filenames...)
+	}
+
+	key := strings.Join(filenames, ",")
+	c.mu.RLock()
+	set, ok := c.sets[key]
+	loaded := len(c.files) > 0
+	c.mu.RUnlock()
+	if ok {
+		return set, nil
+	}
+	if !loaded {
+		// 未调用 LoadTemplates（例如测试环境），按需加载
+		if err := LoadTemplates(); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if set, ok = c.sets[key]; ok {
+		return set, nil
+	}
+	set = template.New("layout").Funcs(templateFuncs)
+	for _, name := range filenames {
+		file, ok := c.files[name]
+		if !ok {
+			return nil, fmt.Errorf("模版 %s%s 不存在", name, c.ext)
+		}
+		for _, t := range file.Templates() {
+			if t.Tree == nil {
+				continue
+			}
+			if _, err := set.AddParseTree(t.Name(), t.Tree.Copy()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	c.sets[key] = set
+	return set, nil
+}
+
+// parseFromDisk 开发模式：不使用缓存，每次从磁盘读取
+func (c *templateCache) parseFromDisk(filenames ...string) (*template.Template, error) {
+	dir, ext := util.Config.TemplatesDir, util.Config.TemplateExt
+	if dir == "" {
+		dir = "templates"
+	}
+	if ext == "" {
+		ext = ".go.html"
+	}
+	var files []string
+	for _, name := range filenames {
+		files = append(files, filepath.Join(dir, name+ext))
+	}
+	return template.New("layout").Funcs(templateFuncs).ParseFiles(files...)
+}
@@ -1,9 +1,15 @@
 package route
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	dao "teachat/DAO"
 	util "teachat/Util"
+	"time"
 )
 
 // GET /v1/user/biography?uuid=
@@ -178,16 +184,289 @@ func avatarUploadUserGet(w http.ResponseWriter, r *http.Request) {
 	generateHTML(w, &lB, "layout", "navbar.private", "user.avatar_upload")
 }
 
-// GET
-// update Password /Forgot Password?
+// Forgot 找回密码
+// GET /v1/user/forgot 填写注册邮箱页面
+// POST /v1/user/forgot 生成一次性令牌并投递重设密码链接
 func Forgot(w http.ResponseWriter, r *http.Request) {
-	report(w, dao.UserUnknown, "尚未提供修改密码服务！")
+	switch r.Method {
+	case http.MethodGet:
+		generateHTML(w, nil, "layout", "navbar.public", "user.forgot")
+	case http.MethodPost:
+		forgotPost(w, r)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// POST /v1/user/forgot
+// 无论邮箱是否已注册都给出相同提示，避免泄露账号是否存在；
+// 查找账号、生成令牌和投递邮件都在后台进行，两种情况的响应时间和内容一致
+func forgotPost(w http.ResponseWriter, r *http.Request) {
+	s_u := dao.UserUnknown
+	if err := r.ParseForm(); err != nil {
+		util.DebugContext(r.Context(), " Cannot parse form", err)
+		report(w, s_u, "你好，茶博士正在为你服务的路上努力，请稍安勿躁。")
+		return
+	}
+	email := r.PostFormValue("email")
+	if !isEmail(email) {
+		report(w, s_u, "你好，请确认邮箱拼写是否正确。")
+		return
+	}
+	go sendPasswordResetMail(context.WithoutCancel(r.Context()), email)
+	report(w, s_u, "你好，如果该邮箱已经登记，茶博士已把重设密码的链接送到邮箱，请在有效期内使用。")
+}
+
+// sendPasswordResetMail 邮箱已登记时生成重设令牌并投递邮件，未登记时什么也不做；结果只记日志
+func sendPasswordResetMail(ctx context.Context, email string) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	defer func() {
+		if v := recover(); v != nil {
+			util.ErrorContext(ctx, " password reset mail panic", v)
+		}
+	}()
+
+	user, err := dao.GetUserByEmail(email, ctx)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			util.DebugContext(ctx, " Cannot get user by email", email, err)
+		}
+		return
+	}
+	ttl := time.Duration(util.Config.PasswordResetTTLMinutes) * time.Minute
+	token, err := dao.CreatePasswordReset(user.Id, ttl, ctx)
+	if err != nil {
+		util.ErrorContext(ctx, " Cannot create password reset token", user.Id, err)
+		return
+	}
+
+	// 链接只用配置的对外地址拼接，不用请求头里可伪造的 Host
+	link := util.Config.PublicBaseURL + "/v1/user/reset?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("%s 你好：\n\n请在 %d 分钟内打开以下链接重设星际茶棚登船密码（仅可使用一次）：\n%s\n\n如果不是你本人申请，请忽略本邮件。",
+		user.Name, util.Config.PasswordResetTTLMinutes, link)
+	if err = util.SendMail(user.Email, "星际茶棚：重设登船密码", body); err != nil {
+		util.ErrorContext(ctx, " Cannot send password reset mail", user.Id, err)
+	}
 }
 
-// GET
-// Reset Password?
+// Reset 使用找回密码令牌重设密码
+// GET /v1/user/reset?token=xxx 填写新密码页面
+// POST /v1/user/reset 重设密码，并使该用户全部会话失效
 func Reset(w http.ResponseWriter, r *http.Request) {
-	panic("not implemented")
+	switch r.Method {
+	case http.MethodGet:
+		resetGet(w, r)
+	case http.MethodPost:
+		resetPost(w, r)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /v1/user/reset?token=xxx
+func resetGet(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if _, err := dao.GetValidPasswordReset(token, r.Context()); err != nil {
+		if !errors.Is(err, dao.ErrPasswordResetTokenInvalid) {
+			util.Debug(" Cannot check password reset token", err)
+		}
+		report(w, dao.UserUnknown, "你好，重设密码链接无效或者已经过期，请重新申请。")
+		return
+	}
+	data := struct {
+		SessUser dao.User
+		Token    string
+	}{
+		SessUser: dao.UserUnknown,
+		Token:    token,
+	}
+	generateHTML(w, &data, "layout", "navbar.public", "user.reset")
+}
+
+// POST /v1/user/reset
+func resetPost(w http.ResponseWriter, r *http.Request) {
+	s_u := dao.UserUnknown
+	if err := r.ParseForm(); err != nil {
+		util.Debug(" Cannot parse form", err)
+		report(w, s_u, "你好，茶博士正在为你服务的路上努力，请稍安勿躁。")
+		return
+	}
+	token := r.PostFormValue("token")
+	password := r.PostFormValue("password")
+	if msg := checkNewPassword(password, r.PostFormValue("confirm_password")); msg != "" {
+		report(w, s_u, msg)
+		return
+	}
+
+	userId, err := dao.ResetPasswordWithToken(token, password, r.Context())
+	if err != nil {
+		if errors.Is(err, dao.ErrPasswordResetTokenInvalid) {
+			report(w, s_u, "你好，重设密码链接无效或者已经过期，请重新申请。")
+			return
+		}
+		util.Debug(" Cannot reset password", err)
+		report(w, s_u, "你好，茶博士因找不到笔导致重设密码失败，请稍后重试。")
+		return
+	}
+	util.Info("password reset by token, all sessions revoked, user id:", userId)
+
+	clearSessionCookie(w)
+	report(w, s_u, "你好，登船密码已经重设，所有设备的登船状态已失效，请使用新密码重新登船。")
+}
+
+// ChangePassword 已登船茶友修改密码，需经 RequireLogin 注册
+// GET /v1/user/password 修改密码页面
+// POST /v1/user/password 校验当前密码后修改，并使其他设备的会话失效
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	ByMethod{
+		http.MethodGet:  changePasswordGet,
+		http.MethodPost: changePasswordPost,
+	}.ServeHTTP(w, r)
+}
+
+// GET /v1/user/password
+func changePasswordGet(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	var lB dao.LetterboxPageData
+	lB.SessUser = s_u
+	generateHTML(w, &lB, "layout", "navbar.private", "user.password_change")
+}
+
+// POST /v1/user/password
+func changePasswordPost(w http.ResponseWriter, r *http.Request) {
+	s, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		util.Debug(" Cannot parse form", err)
+		report(w, s_u, "你好，茶博士正在为你服务的路上努力，请稍安勿躁。")
+		return
+	}
+
+	// session.User() 不读取密码摘要，需要重新查询
+	user, err := dao.GetUser(s_u.Id)
+	if err != nil {
+		util.Debug(" Cannot get user", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取用户信息！")
+		return
+	}
+	if ok, _, err := dao.VerifyPassword(user.Password, r.PostFormValue("current_password")); !ok {
+		if err != nil {
+			util.Debug(" Cannot verify password", s_u.Id, err)
+		}
+		report(w, s_u, "你好，当前密码不正确，请确认键盘大小写灯是否有亮光？")
+		return
+	}
+	password := r.PostFormValue("password")
+	if msg := checkNewPassword(password, r.PostFormValue("confirm_password")); msg != "" {
+		report(w, s_u, msg)
+		return
+	}
+
+	if err = user.UpdatePassword(password); err != nil {
+		util.Debug(" Cannot update password", s_u.Id, err)
+		report(w, s_u, "你好，茶博士因找不到笔导致修改密码失败，请稍后重试。")
+		return
+	}
+	// 当前设备保持登船，其他设备需要使用新密码重新登船
+	if err = user.DeleteSessionsExcept(s.Uuid); err != nil {
+		util.Debug(" Cannot delete other sessions", s_u.Id, err)
+	}
+	report(w, s_u, "你好，登船密码修改成功，其他设备需要使用新密码重新登船。")
+}
+
+// checkNewPassword 检查新密码长度及两次输入是否一致，返回给用户的提示，合格时返回空字符串
+func checkNewPassword(password, confirm string) string {
+	if len(password) < 6 || len(password) > 64 {
+		return "你好，密码至少6个字符，建议8个字母、数字和英文标点组合。"
+	}
+	if password != confirm {
+		return "你好，两次输入的新密码不一致，请确认后再试。"
+	}
+	return ""
+}
+
+// MySessions 我的登船设备
+// GET /v1/user/sessions 列出全部未过期会话，需经 RequireLogin 注册
+func MySessions(w http.ResponseWriter, r *http.Request) {
+	s, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	sessions, err := s_u.Sessions(r.Context())
+	if err != nil {
+		util.Debug(" Cannot get sessions", s_u.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能读取登船记录，请稍后再试。")
+		return
+	}
+	pageData := dao.UserSessionsPageData{
+		SessUser:        s_u,
+		CurrentDeviceId: s.DeviceId(),
+		Sessions:        sessions,
+	}
+	generateHTML(w, &pageData, "layout", "navbar.private", "user.sessions")
+}
+
+// RevokeSession 注销指定设备的会话
+// POST /v1/user/session/revoke，需经 RequireLogin 注册
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	s, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		util.Debug(" Cannot parse form", err)
+		report(w, s_u, "你好，茶博士正在为你服务的路上努力，请稍安勿躁。")
+		return
+	}
+	deviceId := r.PostFormValue("device")
+	if deviceId == "" {
+		report(w, s_u, "你好，请指定需要注销的设备。")
+		return
+	}
+	revoked, err := s_u.RevokeSession(r.Context(), deviceId)
+	if err != nil {
+		util.Debug(" Cannot revoke session", s_u.Id, err)
+		report(w, s_u, "你好，茶博士因找不到笔导致注销失败，请稍后重试。")
+		return
+	}
+	if revoked == nil {
+		report(w, s_u, "你好，该设备的登船记录已失效或不存在。")
+		return
+	}
+	// 注销的是本设备，同时清除Cookie
+	if revoked.Uuid == s.Uuid {
+		clearSessionCookie(w)
+		http.Redirect(w, r, "/v1/", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/v1/user/sessions", http.StatusFound)
+}
+
+// LogoutEverywhere 所有设备登出
+// POST /v1/user/session/logout_all，需经 RequireLogin 注册
+func LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	_, s_u, ok := sessionUser(r)
+	if !ok {
+		http.Redirect(w, r, "/v1/login", http.StatusFound)
+		return
+	}
+	if err := s_u.DeleteAllSessions(); err != nil {
+		util.Debug(" Cannot delete all sessions", s_u.Id, err)
+		report(w, s_u, "你好，茶博士因找不到笔导致登出失败，请稍后重试。")
+		return
+	}
+	clearSessionCookie(w)
+	http.Redirect(w, r, "/v1/", http.StatusFound)
 }
 
 // GET /v1/users/connection_follow
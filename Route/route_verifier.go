@@ -78,6 +78,19 @@ func VerifierWorkspaceGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	pendingDisputeCount, err := dao.GetPendingDisputeCount(ctx)
+	if err != nil {
+		util.Debug("Cannot get pending dispute count", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能获取待仲裁争议数量。请稍后再试。")
+		return
+	}
+	pendingDisputes, err := dao.GetPendingDisputes(ctx, 0, 20)
+	if err != nil {
+		util.Debug("Cannot get pending disputes", err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能获取待仲裁争议。请稍后再试。")
+		return
+	}
+
 	// 获取各状态的茶订单列表（每页20条）
 	pendingOrders, err := dao.GetTeaOrdersByStatus(ctx, dao.TeaOrderStatusPending, 0, 20)
 	if err != nil && err != sql.ErrNoRows {
@@ -148,6 +161,8 @@ func VerifierWorkspaceGet(w http.ResponseWriter, r *http.Request) {
 		PauseOrderCount:     pauseCount,
 		CancelledOrderCount: cancelledCount,
 		CompletedOrderCount: completedCount,
+		PendingDisputes:     pendingDisputes,
+		PendingDisputeCount: pendingDisputeCount,
 	}
 
 	// 渲染页面
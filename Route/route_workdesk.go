@@ -242,6 +242,20 @@ func HandleTeaOrderDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 获取托管预备金及其争议记录（公道杯）
+	deposits, err := dao.GetTeaOrderDepositsByTeaOrderId(teaOrder.Id)
+	if err != nil {
+		util.Debug("Cannot get deposits for tea order", teaOrder.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能获取托管记录。请稍后再试。")
+		return
+	}
+	disputes, err := dao.GetDisputesByTeaOrderId(r.Context(), teaOrder.Id)
+	if err != nil {
+		util.Debug("Cannot get disputes for tea order", teaOrder.Id, err)
+		report(w, s_u, "你好，茶博士失魂鱼，未能获取争议记录。请稍后再试。")
+		return
+	}
+
 	// 获取来源标识（从哪个页面跳转来的）
 	referer := r.URL.Query().Get("from")
 	teamUuid := r.URL.Query().Get("team_id")
@@ -251,6 +265,8 @@ func HandleTeaOrderDetail(w http.ResponseWriter, r *http.Request) {
 		SessUser     dao.User
 		TeaOrderBean *dao.TeaOrderBean
 		WitnessLogs  []*dao.WitnessLog
+		Deposits     []*dao.TeaOrderDeposit
+		Disputes     []*dao.TeaOrderDepositDispute
 		IsVerifier   bool
 		Referer      string // "workdesk" 或 "verifier" 或空
 		TeamUuid     string // 若从工作台跳转，带回团队UUID用于返回链接
@@ -259,6 +275,8 @@ func HandleTeaOrderDetail(w http.ResponseWriter, r *http.Request) {
 		SessUser:     s_u,
 		TeaOrderBean: teaOrderBean,
 		WitnessLogs:  witnessLogs,
+		Deposits:     deposits,
+		Disputes:     disputes,
 		IsVerifier:   isVerifier,
 		Referer:      referer,
 		TeamUuid:     teamUuid,
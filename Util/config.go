@@ -0,0 +1,371 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/joho/godotenv"
+)
+
+/*
+   配置加载：按 配置文件（config.json）→ 环境变量 → 命令行（-set 键=值）的顺序逐层覆盖，最后补齐默认值。
+
+   环境变量名：字段有 env 标签的用标签（数据库沿用 DB_HOST 等），其余为 TEACHAT_ 加字段名的大写下划线形式，
+   例如 Address → TEACHAT_ADDRESS，SessionIdleHours → TEACHAT_SESSION_IDLE_HOURS。
+   工作目录下的 .env 文件（如存在）会先载入环境变量，已存在的环境变量不会被覆盖。
+   带 secret 标签的字段在 Entries 输出时打码。
+*/
+
+// DatabaseConfig 数据库连接及连接池配置
+type DatabaseConfig struct {
+	Driver   string `env:"DB_DRIVER"`
+	Host     string `env:"DB_HOST"`
+	Port     int    `env:"DB_PORT"`
+	User     string `env:"DB_USER"`
+	Password string `env:"DB_PASSWORD" secret:"true"`
+	Name     string `env:"DB_NAME"`
+	SSLMode  string `env:"DB_SSLMODE"`
+	TimeZone string `env:"DB_TIMEZONE"`
+
+	MaxOpenConns           int `env:"DB_MAX_OPEN_CONNS"`             // 最大连接数，默认25
+	MaxIdleConns           int `env:"DB_MAX_IDLE_CONNS"`             // 最大空闲连接数，默认25
+	ConnMaxLifetimeMinutes int `env:"DB_CONN_MAX_LIFETIME_MINUTES"`  // 连接最长使用时间（分钟），默认5
+	ConnMaxIdleTimeMinutes int `env:"DB_CONN_MAX_IDLE_TIME_MINUTES"` // 连接最长空闲时间（分钟），0表示不限
+}
+
+// DSN lib/pq 的 key=value 连接串
+func (d DatabaseConfig) DSN() string {
+	parts := []string{
+		"host=" + dsnQuote(d.Host),
+		"port=" + strconv.Itoa(d.Port),
+		"user=" + dsnQuote(d.User),
+		"password=" + dsnQuote(d.Password),
+		"dbname=" + dsnQuote(d.Name),
+		"sslmode=" + dsnQuote(d.SSLMode),
+	}
+	if d.TimeZone != "" {
+		parts = append(parts, "TimeZone="+dsnQuote(d.TimeZone))
+	}
+	return strings.Join(parts, " ")
+}
+
+func dsnQuote(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// LoadConfig 读取工作目录下的 config.json 及环境变量，写入全局 Config，并按配置初始化日志、发件箱
+func LoadConfig() error {
+	return Setup("config.json", nil)
+}
+
+// Setup 按指定配置文件及命令行覆盖项加载配置，写入全局 Config，并按配置初始化日志、发件箱及本地模拟支付渠道
+func Setup(path string, overrides []string) error {
+	cfg, err := Load(path, overrides)
+	if err != nil {
+		return err
+	}
+	Config = cfg
+	SetMailer(&OutboxMailer{Path: Config.MailOutbox})
+	if Config.TeaPaymentLocalEnabled {
+		RegisterPaymentProvider(NewLocalPaymentProvider())
+	}
+
+	if err := InitLogger(LogOptions{
+		Level:      Config.LogLevel,
+		Format:     Config.LogFormat,
+		Output:     Config.LogOutput,
+		File:       Config.LogFile,
+		MaxSizeMB:  Config.LogMaxSizeMB,
+		MaxBackups: Config.LogMaxBackups,
+	}); err != nil {
+		return fmt.Errorf("初始化日志失败: %w", err)
+	}
+	return nil
+}
+
+// Load 按 配置文件 → 环境变量 → 命令行覆盖 的顺序合成配置并补齐默认值，不修改全局 Config。
+// path 为空时跳过配置文件；overrides 每项形如 "Database.Host=127.0.0.1"
+func Load(path string, overrides []string) (Configuration, error) {
+	var cfg Configuration
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("打开配置文件失败: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+		// 拼错或已废弃的配置项只提示，不影响启动
+		for _, key := range unknownConfigKeys(data, reflect.TypeOf(cfg), "") {
+			Warningf("配置文件 %s 中的配置项 %s 未被识别，已忽略", path, key)
+		}
+	}
+
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		return cfg, fmt.Errorf("读取 .env 文件失败: %w", err)
+	}
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return cfg, err
+	}
+	for _, kv := range overrides {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return cfg, fmt.Errorf("命令行配置项格式应为 键=值: %s", kv)
+		}
+		if err := cfg.Set(strings.TrimSpace(key), value); err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.applyDefaults()
+	return cfg, nil
+}
+
+// defaultPublicBaseURL 未配置对外地址时按监听地址取本机地址，仅适用于开发环境
+func defaultPublicBaseURL(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "http://localhost"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// unknownConfigKeys 配置文件中没有对应字段的键（与 encoding/json 一样不区分大小写），嵌套结构体按 父.子 列出
+func unknownConfigKeys(data []byte, t reflect.Type, prefix string) []string {
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(data, &raw) != nil {
+		return nil
+	}
+	var unknown []string
+	for key, value := range raw {
+		field, ok := t.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, key) })
+		if !ok {
+			unknown = append(unknown, prefix+key)
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			unknown = append(unknown, unknownConfigKeys(value, field.Type, prefix+field.Name+".")...)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// applyDefaults 补齐默认值并标准化路径
+func (c *Configuration) applyDefaults() {
+	// 路径标准化处理
+	c.ImageDir = filepath.Clean(c.ImageDir) + string(filepath.Separator)
+	c.UserImageDir = filepath.Clean(c.UserImageDir) + string(filepath.Separator)
+	c.TeamImageDir = filepath.Clean(c.TeamImageDir) + string(filepath.Separator)
+	c.TemplatesDir = filepath.Clean(c.TemplatesDir) + string(filepath.Separator)
+
+	c.PublicBaseURL = strings.TrimRight(c.PublicBaseURL, "/")
+	if c.PublicBaseURL == "" {
+		c.PublicBaseURL = defaultPublicBaseURL(c.Address)
+	}
+	if c.PasswordResetTTLMinutes <= 0 {
+		c.PasswordResetTTLMinutes = 30
+	}
+	if c.SessionIdleHours <= 0 {
+		c.SessionIdleHours = 7 * 24
+	}
+	if c.SessionMaxDays <= 0 {
+		c.SessionMaxDays = 30
+	}
+	if c.CookieSameSite == "" {
+		c.CookieSameSite = "Lax"
+	}
+	if c.TeaReconcileIntervalMinutes == 0 {
+		c.TeaReconcileIntervalMinutes = 6 * 60
+	}
+	if c.TeaTransferScheduleIntervalMinutes == 0 {
+		c.TeaTransferScheduleIntervalMinutes = 5
+	}
+	if c.TeaTeamTransferPolicyLoosenHours <= 0 {
+		c.TeaTeamTransferPolicyLoosenHours = 72
+	}
+	if c.AcceptReviewTimeoutHours <= 0 {
+		c.AcceptReviewTimeoutHours = 48
+	}
+	if c.AcceptTrustedReviewerMinScore <= 0 {
+		c.AcceptTrustedReviewerMinScore = 90
+	}
+	if c.AcceptAppealWindowDays == 0 {
+		c.AcceptAppealWindowDays = 30
+	}
+	if c.AcceptAppealMaxRounds == 0 {
+		c.AcceptAppealMaxRounds = 2
+	}
+	if c.IdempotencyKeyRetentionHours <= 0 {
+		c.IdempotencyKeyRetentionHours = 24
+	}
+	if c.TeamInvitationExpireDays == 0 {
+		c.TeamInvitationExpireDays = 14
+	}
+	if c.MemberApplicationExpireDays == 0 {
+		c.MemberApplicationExpireDays = 14
+	}
+	if c.GroupInvitationExpireDays == 0 {
+		c.GroupInvitationExpireDays = 30
+	}
+	if c.FamilySignInExpireDays == 0 {
+		c.FamilySignInExpireDays = 30
+	}
+
+	db := &c.Database
+	if db.Driver == "" {
+		db.Driver = "postgres"
+	}
+	if db.Host == "" {
+		db.Host = "localhost"
+	}
+	if db.Port == 0 {
+		db.Port = 5432
+	}
+	if db.SSLMode == "" {
+		db.SSLMode = "disable"
+	}
+	if db.MaxOpenConns <= 0 {
+		db.MaxOpenConns = 25
+	}
+	if db.MaxIdleConns <= 0 {
+		db.MaxIdleConns = db.MaxOpenConns
+	}
+	if db.ConnMaxLifetimeMinutes <= 0 {
+		db.ConnMaxLifetimeMinutes = 5
+	}
+}
+
+// configField 配置结构体中的一个叶子字段
+type configField struct {
+	Key    string // 点分路径，例如 Database.Host
+	Env    string
+	Secret bool
+	Value  reflect.Value
+}
+
+func (c *Configuration) fields() []configField {
+	var out []configField
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			key := prefix + sf.Name
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			env := sf.Tag.Get("env")
+			if env == "" {
+				env = "TEACHAT_" + upperSnake(strings.ReplaceAll(key, ".", ""))
+			}
+			out = append(out, configField{Key: key, Env: env, Secret: sf.Tag.Get("secret") == "true", Value: v.Field(i)})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return out
+}
+
+func (c *Configuration) applyEnv(lookup func(string) (string, bool)) error {
+	for _, f := range c.fields() {
+		if value, ok := lookup(f.Env); ok {
+			if err := setFieldValue(f.Value, value); err != nil {
+				return fmt.Errorf("环境变量 %s 无效: %w", f.Env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Set 按点分路径（不区分大小写）设置配置项
+func (c *Configuration) Set(key, value string) error {
+	for _, f := range c.fields() {
+		if strings.EqualFold(f.Key, key) {
+			if err := setFieldValue(f.Value, value); err != nil {
+				return fmt.Errorf("配置项 %s 无效: %w", key, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("未知的配置项: %s", key)
+}
+
+func setFieldValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	default:
+		return fmt.Errorf("不支持的类型 %s", v.Kind())
+	}
+	return nil
+}
+
+// ConfigEntry 一条生效的配置
+type ConfigEntry struct {
+	Key   string
+	Env   string
+	Value string
+}
+
+// Entries 全部生效配置，敏感信息打码
+func (c *Configuration) Entries() []ConfigEntry {
+	fields := c.fields()
+	entries := make([]ConfigEntry, 0, len(fields))
+	for _, f := range fields {
+		value := fmt.Sprint(f.Value.Interface())
+		if f.Secret && value != "" {
+			value = "******"
+		}
+		entries = append(entries, ConfigEntry{Key: f.Key, Env: f.Env, Value: value})
+	}
+	return entries
+}
+
+// upperSnake SessionIdleHours → SESSION_IDLE_HOURS，MaxSizeMB → MAX_SIZE_MB
+func upperSnake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -1,11 +1,11 @@
 package util
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
-	"path/filepath"
+	"strings"
 )
 
 /*
@@ -16,12 +16,6 @@ import (
    一些工具函数；
 */
 
-// 初始化日志
-func init() {
-	// 开发模式默认日志配置
-	InitLogger(false, LevelDebug) // 默认控制台输出，Debug级别
-}
-
 // 配置文件结构体
 type Configuration struct {
 	Address                string
@@ -34,9 +28,11 @@ type Configuration struct {
 	ImageExt               string
 	TemplatesDir           string
 	TemplateExt            string
+	TemplateHotReload      bool  // 开发模式：每次请求重新读取模版文件，修改模版无需重启
 	ThreadMinWord          int64 //  茶议最小字数限制
 	ThreadMaxWord          int64 // 茶议最大字数限制
 	PostMinWord            int64 // 品味最小字数限制
+	MaxInviteUsers         int64 // 茶围、茶台最大可邀请茶友数
 	MaxInviteTeams         int64 // 茶围、茶台最大可邀请团队数
 	MaxTeamMembers         int64 // 团队最大成员数
 	MaxTeamsCount          int64 // 个人创建的团队数上限
@@ -44,6 +40,56 @@ type Configuration struct {
 	PoliteMode             bool  // Debug模式(是否启用“友邻蒙评”审茶)
 	DefaultSearchResultNum int64 // 默认搜索结果数
 
+	PublicBaseURL           string // 站点对外访问地址，邮件中的链接以此拼接，例如 https://teachat.example.com；默认按 Address 取 http://localhost:端口
+	PasswordResetTTLMinutes int64  // 找回密码链接有效期（分钟），默认30分钟
+	MailOutbox              string // 本地发件箱文件路径，为空时邮件输出到标准输出（开发环境）
+
+	SessionIdleHours int64  // 会话闲置超时（小时），每次访问顺延，默认168（7天）
+	SessionMaxDays   int64  // 会话自登船起最长有效期（天），默认30
+	CookieSecure     bool   // 会话Cookie仅通过HTTPS发送，生产环境应启用
+	CookieSameSite   string // 会话Cookie的SameSite属性：Lax（默认）、Strict、None
+
+	LogLevel      string // 日志级别：debug（默认）、info、warning、error
+	LogFormat     string // 日志格式：text（默认）、json
+	LogOutput     string // 日志输出：stdout（默认）、file、both
+	LogFile       string // 日志文件路径，默认 teachatWeb.log
+	LogMaxSizeMB  int64  // 日志文件轮转大小（MB），默认10
+	LogMaxBackups int    // 保留的历史日志文件数，默认5
+
+	TeaReconcileIntervalMinutes        int64  // 星茶余额对账间隔（分钟），默认360，负数表示不定期对账
+	TeaReconcileFreeze                 bool   // 定期对账发现差异时冻结相应星茶账户
+	TeaPaymentProvider                 string // 茶庄星茶购买、兑现使用的支付渠道名称，必须配置；local（本地模拟）须同时启用 TeaPaymentLocalEnabled
+	TeaPaymentLocalEnabled             bool   // 开发测试：注册本地模拟支付渠道并开放模拟到账接口，生产环境不要启用
+	TeaTransferScheduleIntervalMinutes int64  // 检查到期团队定期转账的间隔（分钟），默认5，负数表示不执行定期转账
+	TeaTeamTransferPolicyLoosenHours   int64  // 放宽团队转出审批策略的生效等待时间（小时），默认72
+
+	// 茶友对茶友转账风控规则，命中时转账待茶博士审核，0表示不限
+	TeaUserTransferMaxMilligrams           int64 // 单笔上限（毫克）
+	TeaUserTransferDailyCapMilligrams      int64 // 每日累计转出上限（毫克）
+	TeaUserTransferNewAccountDays          int64 // 注册不满该天数的茶友按新账户限额
+	TeaUserTransferNewAccountMaxMilligrams int64 // 新账户单笔上限（毫克）
+	TeaUserTransferRecipientDailyMax       int64 // 每日向同一接收方发起的笔数上限
+	TeaUserTransferConflictCheck           bool  // 双方同在进行中的茶订单且须利益回避时待审核
+
+	AcceptReviewTimeoutHours        int64 // 友邻蒙评评审官答复期限（小时），逾期改派他人，默认48
+	AcceptReviewerLowScore          int64 // 信誉分低于此值的评审官排在其他人选之后，0表示不区分
+	AcceptTrustedReviewerMinReviews int64 // 可信评审官至少已答复的评审数，另一位超时未答复时可独自裁定，0表示不启用
+	AcceptTrustedReviewerMinScore   int64 // 可信评审官的最低信誉分，默认90
+	AcceptAppealWindowDays          int64 // 友邻蒙评婉拒后可申诉的期限（天），默认30
+	AcceptAppealMaxRounds           int64 // 同一对象最多申诉次数，最后一次由茶博士复审，默认2
+
+	IdempotencyKeyRetentionHours int64 // 幂等键及其响应的保留时间（小时），默认24
+
+	// 邀请函、申请书、声明书未答复的有效期（天），从创建或最近续期时起算，负数表示不过期
+	TeamInvitationExpireDays    int64 // 茶团邀请函，默认14
+	MemberApplicationExpireDays int64 // 加盟茶团申请书，默认14
+	GroupInvitationExpireDays   int64 // 集团邀请函，默认30
+	FamilySignInExpireDays      int64 // 家庭成员声明书，默认30
+
+	JobSchedules string // 后台任务计划覆盖，格式 名称=计划;名称=计划，计划为 @every 间隔、五段 cron 表达式或 off，例如 tea_reconcile=0 3 * * *
+
+	Database DatabaseConfig // 数据库连接，通常用环境变量 DB_* 配置
+
 	// SysMail_Username string
 	// SysMail_Password string
 	// SysMail_Host     string
@@ -52,29 +98,6 @@ type Configuration struct {
 
 var Config Configuration
 
-// 读取配置文件内容
-func LoadConfig() error {
-
-	file, err := os.Open("config.json")
-	if err != nil {
-		return fmt.Errorf("打开配置文件失败: %w", err)
-	}
-	defer file.Close() // 确保文件关闭
-
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&Config); err != nil {
-		return fmt.Errorf("解析配置文件失败: %w", err)
-	}
-
-	// 路径标准化处理
-	Config.ImageDir = filepath.Clean(Config.ImageDir) + string(filepath.Separator)
-	Config.UserImageDir = filepath.Clean(Config.UserImageDir) + string(filepath.Separator)
-	Config.TeamImageDir = filepath.Clean(Config.TeamImageDir) + string(filepath.Separator)
-	Config.TemplatesDir = filepath.Clean(Config.TemplatesDir) + string(filepath.Separator)
-
-	return nil
-}
-
 func (c *Configuration) Validate() error {
 	if c.Address == "" {
 		return errors.New("服务器地址不能为空")
@@ -118,6 +141,56 @@ func (c *Configuration) Validate() error {
 	if c.ImageExt == "" {
 		return errors.New("图片扩展名不能为空")
 	}
+	if c.Database.Driver == "" || c.Database.Name == "" {
+		return errors.New("数据库驱动及数据库名不能为空（DB_DRIVER、DB_NAME）")
+	}
+	if c.Database.Port <= 0 || c.Database.Port > 65535 {
+		return fmt.Errorf("数据库端口无效: %d", c.Database.Port)
+	}
+	if c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		return fmt.Errorf("数据库最大空闲连接数(%d)不能大于最大连接数(%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+	switch c.CookieSameSite {
+	case "", "Lax", "Strict":
+	case "None":
+		if !c.CookieSecure {
+			return errors.New("CookieSameSite 为 None 时必须启用 CookieSecure")
+		}
+	default:
+		return fmt.Errorf("CookieSameSite 取值无效: %s（可选 Lax、Strict、None）", c.CookieSameSite)
+	}
+	if c.TeaUserTransferMaxMilligrams < 0 || c.TeaUserTransferDailyCapMilligrams < 0 || c.TeaUserTransferNewAccountDays < 0 ||
+		c.TeaUserTransferNewAccountMaxMilligrams < 0 || c.TeaUserTransferRecipientDailyMax < 0 {
+		return errors.New("茶友转账风控规则的上限、天数不能为负数")
+	}
+	if c.AcceptReviewerLowScore < 0 || c.AcceptReviewerLowScore > 100 || c.AcceptTrustedReviewerMinScore < 0 || c.AcceptTrustedReviewerMinScore > 100 {
+		return errors.New("友邻蒙评评审官信誉分阈值须在0~100之间")
+	}
+	if c.AcceptTrustedReviewerMinReviews < 0 {
+		return errors.New("可信评审官的最少评审数不能为负数")
+	}
+	if c.AcceptAppealWindowDays < 0 || c.AcceptAppealMaxRounds < 0 {
+		return errors.New("友邻蒙评申诉期限、申诉次数不能为负数")
+	}
+	if u, err := url.Parse(c.PublicBaseURL); c.PublicBaseURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		return fmt.Errorf("PublicBaseURL 须是 http 或 https 开头的完整地址: %s", c.PublicBaseURL)
+	}
+	switch c.TeaPaymentProvider {
+	case "":
+		return errors.New("未配置支付渠道 TeaPaymentProvider；开发测试可设为 local 并启用 TeaPaymentLocalEnabled")
+	case LocalPaymentProviderName:
+		if !c.TeaPaymentLocalEnabled {
+			return errors.New("TeaPaymentProvider 为 local（本地模拟，收款可随意模拟到账）时须启用 TeaPaymentLocalEnabled，仅用于开发测试")
+		}
+	default:
+		if _, err := GetPaymentProvider(c.TeaPaymentProvider); err != nil {
+			names := PaymentProviderNames()
+			if len(names) == 0 {
+				return fmt.Errorf("TeaPaymentProvider 取值无效: %s（尚未注册任何支付渠道）", c.TeaPaymentProvider)
+			}
+			return fmt.Errorf("TeaPaymentProvider 取值无效: %s（可选 %s）", c.TeaPaymentProvider, strings.Join(names, "、"))
+		}
+	}
 	return nil
 }
 
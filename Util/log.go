@@ -1,11 +1,25 @@
 package util
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 )
 
+/*
+   日志：基于 log/slog 的分级、结构化日志。
+   级别、格式（text/json）、输出位置（stdout/file/both）及按大小轮转由 config.json 的 Log* 配置项决定，
+   LoadConfig 读取配置后调用 InitLogger；在此之前使用默认的控制台 Debug 级别文本日志。
+
+   Debug/Info/Warning/Error(v...) 把参数用空格连接成一条消息；需要格式化时使用 Debugf 等。
+   带 ctx 的 DebugContext 等会附加 WithLogFields 放入上下文的字段（请求编号、路由、茶友id等）。
+*/
+
 // 日志级别常量
 const (
 	LevelDebug = iota
@@ -14,88 +28,202 @@ const (
 	LevelError
 )
 
+// LogOptions 日志配置
+type LogOptions struct {
+	Level      string // debug、info、warning、error
+	Format     string // text（默认）、json
+	Output     string // stdout（默认）、file、both
+	File       string // 日志文件路径，默认 teachatWeb.log
+	MaxSizeMB  int64  // 单个日志文件达到该大小后轮转，默认10
+	MaxBackups int    // 保留的历史日志文件数，默认5
+}
+
 var (
-	logger      *log.Logger
-	logLevel    int    = LevelDebug // 默认开发模式为Debug级别
-	logToFile   bool   = false      // 默认不输出到文件
-	logFilePath string = "teachatWeb.log"
+	logger  *slog.Logger
+	logFile *rotatingFile
 )
 
-// InitLogger 初始化日志配置
-// writeToFile: 是否写入文件
-// level: 日志级别
-func InitLogger(writeToFile bool, level int) {
-	logToFile = writeToFile
-	logLevel = level
+// 初始化日志
+func init() {
+	// 读取配置前的默认日志配置：控制台输出，Debug级别
+	logger = slog.New(&contextHandler{slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug})})
+}
 
-	if logToFile {
-		file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+// InitLogger 按配置初始化日志，可重复调用（会关闭之前打开的日志文件）
+func InitLogger(opts LogOptions) error {
+	level, err := parseLogLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	if opts.File == "" {
+		opts.File = "teachatWeb.log"
+	}
+	if opts.MaxSizeMB <= 0 {
+		opts.MaxSizeMB = 10
+	}
+	if opts.MaxBackups <= 0 {
+		opts.MaxBackups = 5
+	}
+
+	var out io.Writer
+	var file *rotatingFile
+	switch strings.ToLower(opts.Output) {
+	case "", "stdout":
+		out = os.Stdout
+	case "file", "both":
+		file, err = openRotatingFile(opts.File, opts.MaxSizeMB*1024*1024, opts.MaxBackups)
 		if err != nil {
-			log.Fatalln("Failed to open log file", err)
+			return fmt.Errorf("打开日志文件失败: %w", err)
+		}
+		out = file
+		if strings.EqualFold(opts.Output, "both") {
+			out = io.MultiWriter(os.Stdout, file)
 		}
-		logger = log.New(file, "", log.Ldate|log.Ltime|log.Lshortfile)
-	} else {
-		logger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
+	default:
+		return fmt.Errorf("日志输出位置无效: %s（可选 stdout、file、both）", opts.Output)
 	}
-}
 
-// 带调用位置的基础日志方法
-func logWithCaller(skip int, prefix string, v ...any) {
-	_, file, line, ok := runtime.Caller(skip + 1) // +1 跳过本函数
-	if !ok {
-		file = "???"
-		line = 0
+	handlerOpts := &slog.HandlerOptions{AddSource: true, Level: level}
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		h = slog.NewTextHandler(out, handlerOpts)
+	case "json":
+		h = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		if file != nil {
+			file.Close()
+		}
+		return fmt.Errorf("日志格式无效: %s（可选 text、json）", opts.Format)
 	}
-	//	file = filepath.Base(file)
 
-	logger.SetPrefix(prefix + " ")
-	logger.Printf("%s:%d - %v", file, line, v)
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = file
+	logger = slog.New(&contextHandler{h})
+	slog.SetDefault(logger)
+	return nil
 }
 
-// Debug 开发调试信息
-func Debug(v ...any) {
-	if logLevel <= LevelDebug {
-		logWithCaller(1, "DEBUG", v...)
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "", "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warning", "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
 	}
+	return 0, fmt.Errorf("日志级别无效: %s（可选 debug、info、warning、error）", s)
 }
 
-// Info 常规信息
-func Info(v ...any) {
-	if logLevel <= LevelInfo {
-		logWithCaller(1, "INFO", v...)
-	}
+// Logger 当前的 slog 日志器
+func Logger() *slog.Logger {
+	return logger
 }
 
-// Warning 警告信息
-func Warning(v ...any) {
-	if logLevel <= LevelWarning {
-		logWithCaller(1, "WARNING", v...)
+type logFieldsKey struct{}
+
+// WithLogFields 把字段放入上下文，之后用该上下文记录的日志都会带上这些字段
+func WithLogFields(ctx context.Context, args ...any) context.Context {
+	fields, _ := ctx.Value(logFieldsKey{}).([]slog.Attr)
+	r := slog.Record{}
+	r.Add(args...)
+	merged := make([]slog.Attr, 0, len(fields)+r.NumAttrs())
+	merged = append(merged, fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		merged = append(merged, a)
+		return true
+	})
+	return context.WithValue(ctx, logFieldsKey{}, merged)
+}
+
+// contextHandler 记录日志时附加上下文中的字段
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if fields, ok := ctx.Value(logFieldsKey{}).([]slog.Attr); ok {
+			r.AddAttrs(fields...)
+		}
 	}
+	return h.Handler.Handle(ctx, r)
 }
 
-// Error 错误信息
-func Error(v ...any) {
-	if logLevel <= LevelError {
-		logWithCaller(1, "ERROR", v...)
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
+
+// logAt 记录一条日志，调用位置取自调用 Debug 等函数的代码
+func logAt(ctx context.Context, level slog.Level, msg string) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	// 记录错误日志
-	file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalln("Failed to open log file", err)
+	if !logger.Enabled(ctx, level) {
+		return
 	}
-	logger = log.New(file, "Error", log.Ldate|log.Ltime|log.Lshortfile)
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // 跳过 runtime.Callers、logAt 及 Debug 等导出函数
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// joinArgs 参数之间用空格连接
+func joinArgs(v []any) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
 }
 
+// Debug 开发调试信息
+func Debug(v ...any) { logAt(nil, slog.LevelDebug, joinArgs(v)) }
+
+// Info 常规信息
+func Info(v ...any) { logAt(nil, slog.LevelInfo, joinArgs(v)) }
+
+// Warning 警告信息
+func Warning(v ...any) { logAt(nil, slog.LevelWarn, joinArgs(v)) }
+
+// Error 错误信息
+func Error(v ...any) { logAt(nil, slog.LevelError, joinArgs(v)) }
+
+// Debugf 格式化的开发调试信息
+func Debugf(format string, v ...any) { logAt(nil, slog.LevelDebug, fmt.Sprintf(format, v...)) }
+
+// Infof 格式化的常规信息
+func Infof(format string, v ...any) { logAt(nil, slog.LevelInfo, fmt.Sprintf(format, v...)) }
+
+// Warningf 格式化的警告信息
+func Warningf(format string, v ...any) { logAt(nil, slog.LevelWarn, fmt.Sprintf(format, v...)) }
+
+// Errorf 格式化的错误信息
+func Errorf(format string, v ...any) { logAt(nil, slog.LevelError, fmt.Sprintf(format, v...)) }
+
+// DebugContext 开发调试信息，附加上下文中的日志字段
+func DebugContext(ctx context.Context, v ...any) { logAt(ctx, slog.LevelDebug, joinArgs(v)) }
+
+// InfoContext 常规信息，附加上下文中的日志字段
+func InfoContext(ctx context.Context, v ...any) { logAt(ctx, slog.LevelInfo, joinArgs(v)) }
+
+// WarningContext 警告信息，附加上下文中的日志字段
+func WarningContext(ctx context.Context, v ...any) { logAt(ctx, slog.LevelWarn, joinArgs(v)) }
+
+// ErrorContext 错误信息，附加上下文中的日志字段
+func ErrorContext(ctx context.Context, v ...any) { logAt(ctx, slog.LevelError, joinArgs(v)) }
+
 // Panic 严重错误并退出
 func Panic(v ...any) {
-	logWithCaller(1, "PANIC", v...)
-	// 记录错误日志
-	file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalln("Failed to open log file", err)
+	logAt(nil, slog.LevelError, "PANIC "+joinArgs(v))
+	if logFile != nil {
+		logFile.Close()
 	}
-	logger = log.New(file, "Panic", log.Ldate|log.Ltime|log.Lshortfile)
-
 	// 退出程序
 	os.Exit(1)
 }
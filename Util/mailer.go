@@ -0,0 +1,65 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+   邮件投递：
+   通过 Mailer 接口发送系统邮件（例如找回密码链接），具体投递方式可替换；
+   开发环境默认使用 OutboxMailer，把邮件写入本地文件或标准输出，方便查看。
+*/
+
+// Mailer 系统邮件投递接口
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// OutboxMailer 本地邮件投递：邮件内容追加写入 Path 指定的文件，Path 为空时输出到标准输出
+type OutboxMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Send 将邮件追加写入本地发件箱
+func (m *OutboxMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out io.Writer = os.Stdout
+	if m.Path != "" {
+		file, err := os.OpenFile(m.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("打开发件箱文件失败: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	_, err := fmt.Fprintf(out, "----- %s -----\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format("2006-01-02 15:04:05"), to, subject, body)
+	return err
+}
+
+var (
+	mailerMu sync.RWMutex
+	mailer   Mailer = &OutboxMailer{}
+)
+
+// SetMailer 替换系统邮件投递方式（例如接入SMTP服务）
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	mailer = m
+}
+
+// SendMail 使用当前配置的 Mailer 发送邮件
+func SendMail(to, subject, body string) error {
+	mailerMu.RLock()
+	m := mailer
+	mailerMu.RUnlock()
+	return m.Send(to, subject, body)
+}
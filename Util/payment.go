@@ -0,0 +1,191 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/*
+   支付渠道：
+   茶庄售出星茶（购买）时向支付渠道发起收款，回购星茶（兑现）时通过支付渠道付款。
+   各渠道实现 PaymentProvider 接口并用 RegisterPaymentProvider 注册，星茶兑换订单按名称选用，未配置渠道时拒绝启动；
+   本地模拟渠道 LocalPaymentProvider（名称 local）仅在配置 TeaPaymentLocalEnabled 时注册，收款须调用 MarkPaid 模拟到账，
+   付款总是成功，仅用于开发测试。
+   金额单位为分。
+*/
+
+// PaymentStatus 渠道侧收款状态
+type PaymentStatus string
+
+const (
+	PaymentStatusPending PaymentStatus = "pending" // 等待付款
+	PaymentStatusPaid    PaymentStatus = "paid"    // 已到账
+	PaymentStatusClosed  PaymentStatus = "closed"  // 已关闭或已退款
+)
+
+// PaymentIntent 渠道创建的收款单
+type PaymentIntent struct {
+	Reference    string // 渠道收款单号
+	Instructions string // 付款说明（例如付款链接、二维码内容），展示给付款人
+}
+
+// PaymentProvider 支付渠道适配接口
+type PaymentProvider interface {
+	// Name 渠道名称，写入订单
+	Name() string
+	// CreatePayment 为订单创建收款单，orderUuid 可作为渠道侧幂等键
+	CreatePayment(orderUuid string, amountFen int64) (PaymentIntent, error)
+	// QueryPayment 查询收款单状态
+	QueryPayment(reference string) (PaymentStatus, error)
+	// Refund 全额退回已到账的收款单，orderUuid 作为渠道侧幂等键，同一订单重复退款只退一次
+	Refund(orderUuid, reference string) error
+	// Payout 向收款账户付款，orderUuid 可作为渠道侧幂等键，返回渠道付款单号
+	Payout(orderUuid string, amountFen int64, account string) (string, error)
+}
+
+var ErrPaymentProviderNotFound = errors.New("支付渠道不存在")
+
+var (
+	paymentMu        sync.RWMutex
+	paymentProviders = map[string]PaymentProvider{}
+)
+
+// RegisterPaymentProvider 注册（或替换同名）支付渠道
+func RegisterPaymentProvider(p PaymentProvider) {
+	paymentMu.Lock()
+	defer paymentMu.Unlock()
+	paymentProviders[p.Name()] = p
+}
+
+// GetPaymentProvider 按名称取支付渠道
+func GetPaymentProvider(name string) (PaymentProvider, error) {
+	paymentMu.RLock()
+	defer paymentMu.RUnlock()
+	p, ok := paymentProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPaymentProviderNotFound, name)
+	}
+	return p, nil
+}
+
+// PaymentProviderNames 已注册的支付渠道名称
+func PaymentProviderNames() []string {
+	paymentMu.RLock()
+	defer paymentMu.RUnlock()
+	names := make([]string, 0, len(paymentProviders))
+	for name := range paymentProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LocalPaymentProviderName 本地模拟支付渠道名称
+const LocalPaymentProviderName = "local"
+
+// LocalPaymentProvider 本地模拟支付渠道，收款单保存在内存中，进程重启后丢失
+type LocalPaymentProvider struct {
+	mu       sync.Mutex
+	payments map[string]PaymentStatus // 收款单号 → 状态
+	byOrder  map[string]string        // 订单uuid → 收款单号（幂等）
+	payouts  map[string]string        // 订单uuid → 付款单号（幂等）
+	refunds  map[string]string        // 订单uuid → 已退款的收款单号（幂等）
+}
+
+// NewLocalPaymentProvider 创建本地模拟支付渠道
+func NewLocalPaymentProvider() *LocalPaymentProvider {
+	return &LocalPaymentProvider{
+		payments: map[string]PaymentStatus{},
+		byOrder:  map[string]string{},
+		payouts:  map[string]string{},
+		refunds:  map[string]string{},
+	}
+}
+
+func (p *LocalPaymentProvider) Name() string { return LocalPaymentProviderName }
+
+func (p *LocalPaymentProvider) CreatePayment(orderUuid string, amountFen int64) (PaymentIntent, error) {
+	if amountFen <= 0 {
+		return PaymentIntent{}, fmt.Errorf("收款金额必须大于0")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ref, ok := p.byOrder[orderUuid]
+	if !ok {
+		ref = "local-pay-" + localReference()
+		p.byOrder[orderUuid] = ref
+		p.payments[ref] = PaymentStatusPending
+	}
+	return PaymentIntent{
+		Reference:    ref,
+		Instructions: fmt.Sprintf("本地模拟收款 %d.%02d 元，由茶博士在管理页面模拟到账", amountFen/100, amountFen%100),
+	}, nil
+}
+
+func (p *LocalPaymentProvider) QueryPayment(reference string) (PaymentStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status, ok := p.payments[reference]
+	if !ok {
+		return "", fmt.Errorf("收款单不存在: %s", reference)
+	}
+	return status, nil
+}
+
+// MarkPaid 模拟付款人完成付款
+func (p *LocalPaymentProvider) MarkPaid(reference string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status, ok := p.payments[reference]
+	if !ok {
+		return fmt.Errorf("收款单不存在: %s", reference)
+	}
+	if status == PaymentStatusPending {
+		p.payments[reference] = PaymentStatusPaid
+	}
+	return nil
+}
+
+func (p *LocalPaymentProvider) Refund(orderUuid, reference string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ref, ok := p.refunds[orderUuid]; ok {
+		if ref != reference {
+			return fmt.Errorf("订单 %s 已退款的收款单是 %s", orderUuid, ref)
+		}
+		return nil
+	}
+	if _, ok := p.payments[reference]; !ok {
+		return fmt.Errorf("收款单不存在: %s", reference)
+	}
+	p.payments[reference] = PaymentStatusClosed
+	p.refunds[orderUuid] = reference
+	return nil
+}
+
+func (p *LocalPaymentProvider) Payout(orderUuid string, amountFen int64, account string) (string, error) {
+	if amountFen <= 0 {
+		return "", fmt.Errorf("付款金额必须大于0")
+	}
+	if account == "" {
+		return "", fmt.Errorf("收款账户不能为空")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ref, ok := p.payouts[orderUuid]
+	if !ok {
+		ref = "local-payout-" + localReference()
+		p.payouts[orderUuid] = ref
+	}
+	return ref, nil
+}
+
+func localReference() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
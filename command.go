@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	dao "teachat/DAO"
+	util "teachat/Util"
+	"text/tabwriter"
+)
+
+/*
+   命令行：
+     teachat [-config config.json] [-set 键=值 ...]         启动服务
+     teachat config check [-config ...] [-set ...] [-ping] 打印生效配置（敏感信息打码）并校验
+     teachat migrate up [-steps N] [-seed]                  执行尚未执行的数据库迁移，-seed 同时写入预填充数据
+     teachat migrate down [-steps N]                        回滚最近执行的迁移，默认1个
+     teachat migrate status                                 列出各迁移的执行情况
+     teachat migrate baseline [-version N]                  把手工建库的数据库标记为已执行基线迁移
+     teachat migrate seed                                   写入预填充数据（可重复执行）
+     teachat tea reconcile [-freeze]                        星茶余额对账，发现差异时退出码为3，-freeze 冻结有差异的账户
+*/
+
+// setFlags 可重复的 -set 键=值
+type setFlags []string
+
+func (s *setFlags) String() string { return strings.Join(*s, ",") }
+
+func (s *setFlags) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// configFlags 各子命令共用的配置参数
+type configFlags struct {
+	path string
+	sets setFlags
+}
+
+func (c *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "config", "config.json", "配置文件路径")
+	fs.Var(&c.sets, "set", "覆盖配置项，形如 Database.Host=127.0.0.1，可重复")
+}
+
+// runCommand 执行子命令，不是子命令时 handled 为 false
+func runCommand(args []string) (handled bool, code int) {
+	if len(args) == 0 {
+		return false, 0
+	}
+	switch args[0] {
+	case "config":
+		return true, runConfigCommand(args[1:])
+	case "migrate":
+		return true, runMigrateCommand(args[1:])
+	case "tea":
+		return true, runTeaCommand(args[1:])
+	}
+	return false, 0
+}
+
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "用法: teachat config check [-config config.json] [-set 键=值] [-ping]")
+		return 2
+	}
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	var cf configFlags
+	cf.register(fs)
+	ping := fs.Bool("ping", false, "同时测试数据库连接")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := util.Load(cf.path, cf.sets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "配置加载失败:", err)
+		return 1
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "配置项\t生效值\t环境变量")
+	for _, e := range cfg.Entries() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Key, e.Value, e.Env)
+	}
+	tw.Flush()
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "配置校验失败:", err)
+		return 1
+	}
+	if *ping {
+		if _, err := dao.Open(cfg.Database); err != nil {
+			fmt.Fprintln(os.Stderr, "数据库连接失败:", err)
+			return 1
+		}
+		dao.Close()
+		fmt.Println("数据库连接正常")
+	}
+	fmt.Println("配置校验通过")
+	return 0
+}
+
+const migrateUsage = "用法: teachat migrate up|down|status|baseline|seed [-config config.json] [-set 键=值] [-steps N] [-seed] [-version N]"
+
+func runMigrateCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	var cf configFlags
+	cf.register(fs)
+	steps := fs.Int("steps", 0, "up：最多执行的迁移数，0表示全部；down：回滚的迁移数，默认1")
+	seed := fs.Bool("seed", false, "up：迁移完成后写入预填充数据")
+	version := fs.Int("version", dao.BaselineVersion, "baseline：标记为已执行的最高版本")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := util.Load(cf.path, cf.sets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "配置加载失败:", err)
+		return 1
+	}
+	if _, err := dao.Open(cfg.Database); err != nil {
+		fmt.Fprintln(os.Stderr, "数据库连接失败:", err)
+		return 1
+	}
+	defer dao.Close()
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		done, err := dao.MigrateUp(ctx, *steps)
+		printMigrations("已执行", done)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "迁移失败:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("数据库已是最新版本")
+		}
+		if *seed {
+			if err := dao.ApplySeed(ctx); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			fmt.Println("预填充数据已写入")
+		}
+	case "down":
+		done, err := dao.MigrateDown(ctx, *steps)
+		printMigrations("已回滚", done)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "回滚失败:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("没有可回滚的迁移")
+		}
+	case "baseline":
+		done, err := dao.MigrateBaseline(ctx, *version)
+		printMigrations("已标记", done)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "标记基线失败:", err)
+			return 1
+		}
+	case "seed":
+		if err := dao.ApplySeed(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("预填充数据已写入")
+	case "status":
+		states, err := dao.GetMigrationStates(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "读取迁移记录失败:", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "版本\t名称\t状态\t执行时间")
+		for _, s := range states {
+			status, at := "待执行", "-"
+			if s.Applied {
+				status, at = "已执行", s.AppliedAt.Local().Format(dao.FMT_DATE_TIME)
+			}
+			if s.Modified {
+				status += "（脚本已修改）"
+			}
+			if s.Missing {
+				status += "（脚本缺失）"
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, status, at)
+		}
+		tw.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+func printMigrations(verb string, list []dao.Migration) {
+	for _, m := range list {
+		fmt.Printf("%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+const teaUsage = "用法: teachat tea reconcile [-config config.json] [-set 键=值] [-freeze]"
+
+func runTeaCommand(args []string) int {
+	if len(args) == 0 || args[0] != "reconcile" {
+		fmt.Fprintln(os.Stderr, teaUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("tea reconcile", flag.ContinueOnError)
+	var cf configFlags
+	cf.register(fs)
+	freeze := fs.Bool("freeze", false, "冻结有差异且尚未冻结的星茶账户")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := util.Load(cf.path, cf.sets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "配置加载失败:", err)
+		return 1
+	}
+	if _, err := dao.Open(cfg.Database); err != nil {
+		fmt.Fprintln(os.Stderr, "数据库连接失败:", err)
+		return 1
+	}
+	defer dao.Close()
+
+	run, findings, err := dao.TeaReconcile(context.Background(), dao.TeaReconcileOptions{
+		Trigger: dao.TeaReconcileTrigger_CLI,
+		Freeze:  *freeze,
+	})
+	if run.Id > 0 {
+		fmt.Printf("对账批次 #%d：检查账户 %d 个，发现差异 %d 项\n", run.Id, run.AccountsChecked, run.FindingsCount)
+	}
+	if len(findings) > 0 {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "账户\t差异\t应有(毫克)\t实际(毫克)\t偏差(毫克)\t已冻结")
+		for _, f := range findings {
+			frozen := "否"
+			if f.Frozen {
+				frozen = "是"
+			}
+			fmt.Fprintf(tw, "%s#%d\t%s\t%d\t%d\t%+d\t%s\n", f.HolderType, f.HolderId, dao.TeaReconcileKindString(f.Kind),
+				f.ExpectedMilligrams, f.ActualMilligrams, f.DriftMilligrams(), frozen)
+		}
+		tw.Flush()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "对账失败:", err)
+		return 1
+	}
+	if len(findings) > 0 {
+		return 3
+	}
+	fmt.Println("账户余额一致")
+	return 0
+}
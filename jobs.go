@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	dao "teachat/DAO"
+	route "teachat/Route"
+	util "teachat/Util"
+	"time"
+)
+
+/*
+   后台任务注册：默认计划见各任务，配置 JobSchedules 可按名称覆盖（例如 tea_reconcile=0 3 * * *），
+   管理页面 /v1/admin/jobs 查看执行状况并可手动执行。
+*/
+
+// intervalSchedule 分钟数配置转为计划，负数表示停用
+func intervalSchedule(minutes int64) string {
+	if minutes < 0 {
+		return dao.JobScheduleOff
+	}
+	return fmt.Sprintf("@every %dm", minutes)
+}
+
+// registerJobs 注册全部后台任务并应用配置中的计划覆盖
+func registerJobs() error {
+	jobs := []dao.Job{
+		{
+			Name:        "session_cleanup",
+			Description: "清理过期会话",
+			Schedule:    "@every 30m",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := dao.DeleteExpiredSessions()
+				if err != nil {
+					return "", fmt.Errorf("清理过期会话失败: %v", err)
+				}
+				return fmt.Sprintf("已清理过期会话 %d 条", n), nil
+			},
+		},
+		{
+			Name:        "idempotency_key_cleanup",
+			Description: "清理过期幂等键",
+			Schedule:    "@every 30m",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := dao.PurgeExpiredIdempotencyKeys()
+				if err != nil {
+					return "", fmt.Errorf("清理过期幂等键失败: %v", err)
+				}
+				return fmt.Sprintf("已清理过期幂等键 %d 条", n), nil
+			},
+		},
+		{
+			Name:        "tea_expired_transfers",
+			Description: "处理超时未确认的星茶转账",
+			Schedule:    "@every 30m",
+			Run: func(ctx context.Context) (string, error) {
+				if err := route.ProcessExpiredTransfersJob(); err != nil {
+					return "", err
+				}
+				return "过期转账处理完成", nil
+			},
+		},
+		{
+			Name:        "tea_reconcile",
+			Description: "星茶余额对账",
+			Schedule:    intervalSchedule(util.Config.TeaReconcileIntervalMinutes),
+			Run: func(ctx context.Context) (string, error) {
+				run, findings, err := dao.TeaReconcile(ctx, dao.TeaReconcileOptions{
+					Trigger: dao.TeaReconcileTrigger_Job,
+					Freeze:  util.Config.TeaReconcileFreeze,
+				})
+				if err != nil {
+					return "", fmt.Errorf("星茶余额对账失败: %v", err)
+				}
+				if len(findings) > 0 {
+					return "", fmt.Errorf("对账批次 #%d 发现差异 %d 项，请执行 teachat tea reconcile 核查", run.Id, len(findings))
+				}
+				return fmt.Sprintf("对账批次 #%d 未发现差异", run.Id), nil
+			},
+		},
+		{
+			Name:        "tea_transfer_schedules",
+			Description: "执行到期的团队定期转账",
+			Schedule:    intervalSchedule(util.Config.TeaTransferScheduleIntervalMinutes),
+			Run: func(ctx context.Context) (string, error) {
+				summary, err := dao.RunDueTeaTeamTransferSchedules(ctx, time.Now())
+				if err != nil {
+					return "", fmt.Errorf("执行团队定期转账失败: %v", err)
+				}
+				return fmt.Sprintf("到期 %d 项，已发起 %d 笔，失败 %d 笔", summary.Due, summary.Created, summary.Failed), nil
+			},
+		},
+		{
+			Name:        "tea_auto_unfreeze",
+			Description: "解冻到达自动解冻期限的星茶账户",
+			Schedule:    "@every 5m",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := dao.UnfreezeDueTeaAccounts(ctx, time.Now())
+				if err != nil {
+					return "", fmt.Errorf("自动解冻星茶账户失败: %v", err)
+				}
+				return fmt.Sprintf("已解冻 %d 个账户", n), nil
+			},
+		},
+		{
+			Name:        "accept_review_reassign",
+			Description: "友邻蒙评评审官超时未答复时由可信评审官独自裁定或改派他人，并补派缺额",
+			Schedule:    "@every 15m",
+			Run: func(ctx context.Context) (string, error) {
+				summary, err := route.ReassignOverdueAcceptReviews(ctx, time.Now())
+				if err != nil {
+					return "", fmt.Errorf("改派友邻蒙评评审官失败: %v", err)
+				}
+				return fmt.Sprintf("超时 %d 件，可信评审官独自裁定 %d 件（失败 %d 件），改派 %d 位，仍缺评审官的对象 %d 个",
+					summary.TimedOut, summary.DecidedAlone, summary.Failed, summary.Reassigned, summary.Short), nil
+			},
+		},
+		{
+			Name:        "accept_reviewer_stats",
+			Description: "全量刷新友邻蒙评评审官统计及信誉分",
+			Schedule:    "@every 6h",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := dao.RefreshAllAcceptReviewerStats(ctx)
+				if err != nil {
+					return "", fmt.Errorf("刷新评审官统计失败: %v", err)
+				}
+				return fmt.Sprintf("已刷新 %d 位评审官", n), nil
+			},
+		},
+		{
+			Name:        "team_member_suspension_expire",
+			Description: "恢复暂停期满的茶团成员",
+			Schedule:    "@every 5m",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := dao.UnsuspendDueTeamMembers(ctx, time.Now())
+				if err != nil {
+					return "", fmt.Errorf("恢复暂停期满的茶团成员失败: %v", err)
+				}
+				return fmt.Sprintf("已恢复 %d 位成员", n), nil
+			},
+		},
+		{
+			Name:        "document_expiry",
+			Description: "标记超过期限未答复的邀请函、申请书、声明书为已过期并提醒双方",
+			Schedule:    "@every 30m",
+			Run: func(ctx context.Context) (string, error) {
+				s, err := dao.ExpireStaleDocuments(ctx, time.Now())
+				if err != nil {
+					return "", fmt.Errorf("处理过期文书失败: %v", err)
+				}
+				return fmt.Sprintf("茶团邀请函 %d 份，加盟申请书 %d 份，集团邀请函 %d 份，家庭成员声明书 %d 份，提醒 %d 条",
+					s.TeamInvitations, s.MemberApplications, s.GroupInvitations, s.FamilySignIns, s.Notices), nil
+			},
+		},
+	}
+	for _, job := range jobs {
+		if err := dao.Jobs.Register(job); err != nil {
+			return err
+		}
+	}
+	return dao.Jobs.Configure(util.Config.JobSchedules)
+}
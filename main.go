@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	dao "teachat/DAO"
 	route "teachat/Route"
 	util "teachat/Util"
 	"time"
@@ -14,21 +16,110 @@ import (
 )
 
 func main() {
-	// 初始化配置
-	if err := util.LoadConfig(); err != nil {
+	// 子命令（config check 等）
+	if handled, code := runCommand(os.Args[1:]); handled {
+		os.Exit(code)
+	}
+
+	// 初始化配置：配置文件 → 环境变量 → 命令行
+	fs := flag.NewFlagSet("teachat", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	fs.Parse(os.Args[1:])
+	if err := util.Setup(cf.path, cf.sets); err != nil {
 		log.Fatalf("配置加载失败: %v", err)
 	}
 	if err := util.Config.Validate(); err != nil {
 		log.Fatalf("配置校验失败: %v", err)
 	}
+	// 连接数据库
+	if _, err := dao.Open(util.Config.Database); err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+	defer dao.Close()
+	util.PrintStdout("Open tea chat database success, 星际茶棚数据库打开成功")
+	// 提醒尚未执行的数据库迁移（不自动执行，由运维执行 teachat migrate up）
+	if n, err := dao.PendingMigrations(context.Background()); err != nil {
+		util.Warning("读取数据库迁移记录失败:", err)
+	} else if n > 0 {
+		util.Warningf("有 %d 个数据库迁移尚未执行，请先运行 teachat migrate up", n)
+	}
+	// 预先解析页面模版
+	if err := route.LoadTemplates(); err != nil {
+		log.Fatalf("模版加载失败: %v", err)
+	}
+	// 静态资源处理
+	if _, err := os.Stat(util.Config.Static); os.IsNotExist(err) {
+		log.Fatalf("静态资源目录不存在: %s", util.Config.Static)
+	}
+	// 创建路由器
+	mux := newMux()
+
+	// 创建服务器
+	server := &http.Server{
+		Addr:           util.Config.Address,
+		Handler:        route.Chain(mux, route.RequestID, route.AccessLog, route.Recover, route.CSRFProtect),
+		ReadTimeout:    time.Duration(util.Config.ReadTimeout) * time.Second, // 修正时间单位
+		WriteTimeout:   time.Duration(util.Config.WriteTimeout) * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+	// 启动后台任务调度（清理过期会话、处理过期转账、星茶余额对账、团队定期转账等）
+	if err := registerJobs(); err != nil {
+		log.Fatalf("后台任务配置无效: %v", err)
+	}
+	if err := dao.Jobs.Start(context.Background()); err != nil {
+		util.Errorf("后台任务调度器启动失败，后台任务不会执行: %v", err)
+	}
+
+	// 设置优雅关闭：停止接收请求，同时停止后台任务调度并等待执行中的任务结束
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		<-quit
+		log.Println("接收到关闭信号，正在停止服务器...")
+
+		jobsDone := make(chan struct{})
+		go func() {
+			defer close(jobsDone)
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			if err := dao.Jobs.Stop(ctx); err != nil {
+				log.Printf("后台任务未能全部停止: %v", err)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			log.Fatalf("服务器强制关闭: %v", err)
+		} else {
+			log.Println("服务器已优雅停止")
+		}
+		<-jobsDone
+	}()
+	// 启动服务器
+	log.Printf("服务器启动，监听地址: %s", util.Config.Address)
+	util.PrintStdout("teachat", util.Version(), "星际茶棚一>开门迎客")
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("服务器启动失败: %v", err)
+	}
+	// ListenAndServe 在开始关闭时即返回，等待关闭流程完成
+	<-stopped
+	log.Println("服务器已停止")
+	log.Println("星际茶棚 --> 打烊休息")
+}
+
+// newMux 注册全部路由，返回路由器；全局中间件在 main 中创建服务器时包裹
+func newMux() *http.ServeMux {
 	// 创建路由器
 	mux := http.NewServeMux()
 
 	// 静态资源处理
 	const staticPrefix = "/v1/static/"
-	if _, err := os.Stat(util.Config.Static); os.IsNotExist(err) {
-		log.Fatalf("静态资源目录不存在: %s", util.Config.Static)
-	}
 
 	// 创建文件处理器（带缓存控制）
 	cacheControl := func(h http.Handler) http.Handler {
@@ -56,6 +147,10 @@ func main() {
 	mux.HandleFunc("/v1/user/edit", route.EditIntroAndName)
 	mux.HandleFunc("/v1/user/forgot", route.Forgot)
 	mux.HandleFunc("/v1/user/reset", route.Reset)
+	mux.Handle("/v1/user/password", route.Handle(route.ChangePassword, route.RequireLogin))
+	mux.Handle("/v1/user/sessions", route.Handle(route.MySessions, route.Methods(http.MethodGet), route.RequireLogin))
+	mux.Handle("/v1/user/session/revoke", route.Handle(route.RevokeSession, route.Methods(http.MethodPost), route.RequireLogin))
+	mux.Handle("/v1/user/session/logout_all", route.Handle(route.LogoutEverywhere, route.Methods(http.MethodPost), route.RequireLogin))
 	mux.HandleFunc("/v1/user/avatar", route.AvatarUploadUser)
 
 	mux.HandleFunc("/v1/users/connection_follow", route.Follow)
@@ -76,7 +171,7 @@ func main() {
 	mux.HandleFunc("/v1/team/applications", route.TeamApplications)
 	mux.HandleFunc("/v1/team/members/left", route.TeamMembersLeft)
 
-	mux.HandleFunc("/v1/team/manage", route.HandleManageTeam)
+	mux.Handle("/v1/team/manage", route.Handle(route.HandleManageTeam, route.Methods(http.MethodGet, http.MethodPost), route.RequireLogin))
 	mux.HandleFunc("/v1/team/notification/invitation", route.TeamNotificationInvitations)
 	mux.HandleFunc("/v1/team/member_add", route.TeamMemberAddGet)
 	mux.HandleFunc("/v1/team/search_user", route.HandleTeamSearchUser)
@@ -85,7 +180,9 @@ func main() {
 
 	mux.HandleFunc("/v1/team_member/role", route.HandleMemberRole)
 	mux.HandleFunc("/v1/team_member/role_changed", route.MemberRoleChanged)
-	mux.HandleFunc("/v1/team_members/fired", route.MemberFired)
+	mux.Handle("/v1/team_members/fired", route.Handle(route.MemberFired, route.Methods(http.MethodGet), route.RequireLogin))
+	mux.Handle("/v1/team_member/fire", route.Handle(route.HandleMemberFire, route.Methods(http.MethodGet, http.MethodPost), route.RequireLogin))
+	mux.Handle("/v1/team_member/dismissal/detail", route.Handle(route.TeamMemberDismissalDetail, route.Methods(http.MethodGet, http.MethodPost), route.RequireLogin))
 	// 资料更新
 	mux.HandleFunc("/v1/team/edit", route.HandleEditTeam)
 	mux.HandleFunc("/v1/team/logo", route.TeamLogoUpload)
@@ -239,6 +336,10 @@ func main() {
 	mux.HandleFunc("/v1/notification/accept", route.AcceptNotifications)
 	mux.HandleFunc("/v1/group/notification/invitation", route.InvitationGroup)
 
+	//defined in route_expiry_notice.go
+	mux.Handle("/v1/notification/expiry", route.Handle(route.ExpiryNotices, route.Methods(http.MethodGet), route.RequireLogin))
+	mux.Handle("/v1/notification/expiry/renew", route.Handle(route.ExpiryNoticeRenew, route.Methods(http.MethodPost), route.RequireLogin))
+
 	//defined in route_place.go
 	mux.HandleFunc("/v1/place/new", route.NewPlace)
 	mux.HandleFunc("/v1/place/create", route.CreatePlace)
@@ -299,7 +400,10 @@ func main() {
 	mux.HandleFunc("/v1/message/announcement/send", route.MessageAnnouncementSend)
 
 	//定义在 Route_balance.go
-	mux.HandleFunc("/v1/balance/fairnessmug", route.FairnessMug)
+	mux.HandleFunc("/v1/balance/fairnessmug", route.FairnessMug)                    // 托管预备金争议：发起/详情
+	mux.HandleFunc("/v1/balance/fairnessmug/respond", route.FairnessMugRespond)     // 被诉方答辩
+	mux.HandleFunc("/v1/balance/fairnessmug/withdraw", route.FairnessMugWithdraw)   // 发起方撤销争议
+	mux.HandleFunc("/v1/balance/fairnessmug/arbitrate", route.FairnessMugArbitrate) // 见证者仲裁
 
 	// 见证者工作间路由
 	mux.HandleFunc("/v1/verifier/workspace", route.HandleVerifierWorkspace)        // 见证者工作间页面
@@ -321,12 +425,12 @@ func main() {
 	mux.HandleFunc("/v1/tea/user/account/freeze", route.FreezeTeaUserAccountAPI)     // 用户星茶账户冻结API
 	mux.HandleFunc("/v1/tea/user/account/unfreeze", route.UnfreezeTeaUserAccountAPI) // 用户星茶账户解冻API
 	// out
-	mux.HandleFunc("/v1/tea/user/transfer/new/user_to_user", route.CreateTeaUserToUserTransferAPI)                 // 用户对用户创建转账API
-	mux.HandleFunc("/v1/tea/user/transfer/new/user_to_team", route.CreateTeaUserToTeamTransferAPI)                 // 用户对团队创建转账API
-	mux.HandleFunc("/v1/tea/user/transfers/user_to_user/pending/page", route.GetTeaUserToUserPendingTransfers)     // 等待对方用户待确认，用户对用户转账(待确认状态)页面
-	mux.HandleFunc("/v1/tea/user/transfers/user_to_team/pending/page", route.GetTeaUserToTeamPendingTransfers)     // 等待对方团队成员确认，用户对团队转账(待确认状态)页面
-	mux.HandleFunc("/v1/tea/user/transfers/user_to_user/completed/page", route.GetTeaUserToUserCompletedTransfers) // 用户对用户转出已完成记录列表页面(仅已完成状态)
-	mux.HandleFunc("/v1/tea/user/transfers/user_to_team/completed/page", route.GetTeaUserToTeamCompletedTransfers) // 用户对团队转出已完成记录列表页面(仅已完成状态)
+	mux.Handle("/v1/tea/user/transfer/new/user_to_user", route.Handle(route.CreateTeaUserToUserTransferAPI, route.Idempotent)) // 用户对用户创建转账API
+	mux.Handle("/v1/tea/user/transfer/new/user_to_team", route.Handle(route.CreateTeaUserToTeamTransferAPI, route.Idempotent)) // 用户对团队创建转账API
+	mux.HandleFunc("/v1/tea/user/transfers/user_to_user/pending/page", route.GetTeaUserToUserPendingTransfers)                 // 等待对方用户待确认，用户对用户转账(待确认状态)页面
+	mux.HandleFunc("/v1/tea/user/transfers/user_to_team/pending/page", route.GetTeaUserToTeamPendingTransfers)                 // 等待对方团队成员确认，用户对团队转账(待确认状态)页面
+	mux.HandleFunc("/v1/tea/user/transfers/user_to_user/completed/page", route.GetTeaUserToUserCompletedTransfers)             // 用户对用户转出已完成记录列表页面(仅已完成状态)
+	mux.HandleFunc("/v1/tea/user/transfers/user_to_team/completed/page", route.GetTeaUserToTeamCompletedTransfers)             // 用户对团队转出已完成记录列表页面(仅已完成状态)
 	// 超时的转账记录
 	mux.HandleFunc("/v1/tea/user/transfers/user_to_user/expired/page", route.GetTeaUserToUserExpiredTransfers) // 用户对用户转出已超时记录列表页面(仅已超时状态)
 	mux.HandleFunc("/v1/tea/user/transfers/user_to_team/expired/page", route.GetTeaUserToTeamExpiredTransfers) // 用户对团队转出已超时记录列表页面(仅已超时状态)
@@ -348,6 +452,14 @@ func main() {
 	mux.HandleFunc("/v1/tea/user/transfer/reject/user_from_user", route.RejectTeaUserFromUserTransferAPI)   // 用户拒绝接收来自用户转账API
 	mux.HandleFunc("/v1/tea/user/transfer/confirm/user_from_team", route.ConfirmTeaUserFromTeamTransferAPI) // 用户确认接收来自团队转账API
 	mux.HandleFunc("/v1/tea/user/transfer/reject/user_from_team", route.TeaUserRejectFromTeamTransferAPI)   // 用户拒绝接收来自团队转账API
+	// 用户交易流水（复式记账分录）
+	mux.Handle("/v1/tea/user/transactions/page", route.Handle(route.HandleTeaUserTransactionHistory, route.Methods(http.MethodGet), route.RequireLogin)) // 用户交易流水页面
+	mux.Handle("/v1/tea/user/transactions/api", route.Handle(route.GetTeaUserTransactionHistoryAPI, route.Methods(http.MethodGet), route.RequireLogin))  // 用户交易流水API
+	mux.Handle("/v1/tea/user/statement", route.Handle(route.HandleTeaUserStatement, route.Methods(http.MethodGet), route.RequireLogin))                  // 用户对账单（html/csv/json）
+	mux.Handle("/v1/tea/user/freeze", route.Handle(route.HandleTeaUserFreeze, route.Methods(http.MethodGet), route.RequireLogin))                        // 用户星茶账户冻结记录及申诉
+	mux.Handle("/v1/tea/user/freeze/appeal", route.Handle(route.FileTeaUserFreezeAppeal, route.Methods(http.MethodPost), route.RequireLogin))            // 对冻结的用户星茶账户提出申诉
+	mux.Handle("/v1/tea/user/transfer/holds", route.Handle(route.HandleTeaUserTransferHolds, route.Methods(http.MethodGet), route.RequireLogin))         // 本人发起的待审核转账
+	mux.Handle("/v1/tea/user/transfer/hold/cancel", route.Handle(route.CancelTeaUserTransferHold, route.Methods(http.MethodPost), route.RequireLogin))   // 撤回待审核转账
 
 	// 需要更新，团队星茶账户系统路由
 	mux.HandleFunc("/v1/tea/team/account", route.TeaTeamAccountGet)                  // 团队星茶账户页面
@@ -355,10 +467,10 @@ func main() {
 	mux.HandleFunc("/v1/tea/team/account/freeze", route.FreezeTeaTeamAccountAPI)     // 团队星茶账户冻结API
 	mux.HandleFunc("/v1/tea/team/account/unfreeze", route.UnfreezeTeaTeamAccountAPI) // 团队星茶账户解冻API
 	// 团队(out)转出创建
-	mux.HandleFunc("/v1/tea/team/transfer/team_to_team/new", route.CreateTeaTeamToTeamTransferAPI)                    // 团队对团队创建转账API
-	mux.HandleFunc("/v1/tea/team/transfer/team_to_user/new", route.CreateTeaTeamToUserTransferAPI)                    // 团队对用户创建转账API
-	mux.HandleFunc("/v1/tea/team/transfers/team_to_team/approve/page", route.GetTeaTeamPendingApproveToTeamTransfers) // 团队待审批对其他团队转账页面
-	mux.HandleFunc("/v1/tea/team/transfers/team_to_user/approve/page", route.GetTeaTeamPendingApproveToUserTransfers) // 团队待审批对其他个人转账页面
+	mux.Handle("/v1/tea/team/transfer/team_to_team/new", route.Handle(route.CreateTeaTeamToTeamTransferAPI, route.Idempotent)) // 团队对团队创建转账API
+	mux.Handle("/v1/tea/team/transfer/team_to_user/new", route.Handle(route.CreateTeaTeamToUserTransferAPI, route.Idempotent)) // 团队对用户创建转账API
+	mux.HandleFunc("/v1/tea/team/transfers/team_to_team/approve/page", route.GetTeaTeamPendingApproveToTeamTransfers)          // 团队待审批对其他团队转账页面
+	mux.HandleFunc("/v1/tea/team/transfers/team_to_user/approve/page", route.GetTeaTeamPendingApproveToUserTransfers)          // 团队待审批对其他个人转账页面
 	// 团队(out)转出审批
 	mux.HandleFunc("/v1/tea/team/transfer/approve/team_to_team", route.ApproveTeaTeamToTeamTransferAPI)                // 审批团队对团队转账API
 	mux.HandleFunc("/v1/tea/team/transfer/approve/team_to_user", route.ApproveTeaTeamToUserTransferAPI)                // 审批团队对用户转账API
@@ -387,8 +499,55 @@ func main() {
 	// 团队(in)转入已超时记录
 	mux.HandleFunc("/v1/tea/team/transfers/team_from_team/expired/page", route.GetTeaTeamFromTeamExpiredTransfers) // 团队接收团队转入已超时记录页面
 	mux.HandleFunc("/v1/tea/team/transfers/team_from_user/expired/page", route.GetTeaTeamFromUserExpiredTransfers) // 团队接收用户转入已超时记录页面
-	// TODO:团队查询特定转账记录
-	//mux.HandleFunc("/v1/tea/team/transactions/page", route.HandleTeaTeamTransactionHistory) // 团队交易流水页面路由
+	// 团队交易流水（复式记账分录）
+	mux.Handle("/v1/tea/team/transactions/page", route.Handle(route.HandleTeaTeamTransactionHistory, route.Methods(http.MethodGet), route.RequireLogin))       // 团队交易流水页面
+	mux.Handle("/v1/tea/team/transactions/api", route.Handle(route.GetTeaTeamTransactionHistoryAPI, route.Methods(http.MethodGet), route.RequireLogin))        // 团队交易流水API
+	mux.Handle("/v1/tea/team/statement", route.Handle(route.HandleTeaTeamStatement, route.Methods(http.MethodGet), route.RequireLogin))                        // 团队对账单（html/csv/json）
+	mux.Handle("/v1/tea/team/transfer_policy", route.Handle(route.HandleTeaTeamTransferPolicy, route.Methods(http.MethodGet), route.RequireLogin))             // 团队转出审批策略页面
+	mux.Handle("/v1/tea/team/transfer_policy/save", route.Handle(route.SaveTeaTeamTransferPolicy, route.Methods(http.MethodPost), route.RequireLogin))         // 修改团队转出审批策略
+	mux.Handle("/v1/tea/team/transfer_schedules", route.Handle(route.HandleTeaTeamTransferSchedules, route.Methods(http.MethodGet), route.RequireLogin))       // 团队定期转账列表页面
+	mux.Handle("/v1/tea/team/transfer_schedule", route.Handle(route.HandleTeaTeamTransferSchedule, route.Methods(http.MethodGet), route.RequireLogin))         // 团队定期转账详情及执行记录
+	mux.Handle("/v1/tea/team/transfer_schedule/new", route.Handle(route.NewTeaTeamTransferSchedule, route.Methods(http.MethodPost), route.RequireLogin))       // 新建团队定期转账
+	mux.Handle("/v1/tea/team/transfer_schedule/pause", route.Handle(route.PauseTeaTeamTransferSchedule, route.Methods(http.MethodPost), route.RequireLogin))   // 暂停团队定期转账
+	mux.Handle("/v1/tea/team/transfer_schedule/resume", route.Handle(route.ResumeTeaTeamTransferSchedule, route.Methods(http.MethodPost), route.RequireLogin)) // 恢复团队定期转账
+	mux.Handle("/v1/tea/team/transfer_schedule/cancel", route.Handle(route.CancelTeaTeamTransferSchedule, route.Methods(http.MethodPost), route.RequireLogin)) // 取消团队定期转账
+	mux.Handle("/v1/tea/team/freeze", route.Handle(route.HandleTeaTeamFreeze, route.Methods(http.MethodGet), route.RequireLogin))                              // 团队星茶账户冻结记录及申诉
+	mux.Handle("/v1/tea/team/freeze/appeal", route.Handle(route.FileTeaTeamFreezeAppeal, route.Methods(http.MethodPost), route.RequireLogin))                  // 对冻结的团队星茶账户提出申诉
+
+	// 茶庄星茶兑换（购买、兑现）
+	mux.Handle("/v1/tea/exchange/page", route.Handle(route.HandleTeaExchange, route.Methods(http.MethodGet), route.RequireLogin))              // 星茶兑换页面
+	mux.Handle("/v1/tea/exchange/purchase", route.Handle(route.HandleTeaExchangePurchase, route.Methods(http.MethodPost), route.RequireLogin)) // 下单购买星茶
+	mux.Handle("/v1/tea/exchange/redeem", route.Handle(route.HandleTeaExchangeRedeem, route.Methods(http.MethodPost), route.RequireLogin))     // 申请兑现星茶
+	mux.Handle("/v1/tea/exchange/cancel", route.Handle(route.HandleTeaExchangeCancel, route.Methods(http.MethodPost), route.RequireLogin))     // 撤回兑换订单
+	mux.Handle("/v1/tea/exchange/sync", route.Handle(route.HandleTeaExchangeSync, route.Methods(http.MethodPost), route.RequireLogin))         // 刷新付款状态
+	teaOperator := route.RequireRole(dao.User_Role_TeaOffice, dao.User_Role_Captain)
+	mux.Handle("/v1/tea/exchange/admin", route.Handle(route.HandleTeaExchangeAdmin, route.Methods(http.MethodGet), route.RequireLogin, teaOperator))            // 兑换订单处理页面
+	mux.Handle("/v1/tea/exchange/admin/approve", route.Handle(route.HandleTeaExchangeApprove, route.Methods(http.MethodPost), route.RequireLogin, teaOperator)) // 审核通过
+	mux.Handle("/v1/tea/exchange/admin/reject", route.Handle(route.HandleTeaExchangeReject, route.Methods(http.MethodPost), route.RequireLogin, teaOperator))   // 否决
+	mux.Handle("/v1/tea/exchange/admin/payout", route.Handle(route.HandleTeaExchangePayout, route.Methods(http.MethodPost), route.RequireLogin, teaOperator))   // 重试兑现付款
+	mux.Handle("/v1/tea/exchange/admin/refund", route.Handle(route.HandleTeaExchangeRefund, route.Methods(http.MethodPost), route.RequireLogin, teaOperator))   // 重试否决后的退款
+	if util.Config.TeaPaymentLocalEnabled {
+		mux.Handle("/v1/tea/exchange/admin/local_pay", route.Handle(route.HandleTeaExchangeLocalPay, route.Methods(http.MethodPost), route.RequireLogin, teaOperator)) // 本地模拟渠道模拟到账
+	}
+	mux.Handle("/v1/tea/freeze/appeals", route.Handle(route.HandleTeaFreezeAppeals, route.Methods(http.MethodGet), route.RequireLogin, teaOperator))       // 星茶账户冻结申诉审核页面
+	mux.Handle("/v1/tea/freeze/appeal/decide", route.Handle(route.DecideTeaFreezeAppeal, route.Methods(http.MethodPost), route.RequireLogin, teaOperator)) // 批准或驳回冻结申诉
+	mux.Handle("/v1/tea/transfer/holds", route.Handle(route.HandleTeaTransferHolds, route.Methods(http.MethodGet), route.RequireLogin, teaOperator))       // 命中风控规则的待审核转账
+	mux.Handle("/v1/tea/transfer/hold/decide", route.Handle(route.DecideTeaTransferHold, route.Methods(http.MethodPost), route.RequireLogin, teaOperator)) // 批准或驳回待审核转账
+	// 后台任务管理
+	mux.Handle("/v1/admin/jobs", route.Handle(route.HandleJobs, route.Methods(http.MethodGet), route.RequireLogin, teaOperator))        // 后台任务状态页面
+	mux.Handle("/v1/admin/jobs/run", route.Handle(route.HandleJobRun, route.Methods(http.MethodPost), route.RequireLogin, teaOperator)) // 立即执行后台任务
+	// 友邻蒙评
+	mux.Handle("/v1/admin/accept/assignments", route.Handle(route.HandleAcceptAssignments, route.Methods(http.MethodGet), route.RequireLogin, teaOperator)) // 评审官指派记录
+	mux.Handle("/v1/admin/accept/reviewers", route.Handle(route.HandleAcceptReviewers, route.Methods(http.MethodGet), route.RequireLogin, teaOperator))     // 评审官信誉
+	mux.Handle("/v1/admin/accept/check", route.Handle(route.CheckAcceptReview, route.Methods(http.MethodPost), route.RequireLogin, teaOperator))            // 抽查复核
+	mux.Handle("/v1/office/reviews", route.Handle(route.HandleMyAcceptReviews, route.Methods(http.MethodGet), route.RequireLogin))                          // 我的评审记录
+	mux.Handle("/v1/admin/accept/appeals", route.Handle(route.HandleAcceptAppeals, route.Methods(http.MethodGet), route.RequireLogin, teaOperator))         // 申诉复审
+	mux.Handle("/v1/admin/accept/appeal/decide", route.Handle(route.DecideAcceptAppeal, route.Methods(http.MethodPost), route.RequireLogin, teaOperator))   // 茶博士复审申诉
+	mux.Handle("/v1/office/appeals", route.Handle(route.HandleMyAcceptAppeals, route.Methods(http.MethodGet), route.RequireLogin))                          // 我的申诉
+	mux.Handle("/v1/office/appeal/new", route.Handle(route.NewAcceptAppealGet, route.Methods(http.MethodGet), route.RequireLogin))                          // 提出申诉
+	mux.Handle("/v1/office/appeal/create", route.Handle(route.CreateAcceptAppealPost, route.Methods(http.MethodPost), route.RequireLogin))                  // 提交申诉
+	mux.Handle("/v1/office/appeal", route.Handle(route.AcceptAppealDetail, route.Methods(http.MethodGet), route.RequireLogin))                              // 申诉详情
+
 	//mux.HandleFunc("/v1/tea/team/operations/history/page", route.HandleTeaTeamOperationsHistory) // 团队操作历史页面路由
 
 	// define in help.go 帮助 文档 信息
@@ -397,52 +556,5 @@ func main() {
 	// about
 	mux.HandleFunc("/v1/about", route.About)
 
-	// 创建服务器
-	server := &http.Server{
-		Addr:           util.Config.Address,
-		Handler:        mux,
-		ReadTimeout:    time.Duration(util.Config.ReadTimeout) * time.Second, // 修正时间单位
-		WriteTimeout:   time.Duration(util.Config.WriteTimeout) * time.Second,
-		MaxHeaderBytes: 1 << 20,
-	}
-	// 启动定时处理过期转账的任务
-	go func() {
-		ticker := time.NewTicker(30 * time.Minute) // 每30分钟检查一次
-		defer ticker.Stop()
-
-		for range ticker.C {
-			log.Println("开始处理过期转账...")
-			if err := route.ProcessExpiredTransfersJob(); err != nil {
-				log.Printf("处理过期转账失败: %v", err)
-			} else {
-				log.Println("过期转账处理完成")
-			}
-		}
-	}()
-
-	// 设置优雅关闭
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-quit
-		log.Println("接收到关闭信号，正在停止服务器...")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		if err := server.Shutdown(ctx); err != nil {
-			log.Fatalf("服务器强制关闭: %v", err)
-		} else {
-			log.Println("服务器已优雅停止")
-		}
-	}()
-	// 启动服务器
-	log.Printf("服务器启动，监听地址: %s", util.Config.Address)
-	util.PrintStdout("teachat", util.Version(), "星际茶棚一>开门迎客")
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("服务器启动失败: %v", err)
-	}
-	log.Println("服务器已停止")
-	log.Println("星际茶棚 --> 打烊休息")
+	return mux
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	dao "teachat/DAO"
+	route "teachat/Route"
+	util "teachat/Util"
+	"testing"
+)
+
+// testDBErr 测试数据库不可用的原因，为 nil 时 DB 已连接
+var testDBErr error
+
+// TestMain 按 config.json 及环境变量加载配置、页面模版，并连接测试数据库，连接失败时需要数据库的测试会被跳过
+func TestMain(m *testing.M) {
+	if err := util.Setup("config.json", nil); err != nil {
+		fmt.Println("配置加载失败:", err)
+		os.Exit(1)
+	}
+	if err := route.LoadTemplates(); err != nil {
+		fmt.Println("模版加载失败:", err)
+		os.Exit(1)
+	}
+	_, testDBErr = dao.Open(util.Config.Database)
+	if testDBErr != nil {
+		fmt.Println("测试数据库不可用，跳过数据库相关测试:", testDBErr)
+	}
+	code := m.Run()
+	dao.Close()
+	os.Exit(code)
+}
+
+// requireDB 没有可用的测试数据库时跳过当前测试
+func requireDB(t *testing.T) {
+	t.Helper()
+	if testDBErr != nil {
+		t.Skip("需要数据库:", testDBErr)
+	}
+}
+
+// testServer 与 main 相同的路由器及全局中间件
+func testServer() http.Handler {
+	return route.Chain(newMux(), route.RequestID, route.AccessLog, route.Recover, route.CSRFProtect)
+}
+
+// 开除、暂停相关页面须经 RequireLogin 注册：未登船时跳转登船页面并带上原地址，不支持的请求方法返回405
+func TestTeamMemberDismissalRoutesRequireLogin(t *testing.T) {
+	h := testServer()
+	paths := []string{
+		"/v1/team/manage?uuid=x",
+		"/v1/team_members/fired?uuid=x",
+		"/v1/team_member/fire?id=1&m_email=x",
+		"/v1/team_member/dismissal/detail?uuid=x",
+	}
+	for _, path := range paths {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if loc := rec.Header().Get("Location"); rec.Code != http.StatusFound || !strings.HasPrefix(loc, "/v1/login?footprint=") {
+			t.Errorf("GET %s 未登船: %d %q，应跳转登船页面并带上原地址", path, rec.Code, loc)
+		}
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("PUT %s: %d，应为405", path, rec.Code)
+		}
+	}
+}
+
+// 已登船茶友访问开除、暂停相关页面不会被送回登船页面
+func TestTeamMemberDismissalRoutesLoggedIn(t *testing.T) {
+	requireDB(t)
+	user := dao.User{
+		Name:     "路由测试茶友",
+		Email:    "route_test_" + strings.ReplaceAll(dao.Random_UUID(), "-", "")[:12] + "@example.com",
+		Password: "Route-test-123",
+	}
+	if err := user.Create(); err != nil {
+		t.Fatalf("创建茶友失败: %v", err)
+	}
+	sess, err := user.CreateSession("route-test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("创建会话失败: %v", err)
+	}
+	defer sess.Delete()
+
+	h := testServer()
+	paths := []string{
+		"/v1/team/manage?uuid=not-exist",
+		"/v1/team_members/fired?uuid=not-exist",
+		"/v1/team_member/fire?id=0",
+		"/v1/team_member/dismissal/detail?uuid=not-exist",
+	}
+	for _, path := range paths {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "_cookie", Value: sess.Uuid})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if loc := rec.Header().Get("Location"); strings.HasPrefix(loc, "/v1/login") {
+			t.Errorf("GET %s 已登船仍被送回登船页面: %d %q", path, rec.Code, loc)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: %d，应显示页面或提示", path, rec.Code)
+		}
+	}
+}
+
+// 未登船的登船请求须带回 _csrf Cookie 中的令牌：登船页面下发 Cookie 并注入表单，缺少或不一致的令牌被拒绝
+func TestLoginRequiresPreSessionCsrfToken(t *testing.T) {
+	h := testServer()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/login", nil))
+	var token string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "_csrf" {
+			token = c.Value
+		}
+	}
+	if token == "" {
+		t.Fatalf("GET /v1/login 未下发 _csrf Cookie: %v", rec.Header()["Set-Cookie"])
+	}
+	if !strings.Contains(rec.Body.String(), `name="csrf_token" value="`+token+`"`) {
+		t.Fatal("登船页面表单未注入令牌")
+	}
+
+	post := func(cookie, field string) string {
+		form := url.Values{"email": {"nobody@example.com"}, "password": {"x"}}
+		if field != "" {
+			form.Set("csrf_token", field)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/v1/authenticate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "_csrf", Value: cookie})
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	const rejected = "来历不明"
+	if body := post("", ""); !strings.Contains(body, rejected) {
+		t.Error("没有令牌的登船请求未被拒绝")
+	}
+	if body := post(token, ""); !strings.Contains(body, rejected) {
+		t.Error("只有 Cookie 没有表单令牌的登船请求未被拒绝")
+	}
+	if body := post(token, strings.Repeat("0", 64)); !strings.Contains(body, rejected) {
+		t.Error("令牌与 Cookie 不一致的登船请求未被拒绝")
+	}
+	if testDBErr == nil {
+		if body := post(token, token); strings.Contains(body, rejected) {
+			t.Error("令牌与 Cookie 一致的登船请求被拒绝")
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// 页面脚本发起的状态变更请求自动附加CSRF令牌（令牌由服务端写入 <meta name="csrf-token">）
+(function () {
+  function csrfToken() {
+    var meta = document.querySelector('meta[name="csrf-token"]');
+    return meta ? meta.getAttribute('content') : '';
+  }
+
+  function isSafeMethod(method) {
+    return /^(GET|HEAD|OPTIONS|TRACE)$/i.test(method || 'GET');
+  }
+
+  function isSameOrigin(url) {
+    var a = document.createElement('a');
+    a.href = url;
+    return a.host === window.location.host;
+  }
+
+  if (window.fetch) {
+    var originalFetch = window.fetch;
+    window.fetch = function (input, init) {
+      init = init || {};
+      var url = typeof input === 'string' ? input : input.url;
+      var method = init.method || (typeof input === 'string' ? 'GET' : input.method);
+      var token = csrfToken();
+      if (token && !isSafeMethod(method) && isSameOrigin(url)) {
+        var headers = new Headers(init.headers || (typeof input === 'string' ? {} : input.headers));
+        headers.set('X-CSRF-Token', token);
+        init.headers = headers;
+      }
+      return originalFetch.call(this, input, init);
+    };
+  }
+
+  var originalSend = XMLHttpRequest.prototype.send;
+  var originalOpen = XMLHttpRequest.prototype.open;
+  XMLHttpRequest.prototype.open = function (method, url) {
+    this._csrfMethod = method;
+    this._csrfUrl = url;
+    return originalOpen.apply(this, arguments);
+  };
+  XMLHttpRequest.prototype.send = function () {
+    var token = csrfToken();
+    if (token && !isSafeMethod(this._csrfMethod) && isSameOrigin(this._csrfUrl)) {
+      this.setRequestHeader('X-CSRF-Token', token);
+    }
+    return originalSend.apply(this, arguments);
+  };
+})();
@@ -1,12 +1,6 @@
--- TeaChat 数据库架构定义
-
--- 创建数据库(如果需要)
---CREATE USER robin WITH PASSWORD 'robin';
---ALTER USER robin SUPERUSER;
---DROP DATABASE IF EXISTS teachat;
---CREATE DATABASE teachat OWNER robin;
--- 连接到新数据库
-\c teachat
+-- TeaChat 数据库架构定义（基线迁移）
+-- 原 sql/schema.sql，由 teachat migrate up 在当前数据库中执行，不再需要 \c 切换数据库。
+-- 已按旧方式手工建库的部署，执行 teachat migrate baseline 标记为已执行即可。
 
 -- ============================================
 -- 核心用户与组织表
@@ -128,16 +122,6 @@ CREATE TABLE team_members (
     deleted_at            TIMESTAMPTZ
 );
 
--- 某个team加入某个group记录
--- 注意：一个team只能加入一个group一次，已加入的team不能再加入其他group
-CREATE TABLE team_group_memberships (
-    id                    SERIAL PRIMARY KEY,
-    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
-    group_id              INTEGER REFERENCES groups(id),
-    team_id               INTEGER REFERENCES teams(id) UNIQUE,
-    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
-);
-
 -- 集团表
 -- 用于管理多个团队的集合，支持复杂的多团队协作场景
 CREATE TABLE groups (
@@ -159,6 +143,16 @@ CREATE TABLE groups (
 -- 为groups表添加nature字段（兼容旧数据库）
 ALTER TABLE groups ADD COLUMN IF NOT EXISTS nature INTEGER DEFAULT 0;
 
+-- 某个team加入某个group记录
+-- 注意：一个team只能加入一个group一次，已加入的team不能再加入其他group
+CREATE TABLE team_group_memberships (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    group_id              INTEGER REFERENCES groups(id),
+    team_id               INTEGER REFERENCES teams(id) UNIQUE,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 -- 集团成员表
 -- 记录团队在集团中的成员资格和层级关系
 CREATE TABLE group_members (
@@ -0,0 +1,5 @@
+-- 回滚星茶支付系统：删除tea schema及其全部表、视图、触发器，以及public下的辅助函数
+DROP SCHEMA IF EXISTS tea CASCADE;
+DROP FUNCTION IF EXISTS update_tea_updated_at() CASCADE;
+DROP FUNCTION IF EXISTS check_tea_balance_consistency();
+DROP FUNCTION IF EXISTS fix_tea_balance_consistency();
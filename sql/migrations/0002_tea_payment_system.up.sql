@@ -11,10 +11,7 @@
 -- DROP SCHEMA IF EXISTS tea CASCADE;
 -- ============================================
 
--- 删除旧的tea schema及其所有对象
-DROP SCHEMA IF EXISTS tea CASCADE;
-
--- 创建全新的tea schema
+-- 创建tea schema（原脚本先 DROP SCHEMA tea CASCADE 重建，迁移中不再删除已有数据）
 CREATE SCHEMA tea;
 
 -- ============================================
@@ -0,0 +1,11 @@
+-- 找回密码令牌表（只保存令牌的SHA-256摘要，一次性使用）
+CREATE TABLE password_resets (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    user_id               INTEGER NOT NULL REFERENCES users(id),
+    token_hash            VARCHAR(64) NOT NULL UNIQUE,
+    expires_at            TIMESTAMPTZ NOT NULL,
+    used_at               TIMESTAMPTZ,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS csrf_token,
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,9 @@
+-- 会话表：记录登船设备（浏览器、IP）、最近活动时间、过期时间及CSRF令牌
+ALTER TABLE sessions
+    ADD COLUMN user_agent   VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN ip           VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN last_seen_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN expires_at   TIMESTAMPTZ NOT NULL DEFAULT (CURRENT_TIMESTAMP + INTERVAL '7 days'),
+    ADD COLUMN csrf_token   VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS tea.tea_order_deposit_disputes;
+
+-- 已写入的罚没、退回及公道杯见证记录属于审计留痕，不删除也不改写：
+-- 约束以 NOT VALID 恢复，只约束回滚之后新写入的记录
+ALTER TABLE witness_logs DROP CONSTRAINT IF EXISTS witness_logs_action_check;
+ALTER TABLE witness_logs ADD CONSTRAINT witness_logs_action_check
+    CHECK (action IN ('审批', '暂停', '恢复', '终止')) NOT VALID;
@@ -0,0 +1,60 @@
+-- 见证记录增加公道杯（托管争议）相关动作
+ALTER TABLE witness_logs DROP CONSTRAINT IF EXISTS witness_logs_action_check;
+ALTER TABLE witness_logs ADD CONSTRAINT witness_logs_action_check
+    CHECK (action IN ('审批', '暂停', '恢复', '终止', '罚没', '退回', '争议', '答辩', '撤诉', '仲裁'));
+
+-- 茶订单预备金托管争议表（公道杯，完全匹配TeaOrderDepositDispute结构体）
+CREATE TABLE tea.tea_order_deposit_disputes (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  UUID UNIQUE NOT NULL DEFAULT gen_random_uuid(),
+    deposit_id            INTEGER NOT NULL REFERENCES tea.tea_order_deposits(id), -- 关联的托管记录ID
+    initiator_team_id     INTEGER NOT NULL REFERENCES teams(id), -- 发起争议方团队ID
+    respondent_team_id    INTEGER NOT NULL REFERENCES teams(id), -- 被诉方团队ID
+    initiator_user_id     INTEGER NOT NULL REFERENCES users(id), -- 发起争议的用户ID
+    reason                TEXT NOT NULL, -- 争议原因
+    status                INTEGER NOT NULL DEFAULT 1, -- 争议状态
+    respondent_user_id    INTEGER NOT NULL DEFAULT 0, -- 答辩用户ID，未答辩时为0
+    response              TEXT NOT NULL DEFAULT '-', -- 被诉方答辩内容
+    responded_at          TIMESTAMPTZ, -- 答辩时间
+    arbitrator_team_id    INTEGER REFERENCES teams(id), -- 仲裁方团队ID
+    arbitrator_user_id    INTEGER NOT NULL DEFAULT 0, -- 仲裁人用户ID，未仲裁时为0
+    arbitration_notes     TEXT NOT NULL DEFAULT '-', -- 仲裁说明
+    result                INTEGER NOT NULL DEFAULT 0, -- 仲裁结果
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    arbitrated_at         TIMESTAMPTZ, -- 仲裁完成时间
+    updated_at            TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at            TIMESTAMPTZ -- 软删除时间
+);
+
+-- 创建索引
+CREATE INDEX idx_tea_order_deposit_disputes_deposit_id ON tea.tea_order_deposit_disputes(deposit_id);
+CREATE INDEX idx_tea_order_deposit_disputes_status ON tea.tea_order_deposit_disputes(status);
+CREATE INDEX idx_tea_order_deposit_disputes_initiator_team_id ON tea.tea_order_deposit_disputes(initiator_team_id);
+CREATE INDEX idx_tea_order_deposit_disputes_respondent_team_id ON tea.tea_order_deposit_disputes(respondent_team_id);
+
+-- 同一托管记录同时最多只能有一条争议中的记录
+CREATE UNIQUE INDEX idx_tea_order_deposit_disputes_unique_pending
+    ON tea.tea_order_deposit_disputes(deposit_id)
+    WHERE status = 1 AND deleted_at IS NULL;
+
+-- 添加表注释
+COMMENT ON TABLE tea.tea_order_deposit_disputes IS '茶订单预备金托管争议表（公道杯）。需求方或解题方发起争议，见证者仲裁后托管星茶释放给胜出方';
+COMMENT ON COLUMN tea.tea_order_deposit_disputes.status IS '争议状态: 1-争议中, 2-已仲裁, 3-已撤销';
+COMMENT ON COLUMN tea.tea_order_deposit_disputes.result IS '仲裁结果: 0-未仲裁, 1-解题方胜出, 2-需求方胜出';
+
+-- 争议状态枚举约束
+ALTER TABLE tea.tea_order_deposit_disputes ADD CONSTRAINT check_tea_order_deposit_disputes_status 
+    CHECK (status IN (1, 2, 3));
+
+-- 仲裁结果枚举约束
+ALTER TABLE tea.tea_order_deposit_disputes ADD CONSTRAINT check_tea_order_deposit_disputes_result 
+    CHECK (result IN (0, 1, 2));
+
+-- 发起方与被诉方不能是同一团队
+ALTER TABLE tea.tea_order_deposit_disputes ADD CONSTRAINT check_tea_order_deposit_disputes_parties 
+    CHECK (initiator_team_id != respondent_team_id);
+
+-- 更新时间触发器
+CREATE TRIGGER tea_order_deposit_disputes_updated_at_trigger
+    BEFORE UPDATE ON tea.tea_order_deposit_disputes
+    FOR EACH ROW EXECUTE FUNCTION update_tea_updated_at();
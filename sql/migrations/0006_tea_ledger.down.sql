@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS tea.ledger_entries;
+DROP TABLE IF EXISTS tea.ledger_transactions;
+DROP FUNCTION IF EXISTS tea.check_ledger_transaction_balanced();
+DROP FUNCTION IF EXISTS tea.reject_ledger_change();
@@ -0,0 +1,117 @@
+-- ============================================
+-- 星茶复式记账流水（只追加）
+-- 每次 tea.user_accounts / tea.team_accounts 余额变动，在同一事务中写入一笔记账交易及借贷平衡的分录：
+-- 转出方记借（debit，余额减少），转入方记贷（credit，余额增加）；
+-- 系统发放/扣除的对方为系统账户（holder_type = 's'，holder_id = 0，星茶发行），不记余额。
+-- ============================================
+
+-- 记账交易表（完全匹配TeaLedgerTransaction结构体）
+CREATE TABLE tea.ledger_transactions (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    type                  VARCHAR(32) NOT NULL, -- 业务类型：transfer, escrow, escrow_release, refund, forfeit, system_grant, system_deduct, withdraw, opening_balance
+    reference_table       VARCHAR(64) NOT NULL DEFAULT '-', -- 关联业务记录所在表，例如 team_to_team_transfer_out
+    reference_id          INTEGER NOT NULL DEFAULT 0, -- 关联业务记录ID
+    initiator_user_id     INTEGER NOT NULL DEFAULT 0, -- 操作用户ID，系统自动处理时为0
+    notes                 TEXT NOT NULL DEFAULT '-',
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 记账分录表（完全匹配TeaLedgerEntry结构体）
+CREATE TABLE tea.ledger_entries (
+    id                    SERIAL PRIMARY KEY,
+    transaction_id        INTEGER NOT NULL REFERENCES tea.ledger_transactions(id),
+    entry_type            VARCHAR(32) NOT NULL, -- 账户视角的交易类型：transfer_out, transfer_in, system_grant, system_deduct, withdraw
+    holder_type           VARCHAR(1) NOT NULL, -- u:用户 t:团队 s:系统
+    holder_id             INTEGER NOT NULL,
+    direction             VARCHAR(8) NOT NULL, -- debit:余额减少 credit:余额增加
+    amount_milligrams     BIGINT NOT NULL,
+    balance_after         BIGINT, -- 记账后账户余额（毫克），系统账户为NULL
+    counterparty_type     VARCHAR(1) NOT NULL,
+    counterparty_id       INTEGER NOT NULL,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_ledger_entries_holder_type CHECK (holder_type IN ('u', 't', 's')),
+    CONSTRAINT check_ledger_entries_counterparty_type CHECK (counterparty_type IN ('u', 't', 's')),
+    CONSTRAINT check_ledger_entries_direction CHECK (direction IN ('debit', 'credit')),
+    CONSTRAINT check_ledger_entries_amount CHECK (amount_milligrams > 0)
+);
+
+CREATE INDEX idx_ledger_entries_holder ON tea.ledger_entries(holder_type, holder_id, id DESC);
+CREATE INDEX idx_ledger_entries_transaction_id ON tea.ledger_entries(transaction_id);
+CREATE INDEX idx_ledger_transactions_reference ON tea.ledger_transactions(reference_table, reference_id);
+
+COMMENT ON TABLE tea.ledger_transactions IS '星茶记账交易（只追加）。每笔交易的借方合计等于贷方合计';
+COMMENT ON TABLE tea.ledger_entries IS '星茶记账分录（只追加）。debit表示账户余额减少，credit表示账户余额增加';
+
+-- 借贷平衡检查：事务提交时，每笔记账交易的借方合计必须等于贷方合计
+CREATE OR REPLACE FUNCTION tea.check_ledger_transaction_balanced()
+RETURNS TRIGGER AS $$
+DECLARE
+    debit_total  BIGINT;
+    credit_total BIGINT;
+BEGIN
+    SELECT COALESCE(SUM(amount_milligrams) FILTER (WHERE direction = 'debit'), 0),
+           COALESCE(SUM(amount_milligrams) FILTER (WHERE direction = 'credit'), 0)
+    INTO debit_total, credit_total
+    FROM tea.ledger_entries
+    WHERE transaction_id = NEW.transaction_id;
+
+    IF debit_total <> credit_total THEN
+        RAISE EXCEPTION '星茶记账交易 % 借贷不平衡：借方 %，贷方 %', NEW.transaction_id, debit_total, credit_total;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced_trigger
+    AFTER INSERT ON tea.ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION tea.check_ledger_transaction_balanced();
+
+-- 只追加：禁止修改或删除已记账的流水，更正须另记一笔反向交易
+CREATE OR REPLACE FUNCTION tea.reject_ledger_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '星茶记账流水只允许追加，不能修改或删除（%）', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_transactions_append_only_trigger
+    BEFORE UPDATE OR DELETE ON tea.ledger_transactions
+    FOR EACH ROW EXECUTE FUNCTION tea.reject_ledger_change();
+
+CREATE TRIGGER ledger_entries_append_only_trigger
+    BEFORE UPDATE OR DELETE ON tea.ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION tea.reject_ledger_change();
+
+-- 期初余额：为已有余额的账户各记一笔由系统账户转入的交易，使流水合计与当前余额一致
+INSERT INTO tea.ledger_transactions (type, reference_table, reference_id, notes)
+SELECT 'opening_balance', 'user_accounts', user_id, '启用记账流水时的期初余额'
+FROM tea.user_accounts WHERE balance_milligrams <> 0;
+
+INSERT INTO tea.ledger_transactions (type, reference_table, reference_id, notes)
+SELECT 'opening_balance', 'team_accounts', team_id, '启用记账流水时的期初余额'
+FROM tea.team_accounts WHERE balance_milligrams <> 0;
+
+INSERT INTO tea.ledger_entries (transaction_id, entry_type, holder_type, holder_id, direction, amount_milligrams, balance_after, counterparty_type, counterparty_id)
+SELECT lt.id, 'system_grant', 'u', a.user_id,
+       CASE WHEN a.balance_milligrams > 0 THEN 'credit' ELSE 'debit' END,
+       ABS(a.balance_milligrams), a.balance_milligrams, 's', 0
+FROM tea.ledger_transactions lt
+JOIN tea.user_accounts a ON lt.reference_table = 'user_accounts' AND lt.reference_id = a.user_id
+WHERE lt.type = 'opening_balance'
+UNION ALL
+SELECT lt.id, 'system_grant', 't', a.team_id,
+       CASE WHEN a.balance_milligrams > 0 THEN 'credit' ELSE 'debit' END,
+       ABS(a.balance_milligrams), a.balance_milligrams, 's', 0
+FROM tea.ledger_transactions lt
+JOIN tea.team_accounts a ON lt.reference_table = 'team_accounts' AND lt.reference_id = a.team_id
+WHERE lt.type = 'opening_balance';
+
+INSERT INTO tea.ledger_entries (transaction_id, entry_type, holder_type, holder_id, direction, amount_milligrams, balance_after, counterparty_type, counterparty_id)
+SELECT e.transaction_id, 'system_grant', 's', 0,
+       CASE WHEN e.direction = 'credit' THEN 'debit' ELSE 'credit' END,
+       e.amount_milligrams, NULL, e.holder_type, e.holder_id
+FROM tea.ledger_entries e
+JOIN tea.ledger_transactions lt ON lt.id = e.transaction_id
+WHERE lt.type = 'opening_balance';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tea.reconciliation_findings;
+DROP TABLE IF EXISTS tea.reconciliation_runs;
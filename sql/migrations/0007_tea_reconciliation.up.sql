@@ -0,0 +1,38 @@
+-- ============================================
+-- 星茶余额对账
+-- 对账按转账、托管记录及记账流水重新计算每个账户应有的锁定余额与余额，
+-- 与 tea.user_accounts / tea.team_accounts 的实际值比较，差异记入对账发现表。
+-- ============================================
+
+-- 对账批次表（完全匹配TeaReconcileRun结构体）
+CREATE TABLE tea.reconciliation_runs (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    trigger               VARCHAR(16) NOT NULL, -- 触发方式：cli, job
+    freeze                BOOLEAN NOT NULL DEFAULT FALSE, -- 是否冻结有差异的账户
+    accounts_checked      INTEGER NOT NULL DEFAULT 0,
+    findings_count        INTEGER NOT NULL DEFAULT 0,
+    started_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at           TIMESTAMPTZ
+);
+
+-- 对账发现表（完全匹配TeaReconcileFinding结构体）
+CREATE TABLE tea.reconciliation_findings (
+    id                    SERIAL PRIMARY KEY,
+    run_id                INTEGER NOT NULL REFERENCES tea.reconciliation_runs(id) ON DELETE CASCADE,
+    holder_type           VARCHAR(1) NOT NULL, -- u:用户 t:团队
+    holder_id             INTEGER NOT NULL,
+    kind                  VARCHAR(32) NOT NULL, -- locked_mismatch, negative_available, ledger_mismatch, escrow_shortfall
+    expected_milligrams   BIGINT NOT NULL, -- 按转账、托管记录或流水计算的应有值
+    actual_milligrams     BIGINT NOT NULL, -- 账户表中的实际值
+    frozen                BOOLEAN NOT NULL DEFAULT FALSE, -- 本次对账是否冻结了该账户
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_reconciliation_findings_holder_type CHECK (holder_type IN ('u', 't'))
+);
+
+CREATE INDEX idx_reconciliation_findings_run_id ON tea.reconciliation_findings(run_id);
+CREATE INDEX idx_reconciliation_findings_holder ON tea.reconciliation_findings(holder_type, holder_id);
+CREATE INDEX idx_reconciliation_runs_started_at ON tea.reconciliation_runs(started_at DESC);
+
+COMMENT ON TABLE tea.reconciliation_runs IS '星茶余额对账批次';
+COMMENT ON TABLE tea.reconciliation_findings IS '星茶余额对账发现的账户差异';
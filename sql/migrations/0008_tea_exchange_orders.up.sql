@@ -0,0 +1,46 @@
+-- ============================================
+-- 茶庄星茶兑换订单：购买（发行星茶）与兑现（回收星茶）
+-- 购买：pending_payment →（支付渠道到账）paid →（茶博士/船长审核）completed 入账，或 rejected 退款；未付款可 cancelled
+-- 兑现：创建时锁定星茶 pending_review →（审核通过，扣减余额）approved →（渠道付款成功）completed；
+--       审核否决 rejected 或申请人撤回 cancelled 时解锁
+-- 价格：1元/克，即 10 毫克 = 1 分
+-- ============================================
+
+-- 星茶兑换订单表（完全匹配TeaExchangeOrder结构体）
+CREATE TABLE tea.exchange_orders (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    kind                  VARCHAR(16) NOT NULL, -- purchase:购买 redeem:兑现
+    holder_type           VARCHAR(1) NOT NULL, -- u:用户 t:团队
+    holder_id             INTEGER NOT NULL,
+    requester_user_id     INTEGER NOT NULL REFERENCES users(id),
+    amount_milligrams     BIGINT NOT NULL,
+    amount_fen            BIGINT NOT NULL, -- 对应的人民币金额（分）
+    provider              VARCHAR(32) NOT NULL, -- 支付渠道名称
+    provider_reference    VARCHAR(128), -- 渠道收款单号（购买）或付款单号（兑现）
+    payment_instructions  TEXT NOT NULL DEFAULT '-', -- 付款说明
+    payout_account        VARCHAR(255) NOT NULL DEFAULT '-', -- 兑现收款账户
+    status                VARCHAR(32) NOT NULL,
+    reviewer_user_id      INTEGER, -- 审核人（茶博士/船长）
+    review_note           TEXT NOT NULL DEFAULT '-',
+    last_error            TEXT NOT NULL DEFAULT '-', -- 最近一次渠道调用失败原因
+    paid_at               TIMESTAMPTZ,
+    reviewed_at           TIMESTAMPTZ,
+    completed_at          TIMESTAMPTZ,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_exchange_orders_kind CHECK (kind IN ('purchase', 'redeem')),
+    CONSTRAINT check_exchange_orders_holder_type CHECK (holder_type IN ('u', 't')),
+    CONSTRAINT check_exchange_orders_amount CHECK (amount_milligrams > 0 AND amount_fen > 0),
+    CONSTRAINT check_exchange_orders_status CHECK (status IN ('pending_payment', 'paid', 'pending_review', 'approved', 'completed', 'rejected', 'cancelled'))
+);
+
+CREATE UNIQUE INDEX idx_exchange_orders_provider_reference ON tea.exchange_orders(provider, provider_reference) WHERE provider_reference IS NOT NULL;
+CREATE INDEX idx_exchange_orders_holder ON tea.exchange_orders(holder_type, holder_id, id DESC);
+CREATE INDEX idx_exchange_orders_status ON tea.exchange_orders(status);
+
+CREATE TRIGGER update_exchange_orders_updated_at
+    BEFORE UPDATE ON tea.exchange_orders
+    FOR EACH ROW EXECUTE FUNCTION update_tea_updated_at();
+
+COMMENT ON TABLE tea.exchange_orders IS '茶庄星茶兑换订单（购买/兑现）';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tea.team_transfer_approvals;
+DROP TABLE IF EXISTS tea.team_transfer_policies;
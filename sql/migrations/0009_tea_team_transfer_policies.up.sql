@@ -0,0 +1,43 @@
+-- ============================================
+-- 团队星茶转出审批策略（多人签批）
+-- 每个团队星茶账户可设一条策略：超过阈值的转出须 N 位不同核心成员批准；可禁止发起人批准自己的转账；可设每日转出上限。
+-- 没有策略的团队沿用原规则：任一核心成员批准即可，不限每日转出。
+-- 每次批准记一行，满足策略后转账才进入待接收（pending_receipt）。
+-- ============================================
+
+-- 团队转出审批策略表（完全匹配TeaTeamTransferPolicy结构体）
+CREATE TABLE tea.team_transfer_policies (
+    id                                SERIAL PRIMARY KEY,
+    team_id                           INTEGER NOT NULL UNIQUE REFERENCES teams(id),
+    large_amount_threshold_milligrams BIGINT NOT NULL DEFAULT 0, -- 大额阈值，超过时须 large_amount_approvals 人批准，0表示不区分大额
+    large_amount_approvals            INTEGER NOT NULL DEFAULT 1, -- 大额转出须批准人数
+    forbid_self_approval              BOOLEAN NOT NULL DEFAULT FALSE, -- 禁止发起人批准自己发起的转账
+    daily_outflow_cap_milligrams      BIGINT NOT NULL DEFAULT 0, -- 每日转出上限，0表示不限
+    updated_by_user_id                INTEGER NOT NULL REFERENCES users(id),
+    created_at                        TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at                        TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_team_transfer_policies_threshold CHECK (large_amount_threshold_milligrams >= 0),
+    CONSTRAINT check_team_transfer_policies_approvals CHECK (large_amount_approvals BETWEEN 1 AND 9),
+    CONSTRAINT check_team_transfer_policies_daily_cap CHECK (daily_outflow_cap_milligrams >= 0)
+);
+
+CREATE TRIGGER update_team_transfer_policies_updated_at
+    BEFORE UPDATE ON tea.team_transfer_policies
+    FOR EACH ROW EXECUTE FUNCTION update_tea_updated_at();
+
+-- 团队转出批准记录表（完全匹配TeaTeamTransferApproval结构体）
+CREATE TABLE tea.team_transfer_approvals (
+    id                    SERIAL PRIMARY KEY,
+    transfer_table        VARCHAR(32) NOT NULL, -- team_to_user_transfer_out, team_to_team_transfer_out
+    transfer_id           INTEGER NOT NULL,
+    team_id               INTEGER NOT NULL,
+    approver_user_id      INTEGER NOT NULL REFERENCES users(id),
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_team_transfer_approvals_table CHECK (transfer_table IN ('team_to_user_transfer_out', 'team_to_team_transfer_out')),
+    CONSTRAINT uq_team_transfer_approvals_approver UNIQUE (transfer_table, transfer_id, approver_user_id)
+);
+
+CREATE INDEX idx_team_transfer_approvals_transfer ON tea.team_transfer_approvals(transfer_table, transfer_id);
+
+COMMENT ON TABLE tea.team_transfer_policies IS '团队星茶转出审批策略';
+COMMENT ON TABLE tea.team_transfer_approvals IS '团队星茶转出的逐人批准记录';
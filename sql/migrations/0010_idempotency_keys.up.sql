@@ -0,0 +1,27 @@
+-- ============================================
+-- 幂等键：客户端为会产生副作用的请求（例如发起星茶转账）附带 Idempotency-Key，
+-- 服务端保存首次请求的参数摘要及响应，保留期内同一茶友、同一接口、同一键的重放直接返回原响应；
+-- 同一键携带不同参数时拒绝（409）。过期记录由定时任务清理。
+-- ============================================
+
+-- 幂等键表（完全匹配IdempotencyRecord结构体）
+CREATE TABLE idempotency_keys (
+    id                    SERIAL PRIMARY KEY,
+    user_id               INTEGER NOT NULL REFERENCES users(id),
+    endpoint              VARCHAR(255) NOT NULL, -- 请求方法及路径
+    idempotency_key       VARCHAR(128) NOT NULL,
+    request_hash          VARCHAR(64) NOT NULL, -- 请求参数摘要（sha256十六进制）
+    status                VARCHAR(16) NOT NULL DEFAULT 'processing', -- processing:处理中 completed:已完成
+    response_status       INTEGER NOT NULL DEFAULT 0,
+    response_content_type VARCHAR(128) NOT NULL DEFAULT '',
+    response_body         BYTEA,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at          TIMESTAMPTZ,
+    expires_at            TIMESTAMPTZ NOT NULL,
+    CONSTRAINT check_idempotency_keys_status CHECK (status IN ('processing', 'completed')),
+    CONSTRAINT uq_idempotency_keys_user_endpoint_key UNIQUE (user_id, endpoint, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+COMMENT ON TABLE idempotency_keys IS '幂等键及首次响应，防止重复提交';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tea.team_transfer_schedule_runs;
+DROP TABLE IF EXISTS tea.team_transfer_schedules;
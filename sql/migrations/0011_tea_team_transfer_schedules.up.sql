@@ -0,0 +1,60 @@
+-- ============================================
+-- 团队定期星茶转账（定期转账委托）
+-- 团队成员设定一次性（指定时间）或每周、每月重复的团队转出，到期时由后台任务以设定人名义
+-- 按正常流程发起转账（锁定金额、待核心成员审批），每次执行记一行；
+-- 余额不足、账户冻结等失败记入执行记录并通知团队全体成员。
+-- ============================================
+
+-- 团队定期转账表（完全匹配TeaTeamTransferSchedule结构体）
+CREATE TABLE tea.team_transfer_schedules (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    team_id               INTEGER NOT NULL REFERENCES teams(id),
+    created_by_user_id    INTEGER NOT NULL REFERENCES users(id), -- 设定人，也是每笔转账的发起人
+    target_type           VARCHAR(1) NOT NULL, -- u:用户 t:团队
+    target_id             INTEGER NOT NULL,
+    target_name           VARCHAR(255) NOT NULL,
+    amount_milligrams     BIGINT NOT NULL,
+    notes                 VARCHAR(255) NOT NULL DEFAULT '',
+    expire_hours          INTEGER NOT NULL DEFAULT 24, -- 生成转账的待接收时限
+    frequency             VARCHAR(16) NOT NULL, -- once:一次性 weekly:每周 monthly:每月
+    start_at              TIMESTAMPTZ NOT NULL, -- 首次执行时间，重复规则以此为锚点
+    end_at                TIMESTAMPTZ, -- 截止时间，之后不再执行
+    next_run_at           TIMESTAMPTZ, -- 下次执行时间，结束后为空
+    last_run_at           TIMESTAMPTZ,
+    run_count             INTEGER NOT NULL DEFAULT 0,
+    failure_count         INTEGER NOT NULL DEFAULT 0, -- 连续失败次数，成功后清零
+    last_error            TEXT NOT NULL DEFAULT '',
+    status                VARCHAR(16) NOT NULL DEFAULT 'active', -- active:生效 paused:暂停 cancelled:已取消 completed:已结束
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_team_transfer_schedules_target_type CHECK (target_type IN ('u', 't')),
+    CONSTRAINT check_team_transfer_schedules_amount CHECK (amount_milligrams > 0),
+    CONSTRAINT check_team_transfer_schedules_frequency CHECK (frequency IN ('once', 'weekly', 'monthly')),
+    CONSTRAINT check_team_transfer_schedules_status CHECK (status IN ('active', 'paused', 'cancelled', 'completed'))
+);
+
+CREATE INDEX idx_team_transfer_schedules_team ON tea.team_transfer_schedules(team_id);
+CREATE INDEX idx_team_transfer_schedules_due ON tea.team_transfer_schedules(next_run_at) WHERE status = 'active';
+
+CREATE TRIGGER update_team_transfer_schedules_updated_at
+    BEFORE UPDATE ON tea.team_transfer_schedules
+    FOR EACH ROW EXECUTE FUNCTION update_tea_updated_at();
+
+-- 团队定期转账执行记录表（完全匹配TeaTeamTransferScheduleRun结构体）
+CREATE TABLE tea.team_transfer_schedule_runs (
+    id                    SERIAL PRIMARY KEY,
+    schedule_id           INTEGER NOT NULL REFERENCES tea.team_transfer_schedules(id),
+    scheduled_for         TIMESTAMPTZ NOT NULL, -- 本次应执行时间
+    status                VARCHAR(16) NOT NULL DEFAULT 'running', -- running:执行中 created:已发起转账 failed:失败
+    transfer_table        VARCHAR(32) NOT NULL DEFAULT '', -- team_to_user_transfer_out, team_to_team_transfer_out
+    transfer_uuid         VARCHAR(64) NOT NULL DEFAULT '',
+    error                 TEXT NOT NULL DEFAULT '',
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at           TIMESTAMPTZ,
+    CONSTRAINT check_team_transfer_schedule_runs_status CHECK (status IN ('running', 'created', 'failed')),
+    CONSTRAINT uq_team_transfer_schedule_runs_occurrence UNIQUE (schedule_id, scheduled_for)
+);
+
+COMMENT ON TABLE tea.team_transfer_schedules IS '团队定期星茶转账设定';
+COMMENT ON TABLE tea.team_transfer_schedule_runs IS '团队定期星茶转账的逐次执行记录';
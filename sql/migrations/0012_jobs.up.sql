@@ -0,0 +1,49 @@
+-- ============================================
+-- 后台任务：清理过期会话、处理超时转账、星茶余额对账等维护任务由调度器按计划执行。
+-- jobs 保存每个任务的计划、下次执行时间及最近一次执行状态，多个实例共用，
+-- 取得任务咨询锁的实例以 next_run_at 为准判断本周期是否已由其他实例执行；
+-- job_runs 保存执行记录，保留30天。
+-- ============================================
+
+-- 后台任务表（完全匹配JobState结构体）
+CREATE TABLE jobs (
+    name             VARCHAR(64) PRIMARY KEY,
+    description      VARCHAR(255) NOT NULL DEFAULT '',
+    schedule         VARCHAR(128) NOT NULL DEFAULT '', -- @every 30m 或五段 cron 表达式，off 表示停用
+    enabled          BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at      TIMESTAMPTZ,
+    last_started_at  TIMESTAMPTZ,
+    last_finished_at TIMESTAMPTZ,
+    last_status      VARCHAR(16) NOT NULL DEFAULT '', -- running:执行中 succeeded:成功 failed:失败
+    last_trigger     VARCHAR(16) NOT NULL DEFAULT '', -- schedule:按计划 manual:手动
+    last_message     TEXT NOT NULL DEFAULT '',
+    last_duration_ms BIGINT NOT NULL DEFAULT 0,
+    last_instance    VARCHAR(128) NOT NULL DEFAULT '',
+    run_count        INTEGER NOT NULL DEFAULT 0,
+    failure_count    INTEGER NOT NULL DEFAULT 0, -- 连续失败次数，成功后清零
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_jobs_last_status CHECK (last_status IN ('', 'running', 'succeeded', 'failed'))
+);
+
+COMMENT ON TABLE jobs IS '后台任务计划及最近执行状态';
+
+-- 后台任务执行记录表（完全匹配JobRun结构体）
+CREATE TABLE job_runs (
+    id          SERIAL PRIMARY KEY,
+    job_name    VARCHAR(64) NOT NULL REFERENCES jobs(name) ON DELETE CASCADE,
+    trigger     VARCHAR(16) NOT NULL,
+    status      VARCHAR(16) NOT NULL DEFAULT 'running',
+    message     TEXT NOT NULL DEFAULT '',
+    instance    VARCHAR(128) NOT NULL DEFAULT '',
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT check_job_runs_trigger CHECK (trigger IN ('schedule', 'manual')),
+    CONSTRAINT check_job_runs_status CHECK (status IN ('running', 'succeeded', 'failed'))
+);
+
+CREATE INDEX idx_job_runs_job_name_started_at ON job_runs(job_name, started_at DESC);
+CREATE INDEX idx_job_runs_started_at ON job_runs(started_at);
+
+COMMENT ON TABLE job_runs IS '后台任务执行记录';
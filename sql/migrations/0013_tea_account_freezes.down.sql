@@ -0,0 +1,3 @@
+ALTER TABLE tea.account_freeze_events DROP CONSTRAINT IF EXISTS fk_account_freeze_events_appeal;
+DROP TABLE IF EXISTS tea.account_freeze_appeals;
+DROP TABLE IF EXISTS tea.account_freeze_events;
@@ -0,0 +1,63 @@
+-- ============================================
+-- 星茶账户冻结记录及申诉
+-- 每次冻结、解冻记一行（操作人、原因、相关凭据、解冻期限、之前的状态），账户表的 status/frozen_reason 仍为当前状态；
+-- 被冻结账户的持有人（茶友本人或团队核心成员）可提出申诉，由茶博士审核，批准即解冻，审核结论写回冻结记录。
+-- ============================================
+
+-- 星茶账户冻结记录表（完全匹配TeaAccountFreezeEvent结构体）
+CREATE TABLE tea.account_freeze_events (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    holder_type           VARCHAR(1) NOT NULL, -- u:用户 t:团队
+    holder_id             INTEGER NOT NULL,
+    action                VARCHAR(16) NOT NULL, -- freeze:冻结 unfreeze:解冻
+    source                VARCHAR(16) NOT NULL, -- manual:人工 reconcile:对账 auto:到期自动解冻 appeal:申诉获准
+    reason                TEXT NOT NULL DEFAULT '',
+    operator_user_id      INTEGER REFERENCES users(id), -- 系统操作时为空
+    evidence_id           INTEGER REFERENCES evidences(id),
+    unfreeze_at           TIMESTAMPTZ, -- 冻结时设定的自动解冻期限
+    appeal_id             INTEGER, -- 申诉获准解冻时对应的申诉
+    previous_status       VARCHAR(16) NOT NULL DEFAULT '',
+    previous_reason       TEXT NOT NULL DEFAULT '',
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_account_freeze_events_holder_type CHECK (holder_type IN ('u', 't')),
+    CONSTRAINT check_account_freeze_events_action CHECK (action IN ('freeze', 'unfreeze')),
+    CONSTRAINT check_account_freeze_events_source CHECK (source IN ('manual', 'reconcile', 'auto', 'appeal'))
+);
+
+CREATE INDEX idx_account_freeze_events_holder ON tea.account_freeze_events(holder_type, holder_id, id DESC);
+CREATE INDEX idx_account_freeze_events_unfreeze_at ON tea.account_freeze_events(unfreeze_at) WHERE unfreeze_at IS NOT NULL;
+
+-- 星茶账户冻结申诉表（完全匹配TeaFreezeAppeal结构体）
+CREATE TABLE tea.account_freeze_appeals (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    freeze_event_id       INTEGER REFERENCES tea.account_freeze_events(id), -- 申诉针对的冻结记录，本表建立前冻结的账户为空
+    holder_type           VARCHAR(1) NOT NULL,
+    holder_id             INTEGER NOT NULL,
+    appellant_user_id     INTEGER NOT NULL REFERENCES users(id),
+    statement             TEXT NOT NULL,
+    evidence_id           INTEGER REFERENCES evidences(id),
+    status                VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending:待审核 approved:获准解冻 rejected:驳回 closed:账户已另行解冻
+    reviewer_user_id      INTEGER REFERENCES users(id),
+    review_note           TEXT NOT NULL DEFAULT '',
+    reviewed_at           TIMESTAMPTZ,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_account_freeze_appeals_holder_type CHECK (holder_type IN ('u', 't')),
+    CONSTRAINT check_account_freeze_appeals_status CHECK (status IN ('pending', 'approved', 'rejected', 'closed'))
+);
+
+-- 同一账户同时只能有一份待审核申诉
+CREATE UNIQUE INDEX uq_account_freeze_appeals_pending ON tea.account_freeze_appeals(holder_type, holder_id) WHERE status = 'pending';
+CREATE INDEX idx_account_freeze_appeals_event ON tea.account_freeze_appeals(freeze_event_id);
+
+ALTER TABLE tea.account_freeze_events
+    ADD CONSTRAINT fk_account_freeze_events_appeal FOREIGN KEY (appeal_id) REFERENCES tea.account_freeze_appeals(id);
+
+CREATE TRIGGER update_account_freeze_appeals_updated_at
+    BEFORE UPDATE ON tea.account_freeze_appeals
+    FOR EACH ROW EXECUTE FUNCTION update_tea_updated_at();
+
+COMMENT ON TABLE tea.account_freeze_events IS '星茶账户冻结、解冻记录';
+COMMENT ON TABLE tea.account_freeze_appeals IS '星茶账户冻结申诉';
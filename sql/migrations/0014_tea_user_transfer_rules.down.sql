@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tea.user_transfer_rule_decisions;
@@ -0,0 +1,42 @@
+-- ============================================
+-- 茶友对茶友星茶转账风控规则判定记录
+-- 每次发起转账先按规则（单笔上限、每日上限、新账户限额、向同一接收方的笔数、利益回避）判定，判定结果记一行：
+-- allow:放行，已创建转账（transfer_id）；hold:命中规则，暂不锁定星茶，待茶博士审核，批准后才创建转账。
+-- ============================================
+
+-- 转账风控判定记录表（完全匹配TeaUserTransferDecision结构体）
+CREATE TABLE tea.user_transfer_rule_decisions (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    from_user_id          INTEGER NOT NULL REFERENCES users(id),
+    from_user_name        VARCHAR(255) NOT NULL,
+    to_user_id            INTEGER NOT NULL REFERENCES users(id),
+    to_user_name          VARCHAR(255) NOT NULL,
+    amount_milligrams     BIGINT NOT NULL,
+    notes                 TEXT NOT NULL DEFAULT '-',
+    expire_hours          INTEGER NOT NULL,
+    outcome               VARCHAR(16) NOT NULL, -- allow:放行 hold:待审核
+    rules                 TEXT NOT NULL DEFAULT '', -- 命中的规则名，逗号分隔
+    reasons               TEXT NOT NULL DEFAULT '', -- 命中规则的说明
+    transfer_id           INTEGER REFERENCES tea.user_to_user_transfer_out(id), -- 放行或审核批准后创建的转账
+    review_status         VARCHAR(16) NOT NULL DEFAULT '', -- 放行时为空；pending:待审核 approved:批准 rejected:驳回 cancelled:发起人撤回
+    reviewer_user_id      INTEGER REFERENCES users(id),
+    review_note           TEXT NOT NULL DEFAULT '',
+    reviewed_at           TIMESTAMPTZ,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_user_transfer_rule_decisions_amount CHECK (amount_milligrams > 0),
+    CONSTRAINT check_user_transfer_rule_decisions_outcome CHECK (outcome IN ('allow', 'hold')),
+    CONSTRAINT check_user_transfer_rule_decisions_review CHECK (
+        (outcome = 'allow' AND review_status = '') OR
+        (outcome = 'hold' AND review_status IN ('pending', 'approved', 'rejected', 'cancelled')))
+);
+
+CREATE INDEX idx_user_transfer_rule_decisions_from_user ON tea.user_transfer_rule_decisions(from_user_id, created_at DESC);
+CREATE INDEX idx_user_transfer_rule_decisions_pending ON tea.user_transfer_rule_decisions(created_at) WHERE review_status = 'pending';
+
+CREATE TRIGGER update_user_transfer_rule_decisions_updated_at
+    BEFORE UPDATE ON tea.user_transfer_rule_decisions
+    FOR EACH ROW EXECUTE FUNCTION update_tea_updated_at();
+
+COMMENT ON TABLE tea.user_transfer_rule_decisions IS '茶友对茶友星茶转账风控规则判定记录，命中规则的转账待茶博士审核';
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS accept_assignments;
@@ -0,0 +1,29 @@
+-- ============================================
+-- 友邻蒙评评审官指派记录
+-- 每次指派评审官记一行（首次指派或超时改派），评审官提交意见后标记为已答复，
+-- 超过期限仍未答复的由后台任务标记为超时并改派他人；本表建立前的蒙评对象没有指派记录。
+-- ============================================
+
+-- 友邻蒙评指派表（完全匹配AcceptAssignment结构体）
+CREATE TABLE accept_assignments (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    accept_object_id      INTEGER NOT NULL REFERENCES accept_objects(id),
+    reviewer_user_id      INTEGER NOT NULL REFERENCES users(id),
+    author_user_id        INTEGER NOT NULL DEFAULT 0, -- 被评审内容的作者
+    status                VARCHAR(16) NOT NULL DEFAULT 'assigned', -- assigned:待答复 answered:已答复 timed_out:超时改派
+    reason                VARCHAR(16) NOT NULL DEFAULT 'initial', -- initial:首次指派 reassign:超时改派
+    replaces_assignment_id INTEGER REFERENCES accept_assignments(id), -- 改派时被替换的指派
+    assigned_at           TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deadline_at           TIMESTAMPTZ NOT NULL,
+    answered_at           TIMESTAMPTZ,
+    timed_out_at          TIMESTAMPTZ,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_accept_assignments_status CHECK (status IN ('assigned', 'answered', 'timed_out')),
+    CONSTRAINT check_accept_assignments_reason CHECK (reason IN ('initial', 'reassign')),
+    CONSTRAINT uq_accept_assignments_object_reviewer UNIQUE (accept_object_id, reviewer_user_id)
+);
+
+CREATE INDEX idx_accept_assignments_object ON accept_assignments(accept_object_id);
+CREATE INDEX idx_accept_assignments_reviewer_open ON accept_assignments(reviewer_user_id) WHERE status = 'assigned';
+CREATE INDEX idx_accept_assignments_deadline ON accept_assignments(deadline_at) WHERE status = 'assigned';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS accept_reviewer_stats;
+DROP TABLE IF EXISTS accept_review_checks;
+DROP TABLE IF EXISTS accept_review_votes;
@@ -0,0 +1,76 @@
+-- ============================================
+-- 友邻蒙评评审官信誉
+-- 每位评审官对每个蒙评对象的意见记一行，另一位答复后记下两人是否一致；
+-- 茶博士抽查复核（或申诉复审）给出结论后，意见与结论一致记为维持，否则记为推翻；
+-- accept_reviewer_stats 为按意见、指派记录汇总的统计及信誉分，意见、复核、超时后更新，后台任务定期全量刷新。
+-- ============================================
+
+-- 评审意见表（完全匹配AcceptReviewVote结构体）
+CREATE TABLE accept_review_votes (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    accept_object_id      INTEGER NOT NULL REFERENCES accept_objects(id),
+    reviewer_user_id      INTEGER NOT NULL REFERENCES users(id),
+    accept                BOOLEAN NOT NULL,
+    co_reviewer_user_id   INTEGER, -- 另一位评审官，尚未答复时为空
+    agreed                BOOLEAN, -- 与另一位评审官意见是否一致，尚无对照时为空
+    decided_alone         BOOLEAN NOT NULL DEFAULT false, -- 可信评审官在另一位超时后独自裁定
+    outcome               VARCHAR(16) NOT NULL DEFAULT '', -- 空:尚无复核 upheld:维持 overturned:推翻
+    outcome_at            TIMESTAMPTZ,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_accept_review_votes_outcome CHECK (outcome IN ('', 'upheld', 'overturned')),
+    CONSTRAINT uq_accept_review_votes_object_reviewer UNIQUE (accept_object_id, reviewer_user_id)
+);
+
+CREATE INDEX idx_accept_review_votes_reviewer ON accept_review_votes(reviewer_user_id, id DESC);
+
+-- 复核记录表（完全匹配AcceptReviewCheck结构体）
+CREATE TABLE accept_review_checks (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    accept_object_id      INTEGER NOT NULL REFERENCES accept_objects(id),
+    checker_user_id       INTEGER REFERENCES users(id),
+    source                VARCHAR(16) NOT NULL, -- spot_check:茶博士抽查 appeal:申诉复审
+    verdict_accepted      BOOLEAN NOT NULL, -- 友邻蒙评的结论
+    correct_accepted      BOOLEAN NOT NULL, -- 复核认定的结论
+    note                  TEXT NOT NULL DEFAULT '',
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_accept_review_checks_source CHECK (source IN ('spot_check', 'appeal'))
+);
+
+CREATE INDEX idx_accept_review_checks_object ON accept_review_checks(accept_object_id);
+
+-- 评审官统计表（完全匹配AcceptReviewerStats结构体）
+CREATE TABLE accept_reviewer_stats (
+    user_id               INTEGER PRIMARY KEY REFERENCES users(id),
+    answered              INTEGER NOT NULL DEFAULT 0,
+    accepted              INTEGER NOT NULL DEFAULT 0,
+    paired                INTEGER NOT NULL DEFAULT 0,
+    agreed                INTEGER NOT NULL DEFAULT 0,
+    lax_disagreements     INTEGER NOT NULL DEFAULT 0, -- 意见不一致时本人接纳、对方婉拒
+    harsh_disagreements   INTEGER NOT NULL DEFAULT 0, -- 意见不一致时本人婉拒、对方接纳
+    upheld                INTEGER NOT NULL DEFAULT 0,
+    overturned            INTEGER NOT NULL DEFAULT 0,
+    timed_out             INTEGER NOT NULL DEFAULT 0,
+    decided_alone         INTEGER NOT NULL DEFAULT 0,
+    score                 INTEGER NOT NULL DEFAULT 80,
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 本表建立前的评审意见取自 acceptances
+INSERT INTO accept_review_votes (accept_object_id, reviewer_user_id, accept, co_reviewer_user_id, agreed, created_at)
+SELECT a.accept_object_id, a.x_user_id, a.x_accept, NULLIF(a.y_user_id, 0),
+       CASE WHEN a.y_user_id > 0 THEN a.x_accept = a.y_accept END, a.x_accepted_at
+FROM acceptances a
+JOIN accept_objects o ON o.id = a.accept_object_id
+JOIN users u ON u.id = a.x_user_id
+WHERE a.x_user_id > 0
+ON CONFLICT DO NOTHING;
+
+INSERT INTO accept_review_votes (accept_object_id, reviewer_user_id, accept, co_reviewer_user_id, agreed, created_at)
+SELECT a.accept_object_id, a.y_user_id, a.y_accept, NULLIF(a.x_user_id, 0), a.x_accept = a.y_accept, a.y_accepted_at
+FROM acceptances a
+JOIN accept_objects o ON o.id = a.accept_object_id
+JOIN users u ON u.id = a.y_user_id
+WHERE a.y_user_id > 0 AND a.y_user_id <> a.x_user_id AND a.y_accepted_at IS NOT NULL
+ON CONFLICT DO NOTHING;
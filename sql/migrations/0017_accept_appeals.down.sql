@@ -0,0 +1,3 @@
+ALTER TABLE draft_posts DROP COLUMN IF EXISTS rejected_from_class;
+DROP INDEX IF EXISTS idx_accept_objects_target;
+DROP TABLE IF EXISTS accept_appeals;
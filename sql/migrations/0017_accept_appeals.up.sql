@@ -0,0 +1,43 @@
+-- ============================================
+-- 友邻蒙评申诉
+-- 作者对友邻蒙评婉拒的对象提交修订稿及申诉理由，每次申诉记一行；
+-- 申诉时对象恢复为草稿并换上修订稿，另建一个蒙评对象作为复审一轮，
+-- 由新的一对友邻评审官复审，最后一次申诉（或找不到评审官时）由茶博士复审。
+-- 同一对象历次蒙评对象按 id 排列即其结论链。
+-- ============================================
+
+-- 友邻蒙评申诉表（完全匹配AcceptAppeal结构体）
+CREATE TABLE accept_appeals (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    accept_object_id      INTEGER NOT NULL REFERENCES accept_objects(id), -- 被申诉的一轮
+    review_accept_object_id INTEGER NOT NULL REFERENCES accept_objects(id), -- 复审的一轮
+    object_type           INTEGER NOT NULL,
+    object_id             INTEGER NOT NULL,
+    author_user_id        INTEGER NOT NULL REFERENCES users(id),
+    justification         TEXT NOT NULL,
+    original_title        TEXT NOT NULL DEFAULT '',
+    original_body         TEXT NOT NULL DEFAULT '',
+    revised_title         TEXT NOT NULL DEFAULT '',
+    revised_body          TEXT NOT NULL DEFAULT '',
+    reviewer              VARCHAR(16) NOT NULL DEFAULT 'neighbor', -- neighbor:友邻复审 office:茶博士复审
+    status                VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending:复审中 accepted:复审接纳 rejected:复审维持婉拒
+    decider_user_id       INTEGER REFERENCES users(id), -- 茶博士复审时的茶博士
+    decision_note         TEXT NOT NULL DEFAULT '',
+    published_id          INTEGER NOT NULL DEFAULT 0, -- 复审接纳后发布的对象，茶议、品味为新建的id
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at            TIMESTAMPTZ,
+    CONSTRAINT check_accept_appeals_reviewer CHECK (reviewer IN ('neighbor', 'office')),
+    CONSTRAINT check_accept_appeals_status CHECK (status IN ('pending', 'accepted', 'rejected')),
+    CONSTRAINT uq_accept_appeals_object UNIQUE (accept_object_id),
+    CONSTRAINT uq_accept_appeals_review_object UNIQUE (review_accept_object_id)
+);
+
+CREATE INDEX idx_accept_appeals_target ON accept_appeals(object_type, object_id);
+CREATE INDEX idx_accept_appeals_author ON accept_appeals(author_user_id, id DESC);
+CREATE INDEX idx_accept_appeals_pending ON accept_appeals(reviewer, created_at) WHERE status = 'pending';
+CREATE INDEX idx_accept_appeals_published ON accept_appeals(object_type, published_id) WHERE status = 'accepted';
+CREATE INDEX IF NOT EXISTS idx_accept_objects_target ON accept_objects(object_type, object_id);
+
+-- 品味草稿被婉拒时原来的发布级别，申诉复审时恢复
+ALTER TABLE draft_posts ADD COLUMN IF NOT EXISTS rejected_from_class INTEGER;
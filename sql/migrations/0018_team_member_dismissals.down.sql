@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS team_member_dismissal_votes;
+DROP TABLE IF EXISTS team_member_dismissals;
@@ -0,0 +1,51 @@
+-- ============================================
+-- 茶团成员开除、暂停
+-- 核心成员提出开除或暂停某位成员并说明理由，由CEO确认；对象是CEO本人时，由其余核心成员表决，过半数同意即确认；
+-- 确认后成员状态改为黑名单（开除）或暂停，该成员发起的待审批团队转账一律拒绝、设定的定期转账暂停；
+-- 暂停到期由后台任务 team_member_suspension_expire 恢复，CEO也可提前恢复。
+-- ============================================
+
+-- 开除、暂停记录表（完全匹配TeamMemberDismissal结构体）
+CREATE TABLE team_member_dismissals (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    team_id               INTEGER NOT NULL REFERENCES teams(id),
+    member_id             INTEGER NOT NULL REFERENCES team_members(id),
+    member_user_id        INTEGER NOT NULL REFERENCES users(id),
+    member_role           INTEGER NOT NULL, -- 时任角色
+    kind                  VARCHAR(16) NOT NULL, -- dismiss:开除 suspend:暂停
+    suspend_days          INTEGER NOT NULL DEFAULT 0,
+    reason                TEXT NOT NULL,
+    proposer_user_id      INTEGER NOT NULL REFERENCES users(id),
+    by_vote               BOOLEAN NOT NULL DEFAULT false, -- 对象是CEO，由核心成员表决
+    status                VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending:待确认 confirmed:已确认 rejected:已否决 withdrawn:已撤回
+    decider_user_id       INTEGER REFERENCES users(id), -- 确认或否决的CEO，表决时为空
+    decision_note         TEXT NOT NULL DEFAULT '',
+    rejected_transfers    INTEGER NOT NULL DEFAULT 0, -- 确认时拒绝的待审批团队转账数
+    paused_schedules      INTEGER NOT NULL DEFAULT 0, -- 确认时暂停的定期转账数
+    suspended_until       TIMESTAMPTZ,
+    lifted_at             TIMESTAMPTZ, -- 暂停解除时间
+    lifted_by_user_id     INTEGER REFERENCES users(id), -- 提前恢复的CEO，到期自动恢复时为空
+    member_read_at        TIMESTAMPTZ, -- 成员本人查看时间
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at            TIMESTAMPTZ,
+    CONSTRAINT check_team_member_dismissals_kind CHECK (kind IN ('dismiss', 'suspend')),
+    CONSTRAINT check_team_member_dismissals_status CHECK (status IN ('pending', 'confirmed', 'rejected', 'withdrawn'))
+);
+
+-- 同一成员同时只能有一份待确认的提议
+CREATE UNIQUE INDEX uq_team_member_dismissals_pending ON team_member_dismissals(member_id) WHERE status = 'pending';
+CREATE INDEX idx_team_member_dismissals_team ON team_member_dismissals(team_id, id DESC);
+CREATE INDEX idx_team_member_dismissals_member_user ON team_member_dismissals(member_user_id, id DESC);
+CREATE INDEX idx_team_member_dismissals_suspended ON team_member_dismissals(suspended_until)
+    WHERE kind = 'suspend' AND status = 'confirmed' AND lifted_at IS NULL;
+
+-- 核心成员表决表（完全匹配TeamMemberDismissalVote结构体）
+CREATE TABLE team_member_dismissal_votes (
+    id                    SERIAL PRIMARY KEY,
+    dismissal_id          INTEGER NOT NULL REFERENCES team_member_dismissals(id),
+    voter_user_id         INTEGER NOT NULL REFERENCES users(id),
+    agree                 BOOLEAN NOT NULL,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_team_member_dismissal_votes UNIQUE (dismissal_id, voter_user_id)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS expiry_notices;
+ALTER TABLE family_member_sign_ins DROP COLUMN IF EXISTS renewed_at;
+ALTER TABLE group_invitations DROP COLUMN IF EXISTS renewed_at;
+ALTER TABLE member_applications DROP COLUMN IF EXISTS renewed_at;
+ALTER TABLE invitations DROP COLUMN IF EXISTS renewed_at;
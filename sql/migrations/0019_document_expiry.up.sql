@@ -0,0 +1,30 @@
+-- ============================================
+-- 邀请函、申请书、声明书自动过期
+-- 茶团邀请函、加盟申请书、集团邀请函、家庭成员声明书超过各自期限（按创建或最近续期时间计）仍未答复的，
+-- 由后台任务 document_expiry 标记为已过期，并给双方各发一条过期提醒；发出方可在提醒中续期，重新等待对方答复。
+-- ============================================
+
+-- 最近续期时间，为空表示未续期
+ALTER TABLE invitations ADD COLUMN renewed_at TIMESTAMPTZ;
+ALTER TABLE member_applications ADD COLUMN renewed_at TIMESTAMPTZ;
+ALTER TABLE group_invitations ADD COLUMN renewed_at TIMESTAMPTZ;
+ALTER TABLE family_member_sign_ins ADD COLUMN renewed_at TIMESTAMPTZ;
+
+-- 过期提醒表（完全匹配ExpiryNotice结构体）
+CREATE TABLE expiry_notices (
+    id                    SERIAL PRIMARY KEY,
+    uuid                  VARCHAR(64) NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    user_id               INTEGER NOT NULL REFERENCES users(id), -- 收到提醒的茶友
+    doc_type              VARCHAR(32) NOT NULL, -- team_invitation, member_application, group_invitation, family_sign_in
+    doc_id                INTEGER NOT NULL,
+    doc_uuid              VARCHAR(255) NOT NULL,
+    is_sender             BOOLEAN NOT NULL DEFAULT false, -- 是发出方，可以续期
+    content               TEXT NOT NULL,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at               TIMESTAMPTZ,
+    renewed_at            TIMESTAMPTZ, -- 经此提醒续期的时间
+    CONSTRAINT check_expiry_notices_doc_type CHECK (doc_type IN ('team_invitation', 'member_application', 'group_invitation', 'family_sign_in'))
+);
+
+CREATE INDEX idx_expiry_notices_user ON expiry_notices(user_id, id DESC);
+CREATE INDEX idx_expiry_notices_doc ON expiry_notices(doc_type, doc_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS tea.idx_exchange_orders_refund_pending;
+ALTER TABLE tea.exchange_orders DROP COLUMN IF EXISTS refunded_at;
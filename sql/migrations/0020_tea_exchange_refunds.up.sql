@@ -0,0 +1,14 @@
+-- ============================================
+-- 茶庄星茶兑换订单退款记录
+-- 否决已付款的购买订单时先提交否决（rejected），再向支付渠道退款；退款成功记下 refunded_at，
+-- 失败记下 last_error，由茶博士/船长重试，渠道侧以订单uuid为幂等键，重试不会重复退款。
+-- ============================================
+
+ALTER TABLE tea.exchange_orders ADD COLUMN refunded_at TIMESTAMPTZ;
+
+-- 此前否决的已付款订单均在否决前退款完成
+UPDATE tea.exchange_orders SET refunded_at = reviewed_at
+WHERE kind = 'purchase' AND status = 'rejected' AND paid_at IS NOT NULL;
+
+CREATE INDEX idx_exchange_orders_refund_pending ON tea.exchange_orders(id)
+    WHERE kind = 'purchase' AND status = 'rejected' AND paid_at IS NOT NULL AND refunded_at IS NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tea.team_transfer_policy_changes;
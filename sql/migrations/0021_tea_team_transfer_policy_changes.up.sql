@@ -0,0 +1,33 @@
+-- ============================================
+-- 团队星茶转出审批策略修改记录
+-- 策略只能由团队CEO或创建人修改，每次修改记一行（修改前、修改后的取值）；
+-- 收紧（提高批准人数、降低阈值或上限、禁止自批）立即生效（applied）；
+-- 放宽的部分排期（scheduled），到 effective_at 才生效，生效前再次修改则原排期作废（superseded）。
+-- ============================================
+
+-- 策略修改记录表（完全匹配TeaTeamTransferPolicyChange结构体）
+CREATE TABLE tea.team_transfer_policy_changes (
+    id                                    SERIAL PRIMARY KEY,
+    team_id                               INTEGER NOT NULL REFERENCES teams(id),
+    changed_by_user_id                    INTEGER NOT NULL REFERENCES users(id),
+    old_large_amount_threshold_milligrams BIGINT NOT NULL,
+    old_large_amount_approvals            INTEGER NOT NULL,
+    old_forbid_self_approval              BOOLEAN NOT NULL,
+    old_daily_outflow_cap_milligrams      BIGINT NOT NULL,
+    new_large_amount_threshold_milligrams BIGINT NOT NULL,
+    new_large_amount_approvals            INTEGER NOT NULL,
+    new_forbid_self_approval              BOOLEAN NOT NULL,
+    new_daily_outflow_cap_milligrams      BIGINT NOT NULL,
+    status                                VARCHAR(16) NOT NULL, -- applied:已生效 scheduled:待生效 superseded:已作废
+    effective_at                          TIMESTAMPTZ NOT NULL,
+    created_at                            TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_team_transfer_policy_changes_status CHECK (status IN ('applied', 'scheduled', 'superseded')),
+    CONSTRAINT check_team_transfer_policy_changes_approvals CHECK (new_large_amount_approvals BETWEEN 1 AND 9),
+    CONSTRAINT check_team_transfer_policy_changes_amounts CHECK (new_large_amount_threshold_milligrams >= 0 AND new_daily_outflow_cap_milligrams >= 0)
+);
+
+CREATE INDEX idx_team_transfer_policy_changes_team ON tea.team_transfer_policy_changes(team_id, id DESC);
+-- 每个团队最多一条待生效的放宽
+CREATE UNIQUE INDEX idx_team_transfer_policy_changes_scheduled ON tea.team_transfer_policy_changes(team_id) WHERE status = 'scheduled';
+
+COMMENT ON TABLE tea.team_transfer_policy_changes IS '团队星茶转出审批策略修改记录';
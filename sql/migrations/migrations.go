@@ -0,0 +1,18 @@
+package migrations
+
+import "embed"
+
+/*
+   数据库迁移脚本，编译进程序供 teachat migrate 使用。
+
+   文件名形如 0003_password_resets.up.sql / 0003_password_resets.down.sql：
+   四位版本号决定执行顺序，已发布的迁移不要再修改，结构变更一律新增下一个版本号的迁移。
+   down 脚本可省略（如基线迁移），此时该版本不可回滚。
+   seed_data.sql 为预填充数据，可重复执行。
+*/
+
+//go:embed *.sql
+var FS embed.FS
+
+// SeedFile 预填充数据脚本的文件名
+const SeedFile = "seed_data.sql"
@@ -1,5 +1,6 @@
 -- TeaChat 预填充数据
 -- 用于开发和测试环境的初始数据
+-- 由 teachat migrate seed（或 migrate up -seed）执行，可重复执行：已存在的数据不会重复插入
 
 -- ============================================
 -- 预设用户数据
@@ -7,7 +8,8 @@
 
 INSERT INTO users (id, uuid, name, email, password, biography, role, gender, avatar) VALUES
 (1, '396d7fac-2f29-44a7-7f77-63cbaf423438', '太空船长', 'teachat-captain@spacetravels.com', 'df2983700ffecb52e6649f0cb3981b66537083a4', '我是太空船长，欢迎来到星际茶会！', 'captain', 1, 'teaSet'),
-(2, '070a7e98-d5ab-4506-4e59-093a053bc32b', '稻香老农', 'teachat-verifier@storetravels.com', 'df2983700ffecb52e6649f0cb3981b66537083a4', '我是见证团队，欢迎来到星际茶会！', 'traveller', 0, 'teaSet');
+(2, '070a7e98-d5ab-4506-4e59-093a053bc32b', '稻香老农', 'teachat-verifier@storetravels.com', 'df2983700ffecb52e6649f0cb3981b66537083a4', '我是见证团队，欢迎来到星际茶会！', 'traveller', 0, 'teaSet')
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 预设团队数据
@@ -18,7 +20,8 @@ INSERT INTO teams (id, uuid, name, mission, founder_id, class, nature, abbreviat
 (2, 'dcbe3046-b192-44b6-7afb-bc55817c13a9', '茶棚服务团队', '飞船机组乘员茶棚服务团队，系统预设。', 1, 0, 1, '飞船茶棚', 'teamLogo', '系统团队,服务'),
 (3, '38be3046-b192-44b6-7afb-bc55817c13c4', '见证者茶团', '见证者团队，为线下茶会活动作业担当主持见证人，系统预设。', 2, 0, 1, '见证者', 'teamLogo', '见证者,系统'),
 (4, 'center-escrow-team-uuid', '茶庄服务中心', '处理茶庄事务，茶庄为线下茶会活动提供托管星茶服务，系统预设。', 1, 4, 1, '茶庄服务', 'teamLogo', '茶庄中心,系统'),
-(5, 'public-governance-team-uuid', '公共治理团队', '处理公共治理事务，公共治理团队为系统预设的特殊团队，负责接收见证人对违规恶意/不道德行为的处罚罚没星茶，系统预设。', 1, 5, 1, '公共治理', 'teamLogo', '公共治理,系统');
+(5, 'public-governance-team-uuid', '公共治理团队', '处理公共治理事务，公共治理团队为系统预设的特殊团队，负责接收见证人对违规恶意/不道德行为的处罚罚没星茶，系统预设。', 1, 5, 1, '公共治理', 'teamLogo', '公共治理,系统')
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 预设团队成员数据
@@ -30,26 +33,37 @@ INSERT INTO team_members (id, uuid, team_id, user_id, role, status) VALUES
 (3, 'member-002-verifier-team1', 1, 2, 5, 1), -- 稻香老农加入自由人团队
 (4, 'member-001-verifier-team3', 3, 2, 1, 1), -- 稻香老农加入见证者团队
 (5, 'member-001-escrow-team4', 4, 1, 1, 1), -- 太空船长加入茶庄服务中心
-(6, 'member-001-public-governance-team5', 5, 1, 1, 1); -- 太空船长加入公共治理团队
-INSERT INTO user_default_teams (user_id, team_id) VALUES
-(1, 2), -- 太空船长默认团队为茶棚服务团队
-(2, 3); -- 稻香老农默认团队为见证者茶团
+(6, 'member-001-public-governance-team5', 5, 1, 1, 1)  -- 太空船长加入公共治理团队
+ON CONFLICT DO NOTHING;
+INSERT INTO user_default_teams (user_id, team_id)
+SELECT v.user_id, v.team_id
+FROM (VALUES
+    (1, 2), -- 太空船长默认团队为茶棚服务团队
+    (2, 3)  -- 稻香老农默认团队为见证者茶团
+) AS v(user_id, team_id)
+WHERE NOT EXISTS (SELECT 1 FROM user_default_teams d WHERE d.user_id = v.user_id);
 
 -- ============================================
 -- 预设地方数据 
 -- ============================================
 INSERT INTO places (id, uuid, name, nickname, description, icon, occupant_user_id, owner_user_id, level, category, is_public, is_government, user_id, created_at) VALUES
-(1, 'place-001-spaceship-teabar', '星际茶棚', 'Spaceship Teabar', '星际茶棚', 'spaceship-teabar', 1, 1, 4, 1, true, false, 1, '2025-05-07T17:17:07Z');
+(1, 'place-001-spaceship-teabar', '星际茶棚', 'Spaceship Teabar', '星际茶棚', 'spaceship-teabar', 1, 1, 4, 1, true, false, 1, '2025-05-07T17:17:07Z')
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 环境数据
 -- ============================================
 
-INSERT INTO environments (name, summary,user_id, temperature, humidity, pm25, noise, light, wind, flow, rain, pressure, smoke, dust, odor, visibility) VALUES
-('室内普通环境', '室内正常环境，温度适宜，光线较好，通风良好', 1, 3, 3, 5, 4, 2, 5, 5, 5, 3, 5, 5, 5, 4),
-('室外晴朗天气', '室外正常环境，温度适宜，光线强烈，通风非常好', 1, 3, 3, 5, 4, 2, 5, 5, 5, 3, 5, 5, 5, 4),
-('普通家庭', '普通的家庭环境，温度适宜，光线良好，通风良好', 1, 3, 3, 5, 4, 2, 5, 5, 5, 3, 5, 5, 5, 4),
-('车辆维修车间', '一般的车辆维修车间，光线足，通风好，但有一些机械噪音，轻微尾气等', 1, 3, 3, 5, 4, 2, 5, 5, 5, 3, 5, 5, 5, 4),
+-- 环境没有预设uuid，按名称判断是否已存在
+INSERT INTO environments (name, summary, user_id, temperature, humidity, pm25, noise, light, wind, flow, rain, pressure, smoke, dust, odor, visibility)
+SELECT v.*
+FROM (VALUES
+    ('室内普通环境', '室内正常环境，温度适宜，光线较好，通风良好', 1, 3, 3, 5, 4, 2, 5, 5, 5, 3, 5, 5, 5, 4),
+    ('室外晴朗天气', '室外正常环境，温度适宜，光线强烈，通风非常好', 1, 3, 3, 5, 4, 2, 5, 5, 5, 3, 5, 5, 5, 4),
+    ('普通家庭', '普通的家庭环境，温度适宜，光线良好，通风良好', 1, 3, 3, 5, 4, 2, 5, 5, 5, 3, 5, 5, 5, 4),
+    ('车辆维修车间', '一般的车辆维修车间，光线足，通风好，但有一些机械噪音，轻微尾气等', 1, 3, 3, 5, 4, 2, 5, 5, 5, 3, 5, 5, 5, 4)
+) AS v(name, summary, user_id, temperature, humidity, pm25, noise, light, wind, flow, rain, pressure, smoke, dust, odor, visibility)
+WHERE NOT EXISTS (SELECT 1 FROM environments e WHERE e.name = v.name);
 
 -- ============================================
 -- 安全隐患数据
@@ -58,7 +72,8 @@ INSERT INTO environments (name, summary,user_id, temperature, humidity, pm25, no
 INSERT INTO hazards (uuid, user_id, name, nickname, keywords, description, source, severity, category) VALUES
 ('hazard-001-damaged-guardrail', 1, '护栏破损', '防护栏损坏', '护栏,破损,防护,栏杆', '作业场所的安全护栏出现破损、松动或缺失，无法提供有效的防护作用，存在人员意外跌落的隐患。', '设施老化缺乏维护', 4, 2),
 ('hazard-002-high-temp-source', 1, '高温热源', '热源隐患', '高温,热源,热表面,防护', '作业场所存在高温设备、管道或表面，缺乏适当的防护罩或警示标识，人员意外接触可能造成烫伤。', '设备防护不当', 4, 2),
-('hazard-003-exposed-wire', 1, '电线裸露', '电线隐患', '电线,裸露,绝缘,电气', '作业场所的电线绝缘层破损或老化，导致电线裸露，存在人员意外接触导致触电事故的隐患。', '电线老化缺乏维护', 5, 1);
+('hazard-003-exposed-wire', 1, '电线裸露', '电线隐患', '电线,裸露,绝缘,电气', '作业场所的电线绝缘层破损或老化，导致电线裸露，存在人员意外接触导致触电事故的隐患。', '电线老化缺乏维护', 5, 1)
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 风险数据
@@ -67,7 +82,8 @@ INSERT INTO hazards (uuid, user_id, name, nickname, keywords, description, sourc
 INSERT INTO risks (uuid, user_id, name, nickname, keywords, description, source, severity) VALUES
 ('risk-001-uuid', 1, '高空坠落', '坠高险', '高空,坠落,安全带', '在≥2米无护栏平台作业时存在坠落风险，可能导致重伤或死亡', '环境', 5),
 ('risk-002-uuid', 1, '高温烫伤', '烫伤险', '高温,烫伤,防护', '接触高温设备或介质时可能造成皮肤烫伤，温度≥60℃时触发', '设备', 4),
-('risk-003-uuid', 1, '触电风险', '电击险', '触电,电击,绝缘', '接触带电设备或线路时可能发生电击事故，电压≥36V时存在风险', '设备', 5);
+('risk-003-uuid', 1, '触电风险', '电击险', '触电,电击,绝缘', '接触带电设备或线路时可能发生电击事故，电压≥36V时存在风险', '设备', 5)
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 通用技能数据
@@ -77,13 +93,15 @@ INSERT INTO risks (uuid, user_id, name, nickname, keywords, description, source,
 INSERT INTO skills (uuid, user_id, name, nickname, description, strength_level, difficulty_level, category, level) VALUES
 ('skill-001-self-care', 1, '生活自理', '基础生活技能', '包括穿衣、吃饭、洗澡、个人卫生等基本生活技能，是成年人应具备的基础能力', 1, 1, 2, 1),
 ('skill-002-walking', 1, '行走', '步行技能', '正常的步行能力，包括平地行走、上下楼梯、保持平衡等基本移动技能', 2, 1, 2, 1),
-('skill-003-basic-communication', 1, '基础沟通', '日常交流', '基本的语言表达和理解能力，能够进行日常对话和信息交换', 1, 2, 2, 1);
+('skill-003-basic-communication', 1, '基础沟通', '日常交流', '基本的语言表达和理解能力，能够进行日常对话和信息交换', 1, 2, 2, 1)
+ON CONFLICT DO NOTHING;
 
 -- 通用软技能
 INSERT INTO skills (uuid, user_id, name, nickname, description, strength_level, difficulty_level, category, level) VALUES
 ('skill-004-smile', 1, '微笑', '情绪表达', '通过面部表情表达友善和积极情绪，是基本的社交和情绪管理技能', 1, 1, 1, 1),
 ('skill-005-patience', 1, '耐心', '情绪控制', '在面对困难或等待时保持冷静和坚持的能力，是重要的情绪管理技能', 1, 3, 1, 1),
-('skill-006-empathy', 1, '共情', '理解他人', '理解和感受他人情感的能力，是良好人际关系的基础技能', 1, 3, 1, 1);
+('skill-006-empathy', 1, '共情', '理解他人', '理解和感受他人情感的能力，是良好人际关系的基础技能', 1, 3, 1, 1)
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 通用法力数据
@@ -93,13 +111,15 @@ INSERT INTO skills (uuid, user_id, name, nickname, description, strength_level,
 INSERT INTO magics (uuid, user_id, name, nickname, description, intelligence_level, difficulty_level, category, level) VALUES
 ('magic-001-observation', 1, '观察', '观察力', '通过感官收集和分析环境信息的能力，是认知和学习的基础', 2, 2, 1, 1),
 ('magic-002-memory', 1, '记忆', '记忆力', '存储和回忆信息的能力，包括短期记忆和长期记忆', 3, 2, 1, 1),
-('magic-003-logic', 1, '逻辑思维', '推理能力', '运用逻辑规则进行推理和分析的思维能力', 4, 4, 1, 1);
+('magic-003-logic', 1, '逻辑思维', '推理能力', '运用逻辑规则进行推理和分析的思维能力', 4, 4, 1, 1)
+ON CONFLICT DO NOTHING;
 
 -- 感性法力
 INSERT INTO magics (uuid, user_id, name, nickname, description, intelligence_level, difficulty_level, category, level) VALUES
 ('magic-004-intuition', 1, '直觉', '第六感', '不经过逻辑推理而直接感知和判断的能力', 3, 4, 2, 1),
 ('magic-005-creativity', 1, '创造力', '创新思维', '产生新颖想法和解决方案的能力，是艺术和创新的基础', 4, 5, 2, 1),
-('magic-006-aesthetic', 1, '审美', '美感', '感受和欣赏美的能力，包括对艺术、自然等的审美判断', 3, 3, 2, 1);
+('magic-006-aesthetic', 1, '审美', '美感', '感受和欣赏美的能力，包括对艺术、自然等的审美判断', 3, 3, 2, 1)
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 预设集团数据 —— 见证者集团（系统预设）
@@ -118,7 +138,8 @@ VALUES (
     1,  -- 职业集团（GroupNatureProfessional）
     'groupLogo',
     '见证者集团,系统'
-);
+)
+ON CONFLICT DO NOTHING;
 
 -- 将现有『见证者茶团』(id=3) 加入见证者集团为第一团队（最高管理团队）
 INSERT INTO group_members (uuid, group_id, team_id, level, role, status, user_id)
@@ -130,11 +151,13 @@ VALUES (
     1,  -- 最高管理团队（GroupRoleTopManagement）
     1,  -- 活跃状态（GroupMemberStatusActive）
     1   -- 操作用户：太空船长
-);
+)
+ON CONFLICT DO NOTHING;
 
 -- 记录『见证者茶团』(id=3) 加入『见证者集团』(id=1)
 INSERT INTO team_group_memberships (group_id, team_id) VALUES
-(1, 3);
+(1, 3)
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 行业分类白名单（参考《国民经济行业分类》20个门类）
@@ -160,22 +183,42 @@ INSERT INTO industry_tags (name, category, description) VALUES
 ('卫生和社会工作', 'Q', '卫生、社会工作'),
 ('文化、体育和娱乐业', 'R', '新闻和出版业、广播影视录音制作业、文化艺术业、体育、娱乐业'),
 ('公共管理、社会保障和社会组织', 'S', '国家机构、人民政协民主党派、社会保障、群众团体社会团体和其他成员组织、基层群众自治组织'),
-('国际组织', 'T', '国际组织');
+('国际组织', 'T', '国际组织')
+ON CONFLICT DO NOTHING;
 
 -- ============================================
 -- 修复序列
 -- ============================================
 
-SELECT setval('users_id_seq', (SELECT MAX(id) FROM users));
-SELECT setval('teams_id_seq', (SELECT MAX(id) FROM teams));
-SELECT setval('places_id_seq', (SELECT MAX(id) FROM places));
-SELECT setval('hazards_id_seq', (SELECT MAX(id) FROM hazards));
-SELECT setval('risks_id_seq', (SELECT MAX(id) FROM risks));
-SELECT setval('skills_id_seq', (SELECT MAX(id) FROM skills));
-SELECT setval('magics_id_seq', (SELECT MAX(id) FROM magics));
-SELECT setval('environments_id_seq', (SELECT MAX(id) FROM environments));
-SELECT setval('team_members_id_seq', (SELECT MAX(id) FROM team_members));
-SELECT setval('groups_id_seq', (SELECT MAX(id) FROM groups));
-SELECT setval('group_members_id_seq', (SELECT MAX(id) FROM group_members));
-SELECT setval('industry_tags_id_seq', (SELECT MAX(id) FROM industry_tags));
+SELECT setval('users_id_seq', COALESCE((SELECT MAX(id) FROM users), 1));
+SELECT setval('teams_id_seq', COALESCE((SELECT MAX(id) FROM teams), 1));
+SELECT setval('places_id_seq', COALESCE((SELECT MAX(id) FROM places), 1));
+SELECT setval('hazards_id_seq', COALESCE((SELECT MAX(id) FROM hazards), 1));
+SELECT setval('risks_id_seq', COALESCE((SELECT MAX(id) FROM risks), 1));
+SELECT setval('skills_id_seq', COALESCE((SELECT MAX(id) FROM skills), 1));
+SELECT setval('magics_id_seq', COALESCE((SELECT MAX(id) FROM magics), 1));
+SELECT setval('environments_id_seq', COALESCE((SELECT MAX(id) FROM environments), 1));
+SELECT setval('team_members_id_seq', COALESCE((SELECT MAX(id) FROM team_members), 1));
+SELECT setval('groups_id_seq', COALESCE((SELECT MAX(id) FROM groups), 1));
+SELECT setval('group_members_id_seq', COALESCE((SELECT MAX(id) FROM group_members), 1));
+SELECT setval('industry_tags_id_seq', COALESCE((SELECT MAX(id) FROM industry_tags), 1));
+
+-- ============================================
+-- 预设茶友、团队的星茶账户
+-- ============================================
+
+INSERT INTO tea.user_accounts (user_id, balance_milligrams, locked_balance_milligrams, status)
+SELECT id, 0, 0, 'normal'
+FROM users u
+WHERE NOT EXISTS (
+    SELECT 1 FROM tea.user_accounts tua
+    WHERE tua.user_id = u.id
+);
 
+INSERT INTO tea.team_accounts (team_id, balance_milligrams, locked_balance_milligrams, status)
+SELECT id, 0, 0, 'normal'
+FROM teams t
+WHERE NOT EXISTS (
+    SELECT 1 FROM tea.team_accounts tta
+    WHERE tta.team_id = t.id
+);